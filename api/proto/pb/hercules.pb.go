@@ -465,11 +465,15 @@ func (x *GetAnalysisStatusRequest) GetJobId() string {
 
 // Analysis result
 type AnalysisResult struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
-	ResultType    string                 `protobuf:"bytes,3,opt,name=result_type,json=resultType,proto3" json:"result_type,omitempty"`
-	Data          *anypb.Any             `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Name        string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	ResultType  string                 `protobuf:"bytes,3,opt,name=result_type,json=resultType,proto3" json:"result_type,omitempty"`
+	Data        *anypb.Any             `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	// serialization format of `content`: "pb" or "json"
+	Format string `protobuf:"bytes,5,opt,name=format,proto3" json:"format,omitempty"`
+	// the serialized leaf result as produced by LeafPipelineItem.Serialize()
+	Content       []byte `protobuf:"bytes,6,opt,name=content,proto3" json:"content,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -532,6 +536,20 @@ func (x *AnalysisResult) GetData() *anypb.Any {
 	return nil
 }
 
+func (x *AnalysisResult) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
 // Get analysis status response
 type GetAnalysisStatusResponse struct {
 	state         protoimpl.MessageState     `protogen:"open.v1"`
@@ -747,6 +765,218 @@ func (x *StreamAnalysisProgressResponse) GetTimestamp() *timestamppb.Timestamp {
 	return nil
 }
 
+// Cancel analysis request
+type CancelAnalysisRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelAnalysisRequest) Reset() {
+	*x = CancelAnalysisRequest{}
+	mi := &file_hercules_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelAnalysisRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelAnalysisRequest) ProtoMessage() {}
+
+func (x *CancelAnalysisRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hercules_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelAnalysisRequest.ProtoReflect.Descriptor instead.
+func (*CancelAnalysisRequest) Descriptor() ([]byte, []int) {
+	return file_hercules_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CancelAnalysisRequest) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+// Cancel analysis response
+type CancelAnalysisResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Status        string                 `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	JobId         string                 `protobuf:"bytes,3,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelAnalysisResponse) Reset() {
+	*x = CancelAnalysisResponse{}
+	mi := &file_hercules_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelAnalysisResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelAnalysisResponse) ProtoMessage() {}
+
+func (x *CancelAnalysisResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hercules_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelAnalysisResponse.ProtoReflect.Descriptor instead.
+func (*CancelAnalysisResponse) Descriptor() ([]byte, []int) {
+	return file_hercules_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *CancelAnalysisResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *CancelAnalysisResponse) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *CancelAnalysisResponse) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *CancelAnalysisResponse) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+// Metrics request
+type GetMetricsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMetricsRequest) Reset() {
+	*x = GetMetricsRequest{}
+	mi := &file_hercules_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMetricsRequest) ProtoMessage() {}
+
+func (x *GetMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_hercules_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMetricsRequest.ProtoReflect.Descriptor instead.
+func (*GetMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_hercules_proto_rawDescGZIP(), []int{14}
+}
+
+// Metrics response
+type GetMetricsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ContentType   string                 `protobuf:"bytes,1,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Text          string                 `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	Timestamp     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMetricsResponse) Reset() {
+	*x = GetMetricsResponse{}
+	mi := &file_hercules_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMetricsResponse) ProtoMessage() {}
+
+func (x *GetMetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_hercules_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMetricsResponse.ProtoReflect.Descriptor instead.
+func (*GetMetricsResponse) Descriptor() ([]byte, []int) {
+	return file_hercules_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetMetricsResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *GetMetricsResponse) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *GetMetricsResponse) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
 var File_hercules_proto protoreflect.FileDescriptor
 
 const file_hercules_proto_rawDesc = "" +
@@ -785,13 +1015,15 @@ const file_hercules_proto_rawDesc = "" +
 	"\x06job_id\x18\x03 \x01(\tR\x05jobId\x128\n" +
 	"\ttimestamp\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"1\n" +
 	"\x18GetAnalysisStatusRequest\x12\x15\n" +
-	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\x91\x01\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\xc3\x01\n" +
 	"\x0eAnalysisResult\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12 \n" +
 	"\vdescription\x18\x02 \x01(\tR\vdescription\x12\x1f\n" +
 	"\vresult_type\x18\x03 \x01(\tR\n" +
 	"resultType\x12(\n" +
-	"\x04data\x18\x04 \x01(\v2\x14.google.protobuf.AnyR\x04data\"\xb8\x03\n" +
+	"\x04data\x18\x04 \x01(\v2\x14.google.protobuf.AnyR\x04data\x12\x16\n" +
+	"\x06format\x18\x05 \x01(\tR\x06format\x12\x18\n" +
+	"\acontent\x18\x06 \x01(\fR\acontent\"\xb8\x03\n" +
 	"\x19GetAnalysisStatusResponse\x12\x16\n" +
 	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12\x15\n" +
@@ -811,13 +1043,28 @@ const file_hercules_proto_rawDesc = "" +
 	"\x06status\x18\x02 \x01(\tR\x06status\x12\x18\n" +
 	"\amessage\x18\x03 \x01(\tR\amessage\x12)\n" +
 	"\x10progress_percent\x18\x04 \x01(\x05R\x0fprogressPercent\x128\n" +
-	"\ttimestamp\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp2\xdd\x03\n" +
+	"\ttimestamp\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\".\n" +
+	"\x15CancelAnalysisRequest\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\"\x9b\x01\n" +
+	"\x16CancelAnalysisResponse\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x15\n" +
+	"\x06job_id\x18\x03 \x01(\tR\x05jobId\x128\n" +
+	"\ttimestamp\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\"\x13\n" +
+	"\x11GetMetricsRequest\"\x85\x01\n" +
+	"\x12GetMetricsResponse\x12!\n" +
+	"\fcontent_type\x18\x01 \x01(\tR\vcontentType\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x128\n" +
+	"\ttimestamp\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp2\x87\x05\n" +
 	"\x0fHerculesService\x12A\n" +
 	"\x06Health\x12\x1a.hercules.v1.HealthRequest\x1a\x1b.hercules.v1.HealthResponse\x12S\n" +
 	"\fListAnalyses\x12 .hercules.v1.ListAnalysesRequest\x1a!.hercules.v1.ListAnalysesResponse\x12Y\n" +
 	"\x0eSubmitAnalysis\x12\".hercules.v1.SubmitAnalysisRequest\x1a#.hercules.v1.SubmitAnalysisResponse\x12b\n" +
 	"\x11GetAnalysisStatus\x12%.hercules.v1.GetAnalysisStatusRequest\x1a&.hercules.v1.GetAnalysisStatusResponse\x12s\n" +
-	"\x16StreamAnalysisProgress\x12*.hercules.v1.StreamAnalysisProgressRequest\x1a+.hercules.v1.StreamAnalysisProgressResponse0\x01B4Z2github.com/dmytrogajewski/hercules/api/proto/pb;pbb\x06proto3"
+	"\x16StreamAnalysisProgress\x12*.hercules.v1.StreamAnalysisProgressRequest\x1a+.hercules.v1.StreamAnalysisProgressResponse0\x01\x12Y\n" +
+	"\x0eCancelAnalysis\x12\".hercules.v1.CancelAnalysisRequest\x1a#.hercules.v1.CancelAnalysisResponse\x12M\n" +
+	"\n" +
+	"GetMetrics\x12\x1e.hercules.v1.GetMetricsRequest\x1a\x1f.hercules.v1.GetMetricsResponseB4Z2github.com/dmytrogajewski/hercules/api/proto/pb;pbb\x06proto3"
 
 var (
 	file_hercules_proto_rawDescOnce sync.Once
@@ -831,7 +1078,7 @@ func file_hercules_proto_rawDescGZIP() []byte {
 	return file_hercules_proto_rawDescData
 }
 
-var file_hercules_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_hercules_proto_msgTypes = make([]protoimpl.MessageInfo, 19)
 var file_hercules_proto_goTypes = []any{
 	(*HealthRequest)(nil),                  // 0: hercules.v1.HealthRequest
 	(*HealthResponse)(nil),                 // 1: hercules.v1.HealthResponse
@@ -845,41 +1092,51 @@ var file_hercules_proto_goTypes = []any{
 	(*GetAnalysisStatusResponse)(nil),      // 9: hercules.v1.GetAnalysisStatusResponse
 	(*StreamAnalysisProgressRequest)(nil),  // 10: hercules.v1.StreamAnalysisProgressRequest
 	(*StreamAnalysisProgressResponse)(nil), // 11: hercules.v1.StreamAnalysisProgressResponse
-	nil,                                    // 12: hercules.v1.HealthResponse.ConfigEntry
-	nil,                                    // 13: hercules.v1.SubmitAnalysisRequest.OptionsEntry
-	nil,                                    // 14: hercules.v1.GetAnalysisStatusResponse.ResultsEntry
-	(*timestamppb.Timestamp)(nil),          // 15: google.protobuf.Timestamp
-	(*anypb.Any)(nil),                      // 16: google.protobuf.Any
+	(*CancelAnalysisRequest)(nil),          // 12: hercules.v1.CancelAnalysisRequest
+	(*CancelAnalysisResponse)(nil),         // 13: hercules.v1.CancelAnalysisResponse
+	(*GetMetricsRequest)(nil),              // 14: hercules.v1.GetMetricsRequest
+	(*GetMetricsResponse)(nil),             // 15: hercules.v1.GetMetricsResponse
+	nil,                                    // 16: hercules.v1.HealthResponse.ConfigEntry
+	nil,                                    // 17: hercules.v1.SubmitAnalysisRequest.OptionsEntry
+	nil,                                    // 18: hercules.v1.GetAnalysisStatusResponse.ResultsEntry
+	(*timestamppb.Timestamp)(nil),          // 19: google.protobuf.Timestamp
+	(*anypb.Any)(nil),                      // 20: google.protobuf.Any
 }
 var file_hercules_proto_depIdxs = []int32{
-	15, // 0: hercules.v1.HealthResponse.timestamp:type_name -> google.protobuf.Timestamp
-	12, // 1: hercules.v1.HealthResponse.config:type_name -> hercules.v1.HealthResponse.ConfigEntry
+	19, // 0: hercules.v1.HealthResponse.timestamp:type_name -> google.protobuf.Timestamp
+	16, // 1: hercules.v1.HealthResponse.config:type_name -> hercules.v1.HealthResponse.ConfigEntry
 	3,  // 2: hercules.v1.ListAnalysesResponse.analyses:type_name -> hercules.v1.AnalysisType
-	15, // 3: hercules.v1.ListAnalysesResponse.timestamp:type_name -> google.protobuf.Timestamp
-	13, // 4: hercules.v1.SubmitAnalysisRequest.options:type_name -> hercules.v1.SubmitAnalysisRequest.OptionsEntry
-	15, // 5: hercules.v1.SubmitAnalysisResponse.timestamp:type_name -> google.protobuf.Timestamp
-	16, // 6: hercules.v1.AnalysisResult.data:type_name -> google.protobuf.Any
-	15, // 7: hercules.v1.GetAnalysisStatusResponse.start_time:type_name -> google.protobuf.Timestamp
-	15, // 8: hercules.v1.GetAnalysisStatusResponse.end_time:type_name -> google.protobuf.Timestamp
-	14, // 9: hercules.v1.GetAnalysisStatusResponse.results:type_name -> hercules.v1.GetAnalysisStatusResponse.ResultsEntry
-	15, // 10: hercules.v1.GetAnalysisStatusResponse.timestamp:type_name -> google.protobuf.Timestamp
-	15, // 11: hercules.v1.StreamAnalysisProgressResponse.timestamp:type_name -> google.protobuf.Timestamp
-	8,  // 12: hercules.v1.GetAnalysisStatusResponse.ResultsEntry.value:type_name -> hercules.v1.AnalysisResult
-	0,  // 13: hercules.v1.HerculesService.Health:input_type -> hercules.v1.HealthRequest
-	2,  // 14: hercules.v1.HerculesService.ListAnalyses:input_type -> hercules.v1.ListAnalysesRequest
-	5,  // 15: hercules.v1.HerculesService.SubmitAnalysis:input_type -> hercules.v1.SubmitAnalysisRequest
-	7,  // 16: hercules.v1.HerculesService.GetAnalysisStatus:input_type -> hercules.v1.GetAnalysisStatusRequest
-	10, // 17: hercules.v1.HerculesService.StreamAnalysisProgress:input_type -> hercules.v1.StreamAnalysisProgressRequest
-	1,  // 18: hercules.v1.HerculesService.Health:output_type -> hercules.v1.HealthResponse
-	4,  // 19: hercules.v1.HerculesService.ListAnalyses:output_type -> hercules.v1.ListAnalysesResponse
-	6,  // 20: hercules.v1.HerculesService.SubmitAnalysis:output_type -> hercules.v1.SubmitAnalysisResponse
-	9,  // 21: hercules.v1.HerculesService.GetAnalysisStatus:output_type -> hercules.v1.GetAnalysisStatusResponse
-	11, // 22: hercules.v1.HerculesService.StreamAnalysisProgress:output_type -> hercules.v1.StreamAnalysisProgressResponse
-	18, // [18:23] is the sub-list for method output_type
-	13, // [13:18] is the sub-list for method input_type
-	13, // [13:13] is the sub-list for extension type_name
-	13, // [13:13] is the sub-list for extension extendee
-	0,  // [0:13] is the sub-list for field type_name
+	19, // 3: hercules.v1.ListAnalysesResponse.timestamp:type_name -> google.protobuf.Timestamp
+	17, // 4: hercules.v1.SubmitAnalysisRequest.options:type_name -> hercules.v1.SubmitAnalysisRequest.OptionsEntry
+	19, // 5: hercules.v1.SubmitAnalysisResponse.timestamp:type_name -> google.protobuf.Timestamp
+	20, // 6: hercules.v1.AnalysisResult.data:type_name -> google.protobuf.Any
+	19, // 7: hercules.v1.GetAnalysisStatusResponse.start_time:type_name -> google.protobuf.Timestamp
+	19, // 8: hercules.v1.GetAnalysisStatusResponse.end_time:type_name -> google.protobuf.Timestamp
+	18, // 9: hercules.v1.GetAnalysisStatusResponse.results:type_name -> hercules.v1.GetAnalysisStatusResponse.ResultsEntry
+	19, // 10: hercules.v1.GetAnalysisStatusResponse.timestamp:type_name -> google.protobuf.Timestamp
+	19, // 11: hercules.v1.StreamAnalysisProgressResponse.timestamp:type_name -> google.protobuf.Timestamp
+	19, // 12: hercules.v1.CancelAnalysisResponse.timestamp:type_name -> google.protobuf.Timestamp
+	19, // 13: hercules.v1.GetMetricsResponse.timestamp:type_name -> google.protobuf.Timestamp
+	8,  // 14: hercules.v1.GetAnalysisStatusResponse.ResultsEntry.value:type_name -> hercules.v1.AnalysisResult
+	0,  // 15: hercules.v1.HerculesService.Health:input_type -> hercules.v1.HealthRequest
+	2,  // 16: hercules.v1.HerculesService.ListAnalyses:input_type -> hercules.v1.ListAnalysesRequest
+	5,  // 17: hercules.v1.HerculesService.SubmitAnalysis:input_type -> hercules.v1.SubmitAnalysisRequest
+	7,  // 18: hercules.v1.HerculesService.GetAnalysisStatus:input_type -> hercules.v1.GetAnalysisStatusRequest
+	10, // 19: hercules.v1.HerculesService.StreamAnalysisProgress:input_type -> hercules.v1.StreamAnalysisProgressRequest
+	12, // 20: hercules.v1.HerculesService.CancelAnalysis:input_type -> hercules.v1.CancelAnalysisRequest
+	14, // 21: hercules.v1.HerculesService.GetMetrics:input_type -> hercules.v1.GetMetricsRequest
+	1,  // 22: hercules.v1.HerculesService.Health:output_type -> hercules.v1.HealthResponse
+	4,  // 23: hercules.v1.HerculesService.ListAnalyses:output_type -> hercules.v1.ListAnalysesResponse
+	6,  // 24: hercules.v1.HerculesService.SubmitAnalysis:output_type -> hercules.v1.SubmitAnalysisResponse
+	9,  // 25: hercules.v1.HerculesService.GetAnalysisStatus:output_type -> hercules.v1.GetAnalysisStatusResponse
+	11, // 26: hercules.v1.HerculesService.StreamAnalysisProgress:output_type -> hercules.v1.StreamAnalysisProgressResponse
+	13, // 27: hercules.v1.HerculesService.CancelAnalysis:output_type -> hercules.v1.CancelAnalysisResponse
+	15, // 28: hercules.v1.HerculesService.GetMetrics:output_type -> hercules.v1.GetMetricsResponse
+	22, // [22:29] is the sub-list for method output_type
+	15, // [15:22] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
 }
 
 func init() { file_hercules_proto_init() }
@@ -893,7 +1150,7 @@ func file_hercules_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_hercules_proto_rawDesc), len(file_hercules_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   15,
+			NumMessages:   19,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
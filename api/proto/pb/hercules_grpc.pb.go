@@ -24,6 +24,8 @@ const (
 	HerculesService_SubmitAnalysis_FullMethodName         = "/hercules.v1.HerculesService/SubmitAnalysis"
 	HerculesService_GetAnalysisStatus_FullMethodName      = "/hercules.v1.HerculesService/GetAnalysisStatus"
 	HerculesService_StreamAnalysisProgress_FullMethodName = "/hercules.v1.HerculesService/StreamAnalysisProgress"
+	HerculesService_CancelAnalysis_FullMethodName         = "/hercules.v1.HerculesService/CancelAnalysis"
+	HerculesService_GetMetrics_FullMethodName             = "/hercules.v1.HerculesService/GetMetrics"
 )
 
 // HerculesServiceClient is the client API for HerculesService service.
@@ -42,6 +44,10 @@ type HerculesServiceClient interface {
 	GetAnalysisStatus(ctx context.Context, in *GetAnalysisStatusRequest, opts ...grpc.CallOption) (*GetAnalysisStatusResponse, error)
 	// Stream analysis progress (optional)
 	StreamAnalysisProgress(ctx context.Context, in *StreamAnalysisProgressRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamAnalysisProgressResponse], error)
+	// Cancel a queued or running analysis
+	CancelAnalysis(ctx context.Context, in *CancelAnalysisRequest, opts ...grpc.CallOption) (*CancelAnalysisResponse, error)
+	// Server metrics in the Prometheus text format, the equivalent of HTTP GET /metrics
+	GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*GetMetricsResponse, error)
 }
 
 type herculesServiceClient struct {
@@ -111,6 +117,26 @@ func (c *herculesServiceClient) StreamAnalysisProgress(ctx context.Context, in *
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type HerculesService_StreamAnalysisProgressClient = grpc.ServerStreamingClient[StreamAnalysisProgressResponse]
 
+func (c *herculesServiceClient) CancelAnalysis(ctx context.Context, in *CancelAnalysisRequest, opts ...grpc.CallOption) (*CancelAnalysisResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CancelAnalysisResponse)
+	err := c.cc.Invoke(ctx, HerculesService_CancelAnalysis_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *herculesServiceClient) GetMetrics(ctx context.Context, in *GetMetricsRequest, opts ...grpc.CallOption) (*GetMetricsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetMetricsResponse)
+	err := c.cc.Invoke(ctx, HerculesService_GetMetrics_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // HerculesServiceServer is the server API for HerculesService service.
 // All implementations must embed UnimplementedHerculesServiceServer
 // for forward compatibility.
@@ -127,6 +153,10 @@ type HerculesServiceServer interface {
 	GetAnalysisStatus(context.Context, *GetAnalysisStatusRequest) (*GetAnalysisStatusResponse, error)
 	// Stream analysis progress (optional)
 	StreamAnalysisProgress(*StreamAnalysisProgressRequest, grpc.ServerStreamingServer[StreamAnalysisProgressResponse]) error
+	// Cancel a queued or running analysis
+	CancelAnalysis(context.Context, *CancelAnalysisRequest) (*CancelAnalysisResponse, error)
+	// Server metrics in the Prometheus text format, the equivalent of HTTP GET /metrics
+	GetMetrics(context.Context, *GetMetricsRequest) (*GetMetricsResponse, error)
 	mustEmbedUnimplementedHerculesServiceServer()
 }
 
@@ -152,6 +182,12 @@ func (UnimplementedHerculesServiceServer) GetAnalysisStatus(context.Context, *Ge
 func (UnimplementedHerculesServiceServer) StreamAnalysisProgress(*StreamAnalysisProgressRequest, grpc.ServerStreamingServer[StreamAnalysisProgressResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method StreamAnalysisProgress not implemented")
 }
+func (UnimplementedHerculesServiceServer) CancelAnalysis(context.Context, *CancelAnalysisRequest) (*CancelAnalysisResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelAnalysis not implemented")
+}
+func (UnimplementedHerculesServiceServer) GetMetrics(context.Context, *GetMetricsRequest) (*GetMetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetrics not implemented")
+}
 func (UnimplementedHerculesServiceServer) mustEmbedUnimplementedHerculesServiceServer() {}
 func (UnimplementedHerculesServiceServer) testEmbeddedByValue()                         {}
 
@@ -256,6 +292,42 @@ func _HerculesService_StreamAnalysisProgress_Handler(srv interface{}, stream grp
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type HerculesService_StreamAnalysisProgressServer = grpc.ServerStreamingServer[StreamAnalysisProgressResponse]
 
+func _HerculesService_CancelAnalysis_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelAnalysisRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HerculesServiceServer).CancelAnalysis(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HerculesService_CancelAnalysis_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HerculesServiceServer).CancelAnalysis(ctx, req.(*CancelAnalysisRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _HerculesService_GetMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HerculesServiceServer).GetMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: HerculesService_GetMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HerculesServiceServer).GetMetrics(ctx, req.(*GetMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // HerculesService_ServiceDesc is the grpc.ServiceDesc for HerculesService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -279,6 +351,14 @@ var HerculesService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetAnalysisStatus",
 			Handler:    _HerculesService_GetAnalysisStatus_Handler,
 		},
+		{
+			MethodName: "CancelAnalysis",
+			Handler:    _HerculesService_CancelAnalysis_Handler,
+		},
+		{
+			MethodName: "GetMetrics",
+			Handler:    _HerculesService_GetMetrics_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
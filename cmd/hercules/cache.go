@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+// cacheCmd groups the commands which manage the analysis results cached by the server.
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cached analysis results.",
+	Long: `Manage the analysis results which the server stores in the configured cache backend.
+
+The results are keyed by the repository, the analysed commit, the analyses and their options.
+Use --config to specify the same configuration file as for "hercules server".`,
+}
+
+var cacheLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the cached analysis results from the newest to the oldest.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cache, err := openResultCache(cmd)
+		if err != nil {
+			return err
+		}
+		entries, err := cache.List(cmd.Context())
+		if err != nil {
+			return err
+		}
+		return printCacheEntries(cmd.OutOrStdout(), entries)
+	},
+}
+
+var cacheRmCmd = &cobra.Command{
+	Use:   "rm <digest>...",
+	Short: "Delete the cached analysis results with the specified digests.",
+	Long: `Delete the cached analysis results with the specified digests.
+
+The digests may be abbreviated as long as they stay unique, see "hercules cache ls".`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, digests []string) error {
+		cache, err := openResultCache(cmd)
+		if err != nil {
+			return err
+		}
+		for _, digest := range digests {
+			full, err := cache.Remove(cmd.Context(), digest)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), full)
+		}
+		return nil
+	},
+}
+
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete the expired and incomplete cached analysis results.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, err := cmd.Flags().GetDuration("older-than")
+		if err != nil {
+			return err
+		}
+		cache, err := openResultCache(cmd)
+		if err != nil {
+			return err
+		}
+		removed, err := cache.Prune(cmd.Context(), olderThan)
+		for _, digest := range removed {
+			fmt.Fprintln(cmd.OutOrStdout(), digest)
+		}
+		return err
+	},
+}
+
+// openResultCache loads the configuration specified with --config and opens the result cache.
+func openResultCache(cmd *cobra.Command) (*core.ResultCache, error) {
+	configPath, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %v", err)
+	}
+	if !cfg.Cache.Enabled {
+		return nil, fmt.Errorf("the cache is disabled in the configuration")
+	}
+	cacheConfig, err := newCacheConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := core.NewCacheBackend(cacheConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize the cache backend: %v", err)
+	}
+	return newResultCache(backend, cfg), nil
+}
+
+// printCacheEntries writes the table of the cached results.
+func printCacheEntries(writer io.Writer, entries []core.ResultCacheEntry) error {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(table, "DIGEST\tREPOSITORY\tHEAD\tANALYSES\tSIZE\tCREATED\tEXPIRES")
+	for _, entry := range entries {
+		head := entry.Key.Head
+		if len(head) > 12 {
+			head = head[:12]
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			entry.Digest[:12], entry.Key.Repository, head, strings.Join(entry.Key.Analyses, ","),
+			entry.Size, entry.CreatedAt.Format(time.RFC3339), entry.ExpiresAt.Format(time.RFC3339))
+	}
+	return table.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	// formatUsage() of rootCmd hides the analysis flags, which the cache commands do not
+	// have, and crashes; pin cobra's default usage before it is inherited
+	cacheCmd.SetUsageFunc(cacheCmd.UsageFunc())
+	cacheCmd.AddCommand(cacheLsCmd, cacheRmCmd, cachePruneCmd)
+	cacheCmd.PersistentFlags().StringP("config", "c", "", "Path to configuration file")
+	cachePruneCmd.Flags().Duration("older-than", 0,
+		"Also delete the results created earlier than this duration ago, e.g. 168h. 0 disables.")
+}
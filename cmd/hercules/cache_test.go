@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func executeCacheCommand(args ...string) (string, error) {
+	output := &bytes.Buffer{}
+	rootCmd.SetOut(output)
+	rootCmd.SetArgs(append([]string{"cache"}, args...))
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetArgs(nil)
+	}()
+	err := rootCmd.ExecuteContext(context.Background())
+	return output.String(), err
+}
+
+func TestCacheCommands(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(fmt.Sprintf(
+		"cache:\n  enabled: true\n  backend: local\n  directory: %s\n", filepath.Join(dir, "cache"))), 0644))
+	backend, err := core.NewLocalCache(core.CacheConfig{LocalPath: filepath.Join(dir, "cache")})
+	require.NoError(t, err)
+	cache := core.NewResultCache(backend, time.Hour)
+	key1 := core.ResultCacheKey{Repository: "github.com/src-d/hercules", Head: strings.Repeat("1", 40),
+		Analyses: []string{"Burndown", "Couples"}}
+	key2 := core.ResultCacheKey{Repository: "github.com/src-d/go-git", Head: strings.Repeat("2", 40)}
+	require.NoError(t, cache.Put(context.Background(), key1, &pb.AnalysisResults{Header: &pb.Metadata{Version: 2}}))
+	require.NoError(t, cache.Put(context.Background(), key2, &pb.AnalysisResults{Header: &pb.Metadata{Version: 2}}))
+
+	output, err := executeCacheCommand("ls", "--config", configPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "DIGEST"))
+	assert.Contains(t, lines[1], key2.Digest()[:12])
+	assert.Contains(t, lines[2], "github.com/src-d/hercules")
+	assert.Contains(t, lines[2], "111111111111 ")
+	assert.Contains(t, lines[2], "Burndown,Couples")
+
+	output, err = executeCacheCommand("rm", "--config", configPath, key1.Digest()[:8])
+	require.NoError(t, err)
+	assert.Equal(t, key1.Digest()+"\n", output)
+	_, err = executeCacheCommand("rm", "--config", configPath, key1.Digest()[:8])
+	assert.Error(t, err)
+
+	output, err = executeCacheCommand("prune", "--config", configPath, "--older-than", "1ns")
+	require.NoError(t, err)
+	assert.Equal(t, key2.Digest()+"\n", output)
+	entries, err := cache.List(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("cache:\n  enabled: false\n"), 0644))
+	_, err = executeCacheCommand("ls", "--config", configPath)
+	assert.EqualError(t, err, "the cache is disabled in the configuration")
+}
+
+func TestNewResultCacheTTL(t *testing.T) {
+	ttl := func(cfg *config.Config) time.Duration {
+		backend, err := core.NewMemoryCache(core.CacheConfig{})
+		require.NoError(t, err)
+		cache := newResultCache(backend, cfg)
+		require.NoError(t, cache.Put(context.Background(), core.ResultCacheKey{Head: "1"},
+			&pb.AnalysisResults{Header: &pb.Metadata{Version: 2}}))
+		entries, err := cache.List(context.Background())
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		return entries[0].ExpiresAt.Sub(entries[0].CreatedAt)
+	}
+	cfg := &config.Config{}
+	assert.Equal(t, defaultResultCacheTTL, ttl(cfg))
+	cfg.Cache.TTL = time.Minute
+	assert.Equal(t, time.Minute, ttl(cfg))
+}
@@ -124,6 +124,9 @@ const (
 	DependencyIsMerge = core.DependencyIsMerge
 	// DependencyAuthor is the name of the dependency provided by identity.Detector.
 	DependencyAuthor = identity.DependencyAuthor
+	// DependencyAuthorExcluded is the name of the dependency provided by identity.Detector.
+	// It is true if the author is a bot excluded from the analyses.
+	DependencyAuthorExcluded = identity.DependencyAuthorExcluded
 	// DependencyBlobCache identifies the dependency provided by BlobCache.
 	DependencyBlobCache = plumbing.DependencyBlobCache
 	// DependencyTick is the name of the dependency which TicksSinceStart provides - the number
@@ -133,6 +136,9 @@ const (
 	DependencyFileDiff = plumbing.DependencyFileDiff
 	// DependencyTreeChanges is the name of the dependency provided by TreeDiff.
 	DependencyTreeChanges = plumbing.DependencyTreeChanges
+	// DependencyFileOrigins is the name of the dependency provided by RenameAnalysis.
+	// It maps the copied, split and joined files to the files they were derived from.
+	DependencyFileOrigins = plumbing.DependencyFileOrigins
 	// DependencyUastChanges is the name of the dependency provided by Changes.
 	DependencyUastChanges = uast.DependencyUastChanges
 	// DependencyUasts is the name of the dependency provided by Extractor.
@@ -156,6 +162,9 @@ const (
 // FileDiffData is the type of the dependency provided by plumbing.FileDiff.
 type FileDiffData = plumbing.FileDiffData
 
+// FileOrigin is the type of the values of the dependency provided by plumbing.RenameAnalysis.
+type FileOrigin = plumbing.FileOrigin
+
 // CachedBlob allows to explicitly cache the binary data associated with the Blob object.
 // Such structs are returned by DependencyBlobCache.
 type CachedBlob = plumbing.CachedBlob
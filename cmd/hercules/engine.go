@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/config"
+	"github.com/dmytrogajewski/hercules/internal/pkg/leaves"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/version"
+	"github.com/dmytrogajewski/hercules/internal/server/auth"
+	"github.com/dmytrogajewski/hercules/internal/server/jobs"
+	"github.com/dmytrogajewski/hercules/internal/server/metrics"
+	"github.com/go-git/go-git/v6"
+	gitconfig "github.com/go-git/go-git/v6/config"
+	gitplumbing "github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+// analysisEngine runs the analysis pipeline on behalf of the HTTP and gRPC servers.
+// Both front ends submit the same repository/analyses/options triple and receive
+// the finalized leaf results.
+type analysisEngine struct {
+	config *config.Config
+	cache  core.CacheBackend
+	// metrics are optional and may be nil.
+	metrics *metrics.Metrics
+}
+
+// analysisRun is the outcome of analysisEngine.Run().
+type analysisRun struct {
+	Repository string
+	// Key identifies the results in core.ResultCache.
+	Key      core.ResultCacheKey
+	Deployed []core.LeafPipelineItem
+	Results  map[core.LeafPipelineItem]interface{}
+}
+
+func newAnalysisEngine(cfg *config.Config, cache core.CacheBackend) *analysisEngine {
+	return &analysisEngine{config: cfg, cache: cache}
+}
+
+// analyse clones the repository, deploys the requested leaves and runs the pipeline
+// over the whole history. `analyses` are the leaf flags, e.g. "burndown" or "couples".
+// `options` are the command line flags of the configuration options, e.g. "granularity".
+// Cloning and the pipeline stop early with ctx.Err() when `ctx` is done.
+func (engine *analysisEngine) analyse(
+	ctx context.Context, uri string, analyses []string, options map[string]string) (
+	*analysisRun, error) {
+	tempDir, err := os.MkdirTemp(engine.config.Cache.Directory, "hercules-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	cloneStart := time.Now()
+	repository, err := engine.openRepository(ctx, uri, tempDir)
+	engine.metrics.ObserveClone(time.Since(cloneStart))
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	head, err := repository.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve HEAD: %v", err)
+	}
+	pipeline := core.NewPipeline(repository)
+	facts, err := engine.facts(options)
+	if err != nil {
+		return nil, err
+	}
+	deployed, err := deployLeaves(pipeline, analyses)
+	if err != nil {
+		return nil, err
+	}
+	key := core.NewResultCacheKey(uri, head.Hash(), pipeline.Items(), facts)
+	if err := pipeline.Initialize(facts); err != nil {
+		return nil, fmt.Errorf("failed to initialize pipeline: %v", err)
+	}
+	commits, err := pipeline.Commits(false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commits: %v", err)
+	}
+	tracker := engine.metrics.StartPipeline()
+	if tracker != nil {
+		pipeline.OnProgress = tracker.Progress
+	}
+	results, err := pipeline.RunContext(ctx, commits)
+	var runTimePerItem map[string]float64
+	if common, ok := results[nil].(*core.CommonAnalysisResult); ok {
+		runTimePerItem = common.RunTimePerItem
+	}
+	leafNames := make([]string, len(deployed))
+	for i, leaf := range deployed {
+		leafNames[i] = leaf.Name()
+	}
+	tracker.Finish(leafNames, runTimePerItem)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run pipeline: %w", err)
+	}
+	return &analysisRun{Repository: uri, Key: key, Deployed: deployed, Results: results}, nil
+}
+
+// deployLeaves deploys new instances of the leaves with the given flags or names and of all
+// their dependencies, so that the pipeline does not share state with the other jobs.
+func deployLeaves(pipeline *core.Pipeline, analyses []string) ([]core.LeafPipelineItem, error) {
+	var deployed []core.LeafPipelineItem
+	for _, analysis := range analyses {
+		leaf := summonLeaf(analysis)
+		if leaf == nil {
+			return nil, fmt.Errorf("unknown analysis: %s", analysis)
+		}
+		deployed = append(deployed, pipeline.DeployItemInstances(leaf).(core.LeafPipelineItem))
+	}
+	return deployed, nil
+}
+
+// resultCacheKey predicts analysisRun.Key without cloning the repository. It fails if
+// the analyses or the options are invalid or HEAD cannot be resolved.
+func (engine *analysisEngine) resultCacheKey(
+	ctx context.Context, uri string, analyses []string, options map[string]string) (
+	core.ResultCacheKey, error) {
+	facts, err := engine.facts(options)
+	if err != nil {
+		return core.ResultCacheKey{}, err
+	}
+	pipeline := core.NewPipeline(nil)
+	if _, err := deployLeaves(pipeline, analyses); err != nil {
+		return core.ResultCacheKey{}, err
+	}
+	head, err := resolveHead(ctx, uri)
+	if err != nil {
+		return core.ResultCacheKey{}, err
+	}
+	return core.NewResultCacheKey(uri, head, pipeline.Items(), facts), nil
+}
+
+// resolveHead returns the commit which HEAD of the repository points to. Remote
+// repositories are queried like `git ls-remote` does.
+func resolveHead(ctx context.Context, uri string) (gitplumbing.Hash, error) {
+	if !auth.IsRemoteRepository(uri) {
+		repository, err := git.PlainOpen(uri)
+		if err != nil {
+			return gitplumbing.ZeroHash, err
+		}
+		head, err := repository.Head()
+		if err != nil {
+			return gitplumbing.ZeroHash, err
+		}
+		return head.Hash(), nil
+	}
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: git.DefaultRemoteName, URLs: []string{uri}})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{})
+	if err != nil {
+		return gitplumbing.ZeroHash, err
+	}
+	byName := map[gitplumbing.ReferenceName]*gitplumbing.Reference{}
+	for _, ref := range refs {
+		byName[ref.Name()] = ref
+	}
+	ref := byName[gitplumbing.HEAD]
+	for i := 0; ref != nil && ref.Type() == gitplumbing.SymbolicReference && i < 10; i++ {
+		ref = byName[ref.Target()]
+	}
+	if ref == nil || ref.Type() != gitplumbing.HashReference {
+		return gitplumbing.ZeroHash, fmt.Errorf("failed to resolve HEAD of %s", uri)
+	}
+	return ref.Hash(), nil
+}
+
+// openRepository wraps loadRepositoryContext() which panics on failure.
+func (engine *analysisEngine) openRepository(ctx context.Context, uri, cachePath string) (
+	repository *git.Repository, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return loadRepositoryContext(ctx, uri, cachePath, true, ""), nil
+}
+
+// facts converts the request options to the pipeline facts. The server's analysis
+// defaults are applied first.
+func (engine *analysisEngine) facts(options map[string]string) (map[string]interface{}, error) {
+	facts := map[string]interface{}{
+		plumbing.ConfigTicksSinceStartTickSize: engine.config.Analysis.DefaultTickSize,
+		leaves.ConfigBurndownGranularity:       engine.config.Analysis.DefaultGranularity,
+		leaves.ConfigBurndownSampling:          engine.config.Analysis.DefaultSampling,
+	}
+	known := configurationOptionsByFlag()
+	for key, value := range options {
+		opt, exists := known[key]
+		if !exists {
+			// not a pipeline option, e.g. "format"
+			continue
+		}
+		parsed, err := parseConfigurationOption(opt, value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value of option %s: %v", key, err)
+		}
+		facts[opt.Name] = parsed
+	}
+	return facts, nil
+}
+
+// summonLeaf returns a new instance of the registered leaf with the given flag or name.
+func summonLeaf(analysis string) core.LeafPipelineItem {
+	for _, leaf := range core.Registry.GetLeaves() {
+		if leaf.Flag() == analysis || leaf.Name() == analysis {
+			return reflect.New(reflect.TypeOf(leaf).Elem()).Interface().(core.LeafPipelineItem)
+		}
+	}
+	return nil
+}
+
+// configurationOptionsByFlag indexes the configuration options of all the registered
+// items by their command line flags.
+func configurationOptionsByFlag() map[string]core.ConfigurationOption {
+	options := map[string]core.ConfigurationOption{}
+	for _, name := range core.Registry.RegisteredNames() {
+		for _, item := range core.Registry.Summon(name) {
+			for _, opt := range item.ListConfigurationOptions() {
+				options[opt.Flag] = opt
+			}
+		}
+	}
+	return options
+}
+
+func parseConfigurationOption(opt core.ConfigurationOption, value string) (interface{}, error) {
+	switch opt.Type {
+	case core.BoolConfigurationOption:
+		return strconv.ParseBool(value)
+	case core.IntConfigurationOption:
+		return strconv.Atoi(value)
+	case core.FloatConfigurationOption:
+		val, err := strconv.ParseFloat(value, 32)
+		return float32(val), err
+	case core.StringsConfigurationOption:
+		return strings.Split(value, ","), nil
+	default:
+		return value, nil
+	}
+}
+
+// Text returns the YAML text serialization of the result of the specified leaf.
+func (run *analysisRun) Text(leaf core.LeafPipelineItem) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if err := leaf.Serialize(run.Results[leaf], false, buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Binary returns the Protocol Buffers serialization of the result of the specified leaf.
+func (run *analysisRun) Binary(leaf core.LeafPipelineItem) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	if err := leaf.Serialize(run.Results[leaf], true, buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Protobuf returns the results in the format of `hercules --pb`.
+func (run *analysisRun) Protobuf() (*pb.AnalysisResults, error) {
+	header := &pb.Metadata{
+		Version:    2,
+		Hash:       version.BinaryGitHash,
+		Repository: run.Repository,
+	}
+	if common, ok := run.Results[nil].(*core.CommonAnalysisResult); ok {
+		common.FillMetadata(header)
+	}
+	message := &pb.AnalysisResults{Header: header, Contents: map[string][]byte{}}
+	for _, leaf := range run.Deployed {
+		binary, err := run.Binary(leaf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize %s: %v", leaf.Name(), err)
+		}
+		message.Contents[leaf.Name()] = binary
+	}
+	return message, nil
+}
+
+// resultCache returns the result cache on top of the engine's cache backend or nil
+// if caching is disabled.
+func (engine *analysisEngine) resultCache() *core.ResultCache {
+	if engine.cache == nil {
+		return nil
+	}
+	return newResultCache(engine.cache, engine.config)
+}
+
+// cacheable returns true if the cached results of all the analyses can be served.
+// Serving requires decoding the Protocol Buffers to produce the text serialization.
+func cacheable(analyses []string) bool {
+	for _, analysis := range analyses {
+		if _, ok := summonLeaf(analysis).(core.ResultMergeablePipelineItem); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// cachedReport converts the cached results to the report. The second value is false if
+// some analyses are missing.
+func cachedReport(analyses []string, results *pb.AnalysisResults) (*jobs.Report, bool, error) {
+	report := &jobs.Report{Results: map[string]*jobs.Result{}}
+	if header := results.Header; header != nil {
+		report.Metadata = &jobs.Metadata{
+			BeginUnixTime:  header.BeginUnixTime,
+			EndUnixTime:    header.EndUnixTime,
+			Commits:        int(header.Commits),
+			RunTime:        header.RunTime,
+			RunTimePerItem: header.RunTimePerItem,
+		}
+	}
+	for _, analysis := range analyses {
+		leaf := summonLeaf(analysis).(core.ResultMergeablePipelineItem)
+		binary, exists := results.Contents[leaf.Name()]
+		if !exists {
+			return nil, false, nil
+		}
+		decoded, err := leaf.Deserialize(binary)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decode the cached %s: %v", leaf.Name(), err)
+		}
+		buffer := &bytes.Buffer{}
+		if err := leaf.Serialize(decoded, false, buffer); err != nil {
+			return nil, false, fmt.Errorf("failed to serialize %s: %v", leaf.Name(), err)
+		}
+		report.Results[analysis] = &jobs.Result{
+			Name:        leaf.Name(),
+			Description: leaf.Description(),
+			ResultType:  fmt.Sprintf("%T", decoded),
+			Binary:      binary,
+			Text:        buffer.Bytes(),
+		}
+	}
+	return report, true, nil
+}
+
+// Run implements jobs.Runner.
+func (engine *analysisEngine) Run(ctx context.Context, request jobs.Request) (
+	*jobs.Report, error) {
+	start := time.Now()
+	report, cached, err := engine.run(ctx, request)
+	switch {
+	case err != nil:
+		engine.metrics.ObserveRun(metrics.ResultFailed, time.Since(start))
+	case cached:
+		engine.metrics.ObserveRun(metrics.ResultCached, time.Since(start))
+	default:
+		engine.metrics.ObserveRun(metrics.ResultCompleted, time.Since(start))
+	}
+	return report, err
+}
+
+// run implements Run(). The second value is true if the report was loaded from the cache.
+func (engine *analysisEngine) run(ctx context.Context, request jobs.Request) (
+	*jobs.Report, bool, error) {
+	logger := core.GetLogger()
+	cache := engine.resultCache()
+	if cache != nil && !cacheable(request.Analyses) {
+		cache = nil
+	}
+	// Check cache first if available
+	if cache != nil {
+		key, err := engine.resultCacheKey(ctx, request.Repository, request.Analyses, request.Options)
+		if err != nil {
+			logger.Warnf("Warning: failed to compute the cache key of %s: %v", request.Repository, err)
+		} else if report, err := engine.cached(ctx, cache, key, request.Analyses); err != nil {
+			logger.Warnf("Warning: failed to load the cached results: %v", err)
+		} else if report != nil {
+			logger.Infof("Analysis completed from cache for %s", request.Repository)
+			return report, true, nil
+		}
+	}
+
+	run, err := engine.analyse(ctx, request.Repository, request.Analyses, request.Options)
+	if err != nil {
+		return nil, false, err
+	}
+	report := &jobs.Report{Results: map[string]*jobs.Result{}}
+	if common, ok := run.Results[nil].(*core.CommonAnalysisResult); ok {
+		report.Metadata = &jobs.Metadata{
+			BeginUnixTime:  common.BeginTime,
+			EndUnixTime:    common.EndTime,
+			Commits:        common.CommitsNumber,
+			RunTime:        common.RunTime.Nanoseconds() / 1e6,
+			RunTimePerItem: common.RunTimePerItem,
+		}
+	}
+	for i, leaf := range run.Deployed {
+		result := &jobs.Result{
+			Name:        leaf.Name(),
+			Description: leaf.Description(),
+			ResultType:  fmt.Sprintf("%T", run.Results[leaf]),
+		}
+		if result.Binary, err = run.Binary(leaf); err != nil {
+			return nil, false, fmt.Errorf("failed to serialize %s: %v", leaf.Name(), err)
+		}
+		if result.Text, err = run.Text(leaf); err != nil {
+			return nil, false, fmt.Errorf("failed to serialize %s: %v", leaf.Name(), err)
+		}
+		report.Results[request.Analyses[i]] = result
+	}
+
+	// Cache results if cache is available
+	if cache != nil {
+		if message, err := run.Protobuf(); err != nil {
+			logger.Warnf("Warning: Failed to cache results: %v", err)
+		} else if err := cache.Put(ctx, run.Key, message); err != nil {
+			logger.Warnf("Warning: Failed to cache results: %v", err)
+		} else {
+			logger.Infof("Results cached for %s as %s", request.Repository, run.Key.Digest())
+		}
+	}
+	return report, false, nil
+}
+
+// cached loads the report from the result cache. It returns nil on cache misses.
+func (engine *analysisEngine) cached(
+	ctx context.Context, cache *core.ResultCache, key core.ResultCacheKey, analyses []string) (
+	*jobs.Report, error) {
+	results, err := cache.Get(ctx, key)
+	if errors.Is(err, core.ErrResultCacheMiss) {
+		engine.metrics.ObserveResultCache(engine.cache, false)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	report, ok, err := cachedReport(analyses, results)
+	engine.metrics.ObserveResultCache(engine.cache, ok)
+	if !ok {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/config"
+	"github.com/dmytrogajewski/hercules/internal/pkg/leaves"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/dmytrogajewski/hercules/internal/server/jobs"
+	"github.com/dmytrogajewski/hercules/internal/server/metrics"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestEngine() *analysisEngine {
+	return newAnalysisEngine(&config.Config{Analysis: config.AnalysisConfig{
+		DefaultTickSize: 24, DefaultGranularity: 30, DefaultSampling: 30}}, nil)
+}
+
+func newTestCachingEngine(t *testing.T) *analysisEngine {
+	engine := newTestEngine()
+	cache, err := core.NewMemoryCache(core.CacheConfig{})
+	require.NoError(t, err)
+	engine.cache = cache
+	return engine
+}
+
+func TestAnalysisEngineFacts(t *testing.T) {
+	engine := newTestEngine()
+	facts, err := engine.facts(map[string]string{
+		"granularity": "15", "burndown-files": "true", "format": "json"})
+	assert.Nil(t, err)
+	assert.Equal(t, 24, facts[plumbing.ConfigTicksSinceStartTickSize])
+	assert.Equal(t, 15, facts[leaves.ConfigBurndownGranularity])
+	assert.Equal(t, 30, facts[leaves.ConfigBurndownSampling])
+	assert.Equal(t, true, facts[leaves.ConfigBurndownTrackFiles])
+	assert.NotContains(t, facts, "format")
+	_, err = engine.facts(map[string]string{"granularity": "many"})
+	assert.NotNil(t, err)
+}
+
+func TestSummonLeaf(t *testing.T) {
+	leaf := summonLeaf("burndown")
+	assert.IsType(t, &leaves.BurndownAnalysis{}, leaf)
+	assert.False(t, leaf == core.Registry.Summon("Burndown")[0])
+	assert.IsType(t, &leaves.CouplesAnalysis{}, summonLeaf("Couples"))
+	assert.Nil(t, summonLeaf("xxx"))
+}
+
+// newTestEngineRepository creates a repository on disk with one commit by each author,
+// by default a single commit by Bob.
+func newTestEngineRepository(t *testing.T, authors ...string) string {
+	if len(authors) == 0 {
+		authors = []string{"Bob"}
+	}
+	path := t.TempDir()
+	repository, err := git.PlainInit(path, false)
+	require.NoError(t, err)
+	worktree, err := repository.Worktree()
+	require.NoError(t, err)
+	for i, author := range authors {
+		name := strings.ToLower(author) + ".go"
+		require.NoError(t, os.WriteFile(filepath.Join(path, name), []byte("package main\n"), 0644))
+		_, err = worktree.Add(name)
+		require.NoError(t, err)
+		_, err = worktree.Commit(name, &git.CommitOptions{Author: &object.Signature{
+			Name: author, Email: strings.ToLower(author) + "@example.com",
+			When: time.Unix(1500000000+int64(i)*3600, 0)}})
+		require.NoError(t, err)
+	}
+	return path
+}
+
+func TestAnalysisEngineSeparatePipelines(t *testing.T) {
+	engine := newTestEngine()
+	first, err := engine.analyse(
+		context.Background(), newTestEngineRepository(t, "Alice"), []string{"devs"}, nil)
+	require.NoError(t, err)
+	text, err := first.Text(first.Deployed[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(text), "alice@example.com")
+
+	second, err := engine.analyse(
+		context.Background(), newTestEngineRepository(t, "Zed", "Yan"), []string{"devs"}, nil)
+	require.NoError(t, err)
+	text, err = second.Text(second.Deployed[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(text), "alice")
+	assert.Contains(t, string(text), "zed@example.com")
+	assert.Contains(t, string(text), "yan@example.com")
+	ticks := second.Results[second.Deployed[0]].(leaves.DevsResult).Ticks
+	for _, devs := range ticks {
+		assert.NotContains(t, devs, identity.AuthorMissing)
+	}
+
+	// the dependencies are not the registered prototypes
+	pipeline := core.NewPipeline(nil)
+	_, err = deployLeaves(pipeline, []string{"devs"})
+	require.NoError(t, err)
+	assert.Greater(t, len(pipeline.Items()), 1)
+	for _, item := range pipeline.Items() {
+		for _, prototype := range core.Registry.Summon(item.Name()) {
+			assert.False(t, item == prototype, item.Name())
+		}
+	}
+}
+
+func TestAnalysisEngineRunCached(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestCachingEngine(t)
+	engine.metrics = metrics.New()
+	request := jobs.Request{Repository: newTestEngineRepository(t), Analyses: []string{"burndown"}}
+	key, err := engine.resultCacheKey(ctx, request.Repository, request.Analyses, request.Options)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Burndown"}, key.Analyses)
+	assert.Equal(t, "30", key.Options[leaves.ConfigBurndownGranularity])
+	binary, err := proto.Marshal(&pb.BurndownAnalysisResults{
+		Granularity: 30, Sampling: 30, TickSize: int64(24 * time.Hour),
+		Project: &pb.BurndownSparseMatrix{Name: "project", NumberOfRows: 1, NumberOfColumns: 1,
+			Rows: []*pb.BurndownSparseMatrixRow{{Columns: []uint32{7}}}},
+	})
+	require.NoError(t, err)
+	require.NoError(t, engine.resultCache().Put(ctx, key, &pb.AnalysisResults{
+		Header:   &pb.Metadata{Commits: 3},
+		Contents: map[string][]byte{"Burndown": binary},
+	}))
+	report, err := engine.Run(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.Metadata.Commits)
+	assert.Equal(t, binary, report.Results["burndown"].Binary)
+	assert.Contains(t, string(report.Results["burndown"].Text), "granularity: 30")
+
+	// other options produce a different key
+	request.Options = map[string]string{"granularity": "15", "sampling": "15"}
+	report, err = engine.Run(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Metadata.Commits)
+	entries, err := engine.resultCache().List(ctx)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "15", entries[0].Key.Options[leaves.ConfigBurndownGranularity])
+	cached, err := engine.Run(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, report.Metadata.Commits, cached.Metadata.Commits)
+	assert.Equal(t, report.Results["burndown"].Binary, cached.Results["burndown"].Binary)
+	assert.Equal(t, report.Results["burndown"].Text, cached.Results["burndown"].Text)
+
+	text, err := engine.metrics.Text()
+	require.NoError(t, err)
+	assert.Contains(t, text, `hercules_result_cache_requests_total{backend="MemoryCache",result="hit"} 2`)
+	assert.Contains(t, text, `hercules_result_cache_requests_total{backend="MemoryCache",result="miss"} 1`)
+	assert.Contains(t, text, `hercules_analysis_run_duration_seconds_count{result="cached"} 2`)
+	assert.Contains(t, text, `hercules_analysis_run_duration_seconds_count{result="completed"} 1`)
+	assert.Contains(t, text, `hercules_analysis_duration_seconds_count{analysis="Burndown"} 1`)
+	assert.Contains(t, text, "hercules_clone_duration_seconds_count 1")
+	assert.Contains(t, text, "hercules_commits_processed_total 1")
+}
+
+func TestAnalysisEngineRunNotCacheable(t *testing.T) {
+	engine := newTestCachingEngine(t)
+	assert.True(t, cacheable([]string{"burndown", "couples"}))
+	assert.False(t, cacheable([]string{"burndown", "file-history"}))
+	_, err := engine.Run(context.Background(), jobs.Request{
+		Repository: newTestEngineRepository(t), Analyses: []string{"file-history"}})
+	require.NoError(t, err)
+	entries, err := engine.resultCache().List(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -16,8 +17,6 @@ import (
 	"text/template"
 	"unicode"
 
-	"regexp"
-
 	"github.com/Masterminds/sprig"
 	progress "github.com/cheggaaa/pb/v3"
 	"github.com/dmytrogajewski/hercules/api/proto/pb"
@@ -25,6 +24,7 @@ import (
 	"github.com/dmytrogajewski/hercules/internal/pkg/config"
 	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/uast"
 	"github.com/dmytrogajewski/hercules/internal/pkg/version" // Using standard protobuf
+	"github.com/dmytrogajewski/hercules/internal/server/auth"
 	"github.com/go-git/go-billy/v6/osfs"
 	"github.com/go-git/go-git/v6"
 	"github.com/go-git/go-git/v6/plumbing/cache"
@@ -72,11 +72,18 @@ func loadSSHIdentity(sshIdentity string) (*ssh.PublicKeys, error) {
 }
 
 func loadRepository(uri string, cachePath string, disableStatus bool, sshIdentity string) *git.Repository {
+	return loadRepositoryContext(context.Background(), uri, cachePath, disableStatus, sshIdentity)
+}
+
+// loadRepositoryContext is loadRepository() which aborts cloning when `ctx` is done.
+func loadRepositoryContext(
+	ctx context.Context, uri string, cachePath string, disableStatus bool,
+	sshIdentity string) *git.Repository {
 	var repository *git.Repository
 	var backend storage.Storer
 	var err error
 
-	if strings.Contains(uri, "://") || regexp.MustCompile("^[A-Za-z]\\w*@[A-Za-z0-9][\\w.]*:").MatchString(uri) {
+	if auth.IsRemoteRepository(uri) {
 		if cachePath != "" {
 			backend = filesystem.NewStorage(osfs.New(cachePath), cache.NewObjectLRUDefault())
 			_, err = os.Stat(cachePath)
@@ -101,7 +108,7 @@ func loadRepository(uri string, cachePath string, disableStatus bool, sshIdentit
 			cloneOptions.Auth = auth
 		}
 
-		repository, err = git.Clone(backend, nil, cloneOptions)
+		repository, err = git.CloneContext(ctx, backend, nil, cloneOptions)
 		if !disableStatus {
 			fmt.Fprint(os.Stderr, "\033[2K\r")
 		}
@@ -220,6 +227,7 @@ targets can be added using the --plugin system.`,
 		sshIdentity := getString("ssh-identity")
 		allAnalyses := getBool("all")
 		uastProvider := getString("uast-provider")
+		resumeFrom := getString("resume-from")
 
 		if profile {
 			go func() {
@@ -279,6 +287,21 @@ targets can be added using the --plugin system.`,
 		if err != nil {
 			log.Fatalf("failed to list the commits: %v", err)
 		}
+		if resumeFrom != "" {
+			checkpoint, err := core.ReadCheckpoint(resumeFrom)
+			if err != nil {
+				log.Fatalf("failed to load the checkpoint: %v", err)
+			}
+			commits, err = checkpoint.FilterCommits(commits)
+			if err != nil {
+				log.Fatalf("failed to resume from %s: %v", resumeFrom, err)
+			}
+			cmdlineFacts[core.ConfigPipelineResume] = checkpoint
+			if path, _ := cmdlineFacts[core.ConfigPipelineCheckpointPath].(string); path == "" {
+				// update the checkpoint in place
+				cmdlineFacts[core.ConfigPipelineCheckpointPath] = resumeFrom
+			}
+		}
 		cmdlineFacts[core.ConfigPipelineCommits] = commits
 		if uastProvider != "" {
 			cmdlineFacts[uast.ConfigUASTProvider] = uastProvider
@@ -343,10 +366,15 @@ targets can be added using the --plugin system.`,
 		if err != nil {
 			log.Fatal(err)
 		}
-		// After initialization, collect deployed leaves
+		// After initialization, collect deployed leaves; those which were only deployed
+		// as dependencies of the others, e.g. Burndown for Rework, are not printed
 		deployed = nil
 		for _, item := range pipeline.Items() {
-			if leaf, ok := item.(core.LeafPipelineItem); ok {
+			leaf, ok := item.(core.LeafPipelineItem)
+			if !ok {
+				continue
+			}
+			if enabled := cmdlineDeployed[leaf.Name()]; enabled != nil && *enabled {
 				deployed = append(deployed, leaf)
 			}
 		}
@@ -677,6 +705,14 @@ func init() {
 	}
 	core.PathifyFlagValue(rootFlags.Lookup("commits"))
 	rootFlags.Bool("head", false, "Analyze only the latest commit.")
+	rootFlags.String("resume-from", "", "Path to the checkpoint written by --checkpoint. "+
+		"Only the commits after the checkpointed one are analysed and the checkpoint is updated "+
+		"unless --checkpoint points elsewhere.")
+	err = rootCmd.MarkFlagFilename("resume-from")
+	if err != nil {
+		panic(err)
+	}
+	core.PathifyFlagValue(rootFlags.Lookup("resume-from"))
 	rootFlags.Bool("first-parent", false, "Follow only the first parent in the commit history - "+
 		"\"git log --first-parent\".")
 	rootFlags.Bool("pb", false, "The output format will be Protocol Buffers instead of YAML.")
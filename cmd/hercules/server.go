@@ -1,23 +1,22 @@
 package main
 
 import (
-	"context"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/dmytrogajewski/hercules/api/proto/pb"
 	"github.com/dmytrogajewski/hercules/internal/app/core"
 	"github.com/dmytrogajewski/hercules/internal/pkg/config"
-	"github.com/dmytrogajewski/hercules/internal/pkg/leaves"
-	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
 	"github.com/dmytrogajewski/hercules/internal/pkg/version"
+	"github.com/dmytrogajewski/hercules/internal/server/auth"
 	grpcserver "github.com/dmytrogajewski/hercules/internal/server/grpc"
+	"github.com/dmytrogajewski/hercules/internal/server/jobs"
+	"github.com/dmytrogajewski/hercules/internal/server/metrics"
 	"github.com/gorilla/mux"
 	"github.com/spf13/cobra"
 )
@@ -33,75 +32,138 @@ type AnalysisRequest struct {
 type AnalysisResponse struct {
 	Status    string                 `json:"status"`
 	Message   string                 `json:"message,omitempty"`
+	JobID     string                 `json:"job_id,omitempty"`
 	Results   map[string]interface{} `json:"results,omitempty"`
 	Metadata  *pb.Metadata           `json:"metadata,omitempty"`
 	Timestamp time.Time              `json:"timestamp"`
 }
 
-// AnalysisJob represents a running analysis job
-type AnalysisJob struct {
-	ID        string
-	Status    string
-	StartTime time.Time
-	EndTime   *time.Time
-	Error     string
-	Request   AnalysisRequest
-}
-
 // Server represents the Hercules HTTP server
 type Server struct {
-	config    *config.Config
-	router    *mux.Router
-	jobs      map[string]*AnalysisJob
-	jobsMutex sync.RWMutex
-	cache     core.CacheBackend
+	config       *config.Config
+	router       *mux.Router
+	jobs         *jobs.Manager
+	metrics      *metrics.Metrics
+	auth         *auth.Authenticator
+	repositories *auth.RepositoryPolicy
 }
 
-// NewServer creates a new Hercules server
-func NewServer(cfg *config.Config) *Server {
+// NewServer creates a new Hercules server which schedules the analyses on the
+// specified job manager. GET /metrics reports `m` if it is not nil. The API requires
+// the keys of `authenticator` unless it is nil.
+func NewServer(cfg *config.Config, manager *jobs.Manager, m *metrics.Metrics,
+	authenticator *auth.Authenticator) *Server {
 	s := &Server{
-		config: cfg,
-		router: mux.NewRouter(),
-		jobs:   make(map[string]*AnalysisJob),
-	}
-
-	// Initialize cache backend
-	if cfg.Cache.Enabled {
-		cacheConfig := core.CacheConfig{
-			Backend:    cfg.Cache.Backend,
-			LocalPath:  cfg.Cache.Directory,
-			S3Bucket:   cfg.Cache.S3Bucket,
-			S3Region:   cfg.Cache.S3Region,
-			S3Endpoint: cfg.Cache.S3Endpoint,
-			S3Prefix:   cfg.Cache.S3Prefix,
-			DefaultTTL: cfg.Cache.TTL,
-		}
-
-		cache, err := core.NewCacheBackend(cacheConfig)
-		if err != nil {
-			logger := core.GetLogger()
-			logger.Warnf("Warning: Failed to initialize cache backend: %v", err)
-		} else {
-			s.cache = cache
-			logger := core.GetLogger()
-			logger.Infof("Cache backend initialized: %s", cfg.Cache.Backend)
-		}
+		config:       cfg,
+		router:       mux.NewRouter(),
+		jobs:         manager,
+		metrics:      m,
+		auth:         authenticator,
+		repositories: auth.NewRepositoryPolicy(cfg.Repository),
 	}
-
 	s.setupRoutes()
 	return s
 }
 
+// newCacheConfig converts the cache section of the configuration to core.CacheConfig.
+func newCacheConfig(cfg *config.Config) (core.CacheConfig, error) {
+	maxSize, err := cfg.Cache.MaxSizeBytes()
+	if err != nil {
+		return core.CacheConfig{}, err
+	}
+	return core.CacheConfig{
+		Backend:         cfg.Cache.Backend,
+		LocalPath:       cfg.Cache.Directory,
+		S3Bucket:        cfg.Cache.S3Bucket,
+		S3Region:        cfg.Cache.S3Region,
+		S3Endpoint:      cfg.Cache.S3Endpoint,
+		S3Prefix:        cfg.Cache.S3Prefix,
+		DefaultTTL:      cfg.Cache.TTL,
+		MaxSize:         maxSize,
+		CleanupInterval: cfg.Cache.CleanupInterval,
+	}, nil
+}
+
+// defaultResultCacheTTL is how long the results are cached if the configuration does not say.
+const defaultResultCacheTTL = 24 * time.Hour
+
+// newResultCache creates the result cache on top of the backend with the configured TTL.
+func newResultCache(backend core.CacheBackend, cfg *config.Config) *core.ResultCache {
+	ttl := cfg.Cache.TTL
+	if ttl == 0 {
+		ttl = defaultResultCacheTTL
+	}
+	return core.NewResultCache(backend, ttl)
+}
+
+// newCacheBackend initializes the cache backend from the configuration.
+// It returns nil if the cache is disabled or failed to initialize.
+func newCacheBackend(cfg *config.Config) core.CacheBackend {
+	if !cfg.Cache.Enabled {
+		return nil
+	}
+	logger := core.GetLogger()
+	var cache core.CacheBackend
+	cacheConfig, err := newCacheConfig(cfg)
+	if err == nil {
+		cache, err = core.NewCacheBackend(cacheConfig)
+	}
+	if err != nil {
+		logger.Warnf("Warning: Failed to initialize cache backend: %v", err)
+		return nil
+	}
+	logger.Infof("Cache backend initialized: %s", cfg.Cache.Backend)
+	return cache
+}
+
+// newJobManager creates the job store and the job manager shared by the HTTP and gRPC servers.
+// The engine, the job counts and the cache backend report to `m` unless it is nil.
+func newJobManager(cfg *config.Config, m *metrics.Metrics) (*jobs.Manager, jobs.Store, error) {
+	store, err := jobs.NewStore(cfg.Jobs.Store, cfg.Jobs.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	cache := newCacheBackend(cfg)
+	engine := newAnalysisEngine(cfg, cache)
+	engine.metrics = m
+	manager := jobs.NewManager(store, engine, jobs.Options{
+		// the jobs may run at the same time because deployLeaves() does not share the items
+		Workers:          cfg.Analysis.MaxConcurrentAnalyses,
+		MaxQueued:        cfg.Jobs.MaxQueued,
+		Retention:        cfg.Jobs.Retention,
+		EvictionInterval: cfg.Jobs.EvictionInterval,
+		Timeout:          cfg.Analysis.Timeout,
+	})
+	if err := m.RegisterJobs(manager); err != nil {
+		store.Close()
+		return nil, nil, err
+	}
+	if cache != nil {
+		if err := m.RegisterCache(cache); err != nil {
+			store.Close()
+			return nil, nil, err
+		}
+	}
+	return manager, store, nil
+}
+
 // setupRoutes configures the HTTP routes
 func (s *Server) setupRoutes() {
 	// Health check
 	s.router.HandleFunc("/health", s.healthHandler).Methods("GET")
 
+	// Prometheus metrics
+	if s.metrics != nil {
+		s.router.HandleFunc("/metrics", s.auth.HTTP(auth.ScopeMetrics, s.metrics.Handler().ServeHTTP)).Methods("GET")
+	}
+
 	// API routes
 	api := s.router.PathPrefix("/api/v1").Subrouter()
-	api.HandleFunc("/analyze", s.analyzeHandler).Methods("POST")
-	api.HandleFunc("/analyses", s.listAnalysesHandler).Methods("GET")
-	api.HandleFunc("/status/{id}", s.statusHandler).Methods("GET")
+	api.HandleFunc("/analyze", s.auth.HTTP(auth.ScopeSubmit, s.analyzeHandler)).Methods("POST")
+	api.HandleFunc("/analyses", s.auth.HTTP(auth.ScopeRead, s.listAnalysesHandler)).Methods("GET")
+	api.HandleFunc("/status/{id}", s.auth.HTTP(auth.ScopeRead, s.statusHandler)).Methods("GET")
+	api.HandleFunc("/jobs/{id}", s.auth.HTTP(auth.ScopeCancel, s.cancelHandler)).Methods("DELETE")
+	api.HandleFunc("/results/{id}", s.auth.HTTP(auth.ScopeRead, s.resultsHandler)).Methods("GET")
 
 	// Static file serving for documentation
 	s.router.PathPrefix("/docs/").Handler(http.StripPrefix("/docs/", http.FileServer(http.Dir("./docs"))))
@@ -139,6 +201,11 @@ func (s *Server) listAnalysesHandler(w http.ResponseWriter, r *http.Request) {
 		{"name": "file-history", "description": "File history analysis"},
 		{"name": "imports-per-dev", "description": "Import usage per developer"},
 		{"name": "shotness", "description": "Structural hotness analysis"},
+		{"name": "hotspots", "description": "Files and functions ranked by churn × complexity"},
+		{"name": "ownership", "description": "Line ownership, bus factor and orphaned code per file and directory"},
+		{"name": "rework", "description": "New code rewritten or deleted soon after it was written"},
+		{"name": "commit-classes", "description": "Commits classified as features, fixes, refactorings, reverts, etc."},
+		{"name": "szz", "description": "Bug-introducing commits traced from fixes (SZZ) and defect density"},
 	}
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -167,37 +234,33 @@ func (s *Server) analyzeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check concurrent analysis limit
-	s.jobsMutex.RLock()
-	if len(s.jobs) >= s.config.Analysis.MaxConcurrentAnalyses {
-		s.jobsMutex.RUnlock()
-		http.Error(w, "Too many concurrent analyses", http.StatusTooManyRequests)
+	if err := s.repositories.Check(req.Repository); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
 		return
 	}
-	s.jobsMutex.RUnlock()
-
-	// Create job ID
-	jobID := fmt.Sprintf("job_%d", time.Now().UnixNano())
 
-	// Create job
-	job := &AnalysisJob{
-		ID:        jobID,
-		Status:    "running",
-		StartTime: time.Now(),
-		Request:   req,
+	principal := auth.FromContext(r.Context())
+	job, err := s.jobs.SubmitLimited(jobs.Request{
+		Repository: req.Repository,
+		Analyses:   req.Analyses,
+		Options:    req.Options,
+		Tenant:     principal.Tenant(),
+	}, principal.Concurrency(), principal.AllowSubmit)
+	if err == jobs.ErrQueueFull {
+		http.Error(w, "Too many concurrent analyses", http.StatusTooManyRequests)
+		return
+	} else if err == jobs.ErrQuotaExceeded || err == auth.ErrRateLimited {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	// Add job to tracking
-	s.jobsMutex.Lock()
-	s.jobs[jobID] = job
-	s.jobsMutex.Unlock()
-
-	// Start analysis in a goroutine
-	go s.runAnalysis(job)
-
 	response := AnalysisResponse{
-		Status:    "accepted",
-		Message:   "Analysis started",
+		Status:    string(job.State),
+		Message:   "Analysis queued",
+		JobID:     job.ID,
 		Timestamp: time.Now(),
 	}
 
@@ -216,189 +279,165 @@ func (s *Server) statusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.jobsMutex.RLock()
-	job, exists := s.jobs[jobID]
-	s.jobsMutex.RUnlock()
-
-	if !exists {
+	job, err := s.getJob(r, jobID)
+	if err == jobs.ErrNotFound {
 		http.Error(w, "Job not found", http.StatusNotFound)
 		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	response := AnalysisResponse{
-		Status:    job.Status,
+		Status:    string(job.State),
+		JobID:     job.ID,
 		Timestamp: time.Now(),
 	}
 
-	if job.Error != "" {
+	switch {
+	case job.Error != "":
 		response.Message = job.Error
-	} else if job.Status == "completed" {
+	case job.State == jobs.StateCompleted:
 		response.Message = "Analysis completed"
-	} else {
+	case job.State == jobs.StateQueued:
+		response.Message = "Analysis queued"
+	case job.State == jobs.StateCancelled:
+		response.Message = "Analysis cancelled"
+	default:
 		response.Message = "Analysis in progress"
 	}
 
 	json.NewEncoder(w).Encode(response)
 }
 
-// runAnalysis performs the actual analysis
-func (s *Server) runAnalysis(job *AnalysisJob) {
-	defer func() {
-		now := time.Now()
-		job.EndTime = &now
-		if job.Status == "running" {
-			job.Status = "completed"
-		}
-	}()
-
-	// Check cache first if available
-	if s.cache != nil {
-		cacheKey := core.GenerateCacheKey(job.Request.Repository, "main", "", strings.Join(job.Request.Analyses, ","))
-		if cached, err := s.cache.Get(context.Background(), cacheKey); err == nil {
-			// Parse cached results
-			var cachedResults map[string]interface{}
-			if err := json.Unmarshal(cached, &cachedResults); err == nil {
-				job.Status = "completed"
-				logger := core.GetLogger()
-				logger.Infof("Analysis completed from cache for %s", job.Request.Repository)
-				return
-			}
-		}
-	}
+// cancelHandler cancels a queued or running analysis
+func (s *Server) cancelHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 
-	// Create a temporary directory for this analysis
-	tempDir, err := os.MkdirTemp(s.config.Cache.Directory, "hercules-*")
-	if err != nil {
-		job.Status = "failed"
-		job.Error = fmt.Sprintf("Failed to create temp directory: %v", err)
-		logger := core.GetLogger()
-		logger.Errorf("Failed to create temp directory: %v", err)
+	jobID := mux.Vars(r)["id"]
+	job, err := s.getJob(r, jobID)
+	if err == nil {
+		job, err = s.jobs.Cancel(jobID)
+	}
+	switch err {
+	case nil:
+	case jobs.ErrNotFound:
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	case jobs.ErrFinished:
+		http.Error(w, fmt.Sprintf("Job already %s", job.State), http.StatusConflict)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer os.RemoveAll(tempDir)
 
-	// Load repository
-	repository := loadRepository(job.Request.Repository, tempDir, true, "")
-
-	// Create pipeline
-	pipeline := core.NewPipeline(repository)
+	json.NewEncoder(w).Encode(AnalysisResponse{
+		Status:    string(job.State),
+		Message:   "Analysis cancelled",
+		JobID:     job.ID,
+		Timestamp: time.Now(),
+	})
+}
 
-	// Configure pipeline based on request
-	facts := make(map[string]interface{})
+// resultContentTypes map the result formats to their MIME types.
+var resultContentTypes = map[string]string{
+	jobs.FormatYAML:     "application/x-yaml",
+	jobs.FormatJSON:     "application/json",
+	jobs.FormatProtobuf: "application/x-protobuf",
+}
 
-	// Set defaults from config
-	facts[plumbing.ConfigTicksSinceStartTickSize] = s.config.Analysis.DefaultTickSize
-	facts["Burndown.Granularity"] = s.config.Analysis.DefaultGranularity
-	facts["Burndown.Sampling"] = s.config.Analysis.DefaultSampling
+// resultsHandler returns the results of a completed analysis. The format is chosen
+// by the "format" query parameter or else by the Accept header; YAML is the default.
+// The "analysis" query parameter selects the leaves, e.g. ?analysis=burndown,couples.
+func (s *Server) resultsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Vary", "Accept")
 
-	// Parse options from request
-	for key, value := range job.Request.Options {
-		switch key {
-		case "tick-size":
-			if size, err := strconv.Atoi(value); err == nil {
-				facts[plumbing.ConfigTicksSinceStartTickSize] = size
-			}
-		case "granularity":
-			if gran, err := strconv.Atoi(value); err == nil {
-				facts["Burndown.Granularity"] = gran
-			}
-		case "sampling":
-			if samp, err := strconv.Atoi(value); err == nil {
-				facts["Burndown.Sampling"] = samp
-			}
-		}
+	format := negotiateResultFormat(r)
+	if format == "" {
+		http.Error(w, "Unsupported result format", http.StatusNotAcceptable)
+		return
 	}
 
-	// Deploy requested analyses
-	var deployed []core.LeafPipelineItem
-
-	for _, analysis := range job.Request.Analyses {
-		switch analysis {
-		case "burndown":
-			item := pipeline.DeployItem(&leaves.BurndownAnalysis{}).(core.LeafPipelineItem)
-			deployed = append(deployed, item)
-		case "couples":
-			item := pipeline.DeployItem(&leaves.CouplesAnalysis{}).(core.LeafPipelineItem)
-			deployed = append(deployed, item)
-		case "devs":
-			item := pipeline.DeployItem(&leaves.DevsAnalysis{}).(core.LeafPipelineItem)
-			deployed = append(deployed, item)
-		case "commits-stat":
-			item := pipeline.DeployItem(&leaves.CommitsAnalysis{}).(core.LeafPipelineItem)
-			deployed = append(deployed, item)
-		case "file-history":
-			item := pipeline.DeployItem(&leaves.FileHistoryAnalysis{}).(core.LeafPipelineItem)
-			deployed = append(deployed, item)
-		case "imports-per-dev":
-			item := pipeline.DeployItem(&leaves.ImportsPerDeveloper{}).(core.LeafPipelineItem)
-			deployed = append(deployed, item)
-		case "shotness":
-			// Shotness analysis is disabled for now
-			continue
-		}
+	job, err := s.getJob(r, mux.Vars(r)["id"])
+	if err == jobs.ErrNotFound {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-
-	// Initialize and run pipeline
-	if err := pipeline.Initialize(facts); err != nil {
-		job.Status = "failed"
-		job.Error = fmt.Sprintf("Failed to initialize pipeline: %v", err)
-		logger := core.GetLogger()
-		logger.Errorf("Failed to initialize pipeline: %v", err)
+	if job.State != jobs.StateCompleted {
+		http.Error(w, fmt.Sprintf("Analysis is %s", job.State), http.StatusConflict)
 		return
 	}
 
-	commits, err := pipeline.Commits(false)
+	analyses, err := job.Select(splitAnalyses(r.URL.Query()["analysis"]))
 	if err != nil {
-		job.Status = "failed"
-		job.Error = fmt.Sprintf("Failed to get commits: %v", err)
-		logger := core.GetLogger()
-		logger.Errorf("Failed to get commits: %v", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	results, err := pipeline.Run(commits)
-	if err != nil {
-		job.Status = "failed"
-		job.Error = fmt.Sprintf("Failed to run pipeline: %v", err)
-		logger := core.GetLogger()
-		logger.Errorf("Failed to run pipeline: %v", err)
+	buffer := &bytes.Buffer{}
+	if err := jobs.Render(buffer, job, analyses, format); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	w.Header().Set("Content-Type", resultContentTypes[format])
+	w.Write(buffer.Bytes())
+}
 
-	// Convert results to JSON-serializable format
-	jsonResults := make(map[string]interface{})
-	for _, item := range deployed {
-		result := results[item]
-		if result != nil {
-			jsonResults[item.Flag()] = map[string]interface{}{
-				"name":        item.Name(),
-				"description": item.Description(),
-				"result_type": fmt.Sprintf("%T", result),
-			}
+// getJob returns the job if the caller's API key owns it. The jobs of the other keys
+// are reported as jobs.ErrNotFound.
+func (s *Server) getJob(r *http.Request, id string) (*jobs.Job, error) {
+	job, err := s.jobs.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !auth.FromContext(r.Context()).Owns(job.Request.Tenant) {
+		return nil, jobs.ErrNotFound
+	}
+	return job, nil
+}
+
+// negotiateResultFormat returns the requested result format or an empty string
+// if none of the requested formats is supported.
+func negotiateResultFormat(r *http.Request) string {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if _, exists := resultContentTypes[format]; exists {
+			return format
+		}
+		return ""
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return jobs.FormatYAML
+	}
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0])
+		switch mediaType {
+		case "application/x-yaml", "application/yaml", "text/yaml", "text/x-yaml", "*/*":
+			return jobs.FormatYAML
+		case "application/json":
+			return jobs.FormatJSON
+		case "application/x-protobuf", "application/protobuf", "application/octet-stream":
+			return jobs.FormatProtobuf
 		}
 	}
+	return ""
+}
 
-	// Cache results if cache is available
-	if s.cache != nil {
-		cacheKey := core.GenerateCacheKey(job.Request.Repository, "main", "", strings.Join(job.Request.Analyses, ","))
-		if cachedData, err := json.Marshal(jsonResults); err == nil {
-			ttl := s.config.Cache.TTL
-			if ttl == 0 {
-				ttl = 24 * time.Hour // Default 24 hours
-			}
-			if err := s.cache.Set(context.Background(), cacheKey, cachedData, ttl); err != nil {
-				logger := core.GetLogger()
-				logger.Warnf("Warning: Failed to cache results: %v", err)
-			} else {
-				logger := core.GetLogger()
-				logger.Infof("Results cached for %s", job.Request.Repository)
+// splitAnalyses splits the comma separated analysis names, e.g. "burndown,couples".
+func splitAnalyses(values []string) []string {
+	var analyses []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				analyses = append(analyses, name)
 			}
 		}
 	}
-
-	job.Status = "completed"
-	logger := core.GetLogger()
-	logger.Infof("Analysis completed for %s", job.Request.Repository)
+	return analyses
 }
 
 // Start starts the HTTP server
@@ -425,8 +464,8 @@ var serverCmd = &cobra.Command{
 	Long: `Start Hercules as an HTTP and/or gRPC server to provide analysis capabilities via REST API and gRPC.
 
 The server provides endpoints for:
-- HTTP: POST /api/v1/analyze, GET /api/v1/analyses, GET /health, GET /docs/
-- gRPC: HerculesService with Health, ListAnalyses, SubmitAnalysis, GetAnalysisStatus, StreamAnalysisProgress
+- HTTP: POST /api/v1/analyze, GET /api/v1/analyses, GET /health, GET /metrics, GET /docs/
+- gRPC: HerculesService with Health, ListAnalyses, SubmitAnalysis, GetAnalysisStatus, StreamAnalysisProgress, GetMetrics
 
 Use --config to specify a configuration file`,
 	Run: func(cmd *cobra.Command, args []string) {
@@ -449,36 +488,76 @@ Use --config to specify a configuration file`,
 			}
 		}
 
+		authenticator, err := auth.NewAuthenticator(cfg.Auth)
+		if err != nil {
+			logger := core.GetLogger()
+			logger.Errorf("Invalid authentication configuration: %v", err)
+			os.Exit(1)
+		}
+
+		m := metrics.New()
+		manager, store, err := newJobManager(cfg, m)
+		if err != nil {
+			logger := core.GetLogger()
+			logger.Errorf("Failed to create the job store: %v", err)
+			os.Exit(1)
+		}
+		defer store.Close()
+		if err := manager.Start(); err != nil {
+			logger := core.GetLogger()
+			logger.Errorf("Failed to start the job manager: %v", err)
+			os.Exit(1)
+		}
+		defer manager.Stop()
+
+		// Both servers block, so each runs in its own goroutine and the first failure stops the process
+		errs := make(chan error, 2)
 		if cfg.Server.Enabled {
 			logger := core.GetLogger()
 			logger.Infof("Starting Hercules server...")
-			startHTTPServer(cfg)
+			go func() {
+				errs <- startHTTPServer(cfg, manager, m, authenticator)
+			}()
 		}
 
 		if cfg.GRPC.Enabled {
 			logger := core.GetLogger()
 			logger.Infof("Starting Hercules gRPC server...")
-			startGRPCServer(cfg)
+			go func() {
+				errs <- startGRPCServer(cfg, manager, m, authenticator)
+			}()
+		}
+
+		if !cfg.Server.Enabled && !cfg.GRPC.Enabled {
+			logger := core.GetLogger()
+			logger.Errorf("Neither the HTTP nor the gRPC server is enabled")
+			return
+		}
+		if err := <-errs; err != nil {
+			logger := core.GetLogger()
+			logger.Errorf("Server failed: %v", err)
 		}
 	},
 }
 
-func startGRPCServer(cfg *config.Config) {
+func startGRPCServer(cfg *config.Config, manager *jobs.Manager, m *metrics.Metrics,
+	authenticator *auth.Authenticator) error {
 	logger := core.GetLogger()
 	grpcAddr := fmt.Sprintf("%s:%d", cfg.GRPC.Host, cfg.GRPC.Port)
-	grpcServer := grpcserver.NewServer(cfg, grpcAddr, logger)
+	grpcServer := grpcserver.NewServer(cfg, grpcAddr, logger, manager, m, authenticator)
 	if err := grpcServer.Start(); err != nil {
-		logger.Errorf("gRPC server failed: %v", err)
+		return fmt.Errorf("gRPC server failed: %v", err)
 	}
+	return nil
 }
 
-func startHTTPServer(cfg *config.Config) {
-	server := NewServer(cfg)
+func startHTTPServer(cfg *config.Config, manager *jobs.Manager, m *metrics.Metrics,
+	authenticator *auth.Authenticator) error {
+	server := NewServer(cfg, manager, m, authenticator)
 	if err := server.Start(); err != nil {
-		logger := core.GetLogger()
-		logger.Errorf("HTTP server failed: %v", err)
-		os.Exit(1)
+		return fmt.Errorf("HTTP server failed: %v", err)
 	}
+	return nil
 }
 
 func init() {
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/pkg/config"
+	"github.com/dmytrogajewski/hercules/internal/server/auth"
+	"github.com/dmytrogajewski/hercules/internal/server/jobs"
+	"github.com/dmytrogajewski/hercules/internal/server/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+type blockingRunner struct {
+	started chan struct{}
+}
+
+func (runner blockingRunner) Run(ctx context.Context, request jobs.Request) (
+	*jobs.Report, error) {
+	close(runner.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// queueRunner is blockingRunner which may run several jobs.
+type queueRunner struct {
+	started chan struct{}
+}
+
+func (runner queueRunner) Run(ctx context.Context, request jobs.Request) (
+	*jobs.Report, error) {
+	runner.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+type reportRunner struct {
+	report *jobs.Report
+}
+
+func (runner reportRunner) Run(ctx context.Context, request jobs.Request) (
+	*jobs.Report, error) {
+	return runner.report, nil
+}
+
+func newTestServer(t *testing.T, runner jobs.Runner) *Server {
+	manager := jobs.NewManager(jobs.NewMemoryStore(), runner, jobs.Options{})
+	assert.Nil(t, manager.Start())
+	t.Cleanup(manager.Stop)
+	m := metrics.New()
+	assert.Nil(t, m.RegisterJobs(manager))
+	return NewServer(&config.Config{}, manager, m, nil)
+}
+
+func serve(s *Server, method, url string, headers ...string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(method, url, nil)
+	for i := 0; i < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	s.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func waitForCompletion(t *testing.T, s *Server, id string) {
+	for i := 0; i < 100; i++ {
+		job, err := s.jobs.Get(id)
+		assert.Nil(t, err)
+		if job.State.Terminal() {
+			assert.Equal(t, jobs.StateCompleted, job.State)
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+}
+
+func TestServerCancel(t *testing.T) {
+	runner := blockingRunner{started: make(chan struct{})}
+	s := newTestServer(t, runner)
+	job, err := s.jobs.Submit(jobs.Request{Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	<-runner.started
+	recorder := serve(s, http.MethodDelete, "/api/v1/jobs/"+job.ID)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	var response AnalysisResponse
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "cancelled", response.Status)
+	assert.Equal(t, job.ID, response.JobID)
+	recorder = serve(s, http.MethodGet, "/api/v1/status/"+job.ID)
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	assert.Equal(t, "cancelled", response.Status)
+	assert.Equal(t, "Analysis cancelled", response.Message)
+	assert.Equal(t, http.StatusConflict, serve(s, http.MethodDelete, "/api/v1/jobs/"+job.ID).Code)
+	assert.Equal(t, http.StatusNotFound, serve(s, http.MethodDelete, "/api/v1/jobs/missing").Code)
+}
+
+func TestServerResults(t *testing.T) {
+	s := newTestServer(t, reportRunner{report: &jobs.Report{
+		Metadata: &jobs.Metadata{Commits: 7},
+		Results: map[string]*jobs.Result{
+			"burndown": {Name: "Burndown", Binary: []byte{1}, Text: []byte("  granularity: 30\n")},
+			"couples":  {Name: "Couples", Binary: []byte{2}, Text: []byte("  files: [a]\n")},
+		},
+	}})
+	job, err := s.jobs.Submit(jobs.Request{Repository: "repo", Analyses: []string{"burndown", "couples"}})
+	assert.Nil(t, err)
+	waitForCompletion(t, s, job.ID)
+	url := "/api/v1/results/" + job.ID
+
+	recorder := serve(s, http.MethodGet, url)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/x-yaml", recorder.Header().Get("Content-Type"))
+	assert.Contains(t, recorder.Body.String(), "  commits: 7\n")
+	assert.Contains(t, recorder.Body.String(), "Burndown:\n  granularity: 30\n")
+	assert.Contains(t, recorder.Body.String(), "Couples:\n")
+
+	recorder = serve(s, http.MethodGet, url+"?analysis=couples", "Accept", "application/json")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	var document map[string]interface{}
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &document))
+	assert.Contains(t, document, "Couples")
+	assert.NotContains(t, document, "Burndown")
+
+	recorder = serve(s, http.MethodGet, url+"?analysis=burndown", "Accept", "application/x-protobuf")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	message := &pb.AnalysisResults{}
+	assert.Nil(t, proto.Unmarshal(recorder.Body.Bytes(), message))
+	assert.Equal(t, map[string][]byte{"Burndown": {1}}, message.Contents)
+	assert.Equal(t, int32(7), message.Header.Commits)
+
+	recorder = serve(s, http.MethodGet, url+"?format=json", "Accept", "application/x-protobuf")
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, http.StatusNotAcceptable, serve(s, http.MethodGet, url, "Accept", "text/html").Code)
+	assert.Equal(t, http.StatusNotAcceptable, serve(s, http.MethodGet, url+"?format=xml").Code)
+	assert.Equal(t, http.StatusNotFound, serve(s, http.MethodGet, url+"?analysis=devs").Code)
+	assert.Equal(t, http.StatusNotFound, serve(s, http.MethodGet, "/api/v1/results/missing").Code)
+}
+
+func TestServerResultsNotReady(t *testing.T) {
+	runner := blockingRunner{started: make(chan struct{})}
+	s := newTestServer(t, runner)
+	job, err := s.jobs.Submit(jobs.Request{Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	<-runner.started
+	assert.Equal(t, http.StatusConflict, serve(s, http.MethodGet, "/api/v1/results/"+job.ID).Code)
+}
+
+func TestServerMetrics(t *testing.T) {
+	s := newTestServer(t, reportRunner{report: &jobs.Report{}})
+	job, err := s.jobs.Submit(jobs.Request{Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	waitForCompletion(t, s, job.ID)
+	recorder := serve(s, http.MethodGet, "/metrics")
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, recorder.Body.String(), `hercules_jobs{state="completed"} 1`)
+	assert.Contains(t, recorder.Body.String(), `hercules_jobs{state="queued"} 0`)
+	assert.Contains(t, recorder.Body.String(), "go_goroutines")
+
+	disabled := NewServer(&config.Config{}, s.jobs, nil, nil)
+	assert.Equal(t, http.StatusNotFound, serve(disabled, http.MethodGet, "/metrics").Code)
+}
+
+func TestServerAuth(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{Enabled: true, Keys: []config.APIKeyConfig{
+			{Name: "ci", Key: "ci-key", Scopes: []string{"submit", "read"}, MaxConcurrent: 1, RateLimit: 2},
+			{Name: "dev", Key: "dev-key", Scopes: []string{"submit", "read", "cancel"}, RateLimit: 1},
+			{Name: "ops", Key: "ops-key", Scopes: []string{"admin"}},
+		}},
+		Repository: config.RepositoryConfig{
+			AllowedProtocols: []string{"https"}, AllowedHosts: []string{"github.com"}},
+	}
+	authenticator, err := auth.NewAuthenticator(cfg.Auth)
+	assert.Nil(t, err)
+	runner := queueRunner{started: make(chan struct{}, 2)}
+	manager := jobs.NewManager(jobs.NewMemoryStore(), runner, jobs.Options{Workers: 2})
+	assert.Nil(t, manager.Start())
+	t.Cleanup(manager.Stop)
+	s := NewServer(cfg, manager, metrics.New(), authenticator)
+	submit := func(repository string, headers ...string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/api/v1/analyze", strings.NewReader(
+			`{"repository": "`+repository+`", "analyses": ["burndown"]}`))
+		for i := 0; i < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+		s.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+	const repository = "https://github.com/src-d/hercules"
+
+	recorder := submit(repository)
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	assert.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusUnauthorized, submit(repository, "Authorization", "Bearer wrong").Code)
+	assert.Equal(t, http.StatusOK, serve(s, http.MethodGet, "/health").Code)
+	assert.Equal(t, http.StatusForbidden, serve(s, http.MethodGet, "/metrics", "X-API-Key", "ci-key").Code)
+	assert.Equal(t, http.StatusOK, serve(s, http.MethodGet, "/metrics", "X-API-Key", "ops-key").Code)
+
+	// repository policy
+	assert.Equal(t, http.StatusForbidden, submit("http://github.com/src-d/hercules", "X-API-Key", "ci-key").Code)
+	assert.Equal(t, http.StatusForbidden, submit("https://gitlab.com/src-d/hercules", "X-API-Key", "ci-key").Code)
+	assert.Equal(t, http.StatusForbidden, submit("/etc", "X-API-Key", "ci-key").Code)
+
+	// concurrency quota
+	recorder = submit(repository, "Authorization", "Bearer ci-key")
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	var response AnalysisResponse
+	assert.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	<-runner.started
+	assert.Equal(t, http.StatusTooManyRequests, submit(repository, "Authorization", "Bearer ci-key").Code)
+
+	// the jobs of other keys are invisible except to admins
+	url := "/api/v1/status/" + response.JobID
+	assert.Equal(t, http.StatusOK, serve(s, http.MethodGet, url, "X-API-Key", "ci-key").Code)
+	assert.Equal(t, http.StatusNotFound, serve(s, http.MethodGet, url, "X-API-Key", "dev-key").Code)
+	assert.Equal(t, http.StatusOK, serve(s, http.MethodGet, url, "X-API-Key", "ops-key").Code)
+	assert.Equal(t, http.StatusForbidden,
+		serve(s, http.MethodDelete, "/api/v1/jobs/"+response.JobID, "X-API-Key", "ci-key").Code)
+	assert.Equal(t, http.StatusNotFound,
+		serve(s, http.MethodDelete, "/api/v1/jobs/"+response.JobID, "X-API-Key", "dev-key").Code)
+	assert.Equal(t, http.StatusOK,
+		serve(s, http.MethodDelete, "/api/v1/jobs/"+response.JobID, "X-API-Key", "ops-key").Code)
+	// the submission rejected by the concurrency quota did not consume the rate quota
+	assert.Equal(t, http.StatusAccepted, submit(repository, "Authorization", "Bearer ci-key").Code)
+
+	// rate quota
+	assert.Equal(t, http.StatusAccepted, submit(repository, "X-API-Key", "dev-key").Code)
+	assert.Equal(t, http.StatusTooManyRequests, submit(repository, "X-API-Key", "dev-key").Code)
+	job, err := s.jobs.Get(response.JobID)
+	assert.Nil(t, err)
+	assert.Equal(t, "ci", job.Request.Tenant)
+}
+
+func TestJobManagerConcurrentAnalyses(t *testing.T) {
+	cfg := &config.Config{Analysis: config.AnalysisConfig{
+		DefaultTickSize: 24, DefaultGranularity: 30, DefaultSampling: 30, MaxConcurrentAnalyses: 4},
+		Jobs: config.JobsConfig{Store: "memory"}}
+	manager, store, err := newJobManager(cfg, nil)
+	require.NoError(t, err)
+	defer store.Close()
+	require.NoError(t, manager.Start())
+	defer manager.Stop()
+	authors := [][]string{{"Alice", "Bob"}, {"Zed"}, {"Yan", "Xia"}, {"Wes"}}
+	analyses := [][]string{{"devs", "burndown"}, {"devs", "couples"}, {"devs", "file-history"}, {"devs"}}
+	ids := make([]string, len(authors))
+	for i := range authors {
+		job, err := manager.Submit(jobs.Request{
+			Repository: newTestEngineRepository(t, authors[i]...), Analyses: analyses[i],
+			Options: map[string]string{"pathspec": "*.go"}})
+		require.NoError(t, err)
+		ids[i] = job.ID
+	}
+	for i, id := range ids {
+		var job *jobs.Job
+		for j := 0; j < 1000; j++ {
+			if job, err = manager.Get(id); err == nil && job.State.Terminal() {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		require.Equal(t, jobs.StateCompleted, job.State, job.Error)
+		people := string(job.Results["devs"].Text)
+		assert.Equal(t, len(authors[i]), strings.Count(people, "@example.com"), people)
+		for _, author := range authors[i] {
+			assert.Contains(t, people, strings.ToLower(author)+"@example.com")
+		}
+	}
+}
@@ -30,6 +30,24 @@ type CacheBackend interface {
 	Close() error
 }
 
+// CacheEntry describes a value stored in a cache backend
+type CacheEntry struct {
+	// Key is the key of the value
+	Key string
+	// Size is the size of the value in bytes
+	Size int64
+	// ModTime is when the value was stored
+	ModTime time.Time
+	// Expires is when the value expires; zero if unknown
+	Expires time.Time
+}
+
+// CacheLister is implemented by the cache backends which can enumerate the stored values
+type CacheLister interface {
+	// List returns the entries whose keys start with the prefix, including the expired ones
+	List(ctx context.Context, prefix string) ([]CacheEntry, error)
+}
+
 // CacheConfig holds configuration for cache backends
 type CacheConfig struct {
 	// Backend type: "local", "s3", "memory"
@@ -50,5 +68,34 @@ type CacheConfig struct {
 
 	// Cache settings
 	DefaultTTL time.Duration `mapstructure:"default_ttl" yaml:"default_ttl"`
-	MaxSize    int64         `mapstructure:"max_size" yaml:"max_size"` // in bytes
+	MaxSize    int64         `mapstructure:"max_size" yaml:"max_size"` // in bytes, 0 means no limit
+
+	// CleanupInterval is the period of the background pruning of the local cache, 0 disables it
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval" yaml:"cleanup_interval"`
+}
+
+// CacheStats are the counters of a cache backend
+type CacheStats struct {
+	// Hits is the number of successful reads
+	Hits int64
+	// Misses is the number of reads of absent or expired values
+	Misses int64
+	// Errors is the number of reads which failed for other reasons than a missing value
+	Errors int64
+	// Evictions is the number of values removed to stay within MaxSize
+	Evictions int64
+	// Expirations is the number of removed expired values
+	Expirations int64
+	// Entries is the number of stored values
+	Entries int64
+	// Size is the total size of the stored values in bytes
+	Size int64
+	// MaxSize is the limit of Size, 0 means no limit
+	MaxSize int64
+}
+
+// CacheStatsProvider is implemented by the cache backends which track CacheStats
+type CacheStatsProvider interface {
+	// Stats returns the snapshot of the counters
+	Stats() CacheStats
 }
@@ -1,10 +1,13 @@
 package core
 
 import (
+	"bytes"
+	"container/list"
 	"context"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,68 +25,150 @@ func NewCacheBackend(cfg CacheConfig) (CacheBackend, error) {
 	}
 }
 
-// MemoryCache implements CacheBackend using in-memory storage
+// MemoryCache implements CacheBackend using in-memory storage. It is safe for concurrent use.
+// If MaxSize is set, the least recently used values are evicted to keep the total size
+// of the values within the limit.
 type MemoryCache struct {
-	data       map[string][]byte
-	expiry     map[string]time.Time
+	mutex sync.Mutex
+	items map[string]*list.Element
+	// lru orders the *memoryCacheItem-s from the most to the least recently used
+	lru        *list.List
+	stats      CacheStats
 	defaultTTL time.Duration
 }
 
+// memoryCacheItem is a value stored in MemoryCache
+type memoryCacheItem struct {
+	key    string
+	value  []byte
+	stored time.Time
+	expiry time.Time
+}
+
 // NewMemoryCache creates a new in-memory cache backend
 func NewMemoryCache(cfg CacheConfig) (*MemoryCache, error) {
+	if cfg.MaxSize < 0 {
+		return nil, fmt.Errorf("invalid cache max size: %d", cfg.MaxSize)
+	}
 	return &MemoryCache{
-		data:       make(map[string][]byte),
-		expiry:     make(map[string]time.Time),
+		items:      make(map[string]*list.Element),
+		lru:        list.New(),
+		stats:      CacheStats{MaxSize: cfg.MaxSize},
 		defaultTTL: cfg.DefaultTTL,
 	}, nil
 }
 
-// Get retrieves a value from memory cache
-func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
-	if exists, err := m.Exists(ctx, key); err != nil || !exists {
+// lookup returns the value if it exists and is not expired and marks it as recently used.
+// The caller must hold the mutex.
+func (m *MemoryCache) lookup(key string) ([]byte, bool) {
+	element, exists := m.items[key]
+	if !exists {
+		return nil, false
+	}
+	item := element.Value.(*memoryCacheItem)
+	if time.Now().After(item.expiry) {
+		// Remove expired entry
+		m.remove(element)
+		m.stats.Expirations++
+		return nil, false
+	}
+	m.lru.MoveToFront(element)
+	return item.value, true
+}
+
+// read is lookup() which counts hits and misses.
+func (m *MemoryCache) read(key string) ([]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	value, exists := m.lookup(key)
+	if !exists {
+		m.stats.Misses++
 		return nil, fmt.Errorf("cache key not found or expired")
 	}
-	return m.data[key], nil
+	m.stats.Hits++
+	return value, nil
+}
+
+// remove deletes the item. The caller must hold the mutex.
+func (m *MemoryCache) remove(element *list.Element) {
+	item := m.lru.Remove(element).(*memoryCacheItem)
+	delete(m.items, item.key)
+	m.stats.Size -= int64(len(item.value))
+	m.stats.Entries--
+}
+
+// store inserts or replaces the value and evicts the least recently used values
+// if the size exceeds the limit.
+func (m *MemoryCache) store(key string, value []byte, ttl time.Duration) error {
+	size := int64(len(value))
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stats.MaxSize > 0 && size > m.stats.MaxSize {
+		return fmt.Errorf("the value of %s (%d bytes) exceeds the cache max size (%d bytes)",
+			key, size, m.stats.MaxSize)
+	}
+	if element, exists := m.items[key]; exists {
+		m.remove(element)
+	}
+	m.items[key] = m.lru.PushFront(&memoryCacheItem{
+		key: key, value: value, stored: time.Now(), expiry: time.Now().Add(ttl)})
+	m.stats.Size += size
+	m.stats.Entries++
+	if m.stats.MaxSize <= 0 || m.stats.Size <= m.stats.MaxSize {
+		return nil
+	}
+	// the expired values go first
+	now := time.Now()
+	for element := m.lru.Back(); element != nil; {
+		prev := element.Prev()
+		if now.After(element.Value.(*memoryCacheItem).expiry) {
+			m.remove(element)
+			m.stats.Expirations++
+		}
+		element = prev
+	}
+	for m.stats.Size > m.stats.MaxSize {
+		m.remove(m.lru.Back())
+		m.stats.Evictions++
+	}
+	return nil
+}
+
+// Get retrieves a value from memory cache
+func (m *MemoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+	return m.read(key)
 }
 
 // Set stores a value in memory cache
 func (m *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
-	m.data[key] = value
-	m.expiry[key] = time.Now().Add(ttl)
-	return nil
+	return m.store(key, value, ttl)
 }
 
 // Delete removes a value from memory cache
 func (m *MemoryCache) Delete(ctx context.Context, key string) error {
-	delete(m.data, key)
-	delete(m.expiry, key)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if element, exists := m.items[key]; exists {
+		m.remove(element)
+	}
 	return nil
 }
 
 // Exists checks if a key exists in memory cache and is not expired
 func (m *MemoryCache) Exists(ctx context.Context, key string) (bool, error) {
-	if _, exists := m.data[key]; !exists {
-		return false, nil
-	}
-
-	if expiry, exists := m.expiry[key]; exists && time.Now().After(expiry) {
-		// Remove expired entry
-		delete(m.data, key)
-		delete(m.expiry, key)
-		return false, nil
-	}
-
-	return true, nil
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, exists := m.lookup(key)
+	return exists, nil
 }
 
 // GetReader returns an io.ReadCloser for streaming large values
 func (m *MemoryCache) GetReader(ctx context.Context, key string) (io.ReadCloser, error) {
-	if exists, err := m.Exists(ctx, key); err != nil || !exists {
-		return nil, fmt.Errorf("cache key not found or expired")
+	data, err := m.read(key)
+	if err != nil {
+		return nil, err
 	}
-
-	data := m.data[key]
-	return io.NopCloser(strings.NewReader(string(data))), nil
+	return io.NopCloser(bytes.NewReader(data)), nil
 }
 
 // SetReader stores data from an io.Reader with optional TTL
@@ -92,16 +177,44 @@ func (m *MemoryCache) SetReader(ctx context.Context, key string, reader io.Reade
 	if err != nil {
 		return err
 	}
+	return m.store(key, data, ttl)
+}
 
-	m.data[key] = data
-	m.expiry[key] = time.Now().Add(ttl)
-	return nil
+// List returns the entries whose keys start with the prefix
+func (m *MemoryCache) List(ctx context.Context, prefix string) ([]CacheEntry, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var entries []CacheEntry
+	for key, element := range m.items {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		item := element.Value.(*memoryCacheItem)
+		entries = append(entries, CacheEntry{
+			Key:     key,
+			Size:    int64(len(item.value)),
+			ModTime: item.stored,
+			Expires: item.expiry,
+		})
+	}
+	return entries, nil
+}
+
+// Stats returns the snapshot of the cache counters
+func (m *MemoryCache) Stats() CacheStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.stats
 }
 
 // Close performs any cleanup operations
 func (m *MemoryCache) Close() error {
 	// Clear memory cache
-	m.data = make(map[string][]byte)
-	m.expiry = make(map[string]time.Time)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.items = make(map[string]*list.Element)
+	m.lru.Init()
+	m.stats.Entries = 0
+	m.stats.Size = 0
 	return nil
 }
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryCacheLRU(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewMemoryCache(CacheConfig{MaxSize: 10})
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, "a", []byte("aaaa"), time.Hour))
+	require.NoError(t, cache.Set(ctx, "b", []byte("bbb"), time.Hour))
+	require.NoError(t, cache.Set(ctx, "c", []byte("cc"), time.Hour))
+	// "a" becomes the most recently used
+	value, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("aaaa"), value)
+	require.NoError(t, cache.Set(ctx, "d", []byte("dd"), time.Hour))
+	exists, _ := cache.Exists(ctx, "b")
+	assert.False(t, exists)
+	for _, key := range []string{"a", "c", "d"} {
+		exists, _ := cache.Exists(ctx, key)
+		assert.True(t, exists, key)
+	}
+	stats := cache.Stats()
+	assert.Equal(t, CacheStats{Hits: 1, Evictions: 1, Entries: 3, Size: 8, MaxSize: 10}, stats)
+
+	// replacing updates the size
+	require.NoError(t, cache.Set(ctx, "a", []byte("a"), time.Hour))
+	assert.Equal(t, int64(5), cache.Stats().Size)
+	require.NoError(t, cache.SetReader(ctx, "e", bytes.NewReader([]byte("eeeee")), time.Hour))
+	assert.Equal(t, int64(10), cache.Stats().Size)
+	reader, err := cache.GetReader(ctx, "e")
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("eeeee"), data)
+
+	assert.Error(t, cache.Set(ctx, "f", make([]byte, 11), time.Hour))
+	require.NoError(t, cache.Delete(ctx, "e"))
+	_, err = cache.Get(ctx, "e")
+	assert.Error(t, err)
+	stats = cache.Stats()
+	assert.Equal(t, int64(5), stats.Size)
+	assert.Equal(t, int64(3), stats.Entries)
+	assert.Equal(t, int64(1), stats.Misses)
+	require.NoError(t, cache.Close())
+	assert.Equal(t, int64(0), cache.Stats().Size)
+}
+
+func TestMemoryCacheExpiration(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewMemoryCache(CacheConfig{MaxSize: 4})
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, "expired", []byte("xx"), -time.Second))
+	require.NoError(t, cache.Set(ctx, "old", []byte("yy"), time.Hour))
+	// the expired value is removed before the least recently used
+	require.NoError(t, cache.Set(ctx, "new", []byte("zz"), time.Hour))
+	exists, _ := cache.Exists(ctx, "old")
+	assert.True(t, exists)
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Expirations)
+	assert.Equal(t, int64(0), stats.Evictions)
+
+	require.NoError(t, cache.Set(ctx, "expired", []byte("x"), -time.Second))
+	_, err = cache.Get(ctx, "expired")
+	assert.Error(t, err)
+	assert.Equal(t, int64(2), cache.Stats().Expirations)
+	_, err = NewMemoryCache(CacheConfig{MaxSize: -1})
+	assert.Error(t, err)
+}
+
+func TestMemoryCacheConcurrency(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewMemoryCache(CacheConfig{MaxSize: 1000})
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				key := fmt.Sprintf("key-%d", (worker*j)%100)
+				assert.NoError(t, cache.Set(ctx, key, make([]byte, 10+j%20), time.Hour))
+				_, _ = cache.Get(ctx, key)
+				_, _ = cache.Exists(ctx, key)
+				if j%10 == 0 {
+					assert.NoError(t, cache.Delete(ctx, key))
+					_, err := cache.List(ctx, "key-")
+					assert.NoError(t, err)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	stats := cache.Stats()
+	assert.LessOrEqual(t, stats.Size, int64(1000))
+	entries, err := cache.List(ctx, "")
+	require.NoError(t, err)
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size
+	}
+	assert.Equal(t, stats.Size, size)
+	assert.Equal(t, stats.Entries, int64(len(entries)))
+}
@@ -0,0 +1,235 @@
+package core
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/pkg/errors"
+)
+
+// CheckpointablePipelineItem is the interface to save the state of a pipeline item after
+// Pipeline.Run() and to restore it in a later run which continues the analysis from the last
+// analysed commit. The items which do not implement it are considered stateless between commits;
+// however, LeafPipelineItem-s and HibernateablePipelineItem-s must implement it for checkpoints
+// to work.
+type CheckpointablePipelineItem interface {
+	PipelineItem
+	// Checkpoint writes the state of the item. It is called after the last commit and before
+	// LeafPipelineItem.Finalize().
+	Checkpoint(writer io.Writer) error
+	// Restore reads the state written by Checkpoint(). It is called after Initialize().
+	Restore(reader io.Reader) error
+}
+
+// CheckpointVersion is the version of the checkpoint format written by Pipeline.Run().
+const CheckpointVersion = 1
+
+// Checkpoint is the saved state of a Pipeline which analysed the history up to Head.
+// It is written by Pipeline.Run() if ConfigPipelineCheckpointPath is set and restored
+// by Pipeline.Initialize() if ConfigPipelineResume is set.
+type Checkpoint struct {
+	// Version is the format version, see CheckpointVersion.
+	Version int
+	// Head is the last analysed commit.
+	Head plumbing.Hash
+	// BeginTime is the time of the first analysed commit.
+	BeginTime int64
+	// EndTime is the time of the newest analysed commit.
+	EndTime int64
+	// CommitsNumber is the number of analysed commits.
+	CommitsNumber int
+	// Items maps the names of CheckpointablePipelineItem-s to their states.
+	Items map[string][]byte
+}
+
+// checkpointFile is the serialized Checkpoint. encoding/gob cannot handle plumbing.Hash,
+// so the items must convert the hashes to strings, too.
+type checkpointFile struct {
+	Version       int
+	Head          string
+	BeginTime     int64
+	EndTime       int64
+	CommitsNumber int
+	Items         map[string][]byte
+}
+
+// ReadCheckpoint loads the checkpoint from the file system.
+func ReadCheckpoint(path string) (*Checkpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data := checkpointFile{}
+	if err := gob.NewDecoder(file).Decode(&data); err != nil {
+		return nil, errors.Wrapf(err, "corrupted checkpoint %s", path)
+	}
+	checkpoint := &Checkpoint{
+		Version:       data.Version,
+		Head:          plumbing.NewHash(data.Head),
+		BeginTime:     data.BeginTime,
+		EndTime:       data.EndTime,
+		CommitsNumber: data.CommitsNumber,
+		Items:         data.Items,
+	}
+	if checkpoint.Version != CheckpointVersion {
+		return nil, fmt.Errorf("unsupported checkpoint version %d in %s, expected %d",
+			checkpoint.Version, path, CheckpointVersion)
+	}
+	return checkpoint, nil
+}
+
+// Write saves the checkpoint to the file system. The file is replaced atomically so that
+// a failure never leaves a truncated checkpoint.
+func (checkpoint *Checkpoint) Write(path string) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := file.Name()
+	err = gob.NewEncoder(file).Encode(checkpointFile{
+		Version:       checkpoint.Version,
+		Head:          checkpoint.Head.String(),
+		BeginTime:     checkpoint.BeginTime,
+		EndTime:       checkpoint.EndTime,
+		CommitsNumber: checkpoint.CommitsNumber,
+		Items:         checkpoint.Items,
+	})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+// FilterCommits leaves only the commits which were not analysed before the checkpoint:
+// the descendants of Head. The order is preserved. It fails if Head is not among `commits`,
+// e.g. because the history was rewritten.
+//
+// The commits which belong to branches forked before Head and merged after it are dropped,
+// so their changes are accounted in the corresponding merge commits.
+func (checkpoint *Checkpoint) FilterCommits(commits []*object.Commit) ([]*object.Commit, error) {
+	children := map[plumbing.Hash][]plumbing.Hash{}
+	found := false
+	for _, commit := range commits {
+		if commit.Hash == checkpoint.Head {
+			found = true
+		}
+		for _, parent := range commit.ParentHashes {
+			children[parent] = append(children[parent], commit.Hash)
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("the checkpointed commit %s is not in the analysed history",
+			checkpoint.Head.String())
+	}
+	descendants := map[plumbing.Hash]bool{}
+	for queue := children[checkpoint.Head]; len(queue) > 0; {
+		head := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if descendants[head] {
+			continue
+		}
+		descendants[head] = true
+		queue = append(queue, children[head]...)
+	}
+	result := make([]*object.Commit, 0, len(descendants))
+	for _, commit := range commits {
+		if descendants[commit.Hash] {
+			result = append(result, commit)
+		}
+	}
+	return result, nil
+}
+
+// checkCheckpointable returns an error if the item keeps the state which cannot be checkpointed.
+func checkCheckpointable(item PipelineItem) error {
+	if _, ok := item.(CheckpointablePipelineItem); ok {
+		return nil
+	}
+	_, isLeaf := item.(LeafPipelineItem)
+	_, isHibernateable := item.(HibernateablePipelineItem)
+	if isLeaf || isHibernateable {
+		return fmt.Errorf("%s does not support checkpoints", item.Name())
+	}
+	return nil
+}
+
+// restore loads the state of the item from the checkpoint.
+func (checkpoint *Checkpoint) restore(item PipelineItem) error {
+	if err := checkCheckpointable(item); err != nil {
+		return err
+	}
+	citem, ok := item.(CheckpointablePipelineItem)
+	if !ok {
+		return nil
+	}
+	state, exists := checkpoint.Items[item.Name()]
+	if !exists {
+		return fmt.Errorf("the checkpoint does not contain the state of %s", item.Name())
+	}
+	return errors.Wrapf(citem.Restore(bytes.NewReader(state)),
+		"failed to restore %s from the checkpoint", item.Name())
+}
+
+// save records the state of the item in the checkpoint.
+func (checkpoint *Checkpoint) save(item PipelineItem) error {
+	citem, ok := item.(CheckpointablePipelineItem)
+	if !ok {
+		return nil
+	}
+	buffer := &bytes.Buffer{}
+	if err := citem.Checkpoint(buffer); err != nil {
+		return errors.Wrapf(err, "failed to checkpoint %s", item.Name())
+	}
+	checkpoint.Items[item.Name()] = buffer.Bytes()
+	return nil
+}
+
+// EncodeCheckpoint is the helper to implement CheckpointablePipelineItem.Checkpoint()
+// which serializes `state` with encoding/gob. plumbing.Hash is not supported by encoding/gob,
+// see HashesToStrings().
+func EncodeCheckpoint(writer io.Writer, state interface{}) error {
+	return gob.NewEncoder(writer).Encode(state)
+}
+
+// DecodeCheckpoint is the helper to implement CheckpointablePipelineItem.Restore()
+// which deserializes `state` written by EncodeCheckpoint().
+func DecodeCheckpoint(reader io.Reader, state interface{}) error {
+	return gob.NewDecoder(reader).Decode(state)
+}
+
+// HashesToStrings converts the hashes to the hex strings which can be passed to EncodeCheckpoint().
+func HashesToStrings(hashes []plumbing.Hash) []string {
+	if hashes == nil {
+		return nil
+	}
+	result := make([]string, len(hashes))
+	for i, hash := range hashes {
+		result[i] = hash.String()
+	}
+	return result
+}
+
+// StringsToHashes is the inverse of HashesToStrings().
+func StringsToHashes(strs []string) []plumbing.Hash {
+	if strs == nil {
+		return nil
+	}
+	result := make([]plumbing.Hash, len(strs))
+	for i, str := range strs {
+		result[i] = plumbing.NewHash(str)
+	}
+	return result
+}
@@ -0,0 +1,210 @@
+package core
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkpointTestItem records the consumed commits and their indices.
+type checkpointTestItem struct {
+	NoopMerger
+	Hashes  []plumbing.Hash
+	Indices []int
+}
+
+func (item *checkpointTestItem) Name() string {
+	return "CheckpointTest"
+}
+
+func (item *checkpointTestItem) Provides() []string {
+	return []string{}
+}
+
+func (item *checkpointTestItem) Requires() []string {
+	return []string{}
+}
+
+func (item *checkpointTestItem) ListConfigurationOptions() []ConfigurationOption {
+	return nil
+}
+
+func (item *checkpointTestItem) Configure(facts map[string]interface{}) error {
+	return nil
+}
+
+func (item *checkpointTestItem) Initialize(repository *git.Repository) error {
+	item.Hashes = nil
+	item.Indices = nil
+	return nil
+}
+
+func (item *checkpointTestItem) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
+	item.Hashes = append(item.Hashes, deps[DependencyCommit].(*object.Commit).Hash)
+	item.Indices = append(item.Indices, deps[DependencyIndex].(int))
+	return nil, nil
+}
+
+func (item *checkpointTestItem) Fork(n int) []PipelineItem {
+	return ForkSamePipelineItem(item, n)
+}
+
+func (item *checkpointTestItem) Flag() string {
+	return "checkpoint-test"
+}
+
+func (item *checkpointTestItem) Description() string {
+	return "Records the consumed commits."
+}
+
+func (item *checkpointTestItem) Finalize() interface{} {
+	return item.Hashes
+}
+
+func (item *checkpointTestItem) Serialize(result interface{}, binary bool, writer io.Writer) error {
+	return nil
+}
+
+func (item *checkpointTestItem) Checkpoint(writer io.Writer) error {
+	return EncodeCheckpoint(writer, HashesToStrings(item.Hashes))
+}
+
+func (item *checkpointTestItem) Restore(reader io.Reader) error {
+	var hashes []string
+	if err := DecodeCheckpoint(reader, &hashes); err != nil {
+		return err
+	}
+	item.Hashes = StringsToHashes(hashes)
+	return nil
+}
+
+func TestCheckpointWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint")
+	checkpoint := &Checkpoint{
+		Version:       CheckpointVersion,
+		Head:          plumbing.NewHash("cce947b98a050c6d356bc6ba95030254914027b1"),
+		BeginTime:     100,
+		EndTime:       200,
+		CommitsNumber: 3,
+		Items:         map[string][]byte{"Item": {1, 2, 3}},
+	}
+	require.NoError(t, checkpoint.Write(path))
+	loaded, err := ReadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Equal(t, checkpoint, loaded)
+	checkpoint.Version = CheckpointVersion + 1
+	require.NoError(t, checkpoint.Write(path))
+	_, err = ReadCheckpoint(path)
+	assert.Error(t, err)
+	_, err = ReadCheckpoint(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestCheckpointFilterCommits(t *testing.T) {
+	_, commits := test.NewRepository(
+		test.Commit{Files: map[string]string{"a.txt": "a\n"}},
+		test.Commit{Files: map[string]string{"a.txt": "b\n"}},
+		test.Commit{Files: map[string]string{"a.txt": "c\n"}})
+	checkpoint := &Checkpoint{Head: commits[0].Hash}
+	filtered, err := checkpoint.FilterCommits(commits)
+	assert.NoError(t, err)
+	assert.Equal(t, commits[1:], filtered)
+	checkpoint.Head = commits[2].Hash
+	filtered, err = checkpoint.FilterCommits(commits)
+	assert.NoError(t, err)
+	assert.Len(t, filtered, 0)
+	checkpoint.Head = plumbing.ZeroHash
+	_, err = checkpoint.FilterCommits(commits)
+	assert.Error(t, err)
+}
+
+func TestPipelineCheckpointResume(t *testing.T) {
+	repository, commits := test.NewRepository(
+		test.Commit{Files: map[string]string{"a.txt": "a\n"}},
+		test.Commit{Files: map[string]string{"a.txt": "b\n"}},
+		test.Commit{Files: map[string]string{"a.txt": "c\n"}})
+	path := filepath.Join(t.TempDir(), "checkpoint")
+
+	pipeline := NewPipeline(repository)
+	item := &checkpointTestItem{}
+	pipeline.AddItem(item)
+	require.NoError(t, pipeline.Initialize(map[string]interface{}{
+		ConfigPipelineCommits:        commits[:2],
+		ConfigPipelineCheckpointPath: path,
+	}))
+	_, err := pipeline.Run(commits[:2])
+	require.NoError(t, err)
+	checkpoint, err := ReadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Equal(t, commits[1].Hash, checkpoint.Head)
+	assert.Equal(t, 2, checkpoint.CommitsNumber)
+	assert.Equal(t, commits[0].Committer.When.Unix(), checkpoint.BeginTime)
+	assert.Contains(t, checkpoint.Items, item.Name())
+
+	pipeline = NewPipeline(repository)
+	item = &checkpointTestItem{}
+	pipeline.AddItem(item)
+	require.NoError(t, pipeline.Initialize(map[string]interface{}{
+		ConfigPipelineResume:         checkpoint,
+		ConfigPipelineCheckpointPath: path,
+	}))
+	result, err := pipeline.Run(commits[2:])
+	require.NoError(t, err)
+	assert.Equal(t, []plumbing.Hash{commits[0].Hash, commits[1].Hash, commits[2].Hash},
+		result[item])
+	assert.Equal(t, []int{2}, item.Indices)
+	common := result[nil].(*CommonAnalysisResult)
+	assert.Equal(t, 3, common.CommitsNumber)
+	assert.Equal(t, commits[0].Committer.When.Unix(), common.BeginTime)
+	assert.Equal(t, commits[2].Committer.When.Unix(), common.EndTime)
+	checkpoint, err = ReadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Equal(t, commits[2].Hash, checkpoint.Head)
+	assert.Equal(t, 3, checkpoint.CommitsNumber)
+
+	// nothing new
+	pipeline = NewPipeline(repository)
+	item = &checkpointTestItem{}
+	pipeline.AddItem(item)
+	require.NoError(t, pipeline.Initialize(map[string]interface{}{
+		ConfigPipelineResume: checkpoint,
+	}))
+	result, err = pipeline.Run(nil)
+	require.NoError(t, err)
+	assert.Len(t, result[item], 3)
+	assert.Equal(t, 3, result[nil].(*CommonAnalysisResult).CommitsNumber)
+}
+
+func TestPipelineCheckpointUnsupported(t *testing.T) {
+	repository, commits := test.NewRepository(
+		test.Commit{Files: map[string]string{"a.txt": "a\n"}})
+	pipeline := NewPipeline(repository)
+	pipeline.AddItem(&testPipelineItem{})
+	err := pipeline.Initialize(map[string]interface{}{
+		ConfigPipelineCommits:        commits,
+		ConfigPipelineCheckpointPath: filepath.Join(t.TempDir(), "checkpoint"),
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not support checkpoints")
+}
+
+func TestPipelineCheckpointMissingState(t *testing.T) {
+	repository, commits := test.NewRepository(
+		test.Commit{Files: map[string]string{"a.txt": "a\n"}})
+	pipeline := NewPipeline(repository)
+	pipeline.AddItem(&checkpointTestItem{})
+	err := pipeline.Initialize(map[string]interface{}{
+		ConfigPipelineCommits: commits,
+		ConfigPipelineResume: &Checkpoint{
+			Version: CheckpointVersion, Head: commits[0].Hash, Items: map[string][]byte{}},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "does not contain the state of CheckpointTest")
+}
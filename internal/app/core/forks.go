@@ -147,15 +147,18 @@ func mergeItems(branches [][]PipelineItem) {
 
 // getMasterBranch returns the branch with the smallest index.
 func getMasterBranch(branches map[int][]PipelineItem) []PipelineItem {
+	return branches[getMasterBranchIndex(branches)]
+}
+
+// getMasterBranchIndex returns the smallest branch index.
+func getMasterBranchIndex(branches map[int][]PipelineItem) int {
 	minKey := 1 << 31
-	var minVal []PipelineItem
-	for key, val := range branches {
+	for key := range branches {
 		if key < minKey {
 			minKey = key
-			minVal = val
 		}
 	}
-	return minVal
+	return minKey
 }
 
 // prepareRunPlan schedules the actions for Pipeline.Run().
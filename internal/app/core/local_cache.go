@@ -6,57 +6,120 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
-// LocalCache implements CacheBackend using local filesystem
+// LocalCache implements CacheBackend using local filesystem. If MaxSize is set, the least
+// recently used files are removed in the background to keep their total size within the limit.
 type LocalCache struct {
 	basePath   string
 	defaultTTL time.Duration
+
+	mutex sync.Mutex
+	// files map the file names to their sizes and the last access times
+	files map[string]localCacheFile
+	stats CacheStats
+	// cleaning serializes Cleanup() calls
+	cleaning sync.Mutex
+	// cleanupRequests wakes up the background cleanup when the size exceeds the limit
+	cleanupRequests chan struct{}
+	done            chan struct{}
+	closeOnce       sync.Once
+	wg              sync.WaitGroup
+}
+
+// localCacheFile is what LocalCache knows about a cache file
+type localCacheFile struct {
+	size int64
+	// accessed is the time of the last read or write in this process or the modification time
+	accessed time.Time
 }
 
-// NewLocalCache creates a new local filesystem cache backend
+// NewLocalCache creates a new local filesystem cache backend. It removes the expired files
+// and starts the background cleanup every CleanupInterval if it is set.
 func NewLocalCache(cfg CacheConfig) (*LocalCache, error) {
 	path := cfg.LocalPath
 	if path == "" {
 		path = "/tmp/hercules-cache"
 	}
+	if cfg.MaxSize < 0 {
+		return nil, fmt.Errorf("invalid cache max size: %d", cfg.MaxSize)
+	}
 
 	// Create cache directory if it doesn't exist
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create cache directory %s: %w", path, err)
 	}
 
-	return &LocalCache{
-		basePath:   path,
-		defaultTTL: cfg.DefaultTTL,
-	}, nil
+	l := &LocalCache{
+		basePath:        path,
+		defaultTTL:      cfg.DefaultTTL,
+		files:           map[string]localCacheFile{},
+		stats:           CacheStats{MaxSize: cfg.MaxSize},
+		cleanupRequests: make(chan struct{}, 1),
+		done:            make(chan struct{}),
+	}
+	// calculate the initial size
+	if err := l.Cleanup(); err != nil {
+		return nil, fmt.Errorf("failed to scan cache directory %s: %w", path, err)
+	}
+	if cfg.CleanupInterval > 0 || cfg.MaxSize > 0 {
+		l.wg.Add(1)
+		go l.cleanupLoop(cfg.CleanupInterval)
+	}
+	return l, nil
+}
+
+// cleanupLoop calls Cleanup() every `interval` (0 disables) and on requests until Close().
+func (l *LocalCache) cleanupLoop(interval time.Duration) {
+	defer l.wg.Done()
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-tick:
+		case <-l.cleanupRequests:
+		}
+		if err := l.Cleanup(); err != nil {
+			GetLogger().Warnf("Warning: failed to clean up cache directory %s: %v", l.basePath, err)
+		}
+	}
 }
 
 // makePath creates a filesystem path for a cache key
 func (l *LocalCache) makePath(key string) string {
-	// Sanitize key for filesystem
+	return filepath.Join(l.basePath, sanitizeKey(key))
+}
+
+// sanitizeKey converts a cache key to a file name
+func sanitizeKey(key string) string {
 	safeKey := strings.ReplaceAll(key, "/", "_")
-	safeKey = strings.ReplaceAll(safeKey, "\\", "_")
-	return filepath.Join(l.basePath, safeKey)
+	return strings.ReplaceAll(safeKey, "\\", "_")
 }
 
 // Get retrieves a value from local cache
 func (l *LocalCache) Get(ctx context.Context, key string) ([]byte, error) {
-	path := l.makePath(key)
-
-	// Check if file exists and is not expired
-	if exists, err := l.Exists(ctx, key); err != nil || !exists {
-		return nil, fmt.Errorf("cache key not found or expired")
+	reader, err := l.GetReader(ctx, key)
+	if err != nil {
+		return nil, err
 	}
-
-	return os.ReadFile(path)
+	defer reader.Close()
+	return io.ReadAll(reader)
 }
 
 // Set stores a value in local cache
 func (l *LocalCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
 	path := l.makePath(key)
+	defer l.written(path)
 
 	// Create directory if needed
 	dir := filepath.Dir(path)
@@ -89,7 +152,48 @@ func (l *LocalCache) Set(ctx context.Context, key string, value []byte, ttl time
 // Delete removes a value from local cache
 func (l *LocalCache) Delete(ctx context.Context, key string) error {
 	path := l.makePath(key)
-	return os.Remove(path)
+	return l.removeFile(path)
+}
+
+// removeFile deletes the cache file and updates the stats.
+func (l *LocalCache) removeFile(path string) error {
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if file, exists := l.files[name]; exists {
+		delete(l.files, name)
+		l.stats.Size -= file.size
+		l.stats.Entries--
+	}
+	return nil
+}
+
+// written updates the stats after writing the file and requests the cleanup if the size
+// exceeds the limit.
+func (l *LocalCache) written(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	name := filepath.Base(path)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if previous, exists := l.files[name]; exists {
+		l.stats.Size -= previous.size
+	} else {
+		l.stats.Entries++
+	}
+	l.files[name] = localCacheFile{size: info.Size(), accessed: time.Now()}
+	l.stats.Size += info.Size()
+	if l.stats.MaxSize > 0 && l.stats.Size > l.stats.MaxSize {
+		select {
+		case l.cleanupRequests <- struct{}{}:
+		default:
+		}
+	}
 }
 
 // Exists checks if a key exists in local cache and is not expired
@@ -120,7 +224,11 @@ func (l *LocalCache) Exists(ctx context.Context, key string) (bool, error) {
 	// Check if expired
 	if time.Now().Unix() > ttl {
 		// Remove expired file
-		os.Remove(path)
+		if l.removeFile(path) == nil {
+			l.mutex.Lock()
+			l.stats.Expirations++
+			l.mutex.Unlock()
+		}
 		return false, nil
 	}
 
@@ -133,6 +241,9 @@ func (l *LocalCache) GetReader(ctx context.Context, key string) (io.ReadCloser,
 
 	// Check if file exists and is not expired
 	if exists, err := l.Exists(ctx, key); err != nil || !exists {
+		l.mutex.Lock()
+		l.stats.Misses++
+		l.mutex.Unlock()
 		return nil, fmt.Errorf("cache key not found or expired")
 	}
 
@@ -140,6 +251,14 @@ func (l *LocalCache) GetReader(ctx context.Context, key string) (io.ReadCloser,
 	if err != nil {
 		return nil, err
 	}
+	name := filepath.Base(path)
+	l.mutex.Lock()
+	l.stats.Hits++
+	if info, exists := l.files[name]; exists {
+		info.accessed = time.Now()
+		l.files[name] = info
+	}
+	l.mutex.Unlock()
 
 	// Skip TTL header
 	var ttl int64
@@ -154,6 +273,7 @@ func (l *LocalCache) GetReader(ctx context.Context, key string) (io.ReadCloser,
 // SetReader stores data from an io.Reader with optional TTL
 func (l *LocalCache) SetReader(ctx context.Context, key string, reader io.Reader, ttl time.Duration) error {
 	path := l.makePath(key)
+	defer l.written(path)
 
 	// Create directory if needed
 	dir := filepath.Dir(path)
@@ -183,41 +303,152 @@ func (l *LocalCache) SetReader(ctx context.Context, key string, reader io.Reader
 	return nil
 }
 
-// Close performs any cleanup operations
-func (l *LocalCache) Close() error {
-	// No cleanup needed for local cache
-	return nil
+// List returns the entries whose keys start with the prefix. The keys are sanitized
+// the same way as the file names.
+func (l *LocalCache) List(ctx context.Context, prefix string) ([]CacheEntry, error) {
+	safePrefix := sanitizeKey(prefix)
+	var entries []CacheEntry
+	err := l.walk(func(path string, info os.FileInfo, expiry time.Time) {
+		if !strings.HasPrefix(info.Name(), safePrefix) {
+			return
+		}
+		entries = append(entries, CacheEntry{
+			Key:     info.Name(),
+			Size:    info.Size() - int64(len(fmt.Sprintf("TTL:%d\n", expiry.Unix()))),
+			ModTime: info.ModTime(),
+			Expires: expiry,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
 }
 
-// Cleanup removes expired cache entries
-func (l *LocalCache) Cleanup() error {
-	return filepath.Walk(l.basePath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// walk calls `visit` for each cache file with the expiration time from its TTL header.
+// The cache files are never nested and the other files are skipped, because the cache
+// directory may be shared, e.g. with the clones of the analysed repositories.
+func (l *LocalCache) walk(visit func(path string, info os.FileInfo, expiry time.Time)) error {
+	dirEntries, err := os.ReadDir(l.basePath)
+	if err != nil {
+		return err
+	}
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.Type().IsRegular() {
+			continue
 		}
-
-		if info.IsDir() {
-			return nil
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue // Removed in the meantime
 		}
-
-		// Check if file is expired
+		path := filepath.Join(l.basePath, dirEntry.Name())
 		file, err := os.Open(path)
 		if err != nil {
-			return nil // Skip files we can't read
+			continue // Skip files we can't read
 		}
-		defer file.Close()
-
 		var ttl int64
-		if _, err := fmt.Fscanf(file, "TTL:%d\n", &ttl); err != nil {
-			// Remove files without TTL header
-			os.Remove(path)
-			return nil
+		_, err = fmt.Fscanf(file, "TTL:%d\n", &ttl)
+		file.Close()
+		if err != nil {
+			continue // Not a cache file
 		}
+		visit(path, info, time.Unix(ttl, 0))
+	}
+	return nil
+}
 
-		if time.Now().Unix() > ttl {
-			os.Remove(path)
-		}
+// Stats returns the snapshot of the cache counters. The size is the total size of the files.
+func (l *LocalCache) Stats() CacheStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.stats
+}
 
-		return nil
+// Close stops the background cleanup
+func (l *LocalCache) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+		l.wg.Wait()
 	})
+	return nil
+}
+
+// Cleanup removes expired cache entries. If the total size exceeds MaxSize, it also removes
+// the least recently used files until the size fits.
+func (l *LocalCache) Cleanup() error {
+	l.cleaning.Lock()
+	defer l.cleaning.Unlock()
+	start := time.Now()
+	type scannedFile struct {
+		localCacheFile
+		name, path string
+	}
+	var files []scannedFile
+	var expirations int64
+	l.mutex.Lock()
+	known := make(map[string]localCacheFile, len(l.files))
+	for name, file := range l.files {
+		known[name] = file
+	}
+	l.mutex.Unlock()
+	err := l.walk(func(path string, info os.FileInfo, expiry time.Time) {
+		if time.Now().Unix() > expiry.Unix() {
+			if os.Remove(path) == nil {
+				expirations++
+			}
+			return
+		}
+		scanned := scannedFile{name: info.Name(), path: path,
+			localCacheFile: localCacheFile{size: info.Size(), accessed: info.ModTime()}}
+		if file, exists := known[info.Name()]; exists {
+			scanned.accessed = file.accessed
+		}
+		files = append(files, scanned)
+	})
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, file := range files {
+		size += file.size
+	}
+	var evictions int64
+	if l.stats.MaxSize > 0 && size > l.stats.MaxSize {
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].accessed.Before(files[j].accessed)
+		})
+		for len(files) > 0 && size > l.stats.MaxSize {
+			if err := os.Remove(files[0].path); err == nil || os.IsNotExist(err) {
+				size -= files[0].size
+				evictions++
+			}
+			files = files[1:]
+		}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	scanned := make(map[string]localCacheFile, len(files))
+	for _, file := range files {
+		scanned[file.name] = file.localCacheFile
+	}
+	// keep the files written during the scan
+	for name, file := range l.files {
+		if _, exists := scanned[name]; exists || !file.accessed.After(start) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(l.basePath, name)); err == nil {
+			scanned[name] = file
+		}
+	}
+	l.files = scanned
+	l.stats.Size = 0
+	for _, file := range scanned {
+		l.stats.Size += file.size
+	}
+	l.stats.Entries = int64(len(scanned))
+	l.stats.Expirations += expirations
+	l.stats.Evictions += evictions
+	return nil
 }
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalCacheGetSkipsHeader(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewLocalCache(CacheConfig{LocalPath: t.TempDir()})
+	require.NoError(t, err)
+	defer cache.Close()
+	require.NoError(t, cache.Set(ctx, "key", []byte("value"), time.Hour))
+	value, err := cache.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+	_, err = cache.Get(ctx, "missing")
+	assert.Error(t, err)
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, int64(1), stats.Entries)
+	assert.Equal(t, int64(len("value")+len("TTL:1234567890\n")), stats.Size)
+}
+
+func TestLocalCacheMaxSize(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// the files which are not cache values must survive
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "jobs.db"), make([]byte, 1000), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "hercules-clone"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "hercules-clone", "file"), []byte("x"), 0644))
+
+	// each file takes 15 bytes of the header and 100 bytes of data
+	cache, err := NewLocalCache(CacheConfig{LocalPath: dir, MaxSize: 300})
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, "a", make([]byte, 100), time.Hour))
+	require.NoError(t, cache.Set(ctx, "b", make([]byte, 100), time.Hour))
+	_, err = cache.Get(ctx, "a")
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(ctx, "c", make([]byte, 100), time.Hour))
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Evictions == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, cache.Close())
+
+	for key, expected := range map[string]bool{"a": true, "b": false, "c": true} {
+		exists, err := cache.Exists(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, expected, exists, key)
+	}
+	stats := cache.Stats()
+	assert.Equal(t, int64(2), stats.Entries)
+	assert.Equal(t, int64(230), stats.Size)
+	assert.FileExists(t, filepath.Join(dir, "jobs.db"))
+	assert.FileExists(t, filepath.Join(dir, "hercules-clone", "file"))
+	entries, err := cache.List(ctx, "")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// the size is restored from the directory
+	cache, err = NewLocalCache(CacheConfig{LocalPath: dir, MaxSize: 100})
+	require.NoError(t, err)
+	require.NoError(t, cache.Close())
+	stats = cache.Stats()
+	assert.Equal(t, int64(0), stats.Entries)
+	assert.Equal(t, int64(2), stats.Evictions)
+	assert.FileExists(t, filepath.Join(dir, "jobs.db"))
+}
+
+func TestLocalCacheCleanupInterval(t *testing.T) {
+	ctx := context.Background()
+	cache, err := NewLocalCache(CacheConfig{LocalPath: t.TempDir(), CleanupInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer cache.Close()
+	require.NoError(t, cache.Set(ctx, "expired", []byte("x"), -time.Hour))
+	require.NoError(t, cache.Set(ctx, "fresh", []byte("x"), time.Hour))
+	assert.Eventually(t, func() bool {
+		return cache.Stats().Expirations == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), cache.Stats().Entries)
+	exists, err := cache.Exists(ctx, "fresh")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	require.NoError(t, cache.Close())
+	require.NoError(t, cache.Close())
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+// parallelWindow is the maximum number of scheduled but unfinished commits per worker
+// in the parallel mode of Pipeline.Run().
+const parallelWindow = 16
+
+// parallelRun executes the commit actions of the plan on a pool of workers (Pipeline.Workers).
+//
+// Each item in each branch is a resource which consumes the commits strictly in the order
+// of the plan. The resource is the item instance in the branch if it is a ParallelPipelineItem
+// and the item's position in the pipeline otherwise, so that the forks of the regular items
+// never run at the same time. Thus the commits in different branches are processed
+// concurrently while every item sees exactly the same sequence of calls as in the sequential
+// mode. The items of the same commit run one after another because they depend on each other,
+// but different commits in the same branch overlap as in a conveyor.
+//
+// The non-commit actions are executed by the planning goroutine after the involved resources
+// become idle.
+type parallelRun struct {
+	pipeline *Pipeline
+	ctx      context.Context
+	// workers limits the number of concurrent Consume() calls
+	workers chan struct{}
+	// window limits the number of scheduled commits
+	window chan struct{}
+	// tails map the resources to the completion of their last scheduled Consume()
+	tails map[interface{}]chan struct{}
+	wg    sync.WaitGroup
+
+	mutex          sync.Mutex
+	err            error
+	runTimePerItem map[string]float64
+}
+
+func newParallelRun(ctx context.Context, pipeline *Pipeline) *parallelRun {
+	return &parallelRun{
+		pipeline:       pipeline,
+		ctx:            ctx,
+		workers:        make(chan struct{}, pipeline.Workers),
+		window:         make(chan struct{}, pipeline.Workers*parallelWindow),
+		tails:          map[interface{}]chan struct{}{},
+		runTimePerItem: map[string]float64{},
+	}
+}
+
+// parallelBranchItem identifies a ParallelPipelineItem in a branch.
+type parallelBranchItem struct {
+	item   PipelineItem
+	branch int
+}
+
+// parallelResource returns the key which serializes the calls to the item at `position`
+// in `branch`.
+func parallelResource(item PipelineItem, branch, position int) interface{} {
+	if pi, ok := item.(ParallelPipelineItem); ok && pi.ParallelForks() {
+		return parallelBranchItem{item: item, branch: branch}
+	}
+	return position
+}
+
+// Err returns the first error returned by Consume() or the context's error.
+func (run *parallelRun) Err() error {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if run.err != nil {
+		return run.err
+	}
+	return run.ctx.Err()
+}
+
+func (run *parallelRun) fail(err error) {
+	run.mutex.Lock()
+	defer run.mutex.Unlock()
+	if run.err == nil {
+		run.err = err
+	}
+}
+
+// consume schedules the commit in `state` on the `items` of `branch`. `commitIndex` and
+// `stepIndex` are only used in the error messages.
+func (run *parallelRun) consume(
+	branch int, items []PipelineItem, state map[string]interface{}, commitIndex, stepIndex int) {
+	run.window <- struct{}{}
+	waits := make([]chan struct{}, len(items))
+	dones := make([]chan struct{}, len(items))
+	for i, item := range items {
+		key := parallelResource(item, branch, i)
+		waits[i] = run.tails[key]
+		dones[i] = make(chan struct{})
+		run.tails[key] = dones[i]
+	}
+	run.wg.Add(1)
+	go func() {
+		finished := 0
+		defer func() {
+			for _, done := range dones[finished:] {
+				close(done)
+			}
+			<-run.window
+			run.wg.Done()
+		}()
+		commit := state[DependencyCommit].(*object.Commit)
+		for i, item := range items {
+			if waits[i] != nil {
+				<-waits[i]
+			}
+			if run.Err() != nil {
+				return
+			}
+			run.workers <- struct{}{}
+			startTime := time.Now()
+			update, err := item.Consume(state)
+			elapsed := time.Since(startTime).Seconds()
+			<-run.workers
+			run.mutex.Lock()
+			run.runTimePerItem[item.Name()] += elapsed
+			run.mutex.Unlock()
+			if err != nil {
+				run.pipeline.l.Errorf("%s failed on commit #%d (%d) %s: %v\n",
+					item.Name(), commitIndex+1, stepIndex+1, commit.Hash.String(), err)
+				run.fail(err)
+				return
+			}
+			for _, key := range item.Provides() {
+				val, ok := update[key]
+				if !ok {
+					err := fmt.Errorf("%s: Consume() did not return %s", item.Name(), key)
+					run.pipeline.l.Critical(err)
+					run.fail(err)
+					return
+				}
+				state[key] = val
+			}
+			close(dones[i])
+			finished++
+		}
+	}()
+}
+
+// await blocks until all the scheduled Consume() calls on the branches finish.
+func (run *parallelRun) await(branchItems map[int][]PipelineItem, branches ...int) {
+	for _, branch := range branches {
+		for i, item := range branchItems[branch] {
+			if tail := run.tails[parallelResource(item, branch, i)]; tail != nil {
+				<-tail
+			}
+		}
+	}
+}
+
+// finish waits for all the scheduled commits and adds the time spent in the items to
+// `runTimePerItem`.
+func (run *parallelRun) finish(runTimePerItem map[string]float64) error {
+	run.wg.Wait()
+	for key, val := range run.runTimePerItem {
+		runTimePerItem[key] += val
+	}
+	return run.Err()
+}
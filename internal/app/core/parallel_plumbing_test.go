@@ -0,0 +1,168 @@
+package core_test
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plumbingTestItem records the tree changes, the diffs and the line stats of every commit.
+// It is not a ParallelPipelineItem so the calls are serialized.
+type plumbingTestItem struct {
+	core.NoopMerger
+	Changes map[string]map[string]bool
+}
+
+func (item *plumbingTestItem) Name() string {
+	return "PlumbingTest"
+}
+
+func (item *plumbingTestItem) Provides() []string {
+	return []string{}
+}
+
+func (item *plumbingTestItem) Requires() []string {
+	return []string{items.DependencyTreeChanges, items.DependencyFileDiff, items.DependencyLineStats}
+}
+
+func (item *plumbingTestItem) ListConfigurationOptions() []core.ConfigurationOption {
+	return nil
+}
+
+func (item *plumbingTestItem) Configure(facts map[string]interface{}) error {
+	return nil
+}
+
+func (item *plumbingTestItem) Initialize(repository *git.Repository) error {
+	item.Changes = map[string]map[string]bool{}
+	return nil
+}
+
+func (item *plumbingTestItem) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
+	commit := deps[core.DependencyCommit].(*object.Commit).Hash.String()
+	diffs := deps[items.DependencyFileDiff].(map[string]items.FileDiffData)
+	stats := deps[items.DependencyLineStats].(map[object.ChangeEntry]items.LineStats)
+	var records []string
+	for _, change := range deps[items.DependencyTreeChanges].(object.Changes) {
+		record := fmt.Sprintf("%s>%s", change.From.Name, change.To.Name)
+		if diff, exists := diffs[change.To.Name]; exists {
+			record += fmt.Sprintf(" %d:%d/%d", diff.OldLinesOfCode, diff.NewLinesOfCode, len(diff.Diffs))
+		}
+		stat := stats[change.To]
+		record += fmt.Sprintf(" +%d-%d~%d", stat.Added, stat.Removed, stat.Changed)
+		records = append(records, record)
+	}
+	sort.Strings(records)
+	if item.Changes[commit] == nil {
+		item.Changes[commit] = map[string]bool{}
+	}
+	item.Changes[commit][strings.Join(records, ",")] = true
+	return nil, nil
+}
+
+func (item *plumbingTestItem) Fork(n int) []core.PipelineItem {
+	return core.ForkSamePipelineItem(item, n)
+}
+
+func (item *plumbingTestItem) Flag() string {
+	return "plumbing-test"
+}
+
+func (item *plumbingTestItem) Description() string {
+	return "Records the tree changes, the diffs and the line stats."
+}
+
+func (item *plumbingTestItem) Serialize(result interface{}, binary bool, writer io.Writer) error {
+	return nil
+}
+
+func (item *plumbingTestItem) Finalize() interface{} {
+	return item.Changes
+}
+
+// plumbingTestFile generates the contents which differ in one line, so that the renamed
+// files stay similar.
+func plumbingTestFile(name string, version int) string {
+	var lines []string
+	for i := 0; i < 20; i++ {
+		lines = append(lines, fmt.Sprintf("%s line %d", name, i))
+	}
+	lines[version%len(lines)] = fmt.Sprintf("%s version %d", name, version)
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// newPlumbingTestRepository creates an on-disk repository where three branches edit and
+// rename their files and are merged back several times.
+func newPlumbingTestRepository(t *testing.T) (*git.Repository, []*object.Commit) {
+	commits := []test.Commit{{Files: map[string]string{"root": plumbingTestFile("root", 0)}}}
+	for round := 0; round < 3; round++ {
+		base := len(commits) - 1
+		var heads []int
+		for branch := 0; branch < 3; branch++ {
+			parent := base
+			name := fmt.Sprintf("%d_%d", round, branch)
+			for i := 0; i < 6; i++ {
+				files := map[string]string{}
+				if i == 3 {
+					files[name] = ""
+					name += "_renamed"
+				}
+				files[name] = plumbingTestFile(fmt.Sprintf("%d_%d", round, branch), i)
+				commits = append(commits, test.Commit{Files: files, Parents: []int{parent}})
+				parent = len(commits) - 1
+			}
+			heads = append(heads, parent)
+		}
+		commits = append(commits, test.Commit{Parents: heads})
+	}
+	return test.NewFilesystemRepository(t.TempDir(), commits...)
+}
+
+func runPlumbingTestPipeline(t *testing.T, workers int) map[string]map[string]bool {
+	repository, commits := newPlumbingTestRepository(t)
+	pipeline := core.NewPipeline(repository)
+	item := &plumbingTestItem{}
+	pipeline.DeployItem(item)
+	require.NoError(t, pipeline.Initialize(map[string]interface{}{
+		core.ConfigPipelineCommits:                    commits,
+		core.ConfigPipelineWorkers:                    workers,
+		items.ConfigRenameAnalysisSimilarityThreshold: items.RenameAnalysisDefaultThreshold,
+	}))
+	result, err := pipeline.Run(commits)
+	require.NoError(t, err)
+	return result[item].(map[string]map[string]bool)
+}
+
+// TestPipelineRunParallelPlumbing runs the real plumbing forks concurrently over the shared
+// on-disk repository; run it with -race.
+func TestPipelineRunParallelPlumbing(t *testing.T) {
+	changes1 := runPlumbingTestPipeline(t, 1)
+	changes4 := runPlumbingTestPipeline(t, 4)
+	assert.Len(t, changes4, 58)
+	assert.Equal(t, changes1, changes4)
+	renames := 0
+	for _, records := range changes4 {
+		for record := range records {
+			for _, change := range strings.Split(record, ",") {
+				if change == "" {
+					continue
+				}
+				names := strings.Split(strings.Fields(change)[0], ">")
+				if names[0] != "" && names[1] == names[0]+"_renamed" {
+					renames++
+				}
+			}
+		}
+	}
+	assert.Equal(t, 9, renames)
+}
@@ -0,0 +1,284 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// parallelTestItem collects the consumed commits in each branch and joins them on merges,
+// so that every merged branch contains the union.
+type parallelTestItem struct {
+	Hashes map[string]bool
+	// Active is shared by the forks and counts the concurrent Consume() calls.
+	Active *int32
+	// MaxActive is shared by the forks and holds the maximum of Active.
+	MaxActive *int32
+}
+
+func (item *parallelTestItem) Name() string {
+	return "ParallelTest"
+}
+
+func (item *parallelTestItem) Provides() []string {
+	return []string{"parallel_test"}
+}
+
+func (item *parallelTestItem) Requires() []string {
+	return []string{}
+}
+
+func (item *parallelTestItem) ListConfigurationOptions() []ConfigurationOption {
+	return nil
+}
+
+func (item *parallelTestItem) Configure(facts map[string]interface{}) error {
+	return nil
+}
+
+func (item *parallelTestItem) Initialize(repository *git.Repository) error {
+	item.Hashes = map[string]bool{}
+	item.Active = new(int32)
+	item.MaxActive = new(int32)
+	return nil
+}
+
+func (item *parallelTestItem) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
+	active := atomic.AddInt32(item.Active, 1)
+	defer atomic.AddInt32(item.Active, -1)
+	for {
+		max := atomic.LoadInt32(item.MaxActive)
+		if active <= max || atomic.CompareAndSwapInt32(item.MaxActive, max, active) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	hash := deps[DependencyCommit].(*object.Commit).Hash.String()
+	item.Hashes[hash] = true
+	return map[string]interface{}{"parallel_test": hash}, nil
+}
+
+func (item *parallelTestItem) Fork(n int) []PipelineItem {
+	clones := make([]PipelineItem, n)
+	for i := range clones {
+		hashes := map[string]bool{}
+		for key := range item.Hashes {
+			hashes[key] = true
+		}
+		clones[i] = &parallelTestItem{Hashes: hashes, Active: item.Active, MaxActive: item.MaxActive}
+	}
+	return clones
+}
+
+func (item *parallelTestItem) Merge(branches []PipelineItem) {
+	for _, branch := range branches {
+		for key := range branch.(*parallelTestItem).Hashes {
+			item.Hashes[key] = true
+		}
+	}
+	for _, branch := range branches {
+		branch.(*parallelTestItem).Hashes = item.Hashes
+	}
+}
+
+func (item *parallelTestItem) ParallelForks() bool {
+	return true
+}
+
+func (item *parallelTestItem) Flag() string {
+	return "parallel-test"
+}
+
+func (item *parallelTestItem) Description() string {
+	return "Collects the consumed commits."
+}
+
+func (item *parallelTestItem) Serialize(result interface{}, binary bool, writer io.Writer) error {
+	return nil
+}
+
+func (item *parallelTestItem) Finalize() interface{} {
+	hashes := make([]string, 0, len(item.Hashes))
+	for key := range item.Hashes {
+		hashes = append(hashes, key)
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+// sequenceTestItem records the order of all Consume() calls in all the branches.
+// It is not a ParallelPipelineItem so the calls must be serialized.
+type sequenceTestItem struct {
+	NoopMerger
+	Sequence []*object.Commit
+	Matches  bool
+	Fail     int
+}
+
+func (item *sequenceTestItem) Name() string {
+	return "SequenceTest"
+}
+
+func (item *sequenceTestItem) Provides() []string {
+	return []string{}
+}
+
+func (item *sequenceTestItem) Requires() []string {
+	return []string{"parallel_test"}
+}
+
+func (item *sequenceTestItem) ListConfigurationOptions() []ConfigurationOption {
+	return nil
+}
+
+func (item *sequenceTestItem) Configure(facts map[string]interface{}) error {
+	return nil
+}
+
+func (item *sequenceTestItem) Initialize(repository *git.Repository) error {
+	item.Sequence = nil
+	item.Matches = true
+	return nil
+}
+
+func (item *sequenceTestItem) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
+	commit := deps[DependencyCommit].(*object.Commit)
+	if len(item.Sequence)+1 == item.Fail {
+		return nil, errors.New("sequence test failure")
+	}
+	item.Matches = item.Matches && deps["parallel_test"].(string) == commit.Hash.String()
+	item.Sequence = append(item.Sequence, commit)
+	return nil, nil
+}
+
+func (item *sequenceTestItem) Fork(n int) []PipelineItem {
+	return ForkSamePipelineItem(item, n)
+}
+
+func (item *sequenceTestItem) Flag() string {
+	return "sequence-test"
+}
+
+func (item *sequenceTestItem) Description() string {
+	return "Records the order of the consumed commits."
+}
+
+func (item *sequenceTestItem) Serialize(result interface{}, binary bool, writer io.Writer) error {
+	return nil
+}
+
+// Finalize returns the sorted hashes of the consumed commits. Merge commits are consumed
+// in every merged branch so they repeat.
+func (item *sequenceTestItem) Finalize() interface{} {
+	hashes := make([]string, len(item.Sequence))
+	for i, commit := range item.Sequence {
+		hashes[i] = commit.Hash.String()
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+// Topological checks that every commit was consumed after its parents.
+func (item *sequenceTestItem) Topological() bool {
+	seen := map[string]bool{}
+	for _, commit := range item.Sequence {
+		for _, parent := range commit.ParentHashes {
+			if !seen[parent.String()] {
+				return false
+			}
+		}
+		seen[commit.Hash.String()] = true
+	}
+	return true
+}
+
+// newParallelTestRepository creates a repository where three branches are forked
+// and merged back several times.
+func newParallelTestRepository() (*git.Repository, []*object.Commit) {
+	commits := []test.Commit{{Files: map[string]string{"root": "root"}}}
+	for round := 0; round < 3; round++ {
+		base := len(commits) - 1
+		var heads []int
+		for branch := 0; branch < 3; branch++ {
+			parent := base
+			for i := 0; i < 6; i++ {
+				commits = append(commits, test.Commit{
+					Files:   map[string]string{fmt.Sprintf("%d_%d", round, branch): fmt.Sprint(i)},
+					Parents: []int{parent},
+				})
+				parent = len(commits) - 1
+			}
+			heads = append(heads, parent)
+		}
+		commits = append(commits, test.Commit{Parents: heads})
+	}
+	return test.NewRepository(commits...)
+}
+
+func runParallelTestPipeline(t *testing.T, ctx context.Context, workers, fail int) (
+	*parallelTestItem, *sequenceTestItem, map[LeafPipelineItem]interface{}, error) {
+	repository, commits := newParallelTestRepository()
+	pipeline := NewPipeline(repository)
+	parallel := &parallelTestItem{}
+	sequence := &sequenceTestItem{Fail: fail}
+	pipeline.AddItem(parallel)
+	pipeline.AddItem(sequence)
+	require.NoError(t, pipeline.Initialize(map[string]interface{}{
+		ConfigPipelineCommits: commits,
+		ConfigPipelineWorkers: workers,
+	}))
+	result, err := pipeline.RunContext(ctx, commits)
+	return parallel, sequence, result, err
+}
+
+func TestPipelineRunParallel(t *testing.T) {
+	parallel1, sequence1, result1, err := runParallelTestPipeline(t, context.Background(), 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), *parallel1.MaxActive)
+	parallel4, sequence4, result4, err := runParallelTestPipeline(t, context.Background(), 4, 0)
+	require.NoError(t, err)
+	assert.True(t, sequence4.Matches)
+	assert.True(t, sequence4.Topological())
+	assert.Len(t, result4[parallel4], 58)
+	assert.Equal(t, result1[parallel1], result4[parallel4])
+	assert.Equal(t, result1[sequence1], result4[sequence4])
+	assert.Equal(t, result1[nil].(*CommonAnalysisResult).CommitsNumber,
+		result4[nil].(*CommonAnalysisResult).CommitsNumber)
+	assert.Equal(t, result1[nil].(*CommonAnalysisResult).EndTime,
+		result4[nil].(*CommonAnalysisResult).EndTime)
+	assert.Greater(t, *parallel4.MaxActive, int32(1))
+	assert.LessOrEqual(t, *parallel4.MaxActive, int32(4))
+}
+
+func TestPipelineRunParallelError(t *testing.T) {
+	_, _, result, err := runParallelTestPipeline(t, context.Background(), 4, 20)
+	assert.EqualError(t, err, "sequence test failure")
+	assert.Nil(t, result)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, sequence, result, err := runParallelTestPipeline(t, ctx, 4, 0)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, result)
+	assert.Len(t, sequence.Sequence, 0)
+}
+
+func TestPipelineWorkersNegative(t *testing.T) {
+	pipeline := NewPipeline(test.Repository)
+	pipeline.AddItem(&parallelTestItem{})
+	err := pipeline.Initialize(map[string]interface{}{
+		ConfigPipelineCommits: []*object.Commit{},
+		ConfigPipelineWorkers: -1,
+	})
+	assert.Error(t, err)
+}
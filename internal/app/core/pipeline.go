@@ -2,11 +2,13 @@ package core
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime/debug"
 	"sort"
 	"strings"
@@ -172,6 +174,17 @@ type HibernateablePipelineItem interface {
 	Boot() error
 }
 
+// ParallelPipelineItem is the interface for pipeline items whose forks may consume commits
+// concurrently, that is, the clones returned by Fork() do not share mutable state.
+// If Fork() returns the same instance, Consume() must be safe to call from several goroutines.
+// The forks share the *git.Repository which must only be read.
+// See Pipeline.Workers.
+type ParallelPipelineItem interface {
+	PipelineItem
+	// ParallelForks returns true if the forks of the item are independent from each other.
+	ParallelForks() bool
+}
+
 // CommonAnalysisResult holds the information which is always extracted at Pipeline.Run().
 type CommonAnalysisResult struct {
 	// BeginTime is the time of the first commit in the analysed sequence.
@@ -271,6 +284,18 @@ type Pipeline struct {
 	// PrintActions indicates whether to print the taken actions during the execution.
 	PrintActions bool
 
+	// Workers is the number of goroutines which execute the commits of independent branches
+	// concurrently. Only the ParallelPipelineItem-s, that is, the plumbing, run in parallel;
+	// every other item, including all the leaves, stays serialized by its position in the
+	// pipeline and consumes the commits in the order of the plan. Values less than 2 disable the parallel mode.
+	Workers int
+
+	// CheckpointPath is the file where to save the Checkpoint after Run(). Empty disables.
+	CheckpointPath string
+
+	// resumed is the Checkpoint from which Run() continues the analysis.
+	resumed *Checkpoint
+
 	// Repository points to the analysed Git repository struct from go-git.
 	repository *git.Repository
 
@@ -310,6 +335,17 @@ const (
 	// ConfigPipelinePrintActions is the name of the Pipeline configuration option (Pipeline.Initialize())
 	// which enables printing the taken actions of the execution plan to stderr.
 	ConfigPipelinePrintActions = "Pipeline.PrintActions"
+	// ConfigPipelineWorkers is the name of the Pipeline configuration option (Pipeline.Initialize())
+	// which sets the number of goroutines to process independent branches concurrently.
+	ConfigPipelineWorkers = "Pipeline.Workers"
+	// ConfigPipelineCheckpointPath is the name of the Pipeline configuration option (Pipeline.Initialize())
+	// which sets the file where to save the Checkpoint after Run().
+	ConfigPipelineCheckpointPath = "Pipeline.CheckpointPath"
+	// ConfigPipelineResume is the name of the Pipeline configuration option (Pipeline.Initialize())
+	// which continues the analysis from the specified *Checkpoint. If ConfigPipelineCommits
+	// is not set, the commits are filtered with Checkpoint.FilterCommits(). The items can read it
+	// in Configure() to restore the state which affects the facts, e.g. the identities.
+	ConfigPipelineResume = "Pipeline.Resume"
 	// DependencyCommit is the name of one of the three items in `deps` supplied to PipelineItem.Consume()
 	// which always exists. It corresponds to the currently analyzed commit.
 	DependencyCommit = "commit"
@@ -320,6 +356,11 @@ const (
 	// which always exists. It indicates whether the analyzed commit is a merge commit.
 	// Checking the number of parents is not correct - we remove the back edges during the DAG simplification.
 	DependencyIsMerge = "is_merge"
+	// DependencyContext is the name of the item in `deps` supplied to PipelineItem.Consume()
+	// which holds the context.Context of Pipeline.RunContext(). Items which perform long
+	// operations should abort them when the context is done. Pipeline.Run() supplies
+	// context.Background().
+	DependencyContext = "context"
 	// MessageFinalize is the status text reported before calling LeafPipelineItem.Finalize()-s.
 	MessageFinalize = "finalize"
 )
@@ -377,7 +418,25 @@ func (pipeline *Pipeline) SetFeaturesFromFlags(registry ...*PipelineItemRegistry
 
 // DeployItem inserts a PipelineItem into the pipeline. It also recursively creates all of it's
 // dependencies (PipelineItem.Requires()). Returns the same item as specified in the arguments.
+// The dependencies are the prototypes from Registry, so they are shared with every other
+// pipeline which deploys them. See also: DeployItemInstances().
 func (pipeline *Pipeline) DeployItem(item PipelineItem) PipelineItem {
+	return pipeline.deployItem(item, func(prototype PipelineItem) PipelineItem {
+		return prototype
+	})
+}
+
+// DeployItemInstances works like DeployItem() but creates a new instance of every dependency
+// summoned from Registry instead of reusing the prototype. Several pipelines can run in
+// the same process at the same time only if they are deployed this way.
+func (pipeline *Pipeline) DeployItemInstances(item PipelineItem) PipelineItem {
+	return pipeline.deployItem(item, func(prototype PipelineItem) PipelineItem {
+		return reflect.New(reflect.TypeOf(prototype).Elem()).Interface().(PipelineItem)
+	})
+}
+
+func (pipeline *Pipeline) deployItem(
+	item PipelineItem, instantiate func(PipelineItem) PipelineItem) PipelineItem {
 	fpi, ok := item.(FeaturedPipelineItem)
 	if ok {
 		for _, f := range fpi.Features() {
@@ -411,6 +470,7 @@ func (pipeline *Pipeline) DeployItem(item PipelineItem) PipelineItem {
 					if disabled {
 						continue
 					}
+					sibling = instantiate(sibling)
 					added[sibling.Name()] = sibling
 					queue = append(queue, sibling)
 					pipeline.AddItem(sibling)
@@ -719,15 +779,28 @@ func (pipeline *Pipeline) Initialize(facts map[string]interface{}) error {
 		facts[ConfigLogger] = pipeline.l
 	}
 
+	pipeline.resumed, _ = facts[ConfigPipelineResume].(*Checkpoint)
+	pipeline.CheckpointPath, _ = facts[ConfigPipelineCheckpointPath].(string)
 	if _, exists := facts[ConfigPipelineCommits]; !exists {
-		var err error
-		facts[ConfigPipelineCommits], err = pipeline.Commits(false)
+		commits, err := pipeline.Commits(false)
+		if err == nil && pipeline.resumed != nil {
+			commits, err = pipeline.resumed.FilterCommits(commits)
+		}
 		if err != nil {
 			pipeline.l.Errorf("failed to list the commits: %v", err)
 			return err
 		}
+		facts[ConfigPipelineCommits] = commits
 	}
 	pipeline.PrintActions, _ = facts[ConfigPipelinePrintActions].(bool)
+	if val, exists := facts[ConfigPipelineWorkers].(int); exists {
+		if val < 0 {
+			err := fmt.Errorf("--workers cannot be negative (got %d)", val)
+			pipeline.l.Error(err)
+			return err
+		}
+		pipeline.Workers = val
+	}
 	if val, exists := facts[ConfigPipelineHibernationDistance].(int); exists {
 		if val < 0 {
 			err := fmt.Errorf("--hibernation-distance cannot be negative (got %d)", val)
@@ -746,6 +819,18 @@ func (pipeline *Pipeline) Initialize(facts map[string]interface{}) error {
 		pipeline.l.Critical(err.Error())
 		return err
 	}
+	if pipeline.CheckpointPath != "" || pipeline.resumed != nil {
+		names := map[string]bool{}
+		for _, item := range pipeline.items {
+			if err := checkCheckpointable(item); err != nil {
+				return err
+			}
+			if names[item.Name()] {
+				return fmt.Errorf("checkpoints do not support several %s items", item.Name())
+			}
+			names[item.Name()] = true
+		}
+	}
 	if dumpPlan, exists := facts[ConfigPipelineDumpPlan].(bool); exists {
 		pipeline.DumpPlan = dumpPlan
 	}
@@ -770,6 +855,14 @@ func (pipeline *Pipeline) Initialize(facts map[string]interface{}) error {
 			return errors.Wrapf(err, "%s failed to initialize", item.Name())
 		}
 	}
+	if pipeline.resumed != nil {
+		for _, item := range pipeline.items {
+			if err := pipeline.resumed.restore(item); err != nil {
+				cleanReturn = true
+				return err
+			}
+		}
+	}
 	if pipeline.HibernationDistance > 0 {
 		// if we want hibernation, then we want to minimize RSS
 		debug.SetGCPercent(20) // the default is 100
@@ -784,7 +877,19 @@ func (pipeline *Pipeline) Initialize(facts map[string]interface{}) error {
 //
 // Returns the mapping from each LeafPipelineItem to the corresponding analysis result.
 // There is always a "nil" record with CommonAnalysisResult.
+//
+// If the pipeline was resumed from a Checkpoint, `commits` must not include the already
+// analysed commits (see Checkpoint.FilterCommits()) and the results cover the whole history.
+// If CheckpointPath is set, the new Checkpoint is saved before finalizing the leaves.
 func (pipeline *Pipeline) Run(commits []*object.Commit) (map[LeafPipelineItem]interface{}, error) {
+	return pipeline.RunContext(context.Background(), commits)
+}
+
+// RunContext executes the pipeline the same way as Run() but stops between the execution plan
+// steps if the context is cancelled or its deadline is exceeded; the context's error is returned
+// then. The context is also passed to the items in DependencyContext.
+func (pipeline *Pipeline) RunContext(ctx context.Context, commits []*object.Commit) (
+	map[LeafPipelineItem]interface{}, error) {
 	startRunTime := time.Now()
 	cleanReturn := false
 	defer func() {
@@ -809,6 +914,13 @@ func (pipeline *Pipeline) Run(commits []*object.Commit) (map[LeafPipelineItem]in
 	}
 	var newestTime int64
 	runTimePerItem := map[string]float64{}
+	// lastCommits maps the branches to the last commits they consumed
+	lastCommits := map[int]*object.Commit{}
+	commitIndex := 0
+	if pipeline.resumed != nil {
+		newestTime = pipeline.resumed.EndTime
+		commitIndex = pipeline.resumed.CommitsNumber
+	}
 
 	isMerge := func(index int, commit plumbing.Hash) bool {
 		match := false
@@ -842,12 +954,29 @@ func (pipeline *Pipeline) Run(commits []*object.Commit) (map[LeafPipelineItem]in
 		return match
 	}
 
-	commitIndex := 0
+	var parallel *parallelRun
+	if pipeline.Workers > 1 && !pipeline.DryRun {
+		parallel = newParallelRun(ctx, pipeline)
+	}
+
 	for index, step := range plan {
 		onProgress(index+1, progressSteps, step.String())
 		if pipeline.DryRun {
 			continue
 		}
+		if err := ctx.Err(); err != nil {
+			if parallel != nil {
+				_ = parallel.finish(runTimePerItem)
+			}
+			pipeline.l.Warnf("Stopped the pipeline before step %d of %d: %v\n", index+1, len(plan), err)
+			return nil, err
+		}
+		if parallel != nil {
+			if err := parallel.Err(); err != nil {
+				_ = parallel.finish(runTimePerItem)
+				return nil, err
+			}
+		}
 		if pipeline.PrintActions {
 			printAction(step)
 		}
@@ -861,38 +990,51 @@ func (pipeline *Pipeline) Run(commits []*object.Commit) (map[LeafPipelineItem]in
 				DependencyCommit:  step.Commit,
 				DependencyIndex:   commitIndex,
 				DependencyIsMerge: isMerge(index, step.Commit.Hash),
+				DependencyContext: ctx,
 			}
-			for _, item := range branches[firstItem] {
-				startTime := time.Now()
-				update, err := item.Consume(state)
-				runTimePerItem[item.Name()] += time.Since(startTime).Seconds()
-				if err != nil {
-					pipeline.l.Errorf("%s failed on commit #%d (%d) %s: %v\n",
-						item.Name(), commitIndex+1, index+1, step.Commit.Hash.String(), err)
-					return nil, err
-				}
-				for _, key := range item.Provides() {
-					val, ok := update[key]
-					if !ok {
-						err := fmt.Errorf("%s: Consume() did not return %s", item.Name(), key)
-						pipeline.l.Critical(err)
+			if parallel != nil {
+				parallel.consume(firstItem, branches[firstItem], state, commitIndex, index)
+			} else {
+				for _, item := range branches[firstItem] {
+					startTime := time.Now()
+					update, err := item.Consume(state)
+					runTimePerItem[item.Name()] += time.Since(startTime).Seconds()
+					if err != nil {
+						pipeline.l.Errorf("%s failed on commit #%d (%d) %s: %v\n",
+							item.Name(), commitIndex+1, index+1, step.Commit.Hash.String(), err)
 						return nil, err
 					}
-					state[key] = val
+					for _, key := range item.Provides() {
+						val, ok := update[key]
+						if !ok {
+							err := fmt.Errorf("%s: Consume() did not return %s", item.Name(), key)
+							pipeline.l.Critical(err)
+							return nil, err
+						}
+						state[key] = val
+					}
 				}
 			}
 			commitTime := step.Commit.Committer.When.Unix()
 			if commitTime > newestTime {
 				newestTime = commitTime
 			}
+			lastCommits[firstItem] = step.Commit
 			commitIndex++
 		case runActionFork:
+			if parallel != nil {
+				parallel.await(branches, firstItem)
+			}
 			startTime := time.Now()
 			for i, clone := range cloneItems(branches[firstItem], len(step.Items)-1) {
 				branches[step.Items[i+1]] = clone
+				lastCommits[step.Items[i+1]] = lastCommits[firstItem]
 			}
 			runTimePerItem["*.Fork"] += time.Since(startTime).Seconds()
 		case runActionMerge:
+			if parallel != nil {
+				parallel.await(branches, step.Items...)
+			}
 			startTime := time.Now()
 			merged := make([][]PipelineItem, len(step.Items))
 			for i, b := range step.Items {
@@ -908,7 +1050,11 @@ func (pipeline *Pipeline) Run(commits []*object.Commit) (map[LeafPipelineItem]in
 			}
 		case runActionDelete:
 			delete(branches, firstItem)
+			delete(lastCommits, firstItem)
 		case runActionHibernate:
+			if parallel != nil {
+				parallel.await(branches, step.Items...)
+			}
 			for _, item := range step.Items {
 				for _, item := range branches[item] {
 					if hi, ok := item.(HibernateablePipelineItem); ok {
@@ -923,6 +1069,9 @@ func (pipeline *Pipeline) Run(commits []*object.Commit) (map[LeafPipelineItem]in
 				}
 			}
 		case runActionBoot:
+			if parallel != nil {
+				parallel.await(branches, step.Items...)
+			}
 			for _, item := range step.Items {
 				for _, item := range branches[item] {
 					if hi, ok := item.(HibernateablePipelineItem); ok {
@@ -938,10 +1087,45 @@ func (pipeline *Pipeline) Run(commits []*object.Commit) (map[LeafPipelineItem]in
 			}
 		}
 	}
+	if parallel != nil {
+		if err := parallel.finish(runTimePerItem); err != nil {
+			if ctx.Err() != nil {
+				pipeline.l.Warnf("Stopped the pipeline: %v\n", err)
+			}
+			return nil, err
+		}
+	}
 	onProgress(len(plan)+1, progressSteps, MessageFinalize)
 	result := map[LeafPipelineItem]interface{}{}
+	masterIndex := getMasterBranchIndex(branches)
+	master := branches[masterIndex]
+	if pipeline.resumed != nil && len(plan) == 0 {
+		// nothing new since the checkpoint
+		master = pipeline.items
+	}
+	commonResult := &CommonAnalysisResult{
+		EndTime:        newestTime,
+		CommitsNumber:  len(commits),
+		RunTimePerItem: runTimePerItem,
+	}
+	if pipeline.resumed != nil {
+		commonResult.BeginTime = pipeline.resumed.BeginTime
+		commonResult.CommitsNumber += pipeline.resumed.CommitsNumber
+	} else {
+		commonResult.BeginTime = plan[0].Commit.Committer.When.Unix()
+	}
+	if !pipeline.DryRun && pipeline.CheckpointPath != "" {
+		head := lastCommits[masterIndex]
+		if head == nil && pipeline.resumed != nil {
+			head = &object.Commit{Hash: pipeline.resumed.Head}
+		}
+		if err := pipeline.saveCheckpoint(master, head.Hash, commonResult); err != nil {
+			pipeline.l.Errorf("Failed to save the checkpoint to %s: %v\n", pipeline.CheckpointPath, err)
+			return nil, err
+		}
+	}
 	if !pipeline.DryRun {
-		for index, item := range getMasterBranch(branches) {
+		for index, item := range master {
 			if casted, ok := item.(DisposablePipelineItem); ok {
 				casted.Dispose()
 			}
@@ -951,17 +1135,32 @@ func (pipeline *Pipeline) Run(commits []*object.Commit) (map[LeafPipelineItem]in
 		}
 	}
 	onProgress(progressSteps, progressSteps, "")
-	result[nil] = &CommonAnalysisResult{
-		BeginTime:      plan[0].Commit.Committer.When.Unix(),
-		EndTime:        newestTime,
-		CommitsNumber:  len(commits),
-		RunTime:        time.Since(startRunTime),
-		RunTimePerItem: runTimePerItem,
-	}
+	commonResult.RunTime = time.Since(startRunTime)
+	result[nil] = commonResult
 	cleanReturn = true
 	return result, nil
 }
 
+// saveCheckpoint writes the state of the `items` which have analysed the history up to `head`
+// to CheckpointPath.
+func (pipeline *Pipeline) saveCheckpoint(
+	items []PipelineItem, head plumbing.Hash, common *CommonAnalysisResult) error {
+	checkpoint := &Checkpoint{
+		Version:       CheckpointVersion,
+		Head:          head,
+		BeginTime:     common.BeginTime,
+		EndTime:       common.EndTime,
+		CommitsNumber: common.CommitsNumber,
+		Items:         map[string][]byte{},
+	}
+	for _, item := range items {
+		if err := checkpoint.save(item); err != nil {
+			return err
+		}
+	}
+	return checkpoint.Write(pipeline.CheckpointPath)
+}
+
 // LoadCommitsFromFile reads the file by the specified FS path and generates the sequence of commits
 // by interpreting each line as a Git commit hash.
 func LoadCommitsFromFile(path string, repository *git.Repository) ([]*object.Commit, error) {
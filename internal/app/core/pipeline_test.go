@@ -2,6 +2,7 @@ package core
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -348,6 +349,27 @@ func TestPipelineRun(t *testing.T) {
 	assert.Equal(t, 1, len(result))
 }
 
+func TestPipelineRunContext(t *testing.T) {
+	repository, commits := test.NewRepository(
+		test.Commit{Files: map[string]string{"a.txt": "a\n"}},
+		test.Commit{Files: map[string]string{"a.txt": "b\n"}})
+	pipeline := NewPipeline(repository)
+	item := &testPipelineItem{}
+	pipeline.AddItem(item)
+	assert.NoError(t, pipeline.Initialize(map[string]interface{}{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	result, err := pipeline.RunContext(ctx, commits)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(result))
+	assert.True(t, item.DepsConsumed)
+	cancel()
+	item.DepsConsumed = false
+	result, err = pipeline.RunContext(ctx, commits)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, result)
+	assert.False(t, item.DepsConsumed)
+}
+
 func TestPipelineRunBranches(t *testing.T) {
 	pipeline := NewPipeline(test.Repository)
 	item := &testPipelineItem{}
@@ -288,6 +288,18 @@ func (registry *PipelineItemRegistry) AddFlags(flagSet *pflag.FlagSet) (
 		ptr5 := (**bool)(unsafe.Pointer(uintptr(unsafe.Pointer(&iface)) + unsafe.Sizeof(&iface)))
 		*ptr5 = flagSet.Bool("print-actions", false, "Print the executed actions to stderr.")
 		flags[ConfigPipelinePrintActions] = iface
+		iface = interface{}("")
+		ptr6 := (**string)(unsafe.Pointer(uintptr(unsafe.Pointer(&iface)) + unsafe.Sizeof(&iface)))
+		*ptr6 = flagSet.String("checkpoint", "", "Save the state of the analyses to this file "+
+			"to continue later with --resume-from.")
+		flags[ConfigPipelineCheckpointPath] = iface
+		PathifyFlagValue(flagSet.Lookup("checkpoint"))
+		iface = interface{}(0)
+		ptr7 := (**int)(unsafe.Pointer(uintptr(unsafe.Pointer(&iface)) + unsafe.Sizeof(&iface)))
+		*ptr7 = flagSet.Int("workers", 1,
+			"Number of goroutines to run the plumbing of independent branches concurrently; "+
+				"the analyses stay serialized. 1 disables.")
+		flags[ConfigPipelineWorkers] = iface
 	}
 	var features []string
 	for f := range registry.featureFlags.Choices {
@@ -0,0 +1,369 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/go-git/go-git/v6/plumbing"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// resultCachePrefix starts the keys of all the values written by ResultCache.
+	resultCachePrefix = "results-"
+	// resultCacheDataSuffix ends the keys of the serialized pb.AnalysisResults.
+	resultCacheDataSuffix = ".pb"
+	// resultCacheEntrySuffix ends the keys of the JSON-encoded ResultCacheEntry-s.
+	resultCacheEntrySuffix = ".json"
+	// resultCacheWriteGrace is how long Prune() waits for the entry of the written data
+	// before it considers the data an incomplete leftover.
+	resultCacheWriteGrace = 10 * time.Minute
+)
+
+// ErrResultCacheMiss is returned by ResultCache.Get() if the results are not cached.
+var ErrResultCacheMiss = errors.New("the results are not cached")
+
+// scpLikeURL matches the scp-like Git URLs such as git@github.com:owner/repo.git
+var scpLikeURL = regexp.MustCompile(`^(?:[A-Za-z]\w*@)?([A-Za-z0-9][\w.-]*):(.+)$`)
+
+// CanonicalRepositoryURL normalizes the repository address so that all the ways to refer
+// to the same repository produce the same string: the scheme, the user info and
+// the ".git" suffix are dropped and the host is lower-cased. Local paths become absolute.
+func CanonicalRepositoryURL(uri string) string {
+	uri = strings.TrimSpace(uri)
+	if !strings.Contains(uri, "://") {
+		if match := scpLikeURL.FindStringSubmatch(uri); match != nil && len(match[1]) > 1 {
+			uri = "ssh://" + match[1] + "/" + strings.TrimPrefix(match[2], "/")
+		}
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Host == "" {
+		path := strings.TrimPrefix(uri, "file://")
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		return filepath.Clean(path)
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if port := parsed.Port(); port != "" && port != "22" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	path := strings.TrimSuffix(strings.TrimRight(parsed.Path, "/"), ".git")
+	return host + path
+}
+
+// ResultCacheKey identifies the results of the analyses in ResultCache.
+// The same key always corresponds to the same results.
+type ResultCacheKey struct {
+	// Repository is the canonical repository URL, see CanonicalRepositoryURL().
+	Repository string `json:"repository"`
+	// Head is the hash of the analysed commit.
+	Head string `json:"head"`
+	// Analyses are the sorted names of the leaves.
+	Analyses []string `json:"analyses"`
+	// Options map the names of the ConfigurationOption-s of all the items to
+	// the effective values.
+	Options map[string]string `json:"options"`
+}
+
+// NewResultCacheKey builds the key of the results of the pipeline `items` configured with
+// `facts` which analyse `head` in the repository at `uri`. The options absent in `facts`
+// take their default values.
+func NewResultCacheKey(
+	uri string, head plumbing.Hash, items []PipelineItem, facts map[string]interface{}) ResultCacheKey {
+	key := ResultCacheKey{
+		Repository: CanonicalRepositoryURL(uri),
+		Head:       head.String(),
+		Analyses:   []string{},
+		Options:    map[string]string{},
+	}
+	for _, item := range items {
+		if _, ok := item.(LeafPipelineItem); ok {
+			key.Analyses = append(key.Analyses, item.Name())
+		}
+		for _, opt := range item.ListConfigurationOptions() {
+			value, exists := facts[opt.Name]
+			if !exists {
+				value = opt.Default
+			}
+			key.Options[opt.Name] = fmt.Sprint(value)
+		}
+	}
+	sort.Strings(key.Analyses)
+	return key
+}
+
+// Digest returns the hex SHA-256 of the key. ResultCache stores the results by digest.
+func (key ResultCacheKey) Digest() string {
+	// encoding/json sorts the map keys so the serialization is canonical
+	data, err := json.Marshal(key)
+	if err != nil {
+		panic(err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// ResultCacheEntry describes the results stored in ResultCache.
+type ResultCacheEntry struct {
+	Key       ResultCacheKey `json:"key"`
+	Digest    string         `json:"digest"`
+	Size      int64          `json:"size"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// ResultCache stores the analysis results (pb.AnalysisResults, the output of `hercules --pb`)
+// in a CacheBackend. Each result takes two values: the serialized message and
+// the JSON-encoded ResultCacheEntry which is written last.
+type ResultCache struct {
+	backend CacheBackend
+	ttl     time.Duration
+	// writeGrace protects the data of the concurrent Put()-s from Prune()
+	writeGrace time.Duration
+}
+
+// NewResultCache creates a ResultCache on top of `backend`. The stored results expire
+// after `ttl`.
+func NewResultCache(backend CacheBackend, ttl time.Duration) *ResultCache {
+	return &ResultCache{backend: backend, ttl: ttl, writeGrace: resultCacheWriteGrace}
+}
+
+func resultCacheDataKey(digest string) string {
+	return resultCachePrefix + digest + resultCacheDataSuffix
+}
+
+func resultCacheEntryKey(digest string) string {
+	return resultCachePrefix + digest + resultCacheEntrySuffix
+}
+
+// Get loads the results by key. It returns ErrResultCacheMiss if they are not cached.
+func (cache *ResultCache) Get(ctx context.Context, key ResultCacheKey) (*pb.AnalysisResults, error) {
+	digest := key.Digest()
+	for _, name := range []string{resultCacheEntryKey(digest), resultCacheDataKey(digest)} {
+		exists, err := cache.backend.Exists(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrResultCacheMiss
+		}
+	}
+	data, err := cache.backend.Get(ctx, resultCacheDataKey(digest))
+	if err != nil {
+		return nil, err
+	}
+	results := &pb.AnalysisResults{}
+	if err := proto.Unmarshal(data, results); err != nil {
+		return nil, fmt.Errorf("failed to decode the cached results %s: %w", digest, err)
+	}
+	return results, nil
+}
+
+// Put stores the results by key.
+func (cache *ResultCache) Put(ctx context.Context, key ResultCacheKey, results *pb.AnalysisResults) error {
+	data, err := proto.Marshal(results)
+	if err != nil {
+		return err
+	}
+	digest := key.Digest()
+	now := time.Now()
+	entry, err := json.Marshal(&ResultCacheEntry{
+		Key:       key,
+		Digest:    digest,
+		Size:      int64(len(data)),
+		CreatedAt: now,
+		ExpiresAt: now.Add(cache.ttl),
+	})
+	if err != nil {
+		return err
+	}
+	if err := cache.backend.Set(ctx, resultCacheDataKey(digest), data, cache.ttl); err != nil {
+		return err
+	}
+	return cache.backend.Set(ctx, resultCacheEntryKey(digest), entry, cache.ttl)
+}
+
+// lister returns the backend's CacheLister.
+func (cache *ResultCache) lister() (CacheLister, error) {
+	lister, ok := cache.backend.(CacheLister)
+	if !ok {
+		return nil, fmt.Errorf("the %T cache backend cannot list the stored values", cache.backend)
+	}
+	return lister, nil
+}
+
+// List returns the cached results from the newest to the oldest. The backends which track
+// the expiration skip the expired results.
+func (cache *ResultCache) List(ctx context.Context) ([]ResultCacheEntry, error) {
+	lister, err := cache.lister()
+	if err != nil {
+		return nil, err
+	}
+	values, err := lister.List(ctx, resultCachePrefix)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ResultCacheEntry
+	for _, value := range values {
+		if !strings.HasSuffix(value.Key, resultCacheEntrySuffix) {
+			continue
+		}
+		data, err := cache.backend.Get(ctx, value.Key)
+		if err != nil {
+			// expired in the meantime
+			continue
+		}
+		var entry ResultCacheEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", value.Key, err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].CreatedAt.After(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+// Remove deletes the results with the specified digest. Like Git hashes, the digest
+// may be abbreviated as long as it stays unique. It returns the full digest.
+func (cache *ResultCache) Remove(ctx context.Context, digest string) (string, error) {
+	lister, err := cache.lister()
+	if err != nil {
+		return "", err
+	}
+	values, err := lister.List(ctx, resultCachePrefix+digest)
+	if err != nil {
+		return "", err
+	}
+	matches := map[string]bool{}
+	for _, value := range values {
+		if full, ok := resultCacheDigest(value.Key); ok {
+			matches[full] = true
+		}
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("no cached results %s", digest)
+	}
+	if len(matches) > 1 {
+		return "", fmt.Errorf("ambiguous digest %s matches %d results", digest, len(matches))
+	}
+	for full := range matches {
+		digest = full
+	}
+	return digest, cache.remove(ctx, digest)
+}
+
+func (cache *ResultCache) remove(ctx context.Context, digest string) error {
+	for _, key := range []string{resultCacheEntryKey(digest), resultCacheDataKey(digest)} {
+		if err := cache.backend.Delete(ctx, key); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// resultCacheDigest extracts the digest from the key of a value written by ResultCache.
+func resultCacheDigest(key string) (string, bool) {
+	if !strings.HasPrefix(key, resultCachePrefix) {
+		return "", false
+	}
+	key = key[len(resultCachePrefix):]
+	for _, suffix := range []string{resultCacheDataSuffix, resultCacheEntrySuffix} {
+		if strings.HasSuffix(key, suffix) {
+			return key[:len(key)-len(suffix)], true
+		}
+	}
+	return "", false
+}
+
+// Prune deletes the expired results, the results created more than `olderThan` ago
+// (0 disables the age check) and the incomplete leftovers of interrupted writes.
+// It returns the digests of the deleted results. If the backend does not report when
+// the values expire, the expiry is read from the entries. The data without the entry
+// is a leftover only if it was stored more than resultCacheWriteGrace ago, because
+// Put() may still be writing the entry.
+func (cache *ResultCache) Prune(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	lister, err := cache.lister()
+	if err != nil {
+		return nil, err
+	}
+	values, err := lister.List(ctx, resultCachePrefix)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	type parts struct {
+		data, entry bool
+		stale       bool
+		// stored is when the data was written; zero if unknown
+		stored time.Time
+		// untimed indicates that the backend does not report the expiry of the entry
+		untimed bool
+	}
+	digests := map[string]*parts{}
+	for _, value := range values {
+		digest, ok := resultCacheDigest(value.Key)
+		if !ok {
+			continue
+		}
+		p := digests[digest]
+		if p == nil {
+			p = &parts{}
+			digests[digest] = p
+		}
+		if strings.HasSuffix(value.Key, resultCacheDataSuffix) {
+			p.data = true
+			p.stored = value.ModTime
+		} else {
+			p.entry = true
+			p.untimed = value.Expires.IsZero()
+		}
+		if !value.Expires.IsZero() && now.After(value.Expires) {
+			p.stale = true
+		}
+	}
+	var removed []string
+	for digest, p := range digests {
+		if p.data && !p.entry && !p.stale &&
+			(p.stored.IsZero() || now.Sub(p.stored) < cache.writeGrace) {
+			// Put() may be writing the entry right now
+			continue
+		}
+		if p.data && p.entry && !p.stale {
+			if olderThan <= 0 && !p.untimed {
+				continue
+			}
+			// the entry has the expiry which the backend did not report
+			data, err := cache.backend.Get(ctx, resultCacheEntryKey(digest))
+			if err != nil {
+				continue
+			}
+			var entry ResultCacheEntry
+			if err := json.Unmarshal(data, &entry); err == nil &&
+				(olderThan <= 0 || now.Sub(entry.CreatedAt) <= olderThan) &&
+				(entry.ExpiresAt.IsZero() || now.Before(entry.ExpiresAt)) {
+				continue
+			}
+		}
+		if err := cache.remove(ctx, digest); err != nil {
+			return removed, err
+		}
+		removed = append(removed, digest)
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
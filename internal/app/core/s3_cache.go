@@ -4,16 +4,19 @@ import (
 	"context"
 	"crypto/md5"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"path"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 // S3Cache implements CacheBackend using AWS S3
@@ -22,6 +25,8 @@ type S3Cache struct {
 	bucket     string
 	prefix     string
 	defaultTTL time.Duration
+
+	hits, misses, failures atomic.Int64
 }
 
 // NewS3Cache creates a new S3 cache backend
@@ -108,8 +113,10 @@ func (s *S3Cache) Get(ctx context.Context, key string) ([]byte, error) {
 		Key:    aws.String(s.makeKey(key)),
 	})
 	if err != nil {
+		s.countFailure(err)
 		return nil, err
 	}
+	s.hits.Add(1)
 	defer result.Body.Close()
 
 	return io.ReadAll(result.Body)
@@ -144,7 +151,7 @@ func (s *S3Cache) Exists(ctx context.Context, key string) (bool, error) {
 		Key:    aws.String(s.makeKey(key)),
 	})
 	if err != nil {
-		if strings.Contains(err.Error(), "NoSuchKey") {
+		if isS3NotFound(err) {
 			return false, nil
 		}
 		return false, err
@@ -159,11 +166,28 @@ func (s *S3Cache) GetReader(ctx context.Context, key string) (io.ReadCloser, err
 		Key:    aws.String(s.makeKey(key)),
 	})
 	if err != nil {
+		s.countFailure(err)
 		return nil, err
 	}
+	s.hits.Add(1)
 	return result.Body, nil
 }
 
+// countFailure records a cache miss if the object does not exist and an error otherwise
+func (s *S3Cache) countFailure(err error) {
+	if isS3NotFound(err) {
+		s.misses.Add(1)
+	} else {
+		s.failures.Add(1)
+	}
+}
+
+// isS3NotFound checks whether the error means that the object does not exist.
+// GetObject fails with NoSuchKey while HeadObject fails with NotFound.
+func isS3NotFound(err error) bool {
+	return errors.As(err, new(*types.NoSuchKey)) || errors.As(err, new(*types.NotFound))
+}
+
 // SetReader stores data from an io.Reader with optional TTL
 func (s *S3Cache) SetReader(ctx context.Context, key string, reader io.Reader, ttl time.Duration) error {
 	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
@@ -177,6 +201,39 @@ func (s *S3Cache) SetReader(ctx context.Context, key string, reader io.Reader, t
 	return err
 }
 
+// List returns the entries whose keys start with the prefix. Their expiry is unknown.
+func (s *S3Cache) List(ctx context.Context, prefix string) ([]CacheEntry, error) {
+	var entries []CacheEntry
+	fullPrefix := s.makeKey(prefix)
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(fullPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			key := aws.ToString(object.Key)
+			// the TTL is only available in the object's metadata, so Expires stays unknown
+			// instead of sending a HeadObject request per object
+			entries = append(entries, CacheEntry{
+				Key:     prefix + strings.TrimPrefix(key, fullPrefix),
+				Size:    aws.ToInt64(object.Size),
+				ModTime: aws.ToTime(object.LastModified),
+			})
+		}
+	}
+	return entries, nil
+}
+
+// Stats returns the hits, misses and errors of Get and GetReader. The size of the bucket is not
+// tracked because it requires listing all the objects.
+func (s *S3Cache) Stats() CacheStats {
+	return CacheStats{Hits: s.hits.Load(), Misses: s.misses.Load(), Errors: s.failures.Load()}
+}
+
 // Close performs any cleanup operations
 func (s *S3Cache) Close() error {
 	// S3 client doesn't need explicit cleanup
@@ -184,6 +241,8 @@ func (s *S3Cache) Close() error {
 }
 
 // GenerateCacheKey creates a deterministic cache key for repository analysis
+//
+// Deprecated: the key ignores the analysed commit and the options. Use ResultCacheKey.
 func GenerateCacheKey(repoURL, branch, commit string, analysisType string) string {
 	// Create a hash of the repository URL and branch for consistent keys
 	hash := md5.Sum([]byte(fmt.Sprintf("%s:%s:%s", repoURL, branch, commit)))
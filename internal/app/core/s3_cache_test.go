@@ -1,8 +1,12 @@
 package core
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 func TestGenerateCacheKey(t *testing.T) {
@@ -49,6 +53,21 @@ func TestGenerateCacheKey(t *testing.T) {
 	}
 }
 
+func TestS3CacheCountFailure(t *testing.T) {
+	cache := &S3Cache{}
+	cache.countFailure(fmt.Errorf("operation error S3: GetObject: %w", &types.NoSuchKey{}))
+	cache.countFailure(fmt.Errorf("operation error S3: HeadObject: %w", &types.NotFound{}))
+	// the message mentions NoSuchKey but the error is about the credentials
+	cache.countFailure(errors.New("InvalidAccessKeyId: NoSuchKey is not the reason"))
+	stats := cache.Stats()
+	if stats.Misses != 2 {
+		t.Errorf("Expected 2 misses, got %d", stats.Misses)
+	}
+	if stats.Errors != 1 {
+		t.Errorf("Expected 1 error, got %d", stats.Errors)
+	}
+}
+
 func TestCacheConfig(t *testing.T) {
 	cfg := CacheConfig{
 		Backend:    "s3",
@@ -104,6 +104,50 @@ func NewFileFromTree(keys []int, vals []int, allocator *rbtree.Allocator, update
 	return file
 }
 
+// NewFileFromIntervals restores the File from the line intervals returned by Intervals().
+// Unlike NewFileFromTree(), it returns an error instead of panicking on invalid input.
+func NewFileFromIntervals(
+	keys []uint32, vals []uint32, allocator *rbtree.Allocator, updaters ...Updater) (*File, error) {
+	if len(keys) != len(vals) {
+		return nil, fmt.Errorf("keys and vals must be of equal length: %d != %d", len(keys), len(vals))
+	}
+	if len(keys) == 0 || keys[0] != 0 || vals[len(vals)-1] != TreeEnd {
+		return nil, fmt.Errorf("the intervals must start with key 0 and end with %d", uint32(TreeEnd))
+	}
+	file := &File{tree: rbtree.NewRBTree(allocator), updaters: updaters}
+	for i, key := range keys {
+		if i > 0 && key <= keys[i-1] {
+			file.Delete()
+			return nil, fmt.Errorf("the keys must increase: [%d]=%d", i, key)
+		}
+		file.tree.Insert(rbtree.Item{Key: key, Value: vals[i]})
+	}
+	return file, nil
+}
+
+// NewFileFromLines creates the File from the values of the individual lines, e.g. the lines
+// copied from other files. The updaters receive each run of lines with the same value as
+// inserted at stamp(value), so that the moved lines keep their original value.
+func NewFileFromLines(
+	lines []int, stamp func(value int) int, allocator *rbtree.Allocator, updaters ...Updater) *File {
+	file := &File{tree: rbtree.NewRBTree(allocator), updaters: updaters}
+	for start := 0; start < len(lines); {
+		value := lines[start]
+		if value < 0 || value >= math.MaxUint32 {
+			panic(fmt.Sprintf("line value is out of allowed range: [%d]=%d", start, value))
+		}
+		end := start + 1
+		for end < len(lines) && lines[end] == value {
+			end++
+		}
+		file.updateTime(stamp(value), value, end-start)
+		file.tree.Insert(rbtree.Item{Key: uint32(start), Value: uint32(value)})
+		start = end
+	}
+	file.tree.Insert(rbtree.Item{Key: uint32(len(lines)), Value: TreeEnd})
+	return file
+}
+
 // CloneShallow copies the file. It performs a shallow copy of the tree: the allocator
 // must be Clone()-d beforehand.
 func (file *File) CloneShallow(allocator *rbtree.Allocator) *File {
@@ -378,6 +422,24 @@ func (file File) ForEach(callback func(line, value int)) {
 	}
 }
 
+// Intervals returns the keys and the raw values of the underlying tree nodes in ascending key order.
+// NewFileFromIntervals() performs the reverse operation.
+func (file File) Intervals() (keys []uint32, vals []uint32) {
+	keys = make([]uint32, 0, file.tree.Len())
+	vals = make([]uint32, 0, file.tree.Len())
+	for iter := file.tree.Min(); !iter.Limit(); iter = iter.Next() {
+		item := iter.Item()
+		keys = append(keys, item.Key)
+		vals = append(vals, item.Value)
+	}
+	return keys, vals
+}
+
+// Lines returns the value of each line in the file.
+func (file *File) Lines() []int {
+	return file.flatten()
+}
+
 // flatten represents the file as a slice of lines, each line's value being the corresponding day.
 func (file *File) flatten() []int {
 	lines := make([]int, 0, file.Len())
@@ -533,6 +533,35 @@ func TestNewFileFromTreeInvalidSize(t *testing.T) {
 	assert.Panics(t, func() { NewFileFromTree(keys[:], vals[:], rbtree.NewAllocator()) })
 }
 
+func TestFileIntervals(t *testing.T) {
+	file, _, _ := fixtureFile()
+	file.Update(1, 20, 30, 0)
+	file.Update(2, 20, 0, 5)
+	keys, vals := file.Intervals()
+	assert.Equal(t, []uint32{0, 20, 45, 125}, keys)
+	assert.Equal(t, []uint32{0, 1, 0, math.MaxUint32}, vals)
+	status := map[int]int64{}
+	restored, err := NewFileFromIntervals(keys, vals, rbtree.NewAllocator(), func(a, b, c int) {
+		updateStatusFile(status, a, b, c)
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, file.Dump(), restored.Dump())
+	restored.Update(3, 0, 0, 10)
+	assert.Equal(t, int64(-10), status[0])
+}
+
+func TestNewFileFromIntervalsInvalid(t *testing.T) {
+	alloc := rbtree.NewAllocator()
+	_, err := NewFileFromIntervals([]uint32{0, 1}, []uint32{0}, alloc)
+	assert.NotNil(t, err)
+	_, err = NewFileFromIntervals([]uint32{1, 2}, []uint32{0, math.MaxUint32}, alloc)
+	assert.NotNil(t, err)
+	_, err = NewFileFromIntervals([]uint32{0, 2}, []uint32{0, 1}, alloc)
+	assert.NotNil(t, err)
+	_, err = NewFileFromIntervals([]uint32{0, 2, 2}, []uint32{0, 1, math.MaxUint32}, alloc)
+	assert.NotNil(t, err)
+}
+
 func TestUpdatePanic(t *testing.T) {
 	keys := [...]int{0}
 	vals := [...]int{math.MaxUint32}
@@ -710,6 +739,26 @@ func TestFileMergeNil(t *testing.T) {
 	})
 }
 
+func TestNewFileFromLines(t *testing.T) {
+	type update struct{ current, previous, delta int }
+	var updates []update
+	file := NewFileFromLines([]int{3, 3, 5, 9, 9, 9, 3}, func(value int) int {
+		return 9
+	}, rbtree.NewAllocator(), func(a, b, c int) {
+		updates = append(updates, update{a, b, c})
+	})
+	assert.Equal(t, "0 3\n2 5\n3 9\n6 3\n7 -1\n", file.Dump())
+	assert.Equal(t, []update{{9, 3, 2}, {9, 5, 1}, {9, 9, 3}, {9, 3, 1}}, updates)
+	assert.Equal(t, []int{3, 3, 5, 9, 9, 9, 3}, file.Lines())
+	file.Validate()
+	file.Update(10, 1, 1, 2)
+	assert.Equal(t, []int{3, 10, 9, 9, 9, 3}, file.Lines())
+
+	file = NewFileFromLines(nil, nil, rbtree.NewAllocator())
+	assert.Equal(t, 0, file.Len())
+	assert.Equal(t, "0 -1\n", file.Dump())
+}
+
 func TestBug6File(t *testing.T) {
 	status := map[int]int64{}
 	keys := []int{0, 113, 153, 154}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,8 +15,10 @@ type Config struct {
 	GRPC       GRPCConfig       `mapstructure:"grpc"`
 	Cache      CacheConfig      `mapstructure:"cache"`
 	Analysis   AnalysisConfig   `mapstructure:"analysis"`
+	Jobs       JobsConfig       `mapstructure:"jobs"`
 	Logging    LoggingConfig    `mapstructure:"logging"`
 	Repository RepositoryConfig `mapstructure:"repository"`
+	Auth       AuthConfig       `mapstructure:"auth"`
 }
 
 // ServerConfig holds server-specific configuration
@@ -52,9 +55,46 @@ type CacheConfig struct {
 	AWSAccessKeyID     string `mapstructure:"aws_access_key_id"`
 	AWSSecretAccessKey string `mapstructure:"aws_secret_access_key"`
 
-	// Legacy settings
+	// CleanupInterval is the period of pruning the expired and excess entries.
 	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
-	MaxSize         string        `mapstructure:"max_size"`
+	// MaxSize limits the total size of the cached values, e.g. "10GB". Empty or "0" means no limit.
+	MaxSize string `mapstructure:"max_size"`
+}
+
+// MaxSizeBytes returns MaxSize in bytes.
+func (c CacheConfig) MaxSizeBytes() (int64, error) {
+	return ParseSize(c.MaxSize)
+}
+
+// sizeUnits are the suffixes accepted by ParseSize() from the longest to the shortest.
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+}
+
+// ParseSize converts a human-readable size such as "10GB" or "512 MB" to bytes.
+// The units are powers of 1024. A number without a unit means bytes and an empty
+// string means 0.
+func ParseSize(size string) (int64, error) {
+	value := strings.ToUpper(strings.TrimSpace(size))
+	if value == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(value, unit.suffix) {
+			multiplier = unit.multiplier
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("invalid size: %q", size)
+	}
+	return int64(number * float64(multiplier)), nil
 }
 
 // AnalysisConfig holds analysis-specific configuration
@@ -66,6 +106,15 @@ type AnalysisConfig struct {
 	Timeout               time.Duration `mapstructure:"timeout"`
 }
 
+// JobsConfig holds analysis job tracking configuration
+type JobsConfig struct {
+	Store            string        `mapstructure:"store"`
+	Path             string        `mapstructure:"path"`
+	Retention        time.Duration `mapstructure:"retention"`
+	EvictionInterval time.Duration `mapstructure:"eviction_interval"`
+	MaxQueued        int           `mapstructure:"max_queued"`
+}
+
 // LoggingConfig holds logging-specific configuration
 type LoggingConfig struct {
 	Level  string `mapstructure:"level"`
@@ -78,6 +127,33 @@ type RepositoryConfig struct {
 	CloneTimeout     time.Duration `mapstructure:"clone_timeout"`
 	MaxFileSize      string        `mapstructure:"max_file_size"`
 	AllowedProtocols []string      `mapstructure:"allowed_protocols"`
+	// AllowedHosts are the host name patterns of the remote repositories, e.g. "github.com"
+	// or "*.example.com". Empty means any host.
+	AllowedHosts []string `mapstructure:"allowed_hosts"`
+}
+
+// AuthConfig holds the API keys accepted by the HTTP and gRPC servers
+type AuthConfig struct {
+	Enabled bool           `mapstructure:"enabled"`
+	Keys    []APIKeyConfig `mapstructure:"keys"`
+}
+
+// APIKeyConfig describes an API key, its scopes and quotas
+type APIKeyConfig struct {
+	// Name identifies the tenant which owns the key.
+	Name string `mapstructure:"name"`
+	// Key is the secret sent by the clients. KeySHA256 is the hex SHA-256 of the secret
+	// and avoids storing it in plain text. Exactly one of them must be set.
+	Key       string `mapstructure:"key"`
+	KeySHA256 string `mapstructure:"key_sha256"`
+	// Scopes are the granted operations: submit, read, cancel, metrics or admin.
+	Scopes []string `mapstructure:"scopes"`
+	// MaxConcurrent limits the queued and running analyses of the key. 0 means no limit.
+	MaxConcurrent int `mapstructure:"max_concurrent"`
+	// RateLimit is the number of analyses the key may submit per minute. 0 means no limit.
+	RateLimit float64 `mapstructure:"rate_limit"`
+	// Burst is the number of analyses which may be submitted at once. Defaults to RateLimit.
+	Burst int `mapstructure:"burst"`
 }
 
 // LoadConfig loads configuration from file and environment variables
@@ -153,6 +229,13 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("analysis.max_concurrent_analyses", 10)
 	v.SetDefault("analysis.timeout", "30m")
 
+	// Jobs defaults
+	v.SetDefault("jobs.store", "memory")
+	v.SetDefault("jobs.path", "/tmp/hercules-cache/jobs.db")
+	v.SetDefault("jobs.retention", "24h")
+	v.SetDefault("jobs.eviction_interval", "1m")
+	v.SetDefault("jobs.max_queued", 100)
+
 	// Logging defaults
 	v.SetDefault("logging.level", "info")
 	v.SetDefault("logging.format", "json")
@@ -162,6 +245,9 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("repository.clone_timeout", "10m")
 	v.SetDefault("repository.max_file_size", "1MB")
 	v.SetDefault("repository.allowed_protocols", []string{"https", "http", "ssh", "git"})
+
+	// Auth defaults
+	v.SetDefault("auth.enabled", false)
 }
 
 // validateConfig validates the configuration
@@ -186,5 +272,51 @@ func validateConfig(config *Config) error {
 		return fmt.Errorf("default sampling must be positive: %d", config.Analysis.DefaultSampling)
 	}
 
+	if _, err := config.Cache.MaxSizeBytes(); err != nil {
+		return fmt.Errorf("invalid cache max size: %w", err)
+	}
+
+	if config.Cache.CleanupInterval < 0 {
+		return fmt.Errorf("cache cleanup interval must not be negative: %v", config.Cache.CleanupInterval)
+	}
+
+	switch config.Jobs.Store {
+	case "", "memory", "bolt":
+	default:
+		return fmt.Errorf("unsupported job store: %s", config.Jobs.Store)
+	}
+
+	if config.Jobs.Store == "bolt" && config.Jobs.Path == "" {
+		return fmt.Errorf("job store path is required for the bolt store")
+	}
+
+	if config.Jobs.MaxQueued < 0 {
+		return fmt.Errorf("max queued jobs must not be negative: %d", config.Jobs.MaxQueued)
+	}
+
+	return validateAuthConfig(&config.Auth)
+}
+
+// validateAuthConfig validates the API keys
+func validateAuthConfig(config *AuthConfig) error {
+	if config.Enabled && len(config.Keys) == 0 {
+		return fmt.Errorf("authentication is enabled but there are no API keys")
+	}
+	names := map[string]bool{}
+	for i, key := range config.Keys {
+		if key.Name == "" {
+			return fmt.Errorf("API key #%d has no name", i+1)
+		}
+		if names[key.Name] {
+			return fmt.Errorf("duplicate API key name: %s", key.Name)
+		}
+		names[key.Name] = true
+		if (key.Key == "") == (key.KeySHA256 == "") {
+			return fmt.Errorf("API key %s must set exactly one of key and key_sha256", key.Name)
+		}
+		if key.MaxConcurrent < 0 || key.RateLimit < 0 || key.Burst < 0 {
+			return fmt.Errorf("quotas of API key %s must not be negative", key.Name)
+		}
+	}
 	return nil
 }
@@ -29,6 +29,14 @@ func TestLoadConfigDefaults(t *testing.T) {
 	if cfg.Analysis.MaxConcurrentAnalyses != 10 {
 		t.Errorf("Expected default max concurrent analyses 10, got %d", cfg.Analysis.MaxConcurrentAnalyses)
 	}
+
+	if cfg.Jobs.Store != "memory" {
+		t.Errorf("Expected default job store memory, got %s", cfg.Jobs.Store)
+	}
+
+	if cfg.Jobs.Retention != 24*time.Hour {
+		t.Errorf("Expected default job retention 24h, got %v", cfg.Jobs.Retention)
+	}
 }
 
 func TestLoadConfigFromFile(t *testing.T) {
@@ -167,6 +175,126 @@ func TestValidateConfig(t *testing.T) {
 	if err := validateConfig(invalidConcurrentConfig); err == nil {
 		t.Error("Invalid max concurrent analyses should return error")
 	}
+
+	// Test bolt job store without a path
+	invalidJobsConfig := *validConfig
+	invalidJobsConfig.Jobs = JobsConfig{Store: "bolt"}
+	if err := validateConfig(&invalidJobsConfig); err == nil {
+		t.Error("Bolt job store without path should return error")
+	}
+
+	// Test unknown job store
+	invalidJobsConfig.Jobs = JobsConfig{Store: "redis"}
+	if err := validateConfig(&invalidJobsConfig); err == nil {
+		t.Error("Unknown job store should return error")
+	}
+
+	// Test invalid cache size
+	invalidCacheConfig := *validConfig
+	invalidCacheConfig.Cache = CacheConfig{MaxSize: "lots"}
+	if err := validateConfig(&invalidCacheConfig); err == nil {
+		t.Error("Invalid cache max size should return error")
+	}
+}
+
+func TestLoadConfigAuth(t *testing.T) {
+	configContent := `
+auth:
+  enabled: true
+  keys:
+    - name: "ci"
+      key: "secret"
+      scopes: ["submit", "read"]
+      max_concurrent: 2
+      rate_limit: 30
+    - name: "ops"
+      key_sha256: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"
+      scopes: ["admin"]
+
+repository:
+  allowed_hosts: ["github.com", "*.example.com"]
+`
+	tmpFile, err := os.CreateTemp("", "test-config-*.yaml")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	if _, err := tmpFile.WriteString(configContent); err != nil {
+		t.Fatalf("Failed to write config content: %v", err)
+	}
+	tmpFile.Close()
+
+	cfg, err := LoadConfig(tmpFile.Name())
+	if err != nil {
+		t.Fatalf("Failed to load config from file: %v", err)
+	}
+	if !cfg.Auth.Enabled || len(cfg.Auth.Keys) != 2 {
+		t.Fatalf("Expected 2 enabled API keys, got %+v", cfg.Auth)
+	}
+	ci := cfg.Auth.Keys[0]
+	if ci.Name != "ci" || ci.Key != "secret" || len(ci.Scopes) != 2 || ci.MaxConcurrent != 2 || ci.RateLimit != 30 {
+		t.Errorf("Unexpected API key %+v", ci)
+	}
+	if cfg.Auth.Keys[1].KeySHA256 == "" {
+		t.Error("Expected key_sha256 to be loaded")
+	}
+	if len(cfg.Repository.AllowedHosts) != 2 || cfg.Repository.AllowedHosts[1] != "*.example.com" {
+		t.Errorf("Unexpected allowed hosts %v", cfg.Repository.AllowedHosts)
+	}
+	if len(cfg.Repository.AllowedProtocols) != 4 {
+		t.Errorf("Expected the default allowed protocols, got %v", cfg.Repository.AllowedProtocols)
+	}
+}
+
+func TestValidateAuthConfig(t *testing.T) {
+	if err := validateAuthConfig(&AuthConfig{}); err != nil {
+		t.Errorf("Disabled auth should not return error: %v", err)
+	}
+	if err := validateAuthConfig(&AuthConfig{Enabled: true}); err == nil {
+		t.Error("Enabled auth without keys should return error")
+	}
+	invalid := []APIKeyConfig{
+		{Key: "secret"},
+		{Name: "ci"},
+		{Name: "ci", Key: "secret", KeySHA256: "00"},
+		{Name: "ci", Key: "secret", MaxConcurrent: -1},
+		{Name: "ci", Key: "secret", RateLimit: -1},
+	}
+	for _, key := range invalid {
+		if err := validateAuthConfig(&AuthConfig{Enabled: true, Keys: []APIKeyConfig{key}}); err == nil {
+			t.Errorf("Invalid API key %+v should return error", key)
+		}
+	}
+	duplicate := []APIKeyConfig{{Name: "ci", Key: "a"}, {Name: "ci", Key: "b"}}
+	if err := validateAuthConfig(&AuthConfig{Enabled: true, Keys: duplicate}); err == nil {
+		t.Error("Duplicate API key names should return error")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for size, expected := range map[string]int64{
+		"":       0,
+		"0":      0,
+		"100":    100,
+		"100B":   100,
+		"1KB":    1024,
+		"512 MB": 512 << 20,
+		"10GB":   10 << 30,
+		"1.5gb":  3 << 29,
+		"2TB":    2 << 40,
+	} {
+		actual, err := ParseSize(size)
+		if err != nil {
+			t.Errorf("ParseSize(%q) failed: %v", size, err)
+		} else if actual != expected {
+			t.Errorf("ParseSize(%q) = %d, expected %d", size, actual, expected)
+		}
+	}
+	for _, size := range []string{"GB", "ten", "-1MB", "1PB"} {
+		if _, err := ParseSize(size); err == nil {
+			t.Errorf("ParseSize(%q) should return error", size)
+		}
+	}
 }
 
 func TestTimeDurationParsing(t *testing.T) {
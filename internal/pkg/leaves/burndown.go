@@ -9,6 +9,8 @@ import (
 	"math"
 	"os"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 	"unicode/utf8"
@@ -52,6 +54,10 @@ type BurndownAnalysis struct {
 	// TickSize indicates the size of each time granule: day, hour, week, etc.
 	TickSize time.Duration
 
+	// ForecastTicks enables the code survival analysis and sets how many ticks ahead to project
+	// the project's burndown matrix. 0 disables it.
+	ForecastTicks int
+
 	// HibernationThreshold sets the hibernation threshold for the underlying
 	// RBTree allocator. It is useful to trade CPU time for reduced peak memory consumption
 	// if there are many branches.
@@ -110,10 +116,59 @@ type BurndownAnalysis struct {
 	previousTick int
 	// references IdentityDetector.ReversedPeopleDict
 	reversedPeopleDict []string
+	// subscription is published as factBurndownSubscription.
+	subscription *burndownSubscription
+	// trackAuthors packs the authors into the line values even if PeopleNumber is 0.
+	trackAuthors bool
+	// lines accumulates the line updates for DependencyBurndownLines. It is nil if nobody
+	// subscribed to them.
+	lines *burndownLines
 
 	l core.Logger
 }
 
+// LineUpdate is a change of the lines of a file tracked by BurndownAnalysis: Delta lines which
+// PreviousAuthor wrote at PreviousTick were replaced with the lines of CurrentAuthor at CurrentTick.
+// Delta is positive for the new lines, then the current and the previous values are the same,
+// and negative for the removed lines. The authors are identity.AuthorMissing unless they were
+// requested in subscribeBurndownLines().
+type LineUpdate struct {
+	File           string
+	CurrentAuthor  int
+	CurrentTick    int
+	PreviousAuthor int
+	PreviousTick   int
+	Delta          int
+}
+
+// BurndownLines is the value of DependencyBurndownLines.
+type BurndownLines struct {
+	// Updates are the line updates of the consumed commit.
+	Updates []LineUpdate
+	// Merged are the line updates which resolved the conflicts of the merge preceding
+	// the consumed commit. They belong to that merge commit.
+	Merged []LineUpdate
+
+	// burndown is the fork of BurndownAnalysis which consumed the commit.
+	burndown *BurndownAnalysis
+}
+
+// burndownSubscription is published by BurndownAnalysis.Configure() in the facts so that
+// the analyses which require DependencyBurndownLines can enable it, see subscribeBurndownLines().
+type burndownSubscription struct {
+	lines   bool
+	authors bool
+}
+
+// burndownLines accumulates the LineUpdate-s. It is shared by the forks because the updaters
+// of the files stay bound to the instance which created them.
+type burndownLines struct {
+	// file is the path which is being updated
+	file    string
+	updates []LineUpdate
+	merged  []LineUpdate
+}
+
 // BurndownResult carries the result of running BurndownAnalysis - it is returned by
 // BurndownAnalysis.Finalize().
 type BurndownResult struct {
@@ -136,6 +191,9 @@ type BurndownResult struct {
 	// The rest of the elements are equal the number of line removals by the corresponding
 	// authors in reversedPeopleDict: 2 -> 0, 3 -> 1, etc.
 	PeopleMatrix DenseHistory
+	// Survival is the code half-life and the forecast of GlobalHistory. It is nil unless
+	// BurndownAnalysis.ForecastTicks is positive.
+	Survival *BurndownSurvival
 
 	// The following members are private.
 
@@ -172,6 +230,15 @@ const (
 	ConfigBurndownHibernationDirectory = "Burndown.HibernationDirectory"
 	// ConfigBurndownDebug enables some extra debug assertions.
 	ConfigBurndownDebug = "Burndown.Debug"
+	// ConfigBurndownForecastTicks is the name of the option to set BurndownAnalysis.ForecastTicks.
+	ConfigBurndownForecastTicks = "Burndown.ForecastTicks"
+	// DependencyBurndownLines is the name of the dependency provided by BurndownAnalysis:
+	// BurndownLines with the line updates of each commit. The analyses which require it must
+	// call subscribeBurndownLines() in Configure(), otherwise the updates are empty.
+	// The lines which resolved the conflicts of the last merge in the history are not reported.
+	DependencyBurndownLines = "burndown_lines"
+	// factBurndownSubscription is the name of the fact with *burndownSubscription.
+	factBurndownSubscription = "Burndown.Subscription"
 	// DefaultBurndownGranularity is the default number of ticks for BurndownAnalysis.Granularity
 	// and BurndownAnalysis.Sampling.
 	DefaultBurndownGranularity = 30
@@ -196,7 +263,7 @@ func (analyser *BurndownAnalysis) Name() string {
 // Each produced entity will be inserted into `deps` of dependent Consume()-s according
 // to this list. Also used by core.Registry to build the global map of providers.
 func (analyser *BurndownAnalysis) Provides() []string {
-	return []string{}
+	return []string{DependencyBurndownLines}
 }
 
 // Requires returns the list of names of entities which are needed by this PipelineItem.
@@ -205,7 +272,7 @@ func (analyser *BurndownAnalysis) Provides() []string {
 func (analyser *BurndownAnalysis) Requires() []string {
 	return []string{
 		items.DependencyFileDiff, items.DependencyTreeChanges, items.DependencyBlobCache,
-		items.DependencyTick, identity.DependencyAuthor}
+		items.DependencyTick, identity.DependencyAuthor, items.DependencyFileOrigins}
 }
 
 // ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
@@ -253,7 +320,13 @@ func (analyser *BurndownAnalysis) ListConfigurationOptions() []core.Configuratio
 		Description: "Validate the trees at each step.",
 		Flag:        "burndown-debug",
 		Type:        core.BoolConfigurationOption,
-		Default:     false},
+		Default:     false}, {
+		Name: ConfigBurndownForecastTicks,
+		Description: "Estimate the code half-life and project the burndown matrix this many " +
+			"ticks ahead. 0 disables it.",
+		Flag:    "burndown-forecast",
+		Type:    core.IntConfigurationOption,
+		Default: 0},
 	}
 	return options[:]
 }
@@ -297,12 +370,27 @@ func (analyser *BurndownAnalysis) Configure(facts map[string]interface{}) error
 	if val, exists := facts[ConfigBurndownDebug].(bool); exists {
 		analyser.Debug = val
 	}
+	if val, exists := facts[ConfigBurndownForecastTicks].(int); exists {
+		analyser.ForecastTicks = val
+	}
 	if val, exists := facts[items.FactTickSize].(time.Duration); exists {
 		analyser.TickSize = val
 	}
+	analyser.subscription = &burndownSubscription{}
+	facts[factBurndownSubscription] = analyser.subscription
 	return nil
 }
 
+// subscribeBurndownLines enables DependencyBurndownLines of the BurndownAnalysis configured
+// with the same facts. If authors is true, the line values carry the authors even if
+// the people are not tracked.
+func subscribeBurndownLines(facts map[string]interface{}, authors bool) {
+	if subscription, exists := facts[factBurndownSubscription].(*burndownSubscription); exists {
+		subscription.lines = true
+		subscription.authors = subscription.authors || authors
+	}
+}
+
 // Flag for the command line switch which enables this analysis.
 func (analyser *BurndownAnalysis) Flag() string {
 	return "burndown"
@@ -344,6 +432,9 @@ func (analyser *BurndownAnalysis) Initialize(repository *git.Repository) error {
 	if analyser.PeopleNumber < 0 {
 		return fmt.Errorf("PeopleNumber is negative: %d", analyser.PeopleNumber)
 	}
+	if analyser.ForecastTicks < 0 {
+		return fmt.Errorf("ForecastTicks is negative: %d", analyser.ForecastTicks)
+	}
 	analyser.peopleHistories = make([]sparseHistory, analyser.PeopleNumber)
 	analyser.files = map[string]*burndown.File{}
 	analyser.fileAllocator = rbtree.NewAllocator()
@@ -355,6 +446,12 @@ func (analyser *BurndownAnalysis) Initialize(repository *git.Repository) error {
 	analyser.matrix = make([]map[int]int64, analyser.PeopleNumber)
 	analyser.tick = 0
 	analyser.previousTick = 0
+	analyser.trackAuthors = false
+	analyser.lines = nil
+	if subscription := analyser.subscription; subscription != nil && subscription.lines {
+		analyser.trackAuthors = subscription.authors
+		analyser.lines = &burndownLines{}
+	}
 	return nil
 }
 
@@ -382,12 +479,24 @@ func (analyser *BurndownAnalysis) Consume(deps map[string]interface{}) (map[stri
 	cache := deps[items.DependencyBlobCache].(map[plumbing.Hash]*items.CachedBlob)
 	treeDiffs := deps[items.DependencyTreeChanges].(object.Changes)
 	fileDiffs := deps[items.DependencyFileDiff].(map[string]items.FileDiffData)
+	origins, _ := deps[items.DependencyFileOrigins].(map[string]*items.FileOrigin)
+	sources := analyser.originSources(origins)
 	for _, change := range treeDiffs {
 		action, _ := change.Action()
+		if analyser.lines != nil {
+			analyser.lines.file = change.To.Name
+			if action == merkletrie.Delete {
+				analyser.lines.file = change.From.Name
+			}
+		}
 		var err error
 		switch action {
 		case merkletrie.Insert:
-			err = analyser.handleInsertion(change, author, cache)
+			if origin := origins[change.To.Name]; origin != nil && sources != nil {
+				err = analyser.handleDerivation(change, author, cache, origin, sources)
+			} else {
+				err = analyser.handleInsertion(change, author, cache)
+			}
 		case merkletrie.Delete:
 			err = analyser.handleDeletion(change, author, cache)
 		case merkletrie.Modify:
@@ -399,7 +508,14 @@ func (analyser *BurndownAnalysis) Consume(deps map[string]interface{}) (map[stri
 	}
 	// in case there is a merge analyser.tick equals to TreeMergeMark
 	analyser.tick = tick
-	return nil, nil
+	lines := BurndownLines{burndown: analyser}
+	if analyser.lines != nil {
+		lines.Updates = analyser.lines.updates
+		lines.Merged = analyser.lines.merged
+		analyser.lines.updates = nil
+		analyser.lines.merged = nil
+	}
+	return map[string]interface{}{DependencyBurndownLines: lines}, nil
 }
 
 // Fork clones this item. Everything is copied by reference except the files
@@ -458,6 +574,9 @@ func (analyser *BurndownAnalysis) Merge(branches []core.PipelineItem) {
 			// it could be also removed in the merge commit itself
 			continue
 		}
+		if analyser.lines != nil {
+			analyser.lines.file = key
+		}
 		files[0].Merge(
 			analyser.packPersonWithTick(analyser.mergedAuthor, analyser.tick),
 			files[1:]...)
@@ -470,6 +589,11 @@ func (analyser *BurndownAnalysis) Merge(branches []core.PipelineItem) {
 			}
 		}
 	}
+	if analyser.lines != nil {
+		// the merge commit has already been consumed
+		analyser.lines.merged = append(analyser.lines.merged, analyser.lines.updates...)
+		analyser.lines.updates = nil
+	}
 	analyser.onNewTick()
 }
 
@@ -513,6 +637,107 @@ func (analyser *BurndownAnalysis) Boot() error {
 	return nil
 }
 
+// burndownCheckpoint is the state of BurndownAnalysis saved in core.Checkpoint.
+type burndownCheckpoint struct {
+	Granularity     int
+	Sampling        int
+	TrackFiles      bool
+	PeopleNumber    int
+	TrackAuthors    bool
+	TickSize        time.Duration
+	GlobalHistory   sparseHistory
+	FileHistories   map[string]sparseHistory
+	PeopleHistories []sparseHistory
+	// Files map the paths to the line intervals, see burndown.File.Intervals().
+	Files        map[string][2][]uint32
+	Renames      map[string]string
+	Deletions    map[string]bool
+	Matrix       []map[int]int64
+	Tick         int
+	PreviousTick int
+}
+
+// Checkpoint writes the line ownership of the files and the accumulated histories.
+// It implements core.CheckpointablePipelineItem.
+func (analyser *BurndownAnalysis) Checkpoint(writer io.Writer) error {
+	if analyser.fileAllocator.Size() == 0 && len(analyser.files) > 0 {
+		return errors.New("cannot checkpoint a hibernated instance")
+	}
+	state := burndownCheckpoint{
+		Granularity:     analyser.Granularity,
+		Sampling:        analyser.Sampling,
+		TrackFiles:      analyser.TrackFiles,
+		PeopleNumber:    analyser.PeopleNumber,
+		TrackAuthors:    analyser.trackAuthors,
+		TickSize:        analyser.TickSize,
+		GlobalHistory:   analyser.globalHistory,
+		FileHistories:   analyser.fileHistories,
+		PeopleHistories: analyser.peopleHistories,
+		Files:           make(map[string][2][]uint32, len(analyser.files)),
+		Renames:         analyser.renames,
+		Deletions:       analyser.deletions,
+		Matrix:          analyser.matrix,
+		Tick:            analyser.tick,
+		PreviousTick:    analyser.previousTick,
+	}
+	for name, file := range analyser.files {
+		keys, vals := file.Intervals()
+		state.Files[name] = [2][]uint32{keys, vals}
+	}
+	return core.EncodeCheckpoint(writer, state)
+}
+
+// Restore loads the state written by Checkpoint(). The granularity, the sampling, the tick size
+// and whether the files, the people and the authors of the lines are tracked must not change
+// between the runs; the number of people may only grow. It implements core.CheckpointablePipelineItem.
+func (analyser *BurndownAnalysis) Restore(reader io.Reader) error {
+	state := burndownCheckpoint{}
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
+	}
+	if state.Granularity != analyser.Granularity || state.Sampling != analyser.Sampling ||
+		state.TickSize != analyser.TickSize || state.TrackFiles != analyser.TrackFiles {
+		return fmt.Errorf("the configuration changed: granularity %d -> %d, sampling %d -> %d, "+
+			"tick size %s -> %s, track files %v -> %v",
+			state.Granularity, analyser.Granularity, state.Sampling, analyser.Sampling,
+			state.TickSize, analyser.TickSize, state.TrackFiles, analyser.TrackFiles)
+	}
+	if (state.PeopleNumber > 0) != (analyser.PeopleNumber > 0) ||
+		state.PeopleNumber > analyser.PeopleNumber {
+		return fmt.Errorf("the number of people changed from %d to %d",
+			state.PeopleNumber, analyser.PeopleNumber)
+	}
+	if (state.PeopleNumber > 0 || state.TrackAuthors) != analyser.tracksAuthors() {
+		return errors.New("the analyses which require the authors of the lines changed")
+	}
+	analyser.globalHistory = state.GlobalHistory
+	if analyser.globalHistory == nil {
+		analyser.globalHistory = sparseHistory{}
+	}
+	if state.FileHistories != nil {
+		analyser.fileHistories = state.FileHistories
+	}
+	copy(analyser.peopleHistories, state.PeopleHistories)
+	copy(analyser.matrix, state.Matrix)
+	if state.Renames != nil {
+		analyser.renames = state.Renames
+	}
+	if state.Deletions != nil {
+		analyser.deletions = state.Deletions
+	}
+	analyser.tick = state.Tick
+	analyser.previousTick = state.PreviousTick
+	for name, intervals := range state.Files {
+		file, err := burndown.NewFileFromIntervals(
+			intervals[0], intervals[1], analyser.fileAllocator, analyser.fileUpdaters(name)...)
+		if err != nil {
+			return fmt.Errorf("file %s: %v", name, err)
+		}
+		analyser.files[name] = file
+	}
+	return nil
+}
+
 // Finalize returns the result of the analysis. Further Consume() calls are not expected.
 func (analyser *BurndownAnalysis) Finalize() interface{} {
 	globalHistory, lastTick := analyser.groupSparseHistory(analyser.globalHistory, -1)
@@ -523,22 +748,7 @@ func (analyser *BurndownAnalysis) Finalize() interface{} {
 			continue
 		}
 		fileHistories[key], _ = analyser.groupSparseHistory(history, lastTick)
-		file := analyser.files[key]
-		previousLine := 0
-		previousAuthor := identity.AuthorMissing
-		ownership := map[int]int{}
-		fileOwnership[key] = ownership
-		file.ForEach(func(line, value int) {
-			length := line - previousLine
-			if length > 0 {
-				ownership[previousAuthor] += length
-			}
-			previousLine = line
-			previousAuthor, _ = analyser.unpackPersonWithTick(int(value))
-			if previousAuthor == identity.AuthorMissing {
-				previousAuthor = -1
-			}
-		})
+		fileOwnership[key] = analyser.fileOwnership(analyser.files[key])
 	}
 	peopleHistories := make([]DenseHistory, analyser.PeopleNumber)
 	for i, history := range analyser.peopleHistories {
@@ -568,7 +778,7 @@ func (analyser *BurndownAnalysis) Finalize() interface{} {
 			}
 		}
 	}
-	return BurndownResult{
+	result := BurndownResult{
 		GlobalHistory:      globalHistory,
 		FileHistories:      fileHistories,
 		FileOwnership:      fileOwnership,
@@ -579,6 +789,10 @@ func (analyser *BurndownAnalysis) Finalize() interface{} {
 		sampling:           analyser.Sampling,
 		granularity:        analyser.Granularity,
 	}
+	if analyser.ForecastTicks > 0 {
+		result.Survival = newBurndownSurvival(&result, analyser.ForecastTicks)
+	}
+	return result
 }
 
 // Serialize converts the analysis result as returned by Finalize() to text or bytes.
@@ -647,6 +861,23 @@ func (analyser *BurndownAnalysis) Deserialize(pbmessage []byte) (interface{}, er
 			result.PeopleMatrix[i][msg.PeopleInteraction.Indices[j]] = msg.PeopleInteraction.Data[j]
 		}
 	}
+	if survival := msg.Survival; survival != nil {
+		result.Survival = &BurndownSurvival{
+			Curve:           survival.Curve,
+			HalfLife:        survival.HalfLife,
+			BandHalfLives:   survival.BandHalfLives,
+			FileHalfLives:   survival.FileHalfLives,
+			PeopleHalfLives: survival.PeopleHalfLives,
+			ForecastTicks:   int(survival.ForecastTicks),
+			Forecast:        DenseHistory{},
+		}
+		if survival.FileHalfLives == nil {
+			result.Survival.FileHalfLives = map[string]float64{}
+		}
+		if survival.Forecast != nil {
+			result.Survival.Forecast = convertCSR(survival.Forecast)
+		}
+	}
 	return result, nil
 }
 
@@ -760,6 +991,15 @@ func (analyser *BurndownAnalysis) MergeResults(
 		}()
 	}
 	wg.Wait()
+	if bar1.Survival != nil || bar2.Survival != nil {
+		forecastTicks := 0
+		for _, survival := range []*BurndownSurvival{bar1.Survival, bar2.Survival} {
+			if survival != nil && survival.ForecastTicks > forecastTicks {
+				forecastTicks = survival.ForecastTicks
+			}
+		}
+		merged.Survival = newBurndownSurvival(&merged, forecastTicks)
+	}
 	return merged
 }
 
@@ -1062,6 +1302,43 @@ func (analyser *BurndownAnalysis) serializeText(result *BurndownResult, writer i
 			fmt.Fprintf(writer, "    %d: %v\n", i, row)
 		}
 	}
+	if survival := result.Survival; survival != nil {
+		fmt.Fprintln(writer, "  survival:")
+		fmt.Fprintf(writer, "    half_life: %.4f\n", survival.HalfLife)
+		fmt.Fprintf(writer, "    curve: %s\n", formatFloats(survival.Curve))
+		fmt.Fprintf(writer, "    bands_half_life: %s\n", formatFloats(survival.BandHalfLives))
+		if len(survival.FileHalfLives) > 0 {
+			fmt.Fprintln(writer, "    files_half_life:")
+			keys := make([]string, 0, len(survival.FileHalfLives))
+			for key := range survival.FileHalfLives {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				fmt.Fprintf(writer, "      %s: %.4f\n", key, survival.FileHalfLives[key])
+			}
+		}
+		if len(survival.PeopleHalfLives) > 0 {
+			fmt.Fprintln(writer, "    people_half_life:")
+			for key, val := range survival.PeopleHalfLives {
+				fmt.Fprintf(writer, "      %s: %.4f\n", result.reversedPeopleDict[key], val)
+			}
+		}
+		fmt.Fprintln(writer, "    forecast_ticks:", survival.ForecastTicks)
+		fmt.Fprintln(writer, "    forecast:")
+		for i, row := range survival.Forecast {
+			fmt.Fprintf(writer, "      %d: %v\n", len(result.GlobalHistory)+i, row)
+		}
+	}
+}
+
+// formatFloats writes the YAML flow sequence of the numbers with 4 decimal digits.
+func formatFloats(values []float64) string {
+	formatted := make([]string, len(values))
+	for i, val := range values {
+		formatted[i] = strconv.FormatFloat(val, 'f', 4, 64)
+	}
+	return "[" + strings.Join(formatted, ", ") + "]"
 }
 
 func (analyser *BurndownAnalysis) serializeBinary(result *BurndownResult, writer io.Writer) error {
@@ -1101,6 +1378,19 @@ func (analyser *BurndownAnalysis) serializeBinary(result *BurndownResult, writer
 	if result.PeopleMatrix != nil {
 		message.PeopleInteraction = pb.DenseToCompressedSparseRowMatrix(result.PeopleMatrix)
 	}
+	if survival := result.Survival; survival != nil {
+		message.Survival = &pb.BurndownSurvival{
+			Curve:           survival.Curve,
+			HalfLife:        survival.HalfLife,
+			BandHalfLives:   survival.BandHalfLives,
+			FileHalfLives:   survival.FileHalfLives,
+			PeopleHalfLives: survival.PeopleHalfLives,
+			ForecastTicks:   int32(survival.ForecastTicks),
+		}
+		if len(survival.Forecast) > 0 {
+			message.Survival.Forecast = pb.ToBurndownSparseMatrix(survival.Forecast, "forecast")
+		}
+	}
 	serialized, err := proto.Marshal(&message)
 	if err != nil {
 		return err
@@ -1116,6 +1406,15 @@ func (analyser *BurndownAnalysis) serializeJSON(result *BurndownResult, writer i
 		"tick_size":   int(result.tickSize.Seconds()),
 		"project":     result.GlobalHistory,
 	}
+	if survival := result.Survival; survival != nil {
+		output["survival"] = map[string]interface{}{
+			"half_life":       survival.HalfLife,
+			"curve":           survival.Curve,
+			"bands_half_life": survival.BandHalfLives,
+			"forecast_ticks":  survival.ForecastTicks,
+			"forecast":        survival.Forecast,
+		}
+	}
 	enc := json.NewEncoder(writer)
 	enc.SetIndent("", "  ")
 	return enc.Encode(output)
@@ -1141,7 +1440,7 @@ func checkClose(c io.Closer) {
 // This hack is needed to simplify the values storage inside File-s. We can compare
 // different values together and they are compared as ticks for the same author.
 func (analyser *BurndownAnalysis) packPersonWithTick(person int, tick int) int {
-	if analyser.PeopleNumber == 0 {
+	if !analyser.tracksAuthors() {
 		return tick
 	}
 	result := tick & burndown.TreeMergeMark
@@ -1156,12 +1455,37 @@ func (analyser *BurndownAnalysis) packPersonWithTick(person int, tick int) int {
 }
 
 func (analyser *BurndownAnalysis) unpackPersonWithTick(value int) (int, int) {
-	if analyser.PeopleNumber == 0 {
+	if !analyser.tracksAuthors() {
 		return identity.AuthorMissing, value
 	}
 	return value >> burndown.TreeMaxBinPower, value & burndown.TreeMergeMark
 }
 
+// tracksAuthors returns true if the line values carry the authors.
+func (analyser *BurndownAnalysis) tracksAuthors() bool {
+	return analyser.PeopleNumber > 0 || analyser.trackAuthors
+}
+
+// fileOwnership returns the mapping from developer indexes to the number of lines they own
+// in the file. The lines of unknown developers are mapped to -1.
+func (analyser *BurndownAnalysis) fileOwnership(file *burndown.File) map[int]int {
+	previousLine := 0
+	previousAuthor := identity.AuthorMissing
+	ownership := map[int]int{}
+	file.ForEach(func(line, value int) {
+		length := line - previousLine
+		if length > 0 {
+			ownership[previousAuthor] += length
+		}
+		previousLine = line
+		previousAuthor, _ = analyser.unpackPersonWithTick(int(value))
+		if previousAuthor == identity.AuthorMissing {
+			previousAuthor = -1
+		}
+	})
+	return ownership
+}
+
 func (analyser *BurndownAnalysis) onNewTick() {
 	if analyser.tick > analyser.previousTick {
 		analyser.previousTick = analyser.tick
@@ -1241,6 +1565,12 @@ func (analyser *BurndownAnalysis) updateMatrix(currentTime, previousTime, delta
 func (analyser *BurndownAnalysis) newFile(
 	hash plumbing.Hash, name string, author int, tick int, size int) (*burndown.File, error) {
 
+	tick = analyser.packPersonWithTick(author, tick)
+	return burndown.NewFile(tick, size, analyser.fileAllocator, analyser.fileUpdaters(name)...), nil
+}
+
+// fileUpdaters returns the burndown.Updater-s of the file with the specified name.
+func (analyser *BurndownAnalysis) fileUpdaters(name string) []burndown.Updater {
 	updaters := make([]burndown.Updater, 1)
 	updaters[0] = analyser.updateGlobal
 	if analyser.TrackFiles {
@@ -1257,9 +1587,16 @@ func (analyser *BurndownAnalysis) newFile(
 	if analyser.PeopleNumber > 0 {
 		updaters = append(updaters, analyser.updateAuthor)
 		updaters = append(updaters, analyser.updateMatrix)
-		tick = analyser.packPersonWithTick(author, tick)
 	}
-	return burndown.NewFile(tick, size, analyser.fileAllocator, updaters...), nil
+	if lines := analyser.lines; lines != nil {
+		updaters = append(updaters, func(currentTime, previousTime, delta int) {
+			update := LineUpdate{File: lines.file, Delta: delta}
+			update.CurrentAuthor, update.CurrentTick = analyser.unpackPersonWithTick(currentTime)
+			update.PreviousAuthor, update.PreviousTick = analyser.unpackPersonWithTick(previousTime)
+			lines.updates = append(lines.updates, update)
+		})
+	}
+	return updaters
 }
 
 func (analyser *BurndownAnalysis) handleInsertion(
@@ -1288,6 +1625,62 @@ func (analyser *BurndownAnalysis) handleInsertion(
 	return err
 }
 
+// originSources returns the line values of the files which the new files were copied,
+// split or joined from. The values are taken before any change in the commit is applied.
+// Merges are not supported and return nil.
+func (analyser *BurndownAnalysis) originSources(origins map[string]*items.FileOrigin) map[string][]int {
+	if len(origins) == 0 || analyser.tick == burndown.TreeMergeMark {
+		return nil
+	}
+	sources := map[string][]int{}
+	for _, origin := range origins {
+		for _, name := range origin.Sources {
+			if _, exists := sources[name]; exists {
+				continue
+			}
+			if file, exists := analyser.files[name]; exists {
+				sources[name] = file.Lines()
+			}
+		}
+	}
+	return sources
+}
+
+// handleDerivation inserts the new file which was copied, split or joined from the existing
+// files. The lines taken from those files keep their authors and ticks.
+// The joins into the modified files are handled by handleModification() as the regular edits.
+func (analyser *BurndownAnalysis) handleDerivation(
+	change *object.Change, author int, cache map[plumbing.Hash]*items.CachedBlob,
+	origin *items.FileOrigin, sources map[string][]int) error {
+
+	blob := cache[change.To.TreeEntry.Hash]
+	lines, err := blob.CountLines()
+	if err != nil || lines != len(origin.Lines) {
+		return analyser.handleInsertion(change, author, cache)
+	}
+	name := change.To.Name
+	if _, exists := analyser.files[name]; exists {
+		return fmt.Errorf("file %s already exists", name)
+	}
+	values := make([]int, lines)
+	fresh := analyser.packPersonWithTick(author, analyser.tick)
+	for i, line := range origin.Lines {
+		values[i] = fresh
+		if line.Source < 0 {
+			continue
+		}
+		if source := sources[origin.Sources[line.Source]]; line.Line < len(source) {
+			values[i] = source[line.Line]
+		}
+	}
+	analyser.files[name] = burndown.NewFileFromLines(values, func(value int) int {
+		owner, _ := analyser.unpackPersonWithTick(value)
+		return analyser.packPersonWithTick(owner, analyser.tick)
+	}, analyser.fileAllocator, analyser.fileUpdaters(name)...)
+	delete(analyser.deletions, name)
+	return nil
+}
+
 func (analyser *BurndownAnalysis) handleDeletion(
 	change *object.Change, author int, cache map[plumbing.Hash]*items.CachedBlob) error {
 
@@ -0,0 +1,163 @@
+package leaves
+
+import (
+	"math"
+)
+
+// BurndownSurvival is the code survival analysis of BurndownResult. Each band of lines is
+// considered born when the band ends; the share of its lines which are still alive decays
+// with age. The decay is fitted with an exponential curve, so the half-life is the age
+// at which half of the lines were removed or rewritten.
+type BurndownSurvival struct {
+	// Curve is the share of the project's lines which survived each number of samples after
+	// the end of their band, pooled over all the bands. Curve[0] is always 1.
+	Curve []float64
+	// HalfLife is the project's code half-life in ticks. 0 means that no decay was observed.
+	HalfLife float64
+	// BandHalfLives is the code half-life in ticks of each band of the project.
+	BandHalfLives []float64
+	// FileHalfLives is the code half-life in ticks of each file in FileHistories.
+	FileHalfLives map[string]float64
+	// PeopleHalfLives is the code half-life in ticks of each developer in PeopleHistories.
+	PeopleHalfLives []float64
+	// ForecastTicks is how far into the future GlobalHistory is projected.
+	ForecastTicks int
+	// Forecast continues GlobalHistory for ForecastTicks ticks assuming that no new lines are
+	// added and each band keeps decaying at its own rate.
+	// [number of future samples][number of bands]
+	Forecast DenseHistory
+}
+
+// survivalFit is the exponential decay fitted to a burndown matrix.
+type survivalFit struct {
+	// curve is the pooled share of surviving lines by the number of samples since the band's end
+	curve []float64
+	// decay is the pooled decay rate per tick
+	decay float64
+	// bandDecays is the decay rate per tick of each band, -1 if it could not be fitted
+	bandDecays []float64
+}
+
+// survivalFloorLines replaces the zero lines of a band which was removed completely in the fit,
+// so that the logarithm stays finite and the band's disappearance counts as a fast decay.
+const survivalFloorLines = 0.5
+
+// newBurndownSurvival fits the survival curves of every matrix in the result and projects
+// GlobalHistory forward by forecastTicks.
+func newBurndownSurvival(result *BurndownResult, forecastTicks int) *BurndownSurvival {
+	granularity, sampling := result.granularity, result.sampling
+	fit := fitSurvival(result.GlobalHistory, granularity, sampling)
+	survival := &BurndownSurvival{
+		Curve:         fit.curve,
+		HalfLife:      halfLife(fit.decay),
+		BandHalfLives: make([]float64, len(fit.bandDecays)),
+		FileHalfLives: map[string]float64{},
+		ForecastTicks: forecastTicks,
+	}
+	for i, decay := range fit.bandDecays {
+		survival.BandHalfLives[i] = halfLife(decay)
+	}
+	for name, history := range result.FileHistories {
+		survival.FileHalfLives[name] = halfLife(fitSurvival(history, granularity, sampling).decay)
+	}
+	if len(result.PeopleHistories) > 0 {
+		survival.PeopleHalfLives = make([]float64, len(result.PeopleHistories))
+		for i, history := range result.PeopleHistories {
+			survival.PeopleHalfLives[i] = halfLife(fitSurvival(history, granularity, sampling).decay)
+		}
+	}
+	survival.Forecast = forecastBurndown(result.GlobalHistory, fit, sampling, forecastTicks)
+	return survival
+}
+
+// fitSurvival calculates the survival of each band since the first sample after its end and
+// fits exp(-decay * age) by least squares over the logarithms, weighted by the band sizes.
+func fitSurvival(history DenseHistory, granularity, sampling int) survivalFit {
+	fit := survivalFit{curve: []float64{}}
+	if len(history) == 0 || granularity <= 0 || sampling <= 0 {
+		return fit
+	}
+	bands := len(history[len(history)-1])
+	fit.bandDecays = make([]float64, bands)
+	// the total initial and surviving lines by age in samples
+	var born, alive []float64
+	var pooledNum, pooledDenom float64
+	for band := 0; band < bands; band++ {
+		fit.bandDecays[band] = -1
+		// the first sample which covers the whole band
+		first := ((band+1)*granularity - 1) / sampling
+		if first >= len(history) || band >= len(history[first]) || history[first][band] <= 0 {
+			continue
+		}
+		initial := float64(history[first][band])
+		var num, denom float64
+		for age := 0; first+age < len(history); age++ {
+			lines := float64(0)
+			if band < len(history[first+age]) {
+				lines = float64(history[first+age][band])
+			}
+			if age >= len(born) {
+				born = append(born, 0)
+				alive = append(alive, 0)
+			}
+			born[age] += initial
+			alive[age] += lines
+			if age > 0 {
+				ticks := float64(age * sampling)
+				num += ticks * -math.Log(math.Max(lines, survivalFloorLines)/initial)
+				denom += ticks * ticks
+			}
+		}
+		if denom > 0 {
+			fit.bandDecays[band] = num / denom
+		}
+	}
+	fit.curve = make([]float64, len(born))
+	for age := range born {
+		fit.curve[age] = alive[age] / born[age]
+		if age > 0 {
+			ticks := float64(age * sampling)
+			pooledNum += born[age] * ticks * -math.Log(math.Max(alive[age], survivalFloorLines)/born[age])
+			pooledDenom += born[age] * ticks * ticks
+		}
+	}
+	if pooledDenom > 0 {
+		fit.decay = pooledNum / pooledDenom
+	}
+	return fit
+}
+
+// forecastBurndown projects the last sample of the matrix forward by forecastTicks.
+// The bands which could not be fitted decay at the pooled rate.
+func forecastBurndown(history DenseHistory, fit survivalFit, sampling, forecastTicks int) DenseHistory {
+	if len(history) == 0 || sampling <= 0 || forecastTicks <= 0 {
+		return DenseHistory{}
+	}
+	last := history[len(history)-1]
+	samples := (forecastTicks + sampling - 1) / sampling
+	forecast := make(DenseHistory, samples)
+	for i := range forecast {
+		ticks := float64((i + 1) * sampling)
+		row := make([]int64, len(last))
+		for band, lines := range last {
+			decay := fit.decay
+			if band < len(fit.bandDecays) && fit.bandDecays[band] >= 0 {
+				decay = fit.bandDecays[band]
+			}
+			if decay < 0 {
+				decay = 0
+			}
+			row[band] = int64(math.Round(float64(lines) * math.Exp(-decay*ticks)))
+		}
+		forecast[i] = row
+	}
+	return forecast
+}
+
+// halfLife converts the decay rate to the number of ticks until half of the lines remain.
+func halfLife(decay float64) float64 {
+	if decay <= 0 {
+		return 0
+	}
+	return math.Ln2 / decay
+}
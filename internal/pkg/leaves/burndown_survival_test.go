@@ -0,0 +1,132 @@
+package leaves
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixtureSurvivalHistory returns a burndown matrix with granularity = sampling = 1
+// in which every band loses half of its lines each tick.
+func fixtureSurvivalHistory() DenseHistory {
+	return DenseHistory{
+		{1000, 0, 0},
+		{500, 800, 0},
+		{250, 400, 600},
+		{125, 200, 300},
+	}
+}
+
+func TestBurndownFitSurvival(t *testing.T) {
+	fit := fitSurvival(fixtureSurvivalHistory(), 1, 1)
+	assert.InDeltaSlice(t, []float64{1, 0.5, 0.25, 0.125}, fit.curve, 1e-9)
+	assert.InDelta(t, math.Ln2, fit.decay, 1e-9)
+	assert.Len(t, fit.bandDecays, 3)
+	for _, decay := range fit.bandDecays {
+		assert.InDelta(t, math.Ln2, decay, 1e-9)
+	}
+	assert.InDelta(t, 1, halfLife(fit.decay), 1e-9)
+
+	// the band which has just ended has no decay to fit
+	fit = fitSurvival(DenseHistory{{100, 0}, {100, 50}}, 1, 1)
+	assert.Equal(t, 0.0, fit.bandDecays[0])
+	assert.Equal(t, -1.0, fit.bandDecays[1])
+	assert.Equal(t, 0.0, fit.decay)
+
+	// the band which was removed completely decays faster than by half each tick
+	fit = fitSurvival(DenseHistory{{10}, {0}}, 1, 1)
+	assert.Equal(t, []float64{1, 0}, fit.curve)
+	assert.InDelta(t, math.Log(10/survivalFloorLines), fit.bandDecays[0], 1e-9)
+	assert.Equal(t, fit.bandDecays[0], fit.decay)
+	assert.True(t, halfLife(fit.decay) > 0)
+	assert.True(t, halfLife(fit.decay) < 1)
+
+	// the last band is incomplete
+	fit = fitSurvival(DenseHistory{{100}, {80}}, 3, 1)
+	assert.Equal(t, []float64{-1}, fit.bandDecays)
+	assert.Equal(t, []float64{}, fit.curve)
+
+	fit = fitSurvival(DenseHistory{}, 1, 1)
+	assert.Nil(t, fit.bandDecays)
+	assert.Equal(t, 0.0, fit.decay)
+}
+
+func TestBurndownHalfLife(t *testing.T) {
+	assert.Equal(t, 0.0, halfLife(0))
+	assert.Equal(t, 0.0, halfLife(-1))
+	assert.InDelta(t, 10, halfLife(math.Ln2/10), 1e-9)
+}
+
+func TestBurndownForecast(t *testing.T) {
+	history := fixtureSurvivalHistory()
+	fit := fitSurvival(history, 1, 1)
+	forecast := forecastBurndown(history, fit, 1, 2)
+	assert.Equal(t, DenseHistory{{63, 100, 150}, {31, 50, 75}}, forecast)
+	// the unfitted bands decay at the pooled rate
+	fit.bandDecays[2] = -1
+	fit.decay = 0
+	forecast = forecastBurndown(history, fit, 2, 3)
+	assert.Equal(t, DenseHistory{{31, 50, 300}, {8, 13, 300}}, forecast)
+	assert.Equal(t, DenseHistory{}, forecastBurndown(history, fit, 1, 0))
+	assert.Equal(t, DenseHistory{}, forecastBurndown(DenseHistory{}, fit, 1, 10))
+}
+
+func TestBurndownSurvivalResult(t *testing.T) {
+	result := BurndownResult{
+		GlobalHistory: fixtureSurvivalHistory(),
+		FileHistories: map[string]DenseHistory{
+			"cmd/main.go": fixtureSurvivalHistory(),
+			"README.md":   {{10, 0, 0}, {10, 0, 0}, {10, 0, 0}, {10, 0, 0}},
+		},
+		PeopleHistories:    []DenseHistory{fixtureSurvivalHistory()},
+		PeopleMatrix:       DenseHistory{{0, 0, 0}},
+		reversedPeopleDict: []string{"one@srcd"},
+		sampling:           1,
+		granularity:        1,
+		tickSize:           24 * time.Hour,
+	}
+	survival := newBurndownSurvival(&result, 1)
+	assert.InDelta(t, 1, survival.HalfLife, 1e-9)
+	assert.InDeltaSlice(t, []float64{1, 1, 1}, survival.BandHalfLives, 1e-9)
+	assert.InDelta(t, 1, survival.FileHalfLives["cmd/main.go"], 1e-9)
+	assert.Equal(t, 0.0, survival.FileHalfLives["README.md"])
+	assert.InDeltaSlice(t, []float64{1}, survival.PeopleHalfLives, 1e-9)
+	assert.Equal(t, 1, survival.ForecastTicks)
+	assert.Equal(t, DenseHistory{{63, 100, 150}}, survival.Forecast)
+	result.Survival = survival
+
+	bd := BurndownAnalysis{}
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, bd.Serialize(result, false, buffer))
+	text := buffer.String()
+	assert.Contains(t, text, `  survival:
+    half_life: 1.0000
+    curve: [1.0000, 0.5000, 0.2500, 0.1250]
+    bands_half_life: [1.0000, 1.0000, 1.0000]
+    files_half_life:
+      README.md: 0.0000
+      cmd/main.go: 1.0000
+    people_half_life:
+      one@srcd: 1.0000
+    forecast_ticks: 1
+    forecast:
+      4: [63 100 150]
+`)
+
+	buffer = &bytes.Buffer{}
+	assert.Nil(t, bd.Serialize(result, true, buffer))
+	rawResult2, err := bd.Deserialize(buffer.Bytes())
+	assert.Nil(t, err)
+	result2 := rawResult2.(BurndownResult)
+	assert.Equal(t, survival, result2.Survival)
+
+	c1 := core.CommonAnalysisResult{BeginTime: 600566400, EndTime: 600566400 + 3*24*3600}
+	c2 := c1
+	merged := bd.MergeResults(result, result2, &c1, &c2).(BurndownResult)
+	assert.NotNil(t, merged.Survival)
+	assert.Equal(t, 1, merged.Survival.ForecastTicks)
+}
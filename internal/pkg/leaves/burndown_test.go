@@ -22,12 +22,13 @@ import (
 	"github.com/dmytrogajewski/hercules/internal/pkg/test"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func AddHash(t *testing.T, cache map[plumbing.Hash]*items.CachedBlob, hash string) {
 	objhash := plumbing.NewHash(hash)
 	blob, err := test.Repository.BlobObject(objhash)
-	assert.Nil(t, err)
+	require.NoError(t, err)
 	cb := &items.CachedBlob{Blob: *blob}
 	err = cb.Cache()
 	assert.Nil(t, err)
@@ -37,10 +38,10 @@ func AddHash(t *testing.T, cache map[plumbing.Hash]*items.CachedBlob, hash strin
 func TestBurndownMeta(t *testing.T) {
 	bd := BurndownAnalysis{}
 	assert.Equal(t, bd.Name(), "Burndown")
-	assert.Len(t, bd.Provides(), 0)
+	assert.Equal(t, []string{DependencyBurndownLines}, bd.Provides())
 	required := [...]string{
 		items.DependencyFileDiff, items.DependencyTreeChanges, items.DependencyBlobCache,
-		items.DependencyTick, identity.DependencyAuthor}
+		items.DependencyTick, identity.DependencyAuthor, items.DependencyFileOrigins}
 	for _, name := range required {
 		assert.Contains(t, bd.Requires(), name)
 	}
@@ -51,7 +52,7 @@ func TestBurndownMeta(t *testing.T) {
 		case ConfigBurndownGranularity, ConfigBurndownSampling, ConfigBurndownTrackFiles,
 			ConfigBurndownTrackPeople, ConfigBurndownHibernationThreshold,
 			ConfigBurndownHibernationToDisk, ConfigBurndownHibernationDirectory,
-			ConfigBurndownDebug:
+			ConfigBurndownDebug, ConfigBurndownForecastTicks:
 			matches++
 		}
 	}
@@ -75,6 +76,7 @@ func TestBurndownConfigure(t *testing.T) {
 	facts[ConfigBurndownHibernationThreshold] = 100
 	facts[ConfigBurndownHibernationToDisk] = true
 	facts[ConfigBurndownHibernationDirectory] = "xxx"
+	facts[ConfigBurndownForecastTicks] = 90
 	facts[items.FactTickSize] = 24 * time.Hour
 	facts[identity.FactIdentityDetectorPeopleCount] = 5
 	facts[identity.FactIdentityDetectorReversedPeopleDict] = bd.Requires()
@@ -87,6 +89,7 @@ func TestBurndownConfigure(t *testing.T) {
 	assert.True(t, bd.HibernationToDisk)
 	assert.Equal(t, bd.HibernationDirectory, "xxx")
 	assert.Equal(t, bd.Debug, true)
+	assert.Equal(t, bd.ForecastTicks, 90)
 	assert.Equal(t, bd.TickSize, 24*time.Hour)
 	assert.Equal(t, bd.reversedPeopleDict, bd.Requires())
 	facts[ConfigBurndownTrackPeople] = false
@@ -205,8 +208,7 @@ func TestBurndownConsumeFinalize(t *testing.T) {
 	result, err := fd.Consume(deps)
 	assert.Nil(t, err)
 	deps[items.DependencyFileDiff] = result[items.DependencyFileDiff]
-	deps[core.DependencyCommit], _ = test.Repository.CommitObject(plumbing.NewHash(
-		"cce947b98a050c6d356bc6ba95030254914027b1"))
+	deps[core.DependencyCommit] = test.FixtureCommit(t, "cce947b98a050c6d356bc6ba95030254914027b1")
 	deps[core.DependencyIsMerge] = false
 	result, err = bd.Consume(deps)
 	assert.Nil(t, result)
@@ -423,8 +425,7 @@ func TestBurndownConsumeMergeAuthorMissing(t *testing.T) {
 	filediff, err := fd.Consume(deps)
 	assert.Nil(t, err)
 	deps[items.DependencyFileDiff] = filediff[items.DependencyFileDiff]
-	deps[core.DependencyCommit], _ = test.Repository.CommitObject(plumbing.NewHash(
-		"cce947b98a050c6d356bc6ba95030254914027b1"))
+	deps[core.DependencyCommit] = test.FixtureCommit(t, "cce947b98a050c6d356bc6ba95030254914027b1")
 
 	// check that we survive merge + missing author
 	bd := BurndownAnalysis{PeopleNumber: 1}
@@ -464,8 +465,7 @@ func TestBurndownConsumeMergeAuthorMissing(t *testing.T) {
 	filediff, err = fd.Consume(deps)
 	assert.Nil(t, err)
 	deps[items.DependencyFileDiff] = filediff[items.DependencyFileDiff]
-	deps[core.DependencyCommit], _ = test.Repository.CommitObject(plumbing.NewHash(
-		"7ef5c47aa79a1b229e3227d9ffe2401dbcbeb22f"))
+	deps[core.DependencyCommit] = test.FixtureCommit(t, "7ef5c47aa79a1b229e3227d9ffe2401dbcbeb22f")
 	deps[identity.DependencyAuthor] = identity.AuthorMissing
 	deps[core.DependencyIsMerge] = true
 	_, err = bd.Consume(deps)
@@ -1324,7 +1324,7 @@ func TestBurndownMergeNils(t *testing.T) {
 
 func TestBurndownDeserialize(t *testing.T) {
 	allBuffer, err := ioutil.ReadFile(path.Join("..", "internal", "test_data", "burndown.pb"))
-	assert.Nil(t, err)
+	require.NoError(t, err)
 	bd := BurndownAnalysis{}
 	iresult, err := bd.Deserialize(allBuffer)
 	assert.Nil(t, err)
@@ -1626,3 +1626,103 @@ func TestBurndownResultGetters(t *testing.T) {
 	assert.Equal(t, br.tickSize, br.GetTickSize())
 	assert.Equal(t, br.GetIdentities(), br.reversedPeopleDict)
 }
+
+func TestBurndownCheckpointResume(t *testing.T) {
+	repo := newLeafRepository(
+		test.Commit{Files: map[string]string{"a.txt": "1\n2\n3\n", "b.txt": "x\ny\n"},
+			Author: "Alice", Email: "alice@example.com"},
+		test.Commit{Files: map[string]string{"a.txt": "1\n3\n4\n"},
+			Author: "Bob", Email: "bob@example.com"},
+		test.Commit{Files: map[string]string{"b.txt": "", "c.txt": "z\n"},
+			Author: "Alice", Email: "alice@example.com"},
+		test.Commit{Files: map[string]string{"a.txt": "1\n3\n4\n5\n", "c.txt": "z\nw\n"},
+			Author: "Carol", Email: "carol@example.com"})
+	facts := map[string]interface{}{
+		ConfigBurndownGranularity: 2,
+		ConfigBurndownSampling:    2,
+		ConfigBurndownTrackFiles:  true,
+		ConfigBurndownTrackPeople: true,
+	}
+	full := repo.Run(t, &BurndownAnalysis{}, repo.Commits, facts).(BurndownResult)
+	assert.Len(t, full.reversedPeopleDict, 3)
+	assert.NotEmpty(t, full.GlobalHistory)
+
+	resumed := repo.Resume(t, &BurndownAnalysis{}, 2, facts).(BurndownResult)
+	assert.Equal(t, full.GlobalHistory, resumed.GlobalHistory)
+	assert.Equal(t, full.FileHistories, resumed.FileHistories)
+	assert.Equal(t, full.FileOwnership, resumed.FileOwnership)
+	assert.Equal(t, full.PeopleHistories, resumed.PeopleHistories)
+	assert.Equal(t, full.PeopleMatrix, resumed.PeopleMatrix)
+	assert.Equal(t, full.reversedPeopleDict, resumed.reversedPeopleDict)
+}
+
+func TestBurndownSubscription(t *testing.T) {
+	configure := func(subscribe func(facts map[string]interface{})) *BurndownAnalysis {
+		facts := map[string]interface{}{}
+		bd := &BurndownAnalysis{}
+		require.NoError(t, bd.Configure(facts))
+		subscribe(facts)
+		require.NoError(t, bd.Initialize(test.Repository))
+		return bd
+	}
+	bd := configure(func(map[string]interface{}) {})
+	assert.Nil(t, bd.lines)
+	assert.Equal(t, 5, bd.packPersonWithTick(1, 5))
+
+	bd = configure(func(facts map[string]interface{}) { subscribeBurndownLines(facts, false) })
+	assert.NotNil(t, bd.lines)
+	assert.Equal(t, 5, bd.packPersonWithTick(1, 5))
+
+	bd = configure(func(facts map[string]interface{}) {
+		subscribeBurndownLines(facts, true)
+		subscribeBurndownLines(facts, false)
+	})
+	assert.NotNil(t, bd.lines)
+	author, tick := bd.unpackPersonWithTick(bd.packPersonWithTick(1, 5))
+	assert.Equal(t, [2]int{1, 5}, [2]int{author, tick})
+
+	// the line values of the checkpoint would be misinterpreted
+	buffer := &bytes.Buffer{}
+	require.NoError(t, bd.Checkpoint(buffer))
+	bd = configure(func(facts map[string]interface{}) { subscribeBurndownLines(facts, false) })
+	assert.Error(t, bd.Restore(buffer))
+}
+
+func TestBurndownSplitOwnership(t *testing.T) {
+	code := func(prefix string) string {
+		text := ""
+		for i := 0; i < 6; i++ {
+			text += fmt.Sprintf("func %s%d() int { return %d }\n", prefix, i, i)
+		}
+		return text
+	}
+	repo := newLeafRepository(
+		test.Commit{Files: map[string]string{"big.go": code("alpha") + code("beta")},
+			Author: "Alice", Email: "alice@example.com"},
+		test.Commit{Files: map[string]string{"big.go": "", "a.go": code("alpha"),
+			"b.go": code("beta") + "// split\n"},
+			Author: "Bob", Email: "bob@example.com"})
+	run := func(splits bool) BurndownResult {
+		return repo.Run(t, &BurndownAnalysis{}, repo.Commits, map[string]interface{}{
+			ConfigBurndownTrackFiles:                      true,
+			ConfigBurndownTrackPeople:                     true,
+			items.ConfigRenameAnalysisSimilarityThreshold: items.RenameAnalysisDefaultThreshold,
+			items.ConfigRenameAnalysisSplits:              splits,
+		}).(BurndownResult)
+	}
+	alive := func(history DenseHistory) int64 {
+		var sum int64
+		for _, lines := range history[len(history)-1] {
+			sum += lines
+		}
+		return sum
+	}
+	result := run(false)
+	assert.Equal(t, map[string]map[int]int{"a.go": {1: 6}, "b.go": {1: 7}}, result.FileOwnership)
+	assert.Equal(t, int64(0), alive(result.PeopleHistories[0]))
+	result = run(true)
+	assert.Equal(t, map[string]map[int]int{"a.go": {0: 6}, "b.go": {0: 6, 1: 1}}, result.FileOwnership)
+	// Alice's lines moved to the new files are still alive
+	assert.Equal(t, int64(12), alive(result.PeopleHistories[0]))
+	assert.Equal(t, int64(13), alive(result.GlobalHistory))
+}
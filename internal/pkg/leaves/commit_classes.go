@@ -0,0 +1,622 @@
+package leaves
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
+	"google.golang.org/protobuf/proto"
+)
+
+// CommitClassesAnalysis classifies every commit as a feature, a fix, a refactoring, a revert,
+// etc. The class is taken from, in this order: the merge flag, the revert message, the
+// Conventional Commits prefix, the shape of the diff (only renames, only docs or only tests)
+// and the first word of the message. It counts the classes per tick and per developer.
+type CommitClassesAnalysis struct {
+	core.NoopMerger
+	core.OneShotMergeProcessor
+
+	// state is shared between the forks.
+	state *commitClassesState
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+
+	l core.Logger
+}
+
+// commitClassesState is the accumulated classification of CommitClassesAnalysis.
+type commitClassesState struct {
+	Ticks   map[int]map[string]int
+	People  map[int]map[string]int
+	Reverts []CommitRevert
+	// Classes maps the commit hashes to their classes to resolve the reverted commits.
+	Classes map[string]string
+}
+
+// CommitClassesResult is returned by CommitClassesAnalysis.Finalize().
+type CommitClassesResult struct {
+	// Ticks maps the ticks to the classes to the numbers of commits.
+	Ticks map[int]map[string]int
+	// People maps the developers to the classes to the numbers of commits.
+	People map[int]map[string]int
+	// Reverts are the revert commits in the order of the analysis.
+	Reverts []CommitRevert
+
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+}
+
+// CommitRevert links a revert commit to the commit which it reverts.
+type CommitRevert struct {
+	// Commit is the hash of the revert commit.
+	Commit string
+	// Reverted is the hash of the reverted commit as written in the message.
+	Reverted string
+	// RevertedClass is the class of the reverted commit or an empty string if it was not analysed.
+	RevertedClass string
+	Tick          int
+	Author        int
+}
+
+// The commit classes.
+const (
+	CommitClassFeature  = "feature"
+	CommitClassFix      = "fix"
+	CommitClassRefactor = "refactor"
+	CommitClassDocs     = "docs"
+	CommitClassTest     = "test"
+	CommitClassPerf     = "perf"
+	CommitClassStyle    = "style"
+	CommitClassBuild    = "build"
+	CommitClassCI       = "ci"
+	CommitClassChore    = "chore"
+	CommitClassRevert   = "revert"
+	CommitClassMerge    = "merge"
+	CommitClassRename   = "rename"
+	CommitClassOther    = "other"
+)
+
+var (
+	conventionalCommitRE = regexp.MustCompile(`^([a-zA-Z]+)(\([^)]*\))?!?:\s`)
+	revertedCommitRE     = regexp.MustCompile(`(?m)^This reverts commit ([0-9a-f]{7,40})`)
+	revertSubjectRE      = regexp.MustCompile(`^Revert "`)
+	firstWordRE          = regexp.MustCompile(`^[a-zA-Z]+`)
+
+	// conventionalCommitTypes maps the Conventional Commits types to the classes.
+	conventionalCommitTypes = map[string]string{
+		"feat": CommitClassFeature, "feature": CommitClassFeature,
+		"fix": CommitClassFix, "bugfix": CommitClassFix, "hotfix": CommitClassFix,
+		"refactor": CommitClassRefactor,
+		"docs":     CommitClassDocs, "doc": CommitClassDocs,
+		"test": CommitClassTest, "tests": CommitClassTest,
+		"perf":  CommitClassPerf,
+		"style": CommitClassStyle,
+		"build": CommitClassBuild, "deps": CommitClassBuild,
+		"ci":     CommitClassCI,
+		"chore":  CommitClassChore,
+		"revert": CommitClassRevert,
+	}
+	// commitKeywords maps the lowercase first words of the messages to the classes.
+	commitKeywords = map[string]string{
+		"fix": CommitClassFix, "fixes": CommitClassFix, "fixed": CommitClassFix,
+		"bugfix": CommitClassFix, "hotfix": CommitClassFix,
+		"add": CommitClassFeature, "adds": CommitClassFeature, "added": CommitClassFeature,
+		"implement": CommitClassFeature, "implements": CommitClassFeature,
+		"implemented": CommitClassFeature, "introduce": CommitClassFeature,
+		"support":  CommitClassFeature,
+		"refactor": CommitClassRefactor, "refactored": CommitClassRefactor,
+		"refactoring": CommitClassRefactor, "cleanup": CommitClassRefactor,
+		"simplify": CommitClassRefactor,
+	}
+	docsExtensions = map[string]bool{
+		".md": true, ".markdown": true, ".rst": true, ".txt": true, ".adoc": true,
+		".asciidoc": true,
+	}
+	docsBaseNames   = []string{"readme", "license", "changelog", "authors", "contributing", "notice"}
+	testDirectories = map[string]bool{
+		"test": true, "tests": true, "__tests__": true, "spec": true, "testdata": true,
+	}
+)
+
+// Name of this PipelineItem. Uniquely identifies the type, used for mapping keys, etc.
+func (classes *CommitClassesAnalysis) Name() string {
+	return "CommitClasses"
+}
+
+// Provides returns the list of names of entities which are produced by this PipelineItem.
+// Each produced entity will be inserted into `deps` of dependent Consume()-s according
+// to this list. Also used by core.Registry to build the global map of providers.
+func (classes *CommitClassesAnalysis) Provides() []string {
+	return []string{}
+}
+
+// Requires returns the list of names of entities which are needed by this PipelineItem.
+// Each requested entity will be inserted into `deps` of Consume(). In turn, those
+// entities are Provides() upstream.
+func (classes *CommitClassesAnalysis) Requires() []string {
+	return []string{identity.DependencyAuthor, items.DependencyTick, items.DependencyTreeChanges}
+}
+
+// ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
+func (classes *CommitClassesAnalysis) ListConfigurationOptions() []core.ConfigurationOption {
+	return nil
+}
+
+// Configure sets the properties previously published by ListConfigurationOptions().
+func (classes *CommitClassesAnalysis) Configure(facts map[string]interface{}) error {
+	if l, exists := facts[core.ConfigLogger].(core.Logger); exists {
+		classes.l = l
+	}
+	if val, exists := facts[identity.FactIdentityDetectorReversedPeopleDict].([]string); exists {
+		classes.reversedPeopleDict = val
+	}
+	if val, exists := facts[items.FactTickSize].(time.Duration); exists {
+		classes.tickSize = val
+	}
+	return nil
+}
+
+// Flag for the command line switch which enables this analysis.
+func (classes *CommitClassesAnalysis) Flag() string {
+	return "commit-classes"
+}
+
+// Description returns the text which explains what the analysis is doing.
+func (classes *CommitClassesAnalysis) Description() string {
+	return "Classifies the commits into features, fixes, refactorings, reverts, etc. by their " +
+		"messages and diffs and counts the classes per tick and per developer."
+}
+
+// Initialize resets the temporary caches and prepares this PipelineItem for a series of Consume()
+// calls. The repository which is going to be analysed is supplied as an argument.
+func (classes *CommitClassesAnalysis) Initialize(repository *git.Repository) error {
+	classes.l = core.GetLogger()
+	classes.state = &commitClassesState{
+		Ticks:   map[int]map[string]int{},
+		People:  map[int]map[string]int{},
+		Classes: map[string]string{},
+	}
+	classes.OneShotMergeProcessor.Initialize()
+	return nil
+}
+
+// Consume runs this PipelineItem on the next commit data.
+// `deps` contain all the results from upstream PipelineItem-s as requested by Requires().
+// Additionally, DependencyCommit is always present there and represents the analysed *object.Commit.
+// This function returns the mapping with analysis results. The keys must be the same as
+// in Provides(). If there was an error, nil is returned.
+func (classes *CommitClassesAnalysis) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
+	if !classes.ShouldConsumeCommit(deps) {
+		return nil, nil
+	}
+	commit := deps[core.DependencyCommit].(*object.Commit)
+	author := deps[identity.DependencyAuthor].(int)
+	tick := deps[items.DependencyTick].(int)
+	treeDiff := deps[items.DependencyTreeChanges].(object.Changes)
+	class, reverted := classifyCommit(commit.Message, deps[core.DependencyIsMerge].(bool), treeDiff)
+	state := classes.state
+	hash := commit.Hash.String()
+	state.Classes[hash] = class
+	countCommitClass(state.Ticks, tick, class, 1)
+	if author != identity.AuthorMissing {
+		countCommitClass(state.People, author, class, 1)
+	}
+	if class == CommitClassRevert && reverted != "" {
+		state.Reverts = append(state.Reverts, CommitRevert{
+			Commit:        hash,
+			Reverted:      reverted,
+			RevertedClass: state.findClass(reverted),
+			Tick:          tick,
+			Author:        author,
+		})
+	}
+	return nil, nil
+}
+
+// findClass returns the class of the commit with the specified, possibly abbreviated, hash.
+func (state *commitClassesState) findClass(hash string) string {
+	if class, exists := state.Classes[hash]; exists || len(hash) == 40 {
+		return class
+	}
+	for key, class := range state.Classes {
+		if strings.HasPrefix(key, hash) {
+			return class
+		}
+	}
+	return ""
+}
+
+func countCommitClass(counts map[int]map[string]int, key int, class string, n int) {
+	classCounts := counts[key]
+	if classCounts == nil {
+		classCounts = map[string]int{}
+		counts[key] = classCounts
+	}
+	classCounts[class] += n
+}
+
+// classifyCommit returns the class of the commit and the hash of the reverted commit if the
+// message mentions it.
+func classifyCommit(message string, isMerge bool, changes object.Changes) (string, string) {
+	if isMerge {
+		return CommitClassMerge, ""
+	}
+	subject := strings.TrimSpace(strings.SplitN(strings.TrimSpace(message), "\n", 2)[0])
+	reverted := ""
+	if match := revertedCommitRE.FindStringSubmatch(message); match != nil {
+		reverted = match[1]
+	}
+	if reverted != "" || revertSubjectRE.MatchString(subject) {
+		return CommitClassRevert, reverted
+	}
+	if match := conventionalCommitRE.FindStringSubmatch(subject); match != nil {
+		if class, exists := conventionalCommitTypes[strings.ToLower(match[1])]; exists {
+			return class, ""
+		}
+	}
+	if class := classifyCommitDiff(changes); class != "" {
+		return class, ""
+	}
+	if class, exists := commitKeywords[strings.ToLower(firstWordRE.FindString(subject))]; exists {
+		return class, ""
+	}
+	return CommitClassOther, ""
+}
+
+// classifyCommitDiff returns CommitClassRename, CommitClassDocs or CommitClassTest if all the
+// changes are renames without modifications, documentation or tests, otherwise an empty string.
+func classifyCommitDiff(changes object.Changes) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	renames, docs, tests := true, true, true
+	for _, change := range changes {
+		name := change.To.Name
+		if action, err := change.Action(); err == nil && action == merkletrie.Delete {
+			name = change.From.Name
+		}
+		if change.From.Name == "" || change.To.Name == "" || change.From.Name == change.To.Name ||
+			change.From.TreeEntry.Hash != change.To.TreeEntry.Hash {
+			renames = false
+		}
+		docs = docs && isDocsFile(name)
+		tests = tests && isTestFile(name)
+	}
+	switch {
+	case renames:
+		return CommitClassRename
+	case docs:
+		return CommitClassDocs
+	case tests:
+		return CommitClassTest
+	}
+	return ""
+}
+
+// isDocsFile returns true if the path looks like documentation.
+func isDocsFile(name string) bool {
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if dir == "docs" || dir == "doc" {
+			return true
+		}
+	}
+	base := strings.ToLower(path.Base(name))
+	if docsExtensions[path.Ext(base)] {
+		return true
+	}
+	for _, prefix := range docsBaseNames {
+		if strings.HasPrefix(base, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTestFile returns true if the path looks like a test or a test fixture.
+func isTestFile(name string) bool {
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if testDirectories[dir] {
+			return true
+		}
+	}
+	base := strings.ToLower(path.Base(name))
+	stem := strings.TrimSuffix(base, path.Ext(base))
+	return strings.HasPrefix(base, "test_") || strings.HasSuffix(stem, "_test") ||
+		strings.HasSuffix(stem, ".test") || strings.HasSuffix(stem, ".spec") ||
+		strings.HasSuffix(stem, "_spec")
+}
+
+// Finalize returns the result of the analysis. Further Consume() calls are not expected.
+func (classes *CommitClassesAnalysis) Finalize() interface{} {
+	return CommitClassesResult{
+		Ticks:              classes.state.Ticks,
+		People:             classes.state.People,
+		Reverts:            classes.state.Reverts,
+		reversedPeopleDict: classes.reversedPeopleDict,
+		tickSize:           classes.tickSize,
+	}
+}
+
+// Checkpoint writes the classification state. It implements core.CheckpointablePipelineItem.
+func (classes *CommitClassesAnalysis) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, *classes.state)
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (classes *CommitClassesAnalysis) Restore(reader io.Reader) error {
+	state := commitClassesState{}
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
+	}
+	// gob does not transmit empty maps
+	if state.Ticks == nil {
+		state.Ticks = map[int]map[string]int{}
+	}
+	if state.People == nil {
+		state.People = map[int]map[string]int{}
+	}
+	if state.Classes == nil {
+		state.Classes = map[string]string{}
+	}
+	*classes.state = state
+	return nil
+}
+
+// Fork clones this pipeline item.
+func (classes *CommitClassesAnalysis) Fork(n int) []core.PipelineItem {
+	return core.ForkSamePipelineItem(classes, n)
+}
+
+// FixToFeatureRatio returns the number of fixes divided by the number of features in the tick,
+// or 0 if there are no features.
+func (result CommitClassesResult) FixToFeatureRatio(tick int) float64 {
+	counts := result.Ticks[tick]
+	if counts[CommitClassFeature] == 0 {
+		return 0
+	}
+	return float64(counts[CommitClassFix]) / float64(counts[CommitClassFeature])
+}
+
+// Serialize converts the analysis result as returned by Finalize() to text or bytes.
+// The text format is YAML and the bytes format is Protocol Buffers.
+func (classes *CommitClassesAnalysis) Serialize(result interface{}, binary bool, writer io.Writer) error {
+	classesResult, ok := result.(CommitClassesResult)
+	if !ok {
+		return fmt.Errorf("result is not a commit classes result: '%v'", result)
+	}
+	if binary {
+		return classes.serializeBinary(&classesResult, writer)
+	}
+	classes.serializeText(&classesResult, writer)
+	return nil
+}
+
+func sortedIntKeys(counts map[int]map[string]int) []int {
+	keys := make([]int, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func formatCommitClassCounts(counts map[string]int) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	formatted := make([]string, len(names))
+	for i, name := range names {
+		formatted[i] = fmt.Sprintf("%s: %d", name, counts[name])
+	}
+	return "{" + strings.Join(formatted, ", ") + "}"
+}
+
+func (classes *CommitClassesAnalysis) serializeText(result *CommitClassesResult, writer io.Writer) {
+	ticks := sortedIntKeys(result.Ticks)
+	fmt.Fprintln(writer, "  ticks:")
+	for _, tick := range ticks {
+		fmt.Fprintf(writer, "    %d: %s\n", tick, formatCommitClassCounts(result.Ticks[tick]))
+	}
+	fmt.Fprintln(writer, "  fix_to_feature:")
+	for _, tick := range ticks {
+		if result.Ticks[tick][CommitClassFeature] > 0 {
+			fmt.Fprintf(writer, "    %d: %.4f\n", tick, result.FixToFeatureRatio(tick))
+		}
+	}
+	fmt.Fprintln(writer, "  developers:")
+	for _, dev := range sortedIntKeys(result.People) {
+		fmt.Fprintf(writer, "    %d: %s\n", dev, formatCommitClassCounts(result.People[dev]))
+	}
+	fmt.Fprintln(writer, "  reverts:")
+	for _, revert := range result.Reverts {
+		author := revert.Author
+		if author == identity.AuthorMissing {
+			author = -1
+		}
+		fmt.Fprintf(writer, "    - {commit: %s, reverted: %s, reverted_class: %q, tick: %d, author: %d}\n",
+			revert.Commit, revert.Reverted, revert.RevertedClass, revert.Tick, author)
+	}
+	fmt.Fprintln(writer, "  people:")
+	for _, person := range result.reversedPeopleDict {
+		fmt.Fprintf(writer, "  - %s\n", person)
+	}
+	fmt.Fprintln(writer, "  tick_size:", int(result.tickSize.Seconds()))
+}
+
+func newCommitClassCountsMessages(counts map[int]map[string]int) map[int32]*pb.CommitClassCounts {
+	messages := make(map[int32]*pb.CommitClassCounts, len(counts))
+	for key, classCounts := range counts {
+		message := &pb.CommitClassCounts{Counts: make(map[string]int32, len(classCounts))}
+		for class, n := range classCounts {
+			message.Counts[class] = int32(n)
+		}
+		messages[int32(key)] = message
+	}
+	return messages
+}
+
+func newCommitClassCounts(messages map[int32]*pb.CommitClassCounts) map[int]map[string]int {
+	counts := make(map[int]map[string]int, len(messages))
+	for key, message := range messages {
+		classCounts := make(map[string]int, len(message.Counts))
+		for class, n := range message.Counts {
+			classCounts[class] = int(n)
+		}
+		counts[int(key)] = classCounts
+	}
+	return counts
+}
+
+func (classes *CommitClassesAnalysis) serializeBinary(result *CommitClassesResult, writer io.Writer) error {
+	message := pb.CommitClassesAnalysisResults{
+		Ticks:       newCommitClassCountsMessages(result.Ticks),
+		Developers:  newCommitClassCountsMessages(result.People),
+		Reverts:     make([]*pb.CommitRevert, len(result.Reverts)),
+		AuthorIndex: result.reversedPeopleDict,
+		TickSize:    int64(result.tickSize),
+	}
+	for i, revert := range result.Reverts {
+		author := revert.Author
+		if author == identity.AuthorMissing {
+			author = -1
+		}
+		message.Reverts[i] = &pb.CommitRevert{
+			Commit:        revert.Commit,
+			Reverted:      revert.Reverted,
+			RevertedClass: revert.RevertedClass,
+			Tick:          int32(revert.Tick),
+			Author:        int32(author),
+		}
+	}
+	serialized, err := proto.Marshal(&message)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(serialized)
+	return err
+}
+
+// Deserialize converts the specified protobuf bytes to CommitClassesResult.
+func (classes *CommitClassesAnalysis) Deserialize(pbmessage []byte) (interface{}, error) {
+	message := pb.CommitClassesAnalysisResults{}
+	if err := proto.Unmarshal(pbmessage, &message); err != nil {
+		return nil, err
+	}
+	result := CommitClassesResult{
+		Ticks:              newCommitClassCounts(message.Ticks),
+		People:             newCommitClassCounts(message.Developers),
+		reversedPeopleDict: message.AuthorIndex,
+		tickSize:           time.Duration(message.TickSize),
+	}
+	for _, revert := range message.Reverts {
+		author := int(revert.Author)
+		if author == -1 {
+			author = identity.AuthorMissing
+		}
+		result.Reverts = append(result.Reverts, CommitRevert{
+			Commit:        revert.Commit,
+			Reverted:      revert.Reverted,
+			RevertedClass: revert.RevertedClass,
+			Tick:          int(revert.Tick),
+			Author:        author,
+		})
+	}
+	return result, nil
+}
+
+// MergeResults combines two CommitClassesResult-s together. The ticks are aligned in time and
+// the counts of the same ticks and developers are summed.
+func (classes *CommitClassesAnalysis) MergeResults(
+	r1, r2 interface{}, c1, c2 *core.CommonAnalysisResult) interface{} {
+	cr1 := r1.(CommitClassesResult)
+	cr2 := r2.(CommitClassesResult)
+	if cr1.tickSize != cr2.tickSize {
+		return fmt.Errorf("mismatching tick sizes (r1: %d, r2: %d) received",
+			cr1.tickSize, cr2.tickSize)
+	}
+	if cr1.tickSize == 0 {
+		return errors.New("tick size is not set")
+	}
+	t01 := items.FloorTime(c1.BeginTimeAsTime(), cr1.tickSize)
+	t02 := items.FloorTime(c2.BeginTimeAsTime(), cr2.tickSize)
+	t0 := t01
+	if t02.Before(t0) {
+		t0 = t02
+	}
+	offset1 := int(t01.Sub(t0) / cr1.tickSize)
+	offset2 := int(t02.Sub(t0) / cr2.tickSize)
+
+	merged := CommitClassesResult{
+		Ticks:    map[int]map[string]int{},
+		People:   map[int]map[string]int{},
+		tickSize: cr1.tickSize,
+	}
+	var mergedIndex map[string]identity.MergedIndex
+	mergedIndex, merged.reversedPeopleDict = identity.MergeReversedDictsIdentities(
+		cr1.reversedPeopleDict, cr2.reversedPeopleDict)
+	for _, pair := range []struct {
+		result CommitClassesResult
+		offset int
+	}{{cr1, offset1}, {cr2, offset2}} {
+		remap := func(dev int) int {
+			if dev < 0 || dev >= len(pair.result.reversedPeopleDict) {
+				return identity.AuthorMissing
+			}
+			return mergedIndex[pair.result.reversedPeopleDict[dev]].Final
+		}
+		for tick, counts := range pair.result.Ticks {
+			for class, n := range counts {
+				countCommitClass(merged.Ticks, tick+pair.offset, class, n)
+			}
+		}
+		for dev, counts := range pair.result.People {
+			if dev = remap(dev); dev == identity.AuthorMissing {
+				continue
+			}
+			for class, n := range counts {
+				countCommitClass(merged.People, dev, class, n)
+			}
+		}
+		for _, revert := range pair.result.Reverts {
+			revert.Tick += pair.offset
+			revert.Author = remap(revert.Author)
+			merged.Reverts = append(merged.Reverts, revert)
+		}
+	}
+	return merged
+}
+
+// GetTickSize returns the tick size used to generate this commit classes analysis result.
+func (cr CommitClassesResult) GetTickSize() time.Duration {
+	return cr.tickSize
+}
+
+// GetIdentities returns the list of developer identities used to generate this commit classes
+// analysis result. The format is |-joined keys, see internals/plumbing/identity for details.
+func (cr CommitClassesResult) GetIdentities() []string {
+	return cr.reversedPeopleDict
+}
+
+var _ core.PipelineItem = (*CommitClassesAnalysis)(nil)
+
+func init() {
+	core.Registry.Register(&CommitClassesAnalysis{})
+}
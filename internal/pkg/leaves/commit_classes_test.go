@@ -0,0 +1,220 @@
+package leaves
+
+import (
+	"bytes"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitClassesMeta(t *testing.T) {
+	classes := &CommitClassesAnalysis{}
+	assert.Equal(t, "CommitClasses", classes.Name())
+	assert.Equal(t, "commit-classes", classes.Flag())
+	assert.Len(t, classes.Provides(), 0)
+	assert.Len(t, classes.Requires(), 3)
+	assert.Len(t, classes.ListConfigurationOptions(), 0)
+	assert.NoError(t, classes.Configure(map[string]interface{}{}))
+}
+
+func TestCommitClassesRegistration(t *testing.T) {
+	testLeafRegistration(t, &CommitClassesAnalysis{})
+}
+
+func commitClassesChange(from, to string, fromHash, toHash plumbing.Hash) *object.Change {
+	change := &object.Change{}
+	if from != "" {
+		change.From = object.ChangeEntry{Name: from, TreeEntry: object.TreeEntry{Name: path.Base(from), Hash: fromHash}}
+	}
+	if to != "" {
+		change.To = object.ChangeEntry{Name: to, TreeEntry: object.TreeEntry{Name: path.Base(to), Hash: toHash}}
+	}
+	return change
+}
+
+func TestClassifyCommit(t *testing.T) {
+	h1 := plumbing.NewHash("0000000000000000000000000000000000000001")
+	h2 := plumbing.NewHash("0000000000000000000000000000000000000002")
+	code := object.Changes{commitClassesChange("", "main.go", plumbing.ZeroHash, h1)}
+	cases := []struct {
+		message  string
+		merge    bool
+		changes  object.Changes
+		class    string
+		reverted string
+	}{
+		{"Merge branch 'x'", true, code, CommitClassMerge, ""},
+		{"Revert \"feat: x\"\n\nThis reverts commit 0123456789abcdef.\n", false, code,
+			CommitClassRevert, "0123456789abcdef"},
+		{"Revert \"something\"", false, code, CommitClassRevert, ""},
+		{"feat(api)!: add the endpoint", false, code, CommitClassFeature, ""},
+		{"fix: crash", false, code, CommitClassFix, ""},
+		{"Chore: bump", false, code, CommitClassChore, ""},
+		{"wip: stuff", false, code, CommitClassOther, ""},
+		{"move", false, object.Changes{commitClassesChange("a.go", "b/a.go", h1, h1)},
+			CommitClassRename, ""},
+		{"move", false, object.Changes{commitClassesChange("a.go", "b/a.go", h1, h2)},
+			CommitClassOther, ""},
+		{"typo", false, object.Changes{commitClassesChange("README.md", "README.md", h1, h2),
+			commitClassesChange("docs/api.html", "", h1, plumbing.ZeroHash)}, CommitClassDocs, ""},
+		{"more cases", false, object.Changes{commitClassesChange("", "x/x_test.go", plumbing.ZeroHash, h1),
+			commitClassesChange("", "test/fixture.json", plumbing.ZeroHash, h2)}, CommitClassTest, ""},
+		{"fix: the tests", false, object.Changes{commitClassesChange("", "x_test.go", plumbing.ZeroHash, h1)},
+			CommitClassFix, ""},
+		{"Fixed the crash", false, code, CommitClassFix, ""},
+		{"Implement caching", false, code, CommitClassFeature, ""},
+		{"", false, nil, CommitClassOther, ""},
+	}
+	for _, c := range cases {
+		class, reverted := classifyCommit(c.message, c.merge, c.changes)
+		assert.Equal(t, c.class, class, c.message)
+		assert.Equal(t, c.reverted, reverted, c.message)
+	}
+}
+
+func commitClassesRepository(revert string) *leafRepository {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []test.Commit{
+		{Author: "Alice", Email: "alice@example.com", When: start, Message: "feat: x",
+			Files: map[string]string{"x.go": "1\n2\n"}},
+		{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 1), Message: "fix: x",
+			Files: map[string]string{"x.go": "1\n3\n"}},
+		{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 1), Message: "Describe x",
+			Files: map[string]string{"README.md": "x\n"}, Parents: []int{0}},
+		{Author: "Alice", Email: "alice@example.com", When: start.AddDate(0, 0, 2), Message: "Merge",
+			Files: map[string]string{"README.md": "x\n"}, Parents: []int{1, 2}},
+	}
+	if revert != "" {
+		history = append(history, test.Commit{
+			Author: "Alice", Email: "alice@example.com", When: start.AddDate(0, 0, 2),
+			Message: "Revert \"fix: x\"\n\nThis reverts commit " + revert + ".\n",
+			Files:   map[string]string{"x.go": "1\n2\n"}})
+	}
+	return newLeafRepository(history...)
+}
+
+func TestCommitClassesPipeline(t *testing.T) {
+	fixHash := commitClassesRepository("").Commits[1].Hash.String()
+	repo := commitClassesRepository(fixHash[:10])
+	commits := repo.Commits
+	require.Equal(t, fixHash, commits[1].Hash.String())
+	result := repo.Run(t, &CommitClassesAnalysis{}, commits, nil).(CommitClassesResult)
+	assert.Equal(t, []string{"alice|alice@example.com", "bob|bob@example.com"}, result.reversedPeopleDict)
+	assert.Equal(t, map[int]map[string]int{
+		0: {CommitClassFeature: 1},
+		1: {CommitClassFix: 1, CommitClassDocs: 1},
+		2: {CommitClassMerge: 1, CommitClassRevert: 1},
+	}, result.Ticks)
+	assert.Equal(t, map[int]map[string]int{
+		0: {CommitClassFeature: 1, CommitClassMerge: 1, CommitClassRevert: 1},
+		1: {CommitClassFix: 1, CommitClassDocs: 1},
+	}, result.People)
+	assert.Equal(t, []CommitRevert{{
+		Commit: commits[4].Hash.String(), Reverted: fixHash[:10], RevertedClass: CommitClassFix,
+		Tick: 2, Author: 0,
+	}}, result.Reverts)
+	assert.Equal(t, 0.0, result.FixToFeatureRatio(1))
+	result.Ticks[1][CommitClassFeature] = 2
+	assert.InDelta(t, 0.5, result.FixToFeatureRatio(1), 1e-9)
+}
+
+func TestCommitClassesRevertNotAnalysed(t *testing.T) {
+	repo := commitClassesRepository("0123456789")
+	result := repo.Run(t, &CommitClassesAnalysis{}, repo.Commits, nil).(CommitClassesResult)
+	assert.Equal(t, []CommitRevert{{
+		Commit: repo.Commits[4].Hash.String(), Reverted: "0123456789", Tick: 2, Author: 0,
+	}}, result.Reverts)
+}
+
+func TestCommitClassesFindClass(t *testing.T) {
+	state := &commitClassesState{Classes: map[string]string{
+		"0123456789012345678901234567890123456789": CommitClassFix,
+		"abcdefabcdefabcdefabcdefabcdefabcdefabcd": CommitClassFeature,
+	}}
+	assert.Equal(t, CommitClassFix, state.findClass("0123456789012345678901234567890123456789"))
+	// the messages may abbreviate the reverted hash
+	assert.Equal(t, CommitClassFeature, state.findClass("abcdefa"))
+	assert.Equal(t, "", state.findClass("abcdef0"))
+	// the full hashes must match exactly
+	assert.Equal(t, "", state.findClass("0123456789012345678901234567890123456780"))
+}
+
+func TestCommitClassesCheckpoint(t *testing.T) {
+	repo := commitClassesRepository(commitClassesRepository("").Commits[1].Hash.String())
+	full := repo.Run(t, &CommitClassesAnalysis{}, repo.Commits, nil).(CommitClassesResult)
+	resumed := repo.Resume(t, &CommitClassesAnalysis{}, 4, nil).(CommitClassesResult)
+	assert.Equal(t, full.Ticks, resumed.Ticks)
+	assert.Equal(t, full.People, resumed.People)
+	// the class of the reverted commit is restored from the checkpoint
+	assert.Equal(t, full.Reverts, resumed.Reverts)
+	assert.Equal(t, CommitClassFix, resumed.Reverts[0].RevertedClass)
+}
+
+func TestCommitClassesSerialize(t *testing.T) {
+	result := CommitClassesResult{
+		Ticks: map[int]map[string]int{
+			0: {CommitClassFeature: 2, CommitClassFix: 1},
+			3: {CommitClassRevert: 1},
+		},
+		People: map[int]map[string]int{0: {CommitClassFeature: 2, CommitClassFix: 1, CommitClassRevert: 1}},
+		Reverts: []CommitRevert{
+			{Commit: "aaa", Reverted: "bbb", RevertedClass: CommitClassFeature, Tick: 3, Author: 0}},
+		reversedPeopleDict: []string{"alice"},
+		tickSize:           24 * time.Hour,
+	}
+	classes := &CommitClassesAnalysis{}
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, classes.Serialize(result, false, buffer))
+	assert.Equal(t, `  ticks:
+    0: {feature: 2, fix: 1}
+    3: {revert: 1}
+  fix_to_feature:
+    0: 0.5000
+  developers:
+    0: {feature: 2, fix: 1, revert: 1}
+  reverts:
+    - {commit: aaa, reverted: bbb, reverted_class: "feature", tick: 3, author: 0}
+  people:
+  - alice
+  tick_size: 86400
+`, buffer.String())
+
+	testLeafBinaryRoundTrip(t, classes, result)
+}
+
+func TestCommitClassesMergeResults(t *testing.T) {
+	r1 := CommitClassesResult{
+		Ticks:              map[int]map[string]int{0: {CommitClassFeature: 1}, 1: {CommitClassFix: 1}},
+		People:             map[int]map[string]int{0: {CommitClassFeature: 1}, 1: {CommitClassFix: 1}},
+		reversedPeopleDict: []string{"alice", "bob"},
+		tickSize:           24 * time.Hour,
+	}
+	r2 := CommitClassesResult{
+		Ticks:              map[int]map[string]int{0: {CommitClassFix: 1, CommitClassRevert: 1}},
+		People:             map[int]map[string]int{0: {CommitClassFix: 1, CommitClassRevert: 1}},
+		Reverts:            []CommitRevert{{Commit: "aaa", Reverted: "bbb", Tick: 0, Author: 0}},
+		reversedPeopleDict: []string{"bob"},
+		tickSize:           24 * time.Hour,
+	}
+	c1 := core.CommonAnalysisResult{BeginTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+	c2 := core.CommonAnalysisResult{BeginTime: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix()}
+	merged := (&CommitClassesAnalysis{}).MergeResults(r1, r2, &c1, &c2).(CommitClassesResult)
+	assert.Equal(t, []string{"alice", "bob"}, merged.reversedPeopleDict)
+	// r2 starts one tick later and its bob is r1's bob
+	assert.Equal(t, map[int]map[string]int{
+		0: {CommitClassFeature: 1},
+		1: {CommitClassFix: 2, CommitClassRevert: 1},
+	}, merged.Ticks)
+	assert.Equal(t, merged.Ticks, merged.People)
+	assert.Equal(t, []CommitRevert{{Commit: "aaa", Reverted: "bbb", Tick: 1, Author: 1}}, merged.Reverts)
+
+	r2.tickSize = time.Hour
+	assert.Error(t, (&CommitClassesAnalysis{}).MergeResults(r1, r2, &c1, &c2).(error))
+}
@@ -69,7 +69,8 @@ func (ca *CommitsAnalysis) Provides() []string {
 // entities are Provides() upstream.
 func (ca *CommitsAnalysis) Requires() []string {
 	return []string{
-		identity.DependencyAuthor, items.DependencyLanguages, items.DependencyLineStats}
+		identity.DependencyAuthor, items.DependencyLanguages, items.DependencyLineStats,
+		identity.DependencyAuthorExcluded}
 }
 
 // ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
@@ -114,6 +115,9 @@ func (ca *CommitsAnalysis) Consume(deps map[string]interface{}) (map[string]inte
 	if deps[core.DependencyIsMerge].(bool) {
 		return nil, nil
 	}
+	if excluded, _ := deps[identity.DependencyAuthorExcluded].(bool); excluded {
+		return nil, nil
+	}
 	commit := deps[core.DependencyCommit].(*object.Commit)
 	author := deps[identity.DependencyAuthor].(int)
 	lineStats := deps[items.DependencyLineStats].(map[object.ChangeEntry]items.LineStats)
@@ -142,6 +146,16 @@ func (ca *CommitsAnalysis) Finalize() interface{} {
 	}
 }
 
+// Checkpoint writes the collected commit stats. It implements core.CheckpointablePipelineItem.
+func (ca *CommitsAnalysis) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, ca.commits)
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (ca *CommitsAnalysis) Restore(reader io.Reader) error {
+	return core.DecodeCheckpoint(reader, &ca.commits)
+}
+
 // Fork clones this pipeline item.
 func (ca *CommitsAnalysis) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(ca, n)
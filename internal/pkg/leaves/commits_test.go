@@ -21,7 +21,8 @@ func TestCommitsMeta(t *testing.T) {
 	ca := CommitsAnalysis{}
 	assert.Equal(t, ca.Name(), "CommitsStat")
 	assert.Len(t, ca.Provides(), 0)
-	required := [...]string{identity.DependencyAuthor, items.DependencyLanguages, items.DependencyLineStats}
+	required := [...]string{identity.DependencyAuthor, items.DependencyLanguages, items.DependencyLineStats,
+		identity.DependencyAuthorExcluded}
 	for _, name := range required {
 		assert.Contains(t, ca.Requires(), name)
 	}
@@ -120,12 +121,11 @@ func TestCommitsConsume(t *testing.T) {
 	},
 	}
 	deps[items.DependencyTreeChanges] = changes
-	fd := fixtures.NewFileDiff()
+	fd := fixtures.FileDiff()
 	result, err := fd.Consume(deps)
 	assert.Nil(t, err)
 	deps[items.DependencyFileDiff] = result[items.DependencyFileDiff]
-	deps[core.DependencyCommit], _ = test.Repository.CommitObject(plumbing.NewHash(
-		"cce947b98a050c6d356bc6ba95030254914027b1"))
+	deps[core.DependencyCommit] = test.FixtureCommit(t, "cce947b98a050c6d356bc6ba95030254914027b1")
 	deps[core.DependencyIsMerge] = false
 	lsc := &items.LinesStatsCalculator{}
 	lscres, err := lsc.Consume(deps)
@@ -241,6 +241,19 @@ func fixtureCommits() *CommitsAnalysis {
 	return &ca
 }
 
+func TestCommitsConsumeExcluded(t *testing.T) {
+	ca := CommitsAnalysis{}
+	assert.Nil(t, ca.Initialize(test.Repository))
+	result, err := ca.Consume(map[string]interface{}{
+		core.DependencyIsMerge:            false,
+		identity.DependencyAuthor:         identity.AuthorMissing,
+		identity.DependencyAuthorExcluded: true,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, result)
+	assert.Len(t, ca.commits, 0)
+}
+
 func TestCommitsFinalize(t *testing.T) {
 	ca := fixtureCommits()
 	x := ca.Finalize().(CommitsResult)
@@ -10,6 +10,7 @@ import (
 	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
 	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
 	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
 	"google.golang.org/protobuf/proto"
@@ -23,6 +24,16 @@ type CouplesAnalysis struct {
 	core.OneShotMergeProcessor
 	// PeopleNumber is the number of developers for which to build the matrix. 0 disables this analysis.
 	PeopleNumber int
+	// WindowTicks limits the file coupling metrics to the commits from the last WindowTicks ticks.
+	// 0 means the whole history.
+	WindowTicks int
+	// MaxCommitFiles is the maximum number of files changed in a commit to consider them as
+	// coupled. Larger commits are ignored by the file coupling.
+	MaxCommitFiles int
+	// MinCommits is the minimum number of common commits for a file pair to be reported.
+	MinCommits int
+	// MinConfidence is the minimum confidence of either rule of a file pair to be reported.
+	MinConfidence float32
 
 	// people store how many times every developer committed to every file.
 	people []map[string]int
@@ -32,10 +43,14 @@ type CouplesAnalysis struct {
 	files map[string]map[string]int
 	// renames point from new file name to old file name.
 	renames *[]rename
+	// temporal counts the commits which changed the files together, shared between the forks.
+	temporal *temporalCoupling
 	// lastCommit is the last commit which was consumed.
 	lastCommit *object.Commit
 	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
 	reversedPeopleDict []string
+	// repository is used to restore lastCommit from a checkpoint
+	repository *git.Repository
 
 	l core.Logger
 }
@@ -56,15 +71,67 @@ type CouplesResult struct {
 	FilesLines []int
 	// Files is the names of the files. The order matches PeopleFiles' indexes and FilesMatrix.
 	Files []string
+	// FilePairs are the strongly coupled file pairs sorted by the number of common commits.
+	FilePairs []FileCoupling
+	// Commits is the number of commits from which FilePairs were calculated.
+	Commits int
+	// WindowTicks is the number of last ticks from which FilePairs were calculated, 0 means all.
+	WindowTicks int
 
 	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
 	reversedPeopleDict []string
 }
 
+// FileCoupling is the association rule between two files which were changed together.
+type FileCoupling struct {
+	// First and Second are the indexes in CouplesResult.Files, First < Second.
+	First, Second int
+	// Commits is the number of commits which changed both files.
+	Commits int
+	// FirstCommits and SecondCommits are the numbers of commits which changed each file.
+	FirstCommits, SecondCommits int
+	// Support is the share of all the commits which changed both files.
+	Support float64
+	// Confidence is the probability that a commit which changed First also changed Second.
+	Confidence float64
+	// ReverseConfidence is the probability that a commit which changed Second also changed First.
+	ReverseConfidence float64
+	// Lift is how many times more often the files change together than if they were independent.
+	Lift float64
+}
+
+// temporalCoupling tracks the commits for the file coupling metrics.
+type temporalCoupling struct {
+	// Commits is the number of commits which changed at least one file.
+	Commits int
+	// LastTick is the tick of the last consumed commit.
+	LastTick int
+	// Window is the files changed by each commit inside the time window.
+	Window []couplesContext
+}
+
+// couplesContext is the list of files changed by a single commit.
+type couplesContext struct {
+	Tick  int
+	Files []string
+}
+
 const (
 	// CouplesMaximumMeaningfulContextSize is the threshold on the number of files in a commit to
 	// consider them as grouped together.
 	CouplesMaximumMeaningfulContextSize = 1000
+	// ConfigCouplesWindowTicks is the name of the option to set CouplesAnalysis.WindowTicks.
+	ConfigCouplesWindowTicks = "Couples.WindowTicks"
+	// ConfigCouplesMaxCommitFiles is the name of the option to set CouplesAnalysis.MaxCommitFiles.
+	ConfigCouplesMaxCommitFiles = "Couples.MaxCommitFiles"
+	// ConfigCouplesMinCommits is the name of the option to set CouplesAnalysis.MinCommits.
+	ConfigCouplesMinCommits = "Couples.MinCommits"
+	// ConfigCouplesMinConfidence is the name of the option to set CouplesAnalysis.MinConfidence.
+	ConfigCouplesMinConfidence = "Couples.MinConfidence"
+	// DefaultCouplesMinCommits is the default value of CouplesAnalysis.MinCommits.
+	DefaultCouplesMinCommits = 2
+	// DefaultCouplesMinConfidence is the default value of CouplesAnalysis.MinConfidence.
+	DefaultCouplesMinConfidence = float32(0.5)
 )
 
 type rename struct {
@@ -88,12 +155,37 @@ func (couples *CouplesAnalysis) Provides() []string {
 // Each requested entity will be inserted into `deps` of Consume(). In turn, those
 // entities are Provides() upstream.
 func (couples *CouplesAnalysis) Requires() []string {
-	return []string{identity.DependencyAuthor, items.DependencyTreeChanges}
+	return []string{
+		identity.DependencyAuthor, items.DependencyTreeChanges, items.DependencyTick,
+		identity.DependencyAuthorExcluded}
 }
 
 // ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
 func (couples *CouplesAnalysis) ListConfigurationOptions() []core.ConfigurationOption {
-	return []core.ConfigurationOption{}
+	options := [...]core.ConfigurationOption{{
+		Name:        ConfigCouplesWindowTicks,
+		Description: "Calculate the file coupling metrics over the last N ticks; 0 means the whole history.",
+		Flag:        "couples-window",
+		Type:        core.IntConfigurationOption,
+		Default:     0}, {
+		Name:        ConfigCouplesMaxCommitFiles,
+		Description: "Ignore the commits which change more files in the file coupling.",
+		Flag:        "couples-max-commit-files",
+		Type:        core.IntConfigurationOption,
+		Default:     CouplesMaximumMeaningfulContextSize}, {
+		Name:        ConfigCouplesMinCommits,
+		Description: "Minimum number of common commits of the reported file pairs.",
+		Flag:        "couples-min-commits",
+		Type:        core.IntConfigurationOption,
+		Default:     DefaultCouplesMinCommits}, {
+		Name: ConfigCouplesMinConfidence,
+		Description: "Minimum confidence of the reported file pairs: the share of the commits " +
+			"which changed one file and also changed the other.",
+		Flag:    "couples-min-confidence",
+		Type:    core.FloatConfigurationOption,
+		Default: DefaultCouplesMinConfidence},
+	}
+	return options[:]
 }
 
 // Configure sets the properties previously published by ListConfigurationOptions().
@@ -105,6 +197,18 @@ func (couples *CouplesAnalysis) Configure(facts map[string]interface{}) error {
 		couples.PeopleNumber = val
 		couples.reversedPeopleDict = facts[identity.FactIdentityDetectorReversedPeopleDict].([]string)
 	}
+	if val, exists := facts[ConfigCouplesWindowTicks].(int); exists {
+		couples.WindowTicks = val
+	}
+	if val, exists := facts[ConfigCouplesMaxCommitFiles].(int); exists {
+		couples.MaxCommitFiles = val
+	}
+	if val, exists := facts[ConfigCouplesMinCommits].(int); exists {
+		couples.MinCommits = val
+	}
+	if val, exists := facts[ConfigCouplesMinConfidence].(float32); exists {
+		couples.MinConfidence = val
+	}
 	return nil
 }
 
@@ -117,13 +221,21 @@ func (couples *CouplesAnalysis) Flag() string {
 func (couples *CouplesAnalysis) Description() string {
 	return "The result is a square matrix, the value in each cell corresponds to the number " +
 		"of times the pair of files appeared in the same commit or pair of developers " +
-		"committed to the same file."
+		"committed to the same file. Strongly coupled file pairs are additionally listed " +
+		"with their support, confidence and lift."
 }
 
 // Initialize resets the temporary caches and prepares this PipelineItem for a series of Consume()
 // calls. The repository which is going to be analysed is supplied as an argument.
 func (couples *CouplesAnalysis) Initialize(repository *git.Repository) error {
 	couples.l = core.GetLogger()
+	if couples.WindowTicks < 0 {
+		return fmt.Errorf("the coupling window must not be negative: %d", couples.WindowTicks)
+	}
+	if couples.MaxCommitFiles <= 0 {
+		couples.MaxCommitFiles = CouplesMaximumMeaningfulContextSize
+	}
+	couples.repository = repository
 	couples.people = make([]map[string]int, couples.PeopleNumber+1)
 	for i := range couples.people {
 		couples.people[i] = map[string]int{}
@@ -131,6 +243,7 @@ func (couples *CouplesAnalysis) Initialize(repository *git.Repository) error {
 	couples.peopleCommits = make([]int, couples.PeopleNumber+1)
 	couples.files = map[string]map[string]int{}
 	couples.renames = &[]rename{}
+	couples.temporal = &temporalCoupling{}
 	couples.OneShotMergeProcessor.Initialize()
 	return nil
 }
@@ -148,7 +261,9 @@ func (couples *CouplesAnalysis) Consume(deps map[string]interface{}) (map[string
 	if author == identity.AuthorMissing {
 		author = couples.PeopleNumber
 	}
-	if firstMerge {
+	// the excluded bots still couple the files but not the people
+	excluded, _ := deps[identity.DependencyAuthorExcluded].(bool)
+	if firstMerge && !excluded {
 		couples.peopleCommits[author]++
 	}
 	treeDiff := deps[items.DependencyTreeChanges].(object.Changes)
@@ -164,10 +279,12 @@ func (couples *CouplesAnalysis) Consume(deps map[string]interface{}) (map[string
 		case merkletrie.Insert:
 			if !mergeMode || couples.files[toName] == nil {
 				context = append(context, toName)
-				couples.people[author][toName]++
+				if !excluded {
+					couples.people[author][toName]++
+				}
 			}
 		case merkletrie.Delete:
-			if !mergeMode {
+			if !mergeMode && !excluded {
 				couples.people[author][fromName]++
 			}
 		case merkletrie.Modify:
@@ -178,11 +295,17 @@ func (couples *CouplesAnalysis) Consume(deps map[string]interface{}) (map[string
 			}
 			if !mergeMode || couples.files[toName] == nil {
 				context = append(context, toName)
-				couples.people[author][toName]++
+				if !excluded {
+					couples.people[author][toName]++
+				}
 			}
 		}
 	}
-	if len(context) <= CouplesMaximumMeaningfulContextSize {
+	if len(context) > 0 && len(context) <= couples.MaxCommitFiles {
+		couples.temporal.Commits++
+		if couples.WindowTicks > 0 {
+			couples.appendWindow(deps[items.DependencyTick].(int), context)
+		}
 		for _, file := range context {
 			for _, otherFile := range context {
 				lane, exists := couples.files[file]
@@ -197,9 +320,25 @@ func (couples *CouplesAnalysis) Consume(deps map[string]interface{}) (map[string
 	return nil, nil
 }
 
+// appendWindow records the files changed by the commit and forgets the commits which
+// fell out of the time window.
+func (couples *CouplesAnalysis) appendWindow(tick int, context []string) {
+	temporal := couples.temporal
+	if tick > temporal.LastTick {
+		temporal.LastTick = tick
+	}
+	temporal.Window = append(temporal.Window, couplesContext{Tick: tick, Files: context})
+	i := 0
+	for i < len(temporal.Window) && temporal.Window[i].Tick <= temporal.LastTick-couples.WindowTicks {
+		i++
+	}
+	temporal.Window = temporal.Window[i:]
+}
+
 // Finalize returns the result of the analysis. Further Consume() calls are not expected.
 func (couples *CouplesAnalysis) Finalize() interface{} {
-	files, people := couples.propagateRenames(couples.currentFiles())
+	currentFiles := couples.currentFiles()
+	files, people := couples.propagateRenames(currentFiles)
 	filesSequence := make([]string, len(files))
 	i := 0
 	for file := range files {
@@ -260,21 +399,182 @@ func (couples *CouplesAnalysis) Finalize() interface{} {
 			filesMatrix[i][filesIndex[otherFile]] = int64(cooccs)
 		}
 	}
+
+	cooccs, commits := files, couples.temporal.Commits
+	if couples.WindowTicks > 0 {
+		cooccs, commits = couples.windowCooccurrences(currentFiles)
+	}
 	return CouplesResult{
 		PeopleMatrix:       peopleMatrix,
 		PeopleFiles:        peopleFiles,
 		Files:              filesSequence,
 		FilesLines:         filesLines,
 		FilesMatrix:        filesMatrix,
+		FilePairs:          couples.filePairs(cooccs, filesSequence, commits),
+		Commits:            commits,
+		WindowTicks:        couples.WindowTicks,
 		reversedPeopleDict: couples.reversedPeopleDict,
 	}
 }
 
+// windowCooccurrences counts the co-occurrences of the files in the commits inside the time window
+// and returns them together with the number of those commits.
+func (couples *CouplesAnalysis) windowCooccurrences(files map[string]bool) (
+	map[string]map[string]int, int) {
+	cooccs := map[string]map[string]int{}
+	commits := 0
+	for _, context := range couples.temporal.Window {
+		if context.Tick <= couples.temporal.LastTick-couples.WindowTicks {
+			continue
+		}
+		commits++
+		for _, file := range context.Files {
+			lane, exists := cooccs[file]
+			if !exists {
+				lane = map[string]int{}
+				cooccs[file] = lane
+			}
+			for _, otherFile := range context.Files {
+				lane[otherFile]++
+			}
+		}
+	}
+	reduced, _, _ := couples.reduceRenamedFiles(files, cooccs)
+	return reduced, commits
+}
+
+// filePairs calculates the association rules between the files which were changed together
+// and keeps the pairs above MinCommits and MinConfidence. The diagonal of `cooccs` is
+// the number of commits which changed each file.
+func (couples *CouplesAnalysis) filePairs(
+	cooccs map[string]map[string]int, filesSequence []string, commits int) []FileCoupling {
+	pairs := []FileCoupling{}
+	if commits == 0 {
+		return pairs
+	}
+	for i, file := range filesSequence {
+		lane := cooccs[file]
+		firstCommits := lane[file]
+		if firstCommits == 0 {
+			continue
+		}
+		for j := i + 1; j < len(filesSequence); j++ {
+			otherFile := filesSequence[j]
+			common := lane[otherFile]
+			secondCommits := cooccs[otherFile][otherFile]
+			if common == 0 || common < couples.MinCommits || secondCommits == 0 {
+				continue
+			}
+			pair := newFileCoupling(i, j, common, firstCommits, secondCommits, commits)
+			if pair.Confidence < float64(couples.MinConfidence) &&
+				pair.ReverseConfidence < float64(couples.MinConfidence) {
+				continue
+			}
+			pairs = append(pairs, pair)
+		}
+	}
+	sortFileCouplings(pairs)
+	return pairs
+}
+
+// newFileCoupling calculates the support, confidence and lift of a file pair.
+func newFileCoupling(first, second, common, firstCommits, secondCommits, commits int) FileCoupling {
+	return FileCoupling{
+		First:             first,
+		Second:            second,
+		Commits:           common,
+		FirstCommits:      firstCommits,
+		SecondCommits:     secondCommits,
+		Support:           float64(common) / float64(commits),
+		Confidence:        float64(common) / float64(firstCommits),
+		ReverseConfidence: float64(common) / float64(secondCommits),
+		Lift:              float64(common) * float64(commits) / (float64(firstCommits) * float64(secondCommits)),
+	}
+}
+
+func sortFileCouplings(pairs []FileCoupling) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Commits != pairs[j].Commits {
+			return pairs[i].Commits > pairs[j].Commits
+		}
+		if pairs[i].First != pairs[j].First {
+			return pairs[i].First < pairs[j].First
+		}
+		return pairs[i].Second < pairs[j].Second
+	})
+}
+
 // Fork clones this pipeline item.
 func (couples *CouplesAnalysis) Fork(n int) []core.PipelineItem {
 	return core.ForkCopyPipelineItem(couples, n)
 }
 
+// couplesCheckpoint is the state of CouplesAnalysis saved in core.Checkpoint.
+type couplesCheckpoint struct {
+	PeopleNumber  int
+	People        []map[string]int
+	PeopleCommits []int
+	Files         map[string]map[string]int
+	Renames       []rename
+	LastCommit    string
+	Temporal      temporalCoupling
+}
+
+// Checkpoint writes the co-occurrence counters. It implements core.CheckpointablePipelineItem.
+func (couples *CouplesAnalysis) Checkpoint(writer io.Writer) error {
+	state := couplesCheckpoint{
+		PeopleNumber:  couples.PeopleNumber,
+		People:        couples.people,
+		PeopleCommits: couples.peopleCommits,
+		Files:         couples.files,
+		Renames:       *couples.renames,
+		Temporal:      *couples.temporal,
+	}
+	if couples.lastCommit != nil {
+		state.LastCommit = couples.lastCommit.Hash.String()
+	}
+	return core.EncodeCheckpoint(writer, state)
+}
+
+// Restore loads the state written by Checkpoint(). The number of people may only grow.
+// It implements core.CheckpointablePipelineItem.
+func (couples *CouplesAnalysis) Restore(reader io.Reader) error {
+	state := couplesCheckpoint{}
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
+	}
+	if state.PeopleNumber > couples.PeopleNumber || len(state.People) != state.PeopleNumber+1 ||
+		len(state.PeopleCommits) != state.PeopleNumber+1 {
+		return fmt.Errorf("the number of people changed from %d to %d",
+			state.PeopleNumber, couples.PeopleNumber)
+	}
+	// the last element belongs to the unmatched authors
+	for i := 0; i < state.PeopleNumber; i++ {
+		couples.people[i] = state.People[i]
+		couples.peopleCommits[i] = state.PeopleCommits[i]
+	}
+	couples.people[couples.PeopleNumber] = state.People[state.PeopleNumber]
+	couples.peopleCommits[couples.PeopleNumber] = state.PeopleCommits[state.PeopleNumber]
+	for i, files := range couples.people {
+		if files == nil {
+			couples.people[i] = map[string]int{}
+		}
+	}
+	if state.Files != nil {
+		couples.files = state.Files
+	}
+	*couples.renames = state.Renames
+	*couples.temporal = state.Temporal
+	if state.LastCommit != "" {
+		commit, err := couples.repository.CommitObject(plumbing.NewHash(state.LastCommit))
+		if err != nil {
+			return err
+		}
+		couples.lastCommit = commit
+	}
+	return nil
+}
+
 // Serialize converts the analysis result as returned by Finalize() to text or bytes.
 // The text format is YAML and the bytes format is Protocol Buffers.
 func (couples *CouplesAnalysis) Serialize(result interface{}, binary bool, writer io.Writer) error {
@@ -299,8 +599,24 @@ func (couples *CouplesAnalysis) Deserialize(pbmessage []byte) (interface{}, erro
 		FilesMatrix:        make([]map[int]int64, message.FileCouples.Matrix.NumberOfRows),
 		PeopleFiles:        make([][]int, len(message.PeopleCouples.Index)),
 		PeopleMatrix:       make([]map[int]int64, message.PeopleCouples.Matrix.NumberOfRows),
+		FilePairs:          make([]FileCoupling, len(message.FilePairs)),
+		Commits:            int(message.Commits),
+		WindowTicks:        int(message.WindowTicks),
 		reversedPeopleDict: message.PeopleCouples.Index,
 	}
+	for i, pair := range message.FilePairs {
+		result.FilePairs[i] = FileCoupling{
+			First:             int(pair.First),
+			Second:            int(pair.Second),
+			Commits:           int(pair.Commits),
+			FirstCommits:      int(pair.FirstCommits),
+			SecondCommits:     int(pair.SecondCommits),
+			Support:           pair.Support,
+			Confidence:        pair.Confidence,
+			ReverseConfidence: pair.ReverseConfidence,
+			Lift:              pair.Lift,
+		}
+	}
 	for i, files := range message.PeopleFiles {
 		result.PeopleFiles[i] = make([]int, len(files.Files))
 		for j, val := range files.Files {
@@ -421,6 +737,51 @@ func (couples *CouplesAnalysis) MergeResults(r1, r2 interface{}, c1, c2 *core.Co
 	}
 	addFiles(cr1.FilesMatrix, cr1.Files)
 	addFiles(cr2.FilesMatrix, cr2.Files)
+	merged.Commits = cr1.Commits + cr2.Commits
+	merged.WindowTicks = cr1.WindowTicks
+	if cr2.WindowTicks > merged.WindowTicks {
+		merged.WindowTicks = cr2.WindowTicks
+	}
+	merged.FilePairs = mergeFileCouplings(
+		cr1.FilePairs, cr1.Files, cr2.FilePairs, cr2.Files, files, merged.Commits)
+	return merged
+}
+
+// mergeFileCouplings sums the commit counts of the same file pairs from two results
+// and recalculates the metrics over the combined number of commits.
+func mergeFileCouplings(pairs1 []FileCoupling, files1 []string, pairs2 []FileCoupling,
+	files2 []string, files map[string]identity.MergedIndex, commits int) []FileCoupling {
+	type counts struct {
+		common, first, second int
+	}
+	sums := map[[2]int]*counts{}
+	add := func(pairs []FileCoupling, reversedFilesDict []string) {
+		for _, pair := range pairs {
+			first := files[reversedFilesDict[pair.First]].Final
+			second := files[reversedFilesDict[pair.Second]].Final
+			firstCommits, secondCommits := pair.FirstCommits, pair.SecondCommits
+			if first > second {
+				first, second = second, first
+				firstCommits, secondCommits = secondCommits, firstCommits
+			}
+			sum := sums[[2]int{first, second}]
+			if sum == nil {
+				sum = &counts{}
+				sums[[2]int{first, second}] = sum
+			}
+			sum.common += pair.Commits
+			sum.first += firstCommits
+			sum.second += secondCommits
+		}
+	}
+	add(pairs1, files1)
+	add(pairs2, files2)
+	merged := make([]FileCoupling, 0, len(sums))
+	for key, sum := range sums {
+		merged = append(merged, newFileCoupling(
+			key[0], key[1], sum.common, sum.first, sum.second, commits))
+	}
+	sortFileCouplings(merged)
 	return merged
 }
 
@@ -484,6 +845,16 @@ func (couples *CouplesAnalysis) serializeText(result *CouplesResult, writer io.W
 			fmt.Fprintf(writer, "        - %s\n", file) // sorted by path
 		}
 	}
+
+	fmt.Fprintln(writer, "  files_coupling:")
+	fmt.Fprintf(writer, "    commits: %d\n", result.Commits)
+	fmt.Fprintf(writer, "    window_ticks: %d\n", result.WindowTicks)
+	fmt.Fprintln(writer, "    pairs:") // indexes in files_coocc.index
+	for _, pair := range result.FilePairs {
+		fmt.Fprintf(writer, "      - {files: [%d, %d], commits: %d, support: %.4f, "+
+			"confidence: [%.4f, %.4f], lift: %.4f}\n", pair.First, pair.Second, pair.Commits,
+			pair.Support, pair.Confidence, pair.ReverseConfidence, pair.Lift)
+	}
 }
 
 func sortByNumberOfFiles(
@@ -545,6 +916,22 @@ func (couples *CouplesAnalysis) serializeBinary(result *CouplesResult, writer io
 	for i, l := range result.FilesLines {
 		message.FilesLines[i] = int32(l)
 	}
+	message.FilePairs = make([]*pb.FileCoupling, len(result.FilePairs))
+	for i, pair := range result.FilePairs {
+		message.FilePairs[i] = &pb.FileCoupling{
+			First:             int32(pair.First),
+			Second:            int32(pair.Second),
+			Commits:           int32(pair.Commits),
+			FirstCommits:      int32(pair.FirstCommits),
+			SecondCommits:     int32(pair.SecondCommits),
+			Support:           pair.Support,
+			Confidence:        pair.Confidence,
+			ReverseConfidence: pair.ReverseConfidence,
+			Lift:              pair.Lift,
+		}
+	}
+	message.Commits = int32(result.Commits)
+	message.WindowTicks = int32(result.WindowTicks)
 
 	serialized, err := proto.Marshal(&message)
 	if err != nil {
@@ -575,11 +962,43 @@ func (couples *CouplesAnalysis) currentFiles() map[string]bool {
 func (couples *CouplesAnalysis) propagateRenames(files map[string]bool) (
 	map[string]map[string]int, []map[string]int) {
 
+	reducedFiles, aliases, pointers := couples.reduceRenamedFiles(files, couples.files)
+	people := make([]map[string]int, len(couples.people))
+	for i, counts := range couples.people {
+		reducedCounts := map[string]int{}
+		people[i] = reducedCounts
+		for file := range files {
+			count := counts[file]
+			for alias := range aliases[file] {
+				count += counts[alias]
+			}
+			if count > 0 {
+				reducedCounts[file] = count
+			}
+		}
+		for key, val := range counts {
+			if _, exists := files[key]; !exists {
+				if _, exists = pointers[key]; !exists {
+					reducedCounts[key] = val
+				}
+			}
+		}
+	}
+	return reducedFiles, people
+}
+
+// reduceRenamedFiles keeps the co-occurrences `cooccs` of the files from `lastCommit` and adds
+// those of the older names of the renamed files. It returns the reduced co-occurrences,
+// the older names of each file and the mapping from an older name to the final one.
+func (couples *CouplesAnalysis) reduceRenamedFiles(
+	files map[string]bool, cooccs map[string]map[string]int) (
+	map[string]map[string]int, map[string]map[string]bool, map[string]string) {
+
 	renames := *couples.renames
 	reducedFiles := map[string]map[string]int{}
 	for file := range files {
 		fmap := map[string]int{}
-		refmap := couples.files[file]
+		refmap := cooccs[file]
 		for other := range files {
 			refval := refmap[other]
 			if refval > 0 {
@@ -616,7 +1035,7 @@ func (couples *CouplesAnalysis) propagateRenames(files map[string]bool) (
 	for final, set := range aliases {
 		adjustment := map[string]int{}
 		for alias := range set {
-			for k, v := range couples.files[alias] {
+			for k, v := range cooccs[alias] {
 				adjustment[k] += v
 			}
 		}
@@ -638,28 +1057,7 @@ func (couples *CouplesAnalysis) propagateRenames(files map[string]bool) (
 			}
 		}
 	}
-	people := make([]map[string]int, len(couples.people))
-	for i, counts := range couples.people {
-		reducedCounts := map[string]int{}
-		people[i] = reducedCounts
-		for file := range files {
-			count := counts[file]
-			for alias := range aliases[file] {
-				count += counts[alias]
-			}
-			if count > 0 {
-				reducedCounts[file] = count
-			}
-		}
-		for key, val := range counts {
-			if _, exists := files[key]; !exists {
-				if _, exists = pointers[key]; !exists {
-					reducedCounts[key] = val
-				}
-			}
-		}
-	}
-	return reducedFiles, people
+	return reducedFiles, aliases, pointers
 }
 
 func init() {
@@ -14,10 +14,9 @@ import (
 	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
 	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
 	"github.com/dmytrogajewski/hercules/internal/pkg/test"
-	"github.com/go-git/go-git/v6/plumbing"
-	gitplumbing "github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
 )
 
@@ -31,16 +30,28 @@ func TestCouplesMeta(t *testing.T) {
 	c := fixtureCouples()
 	assert.Equal(t, c.Name(), "Couples")
 	assert.Equal(t, len(c.Provides()), 0)
-	assert.Equal(t, len(c.Requires()), 2)
+	assert.Equal(t, len(c.Requires()), 4)
 	assert.Equal(t, c.Requires()[0], identity.DependencyAuthor)
 	assert.Equal(t, c.Requires()[1], plumbing.DependencyTreeChanges)
+	assert.Equal(t, c.Requires()[2], plumbing.DependencyTick)
+	assert.Equal(t, c.Requires()[3], identity.DependencyAuthorExcluded)
 	assert.Equal(t, c.Flag(), "couples")
-	assert.Len(t, c.ListConfigurationOptions(), 0)
+	assert.Len(t, c.ListConfigurationOptions(), 4)
 	logger := core.GetLogger()
 	assert.NoError(t, c.Configure(map[string]interface{}{
-		core.ConfigLogger: logger,
+		core.ConfigLogger:           logger,
+		ConfigCouplesWindowTicks:    30,
+		ConfigCouplesMaxCommitFiles: 50,
+		ConfigCouplesMinCommits:     3,
+		ConfigCouplesMinConfidence:  float32(0.7),
 	}))
 	assert.Equal(t, logger, c.l)
+	assert.Equal(t, 30, c.WindowTicks)
+	assert.Equal(t, 50, c.MaxCommitFiles)
+	assert.Equal(t, 3, c.MinCommits)
+	assert.Equal(t, float32(0.7), c.MinConfidence)
+	c.WindowTicks = -1
+	assert.Error(t, c.Initialize(test.Repository))
 }
 
 func TestCouplesRegistration(t *testing.T) {
@@ -98,8 +109,7 @@ func TestCouplesConsumeFinalize(t *testing.T) {
 	c := fixtureCouples()
 	deps := map[string]interface{}{}
 	deps[identity.DependencyAuthor] = 0
-	deps[core.DependencyCommit], _ = test.Repository.CommitObject(gitplumbing.NewHash(
-		"a3ee37f91f0d705ec9c41ae88426f0ae44b2fbc3"))
+	deps[core.DependencyCommit] = test.FixtureCommit(t, "a3ee37f91f0d705ec9c41ae88426f0ae44b2fbc3")
 	deps[core.DependencyIsMerge] = false
 	deps[plumbing.DependencyTreeChanges] = generateChanges("+LICENSE2", "+file2.go", "+rbtree2.go")
 	c.Consume(deps)
@@ -205,8 +215,7 @@ func TestCouplesConsumeFinalizeMerge(t *testing.T) {
 	c := fixtureCouples()
 	deps := map[string]interface{}{}
 	deps[identity.DependencyAuthor] = 0
-	deps[core.DependencyCommit], _ = test.Repository.CommitObject(gitplumbing.NewHash(
-		"a3ee37f91f0d705ec9c41ae88426f0ae44b2fbc3"))
+	deps[core.DependencyCommit] = test.FixtureCommit(t, "a3ee37f91f0d705ec9c41ae88426f0ae44b2fbc3")
 	deps[core.DependencyIsMerge] = true
 	deps[plumbing.DependencyTreeChanges] = generateChanges("+LICENSE2", "+file2.go")
 	c.Consume(deps)
@@ -236,8 +245,7 @@ func TestCouplesConsumeFinalizeAuthorMissing(t *testing.T) {
 	c := fixtureCouples()
 	deps := map[string]interface{}{}
 	deps[identity.DependencyAuthor] = 0
-	deps[core.DependencyCommit], _ = test.Repository.CommitObject(gitplumbing.NewHash(
-		"a3ee37f91f0d705ec9c41ae88426f0ae44b2fbc3"))
+	deps[core.DependencyCommit] = test.FixtureCommit(t, "a3ee37f91f0d705ec9c41ae88426f0ae44b2fbc3")
 	deps[core.DependencyIsMerge] = false
 	deps[plumbing.DependencyTreeChanges] = generateChanges("+LICENSE2", "+file2.go", "+rbtree2.go")
 	c.Consume(deps)
@@ -339,8 +347,7 @@ func TestCouplesConsumeManyFiles(t *testing.T) {
 	c := fixtureCouples()
 	deps := map[string]interface{}{}
 	deps[identity.DependencyAuthor] = 0
-	deps[core.DependencyCommit], _ = test.Repository.CommitObject(gitplumbing.NewHash(
-		"a3ee37f91f0d705ec9c41ae88426f0ae44b2fbc3"))
+	deps[core.DependencyCommit] = test.FixtureCommit(t, "a3ee37f91f0d705ec9c41ae88426f0ae44b2fbc3")
 	deps[core.DependencyIsMerge] = false
 	changes := make(object.Changes, CouplesMaximumMeaningfulContextSize+1)
 	for i := 0; i < len(changes); i++ {
@@ -453,7 +460,7 @@ func TestCouplesSerialize(t *testing.T) {
 
 func TestCouplesDeserialize(t *testing.T) {
 	message, err := ioutil.ReadFile(path.Join("..", "internal", "test_data", "couples.pb"))
-	assert.Nil(t, err)
+	require.NoError(t, err)
 	couples := CouplesAnalysis{}
 	iresult, err := couples.Deserialize(message)
 	assert.Nil(t, err)
@@ -540,8 +547,7 @@ func TestCouplesMerge(t *testing.T) {
 
 func TestCouplesCurrentFiles(t *testing.T) {
 	c := fixtureCouples()
-	c.lastCommit, _ = test.Repository.CommitObject(gitplumbing.NewHash(
-		"cce947b98a050c6d356bc6ba95030254914027b1"))
+	c.lastCommit = test.FixtureCommit(t, "cce947b98a050c6d356bc6ba95030254914027b1")
 	files := c.currentFiles()
 	assert.Equal(t, files, map[string]bool{".gitignore": true, "LICENSE": true})
 }
@@ -598,3 +604,167 @@ func getCouplesMap(vals ...int) map[int]int64 {
 	}
 	return res
 }
+
+// fixtureCouplesFiles returns CouplesAnalysis initialized with a repository which contains
+// README.md, analyser.go and file_test.go.
+func fixtureCouplesFiles(configure func(c *CouplesAnalysis)) (*CouplesAnalysis, *object.Commit) {
+	repository, commits := test.NewRepository(test.Commit{Files: map[string]string{
+		"README.md": "readme\n", "analyser.go": "package main\n", "file_test.go": "package main\n"}})
+	c := &CouplesAnalysis{PeopleNumber: 1}
+	if configure != nil {
+		configure(c)
+	}
+	c.Initialize(repository)
+	return c, commits[0]
+}
+
+func consumeCouplesContexts(t *testing.T, c *CouplesAnalysis, commit *object.Commit,
+	contexts ...[]string) {
+	deps := map[string]interface{}{}
+	deps[identity.DependencyAuthor] = 0
+	deps[core.DependencyCommit] = commit
+	deps[core.DependencyIsMerge] = false
+	for tick, context := range contexts {
+		changes := make([]string, len(context))
+		for i, name := range context {
+			changes[i] = "=" + name
+		}
+		deps[plumbing.DependencyTick] = tick
+		deps[plumbing.DependencyTreeChanges] = generateChanges(changes...)
+		_, err := c.Consume(deps)
+		assert.NoError(t, err)
+	}
+}
+
+func TestCouplesFilePairs(t *testing.T) {
+	contexts := [][]string{
+		{"README.md", "analyser.go"}, {"README.md", "analyser.go"},
+		{"README.md", "file_test.go"}, {"analyser.go"},
+	}
+	c, commit := fixtureCouplesFiles(func(c *CouplesAnalysis) {
+		c.MinCommits = DefaultCouplesMinCommits
+		c.MinConfidence = DefaultCouplesMinConfidence
+	})
+	consumeCouplesContexts(t, c, commit, contexts...)
+	cr := c.Finalize().(CouplesResult)
+	assert.Equal(t, []string{"README.md", "analyser.go", "file_test.go"}, cr.Files)
+	assert.Equal(t, 4, cr.Commits)
+	assert.Equal(t, 0, cr.WindowTicks)
+	assert.Equal(t, []FileCoupling{{
+		First: 0, Second: 1, Commits: 2, FirstCommits: 3, SecondCommits: 3, Support: 0.5,
+		Confidence: 2.0 / 3, ReverseConfidence: 2.0 / 3, Lift: 8.0 / 9}}, cr.FilePairs)
+
+	c, commit = fixtureCouplesFiles(func(c *CouplesAnalysis) {
+		c.MinCommits = 1
+		c.MinConfidence = 0.9
+	})
+	consumeCouplesContexts(t, c, commit, contexts...)
+	cr = c.Finalize().(CouplesResult)
+	// README.md => file_test.go is weak but file_test.go => README.md is strong
+	assert.Equal(t, []FileCoupling{{
+		First: 0, Second: 2, Commits: 1, FirstCommits: 3, SecondCommits: 1, Support: 0.25,
+		Confidence: 1.0 / 3, ReverseConfidence: 1, Lift: 4.0 / 3}}, cr.FilePairs)
+}
+
+func TestCouplesFilePairsWindow(t *testing.T) {
+	c, commit := fixtureCouplesFiles(func(c *CouplesAnalysis) {
+		c.WindowTicks = 2
+		c.MinCommits = 1
+	})
+	consumeCouplesContexts(t, c, commit,
+		[]string{"README.md", "analyser.go"}, []string{"README.md", "analyser.go"},
+		[]string{"README.md", "file_test.go"}, []string{"analyser.go"})
+	assert.Len(t, c.temporal.Window, 2)
+	cr := c.Finalize().(CouplesResult)
+	assert.Equal(t, 2, cr.Commits)
+	assert.Equal(t, 2, cr.WindowTicks)
+	assert.Equal(t, []FileCoupling{{
+		First: 0, Second: 2, Commits: 1, FirstCommits: 1, SecondCommits: 1, Support: 0.5,
+		Confidence: 1, ReverseConfidence: 1, Lift: 2}}, cr.FilePairs)
+	// the matrix still covers the whole history
+	assert.Equal(t, int64(2), cr.FilesMatrix[0][1])
+}
+
+func TestCouplesMaxCommitFiles(t *testing.T) {
+	c, commit := fixtureCouplesFiles(func(c *CouplesAnalysis) {
+		c.MaxCommitFiles = 1
+		c.MinCommits = 1
+	})
+	consumeCouplesContexts(t, c, commit,
+		[]string{"README.md", "analyser.go"}, []string{"README.md"}, []string{"analyser.go"})
+	assert.Equal(t, 2, c.temporal.Commits)
+	assert.Equal(t, map[string]int{"README.md": 1}, c.files["README.md"])
+	cr := c.Finalize().(CouplesResult)
+	assert.Equal(t, 2, cr.Commits)
+	assert.Len(t, cr.FilePairs, 0)
+}
+
+func TestCouplesCheckpointTemporal(t *testing.T) {
+	setWindow := func(c *CouplesAnalysis) {
+		c.WindowTicks = 5
+	}
+	c, commit := fixtureCouplesFiles(setWindow)
+	consumeCouplesContexts(t, c, commit, []string{"README.md", "analyser.go"}, []string{"README.md"})
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, c.Checkpoint(buffer))
+	restored, _ := fixtureCouplesFiles(setWindow)
+	assert.NoError(t, restored.Restore(buffer))
+	assert.Equal(t, *c.temporal, *restored.temporal)
+	assert.Equal(t, 2, restored.temporal.Commits)
+	assert.Equal(t, 1, restored.temporal.LastTick)
+}
+
+func TestCouplesSerializeFilePairs(t *testing.T) {
+	c := fixtureCouples()
+	result := CouplesResult{
+		PeopleMatrix: []map[int]int64{{}},
+		PeopleFiles:  [][]int{{}},
+		FilesMatrix:  []map[int]int64{{0: 3, 1: 2}, {0: 2, 1: 2}},
+		Files:        []string{"one", "two"},
+		FilesLines:   []int{9, 8},
+		FilePairs: []FileCoupling{{First: 0, Second: 1, Commits: 2, FirstCommits: 3,
+			SecondCommits: 2, Support: 0.5, Confidence: 2.0 / 3, ReverseConfidence: 1, Lift: 4.0 / 3}},
+		Commits:            4,
+		WindowTicks:        7,
+		reversedPeopleDict: []string{},
+	}
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, c.Serialize(result, false, buffer))
+	assert.True(t, strings.HasSuffix(buffer.String(), `  files_coupling:
+    commits: 4
+    window_ticks: 7
+    pairs:
+      - {files: [0, 1], commits: 2, support: 0.5000, confidence: [0.6667, 1.0000], lift: 1.3333}
+`), buffer.String())
+	buffer = &bytes.Buffer{}
+	assert.Nil(t, c.Serialize(result, true, buffer))
+	deserialized, err := c.Deserialize(buffer.Bytes())
+	assert.Nil(t, err)
+	cr := deserialized.(CouplesResult)
+	assert.Equal(t, result.FilePairs, cr.FilePairs)
+	assert.Equal(t, 4, cr.Commits)
+	assert.Equal(t, 7, cr.WindowTicks)
+}
+
+func TestCouplesMergeFilePairs(t *testing.T) {
+	r1 := CouplesResult{
+		Files:      []string{"a", "b", "c"},
+		FilesLines: []int{1, 1, 1},
+		FilePairs: []FileCoupling{
+			newFileCoupling(0, 1, 2, 2, 3, 4), newFileCoupling(1, 2, 2, 3, 2, 4)},
+		Commits:     4,
+		WindowTicks: 10,
+	}
+	r2 := CouplesResult{
+		Files:       []string{"c", "b"},
+		FilesLines:  []int{1, 1},
+		FilePairs:   []FileCoupling{newFileCoupling(0, 1, 1, 1, 2, 4)},
+		Commits:     4,
+		WindowTicks: 10,
+	}
+	merged := (&CouplesAnalysis{}).MergeResults(r1, r2, nil, nil).(CouplesResult)
+	assert.Equal(t, 8, merged.Commits)
+	assert.Equal(t, 10, merged.WindowTicks)
+	assert.Equal(t, []FileCoupling{
+		newFileCoupling(1, 2, 3, 5, 3, 8), newFileCoupling(0, 1, 2, 2, 3, 8)}, merged.FilePairs)
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"path"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
@@ -27,9 +29,21 @@ type DevsAnalysis struct {
 	// ConsiderEmptyCommits indicates whether empty commits (e.g., merges) should be taken
 	// into account.
 	ConsiderEmptyCommits bool
+	// Report enables the per-developer report in DevsResult.Report.
+	Report bool
+	// ReworkTicks is the maximum age in ticks of the removed lines which count as rework.
+	ReworkTicks int
+	// Top is the number of the most changed directories and languages in the report.
+	Top int
+	// Exclude filters out the developers whose identities match, e.g. bots.
+	Exclude *regexp.Regexp
 
 	// ticks maps ticks to developers to stats
 	ticks map[int]map[int]*DevTick
+	// activity maps developers to the report stats which are not in DevTick
+	activity map[int]*devActivity
+	// excluded is the set of developers matched by Exclude
+	excluded map[int]bool
 	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
 	reversedPeopleDict []string
 	// TickSize references TicksSinceStart.TickSize
@@ -43,11 +57,15 @@ type DevsAnalysis struct {
 type DevsResult struct {
 	// Ticks is <tick index> -> <developer index> -> daily stats
 	Ticks map[int]map[int]*DevTick
+	// Report is <developer index> -> summary. It is nil unless DevsAnalysis.Report is set.
+	Report map[int]*DevReport
 
 	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
 	reversedPeopleDict []string
 	// TickSize references TicksSinceStart.TickSize
 	tickSize time.Duration
+	// reworkTicks references DevsAnalysis.ReworkTicks
+	reworkTicks int
 }
 
 // DevTick is the statistics for a development tick and a particular developer.
@@ -59,9 +77,70 @@ type DevTick struct {
 	Languages map[string]items.LineStats
 }
 
+// DevReport summarizes the activity of a developer over the whole history.
+type DevReport struct {
+	// FirstTick is the tick of the first commit.
+	FirstTick int
+	// LastTick is the tick of the last commit.
+	LastTick int
+	// Commits is the total number of commits.
+	Commits int
+	// ActiveDays is the number of calendar days with commits in the author's time zone.
+	ActiveDays int
+	// Directories are the most changed directories, ordered by the number of changed lines.
+	Directories []DevShare
+	// Languages are the most changed languages, ordered by the number of changed lines.
+	Languages []DevShare
+	// ReworkedLines is the number of removed or changed lines which were written no more than
+	// DevsAnalysis.ReworkTicks ticks before, by anybody.
+	ReworkedLines int
+	// RemovedLines is the number of removed or changed lines.
+	RemovedLines int
+	// ReworkRatio is ReworkedLines / RemovedLines.
+	ReworkRatio float64
+	// Weekdays is the number of commits per day of the week in the author's time zone,
+	// starting from Sunday.
+	Weekdays []int
+	// Hours is the number of commits per hour of the day in the author's time zone.
+	Hours []int
+}
+
+// DevShare is the number of lines changed by a developer in a directory or a language.
+type DevShare struct {
+	Name  string
+	Lines int
+}
+
+// devActivity is the part of DevReport which cannot be derived from DevTick.
+type devActivity struct {
+	// Days is the set of the active days since the Unix epoch
+	Days        map[int]bool
+	Weekdays    [7]int
+	Hours       [24]int
+	Directories map[string]int
+	Reworked    int
+	Removed     int
+}
+
+// devsCheckpoint is the state of DevsAnalysis saved in core.Checkpoint.
+type devsCheckpoint struct {
+	Ticks    map[int]map[int]*DevTick
+	Activity map[int]*devActivity
+}
+
 const (
 	// ConfigDevsConsiderEmptyCommits is the name of the option to set DevsAnalysis.ConsiderEmptyCommits.
 	ConfigDevsConsiderEmptyCommits = "Devs.ConsiderEmptyCommits"
+	// ConfigDevsReport is the name of the option to set DevsAnalysis.Report.
+	ConfigDevsReport = "Devs.Report"
+	// ConfigDevsReworkTicks is the name of the option to set DevsAnalysis.ReworkTicks.
+	ConfigDevsReworkTicks = "Devs.ReworkTicks"
+	// ConfigDevsTop is the name of the option to set DevsAnalysis.Top.
+	ConfigDevsTop = "Devs.Top"
+	// ConfigDevsExclude is the name of the option to set DevsAnalysis.Exclude.
+	ConfigDevsExclude = "Devs.Exclude"
+	// DefaultDevsTop is the default value of DevsAnalysis.Top.
+	DefaultDevsTop = 5
 )
 
 // Name of this PipelineItem. Uniquely identifies the type, used for mapping keys, etc.
@@ -82,7 +161,8 @@ func (devs *DevsAnalysis) Provides() []string {
 func (devs *DevsAnalysis) Requires() []string {
 	return []string{
 		identity.DependencyAuthor, items.DependencyTreeChanges, items.DependencyTick,
-		items.DependencyLanguages, items.DependencyLineStats}
+		items.DependencyLanguages, items.DependencyLineStats, DependencyBurndownLines,
+		identity.DependencyAuthorExcluded}
 }
 
 // ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
@@ -92,7 +172,28 @@ func (devs *DevsAnalysis) ListConfigurationOptions() []core.ConfigurationOption
 		Description: "Take into account empty commits such as trivial merges.",
 		Flag:        "empty-commits",
 		Type:        core.BoolConfigurationOption,
-		Default:     false}}
+		Default:     false}, {
+		Name: ConfigDevsReport,
+		Description: "Summarize each developer: active ticks and days, the top directories " +
+			"and languages, the rework ratio and the commit times.",
+		Flag:    "devs-report",
+		Type:    core.BoolConfigurationOption,
+		Default: false}, {
+		Name:        ConfigDevsReworkTicks,
+		Description: "Removed lines which are at most this many ticks old count as rework.",
+		Flag:        "devs-rework-ticks",
+		Type:        core.IntConfigurationOption,
+		Default:     DefaultReworkTicks}, {
+		Name:        ConfigDevsTop,
+		Description: "Number of the most changed directories and languages per developer in the report.",
+		Flag:        "devs-top",
+		Type:        core.IntConfigurationOption,
+		Default:     DefaultDevsTop}, {
+		Name:        ConfigDevsExclude,
+		Description: "Ignore the developers whose identities match this regexp, e.g. bots.",
+		Flag:        "devs-exclude",
+		Type:        core.StringConfigurationOption,
+		Default:     ""}}
 	return options[:]
 }
 
@@ -104,6 +205,25 @@ func (devs *DevsAnalysis) Configure(facts map[string]interface{}) error {
 	if val, exists := facts[ConfigDevsConsiderEmptyCommits].(bool); exists {
 		devs.ConsiderEmptyCommits = val
 	}
+	if val, exists := facts[ConfigDevsReport].(bool); exists {
+		devs.Report = val
+	}
+	if val, exists := facts[ConfigDevsReworkTicks].(int); exists {
+		devs.ReworkTicks = val
+	}
+	if devs.Report {
+		subscribeBurndownLines(facts, false)
+	}
+	if val, exists := facts[ConfigDevsTop].(int); exists {
+		devs.Top = val
+	}
+	if val, exists := facts[ConfigDevsExclude].(string); exists && val != "" {
+		exclude, err := regexp.Compile(val)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", ConfigDevsExclude, err)
+		}
+		devs.Exclude = exclude
+	}
 	if val, exists := facts[identity.FactIdentityDetectorReversedPeopleDict].([]string); exists {
 		devs.reversedPeopleDict = val
 	}
@@ -129,8 +249,23 @@ func (devs *DevsAnalysis) Initialize(repository *git.Repository) error {
 	if devs.tickSize == 0 {
 		return errors.New("tick size must be specified")
 	}
+	if devs.ReworkTicks < 0 {
+		return fmt.Errorf("ReworkTicks is negative: %d", devs.ReworkTicks)
+	}
+	if devs.Top <= 0 {
+		devs.Top = DefaultDevsTop
+	}
 	devs.l = core.GetLogger()
 	devs.ticks = map[int]map[int]*DevTick{}
+	devs.activity = map[int]*devActivity{}
+	devs.excluded = map[int]bool{}
+	if devs.Exclude != nil {
+		for i, person := range devs.reversedPeopleDict {
+			if devs.Exclude.MatchString(person) {
+				devs.excluded[i] = true
+			}
+		}
+	}
 	devs.OneShotMergeProcessor.Initialize()
 	return nil
 }
@@ -145,6 +280,9 @@ func (devs *DevsAnalysis) Consume(deps map[string]interface{}) (map[string]inter
 		return nil, nil
 	}
 	author := deps[identity.DependencyAuthor].(int)
+	if excluded, _ := deps[identity.DependencyAuthorExcluded].(bool); excluded || devs.excluded[author] {
+		return nil, nil
+	}
 	treeDiff := deps[items.DependencyTreeChanges].(object.Changes)
 	if len(treeDiff) == 0 && !devs.ConsiderEmptyCommits {
 		return nil, nil
@@ -161,6 +299,10 @@ func (devs *DevsAnalysis) Consume(deps map[string]interface{}) (map[string]inter
 		devstick[author] = dd
 	}
 	dd.Commits++
+	var activity *devActivity
+	if devs.Report {
+		activity = devs.recordActivity(author, deps[core.DependencyCommit].(*object.Commit))
+	}
 	if deps[core.DependencyIsMerge].(bool) {
 		// we ignore merge commit diffs
 		// TODO(vmarkovtsev): handle them
@@ -168,10 +310,16 @@ func (devs *DevsAnalysis) Consume(deps map[string]interface{}) (map[string]inter
 	}
 	langs := deps[items.DependencyLanguages].(map[plumbing.Hash]string)
 	lineStats := deps[items.DependencyLineStats].(map[object.ChangeEntry]items.LineStats)
+	if activity != nil {
+		devs.countRework(activity, deps[DependencyBurndownLines].(BurndownLines).Updates)
+	}
 	for changeEntry, stats := range lineStats {
 		dd.Added += stats.Added
 		dd.Removed += stats.Removed
 		dd.Changed += stats.Changed
+		if activity != nil {
+			activity.Directories[path.Dir(changeEntry.Name)] += stats.Added + stats.Removed + stats.Changed
+		}
 		lang := langs[changeEntry.TreeEntry.Hash]
 		langStats := dd.Languages[lang]
 		dd.Languages[lang] = items.LineStats{
@@ -183,13 +331,149 @@ func (devs *DevsAnalysis) Consume(deps map[string]interface{}) (map[string]inter
 	return nil, nil
 }
 
+// recordActivity updates the active days and the commit time distribution of the author.
+func (devs *DevsAnalysis) recordActivity(author int, commit *object.Commit) *devActivity {
+	activity, exists := devs.activity[author]
+	if !exists {
+		activity = &devActivity{Days: map[int]bool{}, Directories: map[string]int{}}
+		devs.activity[author] = activity
+	}
+	when := commit.Author.When
+	day := time.Date(when.Year(), when.Month(), when.Day(), 0, 0, 0, 0, time.UTC)
+	activity.Days[int(day.Unix()/(24*3600))] = true
+	activity.Weekdays[when.Weekday()]++
+	activity.Hours[when.Hour()]++
+	return activity
+}
+
+// countRework adds the lines removed by the commit to the author's rework stats.
+func (devs *DevsAnalysis) countRework(activity *devActivity, updates []LineUpdate) {
+	for _, update := range updates {
+		if update.Delta >= 0 {
+			continue
+		}
+		activity.Removed -= update.Delta
+		if update.CurrentTick-update.PreviousTick <= devs.ReworkTicks {
+			activity.Reworked -= update.Delta
+		}
+	}
+}
+
 // Finalize returns the result of the analysis. Further Consume() calls are not expected.
 func (devs *DevsAnalysis) Finalize() interface{} {
-	return DevsResult{
+	result := DevsResult{
 		Ticks:              devs.ticks,
 		reversedPeopleDict: devs.reversedPeopleDict,
 		tickSize:           devs.tickSize,
+		reworkTicks:        devs.ReworkTicks,
+	}
+	if devs.Report {
+		result.Report = devs.buildReport()
+	}
+	return result
+}
+
+// buildReport combines the per-tick stats with the activity of each developer.
+func (devs *DevsAnalysis) buildReport() map[int]*DevReport {
+	report := map[int]*DevReport{}
+	languages := map[int]map[string]int{}
+	for tick, dd := range devs.ticks {
+		for dev, stats := range dd {
+			dr, exists := report[dev]
+			if !exists {
+				dr = &DevReport{FirstTick: tick, LastTick: tick}
+				report[dev] = dr
+				languages[dev] = map[string]int{}
+			}
+			if tick < dr.FirstTick {
+				dr.FirstTick = tick
+			}
+			if tick > dr.LastTick {
+				dr.LastTick = tick
+			}
+			dr.Commits += stats.Commits
+			for lang, ls := range stats.Languages {
+				languages[dev][lang] += ls.Added + ls.Removed + ls.Changed
+			}
+		}
+	}
+	for dev, dr := range report {
+		dr.Languages = topDevShares(languages[dev], devs.Top)
+		activity := devs.activity[dev]
+		if activity == nil {
+			dr.Directories = []DevShare{}
+			dr.Weekdays = make([]int, 7)
+			dr.Hours = make([]int, 24)
+			continue
+		}
+		dr.ActiveDays = len(activity.Days)
+		dr.Directories = topDevShares(activity.Directories, devs.Top)
+		dr.ReworkedLines = activity.Reworked
+		dr.RemovedLines = activity.Removed
+		dr.ReworkRatio = reworkRatio(dr.ReworkedLines, dr.RemovedLines)
+		dr.Weekdays = append([]int{}, activity.Weekdays[:]...)
+		dr.Hours = append([]int{}, activity.Hours[:]...)
+	}
+	return report
+}
+
+// topDevShares returns at most `top` names with the most lines.
+func topDevShares(lines map[string]int, top int) []DevShare {
+	shares := make([]DevShare, 0, len(lines))
+	for name, count := range lines {
+		if count > 0 {
+			shares = append(shares, DevShare{Name: name, Lines: count})
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Lines != shares[j].Lines {
+			return shares[i].Lines > shares[j].Lines
+		}
+		return shares[i].Name < shares[j].Name
+	})
+	if len(shares) > top {
+		shares = shares[:top]
+	}
+	return shares
+}
+
+func reworkRatio(reworked, removed int) float64 {
+	if removed == 0 {
+		return 0
+	}
+	return float64(reworked) / float64(removed)
+}
+
+// Checkpoint writes the per-tick developer stats and the report state.
+// It implements core.CheckpointablePipelineItem.
+func (devs *DevsAnalysis) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, devsCheckpoint{Ticks: devs.ticks, Activity: devs.activity})
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (devs *DevsAnalysis) Restore(reader io.Reader) error {
+	state := devsCheckpoint{}
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
 	}
+	devs.ticks = state.Ticks
+	if devs.ticks == nil {
+		devs.ticks = map[int]map[int]*DevTick{}
+	}
+	devs.activity = state.Activity
+	if devs.activity == nil {
+		devs.activity = map[int]*devActivity{}
+	}
+	// gob does not transmit empty maps
+	for _, activity := range devs.activity {
+		if activity.Days == nil {
+			activity.Days = map[int]bool{}
+		}
+		if activity.Directories == nil {
+			activity.Directories = map[string]int{}
+		}
+	}
+	return nil
 }
 
 // Fork clones this pipeline item.
@@ -246,10 +530,64 @@ func (devs *DevsAnalysis) Deserialize(pbmessage []byte) (interface{}, error) {
 		Ticks:              ticks,
 		reversedPeopleDict: message.DevIndex,
 		tickSize:           time.Duration(message.TickSize),
+		reworkTicks:        int(message.ReworkTicks),
+	}
+	if message.Report != nil {
+		result.Report = map[int]*DevReport{}
+		for dev, dr := range message.Report {
+			if dev == -1 {
+				dev = identity.AuthorMissing
+			}
+			result.Report[int(dev)] = &DevReport{
+				FirstTick:     int(dr.FirstTick),
+				LastTick:      int(dr.LastTick),
+				Commits:       int(dr.Commits),
+				ActiveDays:    int(dr.ActiveDays),
+				Directories:   devSharesFromPB(dr.Directories),
+				Languages:     devSharesFromPB(dr.Languages),
+				ReworkedLines: int(dr.ReworkedLines),
+				RemovedLines:  int(dr.RemovedLines),
+				ReworkRatio:   dr.ReworkRatio,
+				Weekdays:      int32sToInts(dr.Weekdays),
+				Hours:         int32sToInts(dr.Hours),
+			}
+		}
 	}
 	return result, nil
 }
 
+func devSharesFromPB(shares []*pb.DevShare) []DevShare {
+	result := make([]DevShare, len(shares))
+	for i, share := range shares {
+		result[i] = DevShare{Name: share.Name, Lines: int(share.Lines)}
+	}
+	return result
+}
+
+func devSharesToPB(shares []DevShare) []*pb.DevShare {
+	result := make([]*pb.DevShare, len(shares))
+	for i, share := range shares {
+		result[i] = &pb.DevShare{Name: share.Name, Lines: int32(share.Lines)}
+	}
+	return result
+}
+
+func int32sToInts(values []int32) []int {
+	result := make([]int, len(values))
+	for i, val := range values {
+		result[i] = int(val)
+	}
+	return result
+}
+
+func intsToInt32s(values []int) []int32 {
+	result := make([]int32, len(values))
+	for i, val := range values {
+		result[i] = int32(val)
+	}
+	return result
+}
+
 // MergeResults combines two DevsAnalysis-es together.
 func (devs *DevsAnalysis) MergeResults(r1, r2 interface{}, c1, c2 *core.CommonAnalysisResult) interface{} {
 	cr1 := r1.(DevsResult)
@@ -335,9 +673,86 @@ func (devs *DevsAnalysis) MergeResults(r1, r2 interface{}, c1, c2 *core.CommonAn
 			}
 		}
 	}
+	if cr1.Report == nil && cr2.Report == nil {
+		return merged
+	}
+	merged.reworkTicks = cr1.reworkTicks
+	if cr2.reworkTicks > merged.reworkTicks {
+		merged.reworkTicks = cr2.reworkTicks
+	}
+	merged.Report = map[int]*DevReport{}
+	mergeReport := func(report map[int]*DevReport, people []string, offset int) {
+		for dev, dr := range report {
+			newdev := dev
+			if newdev != identity.AuthorMissing {
+				newdev = mergedIndex[people[dev]].Final
+			}
+			shifted := *dr
+			shifted.FirstTick += offset
+			shifted.LastTick += offset
+			if prev, exists := merged.Report[newdev]; exists {
+				merged.Report[newdev] = mergeDevReports(prev, &shifted)
+			} else {
+				merged.Report[newdev] = &shifted
+			}
+		}
+	}
+	mergeReport(cr1.Report, cr1.reversedPeopleDict, offset1)
+	mergeReport(cr2.Report, cr2.reversedPeopleDict, offset2)
 	return merged
 }
 
+// mergeDevReports combines the reports of the same developer in two repositories.
+// The active days are summed, so the days active in both are counted twice.
+func mergeDevReports(dr1, dr2 *DevReport) *DevReport {
+	merged := &DevReport{
+		FirstTick:     dr1.FirstTick,
+		LastTick:      dr1.LastTick,
+		Commits:       dr1.Commits + dr2.Commits,
+		ActiveDays:    dr1.ActiveDays + dr2.ActiveDays,
+		Directories:   mergeDevShares(dr1.Directories, dr2.Directories),
+		Languages:     mergeDevShares(dr1.Languages, dr2.Languages),
+		ReworkedLines: dr1.ReworkedLines + dr2.ReworkedLines,
+		RemovedLines:  dr1.RemovedLines + dr2.RemovedLines,
+		Weekdays:      sumInts(dr1.Weekdays, dr2.Weekdays),
+		Hours:         sumInts(dr1.Hours, dr2.Hours),
+	}
+	if dr2.FirstTick < merged.FirstTick {
+		merged.FirstTick = dr2.FirstTick
+	}
+	if dr2.LastTick > merged.LastTick {
+		merged.LastTick = dr2.LastTick
+	}
+	merged.ReworkRatio = reworkRatio(merged.ReworkedLines, merged.RemovedLines)
+	return merged
+}
+
+// mergeDevShares sums the lines by name and keeps as many top shares as the longest list.
+func mergeDevShares(shares1, shares2 []DevShare) []DevShare {
+	lines := map[string]int{}
+	for _, shares := range [][]DevShare{shares1, shares2} {
+		for _, share := range shares {
+			lines[share.Name] += share.Lines
+		}
+	}
+	top := len(shares1)
+	if len(shares2) > top {
+		top = len(shares2)
+	}
+	return topDevShares(lines, top)
+}
+
+func sumInts(values1, values2 []int) []int {
+	if len(values2) > len(values1) {
+		values1, values2 = values2, values1
+	}
+	result := append([]int{}, values1...)
+	for i, val := range values2 {
+		result[i] += val
+	}
+	return result
+}
+
 func (devs *DevsAnalysis) serializeText(result *DevsResult, writer io.Writer) {
 	fmt.Fprintln(writer, "  ticks:")
 	ticks := make([]int, len(result.Ticks))
@@ -385,12 +800,85 @@ func (devs *DevsAnalysis) serializeText(result *DevsResult, writer io.Writer) {
 		fmt.Fprintf(writer, "  - %s\n", person)
 	}
 	fmt.Fprintln(writer, "  tick_size:", int(result.tickSize.Seconds()))
+	if result.Report == nil {
+		return
+	}
+	fmt.Fprintln(writer, "  report:")
+	fmt.Fprintln(writer, "    rework_ticks:", result.reworkTicks)
+	fmt.Fprintln(writer, "    developers:")
+	devseq := make([]int, 0, len(result.Report))
+	for dev := range result.Report {
+		devseq = append(devseq, dev)
+	}
+	sort.Ints(devseq)
+	for _, dev := range devseq {
+		dr := result.Report[dev]
+		if dev == identity.AuthorMissing {
+			dev = -1
+		}
+		fmt.Fprintf(writer, "      %d:\n", dev)
+		fmt.Fprintln(writer, "        first_tick:", dr.FirstTick)
+		fmt.Fprintln(writer, "        last_tick:", dr.LastTick)
+		fmt.Fprintln(writer, "        commits:", dr.Commits)
+		fmt.Fprintln(writer, "        active_days:", dr.ActiveDays)
+		fmt.Fprintln(writer, "        directories:", formatDevShares(dr.Directories))
+		fmt.Fprintln(writer, "        languages:", formatDevShares(dr.Languages))
+		fmt.Fprintln(writer, "        reworked_lines:", dr.ReworkedLines)
+		fmt.Fprintln(writer, "        removed_lines:", dr.RemovedLines)
+		fmt.Fprintf(writer, "        rework_ratio: %.4f\n", dr.ReworkRatio)
+		fmt.Fprintln(writer, "        weekdays:", formatInts(dr.Weekdays))
+		fmt.Fprintln(writer, "        hours:", formatInts(dr.Hours))
+	}
+}
+
+// formatDevShares writes the YAML flow sequence of [name, lines] pairs.
+func formatDevShares(shares []DevShare) string {
+	formatted := make([]string, len(shares))
+	for i, share := range shares {
+		name := share.Name
+		if name == "" {
+			name = "none"
+		}
+		formatted[i] = fmt.Sprintf("[%q, %d]", name, share.Lines)
+	}
+	return "[" + strings.Join(formatted, ", ") + "]"
+}
+
+// formatInts writes the YAML flow sequence of the numbers.
+func formatInts(values []int) string {
+	formatted := make([]string, len(values))
+	for i, val := range values {
+		formatted[i] = fmt.Sprint(val)
+	}
+	return "[" + strings.Join(formatted, ", ") + "]"
 }
 
 func (devs *DevsAnalysis) serializeBinary(result *DevsResult, writer io.Writer) error {
 	message := pb.DevsAnalysisResults{}
 	message.DevIndex = result.reversedPeopleDict
 	message.TickSize = int64(result.tickSize)
+	if result.Report != nil {
+		message.ReworkTicks = int32(result.reworkTicks)
+		message.Report = map[int32]*pb.DevReport{}
+		for dev, dr := range result.Report {
+			if dev == identity.AuthorMissing {
+				dev = -1
+			}
+			message.Report[int32(dev)] = &pb.DevReport{
+				FirstTick:     int32(dr.FirstTick),
+				LastTick:      int32(dr.LastTick),
+				Commits:       int32(dr.Commits),
+				ActiveDays:    int32(dr.ActiveDays),
+				Directories:   devSharesToPB(dr.Directories),
+				Languages:     devSharesToPB(dr.Languages),
+				ReworkedLines: int32(dr.ReworkedLines),
+				RemovedLines:  int32(dr.RemovedLines),
+				ReworkRatio:   dr.ReworkRatio,
+				Weekdays:      intsToInt32s(dr.Weekdays),
+				Hours:         intsToInt32s(dr.Hours),
+			}
+		}
+	}
 	message.Ticks = map[int32]*pb.TickDevs{}
 	for tick, devs := range result.Ticks {
 		dd := &pb.TickDevs{}
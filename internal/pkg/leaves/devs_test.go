@@ -2,6 +2,7 @@ package leaves
 
 import (
 	"bytes"
+	"regexp"
 	"testing"
 	"time"
 
@@ -30,14 +31,16 @@ func TestDevsMeta(t *testing.T) {
 	d := fixtureDevs()
 	assert.Equal(t, d.Name(), "Devs")
 	assert.Equal(t, len(d.Provides()), 0)
-	assert.Equal(t, len(d.Requires()), 5)
+	assert.Equal(t, len(d.Requires()), 7)
 	assert.Equal(t, d.Requires()[0], identity.DependencyAuthor)
 	assert.Equal(t, d.Requires()[1], items.DependencyTreeChanges)
 	assert.Equal(t, d.Requires()[2], items.DependencyTick)
 	assert.Equal(t, d.Requires()[3], items.DependencyLanguages)
 	assert.Equal(t, d.Requires()[4], items.DependencyLineStats)
+	assert.Equal(t, d.Requires()[5], DependencyBurndownLines)
+	assert.Equal(t, d.Requires()[6], identity.DependencyAuthorExcluded)
 	assert.Equal(t, d.Flag(), "devs")
-	assert.Len(t, d.ListConfigurationOptions(), 1)
+	assert.Len(t, d.ListConfigurationOptions(), 5)
 	assert.Equal(t, d.ListConfigurationOptions()[0].Name, ConfigDevsConsiderEmptyCommits)
 	assert.Equal(t, d.ListConfigurationOptions()[0].Flag, "empty-commits")
 	assert.Equal(t, d.ListConfigurationOptions()[0].Type, core.BoolConfigurationOption)
@@ -70,9 +73,22 @@ func TestDevsConfigure(t *testing.T) {
 	facts := map[string]interface{}{}
 	facts[ConfigDevsConsiderEmptyCommits] = true
 	facts[items.FactTickSize] = 3 * time.Hour
+	facts[ConfigDevsReport] = true
+	facts[ConfigDevsReworkTicks] = 7
+	facts[ConfigDevsTop] = 3
+	facts[ConfigDevsExclude] = `\[bot\]`
+	subscription := &burndownSubscription{}
+	facts[factBurndownSubscription] = subscription
 	assert.NoError(t, devs.Configure(facts))
+	assert.Equal(t, burndownSubscription{lines: true}, *subscription)
 	assert.True(t, devs.ConsiderEmptyCommits)
 	assert.Equal(t, 3*time.Hour, devs.tickSize)
+	assert.True(t, devs.Report)
+	assert.Equal(t, 7, devs.ReworkTicks)
+	assert.Equal(t, 3, devs.Top)
+	assert.True(t, devs.Exclude.MatchString("dependabot[bot]"))
+	facts[ConfigDevsExclude] = "("
+	assert.Error(t, devs.Configure(facts))
 }
 
 func TestDevsInitialize(t *testing.T) {
@@ -148,8 +164,7 @@ func TestDevsConsumeFinalize(t *testing.T) {
 	result, err := fd.Consume(deps)
 	assert.Nil(t, err)
 	deps[items.DependencyFileDiff] = result[items.DependencyFileDiff]
-	deps[core.DependencyCommit], _ = test.Repository.CommitObject(plumbing.NewHash(
-		"cce947b98a050c6d356bc6ba95030254914027b1"))
+	deps[core.DependencyCommit] = test.FixtureCommit(t, "cce947b98a050c6d356bc6ba95030254914027b1")
 	deps[core.DependencyIsMerge] = false
 	lsc := &items.LinesStatsCalculator{}
 	lscres, err := lsc.Consume(deps)
@@ -454,3 +469,144 @@ func TestDevsResultGetters(t *testing.T) {
 	assert.Equal(t, dr.tickSize, dr.GetTickSize())
 	assert.Equal(t, dr.GetIdentities(), dr.reversedPeopleDict)
 }
+
+func fixtureDevsReport() *DevsAnalysis {
+	d := DevsAnalysis{Report: true, ReworkTicks: 21}
+	d.tickSize = 24 * time.Hour
+	d.reversedPeopleDict = []string{"one@srcd", "two@srcd", "dependabot[bot]"}
+	d.Exclude = regexp.MustCompile(`\[bot\]`)
+	d.Initialize(test.Repository)
+	return &d
+}
+
+func devsReportDeps(author, tick int, when time.Time, change *object.Change,
+	updates []LineUpdate, stats items.LineStats) map[string]interface{} {
+	entry := change.To
+	if entry.Name == "" {
+		entry = change.From
+	}
+	return map[string]interface{}{
+		identity.DependencyAuthor:   author,
+		items.DependencyTick:        tick,
+		core.DependencyCommit:       &object.Commit{Author: object.Signature{When: when}},
+		core.DependencyIsMerge:      false,
+		items.DependencyTreeChanges: object.Changes{change},
+		DependencyBurndownLines:     BurndownLines{Updates: updates},
+		items.DependencyLanguages:   map[plumbing.Hash]string{entry.TreeEntry.Hash: "Go"},
+		items.DependencyLineStats:   map[object.ChangeEntry]items.LineStats{entry: stats},
+	}
+}
+
+func TestDevsReport(t *testing.T) {
+	devs := fixtureDevsReport()
+	assert.True(t, devs.excluded[2])
+	hash1 := plumbing.NewHash("1111111111111111111111111111111111111111")
+	hash2 := plumbing.NewHash("2222222222222222222222222222222222222222")
+	entry := func(hash plumbing.Hash) object.ChangeEntry {
+		return object.ChangeEntry{Name: "cmd/main.go", TreeEntry: object.TreeEntry{
+			Name: "cmd/main.go", Mode: 0100644, Hash: hash}}
+	}
+	// Saturday
+	when := time.Date(2020, 1, 4, 10, 0, 0, 0, time.FixedZone("", 3600))
+	_, err := devs.Consume(devsReportDeps(0, 0, when, &object.Change{To: entry(hash1)},
+		[]LineUpdate{{File: "cmd/main.go", Delta: 3}}, ls(3, 0, 0)))
+	assert.NoError(t, err)
+	// the line written at tick 0 is changed at tick 1
+	_, err = devs.Consume(devsReportDeps(
+		1, 1, when.Add(25*time.Hour), &object.Change{From: entry(hash1), To: entry(hash2)},
+		[]LineUpdate{
+			{File: "cmd/main.go", CurrentTick: 1, Delta: -1},
+			{File: "cmd/main.go", CurrentTick: 1, PreviousTick: 1, Delta: 1},
+		}, ls(0, 0, 1)))
+	assert.NoError(t, err)
+	// the bot is ignored
+	_, err = devs.Consume(devsReportDeps(
+		2, 2, when, &object.Change{From: entry(hash2), To: entry(hash1)},
+		[]LineUpdate{{File: "cmd/main.go", CurrentTick: 2, PreviousTick: 1, Delta: -1}}, ls(0, 0, 1)))
+	assert.NoError(t, err)
+	assert.NotContains(t, devs.ticks, 2)
+	_, err = devs.Consume(devsReportDeps(
+		0, 30, when.Add(30*24*time.Hour), &object.Change{From: entry(hash2)},
+		[]LineUpdate{
+			{File: "cmd/main.go", CurrentTick: 30, Delta: -2},
+			{File: "cmd/main.go", CurrentTick: 30, PreviousTick: 1, Delta: -1},
+		}, ls(0, 3, 0)))
+	assert.NoError(t, err)
+
+	result := devs.Finalize().(DevsResult)
+	assert.Len(t, result.Report, 2)
+	dr := result.Report[0]
+	assert.Equal(t, 0, dr.FirstTick)
+	assert.Equal(t, 30, dr.LastTick)
+	assert.Equal(t, 2, dr.Commits)
+	assert.Equal(t, 2, dr.ActiveDays)
+	assert.Equal(t, []DevShare{{"cmd", 6}}, dr.Directories)
+	assert.Equal(t, []DevShare{{"Go", 6}}, dr.Languages)
+	assert.Equal(t, 0, dr.ReworkedLines)
+	assert.Equal(t, 3, dr.RemovedLines)
+	assert.Equal(t, 0.0, dr.ReworkRatio)
+	assert.Equal(t, []int{0, 1, 0, 0, 0, 0, 1}, dr.Weekdays)
+	assert.Equal(t, 1, dr.Weekdays[time.Saturday])
+	assert.Equal(t, 1, dr.Weekdays[time.Monday])
+	assert.Equal(t, 2, dr.Hours[10])
+	dr = result.Report[1]
+	assert.Equal(t, 1, dr.FirstTick)
+	assert.Equal(t, 1, dr.LastTick)
+	assert.Equal(t, 1, dr.ReworkedLines)
+	assert.Equal(t, 1, dr.RemovedLines)
+	assert.Equal(t, 1.0, dr.ReworkRatio)
+	assert.Equal(t, 1, dr.Weekdays[time.Sunday])
+	assert.Equal(t, 1, dr.Hours[11])
+
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, devs.Serialize(result, false, buffer))
+	assert.Contains(t, buffer.String(), `  report:
+    rework_ticks: 21
+    developers:
+      0:
+        first_tick: 0
+        last_tick: 30
+        commits: 2
+        active_days: 2
+        directories: [["cmd", 6]]
+        languages: [["Go", 6]]
+        reworked_lines: 0
+        removed_lines: 3
+        rework_ratio: 0.0000
+        weekdays: [0, 1, 0, 0, 0, 0, 1]
+        hours: [0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0]
+`)
+	buffer = &bytes.Buffer{}
+	assert.NoError(t, devs.Serialize(result, true, buffer))
+	rawResult2, err := devs.Deserialize(buffer.Bytes())
+	assert.NoError(t, err)
+	result2 := rawResult2.(DevsResult)
+	assert.Equal(t, result.Report, result2.Report)
+	assert.Equal(t, 21, result2.reworkTicks)
+}
+
+func TestDevsReportCheckpoint(t *testing.T) {
+	devs := fixtureDevsReport()
+	devs.activity[0] = &devActivity{Days: map[int]bool{}, Directories: map[string]int{}, Removed: 5}
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, devs.Checkpoint(buffer))
+	restored := fixtureDevsReport()
+	assert.NoError(t, restored.Restore(buffer))
+	assert.Equal(t, 5, restored.activity[0].Removed)
+	assert.NotNil(t, restored.activity[0].Days)
+	assert.NotNil(t, restored.activity[0].Directories)
+}
+
+func TestDevsMergeReports(t *testing.T) {
+	dr1 := &DevReport{FirstTick: 5, LastTick: 10, Commits: 3, ActiveDays: 2,
+		Directories: []DevShare{{"a", 10}, {"b", 5}}, Languages: []DevShare{{"Go", 15}},
+		ReworkedLines: 1, RemovedLines: 4, Weekdays: []int{1, 2}, Hours: []int{3}}
+	dr2 := &DevReport{FirstTick: 2, LastTick: 7, Commits: 1, ActiveDays: 1,
+		Directories: []DevShare{{"b", 20}}, Languages: []DevShare{{"Python", 20}},
+		ReworkedLines: 3, RemovedLines: 4, Weekdays: []int{1, 0}, Hours: []int{1}}
+	merged := mergeDevReports(dr1, dr2)
+	assert.Equal(t, &DevReport{FirstTick: 2, LastTick: 10, Commits: 4, ActiveDays: 3,
+		Directories: []DevShare{{"b", 25}, {"a", 10}}, Languages: []DevShare{{"Python", 20}},
+		ReworkedLines: 4, RemovedLines: 8, ReworkRatio: 0.5, Weekdays: []int{2, 2},
+		Hours: []int{4}}, merged)
+}
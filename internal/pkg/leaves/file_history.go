@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dmytrogajewski/hercules/api/proto/pb"
 	"github.com/dmytrogajewski/hercules/internal/app/core"
@@ -24,8 +26,13 @@ var _ core.PipelineItem = (*FileHistoryAnalysis)(nil)
 type FileHistoryAnalysis struct {
 	core.NoopMerger
 	core.OneShotMergeProcessor
+	// Path restricts the output to the files which have ever been inside this directory or
+	// are this file. Empty means no restriction.
+	Path string
+
 	files      map[string]*FileHistory
 	lastCommit *object.Commit
+	repository *git.Repository
 
 	l core.Logger
 }
@@ -39,8 +46,109 @@ type FileHistoryResult struct {
 type FileHistory struct {
 	// Hashes is the list of commit hashes which changed this file.
 	Hashes []plumbing.Hash
-	// People is the mapping from developers to the number of lines they altered.
+	// Times are the committer timestamps of Hashes.
+	Times []time.Time
+	// People is the mapping from developers to the number of lines they altered,
+	// including the changes made under the previous paths.
 	People map[int]items.LineStats
+	// Paths is the lineage of the file: all the paths it has had, in the chronological order.
+	Paths []FilePathSpan
+}
+
+// FilePathSpan is the range of commits during which a file had the same path.
+type FilePathSpan struct {
+	Path string
+	// First is the commit which created the file or renamed it to Path.
+	First plumbing.Hash
+	// Last is the last commit which changed the file at Path.
+	Last plumbing.Hash
+}
+
+const (
+	// ConfigFileHistoryPath is the name of the option to set FileHistoryAnalysis.Path.
+	ConfigFileHistoryPath = "FileHistory.Path"
+)
+
+// record appends the commit to the history of the file which has the specified path
+// after the commit.
+func (fh *FileHistory) record(path string, commit plumbing.Hash, when time.Time) {
+	fh.Hashes = append(fh.Hashes, commit)
+	fh.Times = append(fh.Times, when)
+	if len(fh.Paths) == 0 || fh.Paths[len(fh.Paths)-1].Path != path {
+		fh.Paths = append(fh.Paths, FilePathSpan{Path: path, First: commit, Last: commit})
+		return
+	}
+	fh.Paths[len(fh.Paths)-1].Last = commit
+}
+
+// clone returns a deep copy of the history.
+func (fh *FileHistory) clone() *FileHistory {
+	result := &FileHistory{
+		Hashes: append([]plumbing.Hash{}, fh.Hashes...),
+		Times:  append([]time.Time{}, fh.Times...),
+		Paths:  append([]FilePathSpan{}, fh.Paths...),
+		People: make(map[int]items.LineStats, len(fh.People)),
+	}
+	for person, stats := range fh.People {
+		result.People[person] = stats
+	}
+	return result
+}
+
+// inherit merges the histories of the files which this file was copied, split or joined from.
+// The commits are merged chronologically, the line statistics are summed and the paths
+// of the sources go before the own paths.
+func (fh *FileHistory) inherit(sources []*FileHistory) {
+	seen := map[plumbing.Hash]bool{}
+	type entry struct {
+		hash plumbing.Hash
+		when time.Time
+	}
+	var entries []entry
+	var paths []FilePathSpan
+	for _, source := range append(sources, fh) {
+		for i, hash := range source.Hashes {
+			if !seen[hash] {
+				seen[hash] = true
+				entries = append(entries, entry{hash, source.Times[i]})
+			}
+		}
+		paths = append(paths, source.Paths...)
+		if source == fh {
+			continue
+		}
+		for person, stats := range source.People {
+			if fh.People == nil {
+				fh.People = map[int]items.LineStats{}
+			}
+			oldStats := fh.People[person]
+			fh.People[person] = items.LineStats{
+				Added:   oldStats.Added + stats.Added,
+				Removed: oldStats.Removed + stats.Removed,
+				Changed: oldStats.Changed + stats.Changed,
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].when.Before(entries[j].when)
+	})
+	fh.Hashes = make([]plumbing.Hash, len(entries))
+	fh.Times = make([]time.Time, len(entries))
+	for i, e := range entries {
+		fh.Hashes[i] = e.hash
+		fh.Times[i] = e.when
+	}
+	fh.Paths = paths
+}
+
+// isUnder returns true if any path of the file equals dir or is inside it.
+func (fh *FileHistory) isUnder(dir string) bool {
+	for _, span := range fh.Paths {
+		if span.Path == dir || strings.HasPrefix(span.Path, dir+"/") {
+			return true
+		}
+	}
+	return false
 }
 
 // Name of this PipelineItem. Uniquely identifies the type, used for mapping keys, etc.
@@ -59,12 +167,21 @@ func (history *FileHistoryAnalysis) Provides() []string {
 // Each requested entity will be inserted into `deps` of Consume(). In turn, those
 // entities are Provides() upstream.
 func (history *FileHistoryAnalysis) Requires() []string {
-	return []string{items.DependencyTreeChanges, items.DependencyLineStats, identity.DependencyAuthor}
+	return []string{
+		items.DependencyTreeChanges, items.DependencyLineStats, identity.DependencyAuthor,
+		items.DependencyFileOrigins}
 }
 
 // ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
 func (history *FileHistoryAnalysis) ListConfigurationOptions() []core.ConfigurationOption {
-	return []core.ConfigurationOption{}
+	return []core.ConfigurationOption{{
+		Name: ConfigFileHistoryPath,
+		Description: "Report only the files which are or have ever been inside this directory " +
+			"or at this path, following the renames.",
+		Flag:    "file-history-path",
+		Type:    core.StringConfigurationOption,
+		Default: ""},
+	}
 }
 
 // Flag for the command line switch which enables this analysis.
@@ -74,9 +191,10 @@ func (history *FileHistoryAnalysis) Flag() string {
 
 // Description returns the text which explains what the analysis is doing.
 func (history *FileHistoryAnalysis) Description() string {
-	return "Each file path is mapped to the list of commits which touch that file and the mapping " +
-		"from involved developers to the corresponding line statistics: how many lines were added, " +
-		"removed and changed throughout the whole history."
+	return "Each file path is mapped to the list of commits which touch that file, the previous " +
+		"paths of the file and the mapping from involved developers to the corresponding line " +
+		"statistics: how many lines were added, removed and changed throughout the whole history, " +
+		"following the renames."
 }
 
 // Configure sets the properties previously published by ListConfigurationOptions().
@@ -84,6 +202,9 @@ func (history *FileHistoryAnalysis) Configure(facts map[string]interface{}) erro
 	if l, exists := facts[core.ConfigLogger].(core.Logger); exists {
 		history.l = l
 	}
+	if val, exists := facts[ConfigFileHistoryPath].(string); exists {
+		history.Path = strings.Trim(val, "/")
+	}
 	return nil
 }
 
@@ -92,6 +213,7 @@ func (history *FileHistoryAnalysis) Configure(facts map[string]interface{}) erro
 func (history *FileHistoryAnalysis) Initialize(repository *git.Repository) error {
 	history.l = core.GetLogger()
 	history.files = map[string]*FileHistory{}
+	history.repository = repository
 	history.OneShotMergeProcessor.Initialize()
 	return nil
 }
@@ -109,32 +231,39 @@ func (history *FileHistoryAnalysis) Consume(deps map[string]interface{}) (map[st
 	}
 	history.lastCommit = deps[core.DependencyCommit].(*object.Commit)
 	commit := history.lastCommit.Hash
+	when := history.lastCommit.Committer.When
 	changes := deps[items.DependencyTreeChanges].(object.Changes)
+	origins, _ := deps[items.DependencyFileOrigins].(map[string]*items.FileOrigin)
+	inherited := history.originSources(changes, origins)
+	// the renamed files are detached first so that swapping the paths works
+	renamed := map[string]*FileHistory{}
 	for _, change := range changes {
-		action, _ := change.Action()
-		var fh *FileHistory
-		if action != merkletrie.Delete {
-			fh = history.files[change.To.Name]
-		} else {
-			fh = history.files[change.From.Name]
+		if action, _ := change.Action(); action == merkletrie.Modify && change.From.Name != change.To.Name {
+			renamed[change.To.Name] = history.files[change.From.Name]
+			delete(history.files, change.From.Name)
 		}
+	}
+	for name, fh := range renamed {
 		if fh == nil {
 			fh = &FileHistory{}
-			history.files[change.To.Name] = fh
 		}
-		switch action {
-		case merkletrie.Insert:
-			fh.Hashes = []plumbing.Hash{commit}
-		case merkletrie.Delete:
-			fh.Hashes = append(fh.Hashes, commit)
-		case merkletrie.Modify:
-			hashes := history.files[change.From.Name].Hashes
-			if change.From.Name != change.To.Name {
-				delete(history.files, change.From.Name)
-			}
-			hashes = append(hashes, commit)
-			fh.Hashes = hashes
+		history.files[name] = fh
+	}
+	for _, change := range changes {
+		action, _ := change.Action()
+		name := change.To.Name
+		if action == merkletrie.Delete {
+			name = change.From.Name
+		}
+		fh := history.files[name]
+		if fh == nil || action == merkletrie.Insert {
+			fh = &FileHistory{}
+			history.files[name] = fh
+		}
+		if sources := inherited[name]; len(sources) > 0 {
+			fh.inherit(sources)
 		}
+		fh.record(name, commit, when)
 	}
 	lineStats := deps[items.DependencyLineStats].(map[object.ChangeEntry]items.LineStats)
 	author := deps[identity.DependencyAuthor].(int)
@@ -159,6 +288,35 @@ func (history *FileHistoryAnalysis) Consume(deps map[string]interface{}) (map[st
 	return nil, nil
 }
 
+// originSources returns the histories of the files which the new files were copied, split
+// or joined from, as they were before the commit. The own previous version of a joined file
+// is excluded.
+func (history *FileHistoryAnalysis) originSources(
+	changes object.Changes, origins map[string]*items.FileOrigin) map[string][]*FileHistory {
+
+	if len(origins) == 0 {
+		return nil
+	}
+	previous := map[string]string{}
+	for _, change := range changes {
+		if action, _ := change.Action(); action == merkletrie.Modify {
+			previous[change.To.Name] = change.From.Name
+		}
+	}
+	result := map[string][]*FileHistory{}
+	for name, origin := range origins {
+		for _, source := range origin.Sources {
+			if prev, exists := previous[name]; exists && prev == source {
+				continue
+			}
+			if fh := history.files[source]; fh != nil {
+				result[name] = append(result[name], fh.clone())
+			}
+		}
+	}
+	return result
+}
+
 // Finalize returns the result of the analysis. Further Consume() calls are not expected.
 func (history *FileHistoryAnalysis) Finalize() interface{} {
 	files := map[string]FileHistory{}
@@ -168,7 +326,7 @@ func (history *FileHistoryAnalysis) Finalize() interface{} {
 		return err
 	}
 	err = fileIter.ForEach(func(file *object.File) error {
-		if fh := history.files[file.Name]; fh != nil {
+		if fh := history.files[file.Name]; fh != nil && (history.Path == "" || fh.isUnder(history.Path)) {
 			files[file.Name] = *fh
 		}
 		return nil
@@ -185,6 +343,73 @@ func (history *FileHistoryAnalysis) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(history, n)
 }
 
+// fileHistoryCheckpoint is the state of FileHistoryAnalysis saved in core.Checkpoint.
+type fileHistoryCheckpoint struct {
+	Files      map[string]fileHistoryCheckpointEntry
+	LastCommit string
+}
+
+// fileHistoryCheckpointEntry is FileHistory with the hashes converted to strings.
+type fileHistoryCheckpointEntry struct {
+	Hashes []string
+	Times  []time.Time
+	People map[int]items.LineStats
+	Paths  []fileHistoryCheckpointPath
+}
+
+// fileHistoryCheckpointPath is FilePathSpan with the hashes converted to strings.
+type fileHistoryCheckpointPath struct {
+	Path, First, Last string
+}
+
+// Checkpoint writes the per-file histories. It implements core.CheckpointablePipelineItem.
+func (history *FileHistoryAnalysis) Checkpoint(writer io.Writer) error {
+	state := fileHistoryCheckpoint{
+		Files: make(map[string]fileHistoryCheckpointEntry, len(history.files)),
+	}
+	for name, fh := range history.files {
+		entry := fileHistoryCheckpointEntry{
+			Hashes: core.HashesToStrings(fh.Hashes), Times: fh.Times, People: fh.People}
+		for _, span := range fh.Paths {
+			entry.Paths = append(entry.Paths, fileHistoryCheckpointPath{
+				Path: span.Path, First: span.First.String(), Last: span.Last.String()})
+		}
+		state.Files[name] = entry
+	}
+	if history.lastCommit != nil {
+		state.LastCommit = history.lastCommit.Hash.String()
+	}
+	return core.EncodeCheckpoint(writer, state)
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (history *FileHistoryAnalysis) Restore(reader io.Reader) error {
+	state := fileHistoryCheckpoint{}
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
+	}
+	for name, entry := range state.Files {
+		fh := &FileHistory{
+			Hashes: core.StringsToHashes(entry.Hashes), Times: entry.Times, People: entry.People}
+		for _, span := range entry.Paths {
+			fh.Paths = append(fh.Paths, FilePathSpan{
+				Path: span.Path, First: plumbing.NewHash(span.First), Last: plumbing.NewHash(span.Last)})
+		}
+		if fh.People == nil {
+			fh.People = map[int]items.LineStats{}
+		}
+		history.files[name] = fh
+	}
+	if state.LastCommit != "" {
+		commit, err := history.repository.CommitObject(plumbing.NewHash(state.LastCommit))
+		if err != nil {
+			return err
+		}
+		history.lastCommit = commit
+	}
+	return nil
+}
+
 // Serialize converts the analysis result as returned by Finalize() to text or bytes.
 // The text format is YAML and the bytes format is Protocol Buffers.
 func (history *FileHistoryAnalysis) Serialize(result interface{}, binary bool, writer io.Writer) error {
@@ -212,8 +437,17 @@ func (history *FileHistoryAnalysis) serializeText(result *FileHistoryResult, wri
 		for i, hash := range hashes {
 			strhashes[i] = "\"" + hash.String() + "\""
 		}
-		sort.Strings(strhashes)
 		fmt.Fprintf(writer, "    commits: [%s]\n", strings.Join(strhashes, ","))
+		strtimes := make([]string, len(file.Times))
+		for i, when := range file.Times {
+			strtimes[i] = strconv.FormatInt(when.Unix(), 10)
+		}
+		fmt.Fprintf(writer, "    times: [%s]\n", strings.Join(strtimes, ","))
+		fmt.Fprintln(writer, "    paths:")
+		for _, span := range file.Paths {
+			fmt.Fprintf(writer, "      - {path: %q, first: \"%s\", last: \"%s\"}\n",
+				span.Path, span.First.String(), span.Last.String())
+		}
 		strpeople := make([]string, 0, len(file.People))
 		for key, val := range file.People {
 			strpeople = append(strpeople, fmt.Sprintf("%d:[%d,%d,%d]", key, val.Added, val.Removed, val.Changed))
@@ -231,10 +465,19 @@ func (history *FileHistoryAnalysis) serializeBinary(result *FileHistoryResult, w
 		fh := &pb.FileHistory{
 			Commits:            make([]string, len(vals.Hashes)),
 			ChangesByDeveloper: map[int32]*pb.LineStats{},
+			Times:              make([]int64, len(vals.Times)),
+			Paths:              make([]*pb.FilePathSpan, len(vals.Paths)),
 		}
 		for i, hash := range vals.Hashes {
 			fh.Commits[i] = hash.String()
 		}
+		for i, when := range vals.Times {
+			fh.Times[i] = when.Unix()
+		}
+		for i, span := range vals.Paths {
+			fh.Paths[i] = &pb.FilePathSpan{
+				Path: span.Path, FirstCommit: span.First.String(), LastCommit: span.Last.String()}
+		}
 		for key, val := range vals.People {
 			fh.ChangesByDeveloper[int32(key)] = &pb.LineStats{
 				Added:   int32(val.Added),
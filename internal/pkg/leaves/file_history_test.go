@@ -2,7 +2,9 @@ package leaves
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/dmytrogajewski/hercules/api/proto/pb"
 	"github.com/dmytrogajewski/hercules/internal/app/core"
@@ -26,17 +28,23 @@ func TestFileHistoryMeta(t *testing.T) {
 	fh := fixtureFileHistory()
 	assert.Equal(t, fh.Name(), "FileHistoryAnalysis")
 	assert.Equal(t, len(fh.Provides()), 0)
-	assert.Equal(t, len(fh.Requires()), 3)
+	assert.Equal(t, len(fh.Requires()), 4)
 	assert.Equal(t, fh.Requires()[0], items.DependencyTreeChanges)
 	assert.Equal(t, fh.Requires()[1], items.DependencyLineStats)
 	assert.Equal(t, fh.Requires()[2], identity.DependencyAuthor)
-	assert.Len(t, fh.ListConfigurationOptions(), 0)
+	assert.Equal(t, fh.Requires()[3], items.DependencyFileOrigins)
+	opts := fh.ListConfigurationOptions()
+	assert.Len(t, opts, 1)
+	assert.Equal(t, ConfigFileHistoryPath, opts[0].Name)
+	assert.Equal(t, "file-history-path", opts[0].Flag)
 	assert.Nil(t, fh.Configure(nil))
 	logger := core.GetLogger()
 	assert.NoError(t, fh.Configure(map[string]interface{}{
-		core.ConfigLogger: logger,
+		core.ConfigLogger:     logger,
+		ConfigFileHistoryPath: "/internal/pkg/",
 	}))
 	assert.Equal(t, logger, fh.l)
+	assert.Equal(t, "internal/pkg", fh.Path)
 }
 
 func TestFileHistoryRegistration(t *testing.T) {
@@ -105,11 +113,18 @@ func TestFileHistorySerializeText(t *testing.T) {
 	res := fh.Finalize().(FileHistoryResult)
 	buffer := &bytes.Buffer{}
 	assert.Nil(t, fh.Serialize(res, false, buffer))
-	assert.Equal(t, buffer.String(), `  - .travis.yml:
+	text := buffer.String()
+	assert.Contains(t, text, `  - .travis.yml:
     commits: ["2b1ed978194a94edeabbca6de7ff3b5771d4d665"]
+`)
+	assert.Contains(t, text, `    paths:
+      - {path: ".travis.yml", first: "2b1ed978194a94edeabbca6de7ff3b5771d4d665", last: "2b1ed978194a94edeabbca6de7ff3b5771d4d665"}
     people: {1:[12,0,0]}
   - cmd/hercules/main.go:
     commits: ["0000000000000000000000000000000000000000","2b1ed978194a94edeabbca6de7ff3b5771d4d665"]
+`)
+	assert.Contains(t, text, `    paths:
+      - {path: "cmd/hercules/main.go", first: "0000000000000000000000000000000000000000", last: "2b1ed978194a94edeabbca6de7ff3b5771d4d665"}
     people: {1:[0,207,0]}
 `)
 }
@@ -133,6 +148,10 @@ func TestFileHistorySerializeBinary(t *testing.T) {
 		map[int32]*pb.LineStats{1: {Added: 12, Removed: 0, Changed: 0}})
 	assert.Equal(t, msg.Files["cmd/hercules/main.go"].ChangesByDeveloper,
 		map[int32]*pb.LineStats{1: {Added: 0, Removed: 207, Changed: 0}})
+	assert.Len(t, msg.Files["cmd/hercules/main.go"].Times, 2)
+	assert.Len(t, msg.Files["cmd/hercules/main.go"].Paths, 1)
+	assert.Equal(t, "0000000000000000000000000000000000000000",
+		msg.Files["cmd/hercules/main.go"].Paths[0].FirstCommit)
 }
 
 func bakeFileHistoryForSerialization(t *testing.T) (*FileHistoryAnalysis, map[string]interface{}) {
@@ -201,12 +220,108 @@ func bakeFileHistoryForSerialization(t *testing.T) (*FileHistoryAnalysis, map[st
 	assert.Nil(t, err)
 	deps[items.DependencyLineStats] = lineStats[items.DependencyLineStats]
 
-	fh.files["cmd/hercules/main.go"] = &FileHistory{Hashes: []plumbing.Hash{plumbing.NewHash(
-		"0000000000000000000000000000000000000000")}}
-	fh.files["analyser.go"] = &FileHistory{Hashes: []plumbing.Hash{plumbing.NewHash(
-		"ffffffffffffffffffffffffffffffffffffffff")}}
+	fh.files["cmd/hercules/main.go"] = &FileHistory{}
+	fh.files["cmd/hercules/main.go"].record("cmd/hercules/main.go", plumbing.NewHash(
+		"0000000000000000000000000000000000000000"), time.Unix(1500000000, 0))
+	fh.files["analyser.go"] = &FileHistory{}
+	fh.files["analyser.go"].record("analyser.go", plumbing.NewHash(
+		"ffffffffffffffffffffffffffffffffffffffff"), time.Unix(1500000000, 0))
 	cres, err := fh.Consume(deps)
 	assert.Nil(t, cres)
 	assert.Nil(t, err)
 	return fh, deps
 }
+
+func fileHistoryRepository() *leafRepository {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	contents := "package a\n\nfunc A() {\n\tprintln(1)\n\tprintln(2)\n}\n"
+	return newLeafRepository(
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start,
+			Files: map[string]string{"secret/a.go": contents, "b.go": "1\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 1),
+			Files: map[string]string{"secret/a.go": "", "lib/a.go": contents, "b.go": "2\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 2),
+			Files: map[string]string{"lib/a.go": contents + "\nfunc B() {}\n"}})
+}
+
+func TestFileHistoryRenames(t *testing.T) {
+	repo := fileHistoryRepository()
+	commits := repo.Commits
+	result := repo.Run(t, &FileHistoryAnalysis{}, commits, nil).(FileHistoryResult)
+	assert.Len(t, result.Files, 2)
+	file := result.Files["lib/a.go"]
+	assert.Equal(t, []plumbing.Hash{commits[0].Hash, commits[1].Hash, commits[2].Hash}, file.Hashes)
+	assert.Equal(t, []time.Time{commits[0].Committer.When, commits[1].Committer.When,
+		commits[2].Committer.When}, file.Times)
+	assert.Equal(t, []FilePathSpan{
+		{Path: "secret/a.go", First: commits[0].Hash, Last: commits[0].Hash},
+		{Path: "lib/a.go", First: commits[1].Hash, Last: commits[2].Hash},
+	}, file.Paths)
+	// Alice's lines written under the old path are kept
+	assert.Equal(t, map[int]items.LineStats{0: ls(6, 0, 0), 1: ls(2, 0, 0)}, file.People)
+
+	result = repo.Run(t, &FileHistoryAnalysis{}, commits, map[string]interface{}{
+		ConfigFileHistoryPath: "secret/"}).(FileHistoryResult)
+	assert.Len(t, result.Files, 1)
+	assert.Contains(t, result.Files, "lib/a.go")
+	result = repo.Run(t, &FileHistoryAnalysis{}, commits, map[string]interface{}{
+		ConfigFileHistoryPath: "lib/a"}).(FileHistoryResult)
+	assert.Len(t, result.Files, 0)
+}
+
+func TestFileHistorySwappedPaths(t *testing.T) {
+	fh := fixtureFileHistory()
+	c1 := plumbing.NewHash("0000000000000000000000000000000000000001")
+	c2 := plumbing.NewHash("0000000000000000000000000000000000000002")
+	h1 := plumbing.NewHash("1000000000000000000000000000000000000000")
+	h2 := plumbing.NewHash("2000000000000000000000000000000000000000")
+	entry := func(name string, hash plumbing.Hash) object.ChangeEntry {
+		return object.ChangeEntry{Name: name, TreeEntry: object.TreeEntry{Name: name, Hash: hash}}
+	}
+	consume := func(hash plumbing.Hash, changes object.Changes) {
+		_, err := fh.Consume(map[string]interface{}{
+			core.DependencyCommit:       &object.Commit{Hash: hash},
+			core.DependencyIsMerge:      false,
+			items.DependencyTreeChanges: changes,
+			items.DependencyLineStats:   map[object.ChangeEntry]items.LineStats{},
+			identity.DependencyAuthor:   0,
+		})
+		assert.NoError(t, err)
+	}
+	consume(c1, object.Changes{{To: entry("x", h1)}, {To: entry("y", h2)}})
+	consume(c2, object.Changes{{From: entry("x", h1), To: entry("y", h1)},
+		{From: entry("y", h2), To: entry("x", h2)}})
+	assert.Equal(t, []FilePathSpan{{Path: "x", First: c1, Last: c1}, {Path: "y", First: c2, Last: c2}},
+		fh.files["y"].Paths)
+	assert.Equal(t, []FilePathSpan{{Path: "y", First: c1, Last: c1}, {Path: "x", First: c2, Last: c2}},
+		fh.files["x"].Paths)
+}
+
+func TestFileHistorySplit(t *testing.T) {
+	code := func(prefix string) string {
+		text := ""
+		for i := 0; i < 6; i++ {
+			text += fmt.Sprintf("func %s%d() int { return %d }\n", prefix, i, i)
+		}
+		return text
+	}
+	repo := newLeafRepository(
+		test.Commit{Author: "Alice", Email: "alice@example.com",
+			Files: map[string]string{"big.go": code("alpha") + code("beta")}},
+		test.Commit{Author: "Bob", Email: "bob@example.com",
+			Files: map[string]string{"big.go": "", "a.go": code("alpha"), "b.go": code("beta")}})
+	commits := repo.Commits
+	facts := map[string]interface{}{
+		items.ConfigRenameAnalysisSimilarityThreshold: items.RenameAnalysisDefaultThreshold}
+	result := repo.Run(t, &FileHistoryAnalysis{}, commits, facts).(FileHistoryResult)
+	assert.Equal(t, []plumbing.Hash{commits[1].Hash}, result.Files["a.go"].Hashes)
+	facts[items.ConfigRenameAnalysisSplits] = true
+	result = repo.Run(t, &FileHistoryAnalysis{}, commits, facts).(FileHistoryResult)
+	file := result.Files["a.go"]
+	assert.Equal(t, []plumbing.Hash{commits[0].Hash, commits[1].Hash}, file.Hashes)
+	assert.Equal(t, []FilePathSpan{
+		{Path: "big.go", First: commits[0].Hash, Last: commits[0].Hash},
+		{Path: "a.go", First: commits[1].Hash, Last: commits[1].Hash},
+	}, file.Paths)
+	assert.Equal(t, map[int]items.LineStats{0: ls(12, 0, 0), 1: ls(6, 0, 0)}, file.People)
+}
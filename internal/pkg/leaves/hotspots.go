@@ -0,0 +1,585 @@
+package leaves
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/dmytrogajewski/hercules/pkg/analyzers/complexity"
+	"github.com/dmytrogajewski/hercules/pkg/uast"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"google.golang.org/protobuf/proto"
+)
+
+// HotspotsAnalysis joins the change frequency and the authors of each file and function
+// with the cyclomatic complexity measured on the UAST of the last commit. The product of
+// the number of commits and the complexity ranks the refactoring targets.
+// It is a LeafPipelineItem.
+type HotspotsAnalysis struct {
+	core.NoopMerger
+	core.OneShotMergeProcessor
+	// Top is the number of the files and the functions with the highest scores to report.
+	// 0 means everything.
+	Top int
+
+	// commits are the analysed non-merge commits, indexed by the identifiers stored in files.
+	commits []hotspotCommit
+	// files map the current paths to the change history of their lines.
+	files      map[string]*hotspotFile
+	lastCommit *object.Commit
+	repository *git.Repository
+	parser     *uast.Parser
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+
+	l core.Logger
+}
+
+// hotspotCommit is the part of an analysed commit which the hotspots need.
+type hotspotCommit struct {
+	Tick   int
+	Author int
+}
+
+// hotspotFile is the change history of a file.
+type hotspotFile struct {
+	// Commits are the identifiers of the commits which changed the file.
+	Commits []int
+	// Lines are the sorted identifiers of the newest commits which changed each line,
+	// at most hotspotLineCommits. The slices are shared between lines and must not be
+	// modified in place.
+	Lines [][]int
+}
+
+// HotspotsResult is returned by HotspotsAnalysis.Finalize() and carries the files and
+// the functions ordered by their scores, the highest first.
+type HotspotsResult struct {
+	Files     []Hotspot
+	Functions []Hotspot
+
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+}
+
+// Hotspot is the churn and the complexity of a file or a function.
+type Hotspot struct {
+	// File is the path of the file in the last commit.
+	File string
+	// Function is the name of the function, empty for files.
+	Function string
+	// StartLine and EndLine are the 1-based line range of the function, zero for files.
+	StartLine int
+	EndLine   int
+	// Commits is the number of commits which changed the file or the lines of the function.
+	// Only the newest 100 commits of each line of a function count.
+	Commits int
+	// Authors are the sorted indices of the developers who made those commits.
+	Authors []int
+	// Complexity is the cyclomatic complexity, summed over the functions for files.
+	Complexity int
+	// Score is Commits × Complexity.
+	Score int
+	// Ticks maps the tick indices to the numbers of commits made during them.
+	Ticks map[int]int
+}
+
+const (
+	// ConfigHotspotsTop is the name of the option to set HotspotsAnalysis.Top.
+	ConfigHotspotsTop = "Hotspots.Top"
+	// DefaultHotspotsTop is the default number of the reported files and functions.
+	DefaultHotspotsTop = 100
+	// hotspotLineCommits is the maximum number of the commits remembered per line. It bounds
+	// the history of the lines which are rewritten over and over.
+	hotspotLineCommits = 100
+)
+
+// Name of this PipelineItem. Uniquely identifies the type, used for mapping keys, etc.
+func (hotspots *HotspotsAnalysis) Name() string {
+	return "Hotspots"
+}
+
+// Provides returns the list of names of entities which are produced by this PipelineItem.
+// Each produced entity will be inserted into `deps` of dependent Consume()-s according
+// to this list. Also used by core.Registry to build the global map of providers.
+func (hotspots *HotspotsAnalysis) Provides() []string {
+	return []string{}
+}
+
+// Requires returns the list of names of entities which are needed by this PipelineItem.
+// Each requested entity will be inserted into `deps` of Consume(). In turn, those
+// entities are Provides() upstream.
+func (hotspots *HotspotsAnalysis) Requires() []string {
+	return []string{
+		items.DependencyTreeChanges, items.DependencyFileDiff, items.DependencyBlobCache,
+		identity.DependencyAuthor, items.DependencyTick}
+}
+
+// ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
+func (hotspots *HotspotsAnalysis) ListConfigurationOptions() []core.ConfigurationOption {
+	options := [...]core.ConfigurationOption{{
+		Name:        ConfigHotspotsTop,
+		Description: "Number of the files and the functions with the highest scores to report; 0 means all.",
+		Flag:        "hotspots-top",
+		Type:        core.IntConfigurationOption,
+		Default:     DefaultHotspotsTop}}
+	return options[:]
+}
+
+// Configure sets the properties previously published by ListConfigurationOptions().
+func (hotspots *HotspotsAnalysis) Configure(facts map[string]interface{}) error {
+	if l, exists := facts[core.ConfigLogger].(core.Logger); exists {
+		hotspots.l = l
+	}
+	if val, exists := facts[ConfigHotspotsTop].(int); exists {
+		hotspots.Top = val
+	}
+	if val, exists := facts[identity.FactIdentityDetectorReversedPeopleDict].([]string); exists {
+		hotspots.reversedPeopleDict = val
+	}
+	if val, exists := facts[items.FactTickSize].(time.Duration); exists {
+		hotspots.tickSize = val
+	}
+	return nil
+}
+
+// Flag for the command line switch which enables this analysis.
+func (hotspots *HotspotsAnalysis) Flag() string {
+	return "hotspots"
+}
+
+// Description returns the text which explains what the analysis is doing.
+func (hotspots *HotspotsAnalysis) Description() string {
+	return "Ranks files and functions by churn × complexity: the number of commits which changed " +
+		"them multiplied by the cyclomatic complexity in the last commit. Reports the authors " +
+		"and the commits per tick of each hotspot."
+}
+
+// Initialize resets the temporary caches and prepares this PipelineItem for a series of Consume()
+// calls. The repository which is going to be analysed is supplied as an argument.
+func (hotspots *HotspotsAnalysis) Initialize(repository *git.Repository) error {
+	hotspots.l = core.GetLogger()
+	if hotspots.Top < 0 {
+		return fmt.Errorf("invalid number of hotspots to report: %d", hotspots.Top)
+	}
+	parser, err := uast.NewParser()
+	if err != nil {
+		return fmt.Errorf("failed to initialize UAST parser: %w", err)
+	}
+	hotspots.parser = parser
+	hotspots.commits = nil
+	hotspots.files = map[string]*hotspotFile{}
+	hotspots.lastCommit = nil
+	hotspots.repository = repository
+	hotspots.OneShotMergeProcessor.Initialize()
+	return nil
+}
+
+// Consume runs this PipelineItem on the next commit data.
+// `deps` contain all the results from upstream PipelineItem-s as requested by Requires().
+// Additionally, DependencyCommit is always present there and represents the analysed *object.Commit.
+// This function returns the mapping with analysis results. The keys must be the same as
+// in Provides(). If there was an error, nil is returned.
+func (hotspots *HotspotsAnalysis) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
+	if !hotspots.ShouldConsumeCommit(deps) {
+		return nil, nil
+	}
+	hotspots.lastCommit = deps[core.DependencyCommit].(*object.Commit)
+	// merge commits update the lines but are not counted, the same as in FileHistoryAnalysis
+	id := -1
+	if !deps[core.DependencyIsMerge].(bool) {
+		id = len(hotspots.commits)
+		hotspots.commits = append(hotspots.commits, hotspotCommit{
+			Tick:   deps[items.DependencyTick].(int),
+			Author: deps[identity.DependencyAuthor].(int),
+		})
+	}
+	var touched []int
+	if id >= 0 {
+		touched = []int{id}
+	}
+	cache := deps[items.DependencyBlobCache].(map[plumbing.Hash]*items.CachedBlob)
+	fileDiffs := deps[items.DependencyFileDiff].(map[string]items.FileDiffData)
+	for _, change := range deps[items.DependencyTreeChanges].(object.Changes) {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		switch action {
+		case merkletrie.Insert:
+			file := &hotspotFile{Commits: touched}
+			if blob := cache[change.To.TreeEntry.Hash]; blob != nil {
+				if lines, err := blob.CountLines(); err == nil {
+					file.Lines = make([][]int, lines)
+					for i := range file.Lines {
+						file.Lines[i] = touched
+					}
+				}
+			}
+			hotspots.files[change.To.Name] = file
+		case merkletrie.Delete:
+			delete(hotspots.files, change.From.Name)
+		case merkletrie.Modify:
+			file := hotspots.files[change.From.Name]
+			if file == nil {
+				file = &hotspotFile{}
+			}
+			delete(hotspots.files, change.From.Name)
+			hotspots.files[change.To.Name] = file
+			if id >= 0 {
+				file.Commits = append(file.Commits, id)
+			}
+			if diff, exists := fileDiffs[change.To.Name]; exists {
+				file.Lines = updateHotspotLines(file.Lines, diff, id)
+			} else {
+				file.Lines = nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// updateHotspotLines applies the diff to the per-line commit identifiers. The replaced lines
+// pass their history to the lines which replace them, and the deleted lines pass it to
+// the next line. The history is reset if the diff does not match the tracked lines, e.g.
+// after a skipped merge. `id` is -1 if the commit must not be recorded.
+func updateHotspotLines(lines [][]int, diff items.FileDiffData, id int) [][]int {
+	if len(lines) != diff.OldLinesOfCode {
+		lines = make([][]int, diff.OldLinesOfCode)
+	}
+	result := make([][]int, 0, diff.NewLinesOfCode)
+	var removed []int
+	deleting := false
+	position := 0
+	for _, edit := range diff.Diffs {
+		size := utf8.RuneCountInString(edit.Text)
+		switch edit.Type {
+		case diffmatchpatch.DiffDelete:
+			for _, line := range lines[position : position+size] {
+				removed = mergeLineCommitIDs(removed, line)
+			}
+			position += size
+			deleting = true
+		case diffmatchpatch.DiffInsert:
+			inserted := []int{}
+			if id >= 0 {
+				inserted = []int{id}
+			}
+			inserted = mergeLineCommitIDs(inserted, removed)
+			for i := 0; i < size; i++ {
+				result = append(result, inserted)
+			}
+			removed, deleting = nil, false
+		case diffmatchpatch.DiffEqual:
+			equal := lines[position : position+size]
+			position += size
+			if deleting && size > 0 {
+				first := mergeLineCommitIDs(equal[0], removed)
+				if id >= 0 {
+					first = mergeLineCommitIDs(first, []int{id})
+				}
+				result = append(result, first)
+				equal = equal[1:]
+			}
+			result = append(result, equal...)
+			removed, deleting = nil, false
+		}
+	}
+	if deleting && len(result) > 0 {
+		last := mergeLineCommitIDs(result[len(result)-1], removed)
+		if id >= 0 {
+			last = mergeLineCommitIDs(last, []int{id})
+		}
+		result[len(result)-1] = last
+	}
+	return result
+}
+
+// mergeCommitIDs returns the sorted union of two sorted identifier lists as a new slice.
+func mergeCommitIDs(a, b []int) []int {
+	result := make([]int, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i] < b[j]):
+			result = append(result, a[i])
+			i++
+		case i == len(a) || b[j] < a[i]:
+			result = append(result, b[j])
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+	return result
+}
+
+// mergeLineCommitIDs is mergeCommitIDs which keeps only the newest hotspotLineCommits
+// identifiers of a line.
+func mergeLineCommitIDs(a, b []int) []int {
+	result := mergeCommitIDs(a, b)
+	if len(result) > hotspotLineCommits {
+		// copy so that the dropped identifiers are freed
+		result = append([]int(nil), result[len(result)-hotspotLineCommits:]...)
+	}
+	return result
+}
+
+// Finalize returns the result of the analysis. Further Consume() calls are not expected.
+func (hotspots *HotspotsAnalysis) Finalize() interface{} {
+	result := HotspotsResult{
+		reversedPeopleDict: hotspots.reversedPeopleDict,
+		tickSize:           hotspots.tickSize,
+	}
+	if hotspots.lastCommit == nil {
+		return result
+	}
+	fileIter, err := hotspots.lastCommit.Files()
+	if err != nil {
+		hotspots.l.Errorf("Failed to iterate files of %s", hotspots.lastCommit.Hash.String())
+		return err
+	}
+	analyzer := complexity.NewComplexityAnalyzer()
+	err = fileIter.ForEach(func(file *object.File) error {
+		history := hotspots.files[file.Name]
+		if history == nil || len(history.Commits) == 0 || !hotspots.parser.IsSupported(file.Name) {
+			return nil
+		}
+		contents, err := file.Contents()
+		if err != nil {
+			return err
+		}
+		root, err := hotspots.parser.Parse(file.Name, []byte(contents))
+		if err != nil {
+			hotspots.l.Warnf("Failed to parse %s: %v", file.Name, err)
+			return nil
+		}
+		functions := analyzer.MeasureFunctions(root)
+		if len(functions) == 0 {
+			return nil
+		}
+		fileSpot := hotspots.newHotspot(file.Name, history.Commits)
+		lines, _ := (&items.CachedBlob{Data: []byte(contents)}).CountLines()
+		if lines != len(history.Lines) {
+			hotspots.l.Warnf("Line history of %s is out of sync, skipped its functions", file.Name)
+		}
+		for _, function := range functions {
+			fileSpot.Complexity += function.CyclomaticComplexity
+			if lines != len(history.Lines) || function.StartLine < 1 || function.EndLine < function.StartLine {
+				continue
+			}
+			end := function.EndLine
+			if end > lines {
+				end = lines
+			}
+			var commits []int
+			for _, line := range history.Lines[function.StartLine-1 : end] {
+				commits = mergeCommitIDs(commits, line)
+			}
+			if len(commits) == 0 {
+				continue
+			}
+			spot := hotspots.newHotspot(file.Name, commits)
+			spot.Function = function.Name
+			spot.StartLine = function.StartLine
+			spot.EndLine = function.EndLine
+			spot.Complexity = function.CyclomaticComplexity
+			spot.Score = spot.Commits * spot.Complexity
+			result.Functions = append(result.Functions, spot)
+		}
+		fileSpot.Score = fileSpot.Commits * fileSpot.Complexity
+		result.Files = append(result.Files, fileSpot)
+		return nil
+	})
+	if err != nil {
+		hotspots.l.Errorf("Failed to iterate files of %s", hotspots.lastCommit.Hash.String())
+		return err
+	}
+	result.Files = rankHotspots(result.Files, hotspots.Top)
+	result.Functions = rankHotspots(result.Functions, hotspots.Top)
+	return result
+}
+
+// newHotspot aggregates the authors and the ticks of the commits.
+func (hotspots *HotspotsAnalysis) newHotspot(file string, commits []int) Hotspot {
+	spot := Hotspot{File: file, Commits: len(commits), Ticks: map[int]int{}}
+	authors := map[int]bool{}
+	for _, id := range commits {
+		commit := hotspots.commits[id]
+		authors[commit.Author] = true
+		spot.Ticks[commit.Tick]++
+	}
+	for author := range authors {
+		spot.Authors = append(spot.Authors, author)
+	}
+	sort.Ints(spot.Authors)
+	return spot
+}
+
+// rankHotspots sorts the hotspots by their scores and keeps the `top` best, all if `top` is 0.
+func rankHotspots(spots []Hotspot, top int) []Hotspot {
+	sort.Slice(spots, func(i, j int) bool {
+		if spots[i].Score != spots[j].Score {
+			return spots[i].Score > spots[j].Score
+		}
+		if spots[i].File != spots[j].File {
+			return spots[i].File < spots[j].File
+		}
+		return spots[i].StartLine < spots[j].StartLine
+	})
+	if top > 0 && len(spots) > top {
+		spots = spots[:top]
+	}
+	return spots
+}
+
+// Fork clones this PipelineItem.
+func (hotspots *HotspotsAnalysis) Fork(n int) []core.PipelineItem {
+	return core.ForkSamePipelineItem(hotspots, n)
+}
+
+// hotspotsCheckpoint is the state of HotspotsAnalysis saved in core.Checkpoint.
+type hotspotsCheckpoint struct {
+	Commits    []hotspotCommit
+	Files      map[string]*hotspotFile
+	LastCommit string
+}
+
+// Checkpoint writes the commits and the line histories. It implements core.CheckpointablePipelineItem.
+func (hotspots *HotspotsAnalysis) Checkpoint(writer io.Writer) error {
+	state := hotspotsCheckpoint{Commits: hotspots.commits, Files: hotspots.files}
+	if hotspots.lastCommit != nil {
+		state.LastCommit = hotspots.lastCommit.Hash.String()
+	}
+	return core.EncodeCheckpoint(writer, state)
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (hotspots *HotspotsAnalysis) Restore(reader io.Reader) error {
+	state := hotspotsCheckpoint{}
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
+	}
+	hotspots.commits = state.Commits
+	hotspots.files = state.Files
+	if hotspots.files == nil {
+		hotspots.files = map[string]*hotspotFile{}
+	}
+	if state.LastCommit != "" {
+		commit, err := hotspots.repository.CommitObject(plumbing.NewHash(state.LastCommit))
+		if err != nil {
+			return err
+		}
+		hotspots.lastCommit = commit
+	}
+	return nil
+}
+
+// Serialize converts the analysis result as returned by Finalize() to text or bytes.
+// The text format is YAML and the bytes format is Protocol Buffers.
+func (hotspots *HotspotsAnalysis) Serialize(result interface{}, binary bool, writer io.Writer) error {
+	hotspotsResult := result.(HotspotsResult)
+	if binary {
+		return hotspots.serializeBinary(&hotspotsResult, writer)
+	}
+	hotspots.serializeText(&hotspotsResult, writer)
+	return nil
+}
+
+func (hotspots *HotspotsAnalysis) serializeText(result *HotspotsResult, writer io.Writer) {
+	writeSpots := func(spots []Hotspot) {
+		for _, spot := range spots {
+			fmt.Fprintf(writer, "    - file: %s\n", spot.File)
+			if spot.Function != "" {
+				fmt.Fprintf(writer, "      function: %s\n", spot.Function)
+				fmt.Fprintf(writer, "      lines: [%d, %d]\n", spot.StartLine, spot.EndLine)
+			}
+			fmt.Fprintf(writer, "      score: %d\n", spot.Score)
+			fmt.Fprintf(writer, "      commits: %d\n", spot.Commits)
+			fmt.Fprintf(writer, "      complexity: %d\n", spot.Complexity)
+			authors := make([]string, len(spot.Authors))
+			for i, author := range spot.Authors {
+				authors[i] = fmt.Sprint(author)
+			}
+			fmt.Fprintf(writer, "      authors: [%s]\n", strings.Join(authors, ", "))
+			ticks := make([]int, 0, len(spot.Ticks))
+			for tick := range spot.Ticks {
+				ticks = append(ticks, tick)
+			}
+			sort.Ints(ticks)
+			trend := make([]string, len(ticks))
+			for i, tick := range ticks {
+				trend[i] = fmt.Sprintf("%d: %d", tick, spot.Ticks[tick])
+			}
+			fmt.Fprintf(writer, "      ticks: {%s}\n", strings.Join(trend, ", "))
+		}
+	}
+	fmt.Fprintln(writer, "  files:")
+	writeSpots(result.Files)
+	fmt.Fprintln(writer, "  functions:")
+	writeSpots(result.Functions)
+	fmt.Fprintln(writer, "  people:")
+	for _, person := range result.reversedPeopleDict {
+		fmt.Fprintf(writer, "  - %s\n", person)
+	}
+	fmt.Fprintln(writer, "  tick_size:", int(result.tickSize.Seconds()))
+}
+
+func (hotspots *HotspotsAnalysis) serializeBinary(result *HotspotsResult, writer io.Writer) error {
+	convert := func(spots []Hotspot) []*pb.Hotspot {
+		converted := make([]*pb.Hotspot, len(spots))
+		for i, spot := range spots {
+			message := &pb.Hotspot{
+				File:       spot.File,
+				Function:   spot.Function,
+				StartLine:  int32(spot.StartLine),
+				EndLine:    int32(spot.EndLine),
+				Commits:    int32(spot.Commits),
+				Authors:    make([]int32, len(spot.Authors)),
+				Complexity: int32(spot.Complexity),
+				Score:      int64(spot.Score),
+				Ticks:      make(map[int32]int32, len(spot.Ticks)),
+			}
+			for j, author := range spot.Authors {
+				message.Authors[j] = int32(author)
+			}
+			for tick, commits := range spot.Ticks {
+				message.Ticks[int32(tick)] = int32(commits)
+			}
+			converted[i] = message
+		}
+		return converted
+	}
+	message := pb.HotspotsResults{
+		Files:       convert(result.Files),
+		Functions:   convert(result.Functions),
+		AuthorIndex: result.reversedPeopleDict,
+		TickSize:    int64(result.tickSize),
+	}
+	serialized, err := proto.Marshal(&message)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(serialized)
+	return err
+}
+
+func init() {
+	core.Registry.Register(&HotspotsAnalysis{})
+}
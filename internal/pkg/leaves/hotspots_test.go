@@ -0,0 +1,220 @@
+package leaves
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHotspotsMeta(t *testing.T) {
+	hotspots := &HotspotsAnalysis{}
+	assert.Equal(t, "Hotspots", hotspots.Name())
+	assert.Equal(t, "hotspots", hotspots.Flag())
+	assert.Len(t, hotspots.Provides(), 0)
+	assert.Equal(t, []string{
+		items.DependencyTreeChanges, items.DependencyFileDiff, items.DependencyBlobCache,
+		identity.DependencyAuthor, items.DependencyTick}, hotspots.Requires())
+	opts := hotspots.ListConfigurationOptions()
+	assert.Len(t, opts, 1)
+	assert.Equal(t, ConfigHotspotsTop, opts[0].Name)
+	logger := core.GetLogger()
+	assert.NoError(t, hotspots.Configure(map[string]interface{}{
+		core.ConfigLogger: logger,
+		ConfigHotspotsTop: 5,
+		identity.FactIdentityDetectorReversedPeopleDict: []string{"one"},
+	}))
+	assert.Equal(t, logger, hotspots.l)
+	assert.Equal(t, 5, hotspots.Top)
+	assert.Equal(t, []string{"one"}, hotspots.reversedPeopleDict)
+	hotspots.Top = -1
+	assert.Error(t, hotspots.Initialize(test.Repository))
+}
+
+func TestHotspotsRegistration(t *testing.T) {
+	testLeafRegistration(t, &HotspotsAnalysis{})
+}
+
+func TestHotspotsFork(t *testing.T) {
+	hotspots := &HotspotsAnalysis{}
+	clones := hotspots.Fork(1)
+	assert.Len(t, clones, 1)
+	assert.True(t, hotspots == clones[0].(*HotspotsAnalysis))
+}
+
+func TestUpdateHotspotLines(t *testing.T) {
+	lines := [][]int{{0}, {0}, {0, 1}, {1}}
+	diff := items.FileDiffData{OldLinesOfCode: 4, NewLinesOfCode: 4, Diffs: []diffmatchpatch.Diff{
+		{Type: diffmatchpatch.DiffEqual, Text: "a"},
+		{Type: diffmatchpatch.DiffDelete, Text: "b"},
+		{Type: diffmatchpatch.DiffInsert, Text: "xy"},
+		{Type: diffmatchpatch.DiffEqual, Text: "c"},
+		{Type: diffmatchpatch.DiffDelete, Text: "d"},
+	}}
+	assert.Equal(t, [][]int{{0}, {0, 2}, {0, 2}, {0, 1, 2}}, updateHotspotLines(lines, diff, 2))
+
+	diff = items.FileDiffData{OldLinesOfCode: 2, NewLinesOfCode: 2, Diffs: []diffmatchpatch.Diff{
+		{Type: diffmatchpatch.DiffDelete, Text: "a"},
+		{Type: diffmatchpatch.DiffEqual, Text: "b"},
+		{Type: diffmatchpatch.DiffInsert, Text: "c"},
+	}}
+	// merges do not record themselves
+	assert.Equal(t, [][]int{{0, 1}, {}}, updateHotspotLines([][]int{{0}, {1}}, diff, -1))
+	// out of sync history is reset
+	assert.Equal(t, [][]int{{3}, {3}}, updateHotspotLines([][]int{{0}}, diff, 3))
+
+	// only the newest commits are remembered
+	history := make([]int, hotspotLineCommits)
+	for i := range history {
+		history[i] = i
+	}
+	lines = updateHotspotLines([][]int{history, {0}}, diff, hotspotLineCommits)
+	require.Len(t, lines, 2)
+	assert.Len(t, lines[0], hotspotLineCommits)
+	assert.Equal(t, 1, lines[0][0])
+	assert.Equal(t, hotspotLineCommits, lines[0][hotspotLineCommits-1])
+}
+
+func TestMergeCommitIDs(t *testing.T) {
+	assert.Equal(t, []int{0, 1, 2, 5}, mergeCommitIDs([]int{0, 2, 5}, []int{1, 2}))
+	assert.Equal(t, []int{1}, mergeCommitIDs(nil, []int{1}))
+	assert.Equal(t, []int{}, mergeCommitIDs(nil, nil))
+}
+
+const hotspotsComplexFunction = `func complex(x int) int {
+	for i := 0; i < x; i++ {
+		if i > 10 {
+			return i
+		}
+	}
+	return 0
+}
+`
+
+func hotspotsRepository() *leafRepository {
+	return newLeafRepository(
+		test.Commit{Author: "Alice", Email: "alice@example.com", Files: map[string]string{
+			"a.go": "package main\n\nfunc simple() int {\n\treturn 1\n}\n\n" +
+				hotspotsComplexFunction,
+			"b.go":       "package main\n\nfunc other() {\n}\n",
+			"README.txt": "readme\n",
+		}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", Files: map[string]string{
+			"a.go": "package main\n\nfunc simple() int {\n\treturn 1\n}\n\n" +
+				strings.Replace(hotspotsComplexFunction, "10", "20", 1),
+			"README.txt": "readme\nmore\n",
+		}},
+		test.Commit{Author: "Carol", Email: "carol@example.com", Files: map[string]string{
+			"a.go": "package main\n\nfunc added() {\n}\n\nfunc simple() int {\n\treturn 1\n}\n\n" +
+				strings.Replace(hotspotsComplexFunction, "return 0", "return -1", 1),
+			"b.go": "",
+		}})
+}
+
+func TestHotspotsPipeline(t *testing.T) {
+	repo := hotspotsRepository()
+	result := repo.Run(t, &HotspotsAnalysis{}, repo.Commits, nil).(HotspotsResult)
+	assert.Equal(t, []string{"alice|alice@example.com", "bob|bob@example.com", "carol|carol@example.com"},
+		result.reversedPeopleDict)
+	require.Len(t, result.Files, 1)
+	file := result.Files[0]
+	assert.Equal(t, "a.go", file.File)
+	assert.Equal(t, 3, file.Commits)
+	assert.Equal(t, []int{0, 1, 2}, file.Authors)
+	assert.Equal(t, map[int]int{0: 1, 1: 1, 2: 1}, file.Ticks)
+	assert.True(t, file.Complexity > 0)
+	assert.Equal(t, file.Commits*file.Complexity, file.Score)
+
+	require.Len(t, result.Functions, 3)
+	byName := map[string]Hotspot{}
+	for _, function := range result.Functions {
+		assert.Equal(t, "a.go", function.File)
+		assert.Equal(t, function.Commits*function.Complexity, function.Score)
+		byName[function.Function] = function
+	}
+	assert.Equal(t, "complex", result.Functions[0].Function)
+	assert.Equal(t, 10, byName["complex"].StartLine)
+	assert.Equal(t, 17, byName["complex"].EndLine)
+	assert.Equal(t, 3, byName["complex"].Commits)
+	assert.Equal(t, []int{0, 1, 2}, byName["complex"].Authors)
+	assert.True(t, byName["complex"].Complexity > byName["simple"].Complexity)
+	assert.Equal(t, 1, byName["simple"].Commits)
+	assert.Equal(t, []int{0}, byName["simple"].Authors)
+	assert.Equal(t, map[int]int{0: 1}, byName["simple"].Ticks)
+	assert.Equal(t, 1, byName["added"].Commits)
+	assert.Equal(t, []int{2}, byName["added"].Authors)
+	assert.Equal(t, map[int]int{2: 1}, byName["added"].Ticks)
+
+	top := repo.Run(t, &HotspotsAnalysis{}, repo.Commits,
+		map[string]interface{}{ConfigHotspotsTop: 1}).(HotspotsResult)
+	assert.Len(t, top.Functions, 1)
+	assert.Equal(t, "complex", top.Functions[0].Function)
+}
+
+func TestHotspotsCheckpoint(t *testing.T) {
+	repo := hotspotsRepository()
+	full := repo.Run(t, &HotspotsAnalysis{}, repo.Commits, nil).(HotspotsResult)
+	resumed := repo.Resume(t, &HotspotsAnalysis{}, 2, nil).(HotspotsResult)
+	assert.Equal(t, full.Files, resumed.Files)
+	assert.Equal(t, full.Functions, resumed.Functions)
+}
+
+func TestHotspotsSerialize(t *testing.T) {
+	hotspots := &HotspotsAnalysis{}
+	result := HotspotsResult{
+		Files: []Hotspot{{File: "a.go", Commits: 3, Authors: []int{0, 1}, Complexity: 4, Score: 12,
+			Ticks: map[int]int{2: 1, 0: 2}}},
+		Functions: []Hotspot{{File: "a.go", Function: "f", StartLine: 3, EndLine: 9, Commits: 2,
+			Authors: []int{1}, Complexity: 3, Score: 6, Ticks: map[int]int{2: 1, 0: 1}}},
+		reversedPeopleDict: []string{"one", "two"},
+		tickSize:           24 * 3600 * 1e9,
+	}
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, hotspots.Serialize(result, false, buffer))
+	assert.Equal(t, `  files:
+    - file: a.go
+      score: 12
+      commits: 3
+      complexity: 4
+      authors: [0, 1]
+      ticks: {0: 2, 2: 1}
+  functions:
+    - file: a.go
+      function: f
+      lines: [3, 9]
+      score: 6
+      commits: 2
+      complexity: 3
+      authors: [1]
+      ticks: {0: 1, 2: 1}
+  people:
+  - one
+  - two
+  tick_size: 86400
+`, buffer.String())
+
+	buffer.Reset()
+	assert.NoError(t, hotspots.Serialize(result, true, buffer))
+	message := pb.HotspotsResults{}
+	assert.NoError(t, proto.Unmarshal(buffer.Bytes(), &message))
+	assert.Equal(t, []string{"one", "two"}, message.AuthorIndex)
+	assert.Equal(t, int64(24*3600*1e9), message.TickSize)
+	require.Len(t, message.Files, 1)
+	assert.Equal(t, int64(12), message.Files[0].Score)
+	assert.Equal(t, "", message.Files[0].Function)
+	require.Len(t, message.Functions, 1)
+	assert.Equal(t, "f", message.Functions[0].Function)
+	assert.Equal(t, int32(3), message.Functions[0].StartLine)
+	assert.Equal(t, int32(9), message.Functions[0].EndLine)
+	assert.Equal(t, []int32{1}, message.Functions[0].Authors)
+	assert.Equal(t, map[int32]int32{0: 1, 2: 1}, message.Functions[0].Ticks)
+}
@@ -155,6 +155,16 @@ func (ipd *ImportsPerDeveloper) Finalize() interface{} {
 	}
 }
 
+// Checkpoint writes the collected imports. It implements core.CheckpointablePipelineItem.
+func (ipd *ImportsPerDeveloper) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, ipd.imports)
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (ipd *ImportsPerDeveloper) Restore(reader io.Reader) error {
+	return core.DecodeCheckpoint(reader, &ipd.imports)
+}
+
 // Fork clones this PipelineItem.
 func (ipd *ImportsPerDeveloper) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(ipd, n)
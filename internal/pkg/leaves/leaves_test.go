@@ -0,0 +1,88 @@
+package leaves
+
+import (
+	"bytes"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// leafRepository is the in-memory history which the pipeline tests of the leaves analyse.
+type leafRepository struct {
+	repository *git.Repository
+	Commits    []*object.Commit
+}
+
+func newLeafRepository(commits ...test.Commit) *leafRepository {
+	repository, objects := test.NewRepository(commits...)
+	return &leafRepository{repository: repository, Commits: objects}
+}
+
+// Run analyses the commits with a new instance of the leaf and new instances of its
+// dependencies, so that the tests never share the configured state. The facts override
+// the defaults. Returns the result of the leaf.
+func (repo *leafRepository) Run(t testing.TB, prototype core.LeafPipelineItem,
+	commits []*object.Commit, facts map[string]interface{}) interface{} {
+	pipeline := core.NewPipeline(repo.repository)
+	leaf := pipeline.DeployItemInstances(
+		reflect.New(reflect.TypeOf(prototype).Elem()).Interface().(core.PipelineItem))
+	allFacts := map[string]interface{}{core.ConfigPipelineCommits: commits}
+	for key, val := range facts {
+		allFacts[key] = val
+	}
+	require.NoError(t, pipeline.Initialize(allFacts))
+	result, err := pipeline.Run(commits)
+	require.NoError(t, err)
+	return result[leaf.(core.LeafPipelineItem)]
+}
+
+// Resume analyses the first split commits and saves the checkpoint, then analyses the rest
+// of the commits resuming from it. Returns the result of the resumed run.
+func (repo *leafRepository) Resume(t testing.TB, prototype core.LeafPipelineItem, split int,
+	facts map[string]interface{}) interface{} {
+	checkpointPath := path.Join(t.TempDir(), "checkpoint")
+	allFacts := map[string]interface{}{core.ConfigPipelineCheckpointPath: checkpointPath}
+	for key, val := range facts {
+		allFacts[key] = val
+	}
+	repo.Run(t, prototype, repo.Commits[:split], allFacts)
+	checkpoint, err := core.ReadCheckpoint(checkpointPath)
+	require.NoError(t, err)
+	delete(allFacts, core.ConfigPipelineCheckpointPath)
+	allFacts[core.ConfigPipelineResume] = checkpoint
+	return repo.Run(t, prototype, repo.Commits[split:], allFacts)
+}
+
+// testLeafRegistration checks that the leaf is summoned by its name and listed by its flag.
+func testLeafRegistration(t *testing.T, leaf core.LeafPipelineItem) {
+	summoned := core.Registry.Summon(leaf.Name())
+	require.Len(t, summoned, 1)
+	assert.Equal(t, leaf.Name(), summoned[0].Name())
+	matched := false
+	for _, tp := range core.Registry.GetLeaves() {
+		if tp.Flag() == leaf.Flag() {
+			matched = true
+			break
+		}
+	}
+	assert.True(t, matched)
+}
+
+// testLeafBinaryRoundTrip checks that the result survives the binary serialization and
+// that the leaf refuses to serialize the result of another leaf.
+func testLeafBinaryRoundTrip(t *testing.T, leaf core.ResultMergeablePipelineItem,
+	result interface{}) {
+	buffer := &bytes.Buffer{}
+	require.NoError(t, leaf.Serialize(result, true, buffer))
+	deserialized, err := leaf.Deserialize(buffer.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, result, deserialized)
+	assert.Error(t, leaf.Serialize(BurndownResult{}, false, buffer))
+}
@@ -0,0 +1,664 @@
+package leaves
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
+	"google.golang.org/protobuf/proto"
+)
+
+// OwnershipAnalysis calculates the line ownership of every file and directory per developer,
+// the bus factor and the amount of code owned by the developers who stopped committing.
+// The lines are tracked by BurndownAnalysis, and the state is sampled with the same period as
+// the burndown (--sampling). The samples are taken only while a single branch is analysed, so
+// the periods which end while several branches are alive get the state after they merge.
+// It is a LeafPipelineItem.
+type OwnershipAnalysis struct {
+	// InactiveTicks is the number of ticks without commits after which a developer is
+	// considered gone and their lines orphaned.
+	InactiveTicks int
+
+	// burndown is the fork of BurndownAnalysis which consumed the last commit.
+	burndown *BurndownAnalysis
+	// state is shared between the forks.
+	state *ownershipState
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+
+	l core.Logger
+}
+
+// ownershipState is the sampling progress of OwnershipAnalysis.
+type ownershipState struct {
+	// Samples are taken at the end of each sampling period.
+	Samples []OwnershipSample
+	// LastActive maps the developers to the ticks of their latest commits.
+	LastActive map[int]int
+	// Period is the index of the current sampling period.
+	Period int
+	// LastTick is the latest consumed tick.
+	LastTick int
+	// Started is true after the first commit.
+	Started bool
+	// Sampling is BurndownAnalysis.Sampling
+	Sampling int
+
+	// branches are the forks which analyse commits and were not merged yet.
+	branches map[*OwnershipAnalysis]bool
+}
+
+// OwnershipResult is returned by OwnershipAnalysis.Finalize() and carries the ownership
+// samples ordered by tick.
+type OwnershipResult struct {
+	Samples []OwnershipSample
+
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+	// sampling is BurndownAnalysis.Sampling
+	sampling      int
+	inactiveTicks int
+}
+
+// OwnershipSample is the ownership of the files and the directories at the end of a tick.
+type OwnershipSample struct {
+	// Tick is the last tick of the sampling period, or the last analysed tick for the last sample.
+	Tick int
+	// Files map the paths to their ownership.
+	Files map[string]OwnershipEntry
+	// Directories map the paths to the ownership of all the files inside them, recursively.
+	// "." is the root directory.
+	Directories map[string]OwnershipEntry
+}
+
+// OwnershipEntry is the ownership of a file or a directory.
+type OwnershipEntry struct {
+	// Lines is the total number of lines.
+	Lines int
+	// Owners map the developer indexes to the numbers of lines they own. -1 is an unknown developer.
+	Owners map[int]int
+	// BusFactor is the minimum number of developers who together own at least half of the lines.
+	BusFactor int
+	// Orphaned is the number of lines owned by the developers who have been inactive for
+	// longer than OwnershipAnalysis.InactiveTicks.
+	Orphaned int
+}
+
+const (
+	// ConfigOwnershipInactiveTicks is the name of the option to set OwnershipAnalysis.InactiveTicks.
+	ConfigOwnershipInactiveTicks = "Ownership.InactiveTicks"
+	// DefaultOwnershipInactiveTicks is the default value of OwnershipAnalysis.InactiveTicks.
+	DefaultOwnershipInactiveTicks = 180
+	// ownershipRoot is the name of the root directory in OwnershipSample.Directories.
+	ownershipRoot = "."
+)
+
+// Name of this PipelineItem. Uniquely identifies the type, used for mapping keys, etc.
+func (ownership *OwnershipAnalysis) Name() string {
+	return "Ownership"
+}
+
+// Provides returns the list of names of entities which are produced by this PipelineItem.
+// Each produced entity will be inserted into `deps` of dependent Consume()-s according
+// to this list. Also used by core.Registry to build the global map of providers.
+func (ownership *OwnershipAnalysis) Provides() []string {
+	return []string{}
+}
+
+// Requires returns the list of names of entities which are needed by this PipelineItem.
+// Each requested entity will be inserted into `deps` of Consume(). In turn, those
+// entities are Provides() upstream.
+func (ownership *OwnershipAnalysis) Requires() []string {
+	return []string{
+		DependencyBurndownLines, items.DependencyTreeChanges, identity.DependencyAuthor,
+		items.DependencyTick,
+	}
+}
+
+// ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
+// The sampling is shared with BurndownAnalysis.
+func (ownership *OwnershipAnalysis) ListConfigurationOptions() []core.ConfigurationOption {
+	options := [...]core.ConfigurationOption{{
+		Name:        ConfigOwnershipInactiveTicks,
+		Description: "Number of ticks without commits after which the lines of a developer are orphaned.",
+		Flag:        "ownership-inactive-ticks",
+		Type:        core.IntConfigurationOption,
+		Default:     DefaultOwnershipInactiveTicks}}
+	return options[:]
+}
+
+// Configure sets the properties previously published by ListConfigurationOptions().
+func (ownership *OwnershipAnalysis) Configure(facts map[string]interface{}) error {
+	if l, exists := facts[core.ConfigLogger].(core.Logger); exists {
+		ownership.l = l
+	}
+	if val, exists := facts[ConfigOwnershipInactiveTicks].(int); exists {
+		ownership.InactiveTicks = val
+	}
+	if val, exists := facts[identity.FactIdentityDetectorReversedPeopleDict].([]string); exists {
+		ownership.reversedPeopleDict = val
+	}
+	if val, exists := facts[items.FactTickSize].(time.Duration); exists {
+		ownership.tickSize = val
+	}
+	subscribeBurndownLines(facts, true)
+	return nil
+}
+
+// Flag for the command line switch which enables this analysis.
+func (ownership *OwnershipAnalysis) Flag() string {
+	return "ownership"
+}
+
+// Description returns the text which explains what the analysis is doing.
+func (ownership *OwnershipAnalysis) Description() string {
+	return "Calculates the line ownership per developer, the bus factor and the code orphaned by " +
+		"inactive developers of every file and directory through time."
+}
+
+// Initialize resets the temporary caches and prepares this PipelineItem for a series of Consume()
+// calls. The repository which is going to be analysed is supplied as an argument.
+func (ownership *OwnershipAnalysis) Initialize(repository *git.Repository) error {
+	ownership.l = core.GetLogger()
+	if ownership.InactiveTicks < 0 {
+		return fmt.Errorf("InactiveTicks is negative: %d", ownership.InactiveTicks)
+	}
+	if ownership.tickSize == 0 {
+		ownership.tickSize = items.DefaultTicksSinceStartTickSize * time.Hour
+	}
+	ownership.burndown = nil
+	ownership.state = &ownershipState{
+		LastActive: map[int]int{},
+		branches:   map[*OwnershipAnalysis]bool{},
+	}
+	return nil
+}
+
+// Consume runs this PipelineItem on the next commit data.
+// `deps` contain all the results from upstream PipelineItem-s as requested by Requires().
+// Additionally, DependencyCommit is always present there and represents the analysed *object.Commit.
+// This function returns the mapping with analysis results. The keys must be the same as
+// in Provides(). If there was an error, nil is returned.
+func (ownership *OwnershipAnalysis) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
+	state := ownership.state
+	lines := deps[DependencyBurndownLines].(BurndownLines)
+	ownership.burndown = lines.burndown
+	state.Sampling = lines.burndown.Sampling
+	state.branches[ownership] = true
+	tick := deps[items.DependencyTick].(int)
+	period := tick / state.Sampling
+	if !state.Started {
+		state.Started = true
+		state.Period = period
+	} else if period > state.Period && len(state.branches) == 1 &&
+		!deps[core.DependencyIsMerge].(bool) {
+		// the commit starts a new period, so the state before it is the end of the previous ones
+		owners := ownershipBefore(
+			fileOwners(lines.burndown), lines.Updates, deps[items.DependencyTreeChanges].(object.Changes))
+		for ; state.Period < period; state.Period++ {
+			end := (state.Period+1)*state.Sampling - 1
+			state.Samples = append(state.Samples, ownership.sample(end, owners))
+		}
+	}
+	if author := deps[identity.DependencyAuthor].(int); author != identity.AuthorMissing &&
+		tick >= state.LastActive[author] {
+		state.LastActive[author] = tick
+	}
+	if tick > state.LastTick {
+		state.LastTick = tick
+	}
+	return nil, nil
+}
+
+// fileOwners returns the line ownership of the files tracked by the burndown.
+func fileOwners(analyser *BurndownAnalysis) map[string]map[int]int {
+	result := make(map[string]map[int]int, len(analyser.files))
+	for name, file := range analyser.files {
+		if owned := analyser.fileOwnership(file); len(owned) > 0 {
+			result[name] = owned
+		}
+	}
+	return result
+}
+
+// ownershipBefore reverts the line updates and the renames of a commit in the ownership
+// of the files.
+func ownershipBefore(owners map[string]map[int]int, updates []LineUpdate,
+	changes object.Changes) map[string]map[int]int {
+	for _, update := range updates {
+		owned := owners[update.File]
+		if owned == nil {
+			owned = map[int]int{}
+			owners[update.File] = owned
+		}
+		author := update.PreviousAuthor
+		if author == identity.AuthorMissing {
+			author = -1
+		}
+		owned[author] -= update.Delta
+	}
+	for _, change := range changes {
+		action, _ := change.Action()
+		if action == merkletrie.Modify && change.From.Name != change.To.Name {
+			if owned, exists := owners[change.To.Name]; exists {
+				owners[change.From.Name] = owned
+				delete(owners, change.To.Name)
+			}
+		}
+	}
+	for name, owned := range owners {
+		for dev, lines := range owned {
+			if lines <= 0 {
+				delete(owned, dev)
+			}
+		}
+		if len(owned) == 0 {
+			delete(owners, name)
+		}
+	}
+	return owners
+}
+
+// sample aggregates the ownership of the files into the directories and finds the orphaned lines.
+func (ownership *OwnershipAnalysis) sample(tick int, owners map[string]map[int]int) OwnershipSample {
+	sample := OwnershipSample{
+		Tick:        tick,
+		Files:       make(map[string]OwnershipEntry, len(owners)),
+		Directories: map[string]OwnershipEntry{},
+	}
+	directories := map[string]map[int]int{}
+	for name, owned := range owners {
+		sample.Files[name] = ownership.newEntry(tick, owned)
+		for dir := path.Dir(name); ; dir = path.Dir(dir) {
+			dirOwners := directories[dir]
+			if dirOwners == nil {
+				dirOwners = map[int]int{}
+				directories[dir] = dirOwners
+			}
+			for dev, lines := range owned {
+				dirOwners[dev] += lines
+			}
+			if dir == ownershipRoot {
+				break
+			}
+		}
+	}
+	for dir, owned := range directories {
+		sample.Directories[dir] = ownership.newEntry(tick, owned)
+	}
+	return sample
+}
+
+func (ownership *OwnershipAnalysis) newEntry(tick int, owners map[int]int) OwnershipEntry {
+	entry := OwnershipEntry{Owners: owners}
+	for dev, lines := range owners {
+		entry.Lines += lines
+		if lastActive, exists := ownership.state.LastActive[dev]; exists &&
+			tick-lastActive > ownership.InactiveTicks {
+			entry.Orphaned += lines
+		}
+	}
+	entry.BusFactor = busFactor(owners, entry.Lines)
+	return entry
+}
+
+// busFactor returns the minimum number of known developers who own at least half of the lines.
+func busFactor(owners map[int]int, lines int) int {
+	counts := make([]int, 0, len(owners))
+	for dev, owned := range owners {
+		if dev >= 0 && owned > 0 {
+			counts = append(counts, owned)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+	covered := 0
+	for i, owned := range counts {
+		covered += owned
+		if covered*2 >= lines {
+			return i + 1
+		}
+	}
+	return len(counts)
+}
+
+// Finalize returns the result of the analysis. Further Consume() calls are not expected.
+func (ownership *OwnershipAnalysis) Finalize() interface{} {
+	state := ownership.state
+	result := OwnershipResult{
+		reversedPeopleDict: ownership.reversedPeopleDict,
+		tickSize:           ownership.tickSize,
+		sampling:           state.Sampling,
+		inactiveTicks:      ownership.InactiveTicks,
+	}
+	if !state.Started || ownership.burndown == nil {
+		return result
+	}
+	result.Samples = make([]OwnershipSample, len(state.Samples))
+	copy(result.Samples, state.Samples)
+	// the periods which were not sampled get the final state
+	owners := fileOwners(ownership.burndown)
+	for period := state.Period; period < state.LastTick/state.Sampling; period++ {
+		end := (period+1)*state.Sampling - 1
+		result.Samples = append(result.Samples, ownership.sample(end, owners))
+	}
+	result.Samples = append(result.Samples, ownership.sample(state.LastTick, owners))
+	return result
+}
+
+// Fork clones this item. The sampling state is shared.
+func (ownership *OwnershipAnalysis) Fork(n int) []core.PipelineItem {
+	clones := core.ForkCopyPipelineItem(ownership, n)
+	if ownership.state.branches[ownership] {
+		// the clones are the new branches, unlike those of the items which have not consumed yet
+		for _, clone := range clones {
+			ownership.state.branches[clone.(*OwnershipAnalysis)] = true
+		}
+	}
+	return clones
+}
+
+// Merge combines several items together. The merged branches are no longer sampled.
+func (ownership *OwnershipAnalysis) Merge(branches []core.PipelineItem) {
+	for _, branch := range branches {
+		delete(ownership.state.branches, branch.(*OwnershipAnalysis))
+	}
+}
+
+// Checkpoint writes the samples. It implements core.CheckpointablePipelineItem.
+func (ownership *OwnershipAnalysis) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, *ownership.state)
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (ownership *OwnershipAnalysis) Restore(reader io.Reader) error {
+	state := ownershipState{}
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
+	}
+	state.branches = ownership.state.branches
+	*ownership.state = state
+	if ownership.state.LastActive == nil {
+		ownership.state.LastActive = map[int]int{}
+	}
+	return nil
+}
+
+// Serialize converts the analysis result as returned by Finalize() to text or bytes.
+// The text format is YAML and the bytes format is Protocol Buffers.
+func (ownership *OwnershipAnalysis) Serialize(result interface{}, binary bool, writer io.Writer) error {
+	ownershipResult, ok := result.(OwnershipResult)
+	if !ok {
+		return fmt.Errorf("result is not an ownership result: '%v'", result)
+	}
+	if binary {
+		return ownership.serializeBinary(&ownershipResult, writer)
+	}
+	ownership.serializeText(&ownershipResult, writer)
+	return nil
+}
+
+func (ownership *OwnershipAnalysis) serializeText(result *OwnershipResult, writer io.Writer) {
+	writeEntries := func(entries map[string]OwnershipEntry) {
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			entry := entries[key]
+			devs := make([]int, 0, len(entry.Owners))
+			for dev := range entry.Owners {
+				devs = append(devs, dev)
+			}
+			sort.Ints(devs)
+			owners := make([]string, len(devs))
+			for i, dev := range devs {
+				owners[i] = fmt.Sprintf("%d: %d", dev, entry.Owners[dev])
+			}
+			fmt.Fprintf(writer, "        %s: {lines: %d, bus_factor: %d, orphaned: %d, owners: {%s}}\n",
+				key, entry.Lines, entry.BusFactor, entry.Orphaned, strings.Join(owners, ", "))
+		}
+	}
+	fmt.Fprintln(writer, "  sampling:", result.sampling)
+	fmt.Fprintln(writer, "  inactive_ticks:", result.inactiveTicks)
+	fmt.Fprintln(writer, "  tick_size:", int(result.tickSize.Seconds()))
+	fmt.Fprintln(writer, "  samples:")
+	for _, sample := range result.Samples {
+		fmt.Fprintf(writer, "    - tick: %d\n", sample.Tick)
+		fmt.Fprintln(writer, "      directories:")
+		writeEntries(sample.Directories)
+		fmt.Fprintln(writer, "      files:")
+		writeEntries(sample.Files)
+	}
+	fmt.Fprintln(writer, "  people:")
+	for _, person := range result.reversedPeopleDict {
+		fmt.Fprintf(writer, "  - %s\n", person)
+	}
+}
+
+func (ownership *OwnershipAnalysis) serializeBinary(result *OwnershipResult, writer io.Writer) error {
+	convert := func(entries map[string]OwnershipEntry) map[string]*pb.OwnershipEntry {
+		converted := make(map[string]*pb.OwnershipEntry, len(entries))
+		for key, entry := range entries {
+			message := &pb.OwnershipEntry{
+				Lines:     int32(entry.Lines),
+				Owners:    make(map[int32]int32, len(entry.Owners)),
+				BusFactor: int32(entry.BusFactor),
+				Orphaned:  int32(entry.Orphaned),
+			}
+			for dev, lines := range entry.Owners {
+				message.Owners[int32(dev)] = int32(lines)
+			}
+			converted[key] = message
+		}
+		return converted
+	}
+	message := pb.OwnershipAnalysisResults{
+		Samples:       make([]*pb.OwnershipSample, len(result.Samples)),
+		AuthorIndex:   result.reversedPeopleDict,
+		TickSize:      int64(result.tickSize),
+		Sampling:      int32(result.sampling),
+		InactiveTicks: int32(result.inactiveTicks),
+	}
+	for i, sample := range result.Samples {
+		message.Samples[i] = &pb.OwnershipSample{
+			Tick:        int32(sample.Tick),
+			Files:       convert(sample.Files),
+			Directories: convert(sample.Directories),
+		}
+	}
+	serialized, err := proto.Marshal(&message)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(serialized)
+	return err
+}
+
+// Deserialize converts the specified protobuf bytes to OwnershipResult.
+func (ownership *OwnershipAnalysis) Deserialize(pbmessage []byte) (interface{}, error) {
+	message := pb.OwnershipAnalysisResults{}
+	if err := proto.Unmarshal(pbmessage, &message); err != nil {
+		return nil, err
+	}
+	convert := func(entries map[string]*pb.OwnershipEntry) map[string]OwnershipEntry {
+		converted := make(map[string]OwnershipEntry, len(entries))
+		for key, entry := range entries {
+			owners := make(map[int]int, len(entry.Owners))
+			for dev, lines := range entry.Owners {
+				owners[int(dev)] = int(lines)
+			}
+			converted[key] = OwnershipEntry{
+				Lines:     int(entry.Lines),
+				Owners:    owners,
+				BusFactor: int(entry.BusFactor),
+				Orphaned:  int(entry.Orphaned),
+			}
+		}
+		return converted
+	}
+	result := OwnershipResult{
+		Samples:            make([]OwnershipSample, len(message.Samples)),
+		reversedPeopleDict: message.AuthorIndex,
+		tickSize:           time.Duration(message.TickSize),
+		sampling:           int(message.Sampling),
+		inactiveTicks:      int(message.InactiveTicks),
+	}
+	for i, sample := range message.Samples {
+		result.Samples[i] = OwnershipSample{
+			Tick:        int(sample.Tick),
+			Files:       convert(sample.Files),
+			Directories: convert(sample.Directories),
+		}
+	}
+	return result, nil
+}
+
+// MergeResults combines two OwnershipResult-s together. The samples are aligned in time and
+// the ownership of the paths which exist in both is summed. At each tick, a result
+// contributes its latest sample at or before that tick.
+func (ownership *OwnershipAnalysis) MergeResults(
+	r1, r2 interface{}, c1, c2 *core.CommonAnalysisResult) interface{} {
+	or1 := r1.(OwnershipResult)
+	or2 := r2.(OwnershipResult)
+	if or1.tickSize != or2.tickSize {
+		return fmt.Errorf("mismatching tick sizes (r1: %d, r2: %d) received",
+			or1.tickSize, or2.tickSize)
+	}
+	if or1.tickSize == 0 {
+		return errors.New("tick size is not set")
+	}
+	t01 := items.FloorTime(c1.BeginTimeAsTime(), or1.tickSize)
+	t02 := items.FloorTime(c2.BeginTimeAsTime(), or2.tickSize)
+	t0 := t01
+	if t02.Before(t0) {
+		t0 = t02
+	}
+	offset1 := int(t01.Sub(t0) / or1.tickSize)
+	offset2 := int(t02.Sub(t0) / or2.tickSize)
+
+	merged := OwnershipResult{
+		tickSize:      or1.tickSize,
+		sampling:      or1.sampling,
+		inactiveTicks: or1.inactiveTicks,
+	}
+	var mergedIndex map[string]identity.MergedIndex
+	mergedIndex, merged.reversedPeopleDict = identity.MergeReversedDictsIdentities(
+		or1.reversedPeopleDict, or2.reversedPeopleDict)
+	remap := func(result OwnershipResult, offset int) []OwnershipSample {
+		remapEntries := func(entries map[string]OwnershipEntry) map[string]OwnershipEntry {
+			remapped := make(map[string]OwnershipEntry, len(entries))
+			for key, entry := range entries {
+				owners := make(map[int]int, len(entry.Owners))
+				for dev, lines := range entry.Owners {
+					if dev >= 0 && dev < len(result.reversedPeopleDict) {
+						dev = mergedIndex[result.reversedPeopleDict[dev]].Final
+					} else {
+						dev = -1
+					}
+					owners[dev] += lines
+				}
+				entry.Owners = owners
+				remapped[key] = entry
+			}
+			return remapped
+		}
+		samples := make([]OwnershipSample, len(result.Samples))
+		for i, sample := range result.Samples {
+			samples[i] = OwnershipSample{
+				Tick:        sample.Tick + offset,
+				Files:       remapEntries(sample.Files),
+				Directories: remapEntries(sample.Directories),
+			}
+		}
+		return samples
+	}
+	samples1 := remap(or1, offset1)
+	samples2 := remap(or2, offset2)
+	ticks := map[int]bool{}
+	for _, sample := range append(samples1[:len(samples1):len(samples1)], samples2...) {
+		ticks[sample.Tick] = true
+	}
+	sortedTicks := make([]int, 0, len(ticks))
+	for tick := range ticks {
+		sortedTicks = append(sortedTicks, tick)
+	}
+	sort.Ints(sortedTicks)
+	latest := func(samples []OwnershipSample, tick int) *OwnershipSample {
+		var found *OwnershipSample
+		for i := range samples {
+			if samples[i].Tick > tick {
+				break
+			}
+			found = &samples[i]
+		}
+		return found
+	}
+	mergeEntries := func(dst map[string]OwnershipEntry, src map[string]OwnershipEntry) {
+		for key, entry := range src {
+			existing, exists := dst[key]
+			if !exists {
+				dst[key] = entry
+				continue
+			}
+			owners := make(map[int]int, len(existing.Owners)+len(entry.Owners))
+			for dev, lines := range existing.Owners {
+				owners[dev] += lines
+			}
+			for dev, lines := range entry.Owners {
+				owners[dev] += lines
+			}
+			combined := OwnershipEntry{
+				Lines:    existing.Lines + entry.Lines,
+				Owners:   owners,
+				Orphaned: existing.Orphaned + entry.Orphaned,
+			}
+			combined.BusFactor = busFactor(owners, combined.Lines)
+			dst[key] = combined
+		}
+	}
+	for _, tick := range sortedTicks {
+		sample := OwnershipSample{
+			Tick: tick, Files: map[string]OwnershipEntry{}, Directories: map[string]OwnershipEntry{}}
+		for _, samples := range [][]OwnershipSample{samples1, samples2} {
+			if found := latest(samples, tick); found != nil {
+				mergeEntries(sample.Files, found.Files)
+				mergeEntries(sample.Directories, found.Directories)
+			}
+		}
+		merged.Samples = append(merged.Samples, sample)
+	}
+	return merged
+}
+
+// GetTickSize returns the tick size used to generate this ownership analysis result.
+func (or OwnershipResult) GetTickSize() time.Duration {
+	return or.tickSize
+}
+
+// GetIdentities returns the list of developer identities used to generate this ownership
+// analysis result. The format is |-joined keys, see internals/plumbing/identity for details.
+func (or OwnershipResult) GetIdentities() []string {
+	return or.reversedPeopleDict
+}
+
+var _ core.PipelineItem = (*OwnershipAnalysis)(nil)
+
+func init() {
+	core.Registry.Register(&OwnershipAnalysis{})
+}
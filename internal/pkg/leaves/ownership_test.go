@@ -0,0 +1,241 @@
+package leaves
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/burndown"
+	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOwnershipMeta(t *testing.T) {
+	ownership := &OwnershipAnalysis{}
+	assert.Equal(t, "Ownership", ownership.Name())
+	assert.Equal(t, "ownership", ownership.Flag())
+	assert.Len(t, ownership.Provides(), 0)
+	assert.Equal(t, []string{
+		DependencyBurndownLines, items.DependencyTreeChanges, identity.DependencyAuthor,
+		items.DependencyTick,
+	}, ownership.Requires())
+	opts := ownership.ListConfigurationOptions()
+	assert.Len(t, opts, 1)
+	assert.Equal(t, ConfigOwnershipInactiveTicks, opts[0].Name)
+	subscription := &burndownSubscription{}
+	assert.NoError(t, ownership.Configure(map[string]interface{}{
+		ConfigOwnershipInactiveTicks: 7,
+		factBurndownSubscription:     subscription,
+	}))
+	assert.Equal(t, 7, ownership.InactiveTicks)
+	assert.Equal(t, burndownSubscription{lines: true, authors: true}, *subscription)
+	ownership.InactiveTicks = -1
+	assert.Error(t, ownership.Initialize(test.Repository))
+}
+
+func TestOwnershipRegistration(t *testing.T) {
+	testLeafRegistration(t, &OwnershipAnalysis{})
+}
+
+func TestBusFactor(t *testing.T) {
+	assert.Equal(t, 0, busFactor(map[int]int{}, 0))
+	assert.Equal(t, 1, busFactor(map[int]int{0: 5, 1: 5}, 10))
+	assert.Equal(t, 2, busFactor(map[int]int{0: 3, 1: 2, 2: 2}, 7))
+	// unknown developers never count
+	assert.Equal(t, 1, busFactor(map[int]int{-1: 8, 0: 2}, 10))
+	assert.Equal(t, 0, busFactor(map[int]int{-1: 5}, 5))
+	// the known developers who do not reach half of the lines all count
+	assert.Equal(t, 2, busFactor(map[int]int{-1: 8, 0: 1, 1: 1}, 10))
+	// developers who own nothing do not count
+	assert.Equal(t, 1, busFactor(map[int]int{0: 0, 1: 3, 2: 0}, 3))
+	// the biggest owners count first regardless of the identities
+	assert.Equal(t, 1, busFactor(map[int]int{0: 1, 1: 1, 2: 1, 3: 7}, 10))
+	assert.Equal(t, 3, busFactor(map[int]int{0: 2, 1: 2, 2: 2, 3: 2, 4: 2}, 10))
+}
+
+func ownershipRepository() *leafRepository {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return newLeafRepository(
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start, Files: map[string]string{
+			"a/x.go": "1\n2\n3\n4\n", "b.txt": "1\n2\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 1),
+			Files: map[string]string{"a/x.go": "1\n2\n5\n6\n"}},
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start.AddDate(0, 0, 2),
+			Files: map[string]string{"b.txt": "1\n2\n3\n"}},
+		test.Commit{Author: "Carol", Email: "carol@example.com", When: start.AddDate(0, 0, 5),
+			Files: map[string]string{"a/y.go": "1\n2\n3\n"}})
+}
+
+var ownershipFacts = map[string]interface{}{
+	ConfigBurndownGranularity:    2,
+	ConfigBurndownSampling:       2,
+	ConfigOwnershipInactiveTicks: 2,
+}
+
+func TestOwnershipPipeline(t *testing.T) {
+	repo := ownershipRepository()
+	result := repo.Run(t, &OwnershipAnalysis{}, repo.Commits, ownershipFacts).(OwnershipResult)
+	assert.Equal(t, []string{"alice|alice@example.com", "bob|bob@example.com", "carol|carol@example.com"},
+		result.reversedPeopleDict)
+	require.Len(t, result.Samples, 3)
+	first, second, last := result.Samples[0], result.Samples[1], result.Samples[2]
+	assert.Equal(t, 1, first.Tick)
+	assert.Equal(t, 3, second.Tick)
+	assert.Equal(t, 5, last.Tick)
+
+	assert.Equal(t, OwnershipEntry{Lines: 4, Owners: map[int]int{0: 2, 1: 2}, BusFactor: 1},
+		first.Files["a/x.go"])
+	assert.Equal(t, OwnershipEntry{Lines: 2, Owners: map[int]int{0: 2}, BusFactor: 1},
+		first.Files["b.txt"])
+	assert.Equal(t, OwnershipEntry{Lines: 6, Owners: map[int]int{0: 4, 1: 2}, BusFactor: 1},
+		first.Directories["."])
+	assert.Equal(t, first.Files["a/x.go"], first.Directories["a"])
+
+	assert.Equal(t, OwnershipEntry{Lines: 3, Owners: map[int]int{0: 3}, BusFactor: 1},
+		second.Files["b.txt"])
+	assert.Equal(t, 0, second.Directories["."].Orphaned)
+
+	// Alice and Bob have not committed for more than 2 ticks
+	assert.Equal(t, OwnershipEntry{Lines: 7, Owners: map[int]int{0: 2, 1: 2, 2: 3}, BusFactor: 2,
+		Orphaned: 4}, last.Directories["a"])
+	assert.Equal(t, OwnershipEntry{Lines: 10, Owners: map[int]int{0: 5, 1: 2, 2: 3}, BusFactor: 1,
+		Orphaned: 7}, last.Directories["."])
+	assert.Equal(t, OwnershipEntry{Lines: 3, Owners: map[int]int{2: 3}, BusFactor: 1},
+		last.Files["a/y.go"])
+}
+
+func TestOwnershipBefore(t *testing.T) {
+	owners := map[string]map[int]int{
+		"a.go": {0: 2, 1: 3},
+		"c.go": {1: 4},
+		"d.go": {2: 1},
+	}
+	changes := object.Changes{
+		&object.Change{From: object.ChangeEntry{Name: "b.go"}, To: object.ChangeEntry{Name: "c.go"}},
+	}
+	// a.go: 1 line of 0 was replaced with 2 lines of 1, c.go: b.go renamed and extended
+	// by 1, d.go: created, e.go: deleted
+	before := ownershipBefore(owners, []LineUpdate{
+		{File: "a.go", PreviousAuthor: 0, Delta: -1},
+		{File: "a.go", CurrentAuthor: 1, PreviousAuthor: 1, Delta: 2},
+		{File: "c.go", CurrentAuthor: 1, PreviousAuthor: 1, Delta: 1},
+		{File: "d.go", CurrentAuthor: 2, PreviousAuthor: 2, Delta: 1},
+		{File: "e.go", PreviousAuthor: identity.AuthorMissing, Delta: -5},
+	}, changes)
+	assert.Equal(t, map[string]map[int]int{
+		"a.go": {0: 3, 1: 1},
+		"b.go": {1: 3},
+		"e.go": {-1: 5},
+	}, before)
+}
+
+func TestOwnershipBranches(t *testing.T) {
+	ownership := &OwnershipAnalysis{}
+	require.NoError(t, ownership.Initialize(test.Repository))
+	lines := BurndownLines{burndown: &BurndownAnalysis{Sampling: 1, files: map[string]*burndown.File{}}}
+	consume := func(item core.PipelineItem, tick int) {
+		_, err := item.Consume(map[string]interface{}{
+			DependencyBurndownLines:     lines,
+			items.DependencyTreeChanges: object.Changes{},
+			identity.DependencyAuthor:   0,
+			items.DependencyTick:        tick,
+			core.DependencyIsMerge:      false,
+		})
+		require.NoError(t, err)
+	}
+	// the items which have not consumed yet are not branches
+	assert.Len(t, ownership.Fork(1)[0].(*OwnershipAnalysis).state.branches, 0)
+	consume(ownership, 0)
+	branch := ownership.Fork(1)[0]
+	consume(ownership, 1)
+	consume(branch, 2)
+	assert.Len(t, ownership.state.Samples, 0)
+	ownership.Merge([]core.PipelineItem{branch})
+	consume(ownership, 3)
+	require.Len(t, ownership.state.Samples, 3)
+	for i, sample := range ownership.state.Samples {
+		assert.Equal(t, i, sample.Tick)
+	}
+}
+
+func TestOwnershipCheckpoint(t *testing.T) {
+	repo := ownershipRepository()
+	full := repo.Run(t, &OwnershipAnalysis{}, repo.Commits, ownershipFacts).(OwnershipResult)
+	resumed := repo.Resume(t, &OwnershipAnalysis{}, 3, ownershipFacts).(OwnershipResult)
+	assert.Equal(t, full.Samples, resumed.Samples)
+}
+
+func TestOwnershipSerialize(t *testing.T) {
+	repo := ownershipRepository()
+	result := repo.Run(t, &OwnershipAnalysis{}, repo.Commits[:2], ownershipFacts).(OwnershipResult)
+	ownership := &OwnershipAnalysis{}
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, ownership.Serialize(result, false, buffer))
+	assert.Equal(t, `  sampling: 2
+  inactive_ticks: 2
+  tick_size: 86400
+  samples:
+    - tick: 1
+      directories:
+        .: {lines: 6, bus_factor: 1, orphaned: 0, owners: {0: 4, 1: 2}}
+        a: {lines: 4, bus_factor: 1, orphaned: 0, owners: {0: 2, 1: 2}}
+      files:
+        a/x.go: {lines: 4, bus_factor: 1, orphaned: 0, owners: {0: 2, 1: 2}}
+        b.txt: {lines: 2, bus_factor: 1, orphaned: 0, owners: {0: 2}}
+  people:
+  - alice|alice@example.com
+  - bob|bob@example.com
+`, buffer.String())
+
+	testLeafBinaryRoundTrip(t, ownership, result)
+}
+
+func TestOwnershipMergeResults(t *testing.T) {
+	entry := func(owners map[int]int, orphaned int) OwnershipEntry {
+		lines := 0
+		for _, n := range owners {
+			lines += n
+		}
+		return OwnershipEntry{Lines: lines, Owners: owners, BusFactor: busFactor(owners, lines),
+			Orphaned: orphaned}
+	}
+	r1 := OwnershipResult{
+		Samples: []OwnershipSample{
+			{Tick: 0, Files: map[string]OwnershipEntry{"a": entry(map[int]int{0: 4}, 0)},
+				Directories: map[string]OwnershipEntry{".": entry(map[int]int{0: 4}, 0)}},
+			{Tick: 2, Files: map[string]OwnershipEntry{"a": entry(map[int]int{0: 4, 1: 2}, 4)},
+				Directories: map[string]OwnershipEntry{".": entry(map[int]int{0: 4, 1: 2}, 4)}},
+		},
+		reversedPeopleDict: []string{"alice", "bob"},
+		tickSize:           24 * time.Hour,
+		sampling:           2,
+	}
+	r2 := OwnershipResult{
+		Samples: []OwnershipSample{
+			{Tick: 0, Files: map[string]OwnershipEntry{"a": entry(map[int]int{0: 3, -1: 1}, 0)},
+				Directories: map[string]OwnershipEntry{".": entry(map[int]int{0: 3, -1: 1}, 0)}},
+		},
+		reversedPeopleDict: []string{"bob"},
+		tickSize:           24 * time.Hour,
+		sampling:           2,
+	}
+	c1 := core.CommonAnalysisResult{BeginTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+	c2 := core.CommonAnalysisResult{BeginTime: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix()}
+	merged := (&OwnershipAnalysis{}).MergeResults(r1, r2, &c1, &c2).(OwnershipResult)
+	assert.Equal(t, []string{"alice", "bob"}, merged.reversedPeopleDict)
+	require.Len(t, merged.Samples, 3)
+	assert.Equal(t, []int{0, 1, 2}, []int{
+		merged.Samples[0].Tick, merged.Samples[1].Tick, merged.Samples[2].Tick})
+	assert.Equal(t, entry(map[int]int{0: 4}, 0), merged.Samples[0].Files["a"])
+	// r2 starts one tick later and its bob is r1's bob
+	assert.Equal(t, entry(map[int]int{0: 4, 1: 3, -1: 1}, 0), merged.Samples[1].Files["a"])
+	assert.Equal(t, entry(map[int]int{0: 4, 1: 5, -1: 1}, 4), merged.Samples[2].Directories["."])
+
+	r2.tickSize = time.Hour
+	assert.Error(t, (&OwnershipAnalysis{}).MergeResults(r1, r2, &c1, &c2).(error))
+}
@@ -260,6 +260,40 @@ func (tdb *TyposDatasetBuilder) Finalize() interface{} {
 	return TyposResult{Typos: typos}
 }
 
+// typoCheckpoint is Typo with the commit hash converted to string.
+type typoCheckpoint struct {
+	Wrong   string
+	Correct string
+	Commit  string
+	File    string
+	Line    int
+}
+
+// Checkpoint writes the found typos. It implements core.CheckpointablePipelineItem.
+func (tdb *TyposDatasetBuilder) Checkpoint(writer io.Writer) error {
+	state := make([]typoCheckpoint, len(tdb.typos))
+	for i, typo := range tdb.typos {
+		state[i] = typoCheckpoint{
+			Wrong: typo.Wrong, Correct: typo.Correct, Commit: typo.Commit.String(),
+			File: typo.File, Line: typo.Line}
+	}
+	return core.EncodeCheckpoint(writer, state)
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (tdb *TyposDatasetBuilder) Restore(reader io.Reader) error {
+	var state []typoCheckpoint
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
+	}
+	for _, typo := range state {
+		tdb.typos = append(tdb.typos, Typo{
+			Wrong: typo.Wrong, Correct: typo.Correct, Commit: plumbing.NewHash(typo.Commit),
+			File: typo.File, Line: typo.Line})
+	}
+	return nil
+}
+
 // Fork clones this pipeline item.
 func (tdb *TyposDatasetBuilder) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(tdb, n)
@@ -0,0 +1,454 @@
+package leaves
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/go-git/go-git/v6"
+	"google.golang.org/protobuf/proto"
+)
+
+// ReworkAnalysis measures how much new code is rewritten or deleted soon after it was written,
+// per developer, file and tick. The lines are tracked by BurndownAnalysis which reports
+// the authors and the ticks of the updated lines in DependencyBurndownLines.
+// It is a LeafPipelineItem.
+type ReworkAnalysis struct {
+	core.NoopMerger
+	// Ticks is the maximum age in ticks of the removed lines which count as rework.
+	Ticks int
+
+	// state is shared between the forks.
+	state *reworkState
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+
+	l core.Logger
+}
+
+// reworkState is the accumulated ReworkStats of ReworkAnalysis.
+type reworkState struct {
+	Ticks  map[int]ReworkStats
+	People map[int]ReworkStats
+	Files  map[string]ReworkStats
+}
+
+// ReworkStats counts the new lines and how many of them were rewritten or deleted soon.
+type ReworkStats struct {
+	// Added is the number of the written lines.
+	Added int
+	// Reworked is the number of the written lines which were removed or rewritten no more than
+	// ReworkAnalysis.Ticks ticks later.
+	Reworked int
+	// SelfReworked is the part of Reworked which was removed by the author of the lines.
+	SelfReworked int
+}
+
+// ReworkResult is returned by ReworkAnalysis.Finalize() and carries the rework statistics.
+// The lines which were written less than ReworkAnalysis.Ticks ticks before the end of the
+// analysed history may still be reworked, so the latest ratios are lower bounds.
+type ReworkResult struct {
+	// Ticks maps the ticks to the stats of the lines written in them.
+	Ticks map[int]ReworkStats
+	// People maps the developers to the stats of the lines which they wrote.
+	People map[int]ReworkStats
+	// Files maps the paths to the stats of the lines written in them.
+	Files map[string]ReworkStats
+
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+	// reworkTicks references ReworkAnalysis.Ticks
+	reworkTicks int
+}
+
+const (
+	// ConfigReworkTicks is the name of the option to set ReworkAnalysis.Ticks.
+	ConfigReworkTicks = "Rework.Ticks"
+	// DefaultReworkTicks is the default value of ReworkAnalysis.Ticks.
+	DefaultReworkTicks = 21
+)
+
+// Ratio returns the share of the written lines which were reworked.
+func (stats ReworkStats) Ratio() float64 {
+	if stats.Added == 0 {
+		return 0
+	}
+	return float64(stats.Reworked) / float64(stats.Added)
+}
+
+func (stats ReworkStats) add(other ReworkStats) ReworkStats {
+	return ReworkStats{
+		Added:        stats.Added + other.Added,
+		Reworked:     stats.Reworked + other.Reworked,
+		SelfReworked: stats.SelfReworked + other.SelfReworked,
+	}
+}
+
+// Name of this PipelineItem. Uniquely identifies the type, used for mapping keys, etc.
+func (rework *ReworkAnalysis) Name() string {
+	return "Rework"
+}
+
+// Provides returns the list of names of entities which are produced by this PipelineItem.
+// Each produced entity will be inserted into `deps` of dependent Consume()-s according
+// to this list. Also used by core.Registry to build the global map of providers.
+func (rework *ReworkAnalysis) Provides() []string {
+	return []string{}
+}
+
+// Requires returns the list of names of entities which are needed by this PipelineItem.
+// Each requested entity will be inserted into `deps` of Consume(). In turn, those
+// entities are Provides() upstream.
+func (rework *ReworkAnalysis) Requires() []string {
+	return []string{DependencyBurndownLines}
+}
+
+// ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
+func (rework *ReworkAnalysis) ListConfigurationOptions() []core.ConfigurationOption {
+	options := [...]core.ConfigurationOption{{
+		Name:        ConfigReworkTicks,
+		Description: "Removed lines which are at most this many ticks old count as rework.",
+		Flag:        "rework-ticks",
+		Type:        core.IntConfigurationOption,
+		Default:     DefaultReworkTicks}}
+	return options[:]
+}
+
+// Configure sets the properties previously published by ListConfigurationOptions().
+func (rework *ReworkAnalysis) Configure(facts map[string]interface{}) error {
+	if l, exists := facts[core.ConfigLogger].(core.Logger); exists {
+		rework.l = l
+	}
+	if val, exists := facts[ConfigReworkTicks].(int); exists {
+		rework.Ticks = val
+	}
+	if val, exists := facts[identity.FactIdentityDetectorReversedPeopleDict].([]string); exists {
+		rework.reversedPeopleDict = val
+	}
+	if val, exists := facts[items.FactTickSize].(time.Duration); exists {
+		rework.tickSize = val
+	}
+	subscribeBurndownLines(facts, true)
+	return nil
+}
+
+// Flag for the command line switch which enables this analysis.
+func (rework *ReworkAnalysis) Flag() string {
+	return "rework"
+}
+
+// Description returns the text which explains what the analysis is doing.
+func (rework *ReworkAnalysis) Description() string {
+	return "Measures how much new code is rewritten or deleted soon after it was written, " +
+		"per developer, file and tick."
+}
+
+// Initialize resets the temporary caches and prepares this PipelineItem for a series of Consume()
+// calls. The repository which is going to be analysed is supplied as an argument.
+func (rework *ReworkAnalysis) Initialize(repository *git.Repository) error {
+	rework.l = core.GetLogger()
+	if rework.Ticks < 0 {
+		return fmt.Errorf("Ticks is negative: %d", rework.Ticks)
+	}
+	if rework.tickSize == 0 {
+		rework.tickSize = items.DefaultTicksSinceStartTickSize * time.Hour
+	}
+	rework.state = &reworkState{
+		Ticks:  map[int]ReworkStats{},
+		People: map[int]ReworkStats{},
+		Files:  map[string]ReworkStats{},
+	}
+	return nil
+}
+
+// Consume runs this PipelineItem on the next commit data.
+// `deps` contain all the results from upstream PipelineItem-s as requested by Requires().
+// Additionally, DependencyCommit is always present there and represents the analysed *object.Commit.
+// This function returns the mapping with analysis results. The keys must be the same as
+// in Provides(). If there was an error, nil is returned.
+func (rework *ReworkAnalysis) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
+	lines := deps[DependencyBurndownLines].(BurndownLines)
+	for _, updates := range [...][]LineUpdate{lines.Merged, lines.Updates} {
+		for _, update := range updates {
+			rework.countLines(update)
+		}
+	}
+	return nil, nil
+}
+
+// countLines attributes the new lines to their author and tick, and the removed lines to
+// the author and the tick of the removed lines.
+func (rework *ReworkAnalysis) countLines(update LineUpdate) {
+	var stats ReworkStats
+	author, tick := update.CurrentAuthor, update.CurrentTick
+	if update.Delta > 0 {
+		stats.Added = update.Delta
+	} else {
+		if update.CurrentTick-update.PreviousTick > rework.Ticks {
+			return
+		}
+		stats.Reworked = -update.Delta
+		if update.CurrentAuthor == update.PreviousAuthor &&
+			update.CurrentAuthor != identity.AuthorMissing {
+			stats.SelfReworked = -update.Delta
+		}
+		author, tick = update.PreviousAuthor, update.PreviousTick
+	}
+	state := rework.state
+	state.Ticks[tick] = state.Ticks[tick].add(stats)
+	state.Files[update.File] = state.Files[update.File].add(stats)
+	if author != identity.AuthorMissing {
+		state.People[author] = state.People[author].add(stats)
+	}
+}
+
+// Finalize returns the result of the analysis. Further Consume() calls are not expected.
+func (rework *ReworkAnalysis) Finalize() interface{} {
+	result := ReworkResult{
+		Ticks:              make(map[int]ReworkStats, len(rework.state.Ticks)),
+		People:             make(map[int]ReworkStats, len(rework.state.People)),
+		Files:              make(map[string]ReworkStats, len(rework.state.Files)),
+		reversedPeopleDict: rework.reversedPeopleDict,
+		tickSize:           rework.tickSize,
+		reworkTicks:        rework.Ticks,
+	}
+	for tick, stats := range rework.state.Ticks {
+		result.Ticks[tick] = stats
+	}
+	for dev, stats := range rework.state.People {
+		result.People[dev] = stats
+	}
+	for file, stats := range rework.state.Files {
+		result.Files[file] = stats
+	}
+	return result
+}
+
+// Fork clones this item. The stats are shared.
+func (rework *ReworkAnalysis) Fork(n int) []core.PipelineItem {
+	return core.ForkSamePipelineItem(rework, n)
+}
+
+// Checkpoint writes the stats. It implements core.CheckpointablePipelineItem.
+func (rework *ReworkAnalysis) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, *rework.state)
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (rework *ReworkAnalysis) Restore(reader io.Reader) error {
+	state := reworkState{}
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
+	}
+	*rework.state = state
+	// gob does not transmit empty maps
+	if rework.state.Ticks == nil {
+		rework.state.Ticks = map[int]ReworkStats{}
+	}
+	if rework.state.People == nil {
+		rework.state.People = map[int]ReworkStats{}
+	}
+	if rework.state.Files == nil {
+		rework.state.Files = map[string]ReworkStats{}
+	}
+	return nil
+}
+
+// Serialize converts the analysis result as returned by Finalize() to text or bytes.
+// The text format is YAML and the bytes format is Protocol Buffers.
+func (rework *ReworkAnalysis) Serialize(result interface{}, binary bool, writer io.Writer) error {
+	reworkResult, ok := result.(ReworkResult)
+	if !ok {
+		return fmt.Errorf("result is not a rework result: '%v'", result)
+	}
+	if binary {
+		return rework.serializeBinary(&reworkResult, writer)
+	}
+	rework.serializeText(&reworkResult, writer)
+	return nil
+}
+
+func formatReworkStats(stats ReworkStats) string {
+	return fmt.Sprintf("{added: %d, reworked: %d, self_reworked: %d, ratio: %.4f}",
+		stats.Added, stats.Reworked, stats.SelfReworked, stats.Ratio())
+}
+
+func (rework *ReworkAnalysis) serializeText(result *ReworkResult, writer io.Writer) {
+	writeInts := func(stats map[int]ReworkStats) {
+		keys := make([]int, 0, len(stats))
+		for key := range stats {
+			keys = append(keys, key)
+		}
+		sort.Ints(keys)
+		for _, key := range keys {
+			fmt.Fprintf(writer, "    %d: %s\n", key, formatReworkStats(stats[key]))
+		}
+	}
+	fmt.Fprintln(writer, "  rework_ticks:", result.reworkTicks)
+	fmt.Fprintln(writer, "  tick_size:", int(result.tickSize.Seconds()))
+	fmt.Fprintln(writer, "  ticks:")
+	writeInts(result.Ticks)
+	fmt.Fprintln(writer, "  files:")
+	files := make([]string, 0, len(result.Files))
+	for file := range result.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		fmt.Fprintf(writer, "    %s: %s\n", file, formatReworkStats(result.Files[file]))
+	}
+	fmt.Fprintln(writer, "  developers:")
+	writeInts(result.People)
+	fmt.Fprintln(writer, "  people:")
+	for _, person := range result.reversedPeopleDict {
+		fmt.Fprintf(writer, "  - %s\n", person)
+	}
+}
+
+func newReworkStatsMessage(stats ReworkStats) *pb.ReworkStats {
+	return &pb.ReworkStats{
+		Added:        int32(stats.Added),
+		Reworked:     int32(stats.Reworked),
+		SelfReworked: int32(stats.SelfReworked),
+	}
+}
+
+func newReworkStats(message *pb.ReworkStats) ReworkStats {
+	return ReworkStats{
+		Added:        int(message.Added),
+		Reworked:     int(message.Reworked),
+		SelfReworked: int(message.SelfReworked),
+	}
+}
+
+func (rework *ReworkAnalysis) serializeBinary(result *ReworkResult, writer io.Writer) error {
+	message := pb.ReworkAnalysisResults{
+		Ticks:       make(map[int32]*pb.ReworkStats, len(result.Ticks)),
+		Developers:  make(map[int32]*pb.ReworkStats, len(result.People)),
+		Files:       make(map[string]*pb.ReworkStats, len(result.Files)),
+		AuthorIndex: result.reversedPeopleDict,
+		TickSize:    int64(result.tickSize),
+		ReworkTicks: int32(result.reworkTicks),
+	}
+	for tick, stats := range result.Ticks {
+		message.Ticks[int32(tick)] = newReworkStatsMessage(stats)
+	}
+	for dev, stats := range result.People {
+		message.Developers[int32(dev)] = newReworkStatsMessage(stats)
+	}
+	for file, stats := range result.Files {
+		message.Files[file] = newReworkStatsMessage(stats)
+	}
+	serialized, err := proto.Marshal(&message)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(serialized)
+	return err
+}
+
+// Deserialize converts the specified protobuf bytes to ReworkResult.
+func (rework *ReworkAnalysis) Deserialize(pbmessage []byte) (interface{}, error) {
+	message := pb.ReworkAnalysisResults{}
+	if err := proto.Unmarshal(pbmessage, &message); err != nil {
+		return nil, err
+	}
+	result := ReworkResult{
+		Ticks:              make(map[int]ReworkStats, len(message.Ticks)),
+		People:             make(map[int]ReworkStats, len(message.Developers)),
+		Files:              make(map[string]ReworkStats, len(message.Files)),
+		reversedPeopleDict: message.AuthorIndex,
+		tickSize:           time.Duration(message.TickSize),
+		reworkTicks:        int(message.ReworkTicks),
+	}
+	for tick, stats := range message.Ticks {
+		result.Ticks[int(tick)] = newReworkStats(stats)
+	}
+	for dev, stats := range message.Developers {
+		result.People[int(dev)] = newReworkStats(stats)
+	}
+	for file, stats := range message.Files {
+		result.Files[file] = newReworkStats(stats)
+	}
+	return result, nil
+}
+
+// MergeResults combines two ReworkResult-s together. The ticks are aligned in time and the
+// stats of the same ticks, developers and paths are summed.
+func (rework *ReworkAnalysis) MergeResults(
+	r1, r2 interface{}, c1, c2 *core.CommonAnalysisResult) interface{} {
+	rr1 := r1.(ReworkResult)
+	rr2 := r2.(ReworkResult)
+	if rr1.tickSize != rr2.tickSize {
+		return fmt.Errorf("mismatching tick sizes (r1: %d, r2: %d) received",
+			rr1.tickSize, rr2.tickSize)
+	}
+	if rr1.tickSize == 0 {
+		return errors.New("tick size is not set")
+	}
+	t01 := items.FloorTime(c1.BeginTimeAsTime(), rr1.tickSize)
+	t02 := items.FloorTime(c2.BeginTimeAsTime(), rr2.tickSize)
+	t0 := t01
+	if t02.Before(t0) {
+		t0 = t02
+	}
+	offset1 := int(t01.Sub(t0) / rr1.tickSize)
+	offset2 := int(t02.Sub(t0) / rr2.tickSize)
+
+	merged := ReworkResult{
+		Ticks:       map[int]ReworkStats{},
+		People:      map[int]ReworkStats{},
+		Files:       map[string]ReworkStats{},
+		tickSize:    rr1.tickSize,
+		reworkTicks: rr1.reworkTicks,
+	}
+	var mergedIndex map[string]identity.MergedIndex
+	mergedIndex, merged.reversedPeopleDict = identity.MergeReversedDictsIdentities(
+		rr1.reversedPeopleDict, rr2.reversedPeopleDict)
+	for _, pair := range []struct {
+		result ReworkResult
+		offset int
+	}{{rr1, offset1}, {rr2, offset2}} {
+		for tick, stats := range pair.result.Ticks {
+			merged.Ticks[tick+pair.offset] = merged.Ticks[tick+pair.offset].add(stats)
+		}
+		for dev, stats := range pair.result.People {
+			if dev < 0 || dev >= len(pair.result.reversedPeopleDict) {
+				continue
+			}
+			dev = mergedIndex[pair.result.reversedPeopleDict[dev]].Final
+			merged.People[dev] = merged.People[dev].add(stats)
+		}
+		for file, stats := range pair.result.Files {
+			merged.Files[file] = merged.Files[file].add(stats)
+		}
+	}
+	return merged
+}
+
+// GetTickSize returns the tick size used to generate this rework analysis result.
+func (rr ReworkResult) GetTickSize() time.Duration {
+	return rr.tickSize
+}
+
+// GetIdentities returns the list of developer identities used to generate this rework
+// analysis result. The format is |-joined keys, see internals/plumbing/identity for details.
+func (rr ReworkResult) GetIdentities() []string {
+	return rr.reversedPeopleDict
+}
+
+var _ core.PipelineItem = (*ReworkAnalysis)(nil)
+
+func init() {
+	core.Registry.Register(&ReworkAnalysis{})
+}
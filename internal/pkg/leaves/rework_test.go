@@ -0,0 +1,178 @@
+package leaves
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReworkMeta(t *testing.T) {
+	rework := &ReworkAnalysis{}
+	assert.Equal(t, "Rework", rework.Name())
+	assert.Equal(t, "rework", rework.Flag())
+	assert.Len(t, rework.Provides(), 0)
+	assert.Equal(t, []string{DependencyBurndownLines}, rework.Requires())
+	opts := rework.ListConfigurationOptions()
+	assert.Len(t, opts, 1)
+	assert.Equal(t, ConfigReworkTicks, opts[0].Name)
+	subscription := &burndownSubscription{}
+	assert.NoError(t, rework.Configure(map[string]interface{}{
+		ConfigReworkTicks:        7,
+		factBurndownSubscription: subscription,
+	}))
+	assert.Equal(t, 7, rework.Ticks)
+	assert.Equal(t, burndownSubscription{lines: true, authors: true}, *subscription)
+	rework.Ticks = -1
+	assert.Error(t, rework.Initialize(test.Repository))
+}
+
+func TestReworkRegistration(t *testing.T) {
+	testLeafRegistration(t, &ReworkAnalysis{})
+}
+
+func TestReworkCountLines(t *testing.T) {
+	rework := &ReworkAnalysis{Ticks: 2, state: &reworkState{
+		Ticks:  map[int]ReworkStats{},
+		People: map[int]ReworkStats{},
+		Files:  map[string]ReworkStats{},
+	}}
+	rework.countLines(LineUpdate{File: "a", CurrentAuthor: 0, CurrentTick: 1,
+		PreviousAuthor: 0, PreviousTick: 1, Delta: 3})
+	// the removed lines are attributed to the tick and the author who wrote them
+	rework.countLines(LineUpdate{File: "a", CurrentAuthor: 1, CurrentTick: 3,
+		PreviousAuthor: 0, PreviousTick: 1, Delta: -1})
+	// the window is inclusive
+	rework.countLines(LineUpdate{File: "a", CurrentAuthor: 0, CurrentTick: 3,
+		PreviousAuthor: 0, PreviousTick: 1, Delta: -1})
+	rework.countLines(LineUpdate{File: "a", CurrentAuthor: 1, CurrentTick: 4,
+		PreviousAuthor: 0, PreviousTick: 1, Delta: -1})
+	// nobody owns the rework of the unknown developers
+	rework.countLines(LineUpdate{File: "b", CurrentAuthor: identity.AuthorMissing, CurrentTick: 4,
+		PreviousAuthor: identity.AuthorMissing, PreviousTick: 4, Delta: -2})
+	assert.Equal(t, map[int]ReworkStats{
+		1: {Added: 3, Reworked: 2, SelfReworked: 1},
+		4: {Reworked: 2},
+	}, rework.state.Ticks)
+	assert.Equal(t, map[int]ReworkStats{0: {Added: 3, Reworked: 2, SelfReworked: 1}},
+		rework.state.People)
+	assert.Equal(t, map[string]ReworkStats{
+		"a": {Added: 3, Reworked: 2, SelfReworked: 1},
+		"b": {Reworked: 2},
+	}, rework.state.Files)
+}
+
+func reworkRepository() *leafRepository {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return newLeafRepository(
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start,
+			Files: map[string]string{"a/x.go": "1\n2\n3\n4\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 1),
+			Files: map[string]string{"a/x.go": "1\n2\n5\n6\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 2),
+			Files: map[string]string{"a/x.go": "1\n2\n5\n7\n", "b.txt": "1\n"}},
+		test.Commit{Author: "Carol", Email: "carol@example.com", When: start.AddDate(0, 0, 40),
+			Files: map[string]string{"a/x.go": "1\n5\n7\n"}})
+}
+
+var reworkFacts = map[string]interface{}{ConfigReworkTicks: 21}
+
+func TestReworkPipeline(t *testing.T) {
+	repo := reworkRepository()
+	result := repo.Run(t, &ReworkAnalysis{}, repo.Commits, reworkFacts).(ReworkResult)
+	assert.Equal(t, []string{"alice|alice@example.com", "bob|bob@example.com", "carol|carol@example.com"},
+		result.reversedPeopleDict)
+	assert.Equal(t, 21, result.reworkTicks)
+	// Bob rewrites two lines of Alice and then one of his own; Carol removes a line which
+	// is too old to count
+	assert.Equal(t, map[int]ReworkStats{
+		0: {Added: 4, Reworked: 2},
+		1: {Added: 2, Reworked: 1, SelfReworked: 1},
+		2: {Added: 2},
+	}, result.Ticks)
+	assert.Equal(t, map[int]ReworkStats{
+		0: {Added: 4, Reworked: 2},
+		1: {Added: 4, Reworked: 1, SelfReworked: 1},
+	}, result.People)
+	assert.Equal(t, map[string]ReworkStats{
+		"a/x.go": {Added: 7, Reworked: 3, SelfReworked: 1},
+		"b.txt":  {Added: 1},
+	}, result.Files)
+	assert.InDelta(t, 0.5, result.People[0].Ratio(), 1e-9)
+	assert.Equal(t, 0.0, ReworkStats{}.Ratio())
+
+	result = repo.Run(t, &ReworkAnalysis{}, repo.Commits,
+		map[string]interface{}{ConfigReworkTicks: 0}).(ReworkResult)
+	assert.Equal(t, map[string]ReworkStats{"a/x.go": {Added: 7}, "b.txt": {Added: 1}}, result.Files)
+}
+
+func TestReworkCheckpoint(t *testing.T) {
+	repo := reworkRepository()
+	full := repo.Run(t, &ReworkAnalysis{}, repo.Commits, reworkFacts).(ReworkResult)
+	resumed := repo.Resume(t, &ReworkAnalysis{}, 2, reworkFacts).(ReworkResult)
+	assert.Equal(t, full.Ticks, resumed.Ticks)
+	assert.Equal(t, full.People, resumed.People)
+	assert.Equal(t, full.Files, resumed.Files)
+}
+
+func TestReworkSerialize(t *testing.T) {
+	repo := reworkRepository()
+	result := repo.Run(t, &ReworkAnalysis{}, repo.Commits[:2], reworkFacts).(ReworkResult)
+	rework := &ReworkAnalysis{}
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, rework.Serialize(result, false, buffer))
+	assert.Equal(t, `  rework_ticks: 21
+  tick_size: 86400
+  ticks:
+    0: {added: 4, reworked: 2, self_reworked: 0, ratio: 0.5000}
+    1: {added: 2, reworked: 0, self_reworked: 0, ratio: 0.0000}
+  files:
+    a/x.go: {added: 6, reworked: 2, self_reworked: 0, ratio: 0.3333}
+  developers:
+    0: {added: 4, reworked: 2, self_reworked: 0, ratio: 0.5000}
+    1: {added: 2, reworked: 0, self_reworked: 0, ratio: 0.0000}
+  people:
+  - alice|alice@example.com
+  - bob|bob@example.com
+`, buffer.String())
+
+	testLeafBinaryRoundTrip(t, rework, result)
+}
+
+func TestReworkMergeResults(t *testing.T) {
+	r1 := ReworkResult{
+		Ticks:              map[int]ReworkStats{0: {Added: 4, Reworked: 2}, 1: {Added: 1}},
+		People:             map[int]ReworkStats{0: {Added: 4, Reworked: 2}, 1: {Added: 1}},
+		Files:              map[string]ReworkStats{"a": {Added: 5, Reworked: 2}},
+		reversedPeopleDict: []string{"alice", "bob"},
+		tickSize:           24 * time.Hour,
+		reworkTicks:        21,
+	}
+	r2 := ReworkResult{
+		Ticks:              map[int]ReworkStats{0: {Added: 3, Reworked: 1, SelfReworked: 1}},
+		People:             map[int]ReworkStats{0: {Added: 3, Reworked: 1, SelfReworked: 1}},
+		Files:              map[string]ReworkStats{"a": {Added: 3, Reworked: 1, SelfReworked: 1}},
+		reversedPeopleDict: []string{"bob"},
+		tickSize:           24 * time.Hour,
+		reworkTicks:        21,
+	}
+	c1 := core.CommonAnalysisResult{BeginTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+	c2 := core.CommonAnalysisResult{BeginTime: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix()}
+	merged := (&ReworkAnalysis{}).MergeResults(r1, r2, &c1, &c2).(ReworkResult)
+	assert.Equal(t, []string{"alice", "bob"}, merged.reversedPeopleDict)
+	// r2 starts one tick later and its bob is r1's bob
+	assert.Equal(t, map[int]ReworkStats{
+		0: {Added: 4, Reworked: 2},
+		1: {Added: 4, Reworked: 1, SelfReworked: 1},
+	}, merged.Ticks)
+	assert.Equal(t, merged.Ticks, merged.People)
+	assert.Equal(t, map[string]ReworkStats{"a": {Added: 8, Reworked: 3, SelfReworked: 1}},
+		merged.Files)
+
+	r2.tickSize = time.Hour
+	assert.Error(t, (&ReworkAnalysis{}).MergeResults(r1, r2, &c1, &c2).(error))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/dmytrogajewski/hercules/api/proto/pb"
 	"github.com/dmytrogajewski/hercules/internal/app/core"
 	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
 	uast_items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing/uast"
 	"github.com/dmytrogajewski/hercules/pkg/uast/pkg/node"
 	"github.com/go-git/go-git/v6"
@@ -21,12 +22,17 @@ import (
 // LeafPipelineItem.
 type ShotnessAnalysis struct {
 	core.NoopMerger
-	core.OneShotMergeProcessor
 	DSLStruct string
 	DSLName   string
+	// RenameSimilarity is the minimum Node.Similarity() between a node which disappeared and
+	// a node which appeared in the same file to consider the latter a rename of the former.
+	// 0 disables following the renames.
+	RenameSimilarity float32
 
 	nodes map[string]*nodeShotness
 	files map[string]map[string]*nodeShotness
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
 
 	l core.Logger
 }
@@ -40,6 +46,9 @@ const (
 	// which sets the UAST DSL query to find the name of the nodes chosen by ConfigShotnessDSLStruct.
 	// The format is UAST DSL, see pkg/uast/docs/DSL_SYNTAX.md
 	ConfigShotnessDSLName = "Shotness.DSLName"
+	// ConfigShotnessRenameSimilarity is the name of the configuration option
+	// (ShotnessAnalysis.Configure()) which sets the similarity threshold to detect node renames.
+	ConfigShotnessRenameSimilarity = "Shotness.RenameSimilarity"
 
 	// DefaultShotnessDSLStruct is the default UAST DSL query to choose the analysed nodes.
 	// It extracts functions.
@@ -47,12 +56,16 @@ const (
 	// DefaultShotnessDSLName is the default UAST DSL query to choose the names of the analysed nodes.
 	// It looks at the token field.
 	DefaultShotnessDSLName = ".token"
+	// DefaultShotnessRenameSimilarity is the default similarity threshold to detect node renames.
+	DefaultShotnessRenameSimilarity = float32(0.8)
 )
 
 type nodeShotness struct {
 	Count   int
 	Summary NodeSummary
 	Couples map[string]int
+	Churn   NodeChurn
+	Authors map[int]NodeChurn
 }
 
 // NodeSummary carries the node attributes which annotate the "shotness" analysis' counters.
@@ -63,10 +76,24 @@ type NodeSummary struct {
 	File string
 }
 
+// NodeChurn is how many commits changed a node and how many lines they added and removed.
+type NodeChurn struct {
+	Commits int
+	Added   int
+	Removed int
+}
+
 // ShotnessResult is returned by ShotnessAnalysis.Finalize() and represents the analysis result.
 type ShotnessResult struct {
 	Nodes    []NodeSummary
 	Counters []map[int]int
+	// Churn is the total churn of each node. The order matches Nodes.
+	Churn []NodeChurn
+	// Authors is the churn of each node by developer. The order matches Nodes.
+	Authors []map[int]NodeChurn
+
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
 }
 
 func (node NodeSummary) String() string {
@@ -89,7 +116,7 @@ func (shotness *ShotnessAnalysis) Provides() []string {
 // Each requested entity will be inserted into `deps` of Consume(). In turn, those
 // entities are Provides() upstream.
 func (shotness *ShotnessAnalysis) Requires() []string {
-	return []string{items.DependencyFileDiff, uast_items.DependencyUastChanges}
+	return []string{items.DependencyFileDiff, uast_items.DependencyUastChanges, identity.DependencyAuthor}
 }
 
 // ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
@@ -106,7 +133,13 @@ func (shotness *ShotnessAnalysis) ListConfigurationOptions() []core.Configuratio
 			"Refer to pkg/uast/docs/DSL_SYNTAX.md",
 		Flag:    "shotness-dsl-name",
 		Type:    core.StringConfigurationOption,
-		Default: DefaultShotnessDSLName},
+		Default: DefaultShotnessDSLName}, {
+		Name: ConfigShotnessRenameSimilarity,
+		Description: "Minimum similarity between 0 and 1 of a removed and an added node in " +
+			"the same file to follow the node through the rename.",
+		Flag:    "shotness-rename-similarity",
+		Type:    core.FloatConfigurationOption,
+		Default: DefaultShotnessRenameSimilarity},
 	}
 	return opts[:]
 }
@@ -126,7 +159,8 @@ func (shotness *ShotnessAnalysis) Description() string {
 	return "Structural hotness - a fine-grained alternative to --couples. " +
 		"Given a DSL query over UASTs - selecting functions by default - we build the square " +
 		"co-occurrence matrix. The value in each cell equals to the number of times the pair " +
-		"of selected UAST units appeared in the same commit."
+		"of selected UAST units appeared in the same commit. Each unit also carries the number " +
+		"of added and removed lines by developer."
 }
 
 // Configure sets the properties previously published by ListConfigurationOptions().
@@ -144,6 +178,14 @@ func (shotness *ShotnessAnalysis) Configure(facts map[string]interface{}) error
 	} else {
 		shotness.DSLName = DefaultShotnessDSLName
 	}
+	if val, exists := facts[ConfigShotnessRenameSimilarity]; exists {
+		shotness.RenameSimilarity = val.(float32)
+	} else {
+		shotness.RenameSimilarity = DefaultShotnessRenameSimilarity
+	}
+	if val, exists := facts[identity.FactIdentityDetectorReversedPeopleDict].([]string); exists {
+		shotness.reversedPeopleDict = val
+	}
 	return nil
 }
 
@@ -151,9 +193,12 @@ func (shotness *ShotnessAnalysis) Configure(facts map[string]interface{}) error
 // calls. The repository which is going to be analysed is supplied as an argument.
 func (shotness *ShotnessAnalysis) Initialize(repository *git.Repository) error {
 	shotness.l = core.GetLogger()
+	if shotness.RenameSimilarity < 0 || shotness.RenameSimilarity > 1 {
+		return fmt.Errorf("the rename similarity must be between 0 and 1: %v",
+			shotness.RenameSimilarity)
+	}
 	shotness.nodes = map[string]*nodeShotness{}
 	shotness.files = map[string]map[string]*nodeShotness{}
-	shotness.OneShotMergeProcessor.Initialize()
 	return nil
 }
 
@@ -163,15 +208,16 @@ func (shotness *ShotnessAnalysis) Initialize(repository *git.Repository) error {
 // This function returns the mapping with analysis results. The keys must be the same as
 // in Provides(). If there was an error, nil is returned.
 func (shotness *ShotnessAnalysis) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
-	if !shotness.ShouldConsumeCommit(deps) {
-		return nil, nil
-	}
 	commit := deps[core.DependencyCommit].(*object.Commit)
 	changesList := deps[uast_items.DependencyUastChanges].([]uast_items.Change)
 	diffs := deps[items.DependencyFileDiff].(map[string]items.FileDiffData)
+	author := deps[identity.DependencyAuthor].(int)
+	// the changes brought by a merge were already counted in the merged branches,
+	// so merges only keep the tracked nodes in sync with deletions and renames
+	mergeMode := deps[core.DependencyIsMerge].(bool)
 	allNodes := map[string]bool{}
 
-	addNode := func(name string, node *node.Node, fileName string) {
+	addNode := func(name string, node *node.Node, fileName string) *nodeShotness {
 		nodeSummary := NodeSummary{
 			Type: string(node.Type),
 			Name: name,
@@ -180,29 +226,43 @@ func (shotness *ShotnessAnalysis) Consume(deps map[string]interface{}) (map[stri
 		key := nodeSummary.String()
 		exists := allNodes[key]
 		allNodes[key] = true
-		var count int
-		if ns := shotness.nodes[key]; ns != nil {
-			count = ns.Count
-		}
-		if count == 0 {
-			shotness.nodes[key] = &nodeShotness{
-				Summary: nodeSummary, Count: 1, Couples: map[string]int{}}
+		ns := shotness.nodes[key]
+		if ns == nil || ns.Count == 0 {
+			ns = &nodeShotness{
+				Summary: nodeSummary, Couples: map[string]int{}, Authors: map[int]NodeChurn{}}
+			shotness.nodes[key] = ns
 			fmap := shotness.files[nodeSummary.File]
 			if fmap == nil {
 				fmap = map[string]*nodeShotness{}
 			}
-			fmap[key] = shotness.nodes[key]
+			fmap[key] = ns
 			shotness.files[nodeSummary.File] = fmap
-		} else if !exists { // in case there are removals and additions in the same node
-			shotness.nodes[key].Count = count + 1
 		}
+		if !exists { // in case there are removals and additions in the same node
+			ns.Count++
+			ns.Churn.Commits++
+			churn := ns.Authors[author]
+			churn.Commits++
+			ns.Authors[author] = churn
+		}
+		return ns
+	}
+	addLines := func(ns *nodeShotness, added, removed int) {
+		ns.Churn.Added += added
+		ns.Churn.Removed += removed
+		churn := ns.Authors[author]
+		churn.Added += added
+		churn.Removed += removed
+		ns.Authors[author] = churn
 	}
 
 	for _, change := range changesList {
 		if change.After == nil {
 			for key, summary := range shotness.files[change.Change.From.Name] {
 				for subkey := range summary.Couples {
-					delete(shotness.nodes[subkey].Couples, key)
+					if couple := shotness.nodes[subkey]; couple != nil {
+						delete(couple.Couples, key)
+					}
 				}
 			}
 			for key := range shotness.files[change.Change.From.Name] {
@@ -213,6 +273,9 @@ func (shotness *ShotnessAnalysis) Consume(deps map[string]interface{}) (map[stri
 		}
 		toName := change.Change.To.Name
 		if change.Before == nil {
+			if mergeMode {
+				continue
+			}
 			nodes, err := shotness.extractNodes(change.After)
 			if err != nil {
 				shotness.l.Warnf("Shotness: commit %s file %s failed to filter UAST: %s\n",
@@ -220,14 +283,17 @@ func (shotness *ShotnessAnalysis) Consume(deps map[string]interface{}) (map[stri
 				continue
 			}
 			for name, node := range nodes {
-				addNode(name, node, toName)
+				ns := addNode(name, node, toName)
+				if startLine, endLine, ok := nodeLineRange(node); ok {
+					addLines(ns, endLine-startLine+1, 0)
+				}
 			}
 			continue
 		}
 		// Before -> After
-		if change.Change.From.Name != toName {
+		if oldFile, exists := shotness.files[change.Change.From.Name]; exists &&
+			change.Change.From.Name != toName {
 			// renamed
-			oldFile := shotness.files[change.Change.From.Name]
 			newFile := map[string]*nodeShotness{}
 			shotness.files[toName] = newFile
 			for oldKey, ns := range oldFile {
@@ -263,31 +329,24 @@ func (shotness *ShotnessAnalysis) Consume(deps map[string]interface{}) (map[stri
 			continue
 		}
 		reversedNodesAfter := reverseNodeMap(nodesAfter)
+		for oldName, newName := range shotness.detectNodeRenames(nodesBefore, nodesAfter) {
+			oldNode := nodesBefore[oldName]
+			oldKey := NodeSummary{Type: string(oldNode.Type), Name: oldName, File: toName}.String()
+			if shotness.renameNode(oldKey, newName) {
+				// the removed lines belong to the renamed node
+				reversedNodesBefore[oldNode.Id] = newName
+			}
+		}
+		if mergeMode {
+			continue
+		}
 		genLine2Node := func(nodes map[string]*node.Node, linesNum int) [][]*node.Node {
 			res := make([][]*node.Node, linesNum)
 			for _, uastNode := range nodes {
-				pos := uastNode.Pos
-				if pos == nil {
+				startLine, endLine, ok := nodeLineRange(uastNode)
+				if !ok {
 					continue
 				}
-				startLine := int(pos.StartLine)
-				endLine := int(pos.StartLine)
-				if pos.EndLine > pos.StartLine {
-					endLine = int(pos.EndLine)
-				} else {
-					// we need to determine pos.EndLine
-					uastNode.VisitPreOrder(func(child *node.Node) {
-						if child.Pos != nil {
-							candidate := int(child.Pos.StartLine)
-							if child.Pos.EndLine > child.Pos.StartLine {
-								candidate = int(child.Pos.EndLine)
-							}
-							if candidate > endLine {
-								endLine = candidate
-							}
-						}
-					})
-				}
 				for l := startLine; l <= endLine; l++ {
 					if l > 0 && l <= len(res) {
 						lineNodes := res[l-1]
@@ -311,19 +370,19 @@ func (shotness *ShotnessAnalysis) Consume(deps map[string]interface{}) (map[stri
 			size := utf8.RuneCountInString(edit.Text)
 			switch edit.Type {
 			case diffmatchpatch.DiffDelete:
-				for l := lineNumBefore; l < lineNumBefore+size; l++ {
+				for l := lineNumBefore; l < lineNumBefore+size && l < len(line2nodeBefore); l++ {
 					nodes := line2nodeBefore[l]
 					for _, node := range nodes {
 						// toName because we handled a possible rename before
-						addNode(reversedNodesBefore[node.Id], node, toName)
+						addLines(addNode(reversedNodesBefore[node.Id], node, toName), 0, 1)
 					}
 				}
 				lineNumBefore += size
 			case diffmatchpatch.DiffInsert:
-				for l := lineNumAfter; l < lineNumAfter+size; l++ {
+				for l := lineNumAfter; l < lineNumAfter+size && l < len(line2nodeAfter); l++ {
 					nodes := line2nodeAfter[l]
 					for _, node := range nodes {
-						addNode(reversedNodesAfter[node.Id], node, toName)
+						addLines(addNode(reversedNodesAfter[node.Id], node, toName), 1, 0)
 					}
 				}
 				lineNumAfter += size
@@ -344,16 +403,148 @@ func (shotness *ShotnessAnalysis) Consume(deps map[string]interface{}) (map[stri
 	return nil, nil
 }
 
+// detectNodeRenames matches the nodes which disappeared from a file with the nodes of the same
+// type which appeared in it by their similarity. It returns the mapping from the old names
+// to the new names.
+func (shotness *ShotnessAnalysis) detectNodeRenames(
+	before, after map[string]*node.Node) map[string]string {
+	renames := map[string]string{}
+	if shotness.RenameSimilarity <= 0 {
+		return renames
+	}
+	var removed, added []string
+	for name := range before {
+		if after[name] == nil {
+			removed = append(removed, name)
+		}
+	}
+	for name := range after {
+		if before[name] == nil {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	matched := map[string]bool{}
+	for _, oldName := range removed {
+		oldNode := before[oldName]
+		var bestName string
+		var bestSimilarity float64
+		for _, newName := range added {
+			newNode := after[newName]
+			if matched[newName] || newNode.Type != oldNode.Type {
+				continue
+			}
+			similarity := oldNode.Similarity(newNode)
+			if similarity >= float64(shotness.RenameSimilarity) && similarity > bestSimilarity {
+				bestName, bestSimilarity = newName, similarity
+			}
+		}
+		if bestName != "" {
+			matched[bestName] = true
+			renames[oldName] = bestName
+		}
+	}
+	return renames
+}
+
+// renameNode moves the node tracked under `oldKey` to the new name in the same file.
+// It returns false if the node is not tracked or the new name is already taken.
+func (shotness *ShotnessAnalysis) renameNode(oldKey string, newName string) bool {
+	ns := shotness.nodes[oldKey]
+	if ns == nil {
+		return false
+	}
+	summary := ns.Summary
+	summary.Name = newName
+	newKey := summary.String()
+	if shotness.nodes[newKey] != nil {
+		return false
+	}
+	ns.Summary = summary
+	delete(shotness.nodes, oldKey)
+	shotness.nodes[newKey] = ns
+	if fmap := shotness.files[summary.File]; fmap != nil {
+		delete(fmap, oldKey)
+		fmap[newKey] = ns
+	}
+	for coupleKey, count := range ns.Couples {
+		if couple := shotness.nodes[coupleKey]; couple != nil {
+			delete(couple.Couples, oldKey)
+			couple.Couples[newKey] = count
+		}
+	}
+	return true
+}
+
+// nodeLineRange returns the first and the last line of the node, 1-based.
+func nodeLineRange(uastNode *node.Node) (int, int, bool) {
+	pos := uastNode.Pos
+	if pos == nil {
+		return 0, 0, false
+	}
+	startLine := int(pos.StartLine)
+	endLine := int(pos.StartLine)
+	if pos.EndLine > pos.StartLine {
+		endLine = int(pos.EndLine)
+	} else {
+		// we need to determine pos.EndLine
+		uastNode.VisitPreOrder(func(child *node.Node) {
+			if child.Pos != nil {
+				candidate := int(child.Pos.StartLine)
+				if child.Pos.EndLine > child.Pos.StartLine {
+					candidate = int(child.Pos.EndLine)
+				}
+				if candidate > endLine {
+					endLine = candidate
+				}
+			}
+		})
+	}
+	return startLine, endLine, true
+}
+
 // Fork clones this PipelineItem.
 func (shotness *ShotnessAnalysis) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(shotness, n)
 }
 
+// Checkpoint writes the node counters. It implements core.CheckpointablePipelineItem.
+func (shotness *ShotnessAnalysis) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, shotness.nodes)
+}
+
+// Restore loads the state written by Checkpoint(). It implements core.CheckpointablePipelineItem.
+func (shotness *ShotnessAnalysis) Restore(reader io.Reader) error {
+	if err := core.DecodeCheckpoint(reader, &shotness.nodes); err != nil {
+		return err
+	}
+	// files share the nodes with the main index
+	for key, ns := range shotness.nodes {
+		if ns.Couples == nil {
+			ns.Couples = map[string]int{}
+		}
+		if ns.Authors == nil {
+			ns.Authors = map[int]NodeChurn{}
+		}
+		fmap := shotness.files[ns.Summary.File]
+		if fmap == nil {
+			fmap = map[string]*nodeShotness{}
+			shotness.files[ns.Summary.File] = fmap
+		}
+		fmap[key] = ns
+	}
+	return nil
+}
+
 // Finalize returns the result of the analysis. Further Consume() calls are not expected.
 func (shotness *ShotnessAnalysis) Finalize() interface{} {
 	result := ShotnessResult{
-		Nodes:    make([]NodeSummary, len(shotness.nodes)),
-		Counters: make([]map[int]int, len(shotness.nodes)),
+		Nodes:              make([]NodeSummary, len(shotness.nodes)),
+		Counters:           make([]map[int]int, len(shotness.nodes)),
+		Churn:              make([]NodeChurn, len(shotness.nodes)),
+		Authors:            make([]map[int]NodeChurn, len(shotness.nodes)),
+		reversedPeopleDict: shotness.reversedPeopleDict,
 	}
 	keys := make([]string, len(shotness.nodes))
 	i := 0
@@ -369,6 +560,12 @@ func (shotness *ShotnessAnalysis) Finalize() interface{} {
 	for i, key := range keys {
 		node := shotness.nodes[key]
 		result.Nodes[i] = node.Summary
+		result.Churn[i] = node.Churn
+		authors := map[int]NodeChurn{}
+		for author, churn := range node.Authors {
+			authors[author] = churn
+		}
+		result.Authors[i] = authors
 		counter := map[int]int{}
 		result.Counters[i] = counter
 		counter[i] = node.Count
@@ -411,12 +608,43 @@ func (shotness *ShotnessAnalysis) serializeText(result *ShotnessResult, writer i
 			}
 			j++
 		}
+		if i >= len(result.Churn) {
+			continue
+		}
+		churn := result.Churn[i]
+		fmt.Fprintf(writer, "    churn: {commits: %d, added: %d, removed: %d}\n",
+			churn.Commits, churn.Added, churn.Removed)
+		fmt.Fprint(writer, "    authors: {")
+		authors := make([]int, 0, len(result.Authors[i]))
+		for author := range result.Authors[i] {
+			authors = append(authors, author)
+		}
+		sort.Ints(authors)
+		for j, author := range authors {
+			if j > 0 {
+				fmt.Fprint(writer, ", ")
+			}
+			churn := result.Authors[i][author]
+			fmt.Fprintf(writer, "%q: {commits: %d, added: %d, removed: %d}",
+				shotnessAuthorName(author, result.reversedPeopleDict),
+				churn.Commits, churn.Added, churn.Removed)
+		}
+		fmt.Fprintln(writer, "}")
 	}
 }
 
+// shotnessAuthorName returns the identity of the developer for the text output.
+func shotnessAuthorName(author int, reversedPeopleDict []string) string {
+	if author >= 0 && author < len(reversedPeopleDict) {
+		return reversedPeopleDict[author]
+	}
+	return identity.AuthorMissingName
+}
+
 func (shotness *ShotnessAnalysis) serializeBinary(result *ShotnessResult, writer io.Writer) error {
 	message := pb.ShotnessAnalysisResults{
-		Records: make([]*pb.ShotnessRecord, len(result.Nodes)),
+		Records:     make([]*pb.ShotnessRecord, len(result.Nodes)),
+		AuthorIndex: result.reversedPeopleDict,
 	}
 	for i, summary := range result.Nodes {
 		record := &pb.ShotnessRecord{
@@ -428,6 +656,13 @@ func (shotness *ShotnessAnalysis) serializeBinary(result *ShotnessResult, writer
 		for key, val := range result.Counters[i] {
 			record.Counters[int32(key)] = int32(val)
 		}
+		if i < len(result.Churn) {
+			record.Churn = newShotnessChurnMessage(result.Churn[i])
+			record.Authors = map[int32]*pb.ShotnessChurn{}
+			for author, churn := range result.Authors[i] {
+				record.Authors[int32(author)] = newShotnessChurnMessage(churn)
+			}
+		}
 		message.Records[i] = record
 	}
 	serialized, err := proto.Marshal(&message)
@@ -438,6 +673,14 @@ func (shotness *ShotnessAnalysis) serializeBinary(result *ShotnessResult, writer
 	return err
 }
 
+func newShotnessChurnMessage(churn NodeChurn) *pb.ShotnessChurn {
+	return &pb.ShotnessChurn{
+		Commits: int32(churn.Commits),
+		Added:   int32(churn.Added),
+		Removed: int32(churn.Removed),
+	}
+}
+
 func (shotness *ShotnessAnalysis) extractNodes(root *node.Node) (map[string]*node.Node, error) {
 	if root == nil {
 		return map[string]*node.Node{}, nil
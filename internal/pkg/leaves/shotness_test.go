@@ -7,6 +7,7 @@ import (
 	"github.com/dmytrogajewski/hercules/api/proto/pb"
 	"github.com/dmytrogajewski/hercules/internal/app/core"
 	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
 	uast_items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing/uast"
 	"github.com/dmytrogajewski/hercules/internal/pkg/test"
 	"github.com/dmytrogajewski/hercules/pkg/uast/pkg/node"
@@ -30,21 +31,30 @@ func TestShotnessMeta(t *testing.T) {
 	assert.NotNil(t, sh.files)
 	assert.Equal(t, sh.Name(), "Shotness")
 	assert.Len(t, sh.Provides(), 0)
-	assert.Equal(t, len(sh.Requires()), 2)
+	assert.Equal(t, len(sh.Requires()), 3)
 	assert.Equal(t, sh.Requires()[0], items.DependencyFileDiff)
 	assert.Equal(t, sh.Requires()[1], uast_items.DependencyUastChanges)
-	assert.Len(t, sh.ListConfigurationOptions(), 2)
+	assert.Equal(t, sh.Requires()[2], identity.DependencyAuthor)
+	assert.Len(t, sh.ListConfigurationOptions(), 3)
 	assert.Equal(t, sh.ListConfigurationOptions()[0].Name, ConfigShotnessDSLStruct)
 	assert.Equal(t, sh.ListConfigurationOptions()[1].Name, ConfigShotnessDSLName)
+	assert.Equal(t, sh.ListConfigurationOptions()[2].Name, ConfigShotnessRenameSimilarity)
 	assert.Nil(t, sh.Configure(nil))
 	assert.Equal(t, sh.DSLStruct, DefaultShotnessDSLStruct)
 	assert.Equal(t, sh.DSLName, DefaultShotnessDSLName)
+	assert.Equal(t, sh.RenameSimilarity, DefaultShotnessRenameSimilarity)
 	assert.NoError(t, sh.Configure(map[string]interface{}{
-		ConfigShotnessDSLStruct: "dsl!",
-		ConfigShotnessDSLName:   "another!",
+		ConfigShotnessDSLStruct:                         "dsl!",
+		ConfigShotnessDSLName:                           "another!",
+		ConfigShotnessRenameSimilarity:                  float32(0.5),
+		identity.FactIdentityDetectorReversedPeopleDict: []string{"one"},
 	}))
 	assert.Equal(t, sh.DSLStruct, "dsl!")
 	assert.Equal(t, sh.DSLName, "another!")
+	assert.Equal(t, sh.RenameSimilarity, float32(0.5))
+	assert.Equal(t, []string{"one"}, sh.reversedPeopleDict)
+	sh.RenameSimilarity = 2
+	assert.Error(t, sh.Initialize(test.Repository))
 
 	logger := core.GetLogger()
 	assert.NoError(t, sh.Configure(map[string]interface{}{
@@ -75,9 +85,10 @@ func TestShotnessBasicFunctionality(t *testing.T) {
 	// Test basic initialization
 	assert.Equal(t, "Shotness", sh.Name())
 	assert.Len(t, sh.Provides(), 0)
-	assert.Equal(t, len(sh.Requires()), 2)
+	assert.Equal(t, len(sh.Requires()), 3)
 	assert.Equal(t, sh.Requires()[0], items.DependencyFileDiff)
 	assert.Equal(t, sh.Requires()[1], uast_items.DependencyUastChanges)
+	assert.Equal(t, sh.Requires()[2], identity.DependencyAuthor)
 
 	// Test configuration
 	err := sh.Configure(map[string]interface{}{
@@ -125,6 +136,8 @@ func TestShotnessConsume(t *testing.T) {
 	// Create mock data instead of reading files
 	state := map[string]interface{}{}
 	state[core.DependencyCommit] = &object.Commit{}
+	state[core.DependencyIsMerge] = false
+	state[identity.DependencyAuthor] = 0
 
 	// Create mock file diffs
 	fileDiffs := map[string]items.FileDiffData{}
@@ -197,6 +210,8 @@ func TestShotnessConsumeNoEnd(t *testing.T) {
 	// Create mock data
 	state := map[string]interface{}{}
 	state[core.DependencyCommit] = &object.Commit{}
+	state[core.DependencyIsMerge] = false
+	state[identity.DependencyAuthor] = 0
 
 	fileDiffs := map[string]items.FileDiffData{}
 	const fileName = "test.java"
@@ -295,3 +310,157 @@ func TestShotnessSerializeBinary(t *testing.T) {
 	// Just test that serialization worked and produced some data
 	assert.Greater(t, buffer.Len(), 0, "Binary serialization should produce data")
 }
+
+// shotnessFunction creates a function node which spans the lines from start to end.
+func shotnessFunction(id, name string, start, end uint, body ...string) *node.Node {
+	function := &node.Node{
+		Id:    id,
+		Type:  "Function",
+		Token: name,
+		Pos:   &node.Positions{StartLine: start, EndLine: end},
+	}
+	for i, token := range body {
+		function.Children = append(function.Children, &node.Node{
+			Id: id + "_" + token, Type: "Identifier", Token: token,
+			Pos: &node.Positions{StartLine: start + uint(i), EndLine: start + uint(i)}})
+	}
+	return function
+}
+
+func shotnessFile(functions ...*node.Node) *node.Node {
+	return &node.Node{Id: "file", Type: "File", Children: functions}
+}
+
+func consumeShotness(t *testing.T, sh *ShotnessAnalysis, author int, merge bool,
+	before, after *node.Node, diffs ...diffmatchpatch.Diff) {
+	const fileName = "test.go"
+	change := &object.Change{To: object.ChangeEntry{Name: fileName}}
+	if before != nil {
+		change.From = object.ChangeEntry{Name: fileName}
+	}
+	oldLines, newLines := 0, 0
+	for _, diff := range diffs {
+		size := len([]rune(diff.Text))
+		if diff.Type != diffmatchpatch.DiffInsert {
+			oldLines += size
+		}
+		if diff.Type != diffmatchpatch.DiffDelete {
+			newLines += size
+		}
+	}
+	_, err := sh.Consume(map[string]interface{}{
+		core.DependencyCommit:     &object.Commit{},
+		core.DependencyIsMerge:    merge,
+		identity.DependencyAuthor: author,
+		items.DependencyFileDiff: map[string]items.FileDiffData{fileName: {
+			OldLinesOfCode: oldLines, NewLinesOfCode: newLines, Diffs: diffs}},
+		uast_items.DependencyUastChanges: []uast_items.Change{
+			{Change: change, Before: before, After: after}},
+	})
+	assert.NoError(t, err)
+}
+
+func fixtureShotnessFunctions() *ShotnessAnalysis {
+	sh := fixtureShotness()
+	sh.Configure(map[string]interface{}{
+		ConfigShotnessDSLStruct:                         "filter(.type == \"Function\")",
+		ConfigShotnessDSLName:                           ".token",
+		identity.FactIdentityDetectorReversedPeopleDict: []string{"alice", "bob"},
+	})
+	return sh
+}
+
+func TestShotnessChurnAuthorsRenames(t *testing.T) {
+	sh := fixtureShotnessFunctions()
+	v1 := shotnessFile(
+		shotnessFunction("f1", "f", 1, 3, "a", "b", "c"),
+		shotnessFunction("g1", "g", 4, 6, "x", "y", "z"))
+	consumeShotness(t, sh, 0, false, nil, v1,
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffInsert, Text: "123456"})
+	// bob inserts a line into f
+	v2 := shotnessFile(
+		shotnessFunction("f2", "f", 1, 4, "a", "b", "n", "c"),
+		shotnessFunction("g2", "g", 5, 7, "x", "y", "z"))
+	consumeShotness(t, sh, 1, false, v1, v2,
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual, Text: "12"},
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffInsert, Text: "3"},
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual, Text: "4567"})
+	// alice renames g to h and removes its last line
+	v3 := shotnessFile(
+		shotnessFunction("f3", "f", 1, 4, "a", "b", "n", "c"),
+		shotnessFunction("h3", "h", 5, 6, "x", "y", "z"))
+	consumeShotness(t, sh, 0, false, v2, v3,
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual, Text: "123456"},
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffDelete, Text: "7"})
+	// merges are not counted again
+	consumeShotness(t, sh, 1, true, v1, v3,
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual, Text: "12"},
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffInsert, Text: "3"},
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffEqual, Text: "456"})
+
+	result := sh.Finalize().(ShotnessResult)
+	assert.Equal(t, []NodeSummary{
+		{Type: "Function", Name: "f", File: "test.go"},
+		{Type: "Function", Name: "h", File: "test.go"}}, result.Nodes)
+	assert.Equal(t, []map[int]int{{0: 2, 1: 1}, {0: 1, 1: 2}}, result.Counters)
+	assert.Equal(t, []NodeChurn{
+		{Commits: 2, Added: 4, Removed: 0}, {Commits: 2, Added: 3, Removed: 1}}, result.Churn)
+	assert.Equal(t, []map[int]NodeChurn{
+		{0: {Commits: 1, Added: 3}, 1: {Commits: 1, Added: 1}},
+		{0: {Commits: 2, Added: 3, Removed: 1}}}, result.Authors)
+
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, sh.Serialize(result, false, buffer))
+	assert.Equal(t, `  - name: f
+    file: test.go
+    internal_role: Function
+    counters: {"0":2,"1":1}
+    churn: {commits: 2, added: 4, removed: 0}
+    authors: {"alice": {commits: 1, added: 3, removed: 0}, "bob": {commits: 1, added: 1, removed: 0}}
+  - name: h
+    file: test.go
+    internal_role: Function
+    counters: {"0":1,"1":2}
+    churn: {commits: 2, added: 3, removed: 1}
+    authors: {"alice": {commits: 2, added: 3, removed: 1}}
+`, buffer.String())
+
+	buffer.Reset()
+	assert.NoError(t, sh.Serialize(result, true, buffer))
+	message := pb.ShotnessAnalysisResults{}
+	assert.NoError(t, proto.Unmarshal(buffer.Bytes(), &message))
+	assert.Equal(t, []string{"alice", "bob"}, message.AuthorIndex)
+	assert.Len(t, message.Records, 2)
+	assert.Equal(t, int32(1), message.Records[1].Churn.Removed)
+	assert.Equal(t, int32(2), message.Records[1].Authors[0].Commits)
+}
+
+func TestShotnessRenameSimilarity(t *testing.T) {
+	sh := fixtureShotnessFunctions()
+	before := map[string]*node.Node{
+		"f": shotnessFunction("f", "f", 1, 3, "a", "b", "c"),
+		"g": shotnessFunction("g", "g", 4, 6, "x", "y", "z"),
+	}
+	after := map[string]*node.Node{
+		"f":  before["f"],
+		"g2": shotnessFunction("g2", "g2", 4, 6, "x", "y", "w"),
+		"h":  shotnessFunction("h", "h", 7, 9, "x", "y", "z"),
+	}
+	assert.Equal(t, map[string]string{"g": "h"}, sh.detectNodeRenames(before, after))
+	sh.RenameSimilarity = 0
+	assert.Len(t, sh.detectNodeRenames(before, after), 0)
+}
+
+func TestShotnessCheckpointChurn(t *testing.T) {
+	sh := fixtureShotnessFunctions()
+	consumeShotness(t, sh, 1, false, nil,
+		shotnessFile(shotnessFunction("f1", "f", 1, 2, "a", "b")),
+		diffmatchpatch.Diff{Type: diffmatchpatch.DiffInsert, Text: "12"})
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, sh.Checkpoint(buffer))
+	restored := fixtureShotnessFunctions()
+	assert.NoError(t, restored.Restore(buffer))
+	assert.Equal(t, sh.Finalize(), restored.Finalize())
+	assert.Equal(t, map[int]NodeChurn{1: {Commits: 1, Added: 2}},
+		restored.Finalize().(ShotnessResult).Authors[0])
+}
@@ -0,0 +1,781 @@
+package leaves
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
+	"google.golang.org/protobuf/proto"
+)
+
+// SZZAnalysis finds the bug-introducing commits with the SZZ algorithm: the lines which are
+// removed or changed by the fix commits are traced back to the commits which wrote them.
+// The lines are tracked by BurndownAnalysis which knows the author and the tick of each line;
+// the commits of the same author which added lines to the same file in the same tick are
+// indistinguishable and are blamed together, see SZZCommit.Ambiguous. It is a LeafPipelineItem.
+type SZZAnalysis struct {
+	// FixPattern matches the messages of the fix commits if Issues is empty.
+	FixPattern *regexp.Regexp
+	// Issues is the path to the file with the issue identifiers, one per line. If it is set,
+	// the fix commits are those which mention any of the identifiers in the message.
+	Issues string
+
+	// issues is the set of the identifiers read from Issues.
+	issues map[string]bool
+	// origins maps the files to the line origins to the commits which added those lines.
+	// Unlike state, it belongs to the fork because the branches change the lines differently.
+	origins map[string]map[szzLineOrigin][]string
+	// commit is the hash of the last consumed commit.
+	commit string
+	// state is shared between the forks.
+	state *szzState
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+
+	l core.Logger
+}
+
+// szzLineOrigin is the author and the tick of a line as tracked by BurndownAnalysis.
+type szzLineOrigin struct {
+	Author, Tick int
+}
+
+// szzState is the accumulated state of SZZAnalysis.
+type szzState struct {
+	Commits map[string]*SZZCommit
+	Files   map[string]SZZFileStats
+	People  map[int]SZZDevStats
+}
+
+// SZZCommit is the information about a fix or a bug-introducing commit.
+type SZZCommit struct {
+	Author int
+	Tick   int
+	// Fix indicates whether the commit is a fix.
+	Fix bool
+	// BugLines is the number of the lines written in this commit which were later removed
+	// or changed by the fixes.
+	BugLines int
+	// FixedBy are the fixes which removed or changed the lines written in this commit.
+	FixedBy []string
+	// Ambiguous indicates that some of BugLines were blamed on several commits of the same
+	// author which added lines to the same file in the same tick, so this commit may not have
+	// written all of them.
+	Ambiguous bool
+}
+
+// SZZFileStats is the defect statistics of a file.
+type SZZFileStats struct {
+	// Added is the number of the lines ever written in the file.
+	Added int
+	// BugLines is the number of the lines removed or changed by the fixes.
+	BugLines int
+	// Fixes is the number of the fix commits which changed the file.
+	Fixes int
+}
+
+// SZZDevStats is the defect statistics of a developer.
+type SZZDevStats struct {
+	// Commits is the number of the authored non-merge commits.
+	Commits int
+	// Fixes is the number of the authored fix commits.
+	Fixes int
+	// BugIntroducing is the number of the authored bug-introducing commits.
+	BugIntroducing int
+	// BugLines is the number of the authored lines removed or changed by the fixes.
+	BugLines int
+}
+
+// SZZResult is returned by SZZAnalysis.Finalize() and carries the defect statistics.
+type SZZResult struct {
+	// Commits maps the hashes of the fix and the bug-introducing commits to their details.
+	// The rest of the commits neither fix nor introduce bugs. The lines are blamed per author
+	// and tick, so the bug-introducing commits can be over-reported; they are Ambiguous then.
+	Commits map[string]*SZZCommit
+	// Files maps the paths to the defect statistics.
+	Files map[string]SZZFileStats
+	// People maps the developers to the defect statistics.
+	People map[int]SZZDevStats
+
+	// reversedPeopleDict references IdentityDetector.ReversedPeopleDict
+	reversedPeopleDict []string
+	// tickSize references TicksSinceStart.TickSize
+	tickSize time.Duration
+}
+
+const (
+	// ConfigSZZFixPattern is the name of the option to set SZZAnalysis.FixPattern.
+	ConfigSZZFixPattern = "SZZ.FixPattern"
+	// ConfigSZZIssues is the name of the option to set SZZAnalysis.Issues.
+	ConfigSZZIssues = "SZZ.Issues"
+	// DefaultSZZFixPattern is the default value of SZZAnalysis.FixPattern.
+	DefaultSZZFixPattern = `(?i)\b(fix(e[sd])?|bugs?|bugfix|hotfix|defects?)\b`
+)
+
+// issueTokenRE matches the words in the commit messages which can be issue identifiers.
+var issueTokenRE = regexp.MustCompile(`[\w#-]+`)
+
+// IntroducedBug indicates whether the commit wrote lines which were later fixed.
+func (commit *SZZCommit) IntroducedBug() bool {
+	return commit.BugLines > 0
+}
+
+// Density returns the number of the fixes per 1000 written lines.
+func (stats SZZFileStats) Density() float64 {
+	if stats.Added == 0 {
+		return 0
+	}
+	return float64(stats.Fixes) * 1000 / float64(stats.Added)
+}
+
+func (stats SZZFileStats) add(other SZZFileStats) SZZFileStats {
+	return SZZFileStats{
+		Added:    stats.Added + other.Added,
+		BugLines: stats.BugLines + other.BugLines,
+		Fixes:    stats.Fixes + other.Fixes,
+	}
+}
+
+func (stats SZZDevStats) add(other SZZDevStats) SZZDevStats {
+	return SZZDevStats{
+		Commits:        stats.Commits + other.Commits,
+		Fixes:          stats.Fixes + other.Fixes,
+		BugIntroducing: stats.BugIntroducing + other.BugIntroducing,
+		BugLines:       stats.BugLines + other.BugLines,
+	}
+}
+
+// Name of this PipelineItem. Uniquely identifies the type, used for mapping keys, etc.
+func (szz *SZZAnalysis) Name() string {
+	return "SZZ"
+}
+
+// Provides returns the list of names of entities which are produced by this PipelineItem.
+// Each produced entity will be inserted into `deps` of dependent Consume()-s according
+// to this list. Also used by core.Registry to build the global map of providers.
+func (szz *SZZAnalysis) Provides() []string {
+	return []string{}
+}
+
+// Requires returns the list of names of entities which are needed by this PipelineItem.
+// Each requested entity will be inserted into `deps` of Consume(). In turn, those
+// entities are Provides() upstream.
+func (szz *SZZAnalysis) Requires() []string {
+	return []string{
+		DependencyBurndownLines, items.DependencyTreeChanges, identity.DependencyAuthor,
+		items.DependencyTick,
+	}
+}
+
+// ListConfigurationOptions returns the list of changeable public properties of this PipelineItem.
+func (szz *SZZAnalysis) ListConfigurationOptions() []core.ConfigurationOption {
+	options := [...]core.ConfigurationOption{{
+		Name:        ConfigSZZFixPattern,
+		Description: "Regular expression which matches the messages of the fix commits.",
+		Flag:        "szz-fix-pattern",
+		Type:        core.StringConfigurationOption,
+		Default:     DefaultSZZFixPattern}, {
+		Name: ConfigSZZIssues,
+		Description: "Path to the file with the bug issue identifiers, one per line; the commits " +
+			"which mention them are the fixes instead of those matched by the pattern.",
+		Flag:    "szz-issues",
+		Type:    core.PathConfigurationOption,
+		Default: ""},
+	}
+	return options[:]
+}
+
+// Configure sets the properties previously published by ListConfigurationOptions().
+func (szz *SZZAnalysis) Configure(facts map[string]interface{}) error {
+	if l, exists := facts[core.ConfigLogger].(core.Logger); exists {
+		szz.l = l
+	}
+	if val, exists := facts[ConfigSZZFixPattern].(string); exists {
+		pattern, err := regexp.Compile(val)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", ConfigSZZFixPattern, err)
+		}
+		szz.FixPattern = pattern
+	}
+	if val, exists := facts[ConfigSZZIssues].(string); exists {
+		szz.Issues = val
+	}
+	if val, exists := facts[identity.FactIdentityDetectorReversedPeopleDict].([]string); exists {
+		szz.reversedPeopleDict = val
+	}
+	if val, exists := facts[items.FactTickSize].(time.Duration); exists {
+		szz.tickSize = val
+	}
+	subscribeBurndownLines(facts, true)
+	return nil
+}
+
+// Flag for the command line switch which enables this analysis.
+func (szz *SZZAnalysis) Flag() string {
+	return "szz"
+}
+
+// Description returns the text which explains what the analysis is doing.
+func (szz *SZZAnalysis) Description() string {
+	return "Finds the bug-introducing commits by tracing the lines changed by the fixes back to " +
+		"the commits which wrote them (SZZ) and reports the defect statistics per file and developer."
+}
+
+// Initialize resets the temporary caches and prepares this PipelineItem for a series of Consume()
+// calls. The repository which is going to be analysed is supplied as an argument.
+func (szz *SZZAnalysis) Initialize(repository *git.Repository) error {
+	szz.l = core.GetLogger()
+	if szz.FixPattern == nil {
+		szz.FixPattern = regexp.MustCompile(DefaultSZZFixPattern)
+	}
+	szz.issues = nil
+	if szz.Issues != "" {
+		issues, err := readSZZIssues(szz.Issues)
+		if err != nil {
+			return err
+		}
+		szz.issues = issues
+	}
+	if szz.tickSize == 0 {
+		szz.tickSize = items.DefaultTicksSinceStartTickSize * time.Hour
+	}
+	szz.origins = map[string]map[szzLineOrigin][]string{}
+	szz.commit = ""
+	szz.state = &szzState{
+		Commits: map[string]*SZZCommit{},
+		Files:   map[string]SZZFileStats{},
+		People:  map[int]SZZDevStats{},
+	}
+	return nil
+}
+
+// readSZZIssues reads the non-empty lines of the file without the leading '#'.
+func readSZZIssues(path string) (map[string]bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	issues := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if issue := strings.TrimPrefix(strings.TrimSpace(scanner.Text()), "#"); issue != "" {
+			issues[issue] = true
+		}
+	}
+	return issues, scanner.Err()
+}
+
+// isFix returns true if the commit message matches FixPattern or mentions any of the issues.
+func (szz *SZZAnalysis) isFix(message string) bool {
+	if szz.issues == nil {
+		return szz.FixPattern.MatchString(message)
+	}
+	for _, token := range issueTokenRE.FindAllString(message, -1) {
+		if szz.issues[strings.TrimPrefix(token, "#")] {
+			return true
+		}
+	}
+	return false
+}
+
+// Consume runs this PipelineItem on the next commit data.
+// `deps` contain all the results from upstream PipelineItem-s as requested by Requires().
+// Additionally, DependencyCommit is always present there and represents the analysed *object.Commit.
+// This function returns the mapping with analysis results. The keys must be the same as
+// in Provides(). If there was an error, nil is returned.
+func (szz *SZZAnalysis) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
+	commit := deps[core.DependencyCommit].(*object.Commit)
+	lines := deps[DependencyBurndownLines].(BurndownLines)
+	// the conflicts were resolved by the merge commit consumed before
+	for _, update := range lines.Merged {
+		szz.addLines(update, szz.commit)
+	}
+	szz.commit = commit.Hash.String()
+	state := szz.state
+	merge := deps[core.DependencyIsMerge].(bool)
+	fix := false
+	if !merge {
+		author := deps[identity.DependencyAuthor].(int)
+		fix = szz.isFix(commit.Message)
+		var stats SZZDevStats
+		stats.Commits = 1
+		if fix {
+			stats.Fixes = 1
+			state.Commits[szz.commit] = &SZZCommit{
+				Author: author, Tick: deps[items.DependencyTick].(int), Fix: true}
+		}
+		if author != identity.AuthorMissing {
+			state.People[author] = state.People[author].add(stats)
+		}
+	}
+	for _, change := range deps[items.DependencyTreeChanges].(object.Changes) {
+		action, _ := change.Action()
+		name := change.To.Name
+		if action == merkletrie.Delete {
+			name = change.From.Name
+		}
+		if action == merkletrie.Modify && change.From.Name != change.To.Name {
+			if origins := szz.origins[change.From.Name]; origins != nil {
+				szz.origins[change.To.Name] = origins
+				delete(szz.origins, change.From.Name)
+			}
+		}
+		if fix {
+			state.Files[name] = state.Files[name].add(SZZFileStats{Fixes: 1})
+		}
+	}
+	changed := map[string]bool{}
+	for _, update := range lines.Updates {
+		changed[update.File] = true
+		if update.Delta > 0 {
+			szz.addLines(update, szz.commit)
+		} else if fix {
+			szz.blameLines(update)
+		}
+	}
+	if !merge {
+		// the lines of a merge are final only after Merge()
+		szz.prune(lines.burndown, changed)
+	}
+	return nil, nil
+}
+
+// addLines remembers that the commit added the lines.
+func (szz *SZZAnalysis) addLines(update LineUpdate, commit string) {
+	if update.Delta <= 0 {
+		return
+	}
+	szz.state.Files[update.File] = szz.state.Files[update.File].add(
+		SZZFileStats{Added: update.Delta})
+	origins := szz.origins[update.File]
+	if origins == nil {
+		origins = map[szzLineOrigin][]string{}
+		szz.origins[update.File] = origins
+	}
+	origin := szzLineOrigin{Author: update.CurrentAuthor, Tick: update.CurrentTick}
+	origins[origin] = appendSZZCommit(origins[origin], commit)
+}
+
+// blameLines blames the lines removed by the consumed fix on the commits which added them.
+func (szz *SZZAnalysis) blameLines(update LineUpdate) {
+	state := szz.state
+	lines := -update.Delta
+	author := update.PreviousAuthor
+	state.Files[update.File] = state.Files[update.File].add(SZZFileStats{BugLines: lines})
+	if author != identity.AuthorMissing {
+		state.People[author] = state.People[author].add(SZZDevStats{BugLines: lines})
+	}
+	origin := szzLineOrigin{Author: author, Tick: update.PreviousTick}
+	hashes := make([]string, 0, 1)
+	for _, hash := range szz.origins[update.File][origin] {
+		if hash != szz.commit {
+			hashes = append(hashes, hash)
+		}
+	}
+	for _, hash := range hashes {
+		buggy := state.Commits[hash]
+		if buggy == nil {
+			buggy = &SZZCommit{Author: author, Tick: update.PreviousTick}
+			state.Commits[hash] = buggy
+		}
+		if !buggy.IntroducedBug() && author != identity.AuthorMissing {
+			state.People[author] = state.People[author].add(SZZDevStats{BugIntroducing: 1})
+		}
+		buggy.BugLines += lines
+		buggy.FixedBy = appendSZZCommit(buggy.FixedBy, szz.commit)
+		buggy.Ambiguous = buggy.Ambiguous || len(hashes) > 1
+	}
+}
+
+// prune drops the origins of the lines which no longer exist in the specified files.
+func (szz *SZZAnalysis) prune(analyser *BurndownAnalysis, files map[string]bool) {
+	for name := range files {
+		origins := szz.origins[name]
+		if origins == nil {
+			continue
+		}
+		file := analyser.files[name]
+		if file == nil {
+			delete(szz.origins, name)
+			continue
+		}
+		alive := map[szzLineOrigin]bool{}
+		previousLine, previous := 0, szzLineOrigin{}
+		file.ForEach(func(line, value int) {
+			if line > previousLine {
+				alive[previous] = true
+			}
+			previousLine = line
+			previous.Author, previous.Tick = analyser.unpackPersonWithTick(value)
+		})
+		for origin := range origins {
+			if !alive[origin] {
+				delete(origins, origin)
+			}
+		}
+		if len(origins) == 0 {
+			delete(szz.origins, name)
+		}
+	}
+}
+
+// appendSZZCommit appends the hash to the list unless it is already there.
+func appendSZZCommit(hashes []string, hash string) []string {
+	for _, existing := range hashes {
+		if existing == hash {
+			return hashes
+		}
+	}
+	return append(hashes, hash)
+}
+
+// Finalize returns the result of the analysis. Further Consume() calls are not expected.
+func (szz *SZZAnalysis) Finalize() interface{} {
+	result := SZZResult{
+		Commits:            make(map[string]*SZZCommit, len(szz.state.Commits)),
+		Files:              make(map[string]SZZFileStats, len(szz.state.Files)),
+		People:             make(map[int]SZZDevStats, len(szz.state.People)),
+		reversedPeopleDict: szz.reversedPeopleDict,
+		tickSize:           szz.tickSize,
+	}
+	for hash, commit := range szz.state.Commits {
+		clone := *commit
+		clone.FixedBy = append([]string(nil), commit.FixedBy...)
+		result.Commits[hash] = &clone
+	}
+	for file, stats := range szz.state.Files {
+		result.Files[file] = stats
+	}
+	for dev, stats := range szz.state.People {
+		result.People[dev] = stats
+	}
+	return result
+}
+
+// Fork clones this item. The state is shared and the origins are copied.
+func (szz *SZZAnalysis) Fork(n int) []core.PipelineItem {
+	result := make([]core.PipelineItem, n)
+	for i := range result {
+		clone := *szz
+		clone.origins = make(map[string]map[szzLineOrigin][]string, len(szz.origins))
+		for name, origins := range szz.origins {
+			copied := make(map[szzLineOrigin][]string, len(origins))
+			for origin, hashes := range origins {
+				// the capacity is limited so that append() never writes to the shared array
+				copied[origin] = hashes[:len(hashes):len(hashes)]
+			}
+			clone.origins[name] = copied
+		}
+		result[i] = &clone
+	}
+	return result
+}
+
+// Merge combines several items together. The origins of the branches are joined; those of
+// the lines which were removed in some branch are dropped when the file changes next time.
+func (szz *SZZAnalysis) Merge(branches []core.PipelineItem) {
+	for _, branch := range branches {
+		for name, origins := range branch.(*SZZAnalysis).origins {
+			merged := szz.origins[name]
+			if merged == nil {
+				merged = map[szzLineOrigin][]string{}
+				szz.origins[name] = merged
+			}
+			for origin, hashes := range origins {
+				for _, hash := range hashes {
+					merged[origin] = appendSZZCommit(merged[origin], hash)
+				}
+			}
+		}
+	}
+}
+
+// szzCheckpoint is the state of SZZAnalysis saved in core.Checkpoint.
+type szzCheckpoint struct {
+	State   szzState
+	Origins map[string]map[szzLineOrigin][]string
+}
+
+// Checkpoint writes the state and the origins of the lines.
+// It implements core.CheckpointablePipelineItem.
+func (szz *SZZAnalysis) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, szzCheckpoint{State: *szz.state, Origins: szz.origins})
+}
+
+// Restore loads the state written by Checkpoint(). The same restrictions as in
+// BurndownAnalysis.Restore() apply. It implements core.CheckpointablePipelineItem.
+func (szz *SZZAnalysis) Restore(reader io.Reader) error {
+	checkpoint := szzCheckpoint{}
+	if err := core.DecodeCheckpoint(reader, &checkpoint); err != nil {
+		return err
+	}
+	*szz.state = checkpoint.State
+	szz.origins = checkpoint.Origins
+	// gob does not transmit empty maps
+	if szz.origins == nil {
+		szz.origins = map[string]map[szzLineOrigin][]string{}
+	}
+	if szz.state.Commits == nil {
+		szz.state.Commits = map[string]*SZZCommit{}
+	}
+	if szz.state.Files == nil {
+		szz.state.Files = map[string]SZZFileStats{}
+	}
+	if szz.state.People == nil {
+		szz.state.People = map[int]SZZDevStats{}
+	}
+	return nil
+}
+
+// Serialize converts the analysis result as returned by Finalize() to text or bytes.
+// The text format is YAML and the bytes format is Protocol Buffers.
+func (szz *SZZAnalysis) Serialize(result interface{}, binary bool, writer io.Writer) error {
+	szzResult, ok := result.(SZZResult)
+	if !ok {
+		return fmt.Errorf("result is not an SZZ result: '%v'", result)
+	}
+	if binary {
+		return szz.serializeBinary(&szzResult, writer)
+	}
+	szz.serializeText(&szzResult, writer)
+	return nil
+}
+
+func (szz *SZZAnalysis) serializeText(result *SZZResult, writer io.Writer) {
+	fmt.Fprintln(writer, "  tick_size:", int(result.tickSize.Seconds()))
+	fmt.Fprintln(writer, "  commits:")
+	hashes := make([]string, 0, len(result.Commits))
+	for hash := range result.Commits {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	for _, hash := range hashes {
+		commit := result.Commits[hash]
+		author := commit.Author
+		if author == identity.AuthorMissing {
+			author = -1
+		}
+		fmt.Fprintf(writer,
+			"    %s: {author: %d, tick: %d, fix: %t, introduced_bug: %t, bug_lines: %d, "+
+				"fixed_by: [%s], ambiguous: %t}\n",
+			hash, author, commit.Tick, commit.Fix, commit.IntroducedBug(), commit.BugLines,
+			strings.Join(commit.FixedBy, ", "), commit.Ambiguous)
+	}
+	fmt.Fprintln(writer, "  files:")
+	files := make([]string, 0, len(result.Files))
+	for file := range result.Files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		stats := result.Files[file]
+		fmt.Fprintf(writer, "    %s: {added: %d, bug_lines: %d, fixes: %d, density: %.4f}\n",
+			file, stats.Added, stats.BugLines, stats.Fixes, stats.Density())
+	}
+	fmt.Fprintln(writer, "  developers:")
+	devs := make([]int, 0, len(result.People))
+	for dev := range result.People {
+		devs = append(devs, dev)
+	}
+	sort.Ints(devs)
+	for _, dev := range devs {
+		stats := result.People[dev]
+		fmt.Fprintf(writer, "    %d: {commits: %d, fixes: %d, bug_introducing: %d, bug_lines: %d}\n",
+			dev, stats.Commits, stats.Fixes, stats.BugIntroducing, stats.BugLines)
+	}
+	fmt.Fprintln(writer, "  people:")
+	for _, person := range result.reversedPeopleDict {
+		fmt.Fprintf(writer, "  - %s\n", person)
+	}
+}
+
+func (szz *SZZAnalysis) serializeBinary(result *SZZResult, writer io.Writer) error {
+	message := pb.SZZAnalysisResults{
+		Commits:     make(map[string]*pb.SZZCommit, len(result.Commits)),
+		Files:       make(map[string]*pb.SZZFileStats, len(result.Files)),
+		Developers:  make(map[int32]*pb.SZZDevStats, len(result.People)),
+		AuthorIndex: result.reversedPeopleDict,
+		TickSize:    int64(result.tickSize),
+	}
+	for hash, commit := range result.Commits {
+		author := commit.Author
+		if author == identity.AuthorMissing {
+			author = -1
+		}
+		message.Commits[hash] = &pb.SZZCommit{
+			Author:    int32(author),
+			Tick:      int32(commit.Tick),
+			Fix:       commit.Fix,
+			BugLines:  int32(commit.BugLines),
+			FixedBy:   commit.FixedBy,
+			Ambiguous: commit.Ambiguous,
+		}
+	}
+	for file, stats := range result.Files {
+		message.Files[file] = &pb.SZZFileStats{
+			Added:    int32(stats.Added),
+			BugLines: int32(stats.BugLines),
+			Fixes:    int32(stats.Fixes),
+		}
+	}
+	for dev, stats := range result.People {
+		message.Developers[int32(dev)] = &pb.SZZDevStats{
+			Commits:        int32(stats.Commits),
+			Fixes:          int32(stats.Fixes),
+			BugIntroducing: int32(stats.BugIntroducing),
+			BugLines:       int32(stats.BugLines),
+		}
+	}
+	serialized, err := proto.Marshal(&message)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(serialized)
+	return err
+}
+
+// Deserialize converts the specified protobuf bytes to SZZResult.
+func (szz *SZZAnalysis) Deserialize(pbmessage []byte) (interface{}, error) {
+	message := pb.SZZAnalysisResults{}
+	if err := proto.Unmarshal(pbmessage, &message); err != nil {
+		return nil, err
+	}
+	result := SZZResult{
+		Commits:            make(map[string]*SZZCommit, len(message.Commits)),
+		Files:              make(map[string]SZZFileStats, len(message.Files)),
+		People:             make(map[int]SZZDevStats, len(message.Developers)),
+		reversedPeopleDict: message.AuthorIndex,
+		tickSize:           time.Duration(message.TickSize),
+	}
+	for hash, commit := range message.Commits {
+		author := int(commit.Author)
+		if author == -1 {
+			author = identity.AuthorMissing
+		}
+		result.Commits[hash] = &SZZCommit{
+			Author:    author,
+			Tick:      int(commit.Tick),
+			Fix:       commit.Fix,
+			BugLines:  int(commit.BugLines),
+			FixedBy:   commit.FixedBy,
+			Ambiguous: commit.Ambiguous,
+		}
+	}
+	for file, stats := range message.Files {
+		result.Files[file] = SZZFileStats{
+			Added:    int(stats.Added),
+			BugLines: int(stats.BugLines),
+			Fixes:    int(stats.Fixes),
+		}
+	}
+	for dev, stats := range message.Developers {
+		result.People[int(dev)] = SZZDevStats{
+			Commits:        int(stats.Commits),
+			Fixes:          int(stats.Fixes),
+			BugIntroducing: int(stats.BugIntroducing),
+			BugLines:       int(stats.BugLines),
+		}
+	}
+	return result, nil
+}
+
+// MergeResults combines two SZZResult-s together. The ticks are aligned in time, the stats of
+// the same developers and paths are summed and the commits are joined.
+func (szz *SZZAnalysis) MergeResults(
+	r1, r2 interface{}, c1, c2 *core.CommonAnalysisResult) interface{} {
+	sr1 := r1.(SZZResult)
+	sr2 := r2.(SZZResult)
+	if sr1.tickSize != sr2.tickSize {
+		return fmt.Errorf("mismatching tick sizes (r1: %d, r2: %d) received",
+			sr1.tickSize, sr2.tickSize)
+	}
+	if sr1.tickSize == 0 {
+		return errors.New("tick size is not set")
+	}
+	t01 := items.FloorTime(c1.BeginTimeAsTime(), sr1.tickSize)
+	t02 := items.FloorTime(c2.BeginTimeAsTime(), sr2.tickSize)
+	t0 := t01
+	if t02.Before(t0) {
+		t0 = t02
+	}
+	offset1 := int(t01.Sub(t0) / sr1.tickSize)
+	offset2 := int(t02.Sub(t0) / sr2.tickSize)
+
+	merged := SZZResult{
+		Commits:  map[string]*SZZCommit{},
+		Files:    map[string]SZZFileStats{},
+		People:   map[int]SZZDevStats{},
+		tickSize: sr1.tickSize,
+	}
+	var mergedIndex map[string]identity.MergedIndex
+	mergedIndex, merged.reversedPeopleDict = identity.MergeReversedDictsIdentities(
+		sr1.reversedPeopleDict, sr2.reversedPeopleDict)
+	for _, pair := range []struct {
+		result SZZResult
+		offset int
+	}{{sr1, offset1}, {sr2, offset2}} {
+		remap := func(dev int) int {
+			if dev < 0 || dev >= len(pair.result.reversedPeopleDict) {
+				return identity.AuthorMissing
+			}
+			return mergedIndex[pair.result.reversedPeopleDict[dev]].Final
+		}
+		for hash, commit := range pair.result.Commits {
+			if existing := merged.Commits[hash]; existing != nil {
+				// the same commit was analysed in both repositories
+				existing.Fix = existing.Fix || commit.Fix
+				existing.BugLines += commit.BugLines
+				existing.FixedBy = append(existing.FixedBy, commit.FixedBy...)
+				existing.Ambiguous = existing.Ambiguous || commit.Ambiguous
+				continue
+			}
+			clone := *commit
+			clone.Author = remap(commit.Author)
+			clone.Tick += pair.offset
+			clone.FixedBy = append([]string(nil), commit.FixedBy...)
+			merged.Commits[hash] = &clone
+		}
+		for file, stats := range pair.result.Files {
+			merged.Files[file] = merged.Files[file].add(stats)
+		}
+		for dev, stats := range pair.result.People {
+			if dev = remap(dev); dev != identity.AuthorMissing {
+				merged.People[dev] = merged.People[dev].add(stats)
+			}
+		}
+	}
+	return merged
+}
+
+// GetTickSize returns the tick size used to generate this SZZ analysis result.
+func (sr SZZResult) GetTickSize() time.Duration {
+	return sr.tickSize
+}
+
+// GetIdentities returns the list of developer identities used to generate this SZZ analysis
+// result. The format is |-joined keys, see internals/plumbing/identity for details.
+func (sr SZZResult) GetIdentities() []string {
+	return sr.reversedPeopleDict
+}
+
+var _ core.PipelineItem = (*SZZAnalysis)(nil)
+
+func init() {
+	core.Registry.Register(&SZZAnalysis{})
+}
@@ -0,0 +1,283 @@
+package leaves
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	items "github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing/identity"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSZZMeta(t *testing.T) {
+	szz := &SZZAnalysis{}
+	assert.Equal(t, "SZZ", szz.Name())
+	assert.Equal(t, "szz", szz.Flag())
+	assert.Len(t, szz.Provides(), 0)
+	assert.Equal(t, []string{
+		DependencyBurndownLines, items.DependencyTreeChanges, identity.DependencyAuthor,
+		items.DependencyTick,
+	}, szz.Requires())
+	opts := szz.ListConfigurationOptions()
+	assert.Len(t, opts, 2)
+	assert.Equal(t, ConfigSZZFixPattern, opts[0].Name)
+	assert.Equal(t, ConfigSZZIssues, opts[1].Name)
+	assert.NoError(t, szz.Configure(map[string]interface{}{
+		ConfigSZZFixPattern: "^bug",
+		ConfigSZZIssues:     "issues.txt",
+	}))
+	assert.Equal(t, "^bug", szz.FixPattern.String())
+	assert.Equal(t, "issues.txt", szz.Issues)
+	subscription := &burndownSubscription{}
+	assert.NoError(t, szz.Configure(map[string]interface{}{factBurndownSubscription: subscription}))
+	assert.Equal(t, burndownSubscription{lines: true, authors: true}, *subscription)
+	assert.Error(t, szz.Configure(map[string]interface{}{ConfigSZZFixPattern: "("}))
+	szz.Issues = path.Join(t.TempDir(), "missing")
+	assert.Error(t, szz.Initialize(test.Repository))
+}
+
+func TestSZZRegistration(t *testing.T) {
+	testLeafRegistration(t, &SZZAnalysis{})
+}
+
+func TestSZZIsFix(t *testing.T) {
+	szz := &SZZAnalysis{FixPattern: regexp.MustCompile(DefaultSZZFixPattern)}
+	assert.True(t, szz.isFix("Fix the crash"))
+	assert.True(t, szz.isFix("fix(parser): overflow"))
+	assert.True(t, szz.isFix("Handle the empty input\n\nThis bug was reported by Bob."))
+	assert.False(t, szz.isFix("Add the prefix option"))
+	assert.False(t, szz.isFix("Debug output"))
+
+	issues := path.Join(t.TempDir(), "issues.txt")
+	require.NoError(t, os.WriteFile(issues, []byte("PROJ-7\n\n#42\n"), 0644))
+	szz.Issues = issues
+	require.NoError(t, szz.Initialize(test.Repository))
+	assert.True(t, szz.isFix("Resolve PROJ-7."))
+	assert.True(t, szz.isFix("Closes #42"))
+	assert.True(t, szz.isFix("Closes 42"))
+	assert.False(t, szz.isFix("Resolve PROJ-70"))
+	assert.False(t, szz.isFix("Fix the crash"))
+}
+
+func szzRepository() *leafRepository {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	return newLeafRepository(
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start, Message: "Add a",
+			Files: map[string]string{"a.go": "1\n2\n3\n4\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 1),
+			Message: "Add b", Files: map[string]string{"b.go": "1\n2\n3\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 2),
+			Message: "Fix the crash in a", Files: map[string]string{"a.go": "1\nX\n3\n4\n"}},
+		test.Commit{Author: "Carol", Email: "carol@example.com", When: start.AddDate(0, 0, 3),
+			Message: "fix: b", Files: map[string]string{"b.go": "1\n2\nY\n"}},
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start.AddDate(0, 0, 4),
+			Message: "Refactor a", Files: map[string]string{"a.go": "1\n3\n4\n"}})
+}
+
+func TestSZZPipeline(t *testing.T) {
+	repo := szzRepository()
+	commits := repo.Commits
+	result := repo.Run(t, &SZZAnalysis{}, commits, nil).(SZZResult)
+	assert.Equal(t, []string{"alice|alice@example.com", "bob|bob@example.com", "carol|carol@example.com"},
+		result.reversedPeopleDict)
+	hash := func(i int) string {
+		return commits[i].Hash.String()
+	}
+	// Alice's line is fixed by Bob, Bob's line is fixed by Carol, and Alice's refactoring
+	// of Bob's fix is not a fix
+	assert.Equal(t, map[string]*SZZCommit{
+		hash(0): {Author: 0, Tick: 0, BugLines: 1, FixedBy: []string{hash(2)}},
+		hash(1): {Author: 1, Tick: 1, BugLines: 1, FixedBy: []string{hash(3)}},
+		hash(2): {Author: 1, Tick: 2, Fix: true},
+		hash(3): {Author: 2, Tick: 3, Fix: true},
+	}, result.Commits)
+	assert.True(t, result.Commits[hash(0)].IntroducedBug())
+	assert.False(t, result.Commits[hash(2)].IntroducedBug())
+	assert.Equal(t, map[string]SZZFileStats{
+		"a.go": {Added: 5, BugLines: 1, Fixes: 1},
+		"b.go": {Added: 4, BugLines: 1, Fixes: 1},
+	}, result.Files)
+	assert.InDelta(t, 200, result.Files["a.go"].Density(), 1e-9)
+	assert.Equal(t, 0.0, SZZFileStats{}.Density())
+	assert.Equal(t, map[int]SZZDevStats{
+		0: {Commits: 2, BugIntroducing: 1, BugLines: 1},
+		1: {Commits: 2, Fixes: 1, BugIntroducing: 1, BugLines: 1},
+		2: {Commits: 1, Fixes: 1},
+	}, result.People)
+
+	result = repo.Run(t, &SZZAnalysis{}, commits,
+		map[string]interface{}{ConfigSZZFixPattern: "^fix:"}).(SZZResult)
+	assert.Equal(t, map[string]*SZZCommit{
+		hash(1): {Author: 1, Tick: 1, BugLines: 1, FixedBy: []string{hash(3)}},
+		hash(3): {Author: 2, Tick: 3, Fix: true},
+	}, result.Commits)
+}
+
+func TestSZZFixWithoutRemovedLines(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := newLeafRepository(
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start, Message: "Add a",
+			Files: map[string]string{"a.go": "1\n2\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 1),
+			Message: "Fix the missing check", Files: map[string]string{"a.go": "1\n2\n3\n"}})
+	result := repo.Run(t, &SZZAnalysis{}, repo.Commits, nil).(SZZResult)
+	// there is nothing to blame when the fix only adds lines
+	assert.Equal(t, map[string]*SZZCommit{
+		repo.Commits[1].Hash.String(): {Author: 1, Tick: 1, Fix: true},
+	}, result.Commits)
+	assert.Equal(t, map[string]SZZFileStats{"a.go": {Added: 3, Fixes: 1}}, result.Files)
+}
+
+func TestSZZAmbiguous(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := newLeafRepository(
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start, Message: "Add a",
+			Files: map[string]string{"a.go": "1\n2\n"}},
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start.Add(time.Hour),
+			Message: "Extend a", Files: map[string]string{"a.go": "1\n2\n3\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 1),
+			Message: "Fix the crash", Files: map[string]string{"a.go": "X\n2\n3\n"}})
+	result := repo.Run(t, &SZZAnalysis{}, repo.Commits, nil).(SZZResult)
+	hash := func(i int) string {
+		return repo.Commits[i].Hash.String()
+	}
+	// both commits of Alice wrote lines in the same tick, so both are blamed
+	assert.Equal(t, map[string]*SZZCommit{
+		hash(0): {Author: 0, Tick: 0, BugLines: 1, FixedBy: []string{hash(2)}, Ambiguous: true},
+		hash(1): {Author: 0, Tick: 0, BugLines: 1, FixedBy: []string{hash(2)}, Ambiguous: true},
+		hash(2): {Author: 1, Tick: 1, Fix: true},
+	}, result.Commits)
+}
+
+func TestSZZOrigins(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	repo := newLeafRepository(
+		test.Commit{Author: "Alice", Email: "alice@example.com", When: start, Message: "Add a",
+			Files: map[string]string{"a.go": "1\n2\n", "b.go": "1\n"}},
+		test.Commit{Author: "Bob", Email: "bob@example.com", When: start.AddDate(0, 0, 1),
+			Message: "Rewrite a", Files: map[string]string{"a.go": "3\n4\n", "b.go": ""}})
+	pipeline := core.NewPipeline(repo.repository)
+	szz := pipeline.DeployItem(&SZZAnalysis{}).(*SZZAnalysis)
+	require.NoError(t, pipeline.Initialize(map[string]interface{}{
+		core.ConfigPipelineCommits: repo.Commits}))
+	_, err := pipeline.Run(repo.Commits)
+	require.NoError(t, err)
+	// the lines of Alice are gone
+	assert.Equal(t, map[string]map[szzLineOrigin][]string{
+		"a.go": {{Author: 1, Tick: 1}: {repo.Commits[1].Hash.String()}},
+	}, szz.origins)
+}
+
+func TestSZZForkMerge(t *testing.T) {
+	szz := &SZZAnalysis{
+		origins: map[string]map[szzLineOrigin][]string{
+			"a.go": {{Author: 0, Tick: 0}: make([]string, 1, 4)},
+		},
+		state: &szzState{Files: map[string]SZZFileStats{}},
+	}
+	szz.origins["a.go"][szzLineOrigin{}][0] = "x"
+	forks := szz.Fork(2)
+	first, second := forks[0].(*SZZAnalysis), forks[1].(*SZZAnalysis)
+	first.addLines(LineUpdate{File: "a.go", Delta: 1}, "y")
+	second.addLines(LineUpdate{File: "a.go", Delta: 1}, "z")
+	second.addLines(LineUpdate{File: "b.go", Delta: 1}, "z")
+	assert.Equal(t, []string{"x"}, szz.origins["a.go"][szzLineOrigin{}])
+	assert.Equal(t, []string{"x", "y"}, first.origins["a.go"][szzLineOrigin{}])
+	assert.Equal(t, []string{"x", "z"}, second.origins["a.go"][szzLineOrigin{}])
+	assert.Nil(t, first.origins["b.go"])
+	assert.Equal(t, szz.state, first.state)
+
+	first.Merge([]core.PipelineItem{second})
+	assert.Equal(t, map[string]map[szzLineOrigin][]string{
+		"a.go": {{}: {"x", "y", "z"}},
+		"b.go": {{}: {"z"}},
+	}, first.origins)
+	assert.Equal(t, []string{"x", "z"}, second.origins["a.go"][szzLineOrigin{}])
+}
+
+func TestSZZCheckpoint(t *testing.T) {
+	repo := szzRepository()
+	full := repo.Run(t, &SZZAnalysis{}, repo.Commits, nil).(SZZResult)
+	resumed := repo.Resume(t, &SZZAnalysis{}, 2, nil).(SZZResult)
+	assert.Equal(t, full.Commits, resumed.Commits)
+	assert.Equal(t, full.Files, resumed.Files)
+	assert.Equal(t, full.People, resumed.People)
+}
+
+func TestSZZSerialize(t *testing.T) {
+	result := SZZResult{
+		Commits: map[string]*SZZCommit{
+			"aaa": {Author: 0, Tick: 0, BugLines: 2, FixedBy: []string{"bbb", "ccc"}, Ambiguous: true},
+			"bbb": {Author: 1, Tick: 3, Fix: true},
+			"ccc": {Author: identity.AuthorMissing, Tick: 4, Fix: true},
+		},
+		Files:              map[string]SZZFileStats{"a.go": {Added: 500, BugLines: 2, Fixes: 2}},
+		People:             map[int]SZZDevStats{0: {Commits: 1, BugIntroducing: 1, BugLines: 2}, 1: {Commits: 1, Fixes: 1}},
+		reversedPeopleDict: []string{"alice", "bob"},
+		tickSize:           24 * time.Hour,
+	}
+	szz := &SZZAnalysis{}
+	buffer := &bytes.Buffer{}
+	assert.NoError(t, szz.Serialize(result, false, buffer))
+	assert.Equal(t, `  tick_size: 86400
+  commits:
+    aaa: {author: 0, tick: 0, fix: false, introduced_bug: true, bug_lines: 2, fixed_by: [bbb, ccc], ambiguous: true}
+    bbb: {author: 1, tick: 3, fix: true, introduced_bug: false, bug_lines: 0, fixed_by: [], ambiguous: false}
+    ccc: {author: -1, tick: 4, fix: true, introduced_bug: false, bug_lines: 0, fixed_by: [], ambiguous: false}
+  files:
+    a.go: {added: 500, bug_lines: 2, fixes: 2, density: 4.0000}
+  developers:
+    0: {commits: 1, fixes: 0, bug_introducing: 1, bug_lines: 2}
+    1: {commits: 1, fixes: 1, bug_introducing: 0, bug_lines: 0}
+  people:
+  - alice
+  - bob
+`, buffer.String())
+
+	testLeafBinaryRoundTrip(t, szz, result)
+}
+
+func TestSZZMergeResults(t *testing.T) {
+	r1 := SZZResult{
+		Commits: map[string]*SZZCommit{
+			"aaa": {Author: 0, Tick: 0, BugLines: 1, FixedBy: []string{"bbb"}},
+			"bbb": {Author: 1, Tick: 1, Fix: true},
+		},
+		Files:              map[string]SZZFileStats{"a": {Added: 5, BugLines: 1, Fixes: 1}},
+		People:             map[int]SZZDevStats{0: {Commits: 1, BugIntroducing: 1, BugLines: 1}, 1: {Commits: 1, Fixes: 1}},
+		reversedPeopleDict: []string{"alice", "bob"},
+		tickSize:           24 * time.Hour,
+	}
+	r2 := SZZResult{
+		Commits:            map[string]*SZZCommit{"ccc": {Author: 0, Tick: 0, Fix: true}},
+		Files:              map[string]SZZFileStats{"a": {Added: 2, Fixes: 1}},
+		People:             map[int]SZZDevStats{0: {Commits: 1, Fixes: 1}},
+		reversedPeopleDict: []string{"bob"},
+		tickSize:           24 * time.Hour,
+	}
+	c1 := core.CommonAnalysisResult{BeginTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}
+	c2 := core.CommonAnalysisResult{BeginTime: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix()}
+	merged := (&SZZAnalysis{}).MergeResults(r1, r2, &c1, &c2).(SZZResult)
+	assert.Equal(t, []string{"alice", "bob"}, merged.reversedPeopleDict)
+	// r2 starts one tick later and its bob is r1's bob
+	assert.Equal(t, map[string]*SZZCommit{
+		"aaa": {Author: 0, Tick: 0, BugLines: 1, FixedBy: []string{"bbb"}},
+		"bbb": {Author: 1, Tick: 1, Fix: true},
+		"ccc": {Author: 1, Tick: 1, Fix: true},
+	}, merged.Commits)
+	assert.Equal(t, map[string]SZZFileStats{"a": {Added: 7, BugLines: 1, Fixes: 2}}, merged.Files)
+	assert.Equal(t, map[int]SZZDevStats{
+		0: {Commits: 1, BugIntroducing: 1, BugLines: 1},
+		1: {Commits: 2, Fixes: 2},
+	}, merged.People)
+
+	r2.tickSize = time.Hour
+	assert.Error(t, (&SZZAnalysis{}).MergeResults(r1, r2, &c1, &c2).(error))
+}
@@ -2,6 +2,7 @@ package plumbing
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -33,6 +34,12 @@ func (b *CachedBlob) Reader() (io.ReadCloser, error) {
 
 // Cache reads the underlying blob object and sets CachedBlob.Data.
 func (b *CachedBlob) Cache() error {
+	return b.CacheContext(context.Background())
+}
+
+// CacheContext reads the underlying blob object and sets CachedBlob.Data.
+// The reading is aborted with the context's error once the context is done.
+func (b *CachedBlob) CacheContext(ctx context.Context) error {
 	reader, err := b.Blob.Reader()
 	if err != nil {
 		return err
@@ -40,7 +47,7 @@ func (b *CachedBlob) Cache() error {
 	defer reader.Close()
 	buf := new(bytes.Buffer)
 	buf.Grow(int(b.Size))
-	size, err := buf.ReadFrom(reader)
+	size, err := buf.ReadFrom(contextReader{ctx: ctx, reader: reader})
 	if err != nil {
 		return err
 	}
@@ -52,6 +59,19 @@ func (b *CachedBlob) Cache() error {
 	return nil
 }
 
+// contextReader fails the reads after the context is done.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
+
 // CountLines returns the number of lines in the blob or (0, ErrorBinary) if it is binary.
 func (b *CachedBlob) CountLines() (int, error) {
 	if len(b.Data) == 0 {
@@ -161,9 +181,16 @@ func (blobCache *BlobCache) Initialize(repository *git.Repository) error {
 func (blobCache *BlobCache) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
 	commit := deps[core.DependencyCommit].(*object.Commit)
 	changes := deps[DependencyTreeChanges].(object.Changes)
+	ctx, _ := deps[core.DependencyContext].(context.Context)
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	cache := map[plumbing.Hash]*CachedBlob{}
 	newCache := map[plumbing.Hash]*CachedBlob{}
 	for _, change := range changes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		action, err := change.Action()
 		if err != nil {
 			blobCache.l.Errorf("no action in %s\n", change.To.TreeEntry.Hash)
@@ -180,7 +207,7 @@ func (blobCache *BlobCache) Consume(deps map[string]interface{}) (map[string]int
 				blobCache.l.Errorf("file to %s %s: %v\n", change.To.Name, change.To.TreeEntry.Hash, err)
 			} else {
 				cb := &CachedBlob{Blob: *blob}
-				err = cb.Cache()
+				err = cb.CacheContext(ctx)
 				if err == nil {
 					cache[change.To.TreeEntry.Hash] = cb
 					newCache[change.To.TreeEntry.Hash] = cb
@@ -204,7 +231,7 @@ func (blobCache *BlobCache) Consume(deps map[string]interface{}) (map[string]int
 					}
 				} else {
 					cb := &CachedBlob{Blob: *blob}
-					err = cb.Cache()
+					err = cb.CacheContext(ctx)
 					if err == nil {
 						cache[change.From.TreeEntry.Hash] = cb
 					} else {
@@ -221,7 +248,7 @@ func (blobCache *BlobCache) Consume(deps map[string]interface{}) (map[string]int
 				blobCache.l.Errorf("file to %s: %v\n", change.To.Name, err)
 			} else {
 				cb := &CachedBlob{Blob: *blob}
-				err = cb.Cache()
+				err = cb.CacheContext(ctx)
 				if err == nil {
 					cache[change.To.TreeEntry.Hash] = cb
 					newCache[change.To.TreeEntry.Hash] = cb
@@ -238,7 +265,7 @@ func (blobCache *BlobCache) Consume(deps map[string]interface{}) (map[string]int
 					blobCache.l.Errorf("file from %s: %v\n", change.From.Name, err)
 				} else {
 					cb := &CachedBlob{Blob: *blob}
-					err = cb.Cache()
+					err = cb.CacheContext(ctx)
 					if err == nil {
 						cache[change.From.TreeEntry.Hash] = cb
 					} else {
@@ -267,11 +294,18 @@ func (blobCache *BlobCache) Fork(n int) []core.PipelineItem {
 			FailOnMissingSubmodules: blobCache.FailOnMissingSubmodules,
 			repository:              blobCache.repository,
 			cache:                   cache,
+			l:                       blobCache.l,
 		}
 	}
 	return caches
 }
 
+// ParallelForks returns true because the forks have separate caches.
+// It implements core.ParallelPipelineItem.
+func (blobCache *BlobCache) ParallelForks() bool {
+	return true
+}
+
 // FileGetter defines a function which loads the Git file by
 // the specified path. The state can be arbitrary though here it always
 // corresponds to the currently processed commit.
@@ -1,6 +1,7 @@
 package plumbing
 
 import (
+	"context"
 	"testing"
 
 	"github.com/dmytrogajewski/hercules/internal/app/core"
@@ -105,6 +106,36 @@ func TestBlobCacheConsumeModification(t *testing.T) {
 	assert.Equal(t, blobTo.Size, int64(9481))
 }
 
+func TestBlobCacheConsumeCancelled(t *testing.T) {
+	repository, commits := test.NewRepository(
+		test.Commit{Files: map[string]string{"a.txt": "a\n"}})
+	tree, err := commits[0].Tree()
+	assert.Nil(t, err)
+	changes, err := object.DiffTree(nil, tree)
+	assert.Nil(t, err)
+	cache := &BlobCache{}
+	cache.Initialize(repository)
+	ctx, cancel := context.WithCancel(context.Background())
+	deps := map[string]interface{}{
+		core.DependencyCommit:  commits[0],
+		core.DependencyContext: ctx,
+		DependencyTreeChanges:  changes,
+	}
+	result, err := cache.Consume(deps)
+	assert.Nil(t, err)
+	assert.Len(t, result[DependencyBlobCache], 1)
+	cancel()
+	result, err = cache.Consume(deps)
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, result)
+	blob, err := repository.BlobObject(changes[0].To.TreeEntry.Hash)
+	assert.Nil(t, err)
+	cb := &CachedBlob{Blob: *blob}
+	assert.Equal(t, context.Canceled, cb.CacheContext(ctx))
+	assert.Nil(t, cb.Cache())
+	assert.Equal(t, []byte("a\n"), cb.Data)
+}
+
 func TestBlobCacheConsumeInsertionDeletion(t *testing.T) {
 	commit, _ := test.Repository.CommitObject(plumbing.NewHash(
 		"2b1ed978194a94edeabbca6de7ff3b5771d4d665"))
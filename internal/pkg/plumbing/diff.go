@@ -173,6 +173,12 @@ func (diff *FileDiff) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(diff, n)
 }
 
+// ParallelForks returns true because FileDiff is stateless.
+// It implements core.ParallelPipelineItem.
+func (diff *FileDiff) ParallelForks() bool {
+	return true
+}
+
 func init() {
 	core.Registry.Register(&FileDiff{})
 }
@@ -0,0 +1,374 @@
+package plumbing
+
+import (
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+const (
+	// DependencyFileOrigins is the name of the dependency provided by RenameAnalysis:
+	// map[string]*FileOrigin from the new file names to where their lines came from.
+	DependencyFileOrigins = "file_origins"
+
+	// FileOriginCopy is the FileOrigin.Kind of a new file which is similar to an existing file.
+	FileOriginCopy = "copy"
+	// FileOriginSplit is the FileOrigin.Kind of a new file which contains a part of a file
+	// that was split into several.
+	FileOriginSplit = "split"
+	// FileOriginJoin is the FileOrigin.Kind of a file which contains several files joined
+	// together.
+	FileOriginJoin = "join"
+)
+
+// FileOrigin describes the files which a new or a modified file was derived from.
+type FileOrigin struct {
+	// Kind is FileOriginCopy, FileOriginSplit or FileOriginJoin.
+	Kind string
+	// Sources are the names of the files before the commit. If a modified file absorbed other
+	// files, its own previous name goes first.
+	Sources []string
+	// Lines maps every line of the file after the commit to the line in Sources it came from.
+	Lines []LineOrigin
+}
+
+// LineOrigin is the index of the file in FileOrigin.Sources and the line number in it.
+// Source is -1 for the new lines.
+type LineOrigin struct {
+	Source int
+	Line   int
+}
+
+// originFile is a text file version considered by the copy, split and join detection.
+type originFile struct {
+	name string
+	text string
+	// counts are the numbers of occurrences of the significant lines
+	counts map[string]int
+	// significant is the number of the significant lines
+	significant int
+	// previous is the own version of the file before the commit if it was modified
+	previous *originFile
+	// inserted indicates whether the file was added in the commit
+	inserted bool
+}
+
+// newOriginFile returns nil if the blob is binary or too small.
+func newOriginFile(name string, blob *CachedBlob) *originFile {
+	if blob == nil || blob.Size < RenameAnalysisMinimumSize {
+		return nil
+	}
+	if _, err := blob.CountLines(); err != nil {
+		return nil
+	}
+	file := &originFile{name: name, text: string(blob.Data), counts: map[string]int{}}
+	for _, line := range strings.Split(file.text, "\n") {
+		// braces and other short lines are everywhere and say nothing about the origin
+		if line = strings.TrimSpace(line); len(line) > 2 {
+			file.counts[line]++
+			file.significant++
+		}
+	}
+	if file.significant == 0 {
+		return nil
+	}
+	return file
+}
+
+// containment returns the percentage of the significant lines of part which exist in whole.
+func containment(part, whole *originFile) int {
+	common := 0
+	for line, count := range part.counts {
+		if other := whole.counts[line]; other < count {
+			common += other
+		} else {
+			common += count
+		}
+	}
+	return common * 100 / part.significant
+}
+
+// mapLines finds the origin of each line of dst in srcs. The earlier sources win.
+// The diffs become coarse instead of running past the deadline.
+func mapLines(dst *originFile, srcs []*originFile, deadline time.Time) []LineOrigin {
+	lines := make([]LineOrigin, strings.Count(dst.text, "\n"))
+	if dst.text != "" && dst.text[len(dst.text)-1] != '\n' {
+		lines = append(lines, LineOrigin{})
+	}
+	for i := range lines {
+		lines[i] = LineOrigin{Source: -1, Line: -1}
+	}
+	dmp := diffmatchpatch.New()
+	for index, src := range srcs {
+		// DiffTimeout = 0 means no timeout at all
+		dmp.DiffTimeout = time.Until(deadline)
+		if dmp.DiffTimeout <= 0 {
+			dmp.DiffTimeout = time.Nanosecond
+		}
+		srcRunes, dstRunes, _ := dmp.DiffLinesToRunes(src.text, dst.text)
+		srcLine, dstLine := 0, 0
+		for _, edit := range dmp.DiffMainRunes(srcRunes, dstRunes, false) {
+			// DiffMainRunes() keeps one rune per line
+			length := len([]rune(edit.Text))
+			switch edit.Type {
+			case diffmatchpatch.DiffEqual:
+				for i := 0; i < length; i++ {
+					if lines[dstLine+i].Source < 0 {
+						lines[dstLine+i] = LineOrigin{Source: index, Line: srcLine + i}
+					}
+				}
+				srcLine += length
+				dstLine += length
+			case diffmatchpatch.DiffDelete:
+				srcLine += length
+			case diffmatchpatch.DiffInsert:
+				dstLine += length
+			}
+		}
+	}
+	return lines
+}
+
+// newFileOrigin fills FileOrigin.Lines before the deadline.
+func newFileOrigin(kind string, dst *originFile, srcs []*originFile, deadline time.Time) *FileOrigin {
+	origin := &FileOrigin{Kind: kind, Sources: make([]string, len(srcs)),
+		Lines: mapLines(dst, srcs, deadline)}
+	for i, src := range srcs {
+		origin.Sources[i] = src.name
+	}
+	return origin
+}
+
+// detectOrigins finds the copies, the splits and the joins among the changes which remain
+// after the renames detection.
+func (ra *RenameAnalysis) detectOrigins(
+	changes object.Changes, cache map[plumbing.Hash]*CachedBlob, commit *object.Commit,
+	beginTime time.Time) (map[string]*FileOrigin, error) {
+
+	origins := map[string]*FileOrigin{}
+	if !ra.Copies && !ra.Splits {
+		return origins, nil
+	}
+	var sources, targets []*originFile
+	changed := map[string]bool{}
+	for _, change := range changes {
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		switch action {
+		case merkletrie.Insert:
+			if file := newOriginFile(change.To.Name, cache[change.To.TreeEntry.Hash]); file != nil {
+				file.inserted = true
+				targets = append(targets, file)
+			}
+		case merkletrie.Delete:
+			changed[change.From.Name] = true
+			if file := newOriginFile(change.From.Name, cache[change.From.TreeEntry.Hash]); file != nil {
+				sources = append(sources, file)
+			}
+		case merkletrie.Modify:
+			changed[change.From.Name] = true
+			previous := newOriginFile(change.From.Name, cache[change.From.TreeEntry.Hash])
+			file := newOriginFile(change.To.Name, cache[change.To.TreeEntry.Hash])
+			if previous == nil || file == nil {
+				continue
+			}
+			file.previous = previous
+			sources = append(sources, previous)
+			targets = append(targets, file)
+		}
+	}
+	if ra.Splits && len(sources)+len(targets) <= RenameAnalysisSetSizeLimit {
+		ra.detectSplitsAndJoins(sources, targets, origins, beginTime)
+	}
+	if ra.Copies && commit != nil && commit.NumParents() > 0 {
+		if err := ra.detectCopies(targets, changed, commit, origins, beginTime); err != nil {
+			return nil, err
+		}
+	}
+	return origins, nil
+}
+
+// detectSplitsAndJoins compares the lines of the new files with the lines of the old files.
+// A file is split if at least two of its parts, counting its own modified version, contain
+// mostly its lines; a file is joined if it contains most of the lines of at least two files,
+// counting its own previous version.
+func (ra *RenameAnalysis) detectSplitsAndJoins(
+	sources, targets []*originFile, origins map[string]*FileOrigin, beginTime time.Time) {
+
+	deadline := beginTime.Add(ra.Timeout)
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].name < sources[j].name
+	})
+	parts := make([][]*originFile, len(sources))
+	for _, target := range targets {
+		if time.Since(beginTime) >= ra.Timeout {
+			return
+		}
+		joined := []*originFile{}
+		if target.previous != nil {
+			joined = append(joined, target.previous)
+		}
+		for i, source := range sources {
+			if source == target.previous {
+				continue
+			}
+			if target.inserted && containment(target, source) >= ra.SimilarityThreshold {
+				parts[i] = append(parts[i], target)
+			}
+			if containment(source, target) >= ra.SimilarityThreshold {
+				joined = append(joined, source)
+			}
+		}
+		if len(joined) >= 2 {
+			origins[target.name] = newFileOrigin(FileOriginJoin, target, joined, deadline)
+		}
+	}
+	survivors := map[string]bool{}
+	for _, target := range targets {
+		if target.previous != nil {
+			survivors[target.previous.name] = true
+		}
+	}
+	for i, source := range sources {
+		required := 2
+		if survivors[source.name] {
+			required = 1
+		}
+		if len(parts[i]) < required {
+			continue
+		}
+		for _, part := range parts[i] {
+			if origins[part.name] == nil {
+				origins[part.name] = newFileOrigin(FileOriginSplit, part, []*originFile{source}, deadline)
+			}
+		}
+	}
+}
+
+// copySource is a file in the parent commit which may be the source of a copy.
+type copySource struct {
+	name string
+	hash plumbing.Hash
+	size int64
+}
+
+// detectCopies searches the files which were not changed in the commit for the ones similar
+// to the new files that do not have an origin yet.
+func (ra *RenameAnalysis) detectCopies(
+	targets []*originFile, changed map[string]bool, commit *object.Commit,
+	origins map[string]*FileOrigin, beginTime time.Time) error {
+
+	var added []*originFile
+	for _, target := range targets {
+		if target.inserted && origins[target.name] == nil {
+			added = append(added, target)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	parent, err := commit.Parent(0)
+	if err != nil {
+		return err
+	}
+	tree, err := parent.Tree()
+	if err != nil {
+		return err
+	}
+	var candidates []copySource
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		if time.Since(beginTime) >= ra.Timeout {
+			return nil
+		}
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if changed[name] || (entry.Mode != filemode.Regular && entry.Mode != filemode.Executable) {
+			continue
+		}
+		size, err := ra.repository.Storer.EncodedObjectSize(entry.Hash)
+		if err == plumbing.ErrObjectNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if size < RenameAnalysisMinimumSize {
+			continue
+		}
+		candidates = append(candidates, copySource{name: name, hash: entry.Hash, size: size})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].size < candidates[j].size
+	})
+	maxCandidates := RenameAnalysisMaxCandidates
+	if len(candidates) > RenameAnalysisSetSizeLimit {
+		maxCandidates = 1
+	}
+	deadline := beginTime.Add(ra.Timeout)
+	loaded := map[plumbing.Hash]*CachedBlob{}
+	for _, target := range added {
+		if time.Since(beginTime) >= ra.Timeout {
+			return nil
+		}
+		size := int64(len(target.text))
+		first := sort.Search(len(candidates), func(i int) bool {
+			return candidates[i].size >= size || ra.sizesAreClose(size, candidates[i].size)
+		})
+		var close []int
+		for i := first; i < len(candidates) && ra.sizesAreClose(size, candidates[i].size); i++ {
+			close = append(close, i)
+		}
+		sortRenameCandidates(close, filepath.Base(target.name), func(i int) string {
+			return candidates[i].name
+		})
+		if len(close) > maxCandidates {
+			close = close[:maxCandidates]
+		}
+		targetBlob := &CachedBlob{Blob: object.Blob{Size: size}, Data: []byte(target.text)}
+		for _, i := range close {
+			candidate := candidates[i]
+			blob := loaded[candidate.hash]
+			if blob == nil {
+				gitBlob, err := ra.repository.BlobObject(candidate.hash)
+				if err != nil {
+					return err
+				}
+				blob = &CachedBlob{Blob: *gitBlob}
+				if err = blob.Cache(); err != nil {
+					return err
+				}
+				loaded[candidate.hash] = blob
+			}
+			source := newOriginFile(candidate.name, blob)
+			if source == nil {
+				continue
+			}
+			similar, err := ra.blobsAreClose(blob, targetBlob)
+			if err != nil {
+				return err
+			}
+			if similar {
+				origins[target.name] = newFileOrigin(FileOriginCopy, target, []*originFile{source}, deadline)
+				break
+			}
+		}
+	}
+	return nil
+}
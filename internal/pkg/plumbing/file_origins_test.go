@@ -0,0 +1,179 @@
+package plumbing
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+func originsLines(prefix string, n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		// the lines must differ byte-wise for blobsAreClose()
+		lines[i] = fmt.Sprintf("const %s%d = \"%x\"", prefix, i, sha1.Sum([]byte{byte(i)}))
+		if prefix != "alpha" {
+			lines[i] = fmt.Sprintf("var %s%d = \"%s\"", prefix, i, strings.Repeat(string(rune('k'+i)), 40))
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// consumeOrigins runs TreeDiff, BlobCache and RenameAnalysis over the commits and returns
+// the origins in the last commit.
+func consumeOrigins(t *testing.T, copies, splits bool, specs ...test.Commit) map[string]*FileOrigin {
+	repository, commits := test.NewRepository(specs...)
+	td := &TreeDiff{}
+	assert.NoError(t, td.Configure(nil))
+	assert.NoError(t, td.Initialize(repository))
+	cache := &BlobCache{}
+	assert.NoError(t, cache.Initialize(repository))
+	ra := &RenameAnalysis{}
+	assert.NoError(t, ra.Configure(map[string]interface{}{
+		ConfigRenameAnalysisSimilarityThreshold: RenameAnalysisDefaultThreshold,
+		ConfigRenameAnalysisCopies:              copies,
+		ConfigRenameAnalysisSplits:              splits,
+	}))
+	assert.NoError(t, ra.Initialize(repository))
+	var origins map[string]*FileOrigin
+	for _, commit := range commits {
+		deps := map[string]interface{}{core.DependencyCommit: commit}
+		result, err := td.Consume(deps)
+		assert.NoError(t, err)
+		deps[DependencyTreeChanges] = result[DependencyTreeChanges]
+		result, err = cache.Consume(deps)
+		assert.NoError(t, err)
+		deps[DependencyBlobCache] = result[DependencyBlobCache]
+		result, err = ra.Consume(deps)
+		assert.NoError(t, err)
+		origins = result[DependencyFileOrigins].(map[string]*FileOrigin)
+	}
+	return origins
+}
+
+func originsRange(source, first, n int) []LineOrigin {
+	lines := make([]LineOrigin, n)
+	for i := range lines {
+		lines[i] = LineOrigin{Source: source, Line: first + i}
+	}
+	return lines
+}
+
+func TestRenameAnalysisSplit(t *testing.T) {
+	a, b := originsLines("alpha", 6), originsLines("beta", 6)
+	specs := []test.Commit{
+		{Files: map[string]string{"big.go": a + b}},
+		{Files: map[string]string{"big.go": "", "a.go": a, "b.go": b}},
+	}
+	assert.Empty(t, consumeOrigins(t, true, false, specs...))
+	origins := consumeOrigins(t, false, true, specs...)
+	assert.Equal(t, map[string]*FileOrigin{
+		"a.go": {Kind: FileOriginSplit, Sources: []string{"big.go"}, Lines: originsRange(0, 0, 6)},
+		"b.go": {Kind: FileOriginSplit, Sources: []string{"big.go"}, Lines: originsRange(0, 6, 6)},
+	}, origins)
+
+	// the remaining part stays in the modified file
+	origins = consumeOrigins(t, false, true,
+		test.Commit{Files: map[string]string{"big.go": a + b}},
+		test.Commit{Files: map[string]string{"big.go": a, "b.go": b}})
+	assert.Equal(t, map[string]*FileOrigin{
+		"b.go": {Kind: FileOriginSplit, Sources: []string{"big.go"}, Lines: originsRange(0, 6, 6)},
+	}, origins)
+}
+
+func TestRenameAnalysisJoin(t *testing.T) {
+	a, b := originsLines("alpha", 6), originsLines("beta", 6)
+	origins := consumeOrigins(t, false, true,
+		test.Commit{Files: map[string]string{"a.go": a, "b.go": b}},
+		test.Commit{Files: map[string]string{"a.go": "", "b.go": "", "all.go": a + b}})
+	assert.Equal(t, map[string]*FileOrigin{
+		"all.go": {Kind: FileOriginJoin, Sources: []string{"a.go", "b.go"},
+			Lines: append(originsRange(0, 0, 6), originsRange(1, 0, 6)...)},
+	}, origins)
+
+	origins = consumeOrigins(t, false, true,
+		test.Commit{Files: map[string]string{"a.go": a, "b.go": b}},
+		test.Commit{Files: map[string]string{"a.go": b + a + "// joined\n", "b.go": ""}})
+	assert.Equal(t, map[string]*FileOrigin{
+		"a.go": {Kind: FileOriginJoin, Sources: []string{"a.go", "b.go"},
+			Lines: append(append(originsRange(1, 0, 6), originsRange(0, 0, 6)...),
+				LineOrigin{Source: -1, Line: -1})},
+	}, origins)
+}
+
+func TestRenameAnalysisCopy(t *testing.T) {
+	a, b := originsLines("alpha", 6), originsLines("beta", 6)
+	specs := []test.Commit{
+		{Files: map[string]string{"a.go": a, "b.go": b}},
+		{Files: map[string]string{"c.go": "// copied\n" + a}},
+	}
+	assert.Empty(t, consumeOrigins(t, false, false, specs...))
+	assert.Empty(t, consumeOrigins(t, false, true, specs...))
+	origins := consumeOrigins(t, true, false, specs...)
+	assert.Equal(t, map[string]*FileOrigin{
+		"c.go": {Kind: FileOriginCopy, Sources: []string{"a.go"},
+			Lines: append([]LineOrigin{{Source: -1, Line: -1}}, originsRange(0, 0, 6)...)},
+	}, origins)
+
+	// the changed files are not the sources of copies
+	origins = consumeOrigins(t, true, false,
+		test.Commit{Files: map[string]string{"a.go": a, "b.go": b}},
+		test.Commit{Files: map[string]string{"a.go": a + "// changed\n", "c.go": "// copied\n" + a}})
+	assert.Empty(t, origins)
+}
+
+func TestRenameAnalysisCopyTimeout(t *testing.T) {
+	a := originsLines("alpha", 6)
+	repository, commits := test.NewRepository(
+		test.Commit{Files: map[string]string{"a.go": a}},
+		test.Commit{Files: map[string]string{"c.go": "// copied\n" + a}})
+	ra := &RenameAnalysis{}
+	assert.NoError(t, ra.Configure(map[string]interface{}{
+		ConfigRenameAnalysisSimilarityThreshold: RenameAnalysisDefaultThreshold,
+		ConfigRenameAnalysisCopies:              true,
+	}))
+	assert.NoError(t, ra.Initialize(repository))
+	text := "// copied\n" + a
+	target := newOriginFile("c.go", &CachedBlob{
+		Blob: object.Blob{Size: int64(len(text))}, Data: []byte(text)})
+	target.inserted = true
+	origins := map[string]*FileOrigin{}
+	assert.NoError(t, ra.detectCopies(
+		[]*originFile{target}, map[string]bool{}, commits[1], origins, time.Now()))
+	assert.Contains(t, origins, "c.go")
+	// the parent's tree is not walked when the budget of the commit is spent
+	origins = map[string]*FileOrigin{}
+	assert.NoError(t, ra.detectCopies(
+		[]*originFile{target}, map[string]bool{}, commits[1], origins, time.Now().Add(-ra.Timeout)))
+	assert.Empty(t, origins)
+}
+
+func TestMapLinesDeadline(t *testing.T) {
+	a, b := originsLines("alpha", 6), originsLines("beta", 6)
+	file := func(name, text string) *originFile {
+		return newOriginFile(name, &CachedBlob{Blob: object.Blob{Size: int64(len(text))}, Data: []byte(text)})
+	}
+	src, dst := file("src.go", a+b), file("dst.go", b+"// moved\n"+a)
+	mapped := func(lines []LineOrigin) int {
+		n := 0
+		for _, line := range lines {
+			if line.Source >= 0 {
+				n++
+			}
+		}
+		return n
+	}
+	lines := mapLines(dst, []*originFile{src}, time.Now().Add(time.Minute))
+	assert.Len(t, lines, 13)
+	assert.Equal(t, 6, mapped(lines))
+	// the diff is coarse when the budget of the commit is spent
+	lines = mapLines(dst, []*originFile{src}, time.Now())
+	assert.Len(t, lines, 13)
+	assert.Equal(t, 0, mapped(lines))
+}
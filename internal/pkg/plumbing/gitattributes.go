@@ -0,0 +1,151 @@
+package plumbing
+
+import (
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+const (
+	// gitattributesFile is the name of the files with the path attributes.
+	gitattributesFile = ".gitattributes"
+	// binaryMacro is git's builtin macro attribute which disables the diffs.
+	binaryMacro = "[attr]binary -diff -merge -text"
+)
+
+// Attributes are the path attributes from the .gitattributes files which make TreeDiff skip
+// the files: linguist-generated, linguist-vendored and -diff (including "binary").
+type Attributes struct {
+	// stack is ordered by increasing priority
+	stack  []gitattributes.MatchAttribute
+	macros map[string][]gitattributes.Attribute
+}
+
+// ReadAttributes loads all the .gitattributes files in the commit. The deeper files take
+// precedence. It returns nil if there are no .gitattributes.
+func ReadAttributes(commit *object.Commit) (*Attributes, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var files []*object.File
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// only the matching entries are decoded, the rest of the blobs are not touched
+		if entry.Name != gitattributesFile || !entry.Mode.IsFile() {
+			continue
+		}
+		file, err := tree.TreeEntryFile(&entry)
+		if err != nil {
+			return nil, err
+		}
+		file.Name = name
+		files = append(files, file)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	sort.Slice(files, func(i, j int) bool {
+		depthI, depthJ := strings.Count(files[i].Name, "/"), strings.Count(files[j].Name, "/")
+		if depthI != depthJ {
+			return depthI < depthJ
+		}
+		return files[i].Name < files[j].Name
+	})
+	macro, err := gitattributes.ParseAttributesLine(binaryMacro, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	result := &Attributes{macros: map[string][]gitattributes.Attribute{macro.Name: macro.Attributes}}
+	for _, file := range files {
+		var domain []string
+		if dir := path.Dir(file.Name); dir != "." {
+			domain = strings.Split(dir, "/")
+		}
+		reader, err := file.Reader()
+		if err != nil {
+			return nil, err
+		}
+		// only the root .gitattributes may define the macros
+		attributes, err := gitattributes.ReadAttributes(reader, domain, domain == nil)
+		reader.Close()
+		if err != nil {
+			// git ignores the broken lines, we ignore the broken files
+			continue
+		}
+		for _, attribute := range attributes {
+			if attribute.Pattern == nil {
+				result.macros[attribute.Name] = attribute.Attributes
+			} else {
+				result.stack = append(result.stack, attribute)
+			}
+		}
+	}
+	return result, nil
+}
+
+// match returns the attributes of the path. The last matching line wins, like in git.
+func (attributes *Attributes) match(name string) map[string]gitattributes.Attribute {
+	results := map[string]gitattributes.Attribute{}
+	path := strings.Split(name, "/")
+	set := func(attr gitattributes.Attribute) {
+		if _, exists := results[attr.Name()]; !exists {
+			results[attr.Name()] = attr
+		}
+	}
+	for i := len(attributes.stack) - 1; i >= 0; i-- {
+		if !attributes.stack[i].Pattern.Match(path) {
+			continue
+		}
+		lineAttributes := attributes.stack[i].Attributes
+		// the later attributes on the same line win, too
+		for j := len(lineAttributes) - 1; j >= 0; j-- {
+			attr := lineAttributes[j]
+			set(attr)
+			if attr.IsSet() {
+				for _, expanded := range attributes.macros[attr.Name()] {
+					set(expanded)
+				}
+			}
+		}
+	}
+	return results
+}
+
+// Skip returns true if the file is generated, vendored or has the diff disabled.
+// notVendored is true if linguist-vendored is explicitly unset or false, so that the file
+// must be kept even if it looks vendored.
+func (attributes *Attributes) Skip(name string) (skip bool, notVendored bool) {
+	if attributes == nil {
+		return false, false
+	}
+	results := attributes.match(name)
+	for _, key := range [...]string{"linguist-generated", "linguist-vendored"} {
+		attr := results[key]
+		if attr == nil {
+			continue
+		}
+		if attr.IsSet() || (attr.IsValueSet() && attr.Value() == "true") {
+			return true, false
+		}
+		if key == "linguist-vendored" && (attr.IsUnset() || attr.IsValueSet()) {
+			notVendored = true
+		}
+	}
+	if attr := results["diff"]; attr != nil && attr.IsUnset() {
+		return true, notVendored
+	}
+	return false, notVendored
+}
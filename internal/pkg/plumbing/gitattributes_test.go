@@ -0,0 +1,45 @@
+package plumbing
+
+import (
+	"testing"
+
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadAttributes(t *testing.T) {
+	_, commits := test.NewRepository(test.Commit{Files: map[string]string{
+		".gitattributes": "[attr]generated linguist-generated\n" +
+			"*.pb.go generated\n*.bin binary\n*.dat -diff\n" +
+			"vendor/** linguist-vendored\nvendor/own/** linguist-vendored=false\n",
+		"nested/.gitattributes": "*.dat diff\n",
+		// only the root file may define the macros
+		"other/.gitattributes": "[attr]ignored linguist-generated\n*.txt ignored\n",
+		"main.go":              "package main\n",
+	}})
+	attributes, err := ReadAttributes(commits[0])
+	require.NoError(t, err)
+	require.NotNil(t, attributes)
+	for name, expected := range map[string][2]bool{
+		"main.go":           {false, false},
+		"api/api.pb.go":     {true, false},
+		"image.bin":         {true, false},
+		"data/x.dat":        {true, false},
+		"nested/x.dat":      {false, false},
+		"other/x.txt":       {false, false},
+		"vendor/lib/lib.go": {true, false},
+		"vendor/own/own.go": {false, true},
+	} {
+		skip, notVendored := attributes.Skip(name)
+		assert.Equal(t, expected, [2]bool{skip, notVendored}, name)
+	}
+
+	_, commits = test.NewRepository(test.Commit{Files: map[string]string{"main.go": "package main\n"}})
+	attributes, err = ReadAttributes(commits[0])
+	assert.NoError(t, err)
+	assert.Nil(t, attributes)
+	skip, notVendored := attributes.Skip("main.go")
+	assert.False(t, skip)
+	assert.False(t, notVendored)
+}
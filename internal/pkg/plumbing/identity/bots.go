@@ -0,0 +1,252 @@
+package identity
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+// The values of Detector.Bots.
+const (
+	// BotsKeep treats the bots as the regular developers.
+	BotsKeep = "keep"
+	// BotsExclude maps the bots to AuthorMissing and sets DependencyAuthorExcluded, so that
+	// the leaves skip their commits.
+	BotsExclude = "exclude"
+	// BotsFold merges all the bots into the single BotsIdentity developer.
+	BotsFold = "fold"
+
+	// BotsIdentity is the name of the developer which replaces the bots in BotsFold mode.
+	BotsIdentity = "bots"
+	// RuleBot merges a bot into BotsIdentity, see MergeDecision.Rule.
+	RuleBot = "bot"
+
+	// botMinCommits is the minimum number of commits to apply the behavioral heuristics.
+	botMinCommits = 10
+	// botUniformity is the minimum share of the commits which must look the same
+	// to classify the author as a bot by behavior.
+	botUniformity = 0.8
+)
+
+// knownBotRE matches the names and the emails of the popular automation accounts.
+var knownBotRE = regexp.MustCompile(
+	`(?i)(\[bot\]|^(dependabot|renovate|github-actions|greenkeeper|snyk-bot|pre-commit-ci|` +
+		`mergify|codecov|imgbot|allcontributors|semantic-release-bot)\b|[-_.]bot(@|$))`)
+
+// botStats is the behavior of a developer collected by classifyBots().
+type botStats struct {
+	messages []string
+	minutes  map[string]int
+}
+
+// messageTemplate replaces the words which do not repeat in at least half of the messages
+// with "*". It returns an empty string if no word repeats.
+func messageTemplate(words []string, frequent map[string]bool) string {
+	template := make([]string, len(words))
+	matched := false
+	for i, word := range words {
+		if frequent[word] {
+			template[i] = word
+			matched = true
+		} else {
+			template[i] = "*"
+		}
+	}
+	if !matched {
+		return ""
+	}
+	return strings.Join(template, " ")
+}
+
+// templated returns true if most of the messages follow the same template,
+// e.g. "Bump * from * to *".
+func (stats *botStats) templated() bool {
+	tokenized := make([][]string, len(stats.messages))
+	frequency := map[string]int{}
+	for i, message := range stats.messages {
+		if newline := strings.IndexByte(message, '\n'); newline >= 0 {
+			message = message[:newline]
+		}
+		tokenized[i] = strings.Fields(strings.ToLower(message))
+		seen := map[string]bool{}
+		for _, word := range tokenized[i] {
+			if !seen[word] {
+				seen[word] = true
+				frequency[word]++
+			}
+		}
+	}
+	frequent := map[string]bool{}
+	for word, count := range frequency {
+		if count*2 >= len(stats.messages) {
+			frequent[word] = true
+		}
+	}
+	templates := map[string]int{}
+	for _, words := range tokenized {
+		if template := messageTemplate(words, frequent); template != "" {
+			templates[template]++
+		}
+	}
+	return maxCount(templates) >= botUniformity*float64(len(stats.messages))
+}
+
+// scheduled returns true if most of the commits happened at the same time of the day,
+// like the cron jobs do.
+func (stats *botStats) scheduled() bool {
+	return maxCount(stats.minutes) >= botUniformity*float64(len(stats.messages))
+}
+
+func maxCount(counts map[string]int) float64 {
+	result := 0
+	for _, count := range counts {
+		if count > result {
+			result = count
+		}
+	}
+	return float64(result)
+}
+
+// classifyBots returns the bot developer indices mapped to the reasons. The names and
+// the emails are matched against the known automation accounts and BotPattern; if BotHeuristics
+// is enabled, the developers with uniform commit messages or commit times are bots, too.
+func (detector *Detector) classifyBots(commits []*object.Commit) map[int]string {
+	bots := map[int]string{}
+	stats := map[int]*botStats{}
+	for _, commit := range commits {
+		id, exists := detector.lookup(commit.Author)
+		if !exists || id == AuthorMissing {
+			continue
+		}
+		if _, classified := bots[id]; !classified {
+			if reason := detector.matchBot(id); reason != "" {
+				bots[id] = reason
+			}
+		}
+		if !detector.BotHeuristics || commit.NumParents() > 1 {
+			continue
+		}
+		devStats := stats[id]
+		if devStats == nil {
+			devStats = &botStats{minutes: map[string]int{}}
+			stats[id] = devStats
+		}
+		devStats.messages = append(devStats.messages, commit.Message)
+		devStats.minutes[commit.Author.When.UTC().Format("15:04")]++
+	}
+	for id, devStats := range stats {
+		if _, classified := bots[id]; classified || len(devStats.messages) < botMinCommits {
+			continue
+		}
+		if devStats.templated() {
+			bots[id] = "message template"
+		} else if devStats.scheduled() {
+			bots[id] = "commit cadence"
+		}
+	}
+	return bots
+}
+
+// matchBot returns the matched signature if the developer is a bot by name or email.
+func (detector *Detector) matchBot(id int) string {
+	if id >= len(detector.ReversedPeopleDict) {
+		return ""
+	}
+	signatures := strings.Split(detector.ReversedPeopleDict[id], "|")
+	if detector.ExactSignatures {
+		signatures = strings.SplitN(strings.TrimSuffix(signatures[0], ">"), " <", 2)
+	}
+	for _, signature := range signatures {
+		if signature == "" {
+			continue
+		}
+		if knownBotRE.MatchString(signature) ||
+			(detector.BotPattern != nil && detector.BotPattern.MatchString(signature)) {
+			return signature
+		}
+	}
+	return ""
+}
+
+// applyBots classifies the bots and excludes or folds them according to Bots.
+func (detector *Detector) applyBots(commits []*object.Commit) {
+	if detector.Bots == "" || detector.Bots == BotsKeep {
+		return
+	}
+	detector.foldBots(detector.classifyBots(commits))
+}
+
+// foldBots excludes or folds the specified bots according to Bots. The bots join
+// the existing BotsIdentity developer if there is one.
+func (detector *Detector) foldBots(bots map[int]string) {
+	if detector.Bots == "" || detector.Bots == BotsKeep || len(bots) == 0 {
+		return
+	}
+	botIDs := make([]int, 0, len(bots))
+	for id := range bots {
+		botIDs = append(botIDs, id)
+	}
+	sort.Ints(botIDs)
+	folded := -1
+	for id, person := range detector.ReversedPeopleDict {
+		if _, isBot := bots[id]; person == BotsIdentity && !isBot {
+			folded = id
+			break
+		}
+	}
+	remap := make([]int, len(detector.ReversedPeopleDict))
+	reversedPeopleDict := make([]string, 0, len(detector.ReversedPeopleDict))
+	for id, person := range detector.ReversedPeopleDict {
+		if _, isBot := bots[id]; !isBot {
+			remap[id] = len(reversedPeopleDict)
+			reversedPeopleDict = append(reversedPeopleDict, person)
+			continue
+		}
+		if detector.Bots == BotsExclude {
+			remap[id] = AuthorMissing
+			continue
+		}
+		if folded >= 0 {
+			// the bots identity precedes the new bots
+			remap[id] = remap[folded]
+		} else if id == botIDs[0] {
+			remap[id] = len(reversedPeopleDict)
+			reversedPeopleDict = append(reversedPeopleDict, BotsIdentity)
+		} else {
+			remap[id] = remap[botIDs[0]]
+		}
+	}
+	for key, id := range detector.PeopleDict {
+		if id < len(remap) {
+			detector.PeopleDict[key] = remap[id]
+		}
+	}
+	decisions := detector.MergeDecisions[:0]
+	for _, decision := range detector.MergeDecisions {
+		if decision.Developer = remap[decision.Developer]; decision.Developer != AuthorMissing {
+			decisions = append(decisions, decision)
+		}
+	}
+	detector.MergeDecisions = decisions
+	if detector.Bots == BotsFold {
+		for _, id := range botIDs {
+			detector.MergeDecisions = append(detector.MergeDecisions, MergeDecision{
+				Developer: remap[id], Signature: detector.ReversedPeopleDict[id],
+				Rule: RuleBot, Via: bots[id]})
+		}
+	}
+	detector.ReversedPeopleDict = reversedPeopleDict
+}
+
+// validateBots checks the value of Bots.
+func validateBots(mode string) error {
+	switch mode {
+	case "", BotsKeep, BotsExclude, BotsFold:
+		return nil
+	}
+	return fmt.Errorf("invalid bots mode %q, must be one of %s, %s, %s",
+		mode, BotsKeep, BotsExclude, BotsFold)
+}
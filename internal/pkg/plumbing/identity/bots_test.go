@@ -0,0 +1,166 @@
+package identity
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+)
+
+func botCommits() []*object.Commit {
+	packages := [...]string{"lodash", "axios", "react", "express", "moment", "jest"}
+	specs := []test.Commit{
+		{Author: "Alice", Email: "alice@corp.com", Message: "Add the parser"},
+		{Author: "dependabot[bot]", Email: "49699333+dependabot[bot]@users.noreply.github.com",
+			Message: "Bump lodash from 4.17.1 to 4.17.2"},
+		{Author: "Bob", Email: "bob@corp.com", Message: "Fix the parser"},
+		{Author: "Release", Email: "deploy-bot@corp.com", Message: "Release 1.0"},
+		{Author: "Nightly", Email: "nightly@corp.com", Message: "Update the translations"},
+	}
+	base := time.Date(2020, 1, 1, 3, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		specs = append(specs,
+			test.Commit{Author: "Updater", Email: "updater@corp.com", Message: fmt.Sprintf(
+				"Bump %s from 1.%d.0 to 1.%d.1", packages[i%len(packages)], i, i)},
+			test.Commit{Author: "Nightly", Email: "nightly@corp.com",
+				Message: [...]string{"Refresh cache", "Rotate keys", "Rebuild index", "Prune artifacts"}[i%4],
+				When:    base.AddDate(0, 0, i)},
+			test.Commit{Author: "Alice", Email: "alice@corp.com", Message: [...]string{
+				"Implement the lexer", "Support the comments", "Speed up the parser",
+				"Document the grammar"}[i%4], When: base.Add(time.Duration(i*1531) * time.Minute)},
+			test.Commit{Author: "Bob", Email: "bob@corp.com", Message: [...]string{
+				"Fix a typo", "Refactor the reader", "Add tests for the writer"}[i%3],
+				When: base.Add(time.Duration(i*1777) * time.Minute)})
+	}
+	_, commits := test.NewRepository(specs...)
+	return commits
+}
+
+func TestClassifyBotsPatterns(t *testing.T) {
+	detector := &Detector{}
+	detector.GeneratePeopleDict(botCommits())
+	bots := detector.classifyBots(botCommits())
+	dependabot, _ := detector.lookup(object.Signature{Name: "dependabot[bot]"})
+	deploy, _ := detector.lookup(object.Signature{Email: "deploy-bot@corp.com"})
+	assert.Equal(t, map[int]string{
+		dependabot: "dependabot[bot]",
+		deploy:     "deploy-bot@corp.com",
+	}, bots)
+
+	detector.BotPattern = regexp.MustCompile("^nightly")
+	bots = detector.classifyBots(botCommits())
+	assert.Len(t, bots, 3)
+	nightly, _ := detector.lookup(object.Signature{Name: "Nightly"})
+	assert.Equal(t, "nightly", bots[nightly])
+}
+
+func TestClassifyBotsHeuristics(t *testing.T) {
+	detector := &Detector{BotHeuristics: true}
+	detector.GeneratePeopleDict(botCommits())
+	bots := detector.classifyBots(botCommits())
+	assert.Len(t, bots, 4)
+	updater, _ := detector.lookup(object.Signature{Name: "Updater"})
+	nightly, _ := detector.lookup(object.Signature{Name: "Nightly"})
+	alice, _ := detector.lookup(object.Signature{Name: "Alice"})
+	bob, _ := detector.lookup(object.Signature{Name: "Bob"})
+	assert.Equal(t, "message template", bots[updater])
+	assert.Equal(t, "commit cadence", bots[nightly])
+	assert.NotContains(t, bots, alice)
+	assert.NotContains(t, bots, bob)
+}
+
+func TestBotsExclude(t *testing.T) {
+	commits := botCommits()
+	detector := &Detector{}
+	facts := map[string]interface{}{
+		core.ConfigPipelineCommits:          commits,
+		ConfigIdentityDetectorBots:          BotsExclude,
+		ConfigIdentityDetectorBotHeuristics: true,
+	}
+	assert.NoError(t, detector.Configure(facts))
+	assert.Equal(t, []string{"alice|alice@corp.com", "bob|bob@corp.com"}, detector.ReversedPeopleDict)
+	assert.Equal(t, 2, facts[FactIdentityDetectorPeopleCount])
+	for _, commit := range commits {
+		result, err := detector.Consume(map[string]interface{}{core.DependencyCommit: commit})
+		assert.NoError(t, err)
+		human := commit.Author.Name == "Alice" || commit.Author.Name == "Bob"
+		assert.Equal(t, !human, result[DependencyAuthorExcluded], commit.Author.Name)
+		if !human {
+			assert.Equal(t, AuthorMissing, result[DependencyAuthor])
+		}
+	}
+	result, err := detector.Consume(map[string]interface{}{core.DependencyCommit: &object.Commit{
+		Author: object.Signature{Name: "Stranger", Email: "stranger@corp.com"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, AuthorMissing, result[DependencyAuthor])
+	assert.Equal(t, false, result[DependencyAuthorExcluded])
+}
+
+func TestBotsFold(t *testing.T) {
+	commits := botCommits()
+	detector := &Detector{}
+	assert.NoError(t, detector.Configure(map[string]interface{}{
+		core.ConfigPipelineCommits: commits,
+		ConfigIdentityDetectorBots: BotsFold,
+	}))
+	assert.Equal(t, []string{
+		"alice|alice@corp.com", BotsIdentity, "bob|bob@corp.com",
+		"nightly|nightly@corp.com", "updater|updater@corp.com",
+	}, detector.ReversedPeopleDict)
+	for _, commit := range commits[1:4:4] {
+		result, err := detector.Consume(map[string]interface{}{core.DependencyCommit: commit})
+		assert.NoError(t, err)
+		assert.Equal(t, false, result[DependencyAuthorExcluded])
+		if commit.Author.Name == "Bob" {
+			assert.Equal(t, 2, result[DependencyAuthor])
+		} else {
+			assert.Equal(t, 1, result[DependencyAuthor])
+		}
+	}
+	var folded []MergeDecision
+	for _, decision := range detector.MergeDecisions {
+		if decision.Rule == RuleBot {
+			folded = append(folded, decision)
+		}
+	}
+	assert.Equal(t, []MergeDecision{
+		{Developer: 1, Signature: "dependabot[bot]|49699333+dependabot[bot]@users.noreply.github.com",
+			Rule: RuleBot, Via: "dependabot[bot]"},
+		{Developer: 1, Signature: "release|deploy-bot@corp.com", Rule: RuleBot, Via: "deploy-bot@corp.com"},
+	}, folded)
+}
+
+func TestBotsConfigureErrors(t *testing.T) {
+	detector := &Detector{}
+	assert.Error(t, detector.Configure(map[string]interface{}{ConfigIdentityDetectorBots: "drop"}))
+	assert.Error(t, detector.Configure(map[string]interface{}{ConfigIdentityDetectorBotPattern: "("}))
+}
+
+func TestBotsResume(t *testing.T) {
+	commits := botCommits()
+	for _, bots := range []string{BotsExclude, BotsFold} {
+		// Nightly makes the first commit at index 4 and becomes a bot by the heuristics later,
+		// which cannot change the developers from before the checkpoint
+		for split := 1; split <= 4; split++ {
+			detector := testIdentityDetectorResume(t, commits, split, map[string]interface{}{
+				ConfigIdentityDetectorBots:          bots,
+				ConfigIdentityDetectorBotHeuristics: true,
+			})
+			if split > 1 {
+				continue
+			}
+			// dependabot appears after the checkpoint
+			result, err := detector.Consume(map[string]interface{}{core.DependencyCommit: commits[1]})
+			assert.NoError(t, err)
+			assert.Equal(t, bots == BotsExclude, result[DependencyAuthorExcluded], bots)
+			if bots == BotsFold {
+				assert.Equal(t, BotsIdentity, detector.ReversedPeopleDict[result[DependencyAuthor].(int)])
+			}
+		}
+	}
+}
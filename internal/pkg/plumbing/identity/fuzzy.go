@@ -0,0 +1,289 @@
+package identity
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/dmytrogajewski/hercules/pkg/levenshtein"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// The rules which merge the identities, see MergeDecision.Rule.
+const (
+	// RuleEmail merges the signatures with the same email.
+	RuleEmail = "email"
+	// RuleName merges the signatures with the same name.
+	RuleName = "name"
+	// RuleMailmap merges the signatures mapped to the same person by .mailmap.
+	RuleMailmap = "mailmap"
+	// RuleNormalizedName merges the names which are equal without the diacritics, the
+	// punctuation and the order of the words, e.g. "Doe, John" and "John Doe".
+	RuleNormalizedName = "normalized-name"
+	// RuleGitHubNoreply merges the GitHub noreply emails with the emails and the other noreply
+	// emails which have the same login, e.g. 12345+jdoe@users.noreply.github.com and jdoe@corp.com.
+	RuleGitHubNoreply = "github-noreply"
+	// RuleEmailLocalPart merges the emails with the same local part in different domains.
+	RuleEmailLocalPart = "email-local-part"
+	// RuleSimilarName merges the normalized names within a small Levenshtein distance.
+	RuleSimilarName = "similar-name"
+)
+
+// MergeDecision explains why a signature was attributed to a developer.
+type MergeDecision struct {
+	// Developer is the index in ReversedPeopleDict.
+	Developer int
+	// Signature is the merged name, email or "name <email>".
+	Signature string
+	// Rule is one of the Rule* constants.
+	Rule string
+	// Via is the matched key, e.g. the shared email.
+	Via string
+}
+
+var (
+	githubNoreplyRE = regexp.MustCompile(`^(?:\d+\+)?([^@]+)@users\.noreply\.github\.com$`)
+	// genericLocalParts are the email local parts which do not identify a person.
+	genericLocalParts = map[string]bool{
+		"admin": true, "bot": true, "build": true, "ci": true, "dev": true, "git": true,
+		"github": true, "info": true, "mail": true, "me": true, "noreply": true,
+		"no-reply": true, "root": true, "support": true, "test": true, "user": true,
+	}
+	diacriticsRemover = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+)
+
+// normalizeName removes the diacritics and the punctuation and sorts the words.
+func normalizeName(name string) string {
+	folded, _, err := transform.String(diacriticsRemover, name)
+	if err != nil {
+		folded = name
+	}
+	words := strings.FieldsFunc(strings.ToLower(folded), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// emailLogin returns the GitHub login if the email is a GitHub noreply email.
+func emailLogin(email string) string {
+	if match := githubNoreplyRE.FindStringSubmatch(email); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+// emailLocalPart returns the part of the email before "@" without the "+tag" suffix, or an
+// empty string if it is too generic to identify a person.
+func emailLocalPart(email string) string {
+	at := strings.LastIndexByte(email, '@')
+	if at <= 0 {
+		return ""
+	}
+	local := email[:at]
+	if plus := strings.IndexByte(local, '+'); plus > 0 {
+		local = local[:plus]
+	}
+	if len(local) < 3 || genericLocalParts[local] {
+		return ""
+	}
+	return local
+}
+
+// similarNameDistance returns the maximum Levenshtein distance between two normalized names
+// of the specified length which still counts as the same name.
+func similarNameDistance(length int) int {
+	distance := length / 8
+	if distance > 2 {
+		distance = 2
+	}
+	return distance
+}
+
+// identityUnion is the disjoint set forest of the developer indices.
+type identityUnion []int
+
+func (union identityUnion) find(id int) int {
+	for union[id] != id {
+		union[id] = union[union[id]]
+		id = union[id]
+	}
+	return id
+}
+
+// join merges the sets and returns false if they were already merged.
+func (union identityUnion) join(id1, id2 int) bool {
+	root1, root2 := union.find(id1), union.find(id2)
+	if root1 == root2 {
+		return false
+	}
+	if root2 < root1 {
+		root1, root2 = root2, root1
+	}
+	union[root2] = root1
+	return true
+}
+
+// mergeFuzzy merges the developers generated by the exact matching with the fuzzy rules.
+// The developers with the indices less than `fixed` are never merged together, so that
+// they keep their indices.
+// It returns the mapping from the old to the new developer indices and the names and the emails
+// of the new developers.
+func (detector *Detector) mergeFuzzy(names, emails map[int][]string, size, fixed int) (
+	[]int, map[int][]string, map[int][]string) {
+	union := make(identityUnion, size)
+	for i := range union {
+		union[i] = i
+	}
+	describe := func(id int) string {
+		if len(names[id]) > 0 && len(emails[id]) > 0 {
+			return names[id][0] + " <" + emails[id][0] + ">"
+		}
+		return strings.Join(append(append([]string{}, names[id]...), emails[id]...), "|")
+	}
+	merge := func(owner, id int, rule, via string) {
+		// the roots are the least indices in their sets
+		if union.find(owner) < fixed && union.find(id) < fixed {
+			return
+		}
+		if union.join(owner, id) {
+			detector.MergeDecisions = append(detector.MergeDecisions, MergeDecision{
+				Developer: owner, Signature: describe(id), Rule: rule, Via: via})
+		}
+	}
+	type keyOwner struct {
+		rule, key string
+	}
+	owners := map[keyOwner]int{}
+	claim := func(id int, rule, key string) {
+		if key == "" {
+			return
+		}
+		if owner, exists := owners[keyOwner{rule, key}]; exists {
+			merge(owner, id, rule, key)
+			return
+		}
+		owners[keyOwner{rule, key}] = id
+	}
+	normalized := map[string][]int{}
+	for id := 0; id < size; id++ {
+		sort.Strings(names[id])
+		sort.Strings(emails[id])
+		for _, name := range names[id] {
+			key := normalizeName(name)
+			claim(id, RuleNormalizedName, key)
+			if key != "" && (len(normalized[key]) == 0 || normalized[key][len(normalized[key])-1] != id) {
+				normalized[key] = append(normalized[key], id)
+			}
+		}
+		for _, email := range emails[id] {
+			local := emailLocalPart(email)
+			if login := emailLogin(email); login != "" {
+				// both the other noreply emails and the regular emails with this local part
+				claim(id, RuleGitHubNoreply, login)
+				if owner, exists := owners[keyOwner{RuleEmailLocalPart, login}]; exists {
+					merge(owner, id, RuleGitHubNoreply, login)
+				}
+				continue
+			}
+			if owner, exists := owners[keyOwner{RuleGitHubNoreply, local}]; exists && local != "" {
+				merge(owner, id, RuleGitHubNoreply, local)
+			}
+			claim(id, RuleEmailLocalPart, local)
+		}
+	}
+	// compare the names which start with the same letter and have close lengths
+	keys := make([]string, 0, len(normalized))
+	for key := range normalized {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	keyRunes := make([][]rune, len(keys))
+	for i, key := range keys {
+		keyRunes[i] = []rune(key)
+	}
+	context := &levenshtein.Context{}
+	for i, key1 := range keys {
+		runes1 := keyRunes[i]
+		if !strings.ContainsRune(key1, ' ') {
+			continue
+		}
+		for j, key2 := range keys[i+1:] {
+			runes2 := keyRunes[i+1+j]
+			if runes2[0] != runes1[0] {
+				break
+			}
+			length := len(runes1)
+			if len(runes2) < length {
+				length = len(runes2)
+			}
+			maxDistance := similarNameDistance(length)
+			if maxDistance == 0 || abs(len(runes1)-len(runes2)) > maxDistance ||
+				!strings.ContainsRune(key2, ' ') {
+				continue
+			}
+			if context.Distance(key1, key2) <= maxDistance {
+				merge(normalized[key1][0], normalized[key2][0], RuleSimilarName, key1+" ~ "+key2)
+			}
+		}
+	}
+
+	remap := make([]int, size)
+	newSize := 0
+	for id := 0; id < size; id++ {
+		if root := union.find(id); root == id {
+			remap[id] = newSize
+			newSize++
+		} else {
+			remap[id] = remap[root]
+		}
+	}
+	newNames := make(map[int][]string, newSize)
+	newEmails := make(map[int][]string, newSize)
+	for id := 0; id < size; id++ {
+		newNames[remap[id]] = append(newNames[remap[id]], names[id]...)
+		newEmails[remap[id]] = append(newEmails[remap[id]], emails[id]...)
+	}
+	return remap, newNames, newEmails
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// WritePeopleDictReport writes the developers and the decisions which merged their
+// signatures in YAML.
+func (detector *Detector) WritePeopleDictReport(writer io.Writer) error {
+	decisions := map[int][]MergeDecision{}
+	for _, decision := range detector.MergeDecisions {
+		decisions[decision.Developer] = append(decisions[decision.Developer], decision)
+	}
+	for id, person := range detector.ReversedPeopleDict {
+		if _, err := fmt.Fprintf(writer, "- id: %d\n  identity: %q\n  merges:", id, person); err != nil {
+			return err
+		}
+		if len(decisions[id]) == 0 {
+			if _, err := fmt.Fprintln(writer, " []"); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintln(writer)
+		for _, decision := range decisions[id] {
+			_, err := fmt.Fprintf(writer, "  - {signature: %q, rule: %s, via: %q}\n",
+				decision.Signature, decision.Rule, decision.Via)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
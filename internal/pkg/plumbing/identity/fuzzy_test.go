@@ -0,0 +1,108 @@
+package identity
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeName(t *testing.T) {
+	assert.Equal(t, "doe john", normalizeName("John Doe"))
+	assert.Equal(t, "doe john", normalizeName("Doe, John"))
+	assert.Equal(t, "doe john", normalizeName("Jöhn  Dôe"))
+	assert.Equal(t, "", normalizeName("..."))
+}
+
+func TestEmailLoginAndLocalPart(t *testing.T) {
+	assert.Equal(t, "jdoe", emailLogin("12345+jdoe@users.noreply.github.com"))
+	assert.Equal(t, "jdoe", emailLogin("jdoe@users.noreply.github.com"))
+	assert.Equal(t, "", emailLogin("jdoe@github.com"))
+	assert.Equal(t, "jdoe", emailLocalPart("jdoe+hercules@corp.com"))
+	assert.Equal(t, "", emailLocalPart("admin@corp.com"))
+	assert.Equal(t, "", emailLocalPart("jd@corp.com"))
+	assert.Equal(t, "", emailLocalPart("jdoe"))
+}
+
+func fuzzyCommits() []*object.Commit {
+	_, commits := test.NewRepository(
+		test.Commit{Author: "John Doe", Email: "john@corp.com"},
+		test.Commit{Author: "Doe John", Email: "jdoe@home.org"},
+		test.Commit{Author: "jdoe", Email: "12345+jdoe@users.noreply.github.com"},
+		test.Commit{Author: "John Doe", Email: "john@other.net"},
+		test.Commit{Author: "Alice Smith", Email: "alice@corp.com"},
+		test.Commit{Author: "Alice Smyth", Email: "asmith@x.org"},
+		test.Commit{Author: "Bob", Email: "admin@corp.com"},
+		test.Commit{Author: "Carol", Email: "admin@home.org"})
+	return commits
+}
+
+func TestGeneratePeopleDictFuzzy(t *testing.T) {
+	commits := fuzzyCommits()
+	detector := &Detector{}
+	detector.GeneratePeopleDict(commits)
+	assert.Len(t, detector.ReversedPeopleDict, 7)
+	assert.Equal(t, []MergeDecision{
+		{Developer: 0, Signature: "john doe <john@other.net>", Rule: RuleName, Via: "john doe"},
+	}, detector.MergeDecisions)
+
+	detector = &Detector{Fuzzy: true}
+	detector.GeneratePeopleDict(commits)
+	assert.Equal(t, []string{
+		"doe john|jdoe|john doe|12345+jdoe@users.noreply.github.com|jdoe@home.org|john@corp.com|john@other.net",
+		"alice smith|alice smyth|alice@corp.com|asmith@x.org",
+		"bob|admin@corp.com",
+		"carol|admin@home.org",
+	}, detector.ReversedPeopleDict)
+	for _, key := range []string{"doe john", "jdoe", "12345+jdoe@users.noreply.github.com"} {
+		assert.Equal(t, 0, detector.PeopleDict[key], key)
+	}
+	assert.Equal(t, 1, detector.PeopleDict["asmith@x.org"])
+	assert.Equal(t, 3, detector.PeopleDict["carol"])
+	assert.Equal(t, []MergeDecision{
+		{Developer: 0, Signature: "john doe <john@other.net>", Rule: RuleName, Via: "john doe"},
+		{Developer: 0, Signature: "doe john <jdoe@home.org>", Rule: RuleNormalizedName, Via: "doe john"},
+		{Developer: 0, Signature: "jdoe <12345+jdoe@users.noreply.github.com>", Rule: RuleGitHubNoreply,
+			Via: "jdoe"},
+		{Developer: 1, Signature: "alice smyth <asmith@x.org>", Rule: RuleSimilarName,
+			Via: "alice smith ~ alice smyth"},
+	}, detector.MergeDecisions)
+
+	detector.ExactSignatures = true
+	detector.GeneratePeopleDict(commits)
+	assert.Len(t, detector.ReversedPeopleDict, 8)
+}
+
+func TestWritePeopleDictReport(t *testing.T) {
+	detector := &Detector{Fuzzy: true}
+	detector.GeneratePeopleDict(fuzzyCommits())
+	buffer := &bytes.Buffer{}
+	require.NoError(t, detector.WritePeopleDictReport(buffer))
+	assert.Contains(t, buffer.String(), `- id: 1
+  identity: "alice smith|alice smyth|alice@corp.com|asmith@x.org"
+  merges:
+  - {signature: "alice smyth <asmith@x.org>", rule: similar-name, via: "alice smith ~ alice smyth"}
+- id: 2
+  identity: "bob|admin@corp.com"
+  merges: []
+`)
+
+	reportPath := path.Join(t.TempDir(), "report.yaml")
+	detector = &Detector{}
+	require.NoError(t, detector.Configure(map[string]interface{}{
+		core.ConfigPipelineCommits:       fuzzyCommits(),
+		ConfigIdentityDetectorFuzzy:      true,
+		ConfigIdentityDetectorReportPath: reportPath,
+	}))
+	assert.True(t, detector.Fuzzy)
+	assert.Len(t, detector.ReversedPeopleDict, 4)
+	report, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.Equal(t, buffer.String(), string(report))
+}
@@ -2,7 +2,10 @@ package identity
 
 import (
 	"bufio"
+	"bytes"
+	"io"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -24,6 +27,30 @@ type Detector struct {
 	// ExactSignatures chooses the matching algorithm: opportunistic email || name
 	// or exact email && name
 	ExactSignatures bool
+	// Fuzzy enables the fuzzy matching rules in GeneratePeopleDict(), see fuzzy.go.
+	// It is ignored if ExactSignatures is true.
+	Fuzzy bool
+	// ReportPath is the path to the file where to write the merge decisions, see
+	// WritePeopleDictReport().
+	ReportPath string
+	// MergeDecisions are the reasons why the signatures were merged by GeneratePeopleDict().
+	MergeDecisions []MergeDecision
+	// Bots is BotsKeep, BotsExclude or BotsFold, see bots.go.
+	Bots string
+	// BotPattern additionally classifies the names and the emails as bots.
+	BotPattern *regexp.Regexp
+	// BotHeuristics classifies the developers with uniform commit messages or times as bots.
+	BotHeuristics bool
+	// Mailmap maps the commit signatures before the identities are matched. It is read from
+	// .mailmap in the last analysed commit unless NoMailmap is true.
+	Mailmap *Mailmap
+	// NoMailmap disables reading .mailmap from the analysed repository.
+	NoMailmap bool
+
+	// peopleCount is the value of FactIdentityDetectorPeopleCount
+	peopleCount int
+	// generated indicates whether the identities were generated from the commits
+	generated bool
 
 	l core.Logger
 }
@@ -50,6 +77,24 @@ const (
 	// (Detector.Configure()) which changes the matching algorithm to exact signature (name + email)
 	// correspondence.
 	ConfigIdentityDetectorExactSignatures = "IdentityDetector.ExactSignatures"
+	// ConfigIdentityDetectorFuzzy is the name of the configuration option (Detector.Configure())
+	// which enables the fuzzy identity matching.
+	ConfigIdentityDetectorFuzzy = "IdentityDetector.Fuzzy"
+	// ConfigIdentityDetectorReportPath is the name of the configuration option
+	// (Detector.Configure()) which sets the path to write the merge decisions to.
+	ConfigIdentityDetectorReportPath = "IdentityDetector.ReportPath"
+	// ConfigIdentityDetectorBots is the name of the configuration option (Detector.Configure())
+	// which chooses what to do with the bots: BotsKeep, BotsExclude or BotsFold.
+	ConfigIdentityDetectorBots = "IdentityDetector.Bots"
+	// ConfigIdentityDetectorBotPattern is the name of the configuration option
+	// (Detector.Configure()) which sets the regular expression for the bot names and emails.
+	ConfigIdentityDetectorBotPattern = "IdentityDetector.BotPattern"
+	// ConfigIdentityDetectorBotHeuristics is the name of the configuration option
+	// (Detector.Configure()) which enables the behavioral bot detection.
+	ConfigIdentityDetectorBotHeuristics = "IdentityDetector.BotHeuristics"
+	// ConfigIdentityDetectorNoMailmap is the name of the configuration option
+	// (Detector.Configure()) which disables reading .mailmap from the analysed repository.
+	ConfigIdentityDetectorNoMailmap = "IdentityDetector.NoMailmap"
 	// FactIdentityDetectorPeopleCount is the name of the fact which is inserted in
 	// Detector.Configure(). It is equal to the overall number of unique authors
 	// (the length of ReversedPeopleDict).
@@ -57,6 +102,9 @@ const (
 
 	// DependencyAuthor is the name of the dependency provided by Detector.
 	DependencyAuthor = "author"
+	// DependencyAuthorExcluded is the name of the dependency provided by Detector.
+	// It is true if the author is a bot and Bots is BotsExclude.
+	DependencyAuthorExcluded = "author_excluded"
 )
 
 // Name of this PipelineItem. Uniquely identifies the type, used for mapping keys, etc.
@@ -68,7 +116,7 @@ func (detector *Detector) Name() string {
 // Each produced entity will be inserted into `deps` of dependent Consume()-s according
 // to this list. Also used by core.Registry to build the global map of providers.
 func (detector *Detector) Provides() []string {
-	return []string{DependencyAuthor}
+	return []string{DependencyAuthor, DependencyAuthorExcluded}
 }
 
 // Requires returns the list of names of entities which are needed by this PipelineItem.
@@ -91,7 +139,41 @@ func (detector *Detector) ListConfigurationOptions() []core.ConfigurationOption
 			"identities and should not be normally used.",
 		Flag:    "exact-signatures",
 		Type:    core.BoolConfigurationOption,
-		Default: false},
+		Default: false}, {
+		Name: ConfigIdentityDetectorFuzzy,
+		Description: "Also merge the names which differ in diacritics, word order or by a typo, " +
+			"the GitHub noreply emails and the same email names in different domains.",
+		Flag:    "people-fuzzy",
+		Type:    core.BoolConfigurationOption,
+		Default: false}, {
+		Name:        ConfigIdentityDetectorReportPath,
+		Description: "Write every identity merge and the rule which caused it to this file.",
+		Flag:        "people-dict-report",
+		Type:        core.PathConfigurationOption,
+		Default:     ""}, {
+		Name: ConfigIdentityDetectorBots,
+		Description: "What to do with the bots: \"keep\" them as the regular developers, " +
+			"\"exclude\" them from the analyses or \"fold\" them into the single \"bots\" identity.",
+		Flag:    "bots",
+		Type:    core.StringConfigurationOption,
+		Default: BotsKeep}, {
+		Name: ConfigIdentityDetectorBotPattern,
+		Description: "Regular expression for the bot names and emails in addition to " +
+			"dependabot, renovate, github-actions, \"[bot]\" and the like.",
+		Flag:    "bot-pattern",
+		Type:    core.StringConfigurationOption,
+		Default: ""}, {
+		Name: ConfigIdentityDetectorBotHeuristics,
+		Description: "Also classify the developers whose commit messages follow the same template " +
+			"or whose commits happen at the same time of the day as bots.",
+		Flag:    "bot-heuristics",
+		Type:    core.BoolConfigurationOption,
+		Default: false}, {
+		Name:        ConfigIdentityDetectorNoMailmap,
+		Description: "Do not map the signatures with .mailmap from the analysed repository.",
+		Flag:        "no-mailmap",
+		Type:        core.BoolConfigurationOption,
+		Default:     false},
 	}
 	return options[:]
 }
@@ -112,6 +194,61 @@ func (detector *Detector) Configure(facts map[string]interface{}) error {
 	if val, exists := facts[ConfigIdentityDetectorExactSignatures].(bool); exists {
 		detector.ExactSignatures = val
 	}
+	if val, exists := facts[ConfigIdentityDetectorFuzzy].(bool); exists {
+		detector.Fuzzy = val
+	}
+	if val, exists := facts[ConfigIdentityDetectorReportPath].(string); exists {
+		detector.ReportPath = val
+	}
+	if val, exists := facts[ConfigIdentityDetectorBots].(string); exists {
+		if err := validateBots(val); err != nil {
+			return err
+		}
+		detector.Bots = val
+	}
+	if val, exists := facts[ConfigIdentityDetectorBotPattern].(string); exists && val != "" {
+		pattern, err := regexp.Compile(val)
+		if err != nil {
+			return errors.Wrapf(err, "invalid %s", ConfigIdentityDetectorBotPattern)
+		}
+		detector.BotPattern = pattern
+	}
+	if val, exists := facts[ConfigIdentityDetectorBotHeuristics].(bool); exists {
+		detector.BotHeuristics = val
+	}
+	if val, exists := facts[ConfigIdentityDetectorNoMailmap].(bool); exists {
+		detector.NoMailmap = val
+	}
+	if commits, exists := facts[core.ConfigPipelineCommits].([]*object.Commit); exists {
+		// the detector may have analysed another repository before
+		detector.Mailmap = nil
+		detector.readMailmap(commits)
+	}
+	restored := false
+	if checkpoint, exists := facts[core.ConfigPipelineResume].(*core.Checkpoint); exists {
+		if state, exists := checkpoint.Items[detector.Name()]; exists {
+			restored = true
+			saved := identityCheckpoint{}
+			err := core.DecodeCheckpoint(bytes.NewReader(state), &saved)
+			if err != nil {
+				return errors.Wrap(err, "failed to restore the identities from the checkpoint")
+			}
+			detector.PeopleDict = saved.PeopleDict
+			detector.ReversedPeopleDict = saved.ReversedPeopleDict
+			detector.peopleCount = saved.PeopleCount
+			detector.generated = saved.Generated
+			detector.MergeDecisions = saved.MergeDecisions
+			if commits, exists := facts[core.ConfigPipelineCommits].([]*object.Commit); exists &&
+				saved.Generated {
+				detector.extendPeopleDict(commits)
+				if detector.ReportPath != "" {
+					if err := detector.writePeopleDictReportFile(); err != nil {
+						return errors.Wrapf(err, "failed to write %s", detector.ReportPath)
+					}
+				}
+			}
+		}
+	}
 	if detector.PeopleDict == nil || detector.ReversedPeopleDict == nil {
 		peopleDictPath, _ := facts[ConfigIdentityDetectorPeopleDictPath].(string)
 		if peopleDictPath != "" {
@@ -119,17 +256,31 @@ func (detector *Detector) Configure(facts map[string]interface{}) error {
 			if err != nil {
 				return errors.Errorf("failed to load %s: %v", peopleDictPath, err)
 			}
-			facts[FactIdentityDetectorPeopleCount] = len(detector.ReversedPeopleDict) - 1
+			if commits, exists := facts[core.ConfigPipelineCommits].([]*object.Commit); exists {
+				detector.applyBots(commits)
+			}
+			detector.peopleCount = len(detector.ReversedPeopleDict) - 1
+			detector.generated = false
 		} else {
 			if _, exists := facts[core.ConfigPipelineCommits]; !exists {
 				panic("IdentityDetector needs a list of commits to initialize.")
 			}
-			detector.GeneratePeopleDict(facts[core.ConfigPipelineCommits].([]*object.Commit))
-			facts[FactIdentityDetectorPeopleCount] = len(detector.ReversedPeopleDict)
+			commits := facts[core.ConfigPipelineCommits].([]*object.Commit)
+			detector.GeneratePeopleDict(commits)
+			detector.applyBots(commits)
+			detector.peopleCount = len(detector.ReversedPeopleDict)
+			detector.generated = true
+			if detector.ReportPath != "" {
+				if err := detector.writePeopleDictReportFile(); err != nil {
+					return errors.Wrapf(err, "failed to write %s", detector.ReportPath)
+				}
+			}
 		}
-	} else {
-		facts[FactIdentityDetectorPeopleCount] = len(detector.ReversedPeopleDict)
+	} else if !restored {
+		detector.peopleCount = len(detector.ReversedPeopleDict)
+		detector.generated = false
 	}
+	facts[FactIdentityDetectorPeopleCount] = detector.peopleCount
 	facts[FactIdentityDetectorPeopleDict] = detector.PeopleDict
 	facts[FactIdentityDetectorReversedPeopleDict] = detector.ReversedPeopleDict
 	return nil
@@ -149,21 +300,57 @@ func (detector *Detector) Initialize(repository *git.Repository) error {
 // in Provides(). If there was an error, nil is returned.
 func (detector *Detector) Consume(deps map[string]interface{}) (map[string]interface{}, error) {
 	commit := deps[core.DependencyCommit].(*object.Commit)
-	var authorID int
-	var exists bool
-	signature := commit.Author
-	if !detector.ExactSignatures {
-		authorID, exists = detector.PeopleDict[strings.ToLower(signature.Email)]
-		if !exists {
-			authorID, exists = detector.PeopleDict[strings.ToLower(signature.Name)]
+	authorID, exists := detector.lookup(commit.Author)
+	if !exists {
+		authorID = AuthorMissing
+	}
+	// the excluded bots are the only known signatures which map to AuthorMissing
+	excluded := exists && authorID == AuthorMissing
+	return map[string]interface{}{
+		DependencyAuthor: authorID, DependencyAuthorExcluded: excluded}, nil
+}
+
+// lookup returns the developer index of the signature in PeopleDict. The signature is mapped
+// with Mailmap first, the original is the fallback for the people dicts loaded from files.
+func (detector *Detector) lookup(signature object.Signature) (int, bool) {
+	if mapped := detector.mapSignature(signature); mapped != signature {
+		if authorID, exists := detector.lookupExact(mapped); exists {
+			return authorID, true
 		}
-	} else {
-		authorID, exists = detector.PeopleDict[strings.ToLower(signature.String())]
 	}
+	return detector.lookupExact(signature)
+}
+
+// lookupExact returns the developer index of the signature in PeopleDict without Mailmap.
+func (detector *Detector) lookupExact(signature object.Signature) (int, bool) {
+	if detector.ExactSignatures {
+		authorID, exists := detector.PeopleDict[strings.ToLower(signature.String())]
+		return authorID, exists
+	}
+	authorID, exists := detector.PeopleDict[strings.ToLower(signature.Email)]
 	if !exists {
-		authorID = AuthorMissing
+		authorID, exists = detector.PeopleDict[strings.ToLower(signature.Name)]
+	}
+	return authorID, exists
+}
+
+// mapSignature applies Mailmap to the signature.
+func (detector *Detector) mapSignature(signature object.Signature) object.Signature {
+	if detector.Mailmap == nil {
+		return signature
+	}
+	return detector.Mailmap.Map(signature)
+}
+
+// readMailmap sets Mailmap from .mailmap in the last commit, which is HEAD by default.
+// The broken .mailmap-s are ignored like git does.
+func (detector *Detector) readMailmap(commits []*object.Commit) {
+	if detector.NoMailmap || detector.Mailmap != nil || len(commits) == 0 {
+		return
+	}
+	if mailmap, err := ReadMailmap(commits[len(commits)-1]); err == nil {
+		detector.Mailmap = mailmap
 	}
-	return map[string]interface{}{DependencyAuthor: authorID}, nil
 }
 
 // Fork clones this PipelineItem.
@@ -171,6 +358,50 @@ func (detector *Detector) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(detector, n)
 }
 
+// identityCheckpoint is the state of Detector saved in core.Checkpoint.
+type identityCheckpoint struct {
+	PeopleDict         map[string]int
+	ReversedPeopleDict []string
+	PeopleCount        int
+	Generated          bool
+	MergeDecisions     []MergeDecision
+}
+
+// Checkpoint writes the identities. It implements core.CheckpointablePipelineItem.
+func (detector *Detector) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, identityCheckpoint{
+		PeopleDict:         detector.PeopleDict,
+		ReversedPeopleDict: detector.ReversedPeopleDict,
+		PeopleCount:        detector.peopleCount,
+		Generated:          detector.generated,
+		MergeDecisions:     detector.MergeDecisions,
+	})
+}
+
+// Restore does nothing: the identities are restored in Configure() from core.ConfigPipelineResume
+// because the downstream items must see the same developer indices as before.
+// It implements core.CheckpointablePipelineItem.
+func (detector *Detector) Restore(reader io.Reader) error {
+	return nil
+}
+
+// extendPeopleDict matches the authors of the specified commits with the same rules as
+// GeneratePeopleDict() and appends the new developers, then excludes or folds the new bots.
+// The existing developer indices do not change, so the existing developers are never merged
+// together even if a new signature links them, and the bot heuristics only see the new ones.
+func (detector *Detector) extendPeopleDict(commits []*object.Commit) {
+	existing := len(detector.ReversedPeopleDict)
+	detector.addPeople(commits)
+	bots := detector.classifyBots(commits)
+	for id := range bots {
+		if id < existing {
+			delete(bots, id)
+		}
+	}
+	detector.foldBots(bots)
+	detector.peopleCount = len(detector.ReversedPeopleDict)
+}
+
 // LoadPeopleDict loads author signatures from a text file.
 // The format is one signature per line, and the signature consists of several
 // keys separated by "|". The first key is the main one and used to reference all the rest.
@@ -198,79 +429,86 @@ func (detector *Detector) LoadPeopleDict(path string) error {
 	return nil
 }
 
+// writePeopleDictReportFile writes WritePeopleDictReport() to ReportPath.
+func (detector *Detector) writePeopleDictReportFile() error {
+	file, err := os.Create(detector.ReportPath)
+	if err != nil {
+		return err
+	}
+	if err = detector.WritePeopleDictReport(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 // GeneratePeopleDict loads author signatures from the specified list of Git commits.
+// The reasons of the merges are recorded in MergeDecisions.
 func (detector *Detector) GeneratePeopleDict(commits []*object.Commit) {
-	dict := map[string]int{}
+	detector.PeopleDict = map[string]int{}
+	detector.ReversedPeopleDict = nil
+	detector.MergeDecisions = nil
+	detector.readMailmap(commits)
+	detector.addPeople(commits)
+}
+
+// addPeople matches the authors of the commits with the developers in PeopleDict and appends
+// the new developers to ReversedPeopleDict. The existing developers keep their indices.
+func (detector *Detector) addPeople(commits []*object.Commit) {
+	dict := detector.PeopleDict
+	existing := len(detector.ReversedPeopleDict)
+	size := existing
 	emails := map[int][]string{}
 	names := map[int][]string{}
-	size := 0
-
-	mailmapFile, err := commits[len(commits)-1].File(".mailmap")
-	// TODO(vmarkovtsev): properly handle .mailmap if ExactSignatures
-	if !detector.ExactSignatures && err == nil {
-		mailMapContents, err := mailmapFile.Contents()
-		if err == nil {
-			mailmap := ParseMailmap(mailMapContents)
-			for key, val := range mailmap {
-				key = strings.ToLower(key)
-				toEmail := strings.ToLower(val.Email)
-				toName := strings.ToLower(val.Name)
-				id, exists := dict[toEmail]
-				if !exists {
-					id, exists = dict[toName]
-				}
-				if exists {
-					dict[key] = id
-				} else {
-					id = size
-					size++
-					if toEmail != "" {
-						dict[toEmail] = id
-						emails[id] = append(emails[id], toEmail)
-					}
-					if toName != "" {
-						dict[toName] = id
-						names[id] = append(names[id], toName)
-					}
-					dict[key] = id
-				}
-				if strings.Contains(key, "@") {
-					exists := false
-					for _, val := range emails[id] {
-						if key == val {
-							exists = true
-							break
-						}
-					}
-					if !exists {
-						emails[id] = append(emails[id], key)
-					}
-				} else {
-					exists := false
-					for _, val := range names[id] {
-						if key == val {
-							exists = true
-							break
-						}
-					}
-					if !exists {
-						names[id] = append(names[id], key)
-					}
-				}
+	if !detector.ExactSignatures {
+		for key, id := range dict {
+			if id >= existing {
+				// the excluded bots
+				continue
+			}
+			if strings.ContainsRune(key, '@') {
+				emails[id] = append(emails[id], key)
+			} else {
+				names[id] = append(names[id], key)
 			}
 		}
 	}
+	keys := make([]int, existing)
+	for id := range keys {
+		keys[id] = len(names[id]) + len(emails[id])
+	}
+	firstDecision := len(detector.MergeDecisions)
+	decide := func(id int, signature, rule, via string) {
+		detector.MergeDecisions = append(detector.MergeDecisions, MergeDecision{
+			Developer: id, Signature: signature, Rule: rule, Via: via})
+	}
 
+	// the original signatures changed by .mailmap, in the order of appearance
+	var mapped []object.Signature
+	seen := map[string]bool{}
+	for _, decision := range detector.MergeDecisions {
+		if decision.Rule == RuleMailmap {
+			seen[decision.Signature] = true
+		}
+	}
 	for _, commit := range commits {
+		signature := detector.mapSignature(commit.Author)
+		if signature != commit.Author {
+			if key := strings.ToLower(commit.Author.String()); !seen[key] {
+				seen[key] = true
+				mapped = append(mapped, commit.Author)
+			}
+		}
 		if !detector.ExactSignatures {
-			email := strings.ToLower(commit.Author.Email)
-			name := strings.ToLower(commit.Author.Name)
+			email := strings.ToLower(signature.Email)
+			name := strings.ToLower(signature.Name)
 			id, exists := dict[email]
 			if exists {
 				_, exists := dict[name]
 				if !exists {
 					dict[name] = id
 					names[id] = append(names[id], name)
+					decide(id, name+" <"+email+">", RuleEmail, email)
 				}
 				continue
 			}
@@ -278,6 +516,7 @@ func (detector *Detector) GeneratePeopleDict(commits []*object.Commit) {
 			if exists {
 				dict[email] = id
 				emails[id] = append(emails[id], email)
+				decide(id, name+" <"+email+">", RuleName, name)
 				continue
 			}
 			dict[email] = size
@@ -286,23 +525,61 @@ func (detector *Detector) GeneratePeopleDict(commits []*object.Commit) {
 			names[size] = append(names[size], name)
 			size++
 		} else { // !detector.ExactSignatures
-			sig := strings.ToLower(commit.Author.String())
+			sig := strings.ToLower(signature.String())
 			if _, exists := dict[sig]; !exists {
 				dict[sig] = size
 				size++
 			}
 		}
 	}
+	for _, original := range mapped {
+		signature := detector.mapSignature(original)
+		via := strings.ToLower(signature.Email)
+		if detector.ExactSignatures {
+			via = strings.ToLower(signature.String())
+		}
+		decide(dict[via], strings.ToLower(original.String()), RuleMailmap, via)
+	}
+	if detector.Fuzzy && !detector.ExactSignatures {
+		var remap []int
+		remap, names, emails = detector.mergeFuzzy(names, emails, size, existing)
+		for key, val := range dict {
+			if val < size {
+				dict[key] = remap[val]
+			}
+		}
+		for i := firstDecision; i < len(detector.MergeDecisions); i++ {
+			if developer := detector.MergeDecisions[i].Developer; developer < size {
+				detector.MergeDecisions[i].Developer = remap[developer]
+			}
+		}
+		size = len(names)
+	}
+	// the excluded bots merge nothing
+	decisions := detector.MergeDecisions[:firstDecision]
+	for _, decision := range detector.MergeDecisions[firstDecision:] {
+		if decision.Developer < size {
+			decisions = append(decisions, decision)
+		}
+	}
+	detector.MergeDecisions = decisions
 	reverseDict := make([]string, size)
+	copy(reverseDict, detector.ReversedPeopleDict)
 	if !detector.ExactSignatures {
-		for _, val := range dict {
-			sort.Strings(names[val])
-			sort.Strings(emails[val])
-			reverseDict[val] = strings.Join(names[val], "|") + "|" + strings.Join(emails[val], "|")
+		for id := 0; id < size; id++ {
+			if id < existing && (keys[id] == len(names[id])+len(emails[id]) ||
+				reverseDict[id] == BotsIdentity) {
+				continue
+			}
+			sort.Strings(names[id])
+			sort.Strings(emails[id])
+			reverseDict[id] = strings.Join(names[id], "|") + "|" + strings.Join(emails[id], "|")
 		}
 	} else {
 		for key, val := range dict {
-			reverseDict[val] = key
+			if val >= existing && val < size {
+				reverseDict[val] = key
+			}
 		}
 	}
 	detector.PeopleDict = dict
@@ -1,6 +1,7 @@
 package identity
 
 import (
+	"bytes"
 	"io"
 	"io/ioutil"
 	"os"
@@ -16,6 +17,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func fixtureIdentityDetector() *Detector {
@@ -36,12 +38,19 @@ func TestIdentityDetectorMeta(t *testing.T) {
 	id := fixtureIdentityDetector()
 	assert.Equal(t, id.Name(), "IdentityDetector")
 	assert.Equal(t, len(id.Requires()), 0)
-	assert.Equal(t, len(id.Provides()), 1)
+	assert.Equal(t, len(id.Provides()), 2)
 	assert.Equal(t, id.Provides()[0], DependencyAuthor)
+	assert.Equal(t, id.Provides()[1], DependencyAuthorExcluded)
 	opts := id.ListConfigurationOptions()
-	assert.Len(t, opts, 2)
+	assert.Len(t, opts, 8)
 	assert.Equal(t, opts[0].Name, ConfigIdentityDetectorPeopleDictPath)
 	assert.Equal(t, opts[1].Name, ConfigIdentityDetectorExactSignatures)
+	assert.Equal(t, opts[2].Name, ConfigIdentityDetectorFuzzy)
+	assert.Equal(t, opts[3].Name, ConfigIdentityDetectorReportPath)
+	assert.Equal(t, opts[4].Name, ConfigIdentityDetectorBots)
+	assert.Equal(t, opts[5].Name, ConfigIdentityDetectorBotPattern)
+	assert.Equal(t, opts[6].Name, ConfigIdentityDetectorBotHeuristics)
+	assert.Equal(t, opts[7].Name, ConfigIdentityDetectorNoMailmap)
 	logger := core.GetLogger()
 	assert.NoError(t, id.Configure(map[string]interface{}{
 		core.ConfigLogger: logger,
@@ -136,15 +145,13 @@ func TestIdentityDetectorConfigureEmpty(t *testing.T) {
 }
 
 func TestIdentityDetectorConsume(t *testing.T) {
-	commit, _ := test.Repository.CommitObject(plumbing.NewHash(
-		"5c0e755dd85ac74584d9988cc361eccf02ce1a48"))
+	commit := test.FixtureCommit(t, "5c0e755dd85ac74584d9988cc361eccf02ce1a48")
 	deps := map[string]interface{}{}
 	deps[core.DependencyCommit] = commit
 	res, err := fixtureIdentityDetector().Consume(deps)
 	assert.Nil(t, err)
 	assert.Equal(t, res[DependencyAuthor].(int), 0)
-	commit, _ = test.Repository.CommitObject(plumbing.NewHash(
-		"8a03b5620b1caa72ec9cb847ea88332621e2950a"))
+	commit = test.FixtureCommit(t, "8a03b5620b1caa72ec9cb847ea88332621e2950a")
 	deps[core.DependencyCommit] = commit
 	res, err = fixtureIdentityDetector().Consume(deps)
 	assert.Nil(t, err)
@@ -152,8 +159,7 @@ func TestIdentityDetectorConsume(t *testing.T) {
 }
 
 func TestIdentityDetectorConsumeExact(t *testing.T) {
-	commit, _ := test.Repository.CommitObject(plumbing.NewHash(
-		"5c0e755dd85ac74584d9988cc361eccf02ce1a48"))
+	commit := test.FixtureCommit(t, "5c0e755dd85ac74584d9988cc361eccf02ce1a48")
 	deps := map[string]interface{}{}
 	deps[core.DependencyCommit] = commit
 	id := fixtureIdentityDetector()
@@ -165,8 +171,7 @@ func TestIdentityDetectorConsumeExact(t *testing.T) {
 	res, err := id.Consume(deps)
 	assert.Nil(t, err)
 	assert.Equal(t, res[DependencyAuthor].(int), 1)
-	commit, _ = test.Repository.CommitObject(plumbing.NewHash(
-		"8a03b5620b1caa72ec9cb847ea88332621e2950a"))
+	commit = test.FixtureCommit(t, "8a03b5620b1caa72ec9cb847ea88332621e2950a")
 	deps[core.DependencyCommit] = commit
 	res, err = id.Consume(deps)
 	assert.Nil(t, err)
@@ -176,7 +181,7 @@ func TestIdentityDetectorConsumeExact(t *testing.T) {
 func TestIdentityDetectorLoadPeopleDict(t *testing.T) {
 	id := fixtureIdentityDetector()
 	err := id.LoadPeopleDict(path.Join("..", "..", "test_data", "identities"))
-	assert.Nil(t, err)
+	require.NoError(t, err)
 	assert.Equal(t, len(id.PeopleDict), 7)
 	assert.Contains(t, id.PeopleDict, "linus torvalds")
 	assert.Contains(t, id.PeopleDict, "torvalds@linux-foundation.org")
@@ -200,15 +205,7 @@ func TestIdentityDetectorLoadPeopleDictWrongPath(t *testing.T) {
 
 func TestIdentityDetectorGeneratePeopleDict(t *testing.T) {
 	id := fixtureIdentityDetector()
-	commits := make([]*object.Commit, 0)
-	iter, err := test.Repository.CommitObjects()
-	commit, err := iter.Next()
-	for ; err != io.EOF; commit, err = iter.Next() {
-		if err != nil {
-			panic(err)
-		}
-		commits = append(commits, commit)
-	}
+	commits := test.FixtureCommits(t)
 	{
 		i := 0
 		for ; commits[i].Author.Name != "Vadim Markovtsev"; i++ {
@@ -254,15 +251,7 @@ func TestIdentityDetectorGeneratePeopleDict(t *testing.T) {
 func TestIdentityDetectorGeneratePeopleDictExact(t *testing.T) {
 	id := fixtureIdentityDetector()
 	id.ExactSignatures = true
-	commits := make([]*object.Commit, 0)
-	iter, err := test.Repository.CommitObjects()
-	commit, err := iter.Next()
-	for ; err != io.EOF; commit, err = iter.Next() {
-		if err != nil {
-			panic(err)
-		}
-		commits = append(commits, commit)
-	}
+	commits := test.FixtureCommits(t)
 	id.GeneratePeopleDict(commits)
 	ass := assert.New(t)
 	ass.Equal(len(id.PeopleDict), len(id.ReversedPeopleDict))
@@ -371,6 +360,14 @@ func (strr fakeEncodedObjectStorer) EncodedObjectSize(plumbing.Hash) (int64, err
 	return 0, nil
 }
 
+func (strr fakeEncodedObjectStorer) RawObjectWriter(plumbing.ObjectType, int64) (io.WriteCloser, error) {
+	return nil, nil
+}
+
+func (strr fakeEncodedObjectStorer) AddAlternate(string) error {
+	return nil
+}
+
 func getFakeCommitWithFile(name string, contents string) *object.Commit {
 	c := object.Commit{
 		Hash: plumbing.NewHash("ffffffffffffffffffffffffffffffffffffffff"),
@@ -477,3 +474,83 @@ func TestIdentityDetectorFork(t *testing.T) {
 	assert.True(t, id1 == id2)
 	id1.Merge([]core.PipelineItem{id2})
 }
+
+// resumeIdentityDetector configures a detector with the first `split` commits, saves
+// the checkpoint and configures another detector with the rest of the commits resuming from it.
+func resumeIdentityDetector(t *testing.T, commits []*object.Commit, split int,
+	facts map[string]interface{}) *Detector {
+	configure := func(commits []*object.Commit, checkpoint *core.Checkpoint) *Detector {
+		allFacts := map[string]interface{}{core.ConfigPipelineCommits: commits}
+		for key, val := range facts {
+			allFacts[key] = val
+		}
+		if checkpoint != nil {
+			allFacts[core.ConfigPipelineResume] = checkpoint
+		}
+		detector := &Detector{}
+		require.NoError(t, detector.Configure(allFacts))
+		return detector
+	}
+	first := configure(commits[:split], nil)
+	buffer := &bytes.Buffer{}
+	require.NoError(t, first.Checkpoint(buffer))
+	return configure(commits[split:], &core.Checkpoint{
+		Items: map[string][]byte{first.Name(): buffer.Bytes()}})
+}
+
+// testIdentityDetectorResume checks that the resumed detector matches the signatures
+// the same way as the detector which saw all the commits.
+func testIdentityDetectorResume(t *testing.T, commits []*object.Commit, split int,
+	facts map[string]interface{}) *Detector {
+	full := &Detector{}
+	allFacts := map[string]interface{}{core.ConfigPipelineCommits: commits}
+	for key, val := range facts {
+		allFacts[key] = val
+	}
+	require.NoError(t, full.Configure(allFacts))
+	resumed := resumeIdentityDetector(t, commits, split, facts)
+	assert.Equal(t, full.ReversedPeopleDict, resumed.ReversedPeopleDict, split)
+	assert.Equal(t, full.PeopleDict, resumed.PeopleDict, split)
+	assert.ElementsMatch(t, full.MergeDecisions, resumed.MergeDecisions, split)
+	assert.Equal(t, len(full.ReversedPeopleDict), resumed.peopleCount, split)
+	return resumed
+}
+
+func TestIdentityDetectorResumeFuzzy(t *testing.T) {
+	commits := fuzzyCommits()
+	for split := 1; split < len(commits); split++ {
+		testIdentityDetectorResume(t, commits, split, map[string]interface{}{
+			ConfigIdentityDetectorFuzzy: true,
+		})
+		testIdentityDetectorResume(t, commits, split, map[string]interface{}{})
+		testIdentityDetectorResume(t, commits, split, map[string]interface{}{
+			ConfigIdentityDetectorExactSignatures: true,
+		})
+	}
+
+	reportPath := path.Join(t.TempDir(), "report.yaml")
+	resumed := resumeIdentityDetector(t, commits, 3, map[string]interface{}{
+		ConfigIdentityDetectorFuzzy:      true,
+		ConfigIdentityDetectorReportPath: reportPath,
+	})
+	report, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(report), `rule: similar-name, via: "alice smith ~ alice smyth"`)
+	assert.Contains(t, string(report), `rule: normalized-name, via: "doe john"`)
+	assert.Len(t, resumed.ReversedPeopleDict, 4)
+}
+
+func TestIdentityDetectorResumeKeepsIndices(t *testing.T) {
+	_, commits := test.NewRepository(
+		test.Commit{Author: "John Doe", Email: "john@corp.com"},
+		test.Commit{Author: "Jane Roe", Email: "jdoe@home.org"},
+		// links both developers by the name and by the email local part
+		test.Commit{Author: "Doe John", Email: "jdoe@other.net"})
+	resumed := resumeIdentityDetector(t, commits, 2, map[string]interface{}{
+		ConfigIdentityDetectorFuzzy: true,
+	})
+	assert.Len(t, resumed.ReversedPeopleDict, 2)
+	assert.Equal(t, 0, resumed.PeopleDict["john@corp.com"])
+	assert.Equal(t, 1, resumed.PeopleDict["jdoe@home.org"])
+	assert.Contains(t, []int{0, 1}, resumed.PeopleDict["jdoe@other.net"])
+}
@@ -9,7 +9,7 @@ import (
 // ParseMailmap parses the contents of .mailmap and returns the mapping
 // between signature parts. It does *not* follow the full signature
 // matching convention, that is, developers are identified by email
-// and by name independently.
+// and by name independently. See Mailmap for the git semantics.
 func ParseMailmap(contents string) map[string]object.Signature {
 	mm := map[string]object.Signature{}
 	lines := strings.Split(contents, "\n")
@@ -49,3 +49,134 @@ func ParseMailmap(contents string) map[string]object.Signature {
 	}
 	return mm
 }
+
+// Mailmap maps the commit signatures to the canonical ones exactly like git does,
+// see gitmailmap(5). The four entry forms are supported:
+//
+//	Proper Name <commit@email>
+//	<proper@email> <commit@email>
+//	Proper Name <proper@email> <commit@email>
+//	Proper Name <proper@email> Commit Name <commit@email>
+//
+// The emails and the names are matched case-insensitively. The entries with the commit name
+// take precedence over the entries with only the commit email.
+type Mailmap struct {
+	// emails are keyed by the lowercased commit email
+	emails map[string]*mailmapEmail
+}
+
+// mailmapTarget is the proper name and email, any of them may be empty.
+type mailmapTarget struct {
+	Name  string
+	Email string
+}
+
+// mailmapEmail is the mapping of a single commit email.
+type mailmapEmail struct {
+	mailmapTarget
+	// names are keyed by the lowercased commit name
+	names map[string]*mailmapTarget
+}
+
+// NewMailmap parses the contents of .mailmap.
+func NewMailmap(contents string) *Mailmap {
+	mailmap := &Mailmap{emails: map[string]*mailmapEmail{}}
+	for _, line := range strings.Split(contents, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name1, email1, rest, ok := parseMailmapNameEmail(line, false)
+		if !ok {
+			continue
+		}
+		name2, email2, _, ok := parseMailmapNameEmail(rest, true)
+		if !ok {
+			name2, email2 = "", ""
+		}
+		mailmap.add(name1, email1, name2, email2)
+	}
+	return mailmap
+}
+
+// parseMailmapNameEmail parses "Name <email>" at the beginning of the line and returns the
+// trimmed name, the email and the rest of the line.
+func parseMailmapNameEmail(line string, allowEmptyEmail bool) (
+	name, email, rest string, ok bool) {
+	left := strings.IndexByte(line, '<')
+	if left < 0 {
+		return "", "", "", false
+	}
+	right := strings.IndexByte(line[left+1:], '>')
+	if right < 0 || (right == 0 && !allowEmptyEmail) {
+		return "", "", "", false
+	}
+	right += left + 1
+	return strings.TrimSpace(line[:left]), line[left+1 : right], line[right+1:], true
+}
+
+// add records the mapping of the old name and email to the new ones like git's add_mapping().
+func (mailmap *Mailmap) add(newName, newEmail, oldName, oldEmail string) {
+	if oldEmail == "" {
+		oldEmail, newEmail = newEmail, ""
+	}
+	key := strings.ToLower(oldEmail)
+	entry := mailmap.emails[key]
+	if entry == nil {
+		entry = &mailmapEmail{}
+		mailmap.emails[key] = entry
+	}
+	if oldName == "" {
+		if newName != "" {
+			entry.Name = newName
+		}
+		if newEmail != "" {
+			entry.Email = newEmail
+		}
+		return
+	}
+	if entry.names == nil {
+		entry.names = map[string]*mailmapTarget{}
+	}
+	entry.names[strings.ToLower(oldName)] = &mailmapTarget{Name: newName, Email: newEmail}
+}
+
+// ReadMailmap parses .mailmap in the tree of the commit. It returns nil if there is no .mailmap.
+func ReadMailmap(commit *object.Commit) (*Mailmap, error) {
+	file, err := commit.File(".mailmap")
+	if err == object.ErrFileNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+	return NewMailmap(contents), nil
+}
+
+// Len returns the number of the mapped commit emails.
+func (mailmap *Mailmap) Len() int {
+	return len(mailmap.emails)
+}
+
+// Map returns the canonical signature. The signatures without the matching entries
+// are returned as is.
+func (mailmap *Mailmap) Map(signature object.Signature) object.Signature {
+	entry := mailmap.emails[strings.ToLower(signature.Email)]
+	if entry == nil {
+		return signature
+	}
+	target := &entry.mailmapTarget
+	if named := entry.names[strings.ToLower(signature.Name)]; named != nil {
+		target = named
+	}
+	if target.Name != "" {
+		signature.Name = target.Name
+	}
+	if target.Email != "" {
+		signature.Email = target.Email
+	}
+	return signature
+}
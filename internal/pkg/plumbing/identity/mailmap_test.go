@@ -3,6 +3,9 @@ package identity
 import (
 	"testing"
 
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -301,3 +304,70 @@ func TestParseMailmapBadFormat(t *testing.T) {
 	assert.Equal(t, mm["<dengemann"].Name, "Denis Engemann")
 	assert.Equal(t, mm["<dengemann"].Email, "denis-alexander.engemann@inria.fr")
 }
+
+func TestMailmap(t *testing.T) {
+	mailmap := NewMailmap(`# comment
+Proper Name <commit@email.xx>
+<proper@email.xx> <Other@Email.xx>
+Joe Developer <joe@example.com> <joe@laptop.(none)>
+Jane Doe <jane@example.com> jane <bugs@example.com>
+Jane Doe <jane@example.com> Jane <bugs@example.com>
+Bug Fixer <bugs@example.com> <bugs@example.com>
+<broken@email.xx
+Empty <> <empty@email.xx>
+`)
+	sig := func(name, email string) object.Signature {
+		return object.Signature{Name: name, Email: email}
+	}
+	assert.Equal(t, 4, mailmap.Len())
+	assert.Equal(t, sig("Proper Name", "Commit@Email.xx"), mailmap.Map(sig("Whoever", "Commit@Email.xx")))
+	assert.Equal(t, sig("Other", "proper@email.xx"), mailmap.Map(sig("Other", "other@email.xx")))
+	assert.Equal(t, sig("Joe Developer", "joe@example.com"), mailmap.Map(sig("joe", "joe@laptop.(none)")))
+	assert.Equal(t, sig("Jane Doe", "jane@example.com"), mailmap.Map(sig("JANE", "bugs@example.com")))
+	assert.Equal(t, sig("Bug Fixer", "bugs@example.com"), mailmap.Map(sig("Someone", "bugs@example.com")))
+	assert.Equal(t, sig("Nobody", "empty@email.xx"), mailmap.Map(sig("Nobody", "empty@email.xx")))
+	assert.Equal(t, sig("Stranger", "stranger@email.xx"), mailmap.Map(sig("Stranger", "stranger@email.xx")))
+}
+
+func TestGeneratePeopleDictMailmap(t *testing.T) {
+	_, commits := test.NewRepository(
+		test.Commit{Author: "Jane", Email: "bugs@example.com"},
+		test.Commit{Author: "John", Email: "bugs@example.com"},
+		test.Commit{Author: "Jane Doe", Email: "jane@example.com"},
+		test.Commit{Author: "joe", Email: "joe@laptop.(none)", Files: map[string]string{
+			".mailmap": "Jane Doe <jane@example.com> Jane <bugs@example.com>\n" +
+				"Joe Developer <joe@example.com> <joe@laptop.(none)>\n"}})
+	detector := &Detector{}
+	detector.GeneratePeopleDict(commits)
+	assert.Equal(t, []string{
+		"jane doe|jane@example.com", "john|bugs@example.com", "joe developer|joe@example.com",
+	}, detector.ReversedPeopleDict)
+	assert.Equal(t, []MergeDecision{
+		{Developer: 0, Signature: "jane <bugs@example.com>", Rule: RuleMailmap, Via: "jane@example.com"},
+		{Developer: 2, Signature: "joe <joe@laptop.(none)>", Rule: RuleMailmap, Via: "joe@example.com"},
+	}, detector.MergeDecisions)
+	id, exists := detector.lookup(commits[0].Author)
+	assert.True(t, exists)
+	assert.Equal(t, 0, id)
+
+	detector = &Detector{}
+	assert.NoError(t, detector.Configure(map[string]interface{}{
+		core.ConfigPipelineCommits:      commits,
+		ConfigIdentityDetectorNoMailmap: true,
+	}))
+	assert.Nil(t, detector.Mailmap)
+	assert.Equal(t, []string{
+		"jane|john|bugs@example.com", "jane doe|jane@example.com", "joe|joe@laptop.(none)",
+	}, detector.ReversedPeopleDict)
+}
+
+func TestConfigureMailmapAnotherRepository(t *testing.T) {
+	_, first := test.NewRepository(test.Commit{Author: "joe", Email: "joe@laptop.(none)",
+		Files: map[string]string{".mailmap": "Joe Developer <joe@example.com> <joe@laptop.(none)>\n"}})
+	_, second := test.NewRepository(test.Commit{Author: "joe", Email: "joe@laptop.(none)"})
+	detector := &Detector{}
+	assert.NoError(t, detector.Configure(map[string]interface{}{core.ConfigPipelineCommits: first}))
+	assert.NotNil(t, detector.Mailmap)
+	assert.NoError(t, detector.Configure(map[string]interface{}{core.ConfigPipelineCommits: second}))
+	assert.Nil(t, detector.Mailmap)
+}
@@ -100,6 +100,12 @@ func (langs *LanguagesDetection) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(langs, n)
 }
 
+// ParallelForks returns true because LanguagesDetection is stateless.
+// It implements core.ParallelPipelineItem.
+func (langs *LanguagesDetection) ParallelForks() bool {
+	return true
+}
+
 // detectLanguage returns the programming language of a blob.
 func (langs *LanguagesDetection) detectLanguage(name string, blob *CachedBlob) string {
 	_, err := blob.CountLines()
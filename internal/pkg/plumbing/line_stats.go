@@ -160,6 +160,12 @@ func (lsc *LinesStatsCalculator) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(lsc, n)
 }
 
+// ParallelForks returns true because LinesStatsCalculator is stateless.
+// It implements core.ParallelPipelineItem.
+func (lsc *LinesStatsCalculator) ParallelForks() bool {
+	return true
+}
+
 func init() {
 	core.Registry.Register(&LinesStatsCalculator{})
 }
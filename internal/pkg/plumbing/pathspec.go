@@ -0,0 +1,190 @@
+package plumbing
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Pathspec is a git-style path pattern with the "glob" magic: "*" and "?" do not match "/",
+// "**" matches any number of directories, and a pattern which names a directory matches
+// everything inside it. Examples: "**/*.go", "cmd/", ":!vendor/**", ":(exclude,icase)*.PB.GO".
+type Pathspec struct {
+	// Pattern is the pathspec without the magic prefix.
+	Pattern string
+	// Exclude indicates that the matched paths must be skipped (":!", ":^" or ":(exclude)").
+	Exclude bool
+
+	regexp *regexp.Regexp
+}
+
+// Pathspecs is the list of included and excluded path patterns.
+type Pathspecs []*Pathspec
+
+// ParsePathspec parses the magic prefix of the pathspec and compiles the pattern.
+func ParsePathspec(spec string) (*Pathspec, error) {
+	pathspec := &Pathspec{Pattern: spec}
+	icase, literal := false, false
+	if strings.HasPrefix(spec, ":(") {
+		end := strings.IndexByte(spec, ')')
+		if end < 0 {
+			return nil, fmt.Errorf("pathspec %q: missing ')' at the end of the magic", spec)
+		}
+		for _, magic := range strings.Split(spec[2:end], ",") {
+			switch strings.TrimSpace(magic) {
+			case "exclude":
+				pathspec.Exclude = true
+			case "icase":
+				icase = true
+			case "literal":
+				literal = true
+			case "glob", "top", "":
+			default:
+				return nil, fmt.Errorf("pathspec %q: unsupported magic %q", spec, magic)
+			}
+		}
+		pathspec.Pattern = spec[end+1:]
+	} else if strings.HasPrefix(spec, ":") {
+		i := 1
+		for ; i < len(spec) && strings.IndexByte("!^/", spec[i]) >= 0; i++ {
+			if spec[i] != '/' {
+				pathspec.Exclude = true
+			}
+		}
+		if i < len(spec) && spec[i] == ':' {
+			i++
+		}
+		pathspec.Pattern = spec[i:]
+	}
+	pathspec.Pattern = strings.TrimPrefix(pathspec.Pattern, "/")
+	expr := globToRegexp(pathspec.Pattern, literal)
+	if icase {
+		expr = "(?i)" + expr
+	}
+	var err error
+	if pathspec.regexp, err = regexp.Compile(expr); err != nil {
+		return nil, fmt.Errorf("pathspec %q: %v", spec, err)
+	}
+	return pathspec, nil
+}
+
+// globToRegexp converts the glob pattern to the anchored regular expression.
+func globToRegexp(pattern string, literal bool) string {
+	dir := strings.HasSuffix(pattern, "/")
+	pattern = strings.TrimSuffix(pattern, "/")
+	builder := strings.Builder{}
+	builder.WriteByte('^')
+	if literal {
+		builder.WriteString(regexp.QuoteMeta(pattern))
+		pattern = ""
+	}
+	for i := 0; i < len(pattern); i++ {
+		char := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**") &&
+			(i == 0 || pattern[i-1] == '/') && (i+2 == len(pattern) || pattern[i+2] == '/'):
+			if i+2 == len(pattern) {
+				builder.WriteString(".*")
+			} else {
+				// "**/" matches zero or more directories
+				builder.WriteString("(?:.*/)?")
+			}
+			i += 2
+		case char == '*':
+			builder.WriteString("[^/]*")
+		case char == '?':
+			builder.WriteString("[^/]")
+		case char == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				builder.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			builder.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case char == '\\' && i+1 < len(pattern):
+			i++
+			builder.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			builder.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	if dir {
+		builder.WriteString("/")
+	} else if pattern != "" || literal {
+		builder.WriteString("(?:$|/)")
+	}
+	return builder.String()
+}
+
+// Match returns true if the path matches the pattern, regardless of Exclude.
+func (spec *Pathspec) Match(name string) bool {
+	return spec.regexp.MatchString(name)
+}
+
+// String returns the pathspec with the magic.
+func (spec *Pathspec) String() string {
+	if spec.Exclude {
+		return ":!" + spec.Pattern
+	}
+	return spec.Pattern
+}
+
+// ParsePathspecs parses each pathspec with ParsePathspec().
+func ParsePathspecs(specs []string) (Pathspecs, error) {
+	var result Pathspecs
+	for _, spec := range specs {
+		if spec = strings.TrimSpace(spec); spec == "" {
+			continue
+		}
+		pathspec, err := ParsePathspec(spec)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, pathspec)
+	}
+	return result, nil
+}
+
+// Match returns true if the path matches at least one included pathspec and none of the
+// excluded ones. If there are no included pathspecs, every path is included, like in git.
+func (specs Pathspecs) Match(name string) bool {
+	included, hasIncluded := false, false
+	for _, spec := range specs {
+		if spec.Exclude {
+			if spec.Match(name) {
+				return false
+			}
+			continue
+		}
+		hasIncluded = true
+		included = included || spec.Match(name)
+	}
+	return included || !hasIncluded
+}
+
+// ReadPathspecs reads the pathspecs from the file, one per line. The empty lines and
+// the lines which start with "#" are ignored.
+func ReadPathspecs(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var specs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		specs = append(specs, line)
+	}
+	return specs, scanner.Err()
+}
@@ -0,0 +1,77 @@
+package plumbing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePathspec(t *testing.T) {
+	for spec, expected := range map[string]Pathspec{
+		"**/*.go":                 {Pattern: "**/*.go"},
+		"/cmd":                    {Pattern: "cmd"},
+		":!vendor/**":             {Pattern: "vendor/**", Exclude: true},
+		":^vendor":                {Pattern: "vendor", Exclude: true},
+		":/!:docs":                {Pattern: "docs", Exclude: true},
+		":/docs":                  {Pattern: "docs"},
+		":(exclude,icase)*.PB.GO": {Pattern: "*.PB.GO", Exclude: true},
+		":(glob,top)a/*":          {Pattern: "a/*"},
+	} {
+		pathspec, err := ParsePathspec(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, expected.Pattern, pathspec.Pattern, spec)
+		assert.Equal(t, expected.Exclude, pathspec.Exclude, spec)
+	}
+	for _, spec := range []string{":(exclude", ":(attr:x)*.go", "a[b"} {
+		_, err := ParsePathspec(spec)
+		if spec == "a[b" {
+			assert.NoError(t, err)
+		} else {
+			assert.Error(t, err, spec)
+		}
+	}
+}
+
+func TestPathspecMatch(t *testing.T) {
+	for spec, cases := range map[string]map[string]bool{
+		"**/*.go":             {"main.go": true, "a/b/c.go": true, "a/b/c.go.txt": false, "go": false},
+		"*.go":                {"main.go": true, "a/main.go": false},
+		"cmd":                 {"cmd": true, "cmd/main.go": true, "cmdline.go": false, "a/cmd/x": false},
+		"cmd/":                {"cmd": false, "cmd/main.go": true},
+		"a/**":                {"a/b": true, "a/b/c": true, "b/a/c": false},
+		"a/**/z":              {"a/z": true, "a/b/c/z": true, "a/bz": false},
+		"a?c":                 {"abc": true, "a/c": false},
+		"[!x]y.*":             {"ay.go": true, "xy.go": false},
+		`a\*b`:                {"a*b": true, "axb": false},
+		":(icase)*.PB.GO":     {"api.pb.go": true, "api.go": false},
+		":(literal)*.go":      {"*.go": true, "a.go": false},
+		"**/mock_*.go":        {"mocks/mock_api.go": true, "mock_api.go": true, "mocks/api.go": false},
+		":(glob)internal/**/": {"internal/a/b.go": true, "internal": false},
+	} {
+		pathspec, err := ParsePathspec(spec)
+		assert.NoError(t, err, spec)
+		for name, matched := range cases {
+			assert.Equal(t, matched, pathspec.Match(name), "%s ~ %s", spec, name)
+		}
+	}
+}
+
+func TestPathspecsMatch(t *testing.T) {
+	pathspecs, err := ParsePathspecs([]string{"**/*.go", " ", ":!vendor/", ":!**/*.pb.go"})
+	assert.NoError(t, err)
+	assert.Len(t, pathspecs, 3)
+	assert.True(t, pathspecs.Match("main.go"))
+	assert.True(t, pathspecs.Match("api/api.go"))
+	assert.False(t, pathspecs.Match("api/api.pb.go"))
+	assert.False(t, pathspecs.Match("vendor/lib.go"))
+	assert.False(t, pathspecs.Match("README.md"))
+
+	pathspecs, err = ParsePathspecs([]string{":!vendor"})
+	assert.NoError(t, err)
+	assert.True(t, pathspecs.Match("README.md"))
+	assert.False(t, pathspecs.Match("vendor/lib.go"))
+	assert.True(t, Pathspecs(nil).Match("README.md"))
+
+	_, err = ParsePathspecs([]string{"*.go", ":(bad)x"})
+	assert.Error(t, err)
+}
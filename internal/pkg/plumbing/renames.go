@@ -32,6 +32,12 @@ type RenameAnalysis struct {
 	// Timeout is the maximum time allowed to spend computing renames in a single commit.
 	Timeout time.Duration
 
+	// Copies enables the detection of the new files which were copied from the existing files.
+	Copies bool
+
+	// Splits enables the detection of the files which were split into several or joined together.
+	Splits bool
+
 	repository *git.Repository
 
 	l core.Logger
@@ -55,6 +61,14 @@ const (
 	// computing renames in a single commit.
 	ConfigRenameAnalysisTimeout = "RenameAnalysis.Timeout"
 
+	// ConfigRenameAnalysisCopies is the name of the configuration option
+	// (RenameAnalysis.Configure()) which enables the copies detection.
+	ConfigRenameAnalysisCopies = "RenameAnalysis.Copies"
+
+	// ConfigRenameAnalysisSplits is the name of the configuration option
+	// (RenameAnalysis.Configure()) which enables the splits and joins detection.
+	ConfigRenameAnalysisSplits = "RenameAnalysis.Splits"
+
 	// RenameAnalysisMinimumSize is the minimum size of a blob to be considered.
 	RenameAnalysisMinimumSize = 32
 
@@ -79,7 +93,7 @@ func (ra *RenameAnalysis) Name() string {
 // Each produced entity will be inserted into `deps` of dependent Consume()-s according
 // to this list. Also used by core.Registry to build the global map of providers.
 func (ra *RenameAnalysis) Provides() []string {
-	return []string{DependencyTreeChanges}
+	return []string{DependencyTreeChanges, DependencyFileOrigins}
 }
 
 // Requires returns the list of names of entities which are needed by this PipelineItem.
@@ -102,7 +116,19 @@ func (ra *RenameAnalysis) ListConfigurationOptions() []core.ConfigurationOption
 			"renames in a single commit. 0 sets the default.",
 		Flag:    "renames-timeout",
 		Type:    core.IntConfigurationOption,
-		Default: RenameAnalysisDefaultTimeout},
+		Default: RenameAnalysisDefaultTimeout}, {
+		Name: ConfigRenameAnalysisCopies,
+		Description: "Detect the new files which were copied from the existing files, " +
+			"like git's -C.",
+		Flag:    "C",
+		Type:    core.BoolConfigurationOption,
+		Default: false}, {
+		Name: ConfigRenameAnalysisSplits,
+		Description: "Detect the files which were split into several files or joined " +
+			"into one.",
+		Flag:    "splits",
+		Type:    core.BoolConfigurationOption,
+		Default: false},
 	}
 	return options[:]
 }
@@ -121,6 +147,12 @@ func (ra *RenameAnalysis) Configure(facts map[string]interface{}) error {
 		}
 		ra.Timeout = time.Duration(val) * time.Millisecond
 	}
+	if val, exists := facts[ConfigRenameAnalysisCopies].(bool); exists {
+		ra.Copies = val
+	}
+	if val, exists := facts[ConfigRenameAnalysisSplits].(bool); exists {
+		ra.Splits = val
+	}
 	return nil
 }
 
@@ -398,7 +430,13 @@ func (ra *RenameAnalysis) Consume(deps map[string]interface{}) (map[string]inter
 	for _, change := range smallChanges {
 		reducedChanges = append(reducedChanges, change)
 	}
-	return map[string]interface{}{DependencyTreeChanges: reducedChanges}, nil
+	commit, _ := deps[core.DependencyCommit].(*object.Commit)
+	origins, err := ra.detectOrigins(reducedChanges, cache, commit, beginTime)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		DependencyTreeChanges: reducedChanges, DependencyFileOrigins: origins}, nil
 }
 
 // Fork clones this PipelineItem.
@@ -406,6 +444,12 @@ func (ra *RenameAnalysis) Fork(n int) []core.PipelineItem {
 	return core.ForkSamePipelineItem(ra, n)
 }
 
+// ParallelForks returns true because RenameAnalysis is stateless.
+// It implements core.ParallelPipelineItem.
+func (ra *RenameAnalysis) ParallelForks() bool {
+	return true
+}
+
 func (ra *RenameAnalysis) sizesAreClose(size1 int64, size2 int64) bool {
 	size := mathutil.Max64(1, mathutil.Max64(size1, size2))
 	return (mathutil.Abs64(size1-size2)*10000)/size <= int64(100-ra.SimilarityThreshold)*100
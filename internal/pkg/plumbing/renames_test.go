@@ -26,23 +26,30 @@ func fixtureRenameAnalysis() *RenameAnalysis {
 func TestRenameAnalysisMeta(t *testing.T) {
 	ra := fixtureRenameAnalysis()
 	assert.Equal(t, ra.Name(), "RenameAnalysis")
-	assert.Equal(t, len(ra.Provides()), 1)
+	assert.Equal(t, len(ra.Provides()), 2)
 	assert.Equal(t, ra.Provides()[0], DependencyTreeChanges)
+	assert.Equal(t, ra.Provides()[1], DependencyFileOrigins)
 	assert.Equal(t, len(ra.Requires()), 2)
 	assert.Equal(t, ra.Requires()[0], DependencyBlobCache)
 	assert.Equal(t, ra.Requires()[1], DependencyTreeChanges)
 	opts := ra.ListConfigurationOptions()
-	assert.Len(t, opts, 2)
+	assert.Len(t, opts, 4)
 	assert.Equal(t, opts[0].Name, ConfigRenameAnalysisSimilarityThreshold)
 	assert.Equal(t, opts[1].Name, ConfigRenameAnalysisTimeout)
+	assert.Equal(t, opts[2].Name, ConfigRenameAnalysisCopies)
+	assert.Equal(t, opts[3].Name, ConfigRenameAnalysisSplits)
 	ra.SimilarityThreshold = 0
 
 	assert.NoError(t, ra.Configure(map[string]interface{}{
 		ConfigRenameAnalysisSimilarityThreshold: 70,
 		ConfigRenameAnalysisTimeout:             1000,
+		ConfigRenameAnalysisCopies:              true,
+		ConfigRenameAnalysisSplits:              true,
 	}))
 	assert.Equal(t, ra.SimilarityThreshold, 70)
 	assert.Equal(t, ra.Timeout, time.Second)
+	assert.True(t, ra.Copies)
+	assert.True(t, ra.Splits)
 
 	logger := core.GetLogger()
 	assert.NoError(t, ra.Configure(map[string]interface{}{
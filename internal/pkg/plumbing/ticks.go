@@ -1,6 +1,8 @@
 package plumbing
 
 import (
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/dmytrogajewski/hercules/internal/app/core"
@@ -163,6 +165,48 @@ func (ticks *TicksSinceStart) Fork(n int) []core.PipelineItem {
 	return core.ForkCopyPipelineItem(ticks, n)
 }
 
+// ticksCheckpoint is the state of TicksSinceStart saved in core.Checkpoint.
+type ticksCheckpoint struct {
+	TickSize     time.Duration
+	Tick0        time.Time
+	PreviousTick int
+	Commits      map[int][]string
+}
+
+// Checkpoint writes the tick origin and the commits by tick.
+// It implements core.CheckpointablePipelineItem.
+func (ticks *TicksSinceStart) Checkpoint(writer io.Writer) error {
+	state := ticksCheckpoint{
+		TickSize:     ticks.TickSize,
+		Tick0:        *ticks.tick0,
+		PreviousTick: ticks.previousTick,
+		Commits:      make(map[int][]string, len(ticks.commits)),
+	}
+	for tick, hashes := range ticks.commits {
+		state.Commits[tick] = core.HashesToStrings(hashes)
+	}
+	return core.EncodeCheckpoint(writer, state)
+}
+
+// Restore loads the state written by Checkpoint(). The tick size must not change between
+// the runs. It implements core.CheckpointablePipelineItem.
+func (ticks *TicksSinceStart) Restore(reader io.Reader) error {
+	state := ticksCheckpoint{}
+	if err := core.DecodeCheckpoint(reader, &state); err != nil {
+		return err
+	}
+	if state.TickSize != ticks.TickSize {
+		return fmt.Errorf("the tick size changed from %s to %s", state.TickSize, ticks.TickSize)
+	}
+	*ticks.tick0 = state.Tick0
+	ticks.previousTick = state.PreviousTick
+	// FactCommitsByTick refers to the existing map
+	for tick, hashes := range state.Commits {
+		ticks.commits[tick] = core.StringsToHashes(hashes)
+	}
+	return nil
+}
+
 // FloorTime is the missing implementation of time.Time.Floor() - round to the nearest less than or equal.
 func FloorTime(t time.Time, d time.Duration) time.Time {
 	// We have check if the regular rounding resulted in Floor() + d.
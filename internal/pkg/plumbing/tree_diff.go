@@ -25,6 +25,13 @@ type TreeDiff struct {
 	// Languages is the set of allowed languages. The values must be lower case. The default
 	// (empty) set disables the language filter.
 	Languages map[string]bool
+	// Pathspecs include and exclude the files with git-style globs.
+	Pathspecs Pathspecs
+	// Attributes are read from .gitattributes in the last analysed commit. The generated,
+	// vendored and non-diffable files are skipped.
+	Attributes *Attributes
+	// NoGitattributes disables reading Attributes.
+	NoGitattributes bool
 
 	previousTree   *object.Tree
 	previousCommit plumbing.Hash
@@ -54,6 +61,20 @@ const (
 	// ConfigTreeDiffFilterRegexp is the name of the configuration option
 	// (TreeDiff.Configure()) which makes FileDiff consider only those files which have names matching this regexp.
 	ConfigTreeDiffFilterRegexp = "TreeDiff.FilteredRegexes"
+
+	// ConfigTreeDiffPathspecs is the name of the configuration option (TreeDiff.Configure())
+	// which sets the git-style pathspecs to include and exclude the files, e.g. "**/*.go"
+	// and ":!vendor/**".
+	ConfigTreeDiffPathspecs = "TreeDiff.Pathspecs"
+
+	// ConfigTreeDiffPathspecsFile is the name of the configuration option (TreeDiff.Configure())
+	// which sets the path to the file with the pathspecs, one per line.
+	ConfigTreeDiffPathspecsFile = "TreeDiff.PathspecsFile"
+
+	// ConfigTreeDiffNoGitattributes is the name of the configuration option
+	// (TreeDiff.Configure()) which disables the linguist-generated, linguist-vendored
+	// and -diff attributes from .gitattributes.
+	ConfigTreeDiffNoGitattributes = "TreeDiff.NoGitattributes"
 )
 
 // defaultBlacklistedPrefixes is the list of file path prefixes which should be skipped by default.
@@ -115,7 +136,28 @@ func (treediff *TreeDiff) ListConfigurationOptions() []core.ConfigurationOption
 		Description: "Whitelist regexp to determine which files to analyze.",
 		Flag:        "whitelist",
 		Type:        core.StringConfigurationOption,
-		Default:     ""},
+		Default:     ""}, {
+
+		Name: ConfigTreeDiffPathspecs,
+		Description: "Git-style pathspecs which include or exclude the files, e.g. \"**/*.go\" " +
+			"or \":!vendor/**\". \"*\" does not match \"/\" and \"**\" matches any directories. " +
+			"Repeatable or separated with commas \",\".",
+		Flag:    "pathspec",
+		Type:    core.StringsConfigurationOption,
+		Default: []string{}}, {
+
+		Name:        ConfigTreeDiffPathspecsFile,
+		Description: "Read the pathspecs from this file, one per line; \"#\" starts a comment.",
+		Flag:        "pathspec-from-file",
+		Type:        core.PathConfigurationOption,
+		Default:     ""}, {
+
+		Name: ConfigTreeDiffNoGitattributes,
+		Description: "Do not skip the files marked as linguist-generated, linguist-vendored " +
+			"or -diff in .gitattributes.",
+		Flag:    "no-gitattributes",
+		Type:    core.BoolConfigurationOption,
+		Default: false},
 	}
 	return options[:]
 }
@@ -141,6 +183,36 @@ func (treediff *TreeDiff) Configure(facts map[string]interface{}) error {
 	if val, exists := facts[ConfigTreeDiffFilterRegexp].(string); exists {
 		treediff.NameFilter = regexp.MustCompile(val)
 	}
+	var specs []string
+	if val, exists := facts[ConfigTreeDiffPathspecs].([]string); exists {
+		specs = append(specs, val...)
+	}
+	if val, exists := facts[ConfigTreeDiffPathspecsFile].(string); exists && val != "" {
+		fileSpecs, err := ReadPathspecs(val)
+		if err != nil {
+			return fmt.Errorf("failed to read the pathspecs: %v", err)
+		}
+		specs = append(specs, fileSpecs...)
+	}
+	// the filters of the previous run must not leak if the item is configured again
+	pathspecs, err := ParsePathspecs(specs)
+	if err != nil {
+		return err
+	}
+	treediff.Pathspecs = pathspecs
+	if val, exists := facts[ConfigTreeDiffNoGitattributes].(bool); exists {
+		treediff.NoGitattributes = val
+	}
+	treediff.Attributes = nil
+	if commits, exists := facts[core.ConfigPipelineCommits].([]*object.Commit); exists &&
+		len(commits) > 0 && !treediff.NoGitattributes {
+		// the last commit is HEAD by default, so its .gitattributes are the current ones
+		attributes, err := ReadAttributes(commits[len(commits)-1])
+		if err != nil {
+			return fmt.Errorf("failed to read .gitattributes: %v", err)
+		}
+		treediff.Attributes = attributes
+	}
 	return nil
 }
 
@@ -149,6 +221,7 @@ func (treediff *TreeDiff) Configure(facts map[string]interface{}) error {
 func (treediff *TreeDiff) Initialize(repository *git.Repository) error {
 	treediff.l = core.GetLogger()
 	treediff.previousTree = nil
+	treediff.previousCommit = plumbing.ZeroHash
 	treediff.repository = repository
 	if treediff.Languages == nil {
 		treediff.Languages = map[string]bool{}
@@ -226,7 +299,18 @@ func (treediff *TreeDiff) filterDiffs(diffs object.Changes) object.Changes {
 	filteredDiffs := make(object.Changes, 0, len(diffs))
 OUTER:
 	for _, change := range diffs {
-		if len(treediff.SkipFiles) > 0 && (enry.IsVendor(change.To.Name) || enry.IsVendor(change.From.Name)) {
+		var changeEntry object.ChangeEntry
+		if change.To.Tree == nil {
+			changeEntry = change.From
+		} else {
+			changeEntry = change.To
+		}
+		skip, notVendored := treediff.Attributes.Skip(changeEntry.Name)
+		if skip || !treediff.Pathspecs.Match(changeEntry.Name) {
+			continue
+		}
+		if len(treediff.SkipFiles) > 0 && !notVendored &&
+			(enry.IsVendor(change.To.Name) || enry.IsVendor(change.From.Name)) {
 			continue
 		}
 		for _, dir := range treediff.SkipFiles {
@@ -242,12 +326,6 @@ OUTER:
 				continue
 			}
 		}
-		var changeEntry object.ChangeEntry
-		if change.To.Tree == nil {
-			changeEntry = change.From
-		} else {
-			changeEntry = change.To
-		}
 		if pass, _ := treediff.checkLanguage(changeEntry.Name, changeEntry.TreeEntry.Hash); !pass {
 			continue
 		}
@@ -256,9 +334,55 @@ OUTER:
 	return filteredDiffs
 }
 
-// Fork clones this PipelineItem.
+// Fork clones this PipelineItem. The clones load their own copies of the previous tree
+// because object.Tree lazily builds internal indexes and is not safe for concurrent use.
 func (treediff *TreeDiff) Fork(n int) []core.PipelineItem {
-	return core.ForkCopyPipelineItem(treediff, n)
+	clones := core.ForkCopyPipelineItem(treediff, n)
+	if treediff.previousTree == nil || treediff.repository == nil {
+		return clones
+	}
+	for _, clone := range clones {
+		tree, err := treediff.repository.TreeObject(treediff.previousTree.Hash)
+		if err != nil {
+			// the shared tree is still correct in the sequential mode
+			treediff.l.Warnf("failed to load tree %s: %v\n", treediff.previousTree.Hash, err)
+			continue
+		}
+		clone.(*TreeDiff).previousTree = tree
+	}
+	return clones
+}
+
+// ParallelForks returns true because every fork tracks its own previous tree.
+// It implements core.ParallelPipelineItem.
+func (treediff *TreeDiff) ParallelForks() bool {
+	return true
+}
+
+// Checkpoint writes the last analysed commit. It implements core.CheckpointablePipelineItem.
+func (treediff *TreeDiff) Checkpoint(writer io.Writer) error {
+	return core.EncodeCheckpoint(writer, treediff.previousCommit.String())
+}
+
+// Restore loads the last analysed commit and its tree so that the next Consume()
+// continues the diff chain. It implements core.CheckpointablePipelineItem.
+func (treediff *TreeDiff) Restore(reader io.Reader) error {
+	var hex string
+	if err := core.DecodeCheckpoint(reader, &hex); err != nil {
+		return err
+	}
+	hash := plumbing.NewHash(hex)
+	treediff.previousCommit = hash
+	treediff.previousTree = nil
+	if hash == plumbing.ZeroHash {
+		return nil
+	}
+	commit, err := treediff.repository.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	treediff.previousTree, err = commit.Tree()
+	return err
 }
 
 // checkLanguage returns whether the blob corresponds to the list of required languages.
@@ -1,6 +1,9 @@
 package plumbing
 
 import (
+	"os"
+	"path/filepath"
+	"sort"
 	"testing"
 
 	"github.com/dmytrogajewski/hercules/internal/app/core"
@@ -25,7 +28,7 @@ func TestTreeDiffMeta(t *testing.T) {
 	assert.Equal(t, len(td.Provides()), 1)
 	assert.Equal(t, td.Provides()[0], DependencyTreeChanges)
 	opts := td.ListConfigurationOptions()
-	assert.Len(t, opts, 4)
+	assert.Len(t, opts, 7)
 	logger := core.GetLogger()
 	assert.NoError(t, td.Configure(map[string]interface{}{
 		core.ConfigLogger: logger,
@@ -261,6 +264,25 @@ func TestTreeDiffFork(t *testing.T) {
 	td1.Merge([]core.PipelineItem{td2})
 }
 
+func TestTreeDiffForkParallel(t *testing.T) {
+	repository, commits := test.NewRepository(
+		test.Commit{Files: map[string]string{"a.txt": "a\n"}})
+	td1 := &TreeDiff{}
+	assert.NoError(t, td1.Configure(nil))
+	assert.NoError(t, td1.Initialize(repository))
+	_, err := td1.Consume(map[string]interface{}{core.DependencyCommit: commits[0]})
+	assert.NoError(t, err)
+	assert.True(t, td1.ParallelForks())
+	clones := td1.Fork(2)
+	assert.Len(t, clones, 2)
+	for _, clone := range clones {
+		td2 := clone.(*TreeDiff)
+		assert.False(t, td1.previousTree == td2.previousTree)
+		assert.Equal(t, td1.previousTree.Hash, td2.previousTree.Hash)
+		assert.Equal(t, td1.previousCommit, td2.previousCommit)
+	}
+}
+
 func TestTreeDiffCheckLanguage(t *testing.T) {
 	td := fixtureTreeDiff()
 	lang, err := td.checkLanguage(
@@ -304,3 +326,72 @@ func TestTreeDiffCheckLanguageEmpty(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, lang)
 }
+
+func TestTreeDiffConfigurePathspecs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pathspecs")
+	assert.NoError(t, os.WriteFile(path, []byte("# generated\n:!**/*.pb.go\n\n"), 0o644))
+	td := fixtureTreeDiff()
+	assert.NoError(t, td.Configure(map[string]interface{}{
+		ConfigTreeDiffPathspecs:     []string{"**/*.go", ":!vendor/"},
+		ConfigTreeDiffPathspecsFile: path,
+	}))
+	assert.Len(t, td.Pathspecs, 3)
+	assert.Equal(t, ":!**/*.pb.go", td.Pathspecs[2].String())
+	// the reused item forgets the previous filters
+	td.Attributes = &Attributes{}
+	assert.NoError(t, td.Configure(map[string]interface{}{}))
+	assert.Nil(t, td.Pathspecs)
+	assert.Nil(t, td.Attributes)
+	assert.Error(t, td.Configure(map[string]interface{}{
+		ConfigTreeDiffPathspecs: []string{":(attr:x)*.go"}}))
+	assert.Error(t, td.Configure(map[string]interface{}{
+		ConfigTreeDiffPathspecsFile: path + ".missing"}))
+}
+
+func TestTreeDiffConsumePathspecsAndAttributes(t *testing.T) {
+	files := map[string]string{
+		"main.go":                   "package main\n",
+		"README.md":                 "# readme\n",
+		"api/api.pb.go":             "package api\n",
+		"api/api.go":                "package api\n",
+		"mocks/mock_api.go":         "package mocks\n",
+		"vendor/lib/lib.go":         "package lib\n",
+		"third_party/x/x.go":        "package x\n",
+		"assets/logo.svg":           "<svg/>\n",
+		"assets/.gitattributes":     "*.svg binary\n",
+		"mocks/keep/keep.go":        "package keep\n",
+		"mocks/keep/.gitattributes": "*.go -linguist-generated\n",
+		".gitattributes": "*.pb.go linguist-generated\nmocks/** linguist-generated=true\n" +
+			"third_party/** -linguist-vendored\n",
+	}
+	repository, commits := test.NewRepository(test.Commit{Files: files})
+	consume := func(facts map[string]interface{}) []string {
+		td := &TreeDiff{}
+		facts[core.ConfigPipelineCommits] = commits
+		assert.NoError(t, td.Configure(facts))
+		assert.NoError(t, td.Initialize(repository))
+		result, err := td.Consume(map[string]interface{}{core.DependencyCommit: commits[0]})
+		assert.NoError(t, err)
+		var names []string
+		for _, change := range result[DependencyTreeChanges].(object.Changes) {
+			names = append(names, change.To.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	assert.Equal(t, []string{
+		".gitattributes", "README.md", "api/api.go", "assets/.gitattributes", "main.go",
+		"mocks/keep/keep.go", "third_party/x/x.go", "vendor/lib/lib.go",
+	}, consume(map[string]interface{}{}))
+	assert.Len(t, consume(map[string]interface{}{ConfigTreeDiffNoGitattributes: true}), len(files))
+	assert.Equal(t, []string{"api/api.go", "main.go", "mocks/keep/keep.go", "third_party/x/x.go"},
+		consume(map[string]interface{}{
+			ConfigTreeDiffEnableBlacklist:     true,
+			ConfigTreeDiffBlacklistedPrefixes: []string{"docs/"},
+			ConfigTreeDiffPathspecs:           []string{"**/*.go"},
+		}))
+	assert.Equal(t, []string{"main.go", "mocks/keep/keep.go"},
+		consume(map[string]interface{}{
+			ConfigTreeDiffPathspecs: []string{"*.go", "mocks", ":!third_party/", ":^mocks/*.go"},
+		}))
+}
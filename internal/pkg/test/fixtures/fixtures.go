@@ -1,21 +1,13 @@
 package fixtures
 
 import (
-	"github.com/sergi/go-diff/diffmatchpatch"
+	"github.com/dmytrogajewski/hercules/internal/pkg/plumbing"
+	"github.com/dmytrogajewski/hercules/internal/pkg/test"
 )
 
-// FileDiff represents a file difference for testing
-type FileDiff struct {
-	OldLinesOfCode int
-	NewLinesOfCode int
-	Diffs          []diffmatchpatch.Diff
-}
-
-// NewFileDiff returns a new FileDiff instance for testing
-func NewFileDiff() *FileDiff {
-	return &FileDiff{
-		OldLinesOfCode: 0,
-		NewLinesOfCode: 0,
-		Diffs:          []diffmatchpatch.Diff{},
-	}
+// FileDiff initializes a new plumbing.FileDiff item for testing.
+func FileDiff() *plumbing.FileDiff {
+	fd := &plumbing.FileDiff{}
+	fd.Initialize(test.Repository)
+	return fd
 }
@@ -1,7 +1,14 @@
 package test
 
 import (
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v6/memfs"
+	"github.com/go-git/go-billy/v6/util"
 	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/storage/memory"
 )
 
@@ -16,3 +23,138 @@ func init() {
 		panic(err)
 	}
 }
+
+// FixtureCommit returns the commit from Repository. It stops the test instead of returning nil
+// if the commit does not exist: Repository is empty unless it is replaced with a clone of
+// the hercules history.
+func FixtureCommit(t testing.TB, hash string) *object.Commit {
+	t.Helper()
+	commit, err := Repository.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		t.Fatalf("fixture commit %s: %v", hash, err)
+	}
+	return commit
+}
+
+// FixtureCommits returns all the commits in Repository. It stops the test if there are none.
+func FixtureCommits(t testing.TB) []*object.Commit {
+	t.Helper()
+	iter, err := Repository.CommitObjects()
+	if err != nil {
+		t.Fatalf("fixture commits: %v", err)
+	}
+	var commits []*object.Commit
+	if err = iter.ForEach(func(commit *object.Commit) error {
+		commits = append(commits, commit)
+		return nil
+	}); err != nil {
+		t.Fatalf("fixture commits: %v", err)
+	}
+	if len(commits) == 0 {
+		t.Fatal("fixture commits: the test repository is empty")
+	}
+	return commits
+}
+
+// Commit describes a commit created by NewRepository().
+type Commit struct {
+	// Files maps the paths to the new contents. An empty string deletes the file.
+	Files map[string]string
+	// Author defaults to "Test <test@example.com>".
+	Author string
+	// Email defaults to "test@example.com".
+	Email string
+	// Message defaults to "commit".
+	Message string
+	// When defaults to 2020-01-01 plus one day per commit.
+	When time.Time
+	// Parents are the indices of the parent commits among the previous ones. The files are
+	// changed relative to the first parent. Defaults to the previous commit.
+	Parents []int
+}
+
+// NewRepository creates an in-memory repository with the history of the specified
+// commits, linear unless Commit.Parents are set. It returns the repository and the created commits in the chronological order.
+func NewRepository(commits ...Commit) (*git.Repository, []*object.Commit) {
+	repository, err := git.Init(memory.NewStorage(), git.WithWorkTree(memfs.New()))
+	if err != nil {
+		panic(err)
+	}
+	return repository, commitHistory(repository, commits)
+}
+
+// NewFilesystemRepository is NewRepository which creates the repository in the directory.
+// Unlike the in-memory storage, the filesystem storage reads the objects from the shared
+// pack and object files.
+func NewFilesystemRepository(dir string, commits ...Commit) (*git.Repository, []*object.Commit) {
+	repository, err := git.PlainInit(dir, false)
+	if err != nil {
+		panic(err)
+	}
+	return repository, commitHistory(repository, commits)
+}
+
+// commitHistory creates the commits in the repository with a worktree.
+func commitHistory(repository *git.Repository, commits []Commit) []*object.Commit {
+	worktree, err := repository.Worktree()
+	if err != nil {
+		panic(err)
+	}
+	fs := worktree.Filesystem
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	result := make([]*object.Commit, 0, len(commits))
+	for i, commit := range commits {
+		var parents []plumbing.Hash
+		if len(commit.Parents) > 0 {
+			for _, parent := range commit.Parents {
+				parents = append(parents, result[parent].Hash)
+			}
+			if err = worktree.Checkout(&git.CheckoutOptions{Hash: parents[0], Force: true}); err != nil {
+				panic(err)
+			}
+		}
+		for path, contents := range commit.Files {
+			if contents == "" {
+				if _, err = worktree.Remove(path); err != nil {
+					panic(err)
+				}
+				continue
+			}
+			if err = util.WriteFile(fs, path, []byte(contents), 0644); err != nil {
+				panic(err)
+			}
+			if _, err = worktree.Add(path); err != nil {
+				panic(err)
+			}
+		}
+		signature := &object.Signature{
+			Name:  commit.Author,
+			Email: commit.Email,
+			When:  commit.When,
+		}
+		if signature.Name == "" {
+			signature.Name = "Test"
+		}
+		if signature.Email == "" {
+			signature.Email = "test@example.com"
+		}
+		if signature.When.IsZero() {
+			signature.When = start.AddDate(0, 0, i)
+		}
+		message := commit.Message
+		if message == "" {
+			message = "commit"
+		}
+		hash, err := worktree.Commit(message, &git.CommitOptions{
+			Author: signature, Committer: signature, Parents: parents, AllowEmptyCommits: true})
+		if err != nil {
+			panic(err)
+		}
+		obj, err := repository.CommitObject(hash)
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, obj)
+	}
+	return result
+}
@@ -0,0 +1,245 @@
+// Package auth authenticates the clients of the HTTP and gRPC servers by their API keys,
+// checks the scopes of the keys, enforces their quotas and restricts the analysed repositories.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/pkg/config"
+)
+
+// Scope grants access to a group of the server operations.
+type Scope string
+
+const (
+	// ScopePublic marks the operations which do not require an API key, e.g. health checks.
+	ScopePublic Scope = ""
+	// ScopeSubmit allows submitting analyses.
+	ScopeSubmit Scope = "submit"
+	// ScopeRead allows listing the analysis types and reading the status and the results of the jobs.
+	ScopeRead Scope = "read"
+	// ScopeCancel allows cancelling the jobs.
+	ScopeCancel Scope = "cancel"
+	// ScopeMetrics allows reading the server metrics.
+	ScopeMetrics Scope = "metrics"
+	// ScopeAdmin implies all the other scopes and gives access to the jobs of every key.
+	ScopeAdmin Scope = "admin"
+)
+
+var (
+	// ErrUnauthenticated is returned when the API key is missing or unknown.
+	ErrUnauthenticated = errors.New("missing or invalid API key")
+	// ErrForbidden is returned when the API key lacks the scope of the operation.
+	ErrForbidden = errors.New("API key is not allowed to perform this operation")
+	// ErrRateLimited is returned when the API key submits analyses too often.
+	ErrRateLimited = errors.New("API key rate limit exceeded")
+)
+
+// Principal is the owner of an authenticated API key. All the methods of a nil *Principal,
+// which stands for the disabled authentication, allow everything.
+type Principal struct {
+	// Name is the name of the API key which is the tenant of the submitted jobs.
+	Name string
+	// MaxConcurrent limits the queued and running jobs of the key. 0 means no limit.
+	MaxConcurrent int
+
+	scopes  map[Scope]bool
+	limiter *rateLimiter
+}
+
+// Tenant returns the name of the key or an empty string if the authentication is disabled.
+func (p *Principal) Tenant() string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
+
+// Can returns true if the key is granted the scope.
+func (p *Principal) Can(scope Scope) bool {
+	return p == nil || scope == ScopePublic || p.scopes[ScopeAdmin] || p.scopes[scope]
+}
+
+// Owns returns true if the key may access the jobs of the tenant.
+func (p *Principal) Owns(tenant string) bool {
+	return p == nil || p.scopes[ScopeAdmin] || p.Name == tenant
+}
+
+// Concurrency returns the limit of the queued and running jobs of the key, 0 means no limit.
+func (p *Principal) Concurrency() int {
+	if p == nil {
+		return 0
+	}
+	return p.MaxConcurrent
+}
+
+// AllowSubmit consumes the rate quota of the key and returns ErrRateLimited if it is exhausted.
+func (p *Principal) AllowSubmit() error {
+	if p == nil || p.limiter == nil {
+		return nil
+	}
+	if !p.limiter.allow(time.Now()) {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// Authenticator maps the API keys from the configuration to their principals.
+// A nil *Authenticator accepts every request and returns nil principals.
+type Authenticator struct {
+	// principals are indexed by the SHA-256 of the keys, so the lookup does not leak
+	// the keys through timing.
+	principals map[[sha256.Size]byte]*Principal
+}
+
+// NewAuthenticator creates the Authenticator from the configuration. It returns nil
+// if the authentication is disabled.
+func NewAuthenticator(cfg config.AuthConfig) (*Authenticator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	a := &Authenticator{principals: map[[sha256.Size]byte]*Principal{}}
+	for _, key := range cfg.Keys {
+		digest, err := keyDigest(key)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := a.principals[digest]; exists {
+			return nil, fmt.Errorf("API key %s is not unique", key.Name)
+		}
+		principal := &Principal{
+			Name:          key.Name,
+			MaxConcurrent: key.MaxConcurrent,
+			scopes:        map[Scope]bool{},
+		}
+		for _, scope := range key.Scopes {
+			switch Scope(scope) {
+			case ScopeSubmit, ScopeRead, ScopeCancel, ScopeMetrics, ScopeAdmin:
+				principal.scopes[Scope(scope)] = true
+			default:
+				return nil, fmt.Errorf("unknown scope of API key %s: %q", key.Name, scope)
+			}
+		}
+		if key.RateLimit > 0 {
+			principal.limiter = newRateLimiter(key.RateLimit/60, key.Burst)
+		}
+		a.principals[digest] = principal
+	}
+	return a, nil
+}
+
+func keyDigest(key config.APIKeyConfig) ([sha256.Size]byte, error) {
+	if key.Key != "" {
+		return sha256.Sum256([]byte(key.Key)), nil
+	}
+	var digest [sha256.Size]byte
+	decoded, err := hex.DecodeString(key.KeySHA256)
+	if err != nil || len(decoded) != sha256.Size {
+		return digest, fmt.Errorf("key_sha256 of API key %s is not a hex SHA-256", key.Name)
+	}
+	copy(digest[:], decoded)
+	return digest, nil
+}
+
+// Authenticate returns the principal of the API key or ErrUnauthenticated.
+func (a *Authenticator) Authenticate(key string) (*Principal, error) {
+	if a == nil {
+		return nil, nil
+	}
+	if key == "" {
+		return nil, ErrUnauthenticated
+	}
+	principal, exists := a.principals[sha256.Sum256([]byte(key))]
+	if !exists {
+		return nil, ErrUnauthenticated
+	}
+	return principal, nil
+}
+
+// Authorize authenticates the key and checks that it is granted the scope.
+// Public operations do not require a key.
+func (a *Authenticator) Authorize(key string, scope Scope) (*Principal, error) {
+	if a == nil {
+		return nil, nil
+	}
+	principal, err := a.Authenticate(key)
+	if scope == ScopePublic {
+		return principal, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !principal.Can(scope) {
+		return nil, ErrForbidden
+	}
+	return principal, nil
+}
+
+// bearerKey extracts the key from the value of the Authorization header,
+// e.g. "Bearer 0123abcd".
+func bearerKey(authorization string) string {
+	const prefix = "bearer "
+	if len(authorization) > len(prefix) && strings.EqualFold(authorization[:len(prefix)], prefix) {
+		return strings.TrimSpace(authorization[len(prefix):])
+	}
+	return ""
+}
+
+type principalKey struct{}
+
+// NewContext returns a copy of ctx which carries the principal.
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// FromContext returns the principal stored by NewContext() or nil.
+func FromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// rateLimiter is a token bucket refilled with `rate` tokens per second up to `burst`.
+type rateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	limiter := &rateLimiter{rate: rate, burst: float64(burst)}
+	if limiter.burst < 1 {
+		limiter.burst = rate * 60
+		if limiter.burst < 1 {
+			limiter.burst = 1
+		}
+	}
+	limiter.tokens = limiter.burst
+	return limiter
+}
+
+// allow takes a token at `now` and returns false if there are none.
+func (limiter *rateLimiter) allow(now time.Time) bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if !limiter.last.IsZero() {
+		limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+		if limiter.tokens > limiter.burst {
+			limiter.tokens = limiter.burst
+		}
+	}
+	limiter.last = now
+	if limiter.tokens < 1 {
+		return false
+	}
+	limiter.tokens--
+	return true
+}
@@ -0,0 +1,221 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/dmytrogajewski/hercules/internal/pkg/config"
+)
+
+func newTestAuthenticator(t *testing.T) *Authenticator {
+	a, err := NewAuthenticator(config.AuthConfig{Enabled: true, Keys: []config.APIKeyConfig{
+		{Name: "ci", Key: "ci-key", Scopes: []string{"submit", "read"}, MaxConcurrent: 3},
+		// sha256("ops-key")
+		{Name: "ops", KeySHA256: "2c69bc9111c27110a9b9a7974ba3f8ac0c053c16b23a0738115ee829fbc4d57b",
+			Scopes: []string{"admin"}},
+		{Name: "limited", Key: "limited-key", Scopes: []string{"submit"}, RateLimit: 60, Burst: 2},
+	}})
+	require.NoError(t, err)
+	return a
+}
+
+func TestAuthenticatorAuthenticate(t *testing.T) {
+	a := newTestAuthenticator(t)
+	principal, err := a.Authenticate("ci-key")
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", principal.Tenant())
+	assert.Equal(t, 3, principal.Concurrency())
+	assert.True(t, principal.Can(ScopeSubmit))
+	assert.True(t, principal.Can(ScopeRead))
+	assert.True(t, principal.Can(ScopePublic))
+	assert.False(t, principal.Can(ScopeCancel))
+	assert.False(t, principal.Can(ScopeMetrics))
+	assert.True(t, principal.Owns("ci"))
+	assert.False(t, principal.Owns("ops"))
+	assert.False(t, principal.Owns(""))
+	_, err = a.Authenticate("wrong")
+	assert.Equal(t, ErrUnauthenticated, err)
+	_, err = a.Authenticate("")
+	assert.Equal(t, ErrUnauthenticated, err)
+
+	_, err = a.Authorize("ci-key", ScopeCancel)
+	assert.Equal(t, ErrForbidden, err)
+	principal, err = a.Authorize("", ScopePublic)
+	assert.NoError(t, err)
+	assert.Nil(t, principal)
+	principal, err = a.Authorize("ci-key", ScopePublic)
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", principal.Name)
+}
+
+func TestAuthenticatorKeySHA256(t *testing.T) {
+	a, err := NewAuthenticator(config.AuthConfig{Enabled: true, Keys: []config.APIKeyConfig{
+		// sha256("secret")
+		{Name: "ops", KeySHA256: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b",
+			Scopes: []string{"admin"}},
+	}})
+	require.NoError(t, err)
+	principal, err := a.Authorize("secret", ScopeCancel)
+	assert.NoError(t, err)
+	assert.True(t, principal.Owns("ci"))
+	_, err = a.Authenticate("2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b")
+	assert.Equal(t, ErrUnauthenticated, err)
+}
+
+func TestNewAuthenticatorErrors(t *testing.T) {
+	a, err := NewAuthenticator(config.AuthConfig{Keys: []config.APIKeyConfig{{Name: "ci", Key: "x"}}})
+	assert.NoError(t, err)
+	assert.Nil(t, a)
+	for _, keys := range [][]config.APIKeyConfig{
+		{{Name: "ci", Key: "x", Scopes: []string{"write"}}},
+		{{Name: "ci", KeySHA256: "xyz"}},
+		{{Name: "ci", Key: "x"}, {Name: "ops", Key: "x"}},
+	} {
+		_, err := NewAuthenticator(config.AuthConfig{Enabled: true, Keys: keys})
+		assert.Error(t, err, "%+v", keys)
+	}
+}
+
+func TestAuthenticatorDisabled(t *testing.T) {
+	var a *Authenticator
+	principal, err := a.Authorize("", ScopeAdmin)
+	assert.NoError(t, err)
+	assert.Nil(t, principal)
+	assert.True(t, principal.Can(ScopeAdmin))
+	assert.True(t, principal.Owns("anyone"))
+	assert.Equal(t, "", principal.Tenant())
+	assert.Equal(t, 0, principal.Concurrency())
+	assert.NoError(t, principal.AllowSubmit())
+}
+
+func TestPrincipalAllowSubmit(t *testing.T) {
+	a := newTestAuthenticator(t)
+	principal, err := a.Authenticate("limited-key")
+	require.NoError(t, err)
+	assert.NoError(t, principal.AllowSubmit())
+	assert.NoError(t, principal.AllowSubmit())
+	assert.Equal(t, ErrRateLimited, principal.AllowSubmit())
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := newRateLimiter(0.5, 0)
+	assert.Equal(t, float64(30), limiter.burst)
+	now := time.Unix(1000, 0)
+	for i := 0; i < 30; i++ {
+		assert.True(t, limiter.allow(now))
+	}
+	assert.False(t, limiter.allow(now))
+	assert.False(t, limiter.allow(now.Add(time.Second)))
+	assert.True(t, limiter.allow(now.Add(3*time.Second)))
+	assert.False(t, limiter.allow(now.Add(3*time.Second)))
+	// the bucket never overflows
+	later := now.Add(time.Hour)
+	for i := 0; i < 30; i++ {
+		assert.True(t, limiter.allow(later))
+	}
+	assert.False(t, limiter.allow(later))
+	assert.Equal(t, float64(1), newRateLimiter(0.001, 0).burst)
+}
+
+func TestBearerKey(t *testing.T) {
+	assert.Equal(t, "abc", bearerKey("Bearer abc"))
+	assert.Equal(t, "abc", bearerKey("bearer  abc "))
+	assert.Equal(t, "", bearerKey("Basic abc"))
+	assert.Equal(t, "", bearerKey("Bearer "))
+	assert.Equal(t, "", bearerKey(""))
+}
+
+func TestAuthenticatorHTTP(t *testing.T) {
+	a := newTestAuthenticator(t)
+	var tenant string
+	handler := a.HTTP(ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+		tenant = FromContext(r.Context()).Tenant()
+	})
+	serve := func(header, value string) int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		if header != "" {
+			request.Header.Set(header, value)
+		}
+		handler(recorder, request)
+		return recorder.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, serve("", ""))
+	assert.Equal(t, http.StatusOK, serve("Authorization", "Bearer ci-key"))
+	assert.Equal(t, "ci", tenant)
+	assert.Equal(t, http.StatusOK, serve(APIKeyHeader, "ci-key"))
+	assert.Equal(t, http.StatusForbidden, serve(APIKeyHeader, "limited-key"))
+	assert.Equal(t, http.StatusUnauthorized, serve("Authorization", "Basic ci-key"))
+
+	var disabled *Authenticator
+	tenant = "x"
+	handler = disabled.HTTP(ScopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		tenant = FromContext(r.Context()).Tenant()
+	})
+	assert.Equal(t, http.StatusOK, serve("", ""))
+	assert.Equal(t, "", tenant)
+}
+
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *testServerStream) Context() context.Context {
+	return stream.ctx
+}
+
+func TestAuthenticatorGRPC(t *testing.T) {
+	a := newTestAuthenticator(t)
+	scopes := map[string]Scope{"/svc/Health": ScopePublic, "/svc/Submit": ScopeSubmit}
+	unary := a.UnaryInterceptor(scopes)
+	call := func(method string, md ...string) (string, error) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(md...))
+		tenant, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				return FromContext(ctx).Tenant(), nil
+			})
+		if err != nil {
+			return "", err
+		}
+		return tenant.(string), nil
+	}
+	tenant, err := call("/svc/Health")
+	assert.NoError(t, err)
+	assert.Equal(t, "", tenant)
+	_, err = call("/svc/Submit")
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	tenant, err = call("/svc/Submit", "authorization", "Bearer ci-key")
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", tenant)
+	tenant, err = call("/svc/Submit", "x-api-key", "limited-key")
+	assert.NoError(t, err)
+	assert.Equal(t, "limited", tenant)
+	// unlisted methods require the admin scope
+	_, err = call("/svc/Other", "x-api-key", "ci-key")
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream := a.StreamInterceptor(scopes)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "ci-key"))
+	err = stream(nil, &testServerStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/svc/Submit"},
+		func(srv interface{}, stream grpc.ServerStream) error {
+			tenant = FromContext(stream.Context()).Tenant()
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", tenant)
+	err = stream(nil, &testServerStream{ctx: context.Background()},
+		&grpc.StreamServerInfo{FullMethod: "/svc/Submit"},
+		func(srv interface{}, stream grpc.ServerStream) error { return nil })
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/dmytrogajewski/hercules/internal/pkg/config"
+)
+
+// ErrRepositoryNotAllowed is returned by RepositoryPolicy.Check() for the forbidden repositories.
+var ErrRepositoryNotAllowed = errors.New("repository is not allowed")
+
+// RepositoryPolicy restricts the protocols and the hosts of the analysed repositories.
+// Local paths use the "file" protocol and have no host.
+type RepositoryPolicy struct {
+	protocols map[string]bool
+	hosts     []string
+}
+
+// NewRepositoryPolicy creates the policy from RepositoryConfig.AllowedProtocols and
+// RepositoryConfig.AllowedHosts. Empty lists allow everything.
+func NewRepositoryPolicy(cfg config.RepositoryConfig) *RepositoryPolicy {
+	policy := &RepositoryPolicy{}
+	if len(cfg.AllowedProtocols) > 0 {
+		policy.protocols = map[string]bool{}
+		for _, protocol := range cfg.AllowedProtocols {
+			policy.protocols[strings.ToLower(protocol)] = true
+		}
+	}
+	for _, host := range cfg.AllowedHosts {
+		policy.hosts = append(policy.hosts, strings.ToLower(host))
+	}
+	return policy
+}
+
+// Check returns an error which wraps ErrRepositoryNotAllowed if the repository must not
+// be analysed.
+func (policy *RepositoryPolicy) Check(uri string) error {
+	protocol, host, err := ParseRepositoryURL(uri)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRepositoryNotAllowed, err)
+	}
+	if policy.protocols != nil && !policy.protocols[protocol] {
+		return fmt.Errorf("%w: protocol %s", ErrRepositoryNotAllowed, protocol)
+	}
+	if protocol == "file" || len(policy.hosts) == 0 {
+		return nil
+	}
+	for _, pattern := range policy.hosts {
+		if matched, _ := path.Match(pattern, host); matched {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %s", ErrRepositoryNotAllowed, host)
+}
+
+// IsRemoteRepository returns true if the repository must be cloned rather than opened as
+// a local path. It classifies the URI the same way as RepositoryPolicy.Check(), so that
+// the policy always checks what is actually opened.
+func IsRemoteRepository(uri string) bool {
+	protocol, _, err := ParseRepositoryURL(uri)
+	return err == nil && (protocol != "file" || strings.Contains(uri, "://"))
+}
+
+// ParseRepositoryURL returns the protocol and the lower case host name of the repository.
+// It understands the URLs, the scp-like SSH addresses such as "git@github.com:org/repo.git"
+// or "github.com:org/repo" and the local paths.
+func ParseRepositoryURL(uri string) (protocol string, host string, err error) {
+	if uri == "" {
+		return "", "", errors.New("empty repository URL")
+	}
+	if strings.Contains(uri, "://") {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			return "", "", err
+		}
+		protocol = strings.ToLower(parsed.Scheme)
+		if protocol == "file" {
+			return protocol, "", nil
+		}
+		host = strings.ToLower(parsed.Hostname())
+		if host == "" {
+			return "", "", fmt.Errorf("no host in %s", uri)
+		}
+		return protocol, host, nil
+	}
+	colon := strings.Index(uri, ":")
+	if slash := strings.Index(uri, "/"); colon > 1 && (slash < 0 || colon < slash) {
+		// scp-like syntax; a single letter before the colon is a Windows drive
+		host = uri[:colon]
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			host = host[at+1:]
+		}
+		return "ssh", strings.ToLower(host), nil
+	}
+	return "file", "", nil
+}
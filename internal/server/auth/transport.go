@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// APIKeyHeader is the alternative to "Authorization: Bearer <key>" in HTTP headers and
+// gRPC metadata.
+const APIKeyHeader = "X-API-Key"
+
+// HTTP wraps the handler to require an API key with the scope. The principal is
+// available to the handler through FromContext(r.Context()).
+func (a *Authenticator) HTTP(scope Scope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := bearerKey(r.Header.Get("Authorization"))
+		if key == "" {
+			key = r.Header.Get(APIKeyHeader)
+		}
+		principal, err := a.Authorize(key, scope)
+		switch err {
+		case nil:
+		case ErrForbidden:
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		default:
+			w.Header().Set("WWW-Authenticate", `Bearer realm="hercules"`)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next(w, r.WithContext(NewContext(r.Context(), principal)))
+	}
+}
+
+// UnaryInterceptor requires an API key with the scope of each unary gRPC method.
+// `scopes` map the full method names, e.g. "/hercules.v1.HerculesService/Health",
+// to their scopes. The methods which are not listed require ScopeAdmin.
+func (a *Authenticator) UnaryInterceptor(scopes map[string]Scope) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := a.authorizeGRPC(ctx, info.FullMethod, scopes)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor is UnaryInterceptor for the streaming gRPC methods.
+func (a *Authenticator) StreamInterceptor(scopes map[string]Scope) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler) error {
+		ctx, err := a.authorizeGRPC(stream.Context(), info.FullMethod, scopes)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &principalStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+func (a *Authenticator) authorizeGRPC(ctx context.Context, method string, scopes map[string]Scope) (
+	context.Context, error) {
+	scope, exists := scopes[method]
+	if !exists {
+		scope = ScopeAdmin
+	}
+	var key string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			key = bearerKey(values[0])
+		}
+		if values := md.Get(strings.ToLower(APIKeyHeader)); key == "" && len(values) > 0 {
+			key = values[0]
+		}
+	}
+	principal, err := a.Authorize(key, scope)
+	switch err {
+	case nil:
+		return NewContext(ctx, principal), nil
+	case ErrForbidden:
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+}
+
+// principalStream overrides the context of the stream to carry the principal.
+type principalStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (stream *principalStream) Context() context.Context {
+	return stream.ctx
+}
@@ -2,9 +2,7 @@ package grpcserver
 
 import (
 	"context"
-	"fmt"
 	"net"
-	"sync"
 	"time"
 
 	"google.golang.org/grpc"
@@ -16,36 +14,52 @@ import (
 	"github.com/dmytrogajewski/hercules/internal/app/core"
 	"github.com/dmytrogajewski/hercules/internal/pkg/config"
 	"github.com/dmytrogajewski/hercules/internal/pkg/version"
+	"github.com/dmytrogajewski/hercules/internal/server/auth"
+	"github.com/dmytrogajewski/hercules/internal/server/jobs"
+	"github.com/dmytrogajewski/hercules/internal/server/metrics"
 )
 
 // Server wraps the gRPC server and job management
 type Server struct {
 	pb.UnimplementedHerculesServiceServer
-	config    *config.Config
-	jobs      map[string]*Job
-	jobsMutex sync.RWMutex
-	grpcSrv   *grpc.Server
-	addr      string
-	logger    core.Logger
+	config  *config.Config
+	jobs    *jobs.Manager
+	grpcSrv *grpc.Server
+	addr    string
+	logger  core.Logger
+	metrics *metrics.Metrics
+
+	repositories *auth.RepositoryPolicy
 }
 
-type Job struct {
-	ID        string
-	Status    string
-	StartTime time.Time
-	EndTime   *time.Time
-	Error     string
-	Request   *pb.SubmitAnalysisRequest
-	Results   map[string]*pb.AnalysisResult
+// methodScopes map the HerculesService methods to the API key scopes which they require.
+var methodScopes = map[string]auth.Scope{
+	pb.HerculesService_Health_FullMethodName:                 auth.ScopePublic,
+	pb.HerculesService_ListAnalyses_FullMethodName:           auth.ScopeRead,
+	pb.HerculesService_SubmitAnalysis_FullMethodName:         auth.ScopeSubmit,
+	pb.HerculesService_GetAnalysisStatus_FullMethodName:      auth.ScopeRead,
+	pb.HerculesService_StreamAnalysisProgress_FullMethodName: auth.ScopeRead,
+	pb.HerculesService_CancelAnalysis_FullMethodName:         auth.ScopeCancel,
+	pb.HerculesService_GetMetrics_FullMethodName:             auth.ScopeMetrics,
 }
 
-func NewServer(cfg *config.Config, addr string, logger core.Logger) *Server {
+// NewServer creates the gRPC server which schedules the analyses on the specified
+// job manager, normally the same as the HTTP server's. GetMetrics reports `m`;
+// it is unavailable if `m` is nil. The calls require the keys of `authenticator`
+// unless it is nil.
+func NewServer(cfg *config.Config, addr string, logger core.Logger, manager *jobs.Manager,
+	m *metrics.Metrics, authenticator *auth.Authenticator) *Server {
 	return &Server{
-		config:  cfg,
-		jobs:    make(map[string]*Job),
-		grpcSrv: grpc.NewServer(),
-		addr:    addr,
-		logger:  logger,
+		config: cfg,
+		jobs:   manager,
+		grpcSrv: grpc.NewServer(
+			grpc.UnaryInterceptor(authenticator.UnaryInterceptor(methodScopes)),
+			grpc.StreamInterceptor(authenticator.StreamInterceptor(methodScopes)),
+		),
+		addr:         addr,
+		logger:       logger,
+		metrics:      m,
+		repositories: auth.NewRepositoryPolicy(cfg.Repository),
 	}
 }
 
@@ -88,6 +102,11 @@ func (s *Server) ListAnalyses(ctx context.Context, req *pb.ListAnalysesRequest)
 			{Name: "file-history", Description: "File history analysis"},
 			{Name: "imports-per-dev", Description: "Import usage per developer"},
 			{Name: "shotness", Description: "Structural hotness analysis"},
+			{Name: "hotspots", Description: "Files and functions ranked by churn × complexity"},
+			{Name: "ownership", Description: "Line ownership, bus factor and orphaned code per file and directory"},
+			{Name: "rework", Description: "New code rewritten or deleted soon after it was written"},
+			{Name: "commit-classes", Description: "Commits classified as features, fixes, refactorings, reverts, etc."},
+			{Name: "szz", Description: "Bug-introducing commits traced from fixes (SZZ) and defect density"},
 		},
 		Timestamp: timestamppb.Now(),
 	}, nil
@@ -102,38 +121,33 @@ func (s *Server) SubmitAnalysis(ctx context.Context, req *pb.SubmitAnalysisReque
 		return nil, status.Error(codes.InvalidArgument, "At least one analysis type is required")
 	}
 
-	// Check concurrent analysis limit
-	s.jobsMutex.RLock()
-	if len(s.jobs) >= s.config.Analysis.MaxConcurrentAnalyses {
-		s.jobsMutex.RUnlock()
-		return nil, status.Error(codes.ResourceExhausted, "Too many concurrent analyses")
-	}
-	s.jobsMutex.RUnlock()
-
-	// Create job ID
-	jobID := fmt.Sprintf("job_%d", time.Now().UnixNano())
-
-	// Create job
-	job := &Job{
-		ID:        jobID,
-		Status:    "running",
-		StartTime: time.Now(),
-		Request:   req,
-		Results:   make(map[string]*pb.AnalysisResult),
+	if format := req.Options["format"]; format != "" && format != "pb" && format != "json" {
+		return nil, status.Errorf(codes.InvalidArgument, "Unsupported result format: %s", format)
 	}
 
-	// Add job to tracking
-	s.jobsMutex.Lock()
-	s.jobs[jobID] = job
-	s.jobsMutex.Unlock()
+	if err := s.repositories.Check(req.Repository); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
 
-	// Start analysis in a goroutine
-	go s.runAnalysis(job)
+	principal := auth.FromContext(ctx)
+	job, err := s.jobs.SubmitLimited(jobs.Request{
+		Repository: req.Repository,
+		Analyses:   req.Analyses,
+		Options:    req.Options,
+		Tenant:     principal.Tenant(),
+	}, principal.Concurrency(), principal.AllowSubmit)
+	if err == jobs.ErrQueueFull {
+		return nil, status.Error(codes.ResourceExhausted, "Too many concurrent analyses")
+	} else if err == jobs.ErrQuotaExceeded || err == auth.ErrRateLimited {
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
 
 	return &pb.SubmitAnalysisResponse{
 		Status:    "accepted",
-		Message:   "Analysis started",
-		JobId:     jobID,
+		Message:   "Analysis queued",
+		JobId:     job.ID,
 		Timestamp: timestamppb.Now(),
 	}, nil
 }
@@ -143,28 +157,35 @@ func (s *Server) GetAnalysisStatus(ctx context.Context, req *pb.GetAnalysisStatu
 		return nil, status.Error(codes.InvalidArgument, "Job ID is required")
 	}
 
-	s.jobsMutex.RLock()
-	job, exists := s.jobs[req.JobId]
-	s.jobsMutex.RUnlock()
-
-	if !exists {
-		return nil, status.Error(codes.NotFound, "Job not found")
+	job, err := s.getJob(ctx, req.JobId)
+	if err != nil {
+		return nil, err
 	}
 
 	response := &pb.GetAnalysisStatusResponse{
-		Status:    job.Status,
-		Message:   s.getStatusMessage(job.Status),
+		Status:    string(job.State),
+		Message:   s.getStatusMessage(job.State),
 		JobId:     job.ID,
-		StartTime: timestamppb.New(job.StartTime),
 		Timestamp: timestamppb.Now(),
 	}
 
-	if job.EndTime != nil {
-		response.EndTime = timestamppb.New(*job.EndTime)
+	if !job.StartedAt.IsZero() {
+		response.StartTime = timestamppb.New(job.StartedAt)
+	}
+
+	if !job.FinishedAt.IsZero() {
+		response.EndTime = timestamppb.New(job.FinishedAt)
+	}
+
+	if job.Error != "" {
+		response.Message = job.Error
 	}
 
-	if job.Status == "completed" {
-		response.Results = job.Results
+	if job.State == jobs.StateCompleted {
+		response.Results, err = s.convertResults(job)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
 	}
 
 	return response, nil
@@ -175,20 +196,17 @@ func (s *Server) StreamAnalysisProgress(req *pb.StreamAnalysisProgressRequest, s
 		return status.Error(codes.InvalidArgument, "Job ID is required")
 	}
 
-	s.jobsMutex.RLock()
-	job, exists := s.jobs[req.JobId]
-	s.jobsMutex.RUnlock()
-
-	if !exists {
-		return status.Error(codes.NotFound, "Job not found")
+	job, err := s.getJob(stream.Context(), req.JobId)
+	if err != nil {
+		return err
 	}
 
 	// Send initial status
-	err := stream.Send(&pb.StreamAnalysisProgressResponse{
+	err = stream.Send(&pb.StreamAnalysisProgressResponse{
 		JobId:           job.ID,
-		Status:          job.Status,
-		Message:         s.getStatusMessage(job.Status),
-		ProgressPercent: 0,
+		Status:          string(job.State),
+		Message:         s.getStatusMessage(job.State),
+		ProgressPercent: int32(s.calculateProgress(job)),
 		Timestamp:       timestamppb.Now(),
 	})
 	if err != nil {
@@ -204,19 +222,16 @@ func (s *Server) StreamAnalysisProgress(req *pb.StreamAnalysisProgressRequest, s
 		case <-stream.Context().Done():
 			return stream.Context().Err()
 		case <-ticker.C:
-			s.jobsMutex.RLock()
-			job, exists := s.jobs[req.JobId]
-			s.jobsMutex.RUnlock()
-
-			if !exists {
-				return status.Error(codes.NotFound, "Job not found")
+			job, err := s.getJob(stream.Context(), req.JobId)
+			if err != nil {
+				return err
 			}
 
 			progress := s.calculateProgress(job)
-			err := stream.Send(&pb.StreamAnalysisProgressResponse{
+			err = stream.Send(&pb.StreamAnalysisProgressResponse{
 				JobId:           job.ID,
-				Status:          job.Status,
-				Message:         s.getStatusMessage(job.Status),
+				Status:          string(job.State),
+				Message:         s.getStatusMessage(job.State),
 				ProgressPercent: int32(progress),
 				Timestamp:       timestamppb.Now(),
 			})
@@ -224,74 +239,134 @@ func (s *Server) StreamAnalysisProgress(req *pb.StreamAnalysisProgressRequest, s
 				return err
 			}
 
-			if job.Status == "completed" || job.Status == "failed" {
+			if job.State.Terminal() {
 				return nil
 			}
 		}
 	}
 }
 
+func (s *Server) CancelAnalysis(ctx context.Context, req *pb.CancelAnalysisRequest) (*pb.CancelAnalysisResponse, error) {
+	if req.JobId == "" {
+		return nil, status.Error(codes.InvalidArgument, "Job ID is required")
+	}
+
+	if _, err := s.getJob(ctx, req.JobId); err != nil {
+		return nil, err
+	}
+
+	job, err := s.jobs.Cancel(req.JobId)
+	switch err {
+	case nil:
+	case jobs.ErrNotFound:
+		return nil, status.Error(codes.NotFound, "Job not found")
+	case jobs.ErrFinished:
+		return nil, status.Errorf(codes.FailedPrecondition, "Job already %s", job.State)
+	default:
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &pb.CancelAnalysisResponse{
+		Status:    string(job.State),
+		Message:   s.getStatusMessage(job.State),
+		JobId:     job.ID,
+		Timestamp: timestamppb.Now(),
+	}, nil
+}
+
+func (s *Server) GetMetrics(ctx context.Context, req *pb.GetMetricsRequest) (*pb.GetMetricsResponse, error) {
+	if s.metrics == nil {
+		return nil, status.Error(codes.Unavailable, "Metrics are disabled")
+	}
+	text, err := s.metrics.Text()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.GetMetricsResponse{
+		ContentType: metrics.TextContentType,
+		Text:        text,
+		Timestamp:   timestamppb.Now(),
+	}, nil
+}
+
 // Helper methods
 
-func (s *Server) getStatusMessage(status string) string {
-	switch status {
-	case "running":
+// getJob returns the job if the caller's API key owns it. The jobs of the other keys are not found.
+func (s *Server) getJob(ctx context.Context, id string) (*jobs.Job, error) {
+	job, err := s.jobs.Get(id)
+	if err == nil && !auth.FromContext(ctx).Owns(job.Request.Tenant) {
+		err = jobs.ErrNotFound
+	}
+	if err == jobs.ErrNotFound {
+		return nil, status.Error(codes.NotFound, "Job not found")
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return job, nil
+}
+
+// convertResults serializes the job results in the format requested by the "format" option.
+func (s *Server) convertResults(job *jobs.Job) (map[string]*pb.AnalysisResult, error) {
+	format := job.Request.Options["format"]
+	if format == "" {
+		format = "pb"
+	}
+	results := map[string]*pb.AnalysisResult{}
+	for name, result := range job.Results {
+		converted := &pb.AnalysisResult{
+			Name:        result.Name,
+			Description: result.Description,
+			ResultType:  result.ResultType,
+			Format:      format,
+			Content:     result.Binary,
+		}
+		if converted.Description == "" {
+			converted.Description = s.getAnalysisDescription(name)
+		}
+		if format == "json" {
+			content, err := result.JSON()
+			if err != nil {
+				return nil, err
+			}
+			converted.Content = content
+		}
+		results[name] = converted
+	}
+	return results, nil
+}
+
+func (s *Server) getStatusMessage(state jobs.State) string {
+	switch state {
+	case jobs.StateQueued:
+		return "Analysis queued"
+	case jobs.StateRunning:
 		return "Analysis in progress"
-	case "completed":
+	case jobs.StateCompleted:
 		return "Analysis completed"
-	case "failed":
+	case jobs.StateFailed:
 		return "Analysis failed"
+	case jobs.StateCancelled:
+		return "Analysis cancelled"
 	default:
 		return "Unknown status"
 	}
 }
 
-func (s *Server) calculateProgress(job *Job) int {
-	if job.Status == "completed" {
+func (s *Server) calculateProgress(job *jobs.Job) int {
+	switch {
+	case job.State == jobs.StateCompleted:
 		return 100
-	}
-	if job.Status == "failed" {
+	case job.State != jobs.StateRunning:
 		return 0
 	}
 	// Simple progress calculation based on time elapsed
-	elapsed := time.Since(job.StartTime)
+	elapsed := time.Since(job.StartedAt)
 	if elapsed > 30*time.Second {
 		return 50
 	}
 	return int(elapsed.Seconds() * 2) // Rough estimate
 }
 
-func (s *Server) runAnalysis(job *Job) {
-	defer func() {
-		now := time.Now()
-		job.EndTime = &now
-		if job.Status == "running" {
-			job.Status = "completed"
-		}
-	}()
-
-	// Convert gRPC request to internal format
-	analyses := make([]string, len(job.Request.Analyses))
-	copy(analyses, job.Request.Analyses)
-
-	// Run the analysis using the same logic as HTTP server
-	// This is a simplified version - in practice, you'd want to share the exact same analysis engine
-	for _, analysisType := range analyses {
-		result := &pb.AnalysisResult{
-			Name:        analysisType,
-			Description: s.getAnalysisDescription(analysisType),
-			ResultType:  fmt.Sprintf("*leaves.%sResult", analysisType),
-		}
-
-		// Simulate analysis work
-		time.Sleep(2 * time.Second)
-
-		job.Results[analysisType] = result
-	}
-
-	job.Status = "completed"
-}
-
 func (s *Server) getAnalysisDescription(analysisType string) string {
 	descriptions := map[string]string{
 		"burndown":        "Line burndown statistics for project, files and developers",
@@ -301,6 +376,11 @@ func (s *Server) getAnalysisDescription(analysisType string) string {
 		"file-history":    "File history analysis",
 		"imports-per-dev": "Import usage per developer",
 		"shotness":        "Structural hotness analysis",
+		"hotspots":        "Files and functions ranked by churn × complexity",
+		"ownership":       "Line ownership, bus factor and orphaned code per file and directory",
+		"rework":          "New code rewritten or deleted soon after it was written",
+		"commit-classes":  "Commits classified as features, fixes, refactorings, reverts, etc.",
+		"szz":             "Bug-introducing commits traced from fixes (SZZ) and defect density",
 	}
 	return descriptions[analysisType]
 }
@@ -0,0 +1,208 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/pkg/config"
+	"github.com/dmytrogajewski/hercules/internal/server/auth"
+	"github.com/dmytrogajewski/hercules/internal/server/jobs"
+	"github.com/dmytrogajewski/hercules/internal/server/metrics"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeRunner struct {
+	results map[string]*jobs.Result
+	err     error
+}
+
+func (fr fakeRunner) Run(ctx context.Context, request jobs.Request) (*jobs.Report, error) {
+	if fr.err != nil {
+		return nil, fr.err
+	}
+	return &jobs.Report{Results: fr.results}, nil
+}
+
+func newTestServer(t *testing.T, runner jobs.Runner) *Server {
+	manager := jobs.NewManager(jobs.NewMemoryStore(), runner, jobs.Options{Workers: 1})
+	assert.Nil(t, manager.Start())
+	t.Cleanup(manager.Stop)
+	cfg := &config.Config{Analysis: config.AnalysisConfig{MaxConcurrentAnalyses: 1}}
+	m := metrics.New()
+	assert.Nil(t, m.RegisterJobs(manager))
+	return NewServer(cfg, "localhost:0", core.GetLogger(), manager, m, nil)
+}
+
+func waitForJob(t *testing.T, s *Server, id string) *pb.GetAnalysisStatusResponse {
+	for i := 0; i < 100; i++ {
+		response, err := s.GetAnalysisStatus(context.Background(), &pb.GetAnalysisStatusRequest{JobId: id})
+		assert.Nil(t, err)
+		if jobs.State(response.Status).Terminal() {
+			return response
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return nil
+}
+
+func TestServerSubmitAnalysisResults(t *testing.T) {
+	s := newTestServer(t, fakeRunner{results: map[string]*jobs.Result{
+		"burndown": {Name: "Burndown", Binary: []byte{1, 2, 3}, Text: []byte("  granularity: 30\n")},
+	}})
+	submitted, err := s.SubmitAnalysis(context.Background(), &pb.SubmitAnalysisRequest{
+		Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, submitted.JobId)
+	response := waitForJob(t, s, submitted.JobId)
+	assert.Equal(t, "completed", response.Status)
+	assert.Len(t, response.Results, 1)
+	result := response.Results["burndown"]
+	assert.Equal(t, "Burndown", result.Name)
+	assert.Equal(t, "pb", result.Format)
+	assert.Equal(t, []byte{1, 2, 3}, result.Content)
+	assert.Equal(t, s.getAnalysisDescription("burndown"), result.Description)
+	assert.NotNil(t, response.StartTime)
+	assert.NotNil(t, response.EndTime)
+}
+
+func TestServerSubmitAnalysisJSON(t *testing.T) {
+	s := newTestServer(t, fakeRunner{results: map[string]*jobs.Result{
+		"burndown": {Name: "Burndown", Binary: []byte{1, 2, 3}, Text: []byte("  granularity: 30\n")},
+	}})
+	submitted, err := s.SubmitAnalysis(context.Background(), &pb.SubmitAnalysisRequest{
+		Repository: "repo", Analyses: []string{"burndown"}, Options: map[string]string{"format": "json"}})
+	assert.Nil(t, err)
+	response := waitForJob(t, s, submitted.JobId)
+	result := response.Results["burndown"]
+	assert.Equal(t, "json", result.Format)
+	var parsed map[string]interface{}
+	assert.Nil(t, json.Unmarshal(result.Content, &parsed))
+	assert.Equal(t, float64(30), parsed["granularity"])
+}
+
+func TestServerSubmitAnalysisFailure(t *testing.T) {
+	s := newTestServer(t, fakeRunner{err: errors.New("clone failed")})
+	submitted, err := s.SubmitAnalysis(context.Background(), &pb.SubmitAnalysisRequest{
+		Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	response := waitForJob(t, s, submitted.JobId)
+	assert.Equal(t, "failed", response.Status)
+	assert.Equal(t, "clone failed", response.Message)
+	assert.Len(t, response.Results, 0)
+}
+
+func TestServerSubmitAnalysisInvalid(t *testing.T) {
+	s := newTestServer(t, fakeRunner{})
+	_, err := s.SubmitAnalysis(context.Background(), &pb.SubmitAnalysisRequest{
+		Repository: "repo", Analyses: []string{"burndown"}, Options: map[string]string{"format": "xml"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.GetAnalysisStatus(context.Background(), &pb.GetAnalysisStatusRequest{JobId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+type blockingRunner struct {
+	started chan struct{}
+}
+
+func (br blockingRunner) Run(ctx context.Context, request jobs.Request) (*jobs.Report, error) {
+	close(br.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestServerCancelAnalysis(t *testing.T) {
+	runner := blockingRunner{started: make(chan struct{})}
+	s := newTestServer(t, runner)
+	submitted, err := s.SubmitAnalysis(context.Background(), &pb.SubmitAnalysisRequest{
+		Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	<-runner.started
+	response, err := s.CancelAnalysis(context.Background(), &pb.CancelAnalysisRequest{JobId: submitted.JobId})
+	assert.Nil(t, err)
+	assert.Equal(t, "cancelled", response.Status)
+	assert.Equal(t, "Analysis cancelled", response.Message)
+	assert.Equal(t, submitted.JobId, response.JobId)
+	final := waitForJob(t, s, submitted.JobId)
+	assert.Equal(t, "cancelled", final.Status)
+	assert.NotNil(t, final.EndTime)
+	_, err = s.CancelAnalysis(context.Background(), &pb.CancelAnalysisRequest{JobId: submitted.JobId})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = s.CancelAnalysis(context.Background(), &pb.CancelAnalysisRequest{JobId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.CancelAnalysis(context.Background(), &pb.CancelAnalysisRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServerGetMetrics(t *testing.T) {
+	s := newTestServer(t, fakeRunner{err: errors.New("failed")})
+	submitted, err := s.SubmitAnalysis(context.Background(), &pb.SubmitAnalysisRequest{
+		Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	waitForJob(t, s, submitted.JobId)
+	response, err := s.GetMetrics(context.Background(), &pb.GetMetricsRequest{})
+	assert.Nil(t, err)
+	assert.Equal(t, metrics.TextContentType, response.ContentType)
+	assert.Contains(t, response.Text, `hercules_jobs{state="failed"} 1`)
+	assert.NotNil(t, response.Timestamp)
+
+	s.metrics = nil
+	_, err = s.GetMetrics(context.Background(), &pb.GetMetricsRequest{})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestServerAuth(t *testing.T) {
+	authenticator, err := auth.NewAuthenticator(config.AuthConfig{Enabled: true, Keys: []config.APIKeyConfig{
+		{Name: "ci", Key: "ci-key", Scopes: []string{"submit", "read", "cancel"}, MaxConcurrent: 1,
+			RateLimit: 2},
+		{Name: "dev", Key: "dev-key", Scopes: []string{"submit", "read", "cancel"}},
+	}})
+	assert.Nil(t, err)
+	ci, err := authenticator.Authenticate("ci-key")
+	assert.Nil(t, err)
+	dev, err := authenticator.Authenticate("dev-key")
+	assert.Nil(t, err)
+	manager := jobs.NewManager(jobs.NewMemoryStore(), fakeRunner{}, jobs.Options{Workers: 1})
+	cfg := &config.Config{Repository: config.RepositoryConfig{AllowedHosts: []string{"github.com"}}}
+	s := NewServer(cfg, "localhost:0", core.GetLogger(), manager, nil, authenticator)
+	ciCtx := auth.NewContext(context.Background(), ci)
+	devCtx := auth.NewContext(context.Background(), dev)
+
+	_, err = s.SubmitAnalysis(ciCtx, &pb.SubmitAnalysisRequest{
+		Repository: "https://gitlab.com/repo", Analyses: []string{"burndown"}})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	// the manager is not started, so the job stays queued
+	submitted, err := s.SubmitAnalysis(ciCtx, &pb.SubmitAnalysisRequest{
+		Repository: "https://github.com/repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	_, err = s.SubmitAnalysis(ciCtx, &pb.SubmitAnalysisRequest{
+		Repository: "https://github.com/repo", Analyses: []string{"burndown"}})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, err = s.GetAnalysisStatus(devCtx, &pb.GetAnalysisStatusRequest{JobId: submitted.JobId})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.CancelAnalysis(devCtx, &pb.CancelAnalysisRequest{JobId: submitted.JobId})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	response, err := s.CancelAnalysis(ciCtx, &pb.CancelAnalysisRequest{JobId: submitted.JobId})
+	assert.Nil(t, err)
+	assert.Equal(t, "cancelled", response.Status)
+	job, err := manager.Get(submitted.JobId)
+	assert.Nil(t, err)
+	assert.Equal(t, "ci", job.Request.Tenant)
+	// the submission rejected by the concurrency quota did not consume the rate quota
+	second, err := s.SubmitAnalysis(ciCtx, &pb.SubmitAnalysisRequest{
+		Repository: "https://github.com/repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	_, err = s.CancelAnalysis(ciCtx, &pb.CancelAnalysisRequest{JobId: second.JobId})
+	assert.Nil(t, err)
+	_, err = s.SubmitAnalysis(ciCtx, &pb.SubmitAnalysisRequest{
+		Repository: "https://github.com/repo", Analyses: []string{"burndown"}})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Contains(t, err.Error(), auth.ErrRateLimited.Error())
+}
@@ -0,0 +1,137 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltJobsBucket    = []byte("jobs")
+	boltResultsBucket = []byte("results")
+)
+
+// BoltStore keeps the jobs in a BoltDB file so that they survive restarts.
+// The job records and their results are stored in separate buckets so that List()
+// does not have to decode the potentially large results.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens or creates the BoltDB database at `path`.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("bolt job store requires a database path")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create job store directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltJobsBucket, boltResultsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize job store %s: %w", path, err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Put inserts or replaces the job.
+func (store *BoltStore) Put(job *Job) error {
+	record := job.Copy()
+	results := record.Results
+	record.Results = nil
+	jobData, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	var resultsData []byte
+	if results != nil {
+		if resultsData, err = json.Marshal(results); err != nil {
+			return err
+		}
+	}
+	return store.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(job.ID)
+		if err := tx.Bucket(boltJobsBucket).Put(key, jobData); err != nil {
+			return err
+		}
+		if resultsData == nil {
+			return tx.Bucket(boltResultsBucket).Delete(key)
+		}
+		return tx.Bucket(boltResultsBucket).Put(key, resultsData)
+	})
+}
+
+// Get returns the job with its results or ErrNotFound.
+func (store *BoltStore) Get(id string) (*Job, error) {
+	var job *Job
+	err := store.db.View(func(tx *bolt.Tx) error {
+		key := []byte(id)
+		data := tx.Bucket(boltJobsBucket).Get(key)
+		if data == nil {
+			return ErrNotFound
+		}
+		job = &Job{}
+		if err := json.Unmarshal(data, job); err != nil {
+			return err
+		}
+		if data = tx.Bucket(boltResultsBucket).Get(key); data != nil {
+			return json.Unmarshal(data, &job.Results)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// List returns all the jobs without their results, ordered by creation time.
+func (store *BoltStore) List() ([]*Job, error) {
+	var jobs []*Job
+	err := store.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltJobsBucket).ForEach(func(key, data []byte) error {
+			job := &Job{}
+			if err := json.Unmarshal(data, job); err != nil {
+				return fmt.Errorf("corrupted job %s: %w", key, err)
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortJobs(jobs)
+	return jobs, nil
+}
+
+// Delete removes the job and its results.
+func (store *BoltStore) Delete(id string) error {
+	return store.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(id)
+		if err := tx.Bucket(boltJobsBucket).Delete(key); err != nil {
+			return err
+		}
+		return tx.Bucket(boltResultsBucket).Delete(key)
+	})
+}
+
+// Close closes the database file.
+func (store *BoltStore) Close() error {
+	return store.db.Close()
+}
@@ -0,0 +1,163 @@
+// Package jobs implements the analysis job subsystem shared by the HTTP and gRPC servers.
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// State is the lifecycle stage of a Job.
+type State string
+
+const (
+	// StateQueued means that the job waits for a free worker.
+	StateQueued State = "queued"
+	// StateRunning means that the job is being analysed.
+	StateRunning State = "running"
+	// StateCompleted means that the job finished successfully and has results.
+	StateCompleted State = "completed"
+	// StateFailed means that the job finished with an error.
+	StateFailed State = "failed"
+	// StateCancelled means that the job was cancelled before it could finish.
+	StateCancelled State = "cancelled"
+)
+
+// Terminal returns true if the job in this state will never change again.
+func (state State) Terminal() bool {
+	return state == StateCompleted || state == StateFailed || state == StateCancelled
+}
+
+var (
+	// ErrNotFound is returned when the requested job does not exist.
+	ErrNotFound = errors.New("job not found")
+	// ErrQueueFull is returned by Manager.Submit() when too many jobs are waiting.
+	ErrQueueFull = errors.New("too many queued analyses")
+	// ErrFinished is returned by Manager.Cancel() when the job has already finished.
+	ErrFinished = errors.New("job already finished")
+	// ErrQuotaExceeded is returned by Manager.SubmitLimited() when the tenant has too many
+	// queued and running jobs.
+	ErrQuotaExceeded = errors.New("too many concurrent analyses of the tenant")
+)
+
+// Request describes what to analyse.
+type Request struct {
+	Repository string            `json:"repository"`
+	Analyses   []string          `json:"analyses"`
+	Options    map[string]string `json:"options,omitempty"`
+	// Tenant is the name of the API key which submitted the job. Empty if the
+	// authentication is disabled.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// Result is the serialized outcome of a single analysis.
+type Result struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	ResultType  string `json:"result_type"`
+	// Binary is the Protocol Buffers serialization written by LeafPipelineItem.Serialize().
+	Binary []byte `json:"binary,omitempty"`
+	// Text is the YAML serialization written by LeafPipelineItem.Serialize().
+	Text []byte `json:"text,omitempty"`
+}
+
+// Metadata describes the analysed commit sequence. It mirrors pb.Metadata.
+type Metadata struct {
+	// BeginUnixTime is the UNIX timestamp of the first analysed commit.
+	BeginUnixTime int64 `json:"begin_unix_time"`
+	// EndUnixTime is the UNIX timestamp of the last analysed commit.
+	EndUnixTime int64 `json:"end_unix_time"`
+	// Commits is the number of processed commits.
+	Commits int `json:"commits"`
+	// RunTime is the duration of the analysis in milliseconds.
+	RunTime int64 `json:"run_time"`
+	// RunTimePerItem is the time taken by each pipeline item in seconds.
+	RunTimePerItem map[string]float64 `json:"run_time_per_item,omitempty"`
+}
+
+// Report is the outcome of a successful Runner.Run().
+type Report struct {
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// Results are keyed by the requested analysis names.
+	Results map[string]*Result `json:"results"`
+}
+
+// Job is a single analysis request together with its state and results.
+type Job struct {
+	ID         string    `json:"id"`
+	State      State     `json:"state"`
+	Request    Request   `json:"request"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	StartedAt  time.Time `json:"started_at,omitempty"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	// Metadata is only set in completed jobs.
+	Metadata *Metadata `json:"metadata,omitempty"`
+	// Results are keyed by the requested analysis names. They are only set in completed jobs.
+	Results map[string]*Result `json:"results,omitempty"`
+}
+
+// Copy returns a deep copy of the job except the immutable result contents.
+func (job *Job) Copy() *Job {
+	clone := *job
+	clone.Request.Analyses = append([]string(nil), job.Request.Analyses...)
+	if job.Request.Options != nil {
+		clone.Request.Options = make(map[string]string, len(job.Request.Options))
+		for key, val := range job.Request.Options {
+			clone.Request.Options[key] = val
+		}
+	}
+	if job.Metadata != nil {
+		metadata := *job.Metadata
+		clone.Metadata = &metadata
+	}
+	if job.Results != nil {
+		clone.Results = make(map[string]*Result, len(job.Results))
+		for key, val := range job.Results {
+			clone.Results[key] = val
+		}
+	}
+	return &clone
+}
+
+// JSON converts the YAML text serialization of the result to JSON.
+func (result *Result) JSON() ([]byte, error) {
+	var data interface{}
+	if err := yaml.Unmarshal(result.Text, &data); err != nil {
+		return json.Marshal(string(result.Text))
+	}
+	return json.Marshal(jsonCompatible(data))
+}
+
+// jsonCompatible recursively converts map[interface{}]interface{} produced by the YAML
+// decoder to map[string]interface{}.
+func jsonCompatible(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for key, val := range x {
+			m[fmt.Sprint(key)] = jsonCompatible(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range x {
+			x[i] = jsonCompatible(val)
+		}
+		return x
+	default:
+		return x
+	}
+}
+
+func newJobID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return "job_" + hex.EncodeToString(buf)
+}
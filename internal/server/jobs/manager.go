@@ -0,0 +1,378 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+)
+
+// Runner executes the analyses of a job. It is implemented by the analysis engine.
+type Runner interface {
+	Run(ctx context.Context, request Request) (*Report, error)
+}
+
+// Options configure a Manager.
+type Options struct {
+	// Workers is the maximum number of concurrently running analyses.
+	Workers int
+	// MaxQueued is the maximum number of jobs waiting for a worker. 0 means no limit.
+	MaxQueued int
+	// Retention is how long finished jobs are kept. 0 means forever.
+	Retention time.Duration
+	// Timeout limits the duration of each analysis. 0 means no limit.
+	Timeout time.Duration
+	// EvictionInterval is how often the expired jobs are looked for. Defaults to one minute.
+	EvictionInterval time.Duration
+	// Logger is used to report the job failures. Defaults to core.GetLogger().
+	Logger core.Logger
+}
+
+// Manager schedules the submitted jobs on a fixed pool of workers, tracks their
+// state in a Store, evicts the expired finished jobs and re-queues the jobs which
+// were interrupted by a restart.
+type Manager struct {
+	store   Store
+	runner  Runner
+	options Options
+	l       core.Logger
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	pending []string
+	// running maps the IDs of the running jobs to the functions which cancel them.
+	running map[string]context.CancelCauseFunc
+	started bool
+	stopped bool
+	stop    chan struct{}
+}
+
+// NewManager creates a Manager. Call Start() to begin processing the jobs.
+func NewManager(store Store, runner Runner, options Options) *Manager {
+	if options.Workers <= 0 {
+		options.Workers = 1
+	}
+	if options.EvictionInterval <= 0 {
+		options.EvictionInterval = time.Minute
+	}
+	m := &Manager{
+		store:   store,
+		runner:  runner,
+		options: options,
+		l:       options.Logger,
+		running: map[string]context.CancelCauseFunc{},
+		stop:    make(chan struct{}),
+	}
+	if m.l == nil {
+		m.l = core.GetLogger()
+	}
+	m.cond = sync.NewCond(&m.mutex)
+	return m
+}
+
+// Start recovers the interrupted jobs and launches the workers and the eviction loop.
+func (m *Manager) Start() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.started {
+		return fmt.Errorf("job manager is already started")
+	}
+	if err := m.recover(); err != nil {
+		return err
+	}
+	m.started = true
+	for i := 0; i < m.options.Workers; i++ {
+		go m.work()
+	}
+	if m.options.Retention > 0 {
+		go m.evictLoop()
+	}
+	return nil
+}
+
+// Stop makes the workers exit after they finish the current jobs and stops the eviction.
+// It does not wait for the running analyses; they are re-queued after a restart if the
+// store is persistent.
+func (m *Manager) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stopped {
+		return
+	}
+	m.stopped = true
+	close(m.stop)
+	m.cond.Broadcast()
+}
+
+// Submit creates a new queued job.
+func (m *Manager) Submit(request Request) (*Job, error) {
+	return m.SubmitLimited(request, 0, nil)
+}
+
+// SubmitLimited creates a new queued job unless the tenant of the request already has
+// `maxActive` queued or running jobs, in which case it returns ErrQuotaExceeded.
+// 0 means no limit. `admit` is called after the other checks passed, right before the job
+// is created, so it may consume a rate quota only for the accepted jobs; its error rejects
+// the job and is returned as is. nil admits every job.
+func (m *Manager) SubmitLimited(request Request, maxActive int, admit func() error) (*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.options.MaxQueued > 0 && len(m.pending) >= m.options.MaxQueued {
+		return nil, ErrQueueFull
+	}
+	if maxActive > 0 {
+		active, err := m.countActive(request.Tenant)
+		if err != nil {
+			return nil, err
+		}
+		if active >= maxActive {
+			return nil, ErrQuotaExceeded
+		}
+	}
+	if admit != nil {
+		if err := admit(); err != nil {
+			return nil, err
+		}
+	}
+	job := &Job{
+		ID:        newJobID(),
+		State:     StateQueued,
+		Request:   request,
+		CreatedAt: time.Now(),
+	}
+	if err := m.store.Put(job); err != nil {
+		return nil, fmt.Errorf("failed to store job: %w", err)
+	}
+	m.pending = append(m.pending, job.ID)
+	m.cond.Signal()
+	return job.Copy(), nil
+}
+
+// Cancel stops the job. Queued jobs are cancelled immediately, running jobs are
+// cancelled and their analysis is interrupted at the next pipeline step. It returns
+// ErrNotFound if there is no such job and ErrFinished if the job has already finished.
+func (m *Manager) Cancel(id string) (*Job, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, err := m.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if job.State.Terminal() {
+		return job, ErrFinished
+	}
+	if cancel, exists := m.running[id]; exists {
+		cancel(errCancelled)
+	}
+	job.State = StateCancelled
+	job.FinishedAt = time.Now()
+	if err := m.store.Put(job); err != nil {
+		return nil, fmt.Errorf("failed to update job %s: %w", id, err)
+	}
+	return job, nil
+}
+
+// Get returns the job with its results or ErrNotFound.
+func (m *Manager) Get(id string) (*Job, error) {
+	return m.store.Get(id)
+}
+
+// List returns all the known jobs without their results.
+func (m *Manager) List() ([]*Job, error) {
+	return m.store.List()
+}
+
+// Count returns the number of known jobs in each state.
+func (m *Manager) Count() (map[State]int, error) {
+	jobs, err := m.store.List()
+	if err != nil {
+		return nil, err
+	}
+	counts := map[State]int{}
+	for _, job := range jobs {
+		counts[job.State]++
+	}
+	return counts, nil
+}
+
+// countActive returns the number of the queued and running jobs of the tenant.
+// Must be called with the mutex held.
+func (m *Manager) countActive(tenant string) (int, error) {
+	jobs, err := m.store.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list the stored jobs: %w", err)
+	}
+	active := 0
+	for _, job := range jobs {
+		if !job.State.Terminal() && job.Request.Tenant == tenant {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// recover re-queues the jobs which were queued or running when the previous process exited.
+// Must be called with the mutex held.
+func (m *Manager) recover() error {
+	jobs, err := m.store.List()
+	if err != nil {
+		return fmt.Errorf("failed to list the stored jobs: %w", err)
+	}
+	for _, job := range jobs {
+		if job.State.Terminal() {
+			continue
+		}
+		if job.State == StateRunning {
+			m.l.Warnf("re-queueing the interrupted job %s", job.ID)
+			job.State = StateQueued
+			job.StartedAt = time.Time{}
+			if err := m.store.Put(job); err != nil {
+				return fmt.Errorf("failed to re-queue job %s: %w", job.ID, err)
+			}
+		}
+		m.pending = append(m.pending, job.ID)
+	}
+	return nil
+}
+
+func (m *Manager) work() {
+	for {
+		job := m.next()
+		if job == nil {
+			return
+		}
+		report, err := m.run(job)
+		m.finish(job.ID, report, err)
+	}
+}
+
+// next blocks until there is a queued job and marks it as running. It returns nil
+// when the manager is stopped.
+func (m *Manager) next() *Job {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for {
+		for len(m.pending) == 0 && !m.stopped {
+			m.cond.Wait()
+		}
+		if m.stopped {
+			return nil
+		}
+		id := m.pending[0]
+		m.pending = m.pending[1:]
+		job, err := m.store.Get(id)
+		if err != nil {
+			m.l.Errorf("failed to load the queued job %s: %v", id, err)
+			continue
+		}
+		if job.State != StateQueued {
+			continue
+		}
+		job.State = StateRunning
+		job.StartedAt = time.Now()
+		if err := m.store.Put(job); err != nil {
+			m.l.Errorf("failed to update job %s: %v", id, err)
+			continue
+		}
+		return job
+	}
+}
+
+// errCancelled is the cause of the context of a job cancelled by Manager.Cancel().
+var errCancelled = errors.New("analysis cancelled")
+
+// errTimeout is the cause of the context of a job which ran longer than Options.Timeout.
+var errTimeout = errors.New("analysis timed out")
+
+func (m *Manager) run(job *Job) (report *Report, err error) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	if m.options.Timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeoutCause(ctx, m.options.Timeout, errTimeout)
+		defer cancelTimeout()
+	}
+	m.mutex.Lock()
+	m.running[job.ID] = cancel
+	m.mutex.Unlock()
+	defer func() {
+		m.mutex.Lock()
+		delete(m.running, job.ID)
+		m.mutex.Unlock()
+		cancel(nil)
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("analysis panicked: %v", r)
+		}
+	}()
+	report, err = m.runner.Run(ctx, job.Request)
+	if err != nil && context.Cause(ctx) == errTimeout {
+		err = fmt.Errorf("%w after %s", errTimeout, m.options.Timeout)
+	}
+	return report, err
+}
+
+func (m *Manager) finish(id string, report *Report, runErr error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	job, err := m.store.Get(id)
+	if err != nil {
+		m.l.Errorf("failed to load the finished job %s: %v", id, err)
+		return
+	}
+	if job.State.Terminal() {
+		return
+	}
+	job.FinishedAt = time.Now()
+	if runErr != nil {
+		job.State = StateFailed
+		job.Error = runErr.Error()
+		m.l.Errorf("analysis of %s failed: %v", job.Request.Repository, runErr)
+	} else {
+		job.State = StateCompleted
+		if report != nil {
+			job.Metadata = report.Metadata
+			job.Results = report.Results
+		}
+	}
+	if err := m.store.Put(job); err != nil {
+		m.l.Errorf("failed to update job %s: %v", id, err)
+	}
+}
+
+func (m *Manager) evictLoop() {
+	ticker := time.NewTicker(m.options.EvictionInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			if err := m.Evict(time.Now()); err != nil {
+				m.l.Errorf("failed to evict the expired jobs: %v", err)
+			}
+		}
+	}
+}
+
+// Evict deletes the finished jobs which are older than the retention period at `now`.
+func (m *Manager) Evict(now time.Time) error {
+	if m.options.Retention <= 0 {
+		return nil
+	}
+	jobs, err := m.store.List()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.State.Terminal() && now.Sub(job.FinishedAt) > m.options.Retention {
+			if err := m.store.Delete(job.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRunner struct {
+	block   chan struct{}
+	started chan string
+	err     error
+}
+
+func (runner *testRunner) Run(ctx context.Context, request Request) (*Report, error) {
+	if runner.started != nil {
+		runner.started <- request.Repository
+	}
+	if runner.block != nil {
+		select {
+		case <-runner.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if runner.err != nil {
+		return nil, runner.err
+	}
+	return &Report{
+		Metadata: &Metadata{Commits: 10},
+		Results:  map[string]*Result{request.Analyses[0]: {Name: request.Repository}},
+	}, nil
+}
+
+func waitForState(t *testing.T, manager *Manager, id string, state State) *Job {
+	for i := 0; i < 200; i++ {
+		job, err := manager.Get(id)
+		assert.Nil(t, err)
+		if job.State == state {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not reach the %s state", id, state)
+	return nil
+}
+
+func TestManagerRun(t *testing.T) {
+	manager := NewManager(NewMemoryStore(), &testRunner{}, Options{Workers: 2})
+	assert.Nil(t, manager.Start())
+	defer manager.Stop()
+	assert.NotNil(t, manager.Start())
+	job, err := manager.Submit(Request{Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	assert.Equal(t, StateQueued, job.State)
+	job = waitForState(t, manager, job.ID, StateCompleted)
+	assert.Equal(t, "repo", job.Results["burndown"].Name)
+	assert.Equal(t, 10, job.Metadata.Commits)
+	assert.False(t, job.StartedAt.IsZero())
+	assert.False(t, job.FinishedAt.IsZero())
+	counts, err := manager.Count()
+	assert.Nil(t, err)
+	assert.Equal(t, map[State]int{StateCompleted: 1}, counts)
+}
+
+func TestManagerRunFailure(t *testing.T) {
+	manager := NewManager(NewMemoryStore(), &testRunner{err: errors.New("boom")}, Options{})
+	assert.Nil(t, manager.Start())
+	defer manager.Stop()
+	job, err := manager.Submit(Request{Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	job = waitForState(t, manager, job.ID, StateFailed)
+	assert.Equal(t, "boom", job.Error)
+	assert.Nil(t, job.Results)
+}
+
+func TestManagerCancel(t *testing.T) {
+	runner := &testRunner{block: make(chan struct{}), started: make(chan string, 1)}
+	manager := NewManager(NewMemoryStore(), runner, Options{Workers: 1})
+	assert.Nil(t, manager.Start())
+	defer manager.Stop()
+	running, err := manager.Submit(Request{Repository: "1", Analyses: []string{"x"}})
+	assert.Nil(t, err)
+	<-runner.started
+	queued, err := manager.Submit(Request{Repository: "2", Analyses: []string{"x"}})
+	assert.Nil(t, err)
+	job, err := manager.Cancel(queued.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StateCancelled, job.State)
+	job, err = manager.Cancel(running.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StateCancelled, job.State)
+	assert.False(t, job.FinishedAt.IsZero())
+	// the worker must pick up the next job after the cancelled one exits
+	third, err := manager.Submit(Request{Repository: "3", Analyses: []string{"x"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "3", <-runner.started)
+	close(runner.block)
+	waitForState(t, manager, third.ID, StateCompleted)
+	job = waitForState(t, manager, running.ID, StateCancelled)
+	assert.Nil(t, job.Results)
+	_, err = manager.Cancel(third.ID)
+	assert.Equal(t, ErrFinished, err)
+	_, err = manager.Cancel("missing")
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestManagerTimeout(t *testing.T) {
+	runner := &testRunner{block: make(chan struct{})}
+	manager := NewManager(NewMemoryStore(), runner, Options{Timeout: 10 * time.Millisecond})
+	assert.Nil(t, manager.Start())
+	defer manager.Stop()
+	job, err := manager.Submit(Request{Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	job = waitForState(t, manager, job.ID, StateFailed)
+	assert.Equal(t, "analysis timed out after 10ms", job.Error)
+}
+
+func TestManagerQueueLimit(t *testing.T) {
+	runner := &testRunner{block: make(chan struct{}), started: make(chan string, 1)}
+	manager := NewManager(NewMemoryStore(), runner, Options{Workers: 1, MaxQueued: 1})
+	assert.Nil(t, manager.Start())
+	defer manager.Stop()
+	first, err := manager.Submit(Request{Repository: "1", Analyses: []string{"x"}})
+	assert.Nil(t, err)
+	<-runner.started
+	// the running job does not occupy the queue
+	second, err := manager.Submit(Request{Repository: "2", Analyses: []string{"x"}})
+	assert.Nil(t, err)
+	_, err = manager.Submit(Request{Repository: "3", Analyses: []string{"x"}})
+	assert.Equal(t, ErrQueueFull, err)
+	waitForState(t, manager, first.ID, StateRunning)
+	waitForState(t, manager, second.ID, StateQueued)
+	close(runner.block)
+	<-runner.started
+	waitForState(t, manager, second.ID, StateCompleted)
+	// finished jobs do not count towards the limit
+	_, err = manager.Submit(Request{Repository: "4", Analyses: []string{"x"}})
+	assert.Nil(t, err)
+}
+
+func TestManagerTenantQuota(t *testing.T) {
+	runner := &testRunner{block: make(chan struct{}), started: make(chan string, 3)}
+	manager := NewManager(NewMemoryStore(), runner, Options{Workers: 1})
+	assert.Nil(t, manager.Start())
+	defer manager.Stop()
+	first, err := manager.SubmitLimited(Request{Repository: "1", Analyses: []string{"x"}, Tenant: "a"}, 2, nil)
+	assert.Nil(t, err)
+	second, err := manager.SubmitLimited(Request{Repository: "2", Analyses: []string{"x"}, Tenant: "a"}, 2, nil)
+	assert.Nil(t, err)
+	_, err = manager.SubmitLimited(Request{Repository: "3", Analyses: []string{"x"}, Tenant: "a"}, 2, nil)
+	assert.Equal(t, ErrQuotaExceeded, err)
+	// other tenants have their own quotas
+	third, err := manager.SubmitLimited(Request{Repository: "4", Analyses: []string{"x"}, Tenant: "b"}, 1, nil)
+	assert.Nil(t, err)
+	_, err = manager.Cancel(second.ID)
+	assert.Nil(t, err)
+	// cancelled jobs do not count towards the quota
+	fourth, err := manager.SubmitLimited(Request{Repository: "5", Analyses: []string{"x"}, Tenant: "a"}, 2, nil)
+	assert.Nil(t, err)
+	close(runner.block)
+	for _, job := range []*Job{first, third, fourth} {
+		waitForState(t, manager, job.ID, StateCompleted)
+	}
+	assert.Equal(t, "a", fourth.Request.Tenant)
+}
+
+func TestManagerSubmitAdmit(t *testing.T) {
+	manager := NewManager(NewMemoryStore(), &testRunner{}, Options{Workers: 1})
+	admitted := 0
+	admit := func() error {
+		admitted++
+		return nil
+	}
+	_, err := manager.SubmitLimited(Request{Repository: "1", Analyses: []string{"x"}, Tenant: "a"}, 1, admit)
+	assert.Nil(t, err)
+	_, err = manager.SubmitLimited(Request{Repository: "2", Analyses: []string{"x"}, Tenant: "a"}, 1, admit)
+	assert.Equal(t, ErrQuotaExceeded, err)
+	// the rejected jobs are not admitted
+	assert.Equal(t, 1, admitted)
+	rejected := errors.New("rejected")
+	_, err = manager.SubmitLimited(Request{Repository: "3", Analyses: []string{"x"}, Tenant: "b"}, 0,
+		func() error { return rejected })
+	assert.Equal(t, rejected, err)
+	jobs, err := manager.List()
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 1)
+}
+
+func TestManagerEvict(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	assert.Nil(t, store.Put(&Job{ID: "old", State: StateCompleted, FinishedAt: now.Add(-2 * time.Hour)}))
+	assert.Nil(t, store.Put(&Job{ID: "new", State: StateFailed, FinishedAt: now.Add(-time.Minute)}))
+	assert.Nil(t, store.Put(&Job{ID: "queued", State: StateQueued}))
+	manager := NewManager(store, &testRunner{}, Options{Retention: time.Hour})
+	assert.Nil(t, manager.Evict(now))
+	_, err := store.Get("old")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = store.Get("new")
+	assert.Nil(t, err)
+	_, err = store.Get("queued")
+	assert.Nil(t, err)
+}
+
+func TestManagerRecover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	store, err := NewBoltStore(path)
+	assert.Nil(t, err)
+	now := time.Now()
+	assert.Nil(t, store.Put(&Job{ID: "running", State: StateRunning, StartedAt: now,
+		CreatedAt: now, Request: Request{Repository: "1", Analyses: []string{"x"}}}))
+	assert.Nil(t, store.Put(&Job{ID: "queued", State: StateQueued,
+		CreatedAt: now.Add(time.Second), Request: Request{Repository: "2", Analyses: []string{"x"}}}))
+	assert.Nil(t, store.Put(&Job{ID: "done", State: StateCompleted, CreatedAt: now}))
+	assert.Nil(t, store.Close())
+
+	store, err = NewBoltStore(path)
+	assert.Nil(t, err)
+	defer store.Close()
+	runner := &testRunner{started: make(chan string, 2)}
+	manager := NewManager(store, runner, Options{Workers: 1})
+	assert.Nil(t, manager.Start())
+	defer manager.Stop()
+	assert.Equal(t, "1", <-runner.started)
+	assert.Equal(t, "2", <-runner.started)
+	waitForState(t, manager, "running", StateCompleted)
+	waitForState(t, manager, "queued", StateCompleted)
+	job, _ := manager.Get("done")
+	assert.Equal(t, StateCompleted, job.State)
+}
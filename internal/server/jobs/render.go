@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/dmytrogajewski/hercules/internal/pkg/version"
+	"google.golang.org/protobuf/proto"
+)
+
+// Result serialization formats accepted by Render().
+const (
+	// FormatYAML is the text output of `hercules`.
+	FormatYAML = "yaml"
+	// FormatJSON is the JSON equivalent of FormatYAML.
+	FormatJSON = "json"
+	// FormatProtobuf is the pb.AnalysisResults message of `hercules --pb`
+	// which `hercules combine` reads.
+	FormatProtobuf = "pb"
+)
+
+// Select returns the names of the job's results in the requested order. An empty
+// `analyses` selects all the results. It fails if any analysis has no result.
+func (job *Job) Select(analyses []string) ([]string, error) {
+	if len(analyses) == 0 {
+		analyses = job.Request.Analyses
+	}
+	names := make([]string, 0, len(analyses))
+	for _, name := range analyses {
+		if _, exists := job.Results[name]; !exists {
+			return nil, fmt.Errorf("no results for analysis %s", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Render writes the selected results of the completed job in the specified format.
+// `analyses` is the list returned by Select().
+func Render(writer io.Writer, job *Job, analyses []string, format string) error {
+	switch format {
+	case FormatYAML:
+		return renderYAML(writer, job, analyses)
+	case FormatJSON:
+		return renderJSON(writer, job, analyses)
+	case FormatProtobuf:
+		return renderProtobuf(writer, job, analyses)
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func (job *Job) metadata() Metadata {
+	if job.Metadata == nil {
+		return Metadata{}
+	}
+	return *job.Metadata
+}
+
+func renderYAML(writer io.Writer, job *Job, analyses []string) error {
+	meta := job.metadata()
+	fmt.Fprintln(writer, "hercules:")
+	fmt.Fprintf(writer, "  version: %d\n", version.Binary)
+	fmt.Fprintln(writer, "  hash:", version.BinaryGitHash)
+	fmt.Fprintln(writer, "  repository:", job.Request.Repository)
+	fmt.Fprintln(writer, "  begin_unix_time:", meta.BeginUnixTime)
+	fmt.Fprintln(writer, "  end_unix_time:", meta.EndUnixTime)
+	fmt.Fprintln(writer, "  commits:", meta.Commits)
+	fmt.Fprintln(writer, "  run_time:", meta.RunTime)
+	for _, name := range analyses {
+		result := job.Results[name]
+		fmt.Fprintf(writer, "%s:\n", result.Name)
+		if _, err := writer.Write(result.Text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderJSON(writer io.Writer, job *Job, analyses []string) error {
+	meta := job.metadata()
+	document := map[string]interface{}{
+		"hercules": map[string]interface{}{
+			"version":         version.Binary,
+			"hash":            version.BinaryGitHash,
+			"repository":      job.Request.Repository,
+			"begin_unix_time": meta.BeginUnixTime,
+			"end_unix_time":   meta.EndUnixTime,
+			"commits":         meta.Commits,
+			"run_time":        meta.RunTime,
+		},
+	}
+	for _, name := range analyses {
+		result := job.Results[name]
+		data, err := result.JSON()
+		if err != nil {
+			return fmt.Errorf("failed to convert %s to JSON: %w", result.Name, err)
+		}
+		document[result.Name] = json.RawMessage(data)
+	}
+	return json.NewEncoder(writer).Encode(document)
+}
+
+func renderProtobuf(writer io.Writer, job *Job, analyses []string) error {
+	meta := job.metadata()
+	message := pb.AnalysisResults{
+		Header: &pb.Metadata{
+			Version:        2,
+			Hash:           version.BinaryGitHash,
+			Repository:     job.Request.Repository,
+			BeginUnixTime:  meta.BeginUnixTime,
+			EndUnixTime:    meta.EndUnixTime,
+			Commits:        int32(meta.Commits),
+			RunTime:        meta.RunTime,
+			RunTimePerItem: meta.RunTimePerItem,
+		},
+		Contents: map[string][]byte{},
+	}
+	for _, name := range analyses {
+		result := job.Results[name]
+		message.Contents[result.Name] = result.Binary
+	}
+	serialized, err := proto.Marshal(&message)
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(serialized)
+	return err
+}
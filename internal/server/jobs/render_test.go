@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/dmytrogajewski/hercules/api/proto/pb"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func newRenderedJob() *Job {
+	return &Job{
+		ID:    "job_1",
+		State: StateCompleted,
+		Request: Request{Repository: "https://github.com/src-d/hercules",
+			Analyses: []string{"burndown", "couples"}},
+		Metadata: &Metadata{BeginUnixTime: 100, EndUnixTime: 200, Commits: 3, RunTime: 50},
+		Results: map[string]*Result{
+			"burndown": {Name: "Burndown", Binary: []byte{1, 2}, Text: []byte("  granularity: 30\n")},
+			"couples":  {Name: "Couples", Binary: []byte{3}, Text: []byte("  files: [a, b]\n")},
+		},
+	}
+}
+
+func TestJobSelect(t *testing.T) {
+	job := newRenderedJob()
+	names, err := job.Select(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"burndown", "couples"}, names)
+	names, err = job.Select([]string{"couples"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"couples"}, names)
+	_, err = job.Select([]string{"devs"})
+	assert.EqualError(t, err, "no results for analysis devs")
+}
+
+func TestRenderYAML(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, Render(buffer, newRenderedJob(), []string{"couples"}, FormatYAML))
+	text := buffer.String()
+	assert.Contains(t, text, "hercules:\n")
+	assert.Contains(t, text, "  repository: https://github.com/src-d/hercules\n")
+	assert.Contains(t, text, "  commits: 3\n")
+	assert.Contains(t, text, "Couples:\n  files: [a, b]\n")
+	assert.NotContains(t, text, "Burndown:")
+}
+
+func TestRenderJSON(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, Render(buffer, newRenderedJob(), []string{"burndown", "couples"}, FormatJSON))
+	var document map[string]map[string]interface{}
+	assert.Nil(t, json.Unmarshal(buffer.Bytes(), &document))
+	assert.Equal(t, float64(3), document["hercules"]["commits"])
+	assert.Equal(t, float64(30), document["Burndown"]["granularity"])
+	assert.Equal(t, []interface{}{"a", "b"}, document["Couples"]["files"])
+}
+
+func TestRenderProtobuf(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	assert.Nil(t, Render(buffer, newRenderedJob(), []string{"burndown"}, FormatProtobuf))
+	message := &pb.AnalysisResults{}
+	assert.Nil(t, proto.Unmarshal(buffer.Bytes(), message))
+	assert.Equal(t, int32(2), message.Header.Version)
+	assert.Equal(t, "https://github.com/src-d/hercules", message.Header.Repository)
+	assert.Equal(t, int64(100), message.Header.BeginUnixTime)
+	assert.Equal(t, int32(3), message.Header.Commits)
+	assert.Equal(t, map[string][]byte{"Burndown": {1, 2}}, message.Contents)
+	assert.NotNil(t, Render(buffer, newRenderedJob(), nil, "xml"))
+}
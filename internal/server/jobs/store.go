@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Store persists the jobs. Implementations must be safe for concurrent use.
+type Store interface {
+	// Put inserts or replaces the job.
+	Put(job *Job) error
+	// Get returns the job with its results or ErrNotFound.
+	Get(id string) (*Job, error)
+	// List returns all the jobs without their results, ordered by creation time.
+	List() ([]*Job, error)
+	// Delete removes the job and its results. Deleting a missing job is not an error.
+	Delete(id string) error
+	// Close releases the resources held by the store.
+	Close() error
+}
+
+// Store backend names accepted by NewStore().
+const (
+	StoreMemory = "memory"
+	StoreBolt   = "bolt"
+)
+
+// NewStore creates the store of the specified backend. `path` is the database file
+// and is ignored by the in-memory store.
+func NewStore(backend string, path string) (Store, error) {
+	switch backend {
+	case "", StoreMemory:
+		return NewMemoryStore(), nil
+	case StoreBolt:
+		return NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unsupported job store: %s", backend)
+	}
+}
+
+// MemoryStore keeps the jobs in process memory. They are lost on restart.
+type MemoryStore struct {
+	jobs  map[string]*Job
+	mutex sync.RWMutex
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: map[string]*Job{}}
+}
+
+// Put inserts or replaces the job.
+func (store *MemoryStore) Put(job *Job) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	store.jobs[job.ID] = job.Copy()
+	return nil
+}
+
+// Get returns the job with its results or ErrNotFound.
+func (store *MemoryStore) Get(id string) (*Job, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	job, exists := store.jobs[id]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return job.Copy(), nil
+}
+
+// List returns all the jobs without their results, ordered by creation time.
+func (store *MemoryStore) List() ([]*Job, error) {
+	store.mutex.RLock()
+	jobs := make([]*Job, 0, len(store.jobs))
+	for _, job := range store.jobs {
+		clone := job.Copy()
+		clone.Results = nil
+		jobs = append(jobs, clone)
+	}
+	store.mutex.RUnlock()
+	sortJobs(jobs)
+	return jobs, nil
+}
+
+// Delete removes the job.
+func (store *MemoryStore) Delete(id string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.jobs, id)
+	return nil
+}
+
+// Close does nothing.
+func (store *MemoryStore) Close() error {
+	return nil
+}
+
+func sortJobs(jobs []*Job) {
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].CreatedAt.Equal(jobs[j].CreatedAt) {
+			return jobs[i].ID < jobs[j].ID
+		}
+		return jobs[i].CreatedAt.Before(jobs[j].CreatedAt)
+	})
+}
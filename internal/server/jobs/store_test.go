@@ -0,0 +1,93 @@
+package jobs
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testStore(t *testing.T, store Store) {
+	now := time.Now().UTC().Truncate(time.Second)
+	first := &Job{ID: "job_1", State: StateQueued, CreatedAt: now,
+		Request: Request{Repository: "repo", Analyses: []string{"burndown"},
+			Options: map[string]string{"granularity": "15"}}}
+	second := &Job{ID: "job_2", State: StateCompleted, CreatedAt: now.Add(time.Second),
+		Metadata: &Metadata{Commits: 5},
+		Results:  map[string]*Result{"burndown": {Name: "Burndown", Binary: []byte{1}, Text: []byte("x")}}}
+	assert.Nil(t, store.Put(second))
+	assert.Nil(t, store.Put(first))
+
+	job, err := store.Get("job_1")
+	assert.Nil(t, err)
+	assert.Equal(t, "repo", job.Request.Repository)
+	assert.Equal(t, []string{"burndown"}, job.Request.Analyses)
+	assert.Equal(t, "15", job.Request.Options["granularity"])
+	assert.True(t, now.Equal(job.CreatedAt))
+	// modifying the returned job must not affect the store
+	job.State = StateRunning
+	job.Request.Options["granularity"] = "1"
+	job, _ = store.Get("job_1")
+	assert.Equal(t, StateQueued, job.State)
+	assert.Equal(t, "15", job.Request.Options["granularity"])
+
+	job, err = store.Get("job_2")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1}, job.Results["burndown"].Binary)
+	assert.Equal(t, []byte("x"), job.Results["burndown"].Text)
+	assert.Equal(t, 5, job.Metadata.Commits)
+
+	jobs, err := store.List()
+	assert.Nil(t, err)
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, "job_1", jobs[0].ID)
+	assert.Equal(t, "job_2", jobs[1].ID)
+	assert.Nil(t, jobs[1].Results)
+
+	assert.Nil(t, store.Delete("job_2"))
+	assert.Nil(t, store.Delete("job_2"))
+	_, err = store.Get("job_2")
+	assert.Equal(t, ErrNotFound, err)
+	jobs, _ = store.List()
+	assert.Len(t, jobs, 1)
+	assert.Nil(t, store.Close())
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestBoltStore(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "jobs", "jobs.db"))
+	assert.Nil(t, err)
+	testStore(t, store)
+}
+
+func TestBoltStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.db")
+	store, err := NewBoltStore(path)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Put(&Job{ID: "job_1", State: StateRunning, CreatedAt: time.Now()}))
+	assert.Nil(t, store.Close())
+	store, err = NewBoltStore(path)
+	assert.Nil(t, err)
+	defer store.Close()
+	job, err := store.Get("job_1")
+	assert.Nil(t, err)
+	assert.Equal(t, StateRunning, job.State)
+}
+
+func TestNewStore(t *testing.T) {
+	store, err := NewStore("", "")
+	assert.Nil(t, err)
+	assert.IsType(t, &MemoryStore{}, store)
+	store, err = NewStore(StoreBolt, filepath.Join(t.TempDir(), "jobs.db"))
+	assert.Nil(t, err)
+	assert.IsType(t, &BoltStore{}, store)
+	store.Close()
+	_, err = NewStore(StoreBolt, "")
+	assert.NotNil(t, err)
+	_, err = NewStore("redis", "")
+	assert.NotNil(t, err)
+}
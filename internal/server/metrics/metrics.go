@@ -0,0 +1,368 @@
+// Package metrics collects the operational metrics of the hercules server and exposes them
+// in the Prometheus format to the HTTP (/metrics) and gRPC (GetMetrics) front ends.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/server/jobs"
+)
+
+const namespace = "hercules"
+
+// heapSamplingInterval is the minimum period between the heap measurements of a pipeline.
+// runtime.ReadMemStats() stops the world, so it must not run on every commit.
+const heapSamplingInterval = time.Second
+
+// TextContentType is the MIME type of Text().
+const TextContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Run outcomes, the values of the "result" label of hercules_analysis_run_duration_seconds.
+const (
+	ResultCompleted = "completed"
+	ResultFailed    = "failed"
+	ResultCached    = "cached"
+)
+
+// Metrics holds the instruments shared by the analysis engine and the servers.
+// All the methods of a nil *Metrics do nothing, so the instrumentation is optional.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	runDuration      *prometheus.HistogramVec
+	analysisDuration *prometheus.HistogramVec
+	cloneDuration    prometheus.Histogram
+	commits          prometheus.Counter
+	pipelines        prometheus.Gauge
+	lastProgress     prometheus.Gauge
+	heap             prometheus.Gauge
+	peakHeap         prometheus.Histogram
+	resultCache      *prometheus.CounterVec
+}
+
+// New creates the metrics together with the Go runtime and process collectors.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		runDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "analysis_run_duration_seconds",
+			Help:      "Duration of the analysis jobs including cloning, by result: completed, failed or cached.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 4, 10),
+		}, []string{"result"}),
+		analysisDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "analysis_duration_seconds",
+			Help:      "Time spent in each analysis (leaf pipeline item) per run.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 4, 10),
+		}, []string{"analysis"}),
+		cloneDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "clone_duration_seconds",
+			Help:      "Duration of cloning or opening the analysed repositories.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 3, 10),
+		}),
+		commits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "commits_processed_total",
+			Help:      "Number of commits processed by all the pipelines; rate() gives commits per second.",
+		}),
+		pipelines: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pipelines_running",
+			Help:      "Number of pipelines which are currently running.",
+		}),
+		lastProgress: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pipeline_last_progress_timestamp_seconds",
+			Help:      "UNIX time of the last step made by any pipeline.",
+		}),
+		heap: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pipeline_heap_bytes",
+			Help:      "Heap size measured during the last pipeline step.",
+		}),
+		peakHeap: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "pipeline_peak_heap_bytes",
+			Help:      "Maximum heap size measured during each pipeline run.",
+			Buckets:   prometheus.ExponentialBuckets(16<<20, 2, 10),
+		}),
+		resultCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "result_cache_requests_total",
+			Help:      "Lookups of the analysis results in the cache by backend and result: hit or miss.",
+		}, []string{"backend", "result"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.runDuration, m.analysisDuration, m.cloneDuration, m.commits, m.pipelines,
+		m.lastProgress, m.heap, m.peakHeap, m.resultCache,
+	)
+	return m
+}
+
+// RegisterJobs exports the number of the jobs of the manager in each state.
+func (m *Metrics) RegisterJobs(manager *jobs.Manager) error {
+	if m == nil {
+		return nil
+	}
+	return m.registry.Register(&jobsCollector{manager: manager, desc: prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "jobs"),
+		"Number of the known analysis jobs by state.", []string{"state"}, nil)})
+}
+
+// RegisterCache exports the counters of the cache backend if it implements
+// core.CacheStatsProvider. The series are labelled with the backend type, e.g. "LocalCache".
+func (m *Metrics) RegisterCache(backend core.CacheBackend) error {
+	if m == nil {
+		return nil
+	}
+	provider, ok := backend.(core.CacheStatsProvider)
+	if !ok {
+		return nil
+	}
+	return m.registry.Register(newCacheCollector(BackendName(backend), provider))
+}
+
+// BackendName returns the label of the cache backend, e.g. "MemoryCache".
+func BackendName(backend core.CacheBackend) string {
+	name := fmt.Sprintf("%T", backend)
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Text returns the metrics in the Prometheus text exposition format.
+func (m *Metrics) Text() (string, error) {
+	if m == nil {
+		return "", nil
+	}
+	families, err := m.registry.Gather()
+	if err != nil {
+		return "", err
+	}
+	buffer := &bytes.Buffer{}
+	encoder := expfmt.NewEncoder(buffer, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		if err := encoder.Encode(family); err != nil {
+			return "", err
+		}
+	}
+	return buffer.String(), nil
+}
+
+// ObserveRun records the duration of an analysis job with the specified result.
+func (m *Metrics) ObserveRun(result string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.runDuration.WithLabelValues(result).Observe(duration.Seconds())
+}
+
+// ObserveClone records the duration of cloning a repository.
+func (m *Metrics) ObserveClone(duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.cloneDuration.Observe(duration.Seconds())
+}
+
+// ObserveResultCache records a lookup of the analysis results in the cache backend.
+func (m *Metrics) ObserveResultCache(backend core.CacheBackend, hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.resultCache.WithLabelValues(BackendName(backend), result).Inc()
+}
+
+// PipelineTracker instruments a single pipeline run. Its Progress method is intended
+// to be core.Pipeline.OnProgress.
+type PipelineTracker struct {
+	metrics *Metrics
+
+	mutex      sync.Mutex
+	peakHeap   uint64
+	lastSample time.Time
+}
+
+// StartPipeline begins tracking a pipeline run. Call Finish() after the run.
+func (m *Metrics) StartPipeline() *PipelineTracker {
+	if m == nil {
+		return nil
+	}
+	m.pipelines.Inc()
+	m.lastProgress.SetToCurrentTime()
+	tracker := &PipelineTracker{metrics: m}
+	tracker.sampleHeap(time.Now())
+	return tracker
+}
+
+// Progress implements the signature of core.Pipeline.OnProgress.
+func (tracker *PipelineTracker) Progress(step, total int, action string) {
+	if tracker == nil {
+		return
+	}
+	now := time.Now()
+	tracker.metrics.lastProgress.Set(float64(now.UnixNano()) / 1e9)
+	if isCommitAction(action) {
+		tracker.metrics.commits.Inc()
+	}
+	tracker.sampleHeap(now)
+}
+
+// Finish records the time spent in each of the `analyses` (leaf names) from
+// core.CommonAnalysisResult.RunTimePerItem and the peak heap size. `runTimePerItem`
+// is nil if the run failed.
+func (tracker *PipelineTracker) Finish(analyses []string, runTimePerItem map[string]float64) {
+	if tracker == nil {
+		return
+	}
+	tracker.metrics.pipelines.Dec()
+	if runTimePerItem == nil {
+		return
+	}
+	for _, analysis := range analyses {
+		if seconds, exists := runTimePerItem[analysis]; exists {
+			tracker.metrics.analysisDuration.WithLabelValues(analysis).Observe(seconds)
+		}
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.metrics.peakHeap.Observe(float64(tracker.peakHeap))
+}
+
+// sampleHeap measures the heap if heapSamplingInterval has passed since the previous sample.
+func (tracker *PipelineTracker) sampleHeap(now time.Time) {
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	if now.Sub(tracker.lastSample) < heapSamplingInterval {
+		return
+	}
+	tracker.lastSample = now
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	tracker.metrics.heap.Set(float64(stats.HeapAlloc))
+	if stats.HeapAlloc > tracker.peakHeap {
+		tracker.peakHeap = stats.HeapAlloc
+	}
+}
+
+// isCommitAction returns true if the pipeline progress action is a commit. Pipeline reports
+// the abbreviated hashes of the commits and the names of the other actions, e.g. "fork^2".
+func isCommitAction(action string) bool {
+	if len(action) != 7 {
+		return false
+	}
+	for _, c := range action {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// jobsCollector counts the jobs of a jobs.Manager on every scrape.
+type jobsCollector struct {
+	manager *jobs.Manager
+	desc    *prometheus.Desc
+}
+
+// Describe implements prometheus.Collector.
+func (collector *jobsCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.desc
+}
+
+// Collect implements prometheus.Collector.
+func (collector *jobsCollector) Collect(metrics chan<- prometheus.Metric) {
+	counts, err := collector.manager.Count()
+	if err != nil {
+		metrics <- prometheus.NewInvalidMetric(collector.desc, err)
+		return
+	}
+	for _, state := range []jobs.State{jobs.StateQueued, jobs.StateRunning, jobs.StateCompleted,
+		jobs.StateFailed, jobs.StateCancelled} {
+		metrics <- prometheus.MustNewConstMetric(
+			collector.desc, prometheus.GaugeValue, float64(counts[state]), string(state))
+	}
+}
+
+// cacheCollector exports the core.CacheStats of a cache backend on every scrape.
+type cacheCollector struct {
+	backend  string
+	provider core.CacheStatsProvider
+
+	hits, misses, errors, evictions, expirations *prometheus.Desc
+	entries, size, maxSize                       *prometheus.Desc
+}
+
+func newCacheCollector(backend string, provider core.CacheStatsProvider) *cacheCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", name), help,
+			nil, prometheus.Labels{"backend": backend})
+	}
+	return &cacheCollector{
+		backend:     backend,
+		provider:    provider,
+		hits:        desc("hits_total", "Number of successful cache reads."),
+		misses:      desc("misses_total", "Number of cache reads of absent or expired values."),
+		errors:      desc("errors_total", "Number of cache reads which failed for other reasons."),
+		evictions:   desc("evictions_total", "Number of values evicted to stay within the cache size limit."),
+		expirations: desc("expirations_total", "Number of removed expired values."),
+		entries:     desc("entries", "Number of values in the cache."),
+		size:        desc("size_bytes", "Total size of the values in the cache."),
+		maxSize:     desc("max_size_bytes", "Limit of the cache size, 0 means no limit."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (collector *cacheCollector) Describe(descs chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{collector.hits, collector.misses, collector.errors,
+		collector.evictions, collector.expirations, collector.entries, collector.size, collector.maxSize} {
+		descs <- desc
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (collector *cacheCollector) Collect(metrics chan<- prometheus.Metric) {
+	stats := collector.provider.Stats()
+	for desc, value := range map[*prometheus.Desc]int64{
+		collector.hits:        stats.Hits,
+		collector.misses:      stats.Misses,
+		collector.errors:      stats.Errors,
+		collector.evictions:   stats.Evictions,
+		collector.expirations: stats.Expirations,
+	} {
+		metrics <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value))
+	}
+	for desc, value := range map[*prometheus.Desc]int64{
+		collector.entries: stats.Entries,
+		collector.size:    stats.Size,
+		collector.maxSize: stats.MaxSize,
+	} {
+		metrics <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(value))
+	}
+}
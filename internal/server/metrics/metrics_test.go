@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dmytrogajewski/hercules/internal/app/core"
+	"github.com/dmytrogajewski/hercules/internal/server/jobs"
+)
+
+type reportRunner struct{}
+
+func (reportRunner) Run(ctx context.Context, request jobs.Request) (*jobs.Report, error) {
+	return &jobs.Report{}, nil
+}
+
+func TestMetricsJobs(t *testing.T) {
+	m := New()
+	manager := jobs.NewManager(jobs.NewMemoryStore(), reportRunner{}, jobs.Options{})
+	assert.Nil(t, manager.Start())
+	defer manager.Stop()
+	assert.Nil(t, m.RegisterJobs(manager))
+	job, err := manager.Submit(jobs.Request{Repository: "repo", Analyses: []string{"burndown"}})
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		if job, err = manager.Get(job.ID); err == nil && job.State.Terminal() {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	text, err := m.Text()
+	assert.Nil(t, err)
+	assert.Contains(t, text, `hercules_jobs{state="completed"} 1`)
+	assert.Contains(t, text, `hercules_jobs{state="running"} 0`)
+}
+
+func TestMetricsCache(t *testing.T) {
+	m := New()
+	cache, err := core.NewMemoryCache(core.CacheConfig{MaxSize: 1024})
+	assert.Nil(t, err)
+	assert.Nil(t, m.RegisterCache(cache))
+	ctx := context.Background()
+	assert.Nil(t, cache.Set(ctx, "key", []byte("value"), time.Hour))
+	_, err = cache.Get(ctx, "key")
+	assert.Nil(t, err)
+	_, err = cache.Get(ctx, "missing")
+	assert.NotNil(t, err)
+	m.ObserveResultCache(cache, true)
+	m.ObserveResultCache(cache, false)
+	m.ObserveResultCache(cache, false)
+	text, err := m.Text()
+	assert.Nil(t, err)
+	assert.Contains(t, text, `hercules_cache_hits_total{backend="MemoryCache"} 1`)
+	assert.Contains(t, text, `hercules_cache_misses_total{backend="MemoryCache"} 1`)
+	assert.Contains(t, text, `hercules_cache_errors_total{backend="MemoryCache"} 0`)
+	assert.Contains(t, text, `hercules_cache_entries{backend="MemoryCache"} 1`)
+	assert.Contains(t, text, `hercules_cache_max_size_bytes{backend="MemoryCache"} 1024`)
+	assert.Contains(t, text,
+		`hercules_result_cache_requests_total{backend="MemoryCache",result="hit"} 1`)
+	assert.Contains(t, text,
+		`hercules_result_cache_requests_total{backend="MemoryCache",result="miss"} 2`)
+}
+
+func TestMetricsPipeline(t *testing.T) {
+	m := New()
+	tracker := m.StartPipeline()
+	text, err := m.Text()
+	assert.Nil(t, err)
+	assert.Contains(t, text, "hercules_pipelines_running 1")
+	for _, action := range []string{"0123abc", "fork^2", "89defab", "merge^2", "emerge", "finalize"} {
+		tracker.Progress(0, 6, action)
+	}
+	tracker.Finish([]string{"Burndown", "Couples"},
+		map[string]float64{"Burndown": 1.5, "TreeDiff": 0.5})
+	text, err = m.Text()
+	assert.Nil(t, err)
+	assert.Contains(t, text, "hercules_pipelines_running 0")
+	assert.Contains(t, text, "hercules_commits_processed_total 2")
+	assert.Contains(t, text, `hercules_analysis_duration_seconds_sum{analysis="Burndown"} 1.5`)
+	assert.NotContains(t, text, `analysis="Couples"`)
+	assert.NotContains(t, text, `analysis="TreeDiff"`)
+	assert.Contains(t, text, "hercules_pipeline_peak_heap_bytes_count 1")
+
+	failed := m.StartPipeline()
+	failed.Finish([]string{"Burndown"}, nil)
+	text, err = m.Text()
+	assert.Nil(t, err)
+	assert.Contains(t, text, `hercules_analysis_duration_seconds_count{analysis="Burndown"} 1`)
+	assert.Contains(t, text, "hercules_pipeline_peak_heap_bytes_count 1")
+}
+
+func TestMetricsRunAndClone(t *testing.T) {
+	m := New()
+	m.ObserveRun(ResultCompleted, 2*time.Second)
+	m.ObserveRun(ResultCached, time.Millisecond)
+	m.ObserveClone(3 * time.Second)
+	text, err := m.Text()
+	assert.Nil(t, err)
+	assert.Contains(t, text, `hercules_analysis_run_duration_seconds_sum{result="completed"} 2`)
+	assert.Contains(t, text, `hercules_analysis_run_duration_seconds_count{result="cached"} 1`)
+	assert.Contains(t, text, "hercules_clone_duration_seconds_sum 3")
+}
+
+func TestMetricsHandler(t *testing.T) {
+	m := New()
+	m.ObserveClone(time.Second)
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	response, err := http.Get(server.URL)
+	assert.Nil(t, err)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Contains(t, string(body), "hercules_clone_duration_seconds_count 1")
+	assert.Contains(t, string(body), "process_")
+}
+
+func TestMetricsNil(t *testing.T) {
+	var m *Metrics
+	assert.Nil(t, m.RegisterJobs(nil))
+	assert.Nil(t, m.RegisterCache(nil))
+	m.ObserveRun(ResultFailed, time.Second)
+	m.ObserveClone(time.Second)
+	m.ObserveResultCache(nil, true)
+	tracker := m.StartPipeline()
+	assert.Nil(t, tracker)
+	tracker.Progress(1, 2, "0123abc")
+	tracker.Finish(nil, nil)
+	text, err := m.Text()
+	assert.Nil(t, err)
+	assert.Empty(t, text)
+}
+
+func TestBackendName(t *testing.T) {
+	assert.Equal(t, "MemoryCache", BackendName(&core.MemoryCache{}))
+	assert.Equal(t, "LocalCache", BackendName(&core.LocalCache{}))
+	assert.Equal(t, "S3Cache", BackendName(&core.S3Cache{}))
+}
+
+func TestIsCommitAction(t *testing.T) {
+	assert.True(t, isCommitAction("0123abc"))
+	assert.False(t, isCommitAction("0123ABC"))
+	assert.False(t, isCommitAction("fork^12"))
+	assert.False(t, isCommitAction(core.MessageFinalize))
+	assert.False(t, isCommitAction(""))
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/dmytrogajewski/hercules/pkg/analyzers/analyze"
@@ -55,6 +56,13 @@ type FunctionMetrics struct {
 	ReturnStatements     int    `json:"return_statements"`
 }
 
+// LocatedFunctionMetrics holds complexity metrics for a function together with its line range
+type LocatedFunctionMetrics struct {
+	FunctionMetrics
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+}
+
 // ComplexityConfig holds configuration for complexity analysis
 type ComplexityConfig struct {
 	IncludeCognitiveComplexity bool
@@ -245,6 +253,33 @@ func (c *ComplexityAnalyzer) findFunctions(root *node.Node) []*node.Node {
 	return functions
 }
 
+// MeasureFunctions calculates metrics for every function in the UAST ordered by position.
+// The line ranges are 1-based and inclusive; they are zero if the UAST has no positions.
+func (c *ComplexityAnalyzer) MeasureFunctions(root *node.Node) []LocatedFunctionMetrics {
+	if root == nil {
+		return nil
+	}
+
+	functions := c.findFunctions(root)
+	result := make([]LocatedFunctionMetrics, 0, len(functions))
+	for _, fn := range functions {
+		metrics := LocatedFunctionMetrics{FunctionMetrics: c.calculateFunctionMetrics(fn)}
+		if fn.Pos != nil {
+			metrics.StartLine = int(fn.Pos.StartLine)
+			metrics.EndLine = int(fn.Pos.EndLine)
+		}
+		result = append(result, metrics)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].StartLine != result[j].StartLine {
+			return result[i].StartLine < result[j].StartLine
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
 // isFunctionNode checks if a node represents a function
 func (c *ComplexityAnalyzer) isFunctionNode(n *node.Node) bool {
 	if n == nil {
@@ -189,3 +189,40 @@ func TestComplexityAnalyzer_WithIfStatement(t *testing.T) {
 		t.Errorf("Expected total_complexity to be 2 for function with if, got %v", total)
 	}
 }
+
+func TestComplexityAnalyzer_MeasureFunctions(t *testing.T) {
+	analyzer := NewComplexityAnalyzer()
+
+	if functions := analyzer.MeasureFunctions(nil); functions != nil {
+		t.Errorf("Expected nil for nil root, got %v", functions)
+	}
+
+	newFunction := func(name string, startLine, endLine uint) *node.Node {
+		functionNode := node.NewWithType(node.UASTFunction)
+		functionNode.Roles = []node.Role{node.RoleFunction, node.RoleDeclaration}
+		functionNode.Props = map[string]string{"name": name}
+		functionNode.Pos = &node.Positions{StartLine: startLine, EndLine: endLine}
+		return functionNode
+	}
+	second := newFunction("second", 10, 20)
+	ifNode := node.NewWithType(node.UASTIf)
+	ifNode.Roles = []node.Role{node.RoleCondition}
+	second.AddChild(ifNode)
+	root := node.NewWithType(node.UASTFile)
+	root.AddChild(second)
+	root.AddChild(newFunction("first", 1, 5))
+
+	functions := analyzer.MeasureFunctions(root)
+	if len(functions) != 2 {
+		t.Fatalf("Expected 2 functions, got %d", len(functions))
+	}
+	if functions[0].Name != "first" || functions[0].StartLine != 1 || functions[0].EndLine != 5 {
+		t.Errorf("Unexpected first function %+v", functions[0])
+	}
+	if functions[1].Name != "second" || functions[1].StartLine != 10 || functions[1].EndLine != 20 {
+		t.Errorf("Unexpected second function %+v", functions[1])
+	}
+	if functions[1].CyclomaticComplexity != 2 {
+		t.Errorf("Expected cyclomatic complexity 2, got %d", functions[1].CyclomaticComplexity)
+	}
+}
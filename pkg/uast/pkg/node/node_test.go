@@ -812,3 +812,24 @@ func TestDSLMapChildren(t *testing.T) {
 		t.Errorf("Unexpected tokens: %v, %v", results[0].Token, results[1].Token)
 	}
 }
+
+func TestNodeSimilarity(t *testing.T) {
+	function := func(name, literal string) *Node {
+		return &Node{Type: "Function", Token: name, Children: []*Node{
+			{Type: "Identifier", Token: "x"},
+			{Type: "Return", Children: []*Node{{Type: "Literal", Token: literal}}},
+		}}
+	}
+	if sim := function("f", "1").Similarity(function("g", "1")); sim != 1 {
+		t.Errorf("Renamed function should be identical, got %v", sim)
+	}
+	if sim := function("f", "1").Similarity(function("f", "2")); sim != 0.75 {
+		t.Errorf("Expected 0.75, got %v", sim)
+	}
+	if sim := function("f", "1").Similarity(&Node{Type: "Class"}); sim != 0 {
+		t.Errorf("Expected 0, got %v", sim)
+	}
+	if sim := function("f", "1").Similarity(nil); sim != 0 {
+		t.Errorf("Expected 0 for nil, got %v", sim)
+	}
+}
@@ -0,0 +1,36 @@
+package node
+
+// Similarity returns the Dice coefficient between the subtrees rooted at n and other.
+// Each subtree is a bag of node types and tokens; the roots contribute only their types so that
+// renaming a function does not make it dissimilar. 1 means the same structure, 0 means
+// nothing in common. Positions, roles and properties are ignored.
+func (n *Node) Similarity(other *Node) float64 {
+	if n == nil || other == nil {
+		return 0
+	}
+	left, leftSize := similarityBag(n)
+	right, rightSize := similarityBag(other)
+	common := 0
+	for key, count := range left {
+		if otherCount := right[key]; otherCount < count {
+			common += otherCount
+		} else {
+			common += count
+		}
+	}
+	return 2 * float64(common) / float64(leftSize+rightSize)
+}
+
+func similarityBag(root *Node) (map[string]int, int) {
+	bag := map[string]int{}
+	size := 0
+	root.VisitPreOrder(func(n *Node) {
+		key := string(n.Type)
+		if n != root {
+			key += "\x00" + n.Token
+		}
+		bag[key]++
+		size++
+	})
+	return bag, size
+}